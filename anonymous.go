@@ -0,0 +1,86 @@
+package fastac
+
+import (
+	"github.com/oarkflow/govaluate"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/util"
+)
+
+const (
+	// ANONYMOUS is the default principal of unauthenticated requests and the pseudo-role of the anonymous principal
+	ANONYMOUS = util.ANONYMOUS
+	// AUTHENTICATED is the pseudo-role of all other subjects
+	AUTHENTICATED = "authenticated"
+)
+
+// Option to model unauthenticated requests, principal is the subject of unauthenticated requests (default: ANONYMOUS).
+// Subjects are members of the pseudo-roles ANONYMOUS or AUTHENTICATED without stored links, an empty subject is anonymous.
+// The functions of all role definitions and the built-in matcher functions isAnonymous(r.sub) and isAuthenticated(r.sub) respect them.
+// Role managers must be set before the option, SetRoleManager replaces the role function.
+//
+//	p, anonymous, /public/*, GET
+//	p, authenticated, /profile, GET
+//	g, authenticated, reader
+//
+//	NewEnforcer(model, adapter, OptionAnonymous(""))
+func OptionAnonymous(principal string) Option {
+	return func(e *Enforcer) error {
+		if principal == "" {
+			principal = ANONYMOUS
+		}
+		e.anonymous = principal
+		e.model.SetFunction("isAnonymous", util.AnonymousFunc(principal, true))
+		e.model.SetFunction("isAuthenticated", util.AnonymousFunc(principal, false))
+		functions := e.model.GetFunctions()
+		e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+			if g, ok := functions[key]; ok {
+				e.model.SetFunction(key, e.pseudoRoleFunc(g))
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+// EnforceAnonymous decides a request of the anonymous principal, params are the request values following the subject
+//
+//	e.EnforceAnonymous("/public/index.html", "GET")
+func (e *Enforcer) EnforceAnonymous(params ...interface{}) (bool, error) {
+	return e.Enforce(append([]interface{}{e.anonymousPrincipal()}, params...)...)
+}
+
+// IsAnonymous returns true, if sub is the anonymous principal or empty
+func (e *Enforcer) IsAnonymous(sub string) bool {
+	return sub == "" || sub == e.anonymousPrincipal()
+}
+
+func (e *Enforcer) anonymousPrincipal() string {
+	if e.anonymous == "" {
+		return ANONYMOUS
+	}
+	return e.anonymous
+}
+
+// pseudoRoleFunc extends a role function with the pseudo-roles, a subject has a role,
+// if it is linked to it or its pseudo-role is linked to it
+func (e *Enforcer) pseudoRoleFunc(g govaluate.ExpressionFunction) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		res, err := g(args...)
+		if err != nil || res == true || len(args) < 2 {
+			return res, err
+		}
+		sub, ok := args[0].(string)
+		if !ok {
+			return res, err
+		}
+		pseudo := make([]interface{}, len(args))
+		copy(pseudo, args)
+		pseudo[0] = AUTHENTICATED
+		if e.IsAnonymous(sub) {
+			pseudo[0] = ANONYMOUS
+		}
+		return g(pseudo...)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The FastAC Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"github.com/oarkflow/fastac/model/types"
+)
+
+// The interfaces below are the stable core of FastAC. Integrators should depend on them instead of the
+// concrete types, so that the enforcer, adapters and role managers can be mocked or replaced without
+// importing the implementation packages. storage.Adapter, cluster.Watcher, rbac.IRoleManager and
+// effector.IEffector are aliases of these interfaces.
+
+// Enforcer decides requests and manages the policy rules.
+// Integrators, which only need a part of it, should depend on the smaller interfaces it consists of.
+type Enforcer interface {
+	Decider
+	RuleManager
+	PolicyStore
+}
+
+// Decider decides requests
+type Decider interface {
+	// Enforce decides whether a request is allowed
+	Enforce(params ...interface{}) (bool, error)
+	// EnforceEx decides like Enforce and additionally returns the rule responsible for the result
+	EnforceEx(params ...interface{}) (bool, []string, error)
+	// Decide decides like Enforce, but returns the whole decision
+	Decide(params ...interface{}) Decision
+}
+
+// RuleManager adds and removes policy rules
+type RuleManager interface {
+	AddRule(rule []string) (bool, error)
+	AddRules(rules [][]string) error
+	RemoveRule(rule []string) (bool, error)
+	RemoveRules(rules [][]string) error
+	HasRule(rule []string) bool
+}
+
+// PolicyStore loads and saves the policy rules through the adapter
+type PolicyStore interface {
+	// LoadPolicy adds the rules of the adapter, the rules already present are kept
+	LoadPolicy() error
+	// SavePolicy writes all rules to the adapter
+	SavePolicy() error
+}
+
+// Decision is the result of an enforcement
+type Decision interface {
+	// IsAllowed returns true, if the request is allowed
+	IsAllowed() bool
+	// GetError returns the error of the enforcement, the request is denied if it is not nil
+	GetError() error
+	// RawEffect returns the effect of the matching rules, Indeterminate if no matching rule has the effect of the decision
+	RawEffect() types.Effect
+	// HasEffect returns true, if one of the matching rules has the given effect
+	HasEffect(name string) bool
+	// Reason returns the first reason of the matching deny rules or "", if the decision has no reason
+	Reason() string
+	// Explain returns the rule including its policy key, which is responsible for the decision, or nil, if no rule is
+	Explain() []string
+	// RequestKey returns the canonical key of the request
+	RequestKey() (string, error)
+}
+
+// Adapter is the interface for Casbin adapters.
+type Adapter interface {
+	// LoadPolicy loads all policy rules from the storage.
+	LoadPolicy(model IAddRuleBool) error
+	// SavePolicy saves all policy rules to the storage.
+	SavePolicy(model IRangeRules) error
+}
+
+// Watcher notifies the other nodes of a cluster about changes of the shared adapter
+type Watcher interface {
+	// Update notifies all other nodes
+	Update() error
+	// SetUpdateCallback sets the function, which is called when another node sent an update
+	SetUpdateCallback(fn func()) error
+}
+
+// RoleManager provides interface to define the operations for managing roles.
+type RoleManager interface {
+	// Clear clears all stored data and resets the role manager to the initial state.
+	Clear() error
+	// AddLink adds the inheritance link between two roles. role: name1 and role: name2.
+	// domain is a prefix to the roles (can be used for other purposes).
+	AddLink(name1 string, name2 string, domain ...string) (bool, error)
+	// DeleteLink deletes the inheritance link between two roles. role: name1 and role: name2.
+	// domain is a prefix to the roles (can be used for other purposes).
+	DeleteLink(name1 string, name2 string, domain ...string) (bool, error)
+	// HasLink determines whether a link exists between two roles. role: name1 inherits role: name2.
+	// domain is a prefix to the roles (can be used for other purposes).
+	HasLink(name1 string, name2 string, domain ...string) (bool, error)
+	// GetRoles gets the roles that a user inherits.
+	// domain is a prefix to the roles (can be used for other purposes).
+	GetRoles(name string, domain ...string) ([]string, error)
+	// GetUsers gets the users that inherits a role.
+	// domain is a prefix to the users (can be used for other purposes).
+	GetUsers(name string, domain ...string) ([]string, error)
+
+	Range(fn func(name1, name2 string, domain ...string) bool)
+}
+
+// Effector merges the effects of the matching rules
+type Effector interface {
+	// MergeEffects merges a list of effects into a single one
+	// This function gets called during the accumulation of effects and once more when all effects have been gathered
+	// Returns the effect and the rule, which is responsible for the result
+	MergeEffects(effects []types.Effect, matches [][]string, complete bool) (types.Effect, []string, error)
+}
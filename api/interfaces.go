@@ -38,6 +38,11 @@ type IAddRules interface {
 	AddRules(rules [][]string) error
 }
 
+// IAddRulesBulk adds multiple rules at once and returns the added rules
+type IAddRulesBulk interface {
+	AddRules(rules [][]string) ([][]string, error)
+}
+
 type IRemoveRule interface {
 	RemoveRule(rule []string) error
 }
@@ -0,0 +1,38 @@
+package fastac
+
+import (
+	"fmt"
+)
+
+// BatchEnforce decides multiple requests with a single context.
+// The requests share the compiled matcher expressions and the results of the role lookups and built-in match functions,
+// so the batch should not span changes of the policy. Decision hooks and the decision log run for every request.
+// The first failing request stops the batch and its error is returned with the index of the request.
+//
+//	e.BatchEnforce([][]interface{}{
+//		{"alice", "data1", "read"},
+//		{"bob", "data2", "write"},
+//	})
+func (e *Enforcer) BatchEnforce(requests [][]interface{}, options ...ContextOption) ([]bool, error) {
+	ctx, err := NewContext(e.model, options...)
+	if err != nil {
+		return nil, err
+	}
+	return e.BatchEnforceWithContext(ctx, requests)
+}
+
+// BatchEnforceWithContext is like BatchEnforce with an existing context
+func (e *Enforcer) BatchEnforceWithContext(ctx *Context, requests [][]interface{}) ([]bool, error) {
+	batch := *ctx
+	batch.sessions = e.newMatcherSessions()
+
+	res := make([]bool, len(requests))
+	for i, rvals := range requests {
+		d := e.decide(&batch, rvals)
+		if d.Err != nil {
+			return nil, fmt.Errorf("error: request %d: %w", i, d.Err)
+		}
+		res[i] = d.Allowed
+	}
+	return res, nil
+}
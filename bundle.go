@@ -0,0 +1,132 @@
+package fastac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// BundleFormat identifies a bundle, BundleVersion is the current version of the format
+const (
+	BundleFormat  = "fastac-bundle"
+	BundleVersion = 1
+)
+
+// Bundle packages a model and its policy as a single JSON document.
+//
+//	{
+//	  "format": "fastac-bundle",
+//	  "version": 1,
+//	  "created": "2022-06-01T12:00:00Z",
+//	  "metadata": {"revision": "v1.4.0"},
+//	  "model": "[request_definition]\nr = sub, obj, act\n...",
+//	  "policy": [["p", "alice", "data1", "read"], ["g", "alice", "admin"]],
+//	  "digest": "sha256:..."
+//	}
+//
+// The digest covers the model and the policy, metadata is not part of it.
+// Readers reject bundles with a newer version or a mismatching digest.
+type Bundle struct {
+	Format   string            `json:"format"`
+	Version  int               `json:"version"`
+	Created  time.Time         `json:"created"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Model    string            `json:"model"`
+	Policy   [][]string        `json:"policy"`
+	Digest   string            `json:"digest"`
+}
+
+// NewBundle packages the model and all rules of the enforcer
+func NewBundle(e *Enforcer, metadata map[string]string) *Bundle {
+	rules := [][]string{}
+	e.model.RangeRules(func(rule []string) bool {
+		rules = append(rules, rule)
+		return true
+	})
+	util.SortRules(rules)
+
+	b := &Bundle{
+		Format:   BundleFormat,
+		Version:  BundleVersion,
+		Created:  time.Now().UTC(),
+		Metadata: metadata,
+		Model:    e.model.String(),
+		Policy:   rules,
+	}
+	b.Digest = b.digest()
+	return b
+}
+
+// ReadBundle decodes a bundle and verifies its format, version and digest
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	b := &Bundle{}
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, err
+	}
+	if b.Format != BundleFormat || b.Version < 1 || b.Version > BundleVersion {
+		return nil, fmt.Errorf(str.ERR_BUNDLE_FORMAT, b.Format, b.Version)
+	}
+	if b.Digest != b.digest() {
+		return nil, fmt.Errorf(str.ERR_BUNDLE_DIGEST, b.Digest)
+	}
+	return b, nil
+}
+
+// digest returns the SHA-256 hash of the model and the canonical policy
+func (b *Bundle) digest() string {
+	sum := sha256.Sum256([]byte(b.Model + "\n" + util.FormatPolicy(b.Policy)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Enforcer creates an enforcer from the bundle
+func (b *Bundle) Enforcer(options ...Option) (*Enforcer, error) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(b.Model); err != nil {
+		return nil, err
+	}
+	e, err := NewEnforcer(model, nil, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.AddRules(b.Policy); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WriteTo encodes the bundle as indented JSON
+func (b *Bundle) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// LoadBundle reads a bundle and creates an enforcer from it
+//
+//	f, _ := os.Open("authz.bundle.json")
+//	e, err := fastac.LoadBundle(f)
+func LoadBundle(r io.Reader, options ...Option) (*Enforcer, error) {
+	b, err := ReadBundle(r)
+	if err != nil {
+		return nil, err
+	}
+	return b.Enforcer(options...)
+}
+
+// SaveBundle writes the model and all rules as bundle to w
+//
+//	e.SaveBundle(f, map[string]string{"revision": os.Getenv("GIT_SHA")})
+func (e *Enforcer) SaveBundle(w io.Writer, metadata map[string]string) error {
+	_, err := NewBundle(e, metadata).WriteTo(w)
+	return err
+}
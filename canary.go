@@ -0,0 +1,165 @@
+package fastac
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oarkflow/fastac/str"
+)
+
+// CanaryStats compares the decisions of the primary and the candidate enforcer for the requests routed to a canary
+type CanaryStats struct {
+	// Percent is the percentage of the subjects, whose requests are decided by the candidate
+	Percent  float64
+	Requests uint64
+	// Agreements and Disagreements count the requests, which both enforcers decided equally or differently
+	Agreements    uint64
+	Disagreements uint64
+	// PrimaryTime and CandidateTime are the total durations of the evaluations
+	PrimaryTime   time.Duration
+	CandidateTime time.Duration
+}
+
+// AgreementRate returns the fraction of the requests, which both enforcers decided equally
+func (s CanaryStats) AgreementRate() float64 {
+	if s.Requests == 0 {
+		return 1
+	}
+	return float64(s.Agreements) / float64(s.Requests)
+}
+
+// LatencyDelta returns the mean duration of the candidate minus the mean duration of the primary
+func (s CanaryStats) LatencyDelta() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return (s.CandidateTime - s.PrimaryTime) / time.Duration(s.Requests)
+}
+
+type canary struct {
+	candidate *Enforcer
+	// threshold is the percentage in hundredths of a percent
+	threshold uint32
+
+	mutex sync.Mutex
+	stats CanaryStats
+}
+
+// Option to decide a percentage of the requests with a candidate enforcer, see StartCanary
+func OptionCanary(candidate *Enforcer, percent float64) Option {
+	return func(e *Enforcer) error {
+		return e.StartCanary(candidate, percent)
+	}
+}
+
+// StartCanary decides the requests of percent of the subjects with the candidate enforcer instead of e.
+// A subject is routed by the hash of the first request value, so its requests are decided consistently by the same enforcer.
+// The requests of the canary are evaluated by e as well, to record the agreement rate and the latency delta.
+// Like the shadow evaluation, the candidate is evaluated with the variables, functions and context of the request, but without other context options.
+// Cached decisions of OptionCache are not routed.
+//
+//	candidate, _ := NewEnforcer("model_v2.conf", adapterV2)
+//	e.StartCanary(candidate, 5)
+//	stats, _ := e.CanaryStats()
+//	if stats.Requests > 10000 && stats.AgreementRate() > 0.999 {
+//		e.PromoteCanary()
+//	}
+func (e *Enforcer) StartCanary(candidate *Enforcer, percent float64) error {
+	if candidate == nil {
+		return errors.New(str.ERR_NO_CANDIDATE)
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf(str.ERR_CANARY_PERCENT, percent)
+	}
+	e.canary = &canary{candidate: candidate, threshold: uint32(percent * 100), stats: CanaryStats{Percent: percent}}
+	return nil
+}
+
+// SetCanaryPercent changes the percentage of the subjects routed to the canary, the statistics are kept
+func (e *Enforcer) SetCanaryPercent(percent float64) error {
+	c := e.canary
+	if c == nil {
+		return errors.New(str.ERR_NO_CANARY)
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf(str.ERR_CANARY_PERCENT, percent)
+	}
+	atomic.StoreUint32(&c.threshold, uint32(percent*100))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Percent = percent
+	return nil
+}
+
+// CanaryStats returns the statistics of the canary, false if no canary is running
+func (e *Enforcer) CanaryStats() (CanaryStats, bool) {
+	c := e.canary
+	if c == nil {
+		return CanaryStats{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats, true
+}
+
+// PromoteCanary ends the canary and replaces the model of e by a copy of the model of the candidate, so all requests are decided by it.
+// The adapter of e is kept and stores the later changes of e, call SavePolicy to store the rules of the candidate.
+// The candidate is detached from e and keeps its own model and adapter.
+func (e *Enforcer) PromoteCanary() error {
+	c := e.canary
+	if c == nil {
+		return errors.New(str.ERR_NO_CANARY)
+	}
+	model, err := c.candidate.copyModel()
+	if err != nil {
+		return err
+	}
+	e.canary = nil
+	e.SetModel(model)
+	e.bindRoleFunctions()
+	return nil
+}
+
+// AbortCanary ends the canary, all requests are decided by e again
+func (e *Enforcer) AbortCanary() {
+	e.canary = nil
+}
+
+// routes returns true, if the request belongs to a subject of the canary
+func (c *canary) routes(rvals []interface{}) bool {
+	threshold := atomic.LoadUint32(&c.threshold)
+	if len(rvals) == 0 || threshold == 0 {
+		return false
+	}
+	h := fnv.New32a()
+	_, _ = fmt.Fprint(h, rvals[0])
+	return h.Sum32()%10000 < threshold
+}
+
+// decide decides the request with the candidate and compares it with the decision of the primary
+func (c *canary) decide(primary *Enforcer, ctx *Context, rvals []interface{}) *Decision {
+	start := time.Now()
+	p := primary.hooked(ctx, rvals)
+	primaryTime := time.Since(start)
+
+	start = time.Now()
+	d := candidateDecision(c.candidate, ctx, rvals)
+	candidateTime := time.Since(start)
+	d.Canary = true
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Requests++
+	if p.Allowed == d.Allowed && (p.Err == nil) == (d.Err == nil) {
+		c.stats.Agreements++
+	} else {
+		c.stats.Disagreements++
+	}
+	c.stats.PrimaryTime += primaryTime
+	c.stats.CandidateTime += candidateTime
+	return d
+}
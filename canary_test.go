@@ -0,0 +1,64 @@
+package fastac
+
+import (
+	"testing"
+
+	"github.com/oarkflow/fastac/api"
+	m "github.com/oarkflow/fastac/model"
+)
+
+// recordingAdapter records the rules added by autosave
+type recordingAdapter struct {
+	added [][]string
+}
+
+func (a *recordingAdapter) LoadPolicy(model api.IAddRuleBool) error { return nil }
+func (a *recordingAdapter) SavePolicy(model api.IRangeRules) error  { return nil }
+func (a *recordingAdapter) RemoveRule(rule []string) error          { return nil }
+func (a *recordingAdapter) AddRule(rule []string) error {
+	a.added = append(a.added, rule)
+	return nil
+}
+
+func testEnforcer(t *testing.T, adapter api.Adapter, options ...Option) *Enforcer {
+	t.Helper()
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, adapter, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestPromoteCanary(t *testing.T) {
+	primaryAdapter, candidateAdapter := &recordingAdapter{}, &recordingAdapter{}
+	e := testEnforcer(t, primaryAdapter, OptionAutosave(true))
+	candidate := testEnforcer(t, candidateAdapter, OptionAutosave(true))
+	if _, err := candidate.AddRule([]string{"p", "bob", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	candidateAdapter.added = nil
+
+	if err := e.StartCanary(candidate, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.PromoteCanary(); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := e.Enforce("bob", "data1", "read"); !allowed {
+		t.Fatal("rule of the candidate has not been promoted")
+	}
+
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(primaryAdapter.added) != 1 || len(candidateAdapter.added) != 0 {
+		t.Fatalf("saved to primary %v and candidate %v, want only primary", primaryAdapter.added, candidateAdapter.added)
+	}
+	if allowed, _ := candidate.Enforce("alice", "data1", "read"); allowed {
+		t.Fatal("rule of the primary has been added to the candidate")
+	}
+}
@@ -0,0 +1,246 @@
+package fastac
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oarkflow/govaluate"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/matcher"
+	"github.com/oarkflow/fastac/rbac"
+	a "github.com/oarkflow/fastac/storage/adapter"
+	"github.com/oarkflow/fastac/str"
+)
+
+// Clone returns an independent copy of the enforcer for what-if analysis.
+// The model, all rules and the role graphs are copied, so changes of the clone never affect e and vice versa.
+// The clone keeps the functions, request limits, vocabulary, preprocessors, hooks and the logger of e,
+// but has no adapter, decision cache, write-ahead log, shadow or canary, is never read-only and not accounted by Tenants.
+// Every role manager is replaced by an empty one of the same kind, which receives the links of the copied rules,
+// an error is returned for role managers, which do not implement rbac.IRenewableRoleManager.
+//
+//	whatIf, _ := e.Clone()
+//	whatIf.RemoveRule([]string{"g", "alice", "admin"})
+//	whatIf.Enforce("alice", "data1", "read")
+func (e *Enforcer) Clone() (*Enforcer, error) {
+	model, err := e.copyModel()
+	if err != nil {
+		return nil, err
+	}
+	clone := e.derive(model)
+	clone.bindRoleFunctions()
+	return clone, nil
+}
+
+// Fork returns a copy-on-write copy of the enforcer for speculative changes and simulations.
+// Forking is cheap even for large policies: until its first change, the fork decides with the model of e.
+// The first change copies the model and the rules of e at that moment like Clone, from then on the fork is independent.
+// GetModel, SetOption, LoadPolicy, ReloadPolicy and AddDynamicGroup copy the model as well, since they may change it,
+// ViewModel returns the shared model for reading.
+// Like a clone, the fork has no adapter, so neither e nor its storage are ever changed by the fork.
+//
+//	fork := e.Fork()
+//	fork.AddRule([]string{"g", "bob", "admin"})
+//	d := fork.EnforceDecision("bob", "data1", "write")
+func (e *Enforcer) Fork() *Enforcer {
+	fork := e.derive(e.model)
+	fork.forkOf = e
+	return fork
+}
+
+// derive returns an enforcer with the configuration of e, but without adapter
+func (e *Enforcer) derive(model m.IModel) *Enforcer {
+	derived := &Enforcer{
+		model:         model,
+		vocab:         e.vocab,
+		strictVocab:   e.strictVocab,
+		limits:        e.limits,
+		regex:         e.regex,
+		regexArgs:     e.regexArgs,
+		regexCtx:      e.regexCtx,
+		failOpen:      e.failOpen,
+		defaultEffect: e.defaultEffect,
+		preprocessors: append([]preprocessor(nil), e.preprocessors...),
+		hooks:         append([]decisionHook(nil), e.hooks...),
+		messages:      e.messages,
+		logger:        e.logger,
+		anonymous:     e.anonymous,
+		dynamic:       e.dynamic.copy(),
+		enforceHooks:  append([]enforceHook(nil), e.enforceHooks...),
+		env:           e.env,
+		encoder:       e.encoder,
+	}
+	derived.matchers = newMatcherCache(model, DefaultMatcherCacheSize)
+	derived.detachStorage()
+	if version, ok := e.policyVersion.Load().(string); ok {
+		derived.policyVersion.Store(version)
+	}
+	return derived
+}
+
+// detachStorage replaces the adapter by a disabled NoopAdapter
+func (e *Enforcer) detachStorage() {
+	e.SetAdapter(&a.NoopAdapter{})
+	e.sc.Disable()
+}
+
+// own copies the model of the enforcer a fork has been created of, before the fork changes its model
+func (e *Enforcer) own() error {
+	e.forkMutex.Lock()
+	defer e.forkMutex.Unlock()
+	if e.forkOf == nil {
+		return nil
+	}
+	model, err := e.forkOf.copyModel()
+	if err != nil {
+		return err
+	}
+	e.forkOf = nil
+	e.SetModel(model)
+	e.detachStorage()
+	e.bindRoleFunctions()
+	return nil
+}
+
+// bindRoleFunctions extends the role functions of a copied model with the pseudo-roles and dynamic groups of e
+func (e *Enforcer) bindRoleFunctions() {
+	if e.anonymous == "" && !e.dynamic.isBound() {
+		return
+	}
+	functions := e.model.GetFunctions()
+	e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+		if g, ok := functions[key]; ok {
+			e.model.SetFunction(key, e.wrapRoleFunc(g))
+		}
+		return true
+	})
+}
+
+// wrapRoleFunc extends a role function with the pseudo-roles and dynamic groups of e
+func (e *Enforcer) wrapRoleFunc(g govaluate.ExpressionFunction) govaluate.ExpressionFunction {
+	if e.anonymous != "" {
+		g = e.pseudoRoleFunc(g)
+	}
+	if e.dynamic.isBound() {
+		g = e.dynamic.roleFunc(g)
+	}
+	return g
+}
+
+// functionContext returns goctx with the functions bound to the enforcer or to goctx, goctx may be nil.
+// Role functions pass a cancellable goctx to the role managers, so role lookups stop, when goctx is done.
+// If regex is true, regexMatch is replaced by the util.SafeRegex of OptionSafeRegex, which stops as well.
+// Functions shadowed by SetFunction are kept. goctx is returned unchanged, if no function has to be bound.
+// Without a cancellable goctx, the functions bound by OptionSafeRegex are reused, so no function map is built.
+func (e *Enforcer) functionContext(goctx context.Context, regex bool) context.Context {
+	regex = regex && e.regex != nil
+	cancellable := goctx != nil && goctx.Done() != nil
+	if !cancellable && !regex {
+		return goctx
+	}
+	if !cancellable && goctx == nil {
+		return e.regexCtx
+	}
+	if goctx == nil {
+		goctx = context.Background()
+	}
+	shadowed := matcher.Functions(goctx)
+	if !cancellable {
+		if _, ok := shadowed["regexMatch"]; ok {
+			return goctx
+		}
+		if len(shadowed) == 0 {
+			return matcher.WithFunctions(goctx, matcher.Functions(e.regexCtx))
+		}
+	}
+	functions := make(map[string]govaluate.ExpressionFunction, len(shadowed)+2)
+	for name, function := range shadowed {
+		functions[name] = function
+	}
+	if cancellable {
+		e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+			if _, ok := functions[key]; ok {
+				return true
+			}
+			rm, ok := e.model.GetRoleManager(key)
+			if _, budget := rm.(rbac.IBudgetRoleManager); ok && budget {
+				functions[key] = e.wrapRoleFunc(rbac.GenerateGFunctionWithContext(goctx, rm))
+			}
+			return true
+		})
+	}
+	if _, ok := functions["regexMatch"]; regex && !ok {
+		functions["regexMatch"] = e.regex.FuncContext(goctx)
+	}
+	return matcher.WithFunctions(goctx, functions)
+}
+
+// copySettings copies the expression profile and the functions of the model of e to model
+func (e *Enforcer) copySettings(model *m.Model) error {
+	if profile := e.model.GetProfile(); profile != nil {
+		if err := model.SetProfile(profile); err != nil {
+			return err
+		}
+	}
+	// role functions and actMatch are bound to the role managers and action groups of their model
+	bound := map[string]struct{}{"actMatch": {}}
+	e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+		bound[key] = struct{}{}
+		return true
+	})
+	model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+		bound[key] = struct{}{}
+		return true
+	})
+	for name, function := range e.model.GetFunctions() {
+		if _, ok := bound[name]; !ok {
+			model.SetFunction(name, function)
+		}
+	}
+	return nil
+}
+
+// copyModel returns a copy of the model with a snapshot of its rules
+func (e *Enforcer) copyModel() (*m.Model, error) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(e.model.String()); err != nil {
+		return nil, err
+	}
+	if err := e.copySettings(model); err != nil {
+		return nil, err
+	}
+	if err := e.renewRoleManagers(model); err != nil {
+		return nil, err
+	}
+
+	rules := [][]string{}
+	e.model.Snapshot().RangeRules(func(rule []string) bool {
+		rules = append(rules, rule)
+		return true
+	})
+	if _, err := model.AddRules(rules); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// renewRoleManagers sets empty copies of the role managers of e in model, before the rules are copied
+func (e *Enforcer) renewRoleManagers(model *m.Model) error {
+	var err error
+	e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+		rm, ok := e.model.GetRoleManager(key)
+		if !ok || rm == nil {
+			return true
+		}
+		renewable, ok := rm.(rbac.IRenewableRoleManager)
+		if !ok {
+			err = fmt.Errorf(str.ERR_RM_NOT_RENEWABLE, key)
+			return false
+		}
+		model.SetRoleManager(key, renewable.Renew())
+		return true
+	})
+	return err
+}
@@ -0,0 +1,325 @@
+// Package cluster coordinates the enforcers of a cluster, which share a storage adapter.
+//
+// Only the elected leader writes to the adapter, followers are read-only
+// and reload the rules, when the leader notifies them through the Watcher.
+// The lock service and the notification channel are plugged in, e.g. for redis (go-redis):
+//
+//	locker := cluster.LockerFuncs{
+//		TryLockFunc: func(ctx context.Context, id string, ttl time.Duration) (uint64, bool, error) {
+//			// acquire or extend the lock, if it is held by id, and INCR fastac:epoch on acquisition
+//			token, err := script.Run(ctx, rdb, []string{"fastac:leader", "fastac:epoch"}, id, ttl.Milliseconds()).Int64()
+//			return uint64(token), token > 0, err
+//		},
+//		UnlockFunc: func(ctx context.Context, id string) error {
+//			return unlockScript.Run(ctx, rdb, []string{"fastac:leader"}, id).Err()
+//		},
+//	}
+//
+// or for etcd with a lease, which is kept alive while the session exists, and the revision of the key as token.
+//
+// A leader can be deposed without noticing it, e.g. while it is paused by the garbage collector.
+// Its writes are fenced by the token of the lock, which is passed to adapters implementing storage.FencedAdapter.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/storage"
+)
+
+// Locker is a distributed lock with expiry
+type Locker interface {
+	// TryLock acquires the lock for id or extends it, if id already holds it.
+	// It returns false, if another id holds the lock.
+	// The fencing token has to increase every time the lock is acquired and stay the same while it is extended.
+	TryLock(ctx context.Context, id string, ttl time.Duration) (token uint64, ok bool, err error)
+	// Unlock releases the lock, if id holds it
+	Unlock(ctx context.Context, id string) error
+}
+
+// LockerFuncs adapts functions to the Locker interface
+type LockerFuncs struct {
+	TryLockFunc func(ctx context.Context, id string, ttl time.Duration) (uint64, bool, error)
+	UnlockFunc  func(ctx context.Context, id string) error
+}
+
+func (l LockerFuncs) TryLock(ctx context.Context, id string, ttl time.Duration) (uint64, bool, error) {
+	return l.TryLockFunc(ctx, id, ttl)
+}
+
+func (l LockerFuncs) Unlock(ctx context.Context, id string) error {
+	return l.UnlockFunc(ctx, id)
+}
+
+// Watcher notifies the other nodes of a cluster about changes of the shared adapter, see api.Watcher
+type Watcher = api.Watcher
+
+// MemoryLocker is a Locker for nodes in a single process, e.g. for tests
+type MemoryLocker struct {
+	mutex   sync.Mutex
+	holder  string
+	expires time.Time
+	token   uint64
+}
+
+func (l *MemoryLocker) TryLock(ctx context.Context, id string, ttl time.Duration) (uint64, bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	held := l.holder != "" && now.Before(l.expires)
+	if held && l.holder != id {
+		return 0, false, nil
+	}
+	if !held {
+		l.token++
+	}
+	l.holder, l.expires = id, now.Add(ttl)
+	return l.token, true, nil
+}
+
+func (l *MemoryLocker) Unlock(ctx context.Context, id string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.holder == id {
+		l.holder = ""
+	}
+	return nil
+}
+
+// Events of a node
+const (
+	// ELECTED is emitted, when the node became the leader
+	ELECTED = "elected"
+	// DEPOSED is emitted, when the node lost the leadership
+	DEPOSED = "deposed"
+	// RELOADED is emitted, when a follower reloaded the rules, the handler receives the error of the reload
+	RELOADED = "reloaded"
+)
+
+// Node runs an enforcer as leader or follower of a cluster.
+// The leader has storage enabled and notifies the followers after every flush, autosave is enabled,
+// if it was enabled when the node was created. Followers are read-only and have storage disabled.
+// The role changes and reloads hold the write lock of the SyncedEnforcer, so they do not race with requests.
+type Node struct {
+	*emitter.Emitter
+
+	e       *fastac.SyncedEnforcer
+	id      string
+	locker  Locker
+	watcher Watcher
+	ttl     time.Duration
+	// autosave is the autosave setting of the storage controller, when the node was created
+	autosave bool
+
+	mutex    sync.Mutex
+	leader   bool
+	token    uint64
+	expires  time.Time
+	listener *emitter.Listener
+}
+
+// NewNode creates a follower node with the unique id. The leadership expires after ttl, if it is not renewed.
+//
+//	e, _ := fastac.NewSyncedEnforcer("model.conf", adapter, fastac.OptionAutosave(true))
+//	node, _ := cluster.NewNode(e, hostname, locker, watcher, 10*time.Second)
+//	go node.Run(ctx)
+func NewNode(e *fastac.SyncedEnforcer, id string, locker Locker, watcher Watcher, ttl time.Duration) (*Node, error) {
+	n := &Node{
+		Emitter: emitter.NewEmitter(false),
+		e:       e,
+		id:      id,
+		locker:  locker,
+		watcher: watcher,
+		ttl:     ttl,
+	}
+	err := e.SetOption(func(e *fastac.Enforcer) error {
+		n.autosave = e.GetStorageController().AutosaveEnabled()
+		return n.follow(e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.SetUpdateCallback(n.onUpdate); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// ID returns the id of the node
+func (n *Node) ID() string {
+	return n.id
+}
+
+// IsLeader returns true, if the node is the leader
+func (n *Node) IsLeader() bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.leader
+}
+
+// Run campaigns for the leadership and renews it every third of the ttl until ctx is done.
+// The leadership is released, when Run returns.
+func (n *Node) Run(ctx context.Context) error {
+	ticker := time.NewTicker(n.ttl / 3)
+	defer ticker.Stop()
+	for {
+		if err := n.Campaign(ctx); err != nil && ctx.Err() == nil {
+			n.e.GetLogger().Warn("leader election failed", "node", n.id, "error", err)
+			n.setLeader(false)
+		}
+		select {
+		case <-ctx.Done():
+			return n.Resign(context.Background())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Token returns the fencing token of the leadership, it is 0, if the node is not the leader
+func (n *Node) Token() uint64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.token
+}
+
+// Campaign acquires or renews the leadership once
+func (n *Node) Campaign(ctx context.Context) error {
+	// the lease is counted from before the request, it can only expire earlier on the lock service
+	expires := time.Now().Add(n.ttl)
+	token, ok, err := n.locker.TryLock(ctx, n.id, n.ttl)
+	if err != nil {
+		return err
+	}
+	if ok {
+		n.setToken(token, expires)
+	}
+	n.setLeader(ok)
+	return nil
+}
+
+// Resign releases the leadership
+func (n *Node) Resign(ctx context.Context) error {
+	wasLeader := n.IsLeader()
+	n.setLeader(false)
+	if !wasLeader {
+		return nil
+	}
+	return n.locker.Unlock(ctx, n.id)
+}
+
+// SavePolicy saves all rules to the adapter and notifies the followers,
+// it fails on followers and on a leader, whose lease has expired without being renewed.
+func (n *Node) SavePolicy() error {
+	n.mutex.Lock()
+	valid := n.leader && time.Now().Before(n.expires)
+	n.mutex.Unlock()
+	if !valid {
+		return &NotLeaderError{n.id}
+	}
+	if err := n.e.SavePolicy(); err != nil {
+		return err
+	}
+	return n.watcher.Update()
+}
+
+// NotLeaderError is returned, if a node has to be the leader for an operation
+type NotLeaderError struct {
+	ID string
+}
+
+func (err *NotLeaderError) Error() string {
+	return fmt.Sprintf("error: node %s is not the leader", err.ID)
+}
+
+// setToken stores the fencing token and passes it to the adapter, if it changed
+func (n *Node) setToken(token uint64, expires time.Time) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.expires = expires
+	if n.token == token {
+		return
+	}
+	n.token = token
+	if fa, ok := n.e.GetAdapter().(storage.FencedAdapter); ok {
+		fa.SetFencingToken(token)
+	}
+}
+
+func (n *Node) setLeader(leader bool) {
+	n.mutex.Lock()
+	changed := n.leader != leader
+	n.leader = leader
+	if !leader {
+		n.token, n.expires = 0, time.Time{}
+	}
+	var err error
+	if changed && leader {
+		err = n.e.SetOption(n.lead)
+	} else if changed {
+		err = n.e.SetOption(n.follow)
+	}
+	n.mutex.Unlock()
+	if err != nil {
+		n.e.GetLogger().Error("changing the role of the node failed", "node", n.id, "error", err)
+	}
+
+	if changed && leader {
+		n.e.GetLogger().Info("node elected as leader", "node", n.id)
+		n.EmitEvent(ELECTED, n.id)
+	} else if changed {
+		n.e.GetLogger().Info("node deposed as leader", "node", n.id)
+		n.EmitEvent(DEPOSED, n.id)
+	}
+}
+
+// lead enables writes and notifies the followers after every flush, it is applied as option under the write lock of the enforcer
+func (n *Node) lead(e *fastac.Enforcer) error {
+	sc := e.GetStorageController()
+	sc.Enable()
+	if n.autosave {
+		sc.EnableAutosave()
+	}
+	n.listener = sc.AddListener(storage.FLUSHED, func(arguments ...interface{}) {
+		if err := n.watcher.Update(); err != nil {
+			n.e.GetLogger().Warn("notifying followers failed", "node", n.id, "error", err)
+		}
+	})
+	e.SetReadOnly(false)
+	return nil
+}
+
+// follow disables writes, it is applied as option under the write lock of the enforcer
+func (n *Node) follow(e *fastac.Enforcer) error {
+	e.SetReadOnly(true)
+	sc := e.GetStorageController()
+	if n.listener != nil {
+		sc.RemoveListener(storage.FLUSHED, n.listener)
+		n.listener = nil
+	}
+	sc.DisableAutosave()
+	sc.Disable()
+	return nil
+}
+
+func (n *Node) onUpdate() {
+	if n.IsLeader() {
+		return
+	}
+	err := n.Reload()
+	if err != nil {
+		n.e.GetLogger().Error("reloading policy failed", "node", n.id, "error", err)
+	}
+	n.EmitEvent(RELOADED, err)
+}
+
+// Reload replaces the rules of the enforcer by the rules of the adapter.
+// Only the differences are applied while requests wait, so they never see an empty model.
+func (n *Node) Reload() error {
+	return n.e.ReloadPolicy()
+}
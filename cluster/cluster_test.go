@@ -0,0 +1,251 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+const testModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = r.sub == p.sub && r.obj == p.obj && r.act == p.act
+`
+
+// hub connects the watchers of the nodes in a test, an update of one watcher calls the callbacks of the others
+type hub struct {
+	mutex    sync.Mutex
+	watchers []*hubWatcher
+}
+
+type hubWatcher struct {
+	h  *hub
+	fn func()
+}
+
+func (h *hub) watcher() *hubWatcher {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	w := &hubWatcher{h: h}
+	h.watchers = append(h.watchers, w)
+	return w
+}
+
+func (w *hubWatcher) Update() error {
+	w.h.mutex.Lock()
+	watchers := append([]*hubWatcher(nil), w.h.watchers...)
+	w.h.mutex.Unlock()
+	for _, other := range watchers {
+		if other != w && other.fn != nil {
+			other.fn()
+		}
+	}
+	return nil
+}
+
+func (w *hubWatcher) SetUpdateCallback(fn func()) error {
+	w.fn = fn
+	return nil
+}
+
+// events records the events of a node
+type events struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (ev *events) record(n *Node, event emitter.EventType) {
+	n.AddListener(event, func(arguments ...interface{}) {
+		ev.mutex.Lock()
+		defer ev.mutex.Unlock()
+		ev.events = append(ev.events, string(event))
+	})
+}
+
+func (ev *events) take() []string {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	res := ev.events
+	ev.events = nil
+	return res
+}
+
+func newNode(t *testing.T, id, path string, locker Locker, h *hub, ttl time.Duration) (*Node, *fastac.SyncedEnforcer, *events) {
+	t.Helper()
+	m := model.NewModel()
+	if err := m.LoadModelFromText(testModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := fastac.NewSyncedEnforcer(m, adapter.NewFileAdapter(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewNode(e, id, locker, h.watcher(), ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &events{}
+	for _, event := range []emitter.EventType{ELECTED, DEPOSED, RELOADED} {
+		ev.record(n, event)
+	}
+	return n, e, ev
+}
+
+func policyFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestElection(t *testing.T) {
+	ctx := context.Background()
+	locker, h, path := &MemoryLocker{}, &hub{}, policyFile(t)
+	a, ea, evA := newNode(t, "a", path, locker, h, time.Minute)
+	b, eb, evB := newNode(t, "b", path, locker, h, time.Minute)
+	if !ea.IsReadOnly() || !eb.IsReadOnly() {
+		t.Fatal("new nodes are not read-only followers")
+	}
+
+	if err := a.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("leaders: a=%v b=%v, want a", a.IsLeader(), b.IsLeader())
+	}
+	if ea.IsReadOnly() || !eb.IsReadOnly() {
+		t.Fatal("only the leader must accept writes")
+	}
+	tokenA := a.Token()
+	if tokenA == 0 || b.Token() != 0 {
+		t.Fatalf("tokens: a=%d b=%d", tokenA, b.Token())
+	}
+	// renewing keeps the token and emits no event
+	if err := a.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.Token() != tokenA {
+		t.Fatalf("renewal changed the token from %d to %d", tokenA, a.Token())
+	}
+	if got := evA.take(); !equal(got, []string{ELECTED}) {
+		t.Fatalf("events of a: %v", got)
+	}
+
+	if err := a.Resign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a.IsLeader() || !b.IsLeader() || !ea.IsReadOnly() || eb.IsReadOnly() {
+		t.Fatal("leadership has not moved to b")
+	}
+	if b.Token() <= tokenA {
+		t.Fatalf("token of the new leader %d does not fence the old one %d", b.Token(), tokenA)
+	}
+	if got := evA.take(); !equal(got, []string{DEPOSED}) {
+		t.Fatalf("events of a: %v", got)
+	}
+	if got := evB.take(); !equal(got, []string{ELECTED}) {
+		t.Fatalf("events of b: %v", got)
+	}
+}
+
+func TestSavePolicyFencing(t *testing.T) {
+	ctx := context.Background()
+	locker, h, path := &MemoryLocker{}, &hub{}, policyFile(t)
+	ttl := 50 * time.Millisecond
+	leader, el, _ := newNode(t, "leader", path, locker, h, ttl)
+	follower, _, _ := newNode(t, "follower", path, locker, h, ttl)
+	if err := leader.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := follower.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := el.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var notLeader *NotLeaderError
+	if err := follower.SavePolicy(); !errors.As(err, &notLeader) || notLeader.ID != "follower" {
+		t.Fatalf("SavePolicy of the follower: %v, want NotLeaderError", err)
+	}
+	if err := leader.SavePolicy(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease expires without being renewed
+	time.Sleep(ttl + 10*time.Millisecond)
+	if err := leader.SavePolicy(); !errors.As(err, &notLeader) || notLeader.ID != "leader" {
+		t.Fatalf("SavePolicy after the lease expired: %v, want NotLeaderError", err)
+	}
+}
+
+func TestFollowerReloadsOnUpdate(t *testing.T) {
+	ctx := context.Background()
+	locker, h, path := &MemoryLocker{}, &hub{}, policyFile(t)
+	leader, el, evL := newNode(t, "leader", path, locker, h, time.Minute)
+	_, ef, evF := newNode(t, "follower", path, locker, h, time.Minute)
+	if err := leader.Campaign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	evL.take()
+
+	if _, err := ef.AddRule([]string{"p", "bob", "data1", "read"}); err == nil {
+		t.Fatal("follower accepted a write")
+	}
+	if _, err := el.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if ef.HasRule([]string{"p", "alice", "data1", "read"}) {
+		t.Fatal("follower has the rule before the leader saved it")
+	}
+	if err := leader.SavePolicy(); err != nil {
+		t.Fatal(err)
+	}
+	if !ef.HasRule([]string{"p", "alice", "data1", "read"}) {
+		t.Fatal("follower has not reloaded the rules")
+	}
+	if got := evF.take(); !equal(got, []string{RELOADED}) {
+		t.Fatalf("events of the follower: %v", got)
+	}
+	// the leader ignores its own updates
+	if got := evL.take(); len(got) != 0 {
+		t.Fatalf("events of the leader: %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	register(&command{
+		name:  "bundle",
+		short: "package a model and policy as a single bundle file",
+		run:   runBundle,
+	})
+}
+
+type metaFlag map[string]string
+
+func (f metaFlag) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f metaFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("metadata %q is not of the form key=value", value)
+	}
+	f[key] = val
+	return nil
+}
+
+// fastac bundle -model model.conf -policy policy.csv -meta revision=v1 -o authz.bundle.json
+func runBundle(args []string) error {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	modelPath := fs.String("model", "model.conf", "path of the model")
+	policyPath := fs.String("policy", "policy.csv", "path of the policy")
+	output := fs.String("o", "", "path of the bundle, stdout if empty")
+	meta := metaFlag{}
+	fs.Var(meta, "meta", "metadata key=value, may be repeated")
+	_ = fs.Parse(args)
+
+	e, err := loadEnforcer(*modelPath, *policyPath)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return e.SaveBundle(os.Stdout, meta)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := e.SaveBundle(f, meta); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+func init() {
+	register(&command{
+		name:  "fmt",
+		short: "format policy files",
+		run:   runFmt,
+	})
+}
+
+// fastac fmt [-w] [-sort 1,0] policy.csv...
+// Included files are formatted as well, comments and include directives are kept.
+func runFmt(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write result to the source file instead of stdout")
+	sortBy := fs.String("sort", "", "comma separated columns to sort by, e.g. 1,0")
+	_ = fs.Parse(args)
+
+	columns := []int{}
+	if *sortBy != "" {
+		for _, s := range strings.Split(*sortBy, ",") {
+			column, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("invalid sort column %q", s)
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	for _, path := range fs.Args() {
+		files, err := adapter.FormatPolicyFile(path, columns...)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if !*write {
+				if len(files) > 1 {
+					fmt.Printf("# %s\n", file.Path)
+				}
+				fmt.Print(file.Content)
+				continue
+			}
+			if err := os.WriteFile(file.Path, []byte(file.Content), 0600); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+func init() {
+	register(&command{
+		name:  "init",
+		short: "generate a model, policy and HTTP service from a template",
+		run:   runInit,
+	})
+}
+
+// fastac init [-template rbac] [-force] [dir]
+func runInit(args []string) error {
+	names := make([]string, 0, len(scaffolds))
+	for name := range scaffolds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	name := fs.String("template", "rbac", "template: "+strings.Join(names, ", "))
+	force := fs.Bool("force", false, "overwrite existing files")
+	list := fs.Bool("list", false, "list all templates")
+	_ = fs.Parse(args)
+
+	if *list {
+		for _, name := range names {
+			fmt.Printf("%-14s %s\n", name, scaffolds[name].description)
+		}
+		return nil
+	}
+
+	s, ok := scaffolds[*name]
+	if !ok {
+		return fmt.Errorf("unknown template %q, available: %s", *name, strings.Join(names, ", "))
+	}
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	var main bytes.Buffer
+	err = template.Must(template.New("main").Parse(mainTemplate)).Execute(&main, map[string]string{
+		"Name":     filepath.Base(abs),
+		"Template": *name,
+		"Request":  s.request,
+		"Usage":    s.usage,
+	})
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"model.conf", []byte(s.model)},
+		{"policy.csv", []byte(s.policy)},
+		{"main.go", main.Bytes()},
+	}
+
+	if !*force {
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(dir, f.name)); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite", filepath.Join(dir, f.name))
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.content, 0644); err != nil {
+			return err
+		}
+		fmt.Println("created", filepath.Join(dir, f.name))
+	}
+
+	fmt.Println()
+	fmt.Println("next steps:")
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("  cd %s && go mod init %s && go get github.com/oarkflow/fastac\n", dir, filepath.Base(abs))
+	}
+	fmt.Println("  go run .")
+	fmt.Println("  " + s.usage)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// errInterrupt is returned by readLine, if the line was cancelled with Ctrl-C
+var errInterrupt = errors.New("interrupt")
+
+// lineEditor reads lines from a terminal with history and tab completion.
+// If the input is not a terminal, it reads plain lines.
+type lineEditor struct {
+	in       *os.File
+	out      io.Writer
+	reader   *bufio.Reader
+	history  []string
+	complete func(line string) []string
+}
+
+func newLineEditor(in *os.File, out io.Writer, complete func(line string) []string) *lineEditor {
+	return &lineEditor{in: in, out: out, reader: bufio.NewReader(in), complete: complete}
+}
+
+// addHistory appends a line to the history, repeated lines are stored once
+func (le *lineEditor) addHistory(line string) {
+	if line == "" || (len(le.history) > 0 && le.history[len(le.history)-1] == line) {
+		return
+	}
+	le.history = append(le.history, line)
+}
+
+// readLine reads the next line, it returns io.EOF on Ctrl-D or the end of the input
+func (le *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(le.in.Fd())
+	if err != nil {
+		fmt.Fprint(le.out, prompt)
+		line, err := le.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+	return le.edit(prompt)
+}
+
+func (le *lineEditor) edit(prompt string) (string, error) {
+	line := []rune{}
+	pos := 0
+	index := len(le.history)
+	tabbed := false
+
+	redraw := func() {
+		fmt.Fprintf(le.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(le.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(s string) {
+		line = []rune(s)
+		pos = len(line)
+		redraw()
+	}
+
+	redraw()
+	for {
+		r, _, err := le.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r != '\t' {
+			tabbed = false
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(le.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(le.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(le.out, "\r\n")
+				return "", io.EOF
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(line)
+			redraw()
+		case 21: // Ctrl-U
+			line, pos = line[pos:], 0
+			redraw()
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+				redraw()
+			}
+		case '\t':
+			s, list := le.completeLine(string(line[:pos]))
+			if list != nil && tabbed {
+				fmt.Fprintf(le.out, "\r\n%s\r\n", strings.Join(list, "  "))
+			}
+			line = append([]rune(s), line[pos:]...)
+			pos = len([]rune(s))
+			tabbed = true
+			redraw()
+		case 27: // escape sequences of the arrow keys
+			if next, _, _ := le.reader.ReadRune(); next != '[' {
+				continue
+			}
+			key, _, _ := le.reader.ReadRune()
+			switch key {
+			case 'A':
+				if index > 0 {
+					index--
+					setLine(le.history[index])
+				}
+			case 'B':
+				if index < len(le.history)-1 {
+					index++
+					setLine(le.history[index])
+				} else if index < len(le.history) {
+					index++
+					setLine("")
+				}
+			case 'C':
+				if pos < len(line) {
+					pos++
+					redraw()
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			}
+		default:
+			if r >= 32 {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+				redraw()
+			}
+		}
+	}
+}
+
+// completeLine completes the last word of line.
+// If the word has several completions, it is extended to their common prefix and the completions are returned.
+func (le *lineEditor) completeLine(line string) (string, []string) {
+	if le.complete == nil {
+		return line, nil
+	}
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	candidates := []string{}
+	for _, candidate := range le.complete(line[:start]) {
+		if strings.HasPrefix(candidate, word) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return line, nil
+	case 1:
+		return line[:start] + candidates[0] + " ", nil
+	}
+	sort.Strings(candidates)
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return line[:start] + prefix, candidates
+}
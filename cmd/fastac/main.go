@@ -0,0 +1,91 @@
+// Command fastac is a command line tool to work with FastAC models and policies.
+//
+//	fastac <command> [arguments]
+//
+// Matcher functions of plugins are loaded from the configuration in FASTAC_PLUGINS, see package plugins.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/oarkflow/fastac"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/plugins"
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = map[string]*command{}
+
+func register(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fastac <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].short)
+	}
+}
+
+// loadEnforcer creates an enforcer without storage from a model and an optional policy file
+func loadEnforcer(modelPath, policyPath string) (*fastac.Enforcer, error) {
+	model, err := m.NewModelFromFile(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	if policyPath != "" {
+		if err := adapter.NewFileAdapter(policyPath).LoadPolicy(model); err != nil {
+			return nil, err
+		}
+	}
+	return fastac.NewEnforcer(model, nil)
+}
+
+// installPlugins registers the matcher functions configured in FASTAC_PLUGINS
+func installPlugins() error {
+	path := os.Getenv("FASTAC_PLUGINS")
+	if path == "" {
+		return nil
+	}
+	cfg, err := plugins.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	return plugins.Install(cfg)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	if err := installPlugins(); err != nil {
+		fmt.Fprintf(os.Stderr, "fastac: %s\n", err)
+		os.Exit(1)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fastac: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "fastac %s: %s\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oarkflow/fastac/fastactest/cases"
+)
+
+func init() {
+	register(&command{
+		name:  "mutate",
+		short: "find policy changes not detected by test tables",
+		run:   runMutate,
+	})
+}
+
+// fastac mutate -model model.conf -policy policy.csv cases.csv...
+func runMutate(args []string) error {
+	fs := flag.NewFlagSet("mutate", flag.ExitOnError)
+	modelPath := fs.String("model", "model.conf", "path of the model")
+	policyPath := fs.String("policy", "policy.csv", "path of the policy")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("no test tables specified")
+	}
+
+	modelText, err := os.ReadFile(*modelPath)
+	if err != nil {
+		return err
+	}
+	e, err := loadEnforcer(*modelPath, *policyPath)
+	if err != nil {
+		return err
+	}
+
+	all := []cases.Case{}
+	for _, path := range fs.Args() {
+		c, err := cases.LoadCases(path)
+		if err != nil {
+			return err
+		}
+		all = append(all, c...)
+	}
+
+	report, err := cases.CheckMutations(string(modelText), cases.Rules(e.ViewModel()), all)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	if len(report.Survivors) > 0 {
+		return fmt.Errorf("%d mutants survived", len(report.Survivors))
+	}
+	return nil
+}
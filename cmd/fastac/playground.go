@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/oarkflow/fastac"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+//go:embed playground/index.html
+var playgroundPage []byte
+
+// maxPlaygroundBody limits the size of an evaluation request
+const maxPlaygroundBody = 1 << 20
+
+func init() {
+	register(&command{
+		name:  "playground",
+		short: "serve a web ui to try models and policies",
+		run:   runPlayground,
+	})
+}
+
+type playgroundInput struct {
+	Model    string `json:"model"`
+	Policy   string `json:"policy"`
+	Requests string `json:"requests"`
+}
+
+type playgroundResult struct {
+	Request []string   `json:"request"`
+	Allowed bool       `json:"allowed"`
+	Reason  string     `json:"reason,omitempty"`
+	Matches [][]string `json:"matches,omitempty"`
+	Trace   string     `json:"trace"`
+	Error   string     `json:"error,omitempty"`
+}
+
+type playgroundOutput struct {
+	Results []playgroundResult `json:"results,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// fastac playground [-addr 127.0.0.1:8080] [-template rbac] [-model model.conf] [-policy policy.csv]
+func runPlayground(args []string) error {
+	fs := flag.NewFlagSet("playground", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	template := fs.String("template", "rbac", "template of the initial model and policy")
+	modelPath := fs.String("model", "", "path of the initial model, overrides the template")
+	policyPath := fs.String("policy", "", "path of the initial policy, overrides the template")
+	_ = fs.Parse(args)
+
+	sc, ok := scaffolds[*template]
+	if !ok {
+		return fmt.Errorf("unknown template %q", *template)
+	}
+	initial := playgroundInput{Model: sc.model, Policy: sc.policy}
+	if *modelPath != "" {
+		data, err := os.ReadFile(*modelPath)
+		if err != nil {
+			return err
+		}
+		initial.Model = string(data)
+	}
+	if *policyPath != "" {
+		data, err := os.ReadFile(*policyPath)
+		if err != nil {
+			return err
+		}
+		initial.Policy = string(data)
+	}
+	if *template == "rbac" && *modelPath == "" {
+		initial.Requests = "alice, /documents/1, PUT\nbob, /documents/1, PUT\n"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(playgroundPage)
+	})
+	mux.HandleFunc("/api/initial", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, initial)
+	})
+	mux.HandleFunc("/api/evaluate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		input := playgroundInput{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxPlaygroundBody)).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		results, err := evaluatePlayground(input)
+		if err != nil {
+			writeJSON(w, playgroundOutput{Error: err.Error()})
+			return
+		}
+		writeJSON(w, playgroundOutput{Results: results})
+	})
+
+	fmt.Printf("playground listening on http://%s\n", *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// evaluatePlayground builds an enforcer from the model and policy of input and decides every request with a trace
+func evaluatePlayground(input playgroundInput) ([]playgroundResult, error) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(input.Model); err != nil {
+		return nil, fmt.Errorf("model: %w", err)
+	}
+	if err := adapter.LoadPolicyReader(strings.NewReader(input.Policy), model); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	e, err := fastac.NewEnforcer(model, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(input.Requests))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	requests, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("requests: %w", err)
+	}
+
+	results := make([]playgroundResult, 0, len(requests))
+	for _, request := range requests {
+		trace := &bytes.Buffer{}
+		params := []interface{}{fastac.EnableTrace(trace)}
+		for _, value := range request {
+			params = append(params, value)
+		}
+		d := e.EnforceDecision(params...)
+		res := playgroundResult{Request: request, Allowed: d.Allowed, Matches: d.Matches, Trace: trace.String()}
+		if d.Err != nil {
+			res.Error = d.Err.Error()
+		} else if !d.Allowed {
+			res.Reason = d.Reason()
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
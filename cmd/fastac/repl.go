@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/fastac"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+// repl holds the state of an interactive session
+type repl struct {
+	e          *fastac.Enforcer
+	policyPath string
+	out        io.Writer
+}
+
+type replCommand struct {
+	usage string
+	short string
+	run   func(r *repl, args []string) error
+}
+
+var replCommands map[string]*replCommand
+
+func init() {
+	register(&command{
+		name:  "repl",
+		short: "run queries against a policy interactively",
+		run:   runRepl,
+	})
+	replCommands = map[string]*replCommand{
+		"enforce": {"enforce <request values...>", "decide a request", (*repl).enforce},
+		"explain": {"explain <request values...>", "decide a request and trace the evaluation", (*repl).explain},
+		"filter":  {"filter <request values...>", "list the rules matching a request", (*repl).filter},
+		"add":     {"add <ptype> <values...>", "add a rule", (*repl).add},
+		"remove":  {"remove <ptype> <values...>", "remove a rule", (*repl).remove},
+		"rules":   {"rules [ptype]", "list the rules", (*repl).rules},
+		"roles":   {"roles [gtype] <name> [domain]", "list the roles of a user", (*repl).roles},
+		"users":   {"users [gtype] <role> [domain]", "list the users of a role", (*repl).users},
+		"save":    {"save [path]", "save the rules to the policy file", (*repl).save},
+		"help":    {"help", "list the commands", (*repl).help},
+		"exit":    {"exit", "leave the repl", nil},
+	}
+}
+
+// fastac repl [-history file] model.conf [policy.csv]
+func runRepl(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	historyPath := fs.String("history", defaultHistoryPath(), "path of the history file, empty disables the history")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || fs.NArg() > 2 {
+		return errors.New("usage: fastac repl [-history file] model.conf [policy.csv]")
+	}
+	r := &repl{policyPath: fs.Arg(1), out: os.Stdout}
+	e, err := loadEnforcer(fs.Arg(0), r.policyPath)
+	if err != nil {
+		return err
+	}
+	r.e = e
+
+	le := newLineEditor(os.Stdin, os.Stdout, r.completions)
+	history := loadHistory(*historyPath)
+	for _, line := range history {
+		le.addHistory(line)
+	}
+	var historyFile *os.File
+	if *historyPath != "" {
+		historyFile, _ = os.OpenFile(*historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	}
+	if historyFile != nil {
+		defer historyFile.Close()
+	}
+
+	fmt.Fprintln(r.out, "type help for the list of commands")
+	for {
+		line, err := le.readLine("fastac> ")
+		if err == errInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		le.addHistory(line)
+		if historyFile != nil {
+			fmt.Fprintln(historyFile, line)
+		}
+
+		fields := splitLine(line)
+		if fields[0] == "exit" || fields[0] == "quit" {
+			return nil
+		}
+		cmd, ok := replCommands[fields[0]]
+		if !ok {
+			fmt.Fprintf(r.out, "unknown command %q, type help for the list of commands\n", fields[0])
+			continue
+		}
+		if err := cmd.run(r, fields[1:]); err != nil {
+			fmt.Fprintf(r.out, "error: %s\n", strings.TrimPrefix(err.Error(), "error: "))
+		}
+	}
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".fastac_history")
+}
+
+// loadHistory returns the last lines of the history file
+func loadHistory(path string) []string {
+	const max = 1000
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+	return lines
+}
+
+// splitLine splits a line into fields, values may be separated by commas like in a policy file
+func splitLine(line string) []string {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	return fields
+}
+
+func values(args []string) []interface{} {
+	res := make([]interface{}, len(args))
+	for i, arg := range args {
+		res[i] = arg
+	}
+	return res
+}
+
+func (r *repl) enforce(args []string) error {
+	d := r.e.EnforceDecision(values(args)...)
+	return r.printDecision(d)
+}
+
+func (r *repl) explain(args []string) error {
+	d := r.e.EnforceDecision(append(values(args), fastac.EnableTrace(r.out))...)
+	return r.printDecision(d)
+}
+
+func (r *repl) printDecision(d *fastac.Decision) error {
+	if d.Err != nil {
+		return d.Err
+	}
+	if d.Allowed {
+		fmt.Fprintln(r.out, "allow")
+		return nil
+	}
+	if reason := d.Reason(); reason != "" {
+		fmt.Fprintf(r.out, "deny: %s\n", reason)
+		return nil
+	}
+	fmt.Fprintln(r.out, "deny")
+	return nil
+}
+
+func (r *repl) filter(args []string) error {
+	rules, err := r.e.FilterSources(values(args)...)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Source != "" {
+			fmt.Fprintf(r.out, "%s (%s)\n", strings.Join(rule.Rule, ", "), rule.Source)
+		} else {
+			fmt.Fprintln(r.out, strings.Join(rule.Rule, ", "))
+		}
+	}
+	fmt.Fprintf(r.out, "(%d rules)\n", len(rules))
+	return nil
+}
+
+func (r *repl) add(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + replCommands["add"].usage)
+	}
+	added, err := r.e.AddRule(args)
+	if err != nil {
+		return err
+	}
+	if !added {
+		fmt.Fprintln(r.out, "rule exists")
+	}
+	return nil
+}
+
+func (r *repl) remove(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + replCommands["remove"].usage)
+	}
+	removed, err := r.e.RemoveRule(args)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		fmt.Fprintln(r.out, "rule not found")
+	}
+	return nil
+}
+
+func (r *repl) rules(args []string) error {
+	rules := [][]string{}
+	r.e.ViewModel().RangeRules(func(rule []string) bool {
+		if len(args) == 0 || rule[0] == args[0] {
+			rules = append(rules, rule)
+		}
+		return true
+	})
+	r.printRules(rules)
+	return nil
+}
+
+func (r *repl) printRules(rules [][]string) {
+	for _, rule := range rules {
+		fmt.Fprintln(r.out, strings.Join(rule, ", "))
+	}
+	fmt.Fprintf(r.out, "(%d rules)\n", len(rules))
+}
+
+// roleArgs splits the optional role definition key from the arguments of roles and users
+func (r *repl) roleArgs(args []string, usage string) (key string, name string, domains []string, err error) {
+	key = "g"
+	if len(args) > 1 {
+		if _, ok := r.e.ViewModel().GetDef(m.G_SEC, args[0]); ok {
+			key, args = args[0], args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return "", "", nil, errors.New("usage: " + usage)
+	}
+	return key, args[0], args[1:], nil
+}
+
+func (r *repl) roles(args []string) error {
+	key, name, domains, err := r.roleArgs(args, replCommands["roles"].usage)
+	if err != nil {
+		return err
+	}
+	rm, ok := r.e.ViewModel().GetRoleManager(key)
+	if !ok {
+		return fmt.Errorf("no role definition %s", key)
+	}
+	roles, err := rm.GetRoles(name, domains...)
+	if err != nil {
+		return err
+	}
+	sort.Strings(roles)
+	fmt.Fprintln(r.out, strings.Join(roles, ", "))
+	return nil
+}
+
+func (r *repl) users(args []string) error {
+	key, name, domains, err := r.roleArgs(args, replCommands["users"].usage)
+	if err != nil {
+		return err
+	}
+	rm, ok := r.e.ViewModel().GetRoleManager(key)
+	if !ok {
+		return fmt.Errorf("no role definition %s", key)
+	}
+	users, err := rm.GetUsers(name, domains...)
+	if err != nil {
+		return err
+	}
+	sort.Strings(users)
+	fmt.Fprintln(r.out, strings.Join(users, ", "))
+	return nil
+}
+
+func (r *repl) save(args []string) error {
+	path := r.policyPath
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		return errors.New("usage: " + replCommands["save"].usage)
+	}
+	if err := adapter.NewFileAdapter(path).SavePolicy(r.e.ViewModel()); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "saved %s\n", path)
+	return nil
+}
+
+func (r *repl) help(args []string) error {
+	names := make([]string, 0, len(replCommands))
+	for name := range replCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "  %-32s %s\n", replCommands[name].usage, replCommands[name].short)
+	}
+	return nil
+}
+
+// completions returns the candidates for the word following prefix:
+// commands for the first word, policy types for the first argument of add and remove
+// and the values of the loaded rules otherwise
+func (r *repl) completions(prefix string) []string {
+	fields := splitLine(prefix)
+	if len(fields) == 0 {
+		names := make([]string, 0, len(replCommands))
+		for name := range replCommands {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	model := r.e.ViewModel()
+	set := map[string]struct{}{}
+	if len(fields) == 1 && (fields[0] == "add" || fields[0] == "remove" || fields[0] == "rules") {
+		for _, sec := range []byte{m.P_SEC, m.G_SEC} {
+			model.RangeDefs(sec, func(key string, _ defs.IDef) bool {
+				set[key] = struct{}{}
+				return true
+			})
+		}
+	} else {
+		model.RangeRules(func(rule []string) bool {
+			for _, value := range rule[1:] {
+				set[value] = struct{}{}
+			}
+			return true
+		})
+	}
+	res := make([]string, 0, len(set))
+	for value := range set {
+		res = append(res, value)
+	}
+	return res
+}
@@ -0,0 +1,179 @@
+package main
+
+// scaffold is a project template of fastac init
+type scaffold struct {
+	description string
+	model       string
+	policy      string
+	// request is the body of the function, which converts an HTTP request to the request values
+	request string
+	// usage is an example request against the generated service
+	usage string
+}
+
+const httpActionGroups = `
+[action_groups]
+read = GET, HEAD, OPTIONS
+write = POST, PUT, PATCH, DELETE
+`
+
+var scaffolds = map[string]*scaffold{
+	"rbac": {
+		description: "role based access control",
+		model: `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && pathMatch(r.obj, p.obj) && actMatch(r.act, p.act)
+` + httpActionGroups,
+		policy: `p, reader, /documents/*, read
+p, editor, /documents/*, (read|write)
+
+g, alice, editor
+g, bob, reader
+`,
+		request: `return []interface{}{r.Header.Get("X-User"), r.URL.Path, r.Method}`,
+		usage:   `curl -H "X-User: alice" -X PUT localhost:8080/documents/1`,
+	},
+	"rbac-domains": {
+		description: "role based access control with tenants",
+		model: `[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && pathMatch(r.obj, p.obj) && actMatch(r.act, p.act)
+` + httpActionGroups,
+		policy: `p, admin, tenant1, /*, (read|write)
+p, member, tenant1, /documents/*, read
+
+g, alice, admin, tenant1
+g, bob, member, tenant1
+`,
+		request: `return []interface{}{r.Header.Get("X-User"), r.Header.Get("X-Tenant"), r.URL.Path, r.Method}`,
+		usage:   `curl -H "X-User: bob" -H "X-Tenant: tenant1" localhost:8080/documents/1`,
+	},
+	"abac": {
+		description: "attribute based access control",
+		model: `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub_rule, obj, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = eval(p.sub_rule) && pathMatch(r.obj, p.obj) && actMatch(r.act, p.act)
+` + httpActionGroups,
+		policy: `p, "r.sub.Role == 'admin'", /*, (read|write)
+p, "r.sub.Department == 'sales'", /reports/*, read
+`,
+		request: `sub := map[string]interface{}{
+		"Name":       r.Header.Get("X-User"),
+		"Role":       r.Header.Get("X-Role"),
+		"Department": r.Header.Get("X-Department"),
+	}
+	return []interface{}{sub, r.URL.Path, r.Method}`,
+		usage: `curl -H "X-User: carol" -H "X-Department: sales" localhost:8080/reports/q1`,
+	},
+	"rebac": {
+		description: "relationship based access control",
+		model: `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = rel, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.rel, r.obj) && actMatch(r.act, p.act)
+` + httpActionGroups,
+		policy: `p, viewer, read
+p, editor, (read|write)
+
+g, alice, editor, /documents/1
+g, bob, viewer, /documents/1
+`,
+		request: `return []interface{}{r.Header.Get("X-User"), r.URL.Path, r.Method}`,
+		usage:   `curl -H "X-User: bob" localhost:8080/documents/1`,
+	},
+}
+
+const mainTemplate = `// Command {{.Name}} is an HTTP service protected by a fastac {{.Template}} policy.
+//
+//	go run .
+//	{{.Usage}}
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+// request converts an HTTP request to the request values of the model
+func request(r *http.Request) []interface{} {
+	{{.Request}}
+}
+
+// authorize rejects all requests, which are not allowed by the policy
+func authorize(e *fastac.Enforcer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, err := e.Enforce(request(r)...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	e, err := fastac.NewEnforcer("model.conf", adapter.NewFileAdapter("policy.csv"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s\n", r.Method, r.URL.Path)
+	})
+
+	log.Println("listening on :8080")
+	log.Fatal(http.ListenAndServe(":8080", authorize(e, mux)))
+}
+`
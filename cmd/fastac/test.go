@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/oarkflow/fastac/fastactest/cases"
+)
+
+func init() {
+	register(&command{
+		name:  "test",
+		short: "run policy test tables",
+		run:   runTest,
+	})
+}
+
+// fastac test -model model.conf -policy policy.csv cases.csv cases.yaml...
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	modelPath := fs.String("model", "model.conf", "path of the model")
+	policyPath := fs.String("policy", "policy.csv", "path of the policy")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("no test tables specified")
+	}
+
+	e, err := loadEnforcer(*modelPath, *policyPath)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		report, err := cases.RunFile(e, path)
+		if err != nil {
+			return err
+		}
+		fmt.Print(report)
+		failed += len(report.Failures)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d cases failed", failed)
+	}
+	return nil
+}
@@ -1,12 +1,20 @@
 package fastac
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/govaluate"
 
 	"github.com/oarkflow/fastac/model"
 	"github.com/oarkflow/fastac/model/defs"
 	e "github.com/oarkflow/fastac/model/effector"
+	"github.com/oarkflow/fastac/model/eft"
 	m "github.com/oarkflow/fastac/model/matcher"
+	"github.com/oarkflow/fastac/model/types"
 	"github.com/oarkflow/fastac/str"
 )
 
@@ -42,6 +50,58 @@ func SetMatcher(matcher interface{}) ContextOption {
 	}
 }
 
+// WithContext stops the evaluation of a request with the error of goctx, if goctx is canceled or its deadline is exceeded
+//
+//	e.Enforce("alice", "data1", "read", WithContext(r.Context()))
+func WithContext(goctx context.Context) ContextOption {
+	return func(ctx *Context) error {
+		ctx.goctx = goctx
+		return nil
+	}
+}
+
+// OnlyEffect restricts Filter and RangeMatches to the matching rules with the given effect, e.g. to list the deny rules of a request.
+// The decision of Enforce is not affected.
+//
+//	e.Filter("alice", "data1", "read", OnlyEffect(eft.Deny))
+func OnlyEffect(effect types.Effect) ContextOption {
+	return func(ctx *Context) error {
+		ctx.onlyEffect, ctx.filterEffect = effect, true
+		return nil
+	}
+}
+
+// SetVariable binds a variable, which can be used by name in the matcher expression without extending the request definition.
+// Request and policy parameters take precedence over variables.
+//
+//	m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act && (env == "prod" || p.sub == "developer")
+//
+//	e.Enforce("alice", "data1", "read", SetVariable("env", "prod"))
+func SetVariable(name string, value interface{}) ContextOption {
+	return func(ctx *Context) error {
+		if ctx.vars == nil {
+			ctx.vars = make(map[string]interface{})
+		}
+		ctx.vars[name] = value
+		return nil
+	}
+}
+
+// SetFunction shadows a function of the model for the evaluation of one request, the function of the model is not modified
+//
+//	e.Enforce("alice", "data1", "read", SetFunction("now", func(args ...interface{}) (interface{}, error) {
+//		return float64(fixed.Unix()), nil
+//	}))
+func SetFunction(name string, function govaluate.ExpressionFunction) ContextOption {
+	return func(ctx *Context) error {
+		if ctx.functions == nil {
+			ctx.functions = make(map[string]govaluate.ExpressionFunction)
+		}
+		ctx.functions[name] = function
+		return nil
+	}
+}
+
 func SetRequestDef(definition interface{}) ContextOption {
 	return func(ctx *Context) error {
 		switch rType := definition.(type) {
@@ -61,6 +121,75 @@ func SetRequestDef(definition interface{}) ContextOption {
 	}
 }
 
+// SetPolicyKey selects the policy definition of a request, e.g. p2. Unless they are set explicitly,
+// the matcher, request and effect definitions with the same suffix are used, e.g. m2, r2 and e2.
+// If the model has no matcher with the suffix, the first matcher using the policy definition is used.
+//
+//	e.Enforce("alice", "data1", "read", SetPolicyKey("p2"))
+func SetPolicyKey(key string) ContextOption {
+	return func(ctx *Context) error {
+		if key == "" {
+			return nil
+		}
+		if _, ok := ctx.model.GetDef(model.P_SEC, key); !ok {
+			return fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
+		}
+		ctx.pKey = key
+		return nil
+	}
+}
+
+// selectPolicy sets the definitions, which are not set explicitly, for the policy definition selected by SetPolicyKey
+func (ctx *Context) selectPolicy() error {
+	suffix := ctx.pKey[1:]
+	if ctx.matcher == nil {
+		matcher, ok := ctx.model.GetMatcher(string(model.M_SEC) + suffix)
+		if !ok || matcher.GetPolicyKey() != ctx.pKey {
+			keys := []string{}
+			ctx.model.RangeDefs(model.M_SEC, func(key string, def defs.IDef) bool {
+				if mDef, ok := def.(*defs.MatcherDef); ok && mDef.GetPolicyKey() == ctx.pKey {
+					keys = append(keys, key)
+				}
+				return true
+			})
+			if len(keys) == 0 {
+				return fmt.Errorf(str.ERR_NO_POLICY_MATCHER, ctx.pKey)
+			}
+			sort.Strings(keys)
+			if matcher, ok = ctx.model.GetMatcher(keys[0]); !ok {
+				return fmt.Errorf(str.ERR_MATCHER_NOT_FOUND, keys[0])
+			}
+		}
+		ctx.matcher = matcher
+	} else if pKey := ctx.matcher.GetPolicyKey(); pKey != ctx.pKey {
+		return fmt.Errorf(str.ERR_POLICY_MISMATCH, pKey, ctx.pKey)
+	}
+	if ctx.rDef == nil {
+		if rDef, ok := ctx.model.GetDef(model.R_SEC, string(model.R_SEC)+suffix); ok {
+			ctx.rDef = rDef.(*defs.RequestDef)
+		}
+	}
+	if ctx.effector == nil {
+		if eff, ok := ctx.model.GetEffector(string(model.E_SEC) + suffix); ok {
+			ctx.effector = eff
+		}
+	}
+	return nil
+}
+
+// effectorNames are the names of the supported effects, which can be passed to SetEffector
+var effectorNames = map[string]string{
+	"allow-override": eft.SOME_ALLOW,
+	"deny-override":  eft.NO_DENY,
+	"allow-and-deny": eft.SOME_ALLOW_NO_DENY,
+}
+
+// SetEffector sets the effector, which merges the effects of the matching rules, for a single request.
+// The effector is the key of an effect definition of the model, the name allow-override, deny-override or allow-and-deny,
+// an effect expression, an effect definition or an effector.
+//
+//	e.Enforce("alice", "data1", "read", SetEffector("deny-override"))
+//	e.Enforce("alice", "data1", "read", SetEffector("e2"))
 func SetEffector(effector interface{}) ContextOption {
 	return func(ctx *Context) error {
 		switch eType := effector.(type) {
@@ -70,8 +199,14 @@ func SetEffector(effector interface{}) ContextOption {
 			}
 			eff, ok := ctx.model.GetEffector(eType)
 			if !ok {
-				eDef := defs.NewEffectDef("", eType)
-				eff = e.NewEffector(eDef)
+				expr, named := effectorNames[eType]
+				if !named {
+					expr = strings.ReplaceAll(eType, " ", "")
+				}
+				if expr != eft.SOME_ALLOW && expr != eft.NO_DENY && expr != eft.SOME_ALLOW_NO_DENY {
+					return fmt.Errorf(str.ERR_EFFECTOR_NOT_FOUND, eType)
+				}
+				eff = e.NewEffector(defs.NewEffectDef("", expr))
 			}
 			ctx.effector = eff
 		case *defs.EffectDef:
@@ -90,6 +225,25 @@ type Context struct {
 	rDef     *defs.RequestDef
 	matcher  m.IMatcher
 	effector e.IEffector
+	// pKey is the policy definition selected by SetPolicyKey
+	pKey string
+	// routable is true, if the matcher was not set explicitly and may be selected by the matcher route of the model
+	routable bool
+	// trace is the writer of the request trace set by EnableTrace, explain records the trace in the decision,
+	// tracer collects the trace during a request
+	trace   io.Writer
+	explain bool
+	tracer  *tracer
+	// sessions evaluate the requests of a matrix with shared functions and compiled expressions
+	sessions *matcherSessions
+	// goctx cancels the evaluation, see WithContext
+	goctx context.Context
+	// onlyEffect is the effect of the rules ranged by RangeMatches, if filterEffect is true
+	onlyEffect   types.Effect
+	filterEffect bool
+	// vars and functions are the variables and shadowed functions of the matcher, which are passed to the evaluation with goctx
+	vars      map[string]interface{}
+	functions map[string]govaluate.ExpressionFunction
 }
 
 func NewContext(model model.IModel, options ...ContextOption) (*Context, error) {
@@ -102,15 +256,75 @@ func NewContext(model model.IModel, options ...ContextOption) (*Context, error)
 		}
 	}
 
+	if ctx.pKey != "" {
+		if err := ctx.selectPolicy(); err != nil {
+			return nil, err
+		}
+	}
 	if ctx.rDef == nil {
 		_ = SetRequestDef("r")(ctx)
 	}
 	if ctx.matcher == nil {
+		ctx.routable = true
 		_ = SetMatcher("m")(ctx)
 	}
 	if ctx.effector == nil {
-		_ = SetEffector("e")(ctx)
+		if err := SetEffector("e")(ctx); err != nil {
+			// models without effect definition can still range and filter the matching rules
+			ctx.effector = e.NewEffector(defs.NewEffectDef("e", ""))
+		}
+	}
+	if len(ctx.vars) > 0 || len(ctx.functions) > 0 {
+		if ctx.goctx == nil {
+			ctx.goctx = context.Background()
+		}
+		if len(ctx.vars) > 0 {
+			ctx.goctx = m.WithVariables(ctx.goctx, ctx.vars)
+		}
+		if len(ctx.functions) > 0 {
+			ctx.goctx = m.WithFunctions(ctx.goctx, ctx.functions)
+		}
 	}
 
 	return ctx, nil
 }
+
+// routed returns a copy of the context with the matcher selected by the matcher route of the model
+func (ctx *Context) routed(rvals []interface{}) (*Context, error) {
+	route := ctx.model.GetMatcherRoute()
+	if !ctx.routable || route == nil || route.RequestKey() != ctx.rDef.GetKey() {
+		return ctx, nil
+	}
+	value, err := ctx.rDef.GetParameter(rvals, route.Parameter())
+	if err != nil {
+		return nil, err
+	}
+	key, ok := route.Select(value)
+	if !ok {
+		return ctx, nil
+	}
+	matcher, ok := ctx.model.GetMatcher(key)
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_MATCHER_NOT_FOUND, key)
+	}
+	routed := *ctx
+	routed.matcher = matcher
+	return &routed, nil
+}
+
+// Matcher returns the matcher of the context
+//
+//	ctx.Matcher().AST()
+func (ctx *Context) Matcher() m.IMatcher {
+	return ctx.matcher
+}
+
+// RequestDef returns the request definition of the context
+func (ctx *Context) RequestDef() *defs.RequestDef {
+	return ctx.rDef
+}
+
+// Effector returns the effector of the context
+func (ctx *Context) Effector() e.IEffector {
+	return ctx.effector
+}
@@ -0,0 +1,386 @@
+package fastac
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oarkflow/fastac/api"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/storage"
+)
+
+var _ api.Decision = (*Decision)(nil)
+
+// REASON is the name of the optional policy argument, which carries the reason code of deny rules.
+// The column must be the last one and rules of a policy with a reason column must have every column, the reason may be empty.
+// Rules with fewer values are rejected, since values omitted at the end cannot be told apart from the key of the rule.
+//
+//	p = sub, obj, act, eft, reason
+//	p, *, /eu/*, write, deny, blocked by data-residency policy
+//	p, alice, /eu/*, read, allow,
+const REASON = "reason"
+
+// hasReason returns true, if a policy definition has the argument reason
+func (e *Enforcer) hasReason() bool {
+	found := false
+	e.model.RangeDefs(m.P_SEC, func(key string, def defs.IDef) bool {
+		found = def.(*defs.PolicyDef).Has(key + "_" + REASON)
+		return !found
+	})
+	return found
+}
+
+// Decision is the result of an enforcement
+type Decision struct {
+	// Request contains the request values before preprocessing
+	Request []interface{}
+	// Effect is the merged effect of the matching rules
+	Effect types.Effect
+	// Matches contains the rules, which have been evaluated by the effector
+	Matches [][]string
+	// Effects contains the values of the eft column of Matches, e.g. "audit" for custom effects
+	Effects []string
+	// Reasons contains the values of the reason column of the matching deny rules, if the request is denied
+	Reasons []string
+	Allowed bool
+	Err     error
+	// OverriddenBy is the name of the last hook, which has overridden the decision
+	OverriddenBy string
+	// Canary is true, if the request was decided by the candidate of a canary
+	Canary bool
+	// Trace and RolePaths contain the steps and granted role lookups of the evaluation, see EnableExplain
+	Trace     []TraceStep
+	RolePaths []RolePath
+	Timings   Timings
+
+	overridden bool
+	explicit   bool
+	// rule is the rule, which the effector made responsible for the effect
+	rule []string
+	// values contains the request values after binding and preprocessing, see requestValues
+	values      []interface{}
+	reasonRules [][]string
+	pDef        *defs.PolicyDef
+	rDef        *defs.RequestDef
+	catalog     *MessageCatalog
+	encoder     RequestEncoder
+	provenance  storage.ProvenanceAdapter
+}
+
+// Timings contains the durations of the phases of an enforcement
+type Timings struct {
+	// Prepare is the duration of the limit checks, preprocessors and matcher routing
+	Prepare time.Duration
+	// Evaluate is the duration of the matcher and effector
+	Evaluate time.Duration
+	Total    time.Duration
+}
+
+// Override replaces the decision and clears the error, it may only be called by decision hooks
+func (d *Decision) Override(allowed bool) {
+	d.Allowed = allowed
+	d.Err = nil
+	d.overridden = true
+}
+
+// HasEffect returns true, if one of the matches has the given value in the eft column
+//
+// Allow, but flag audit-only rules:
+//
+//	e.AddDecisionHook("audit", func(d *Decision) {
+//		if d.HasEffect("audit") {
+//			log.Println("audit", d.Request)
+//		}
+//	})
+func (d *Decision) HasEffect(name string) bool {
+	for _, effect := range d.Effects {
+		if effect == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reason returns the first reason of the decision or "", if the decision has no reason
+func (d *Decision) Reason() string {
+	if len(d.Reasons) == 0 {
+		return ""
+	}
+	return d.Reasons[0]
+}
+
+// Messages renders the reasons with the message catalog of the enforcer, see OptionMessageCatalog.
+// Without catalog the reasons are returned unchanged.
+func (d *Decision) Messages(locale string) []string {
+	if d.catalog == nil {
+		return d.Reasons
+	}
+	messages := make([]string, len(d.Reasons))
+	for i, reason := range d.Reasons {
+		messages[i] = d.catalog.Render(locale, reason, d.messageValues(d.reasonRules[i]))
+	}
+	return messages
+}
+
+// Message returns the first message of the decision or "", if the decision has no reason
+func (d *Decision) Message(locale string) string {
+	if messages := d.Messages(locale); len(messages) > 0 {
+		return messages[0]
+	}
+	return ""
+}
+
+// requestValues returns the request values bound to the arguments of the request definition,
+// struct and map requests are bound to several arguments. Without binding the raw request is returned.
+func (d *Decision) requestValues() []interface{} {
+	if d.values != nil {
+		return d.values
+	}
+	return d.Request
+}
+
+// messageValues returns the values of the request and the rule by their argument names
+func (d *Decision) messageValues(rule []string) map[string]string {
+	values := make(map[string]string)
+	if d.rDef != nil {
+		rvals := d.requestValues()
+		for i, arg := range d.rDef.GetArgs() {
+			if i < len(rvals) {
+				value := fmt.Sprint(rvals[i])
+				values[arg] = value
+				values[d.rDef.GetKey()+"."+arg] = value
+			}
+		}
+	}
+	if d.pDef != nil {
+		for _, arg := range d.pDef.GetArgs() {
+			if value, err := d.pDef.GetParameter(rule, d.pDef.GetKey()+"_"+arg); err == nil {
+				values[d.pDef.GetKey()+"."+arg] = value
+			}
+		}
+	}
+	return values
+}
+
+// RequestKey returns the stable key of the request, e.g. to correlate audit records, see RequestEncoder
+func (d *Decision) RequestKey() (string, error) {
+	encoder := d.encoder
+	if encoder == nil {
+		encoder = CanonicalEncoder{}
+	}
+	return requestKey(encoder, nil, d.Request)
+}
+
+// Overridden returns true, if a hook has overridden the decision
+func (d *Decision) Overridden() bool {
+	return d.OverriddenBy != ""
+}
+
+// IsAllowed returns Allowed, it implements api.Decision
+func (d *Decision) IsAllowed() bool {
+	return d.Allowed
+}
+
+// GetError returns Err, it implements api.Decision
+func (d *Decision) GetError() error {
+	return d.Err
+}
+
+// EnforceDecision decides like Enforce, but returns the whole decision including its reasons
+//
+//	d := e.EnforceDecision("alice", "/eu/data1", "write")
+//	if !d.Allowed {
+//		http.Error(w, d.Reason(), http.StatusForbidden)
+//	}
+func (e *Enforcer) EnforceDecision(params ...interface{}) *Decision {
+	ctx, rvals, err := e.splitParams(params...)
+	if err != nil {
+		return &Decision{Request: rvals, Effect: eft.Deny, Err: err}
+	}
+	return e.EnforceDecisionWithContext(ctx, rvals...)
+}
+
+// EnforceDecisionWithContext decides like EnforceWithContext, but returns the whole decision
+func (e *Enforcer) EnforceDecisionWithContext(ctx *Context, rvals ...interface{}) *Decision {
+	return e.decide(ctx, rvals)
+}
+
+// Decide is EnforceDecision for integrators, which depend on api.Enforcer
+func (e *Enforcer) Decide(params ...interface{}) api.Decision {
+	return e.EnforceDecision(params...)
+}
+
+// Explain returns the rule including its policy key, which the effector made responsible for the decision,
+// or nil, if no rule is, e.g. if the default effect applies, the evaluation failed or a hook has overridden the decision.
+func (d *Decision) Explain() []string {
+	if d.Err != nil || d.overridden {
+		return nil
+	}
+	return d.rule
+}
+
+// Source returns the source of the rule returned by Explain, if the adapter tracks provenance, see Enforcer.GetProvenance
+func (d *Decision) Source() (string, bool) {
+	return d.sourceOf(d.Explain())
+}
+
+func (d *Decision) sourceOf(rule []string) (string, bool) {
+	if d.provenance == nil || rule == nil {
+		return "", false
+	}
+	return d.provenance.Provenance(rule)
+}
+
+// EnforceEx decides like Enforce and returns the rule including its policy key, which produced the decision, see Decision.Explain
+//
+//	allowed, rule, _ := e.EnforceEx("alice", "data1", "read") // true, [p alice data1 read]
+func (e *Enforcer) EnforceEx(params ...interface{}) (bool, []string, error) {
+	d := e.EnforceDecision(params...)
+	return d.Allowed, d.Explain(), d.Err
+}
+
+// EnforceExWithContext decides like EnforceWithContext and returns the rule, which produced the decision
+func (e *Enforcer) EnforceExWithContext(ctx *Context, rvals ...interface{}) (bool, []string, error) {
+	d := e.EnforceDecisionWithContext(ctx, rvals...)
+	return d.Allowed, d.Explain(), d.Err
+}
+
+// EnforceWithEffect decides like Enforce, but returns the effect of the matching rules instead of a bool.
+// The effect is Indeterminate, if no matching rule has the effect of the decision, e.g. if no rule matched and
+// the request is denied by default, so an explicit deny can be told apart from a missing permission.
+// The effect never grants more than the decision: requests denied by a hook are Deny, requests allowed by a hook
+// without an allowing rule and failed requests, which are allowed by the fail-open mode, are Indeterminate.
+//
+//	switch effect, _ := e.EnforceWithEffect("alice", "data1", "read"); effect {
+//	case eft.Allow:
+//	case eft.Deny: // 403
+//	case eft.Indeterminate: // 401
+//	}
+func (e *Enforcer) EnforceWithEffect(params ...interface{}) (types.Effect, error) {
+	d := e.EnforceDecision(params...)
+	return d.RawEffect(), d.Err
+}
+
+// RawEffect returns the effect of the decision, if a matching rule has this effect, otherwise Indeterminate.
+// If a hook has overridden the decision, the effect agrees with Allowed, see EnforceWithEffect.
+func (d *Decision) RawEffect() types.Effect {
+	if !d.Allowed && d.Overridden() {
+		return eft.Deny
+	}
+	if d.Err != nil || !d.explicit || (d.Effect == eft.Allow) != d.Allowed {
+		return eft.Indeterminate
+	}
+	return d.Effect
+}
+
+// explicit returns true, if a match of the decision has the effect of the decision
+func (e *Enforcer) explicit(ctx *Context, d *Decision) bool {
+	if len(d.Matches) == 0 {
+		return false
+	}
+	def, ok := e.model.GetDef(m.P_SEC, ctx.matcher.GetPolicyKey())
+	if !ok {
+		return false
+	}
+	pDef := def.(*defs.PolicyDef)
+	for _, rule := range d.Matches {
+		if pDef.GetEft(rule) == d.Effect {
+			return true
+		}
+	}
+	return false
+}
+
+// reasons returns the distinct values of the reason column of the matching deny rules and the first rule of each reason
+func reasons(pDef *defs.PolicyDef, matches [][]string) (res []string, rules [][]string) {
+	name := pDef.GetKey() + "_" + REASON
+	if !pDef.Has(name) {
+		return nil, nil
+	}
+	seen := make(map[string]struct{})
+	for _, rule := range matches {
+		if pDef.GetEft(rule) != eft.Deny {
+			continue
+		}
+		reason, err := pDef.GetParameter(rule, name)
+		if err != nil || reason == "" {
+			continue
+		}
+		if _, ok := seen[reason]; !ok {
+			seen[reason] = struct{}{}
+			res = append(res, reason)
+			rules = append(rules, rule)
+		}
+	}
+	return res, rules
+}
+
+// DecisionHook observes and may override a decision
+type DecisionHook func(d *Decision)
+
+type decisionHook struct {
+	name string
+	fn   DecisionHook
+}
+
+// Option to add a decision hook, see AddDecisionHook
+func OptionDecisionHook(name string, fn DecisionHook) Option {
+	return func(e *Enforcer) error {
+		e.AddDecisionHook(name, fn)
+		return nil
+	}
+}
+
+// AddDecisionHook appends a hook, which is called after every decision of Enforce.
+// An existing hook with the same name is replaced. Hooks run in the order they have been added.
+//
+// Deny all requests during an incident:
+//
+//	e.AddDecisionHook("incident", func(d *Decision) {
+//		if incident.Active() {
+//			d.Override(false)
+//		}
+//	})
+func (e *Enforcer) AddDecisionHook(name string, fn DecisionHook) {
+	for i, h := range e.hooks {
+		if h.name == name {
+			e.hooks[i].fn = fn
+			return
+		}
+	}
+	e.hooks = append(e.hooks, decisionHook{name, fn})
+}
+
+// RemoveDecisionHook removes a decision hook
+func (e *Enforcer) RemoveDecisionHook(name string) bool {
+	for i, h := range e.hooks {
+		if h.name == name {
+			e.hooks = append(e.hooks[:i:i], e.hooks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Enforcer) runDecisionHooks(d *Decision) {
+	for _, h := range e.hooks {
+		d.overridden = false
+		if err := runDecisionHook(h.fn, d); err != nil {
+			d.Allowed = e.failOpen
+			d.Err = err
+			return
+		}
+		if d.overridden {
+			d.OverriddenBy = h.name
+		}
+	}
+}
+
+func runDecisionHook(fn DecisionHook, d *Decision) (err error) {
+	defer recoverPanic(&err)
+	fn(d)
+	return nil
+}
@@ -0,0 +1,199 @@
+package fastac
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac/emitter"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// decisionCache caches the decisions of requests, which are keyed by the RequestEncoder of the enforcer.
+// Invalidating replaces the entries, so decisions computed before an invalidation are stored in the dropped entries.
+type decisionCache struct {
+	size      int
+	ttl       time.Duration
+	mutex     sync.RWMutex
+	entries   util.Cache
+	model     m.IModel
+	listeners []modelListener
+	// version returns the version embedded in the keys of a versioned cache
+	version func() string
+}
+
+type cachedDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+// Option to cache the decisions of Enforce for requests, whose values can be encoded by the RequestEncoder (default: disabled)
+// Maps and structs are keyed by their content, see CanonicalEncoder, requests with ContextOptions or with structs,
+// which have unexported fields, are never cached.
+// At most size decisions are cached, each for ttl, if ttl is greater than 0.
+// The cache is invalidated, whenever rules are added or removed. ClearPolicy, SetFunction and changes of role managers
+// do not emit rule events, call InvalidateCache afterwards. Enforce hooks, decision hooks and the decision log are not run for cached decisions.
+//
+//	NewEnforcer(model, adapter, OptionCache(10000, time.Minute))
+func OptionCache(size int, ttl time.Duration) Option {
+	return func(e *Enforcer) error {
+		if e.decisions != nil {
+			e.decisions.detach()
+			e.decisions = nil
+		}
+		if size == 0 {
+			return nil
+		}
+		if size < 0 || ttl < 0 {
+			return errors.New(str.ERR_INVALID_CACHE)
+		}
+		e.decisions = &decisionCache{size: size, ttl: ttl, entries: util.NewShardedLRUCache(size)}
+		e.decisions.attach(e.model)
+		return nil
+	}
+}
+
+// Option to cache the decisions of Enforce like OptionCache, but keyed by the policy version and the version of the model (default: disabled)
+// Advancing the policy version with SetPolicyVersion or changing rules invalidates all cached decisions at once,
+// the decisions of former versions are never read again and evicted, when the cache is full.
+// It suits edge nodes, which poll the version of a central policy and tolerate staleness until the next check.
+//
+//	NewEnforcer(model, adapter, OptionVersionedCache(10000, 0))
+//	e.SetPolicyVersion("2024-05-01T10:00:00Z")
+func OptionVersionedCache(size int, ttl time.Duration) Option {
+	return func(e *Enforcer) error {
+		if err := OptionCache(0, 0)(e); err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if size < 0 || ttl < 0 {
+			return errors.New(str.ERR_INVALID_CACHE)
+		}
+		e.decisions = &decisionCache{size: size, ttl: ttl, entries: util.NewShardedLRUCache(size), version: func() string {
+			return e.PolicyVersion() + "@" + strconv.FormatUint(e.model.Version(), 10)
+		}}
+		return nil
+	}
+}
+
+// VersionSource returns the current version of a policy, e.g. the ETag of a policy bundle or a version endpoint
+type VersionSource func(ctx context.Context) (string, error)
+
+// SetPolicyVersion sets the version of the policy and returns true, if it changed.
+// A changed version invalidates all cached decisions.
+func (e *Enforcer) SetPolicyVersion(version string) bool {
+	if old, _ := e.policyVersion.Swap(version).(string); old == version {
+		return false
+	}
+	if e.decisions != nil && e.decisions.version == nil {
+		e.decisions.invalidate()
+	}
+	return true
+}
+
+// PolicyVersion returns the version of the policy set by SetPolicyVersion
+func (e *Enforcer) PolicyVersion() string {
+	version, _ := e.policyVersion.Load().(string)
+	return version
+}
+
+// CheckPolicyVersion gets the version of the policy from source and sets it, it returns true, if the version changed
+//
+//	changed, err := e.CheckPolicyVersion(ctx, func(ctx context.Context) (string, error) {
+//		return bundles.Version(ctx, "payments")
+//	})
+func (e *Enforcer) CheckPolicyVersion(ctx context.Context, source VersionSource) (bool, error) {
+	version, err := source(ctx)
+	if err != nil {
+		return false, err
+	}
+	return e.SetPolicyVersion(version), nil
+}
+
+// InvalidateCache drops all cached decisions
+func (e *Enforcer) InvalidateCache() {
+	if e.decisions != nil {
+		e.decisions.invalidate()
+	}
+}
+
+// attach invalidates the cache on the rule events of model, a versioned cache is only invalidated,
+// because the versions of different models may be equal
+func (dc *decisionCache) attach(model m.IModel) {
+	dc.detach()
+	if dc.version != nil {
+		dc.invalidate()
+		return
+	}
+	dc.model = model
+	for _, event := range []emitter.EventType{m.RULE_ADDED, m.RULES_ADDED, m.RULE_REMOVED} {
+		l := model.AddListener(event, func(arguments ...interface{}) {
+			dc.invalidate()
+		})
+		dc.listeners = append(dc.listeners, modelListener{event, l})
+	}
+	dc.invalidate()
+}
+
+func (dc *decisionCache) detach() {
+	for _, l := range dc.listeners {
+		dc.model.RemoveListener(l.event, l.listener)
+	}
+	dc.listeners = nil
+}
+
+func (dc *decisionCache) invalidate() {
+	dc.mutex.Lock()
+	dc.entries = util.NewShardedLRUCache(dc.size)
+	dc.mutex.Unlock()
+}
+
+func (dc *decisionCache) current() util.Cache {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	return dc.entries
+}
+
+// key returns the cache key of a request, false if a parameter is a ContextOption or cannot be encoded
+func (dc *decisionCache) key(encoder RequestEncoder, params []interface{}) (string, bool) {
+	var buf []byte
+	if dc.version != nil {
+		version := dc.version()
+		buf = append(strconv.AppendInt(buf, int64(len(version)), 10), ':')
+		buf = append(buf, version...)
+	}
+	for _, param := range params {
+		if _, ok := param.(ContextOption); ok {
+			return "", false
+		}
+	}
+	key, err := requestKey(encoder, buf, params)
+	return key, err == nil
+}
+
+// enforce returns the cached decision of the request or decides and caches it, errors are not cached
+func (dc *decisionCache) enforce(key string, decide func() (bool, error)) (bool, error) {
+	entries := dc.current()
+	if value, ok := entries.Get(key); ok {
+		cached := value.(cachedDecision)
+		if cached.expires.IsZero() || time.Now().Before(cached.expires) {
+			return cached.allowed, nil
+		}
+	}
+	allowed, err := decide()
+	if err != nil {
+		return allowed, err
+	}
+	cached := cachedDecision{allowed: allowed}
+	if dc.ttl > 0 {
+		cached.expires = time.Now().Add(dc.ttl)
+	}
+	entries.Put(key, cached)
+	return allowed, nil
+}
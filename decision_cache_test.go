@@ -0,0 +1,143 @@
+package fastac
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/util"
+)
+
+const benchModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+`
+
+func TestDecisionCacheExpiry(t *testing.T) {
+	dc := &decisionCache{size: 10, ttl: 20 * time.Millisecond, entries: util.NewShardedLRUCache(10)}
+	calls := 0
+	decide := func() (bool, error) {
+		calls++
+		return true, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := dc.enforce("key", decide); !allowed || err != nil {
+			t.Fatalf("enforce: %v %v", allowed, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("decided %d times, want 1", calls)
+	}
+
+	// the expired decision is decided again and the refreshed decision is cached
+	time.Sleep(30 * time.Millisecond)
+	_, _ = dc.enforce("key", decide)
+	_, _ = dc.enforce("key", decide)
+	if calls != 2 {
+		t.Fatalf("decided %d times after the expiry, want 2", calls)
+	}
+}
+
+func TestDecisionCacheInvalidation(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil, OptionCache(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforce := func(want bool) {
+		t.Helper()
+		if allowed, err := e.Enforce("alice", "data1", "read"); err != nil || allowed != want {
+			t.Fatalf("Enforce: %v %v, want %v", allowed, err, want)
+		}
+	}
+
+	enforce(false)
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	enforce(true)
+	key, ok := e.decisions.key(e.requestEncoder(), []interface{}{"alice", "data1", "read"})
+	if !ok {
+		t.Fatal("request cannot be cached")
+	}
+	if _, ok := e.decisions.current().Get(key); !ok {
+		t.Fatal("decision has not been cached")
+	}
+	if _, err := e.RemoveRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	enforce(false)
+}
+
+// benchEnforcer creates an enforcer with 100 roles, 1000 users and OptionCache(size, 0)
+func benchEnforcer(b *testing.B, size int) *Enforcer {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		b.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil, OptionCache(size, 0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	rules := [][]string{}
+	for i := 0; i < 100; i++ {
+		rules = append(rules, []string{"p", "role" + strconv.Itoa(i), "data" + strconv.Itoa(i), "read"})
+	}
+	for i := 0; i < 1000; i++ {
+		rules = append(rules, []string{"g", "user" + strconv.Itoa(i), "role" + strconv.Itoa(i%100)})
+	}
+	if err := e.AddRules(rules); err != nil {
+		b.Fatal(err)
+	}
+	return e
+}
+
+// benchDecisionCache enforces requests of 1000 users from parallel goroutines, the decisions are cached in cache
+func benchDecisionCache(b *testing.B, cache util.Cache) {
+	e := benchEnforcer(b, 1000)
+	e.decisions.entries = cache
+	users := make([]string, 1000)
+	objects := make([]string, 1000)
+	for i := range users {
+		users[i] = "user" + strconv.Itoa(i)
+		objects[i] = "data" + strconv.Itoa(i%100)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := e.Enforce(users[i%len(users)], objects[i%len(objects)], "read"); err != nil {
+				b.Error(err)
+				return
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkDecisionCacheSyncLRUCache(b *testing.B) {
+	benchDecisionCache(b, util.NewSyncLRUCache(1000))
+}
+
+func BenchmarkDecisionCacheShardedLRUCache(b *testing.B) {
+	benchDecisionCache(b, util.NewShardedLRUCache(1000))
+}
@@ -0,0 +1,129 @@
+package fastac
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/storage/adapter"
+)
+
+const reasonModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft, reason
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = r.sub == p.sub && r.obj == p.obj && r.act == p.act
+`
+
+func TestDecisionBoundRequest(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(reasonModel); err != nil {
+		t.Fatal(err)
+	}
+	catalog := NewMessageCatalog("en")
+	catalog.Register("en", "blocked", "{r.sub} may not {act} {r.obj}")
+	e, err := NewEnforcer(model, nil, OptionMessageCatalog(catalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "write", "deny", "blocked"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := e.EnforceDecision(map[string]interface{}{"sub": "alice", "obj": "data1", "act": "write"})
+	if d.Err != nil || d.Allowed {
+		t.Fatalf("expected deny, got allowed=%v err=%v", d.Allowed, d.Err)
+	}
+	if msg := d.Message("en"); msg != "alice may not write data1" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		RequestArgs map[string]interface{} `json:"request_args"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"sub": "alice", "obj": "data1", "act": "write"}
+	if len(res.RequestArgs) != len(want) {
+		t.Fatalf("unexpected request args %v", res.RequestArgs)
+	}
+	for arg, value := range want {
+		if res.RequestArgs[arg] != value {
+			t.Fatalf("unexpected request args %v", res.RequestArgs)
+		}
+	}
+}
+
+func TestDecisionReasonColumn(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(reasonModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRules([][]string{
+		{"p", "alice", "data1", "read", "allow", ""},
+		{"p", "alice", "data2", "write", "deny", ""},
+		{"p", "bob", "data1", "write", "deny", "blocked"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// rules without the reason column are rejected
+	if _, err := e.AddRule([]string{"p", "bob", "data2", "read", "allow"}); err == nil {
+		t.Fatal("AddRule accepted a rule without the reason column")
+	}
+
+	tests := []struct {
+		request []interface{}
+		allowed bool
+		reasons []string
+	}{
+		{[]interface{}{"alice", "data1", "read"}, true, nil},
+		{[]interface{}{"alice", "data2", "write"}, false, nil},
+		{[]interface{}{"bob", "data1", "write"}, false, []string{"blocked"}},
+		{[]interface{}{"bob", "data2", "read"}, false, nil},
+	}
+	for _, test := range tests {
+		d := e.EnforceDecision(test.request...)
+		if d.Err != nil || d.Allowed != test.allowed {
+			t.Fatalf("%v: allowed=%v err=%v, want %v", test.request, d.Allowed, d.Err, test.allowed)
+		}
+		if len(d.Reasons) != len(test.reasons) || (len(test.reasons) > 0 && d.Reasons[0] != test.reasons[0]) {
+			t.Fatalf("%v: reasons %v, want %v", test.request, d.Reasons, test.reasons)
+		}
+	}
+}
+
+func TestDecisionLoadRulesWithoutReason(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(reasonModel); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	if err := os.WriteFile(path, []byte("p, alice, data1, read, allow\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, adapter.NewFileAdapter(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadPolicy(); err == nil {
+		t.Fatal("LoadPolicy accepted a rule without the reason column")
+	}
+}
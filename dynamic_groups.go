@@ -0,0 +1,251 @@
+package fastac
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/oarkflow/govaluate"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/util"
+)
+
+// DefaultDynamicGroupCacheSize is the number of subjects, whose dynamic groups are cached
+const DefaultDynamicGroupCacheSize = 10000
+
+// SubjectAttributes resolves a subject to its attributes, e.g. a map or struct loaded from a directory
+type SubjectAttributes func(sub string) (interface{}, error)
+
+type dynamicGroup struct {
+	name string
+	expr *govaluate.EvaluableExpression
+}
+
+// dynamicGroups holds the dynamic groups of an enforcer and caches the groups of string subjects
+type dynamicGroups struct {
+	mutex      sync.RWMutex
+	groups     map[string]*dynamicGroup
+	attributes SubjectAttributes
+	cache      *util.ShardedLRUCache
+	// generation is increased, whenever the cache is dropped, so groups evaluated before are not cached
+	generation uint64
+	// bound is true, once the role functions of the model have been extended with the dynamic groups
+	bound bool
+}
+
+func newDynamicGroups() *dynamicGroups {
+	return &dynamicGroups{groups: make(map[string]*dynamicGroup), cache: util.NewShardedLRUCache(DefaultDynamicGroupCacheSize)}
+}
+
+// Option to resolve string subjects to their attributes, which are used by the expressions of dynamic groups
+//
+//	NewEnforcer(model, adapter, OptionSubjectAttributes(func(sub string) (interface{}, error) {
+//		return directory.User(sub)
+//	}))
+func OptionSubjectAttributes(fn SubjectAttributes) Option {
+	return func(e *Enforcer) error {
+		dg, err := e.dynamicGroups()
+		if err != nil {
+			return err
+		}
+		dg.mutex.Lock()
+		defer dg.mutex.Unlock()
+		dg.attributes = fn
+		dg.reset()
+		return nil
+	}
+}
+
+// Option to add a dynamic group, see AddDynamicGroup
+func OptionDynamicGroup(name, expr string) Option {
+	return func(e *Enforcer) error {
+		return e.AddDynamicGroup(name, expr)
+	}
+}
+
+// AddDynamicGroup adds a group, whose members are the subjects matching the attribute expression expr.
+// The expression refers to the subject as r.sub, which are the attributes of SubjectAttributes for string subjects
+// or the subject itself, e.g. a map passed as request value.
+// Dynamic groups can be used like roles by all role definitions, they may also be linked to other roles.
+// The groups of a string subject are cached until InvalidateDynamicGroups is called.
+//
+//	e.AddDynamicGroup("group:engineers", `r.sub.dept == "eng"`)
+//	e.AddRule([]string{"p", "group:engineers", "/repos/*", "read"})
+func (e *Enforcer) AddDynamicGroup(name, expr string) error {
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(defs.ArgReg.ReplaceAllString(expr, "${1}_${3}"), e.model.GetFunctions())
+	if err != nil {
+		return fmt.Errorf("error: dynamic group %s: %w", name, err)
+	}
+	dg, err := e.dynamicGroups()
+	if err != nil {
+		return err
+	}
+	dg.mutex.Lock()
+	defer dg.mutex.Unlock()
+	dg.groups[name] = &dynamicGroup{name: name, expr: expression}
+	dg.reset()
+	return nil
+}
+
+// RemoveDynamicGroup removes a dynamic group
+func (e *Enforcer) RemoveDynamicGroup(name string) bool {
+	dg, err := e.dynamicGroups()
+	if err != nil {
+		e.GetLogger().Error("removing the dynamic group failed", "group", name, "error", err)
+		return false
+	}
+	dg.mutex.Lock()
+	defer dg.mutex.Unlock()
+	_, ok := dg.groups[name]
+	delete(dg.groups, name)
+	dg.reset()
+	return ok
+}
+
+// InvalidateDynamicGroups drops the cached groups of subs, e.g. after their attributes changed, or of all subjects
+func (e *Enforcer) InvalidateDynamicGroups(subs ...string) {
+	dg := e.dynamic
+	dg.mutex.Lock()
+	defer dg.mutex.Unlock()
+	if len(subs) == 0 {
+		dg.reset()
+		return
+	}
+	dg.generation++
+	for _, sub := range subs {
+		dg.cache.Remove(sub)
+	}
+}
+
+// GetDynamicGroups returns the sorted dynamic groups of a subject
+func (e *Enforcer) GetDynamicGroups(sub interface{}) ([]string, error) {
+	return e.dynamic.of(sub)
+}
+
+// dynamicGroups returns the dynamic groups of the enforcer for changes, a fork copies the model first.
+// The functions of all role definitions are extended with the dynamic groups on the first change.
+func (e *Enforcer) dynamicGroups() (*dynamicGroups, error) {
+	if err := e.own(); err != nil {
+		return nil, err
+	}
+	dg := e.dynamic
+	dg.mutex.Lock()
+	defer dg.mutex.Unlock()
+	if !dg.bound {
+		dg.bound = true
+		functions := e.model.GetFunctions()
+		e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+			if g, ok := functions[key]; ok {
+				e.model.SetFunction(key, dg.roleFunc(g))
+			}
+			return true
+		})
+	}
+	return dg, nil
+}
+
+// copy returns a copy of the groups with an empty cache
+func (dg *dynamicGroups) copy() *dynamicGroups {
+	dg.mutex.RLock()
+	defer dg.mutex.RUnlock()
+	c := newDynamicGroups()
+	for name, group := range dg.groups {
+		c.groups[name] = group
+	}
+	c.attributes, c.bound = dg.attributes, dg.bound
+	return c
+}
+
+// isBound returns true, if the role functions have to be extended with the dynamic groups
+func (dg *dynamicGroups) isBound() bool {
+	dg.mutex.RLock()
+	defer dg.mutex.RUnlock()
+	return dg.bound
+}
+
+func (dg *dynamicGroups) reset() {
+	dg.cache = util.NewShardedLRUCache(DefaultDynamicGroupCacheSize)
+	dg.generation++
+}
+
+func (dg *dynamicGroups) of(sub interface{}) ([]string, error) {
+	name, isName := sub.(string)
+	dg.mutex.RLock()
+	cache := dg.cache
+	attributes, generation := dg.attributes, dg.generation
+	all := make([]*dynamicGroup, 0, len(dg.groups))
+	for _, group := range dg.groups {
+		all = append(all, group)
+	}
+	dg.mutex.RUnlock()
+	if len(all) == 0 {
+		return []string{}, nil
+	}
+	if isName {
+		if groups, ok := cache.Get(name); ok {
+			return groups.([]string), nil
+		}
+	}
+
+	if isName && attributes != nil {
+		var err error
+		if sub, err = attributes(name); err != nil {
+			return nil, err
+		}
+	}
+	params := govaluate.MapParameters{"r_sub": sub}
+	groups := []string{}
+	for _, group := range all {
+		res, err := group.expr.Eval(params)
+		if err != nil {
+			return nil, fmt.Errorf("error: dynamic group %s: %w", group.name, err)
+		}
+		if member, _ := res.(bool); member {
+			groups = append(groups, group.name)
+		}
+	}
+	sort.Strings(groups)
+
+	if isName {
+		// the read lock excludes InvalidateDynamicGroups, so no invalidation is missed between the check and the Put
+		dg.mutex.RLock()
+		if dg.generation == generation {
+			dg.cache.Put(name, groups)
+		}
+		dg.mutex.RUnlock()
+	}
+	return groups, nil
+}
+
+// roleFunc extends a role function with the dynamic groups, a subject has a role,
+// if it is linked to it, or one of its dynamic groups is the role or linked to it
+func (dg *dynamicGroups) roleFunc(g govaluate.ExpressionFunction) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) < 2 {
+			return g(args...)
+		}
+		if _, ok := args[0].(string); ok {
+			if res, err := g(args...); err != nil || res == true {
+				return res, err
+			}
+		}
+		groups, err := dg.of(args[0])
+		if err != nil {
+			return false, err
+		}
+		link := make([]interface{}, len(args))
+		copy(link, args)
+		for _, group := range groups {
+			if group == args[1] {
+				return true, nil
+			}
+			link[0] = group
+			if res, err := g(link...); err != nil || res == true {
+				return res, err
+			}
+		}
+		return false, nil
+	}
+}
@@ -61,6 +61,9 @@ func (em *Emitter) EmitEvent(event EventType, arguments ...interface{}) {
 
 func (em *Emitter) emitListenerEvents(listeners []*Listener, arguments []interface{}) {
 	for _, listener := range listeners {
+		if listener.filter != nil && !listener.filter(arguments...) {
+			continue
+		}
 		if em.async {
 			go listener.handler(arguments...)
 			continue
@@ -91,6 +94,20 @@ func (em *Emitter) AddListener(event EventType, handler HandleFunc) (listener *L
 	return listener
 }
 
+// AddFilteredListener adds a listener for the given event type, which is only called, if filter returns true.
+// The filter is evaluated synchronously, even if the emitter is async, so skipped listeners cost no goroutine.
+func (em *Emitter) AddFilteredListener(event EventType, filter FilterFunc, handler HandleFunc) (listener *Listener) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	listener = &Listener{
+		handler: handler,
+		filter:  filter,
+	}
+	em.listeners[event] = append(em.listeners[event], listener)
+	return listener
+}
+
 // ListenOnce adds a listener for the given event type that removes itself after it has been fired once
 func (em *Emitter) ListenOnce(event EventType, handler HandleFunc) (listener *Listener) {
 	em.mu.Lock()
@@ -0,0 +1,72 @@
+package emitter
+
+import (
+	"testing"
+)
+
+const benchListeners = 100
+
+// BenchmarkEmitEvent emits an event to listeners, which are all called
+func BenchmarkEmitEvent(b *testing.B) {
+	em := NewEmitter(false)
+	for i := 0; i < benchListeners; i++ {
+		em.AddListener("event", func(arguments ...interface{}) {})
+	}
+	rule := []string{"g", "alice", "admin", "tenant1"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		em.EmitEvent("event", rule)
+	}
+}
+
+// BenchmarkEmitEventFiltered emits an event to filtered listeners, of which only one is called
+func BenchmarkEmitEventFiltered(b *testing.B) {
+	em := NewEmitter(false)
+	for i := 0; i < benchListeners; i++ {
+		i := i
+		em.AddFilteredListener("event", func(arguments ...interface{}) bool {
+			return i == 0
+		}, func(arguments ...interface{}) {})
+	}
+	rule := []string{"g", "alice", "admin", "tenant1"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		em.EmitEvent("event", rule)
+	}
+}
+
+// BenchmarkEmitEventAsync emits an event to the listeners of an async emitter, every listener is called in a goroutine
+func BenchmarkEmitEventAsync(b *testing.B) {
+	em := NewEmitter(true)
+	for i := 0; i < benchListeners; i++ {
+		em.AddListener("event", func(arguments ...interface{}) {})
+	}
+	rule := []string{"g", "alice", "admin", "tenant1"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		em.EmitEvent("event", rule)
+	}
+}
+
+// BenchmarkEmitEventFilteredAsync is like BenchmarkEmitEventFiltered, skipped listeners of an async emitter start no goroutine
+func BenchmarkEmitEventFilteredAsync(b *testing.B) {
+	em := NewEmitter(true)
+	for i := 0; i < benchListeners; i++ {
+		em.AddFilteredListener("event", func(arguments ...interface{}) bool {
+			return false
+		}, func(arguments ...interface{}) {})
+	}
+	rule := []string{"g", "alice", "admin", "tenant1"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		em.EmitEvent("event", rule)
+	}
+}
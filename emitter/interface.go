@@ -6,9 +6,13 @@ type EventType string
 // HandleFunc is a handler function for a given event type
 type HandleFunc func(arguments ...interface{})
 
+// FilterFunc decides whether a listener is called with the arguments of an event
+type FilterFunc func(arguments ...interface{}) bool
+
 // Listener is a container struct used to remove the listener
 type Listener struct {
 	handler HandleFunc
+	filter  FilterFunc
 }
 
 // CaptureFunc is a capturer function that can capture all emitted events
@@ -15,27 +15,88 @@
 package fastac
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/oarkflow/fastac/logger"
 	m "github.com/oarkflow/fastac/model"
 	"github.com/oarkflow/fastac/model/defs"
 	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/matcher"
 	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/rbac"
 	"github.com/oarkflow/fastac/storage"
 	a "github.com/oarkflow/fastac/storage/adapter"
+	"github.com/oarkflow/fastac/storage/wal"
 	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+	"github.com/oarkflow/govaluate"
 )
 
 type Enforcer struct {
 	model   m.IModel
 	adapter storage.Adapter
 	sc      *storage.StorageController
+
+	vocab       *Vocabulary
+	strictVocab bool
+	limits      RequestLimits
+
+	regex     *util.SafeRegex
+	regexArgs map[string][]string
+	// regexCtx binds the regexMatch of regex to context.Background(), see functionContext
+	regexCtx context.Context
+
+	failOpen      bool
+	defaultEffect *types.Effect
+
+	preprocessors []preprocessor
+	hooks         []decisionHook
+	messages      *MessageCatalog
+	admit         func(rules [][]string) error
+	wal           *writeAheadLog
+	readOnly      int32
+	migrations    MigrationStore
+	logger        logger.Logger
+	trace         io.Writer
+	anonymous     string
+	dynamic       *dynamicGroups
+	stamps        ruleStamps
+	tombstones    *tombstones
+	decisions     *decisionCache
+	matchers      *matcherCache
+	policyVersion atomic.Value
+	enforceHooks  []enforceHook
+	shadowing     *shadowEnforcer
+	canary        *canary
+	env           string
+	encoder       RequestEncoder
+	// forkOf is the enforcer, whose model a fork shares until it changes its model
+	forkOf    *Enforcer
+	forkMutex sync.Mutex
+	// modelPath is the path of the model file, if the model has been loaded from a file
+	modelPath   string
+	watch       *fileWatch
+	fileWatcher FileWatcher
+	// lock is the write lock of the SyncedEnforcer wrapping the enforcer, it guards the reloads of OptionWatchFiles
+	lock sync.Locker
+	// modelListeners follow the model, see AddModelListener
+	modelListeners []*ModelListener
+	listenerMutex  sync.Mutex
 }
 
 type Option func(*Enforcer) error
 
 // Option to disable/enable the autosave feature (default: disabled)
 // If autosave is disabled, Flush needs to be called to save modified rules
+// If saving fails, AddRule and the like return the error, but keep the change in the model. The failed operations stay queued
+// and are dropped after storage.DefaultMaxFlushAttempts, the storage controller emits storage.FLUSH_FAILED for every failure.
 // Enable autosave:
 //
 //	NewEnforcer(model, adapter, OptionAutosave(true))
@@ -68,6 +129,36 @@ func OptionStorage(enable bool) Option {
 	}
 }
 
+// Option to register a vocabulary of valid argument values (default: none)
+// If strict is enabled, AddRule and Enforce reject values, which are not part of the vocabulary,
+// patterns like "*" are accepted in rules, but not in requests
+//
+//	v := NewVocabulary()
+//	v.Register("act", "read", "write")
+//	NewEnforcer(model, adapter, OptionVocabulary(v, true))
+func OptionVocabulary(vocab *Vocabulary, strict bool) Option {
+	return func(e *Enforcer) error {
+		e.vocab = vocab
+		e.strictVocab = strict
+		return nil
+	}
+}
+
+// Option to replace regexMatch with a util.SafeRegex, which enforces the limits of opts
+// Patterns of policy values used by regexMatch are checked, when rules are added or loaded.
+// The functions of the model are not changed, so other enforcers sharing the model keep the regexMatch of the model.
+// Evaluations stop after the timeout of opts or, if the request has a context, when the context is done.
+//
+//	NewEnforcer(model, adapter, OptionSafeRegex(util.DefaultRegexOptions))
+func OptionSafeRegex(opts util.RegexOptions) Option {
+	return func(e *Enforcer) error {
+		e.regex = util.NewSafeRegex(opts)
+		e.regexCtx = matcher.WithFunctions(context.Background(), map[string]govaluate.ExpressionFunction{"regexMatch": e.regex.Func()})
+		e.regexArgs = regexArgs(e.model)
+		return e.checkRules()
+	}
+}
+
 // NewEnforcer creates a new Enforcer instance. An Enforcer is the main item of FastAC
 //
 // Without adapter and default options:
@@ -79,7 +170,7 @@ func OptionStorage(enable bool) Option {
 //	adapter := gormadapter.NewAdapter(db, tableName)
 //	NewEnforcer("model.conf", adapter, OptionAutosave(true))
 func NewEnforcer(model interface{}, adapter interface{}, options ...Option) (*Enforcer, error) {
-	e := &Enforcer{}
+	e := &Enforcer{dynamic: newDynamicGroups()}
 
 	switch m2 := model.(type) {
 	case string:
@@ -87,6 +178,7 @@ func NewEnforcer(model interface{}, adapter interface{}, options ...Option) (*En
 			return nil, err
 		} else {
 			e.model = m
+			e.modelPath = m2
 		}
 	case m.Model:
 		e.model = &m2
@@ -95,14 +187,16 @@ func NewEnforcer(model interface{}, adapter interface{}, options ...Option) (*En
 	default:
 		return nil, errors.New(str.ERR_INVALID_MODEL)
 	}
+	e.matchers = newMatcherCache(e.model, DefaultMatcherCacheSize)
 
 	var a3 storage.Adapter
 	switch a2 := adapter.(type) {
 	case string:
-		a3 := a.NewFileAdapter(a2)
-		if err := a3.LoadPolicy(e.model); err != nil {
+		fa := a.NewFileAdapter(a2)
+		if err := fa.LoadPolicy(e.model); err != nil {
 			return nil, err
 		}
+		a3 = fa
 	case storage.Adapter:
 		a3 = a2
 	default:
@@ -123,6 +217,9 @@ func NewEnforcer(model interface{}, adapter interface{}, options ...Option) (*En
 
 // SetOption applies an option to the Enforcer
 func (e *Enforcer) SetOption(option Option) error {
+	if err := e.own(); err != nil {
+		return err
+	}
 	return option(e)
 }
 
@@ -138,6 +235,8 @@ func (e *Enforcer) SetAdapter(adapter storage.Adapter) {
 		e.sc.Disable()
 	}
 	e.sc = storage.NewStorageController(e.model, adapter, autosave)
+	e.sc.SetLogger(e.logger)
+	e.sc.SetSoftDelete(e.tombstones != nil)
 	e.adapter = adapter
 }
 
@@ -145,19 +244,130 @@ func (e *Enforcer) GetAdapter() storage.Adapter {
 	return e.adapter
 }
 
+// GetProvenance returns the source of a rule, if the adapter tracks provenance
+//
+//	e.GetProvenance([]string{"p", "alice", "data1", "read"}) // "base", true
+func (e *Enforcer) GetProvenance(rule []string) (string, bool) {
+	if pa, ok := e.adapter.(storage.ProvenanceAdapter); ok {
+		return pa.Provenance(rule)
+	}
+	return "", false
+}
+
+// SourcedRule is a rule including its policy key and the source it has been loaded from, see GetProvenance
+type SourcedRule struct {
+	Rule   []string `json:"rule"`
+	Source string   `json:"source,omitempty"`
+}
+
 // LoadPolicy loads all rules from the storage adapter into the model.
-// The model is not cleared before the loading process
+// The model is not cleared before the loading process, use ReloadPolicy to replace the rules by the rules of the adapter
 func (e *Enforcer) LoadPolicy() error {
+	return e.LoadPolicyCtx(context.Background())
+}
+
+// LoadPolicyCtx is like LoadPolicy, adapters implementing storage.ContextAdapter stop loading, if ctx is canceled
+func (e *Enforcer) LoadPolicyCtx(ctx context.Context) error {
+	if err := e.own(); err != nil {
+		return err
+	}
+	if e.sc.Enabled() {
+		e.sc.Disable()
+		defer e.sc.Enable()
+	}
+	var err error
+	if ca, ok := e.adapter.(storage.ContextAdapter); ok {
+		err = ca.LoadPolicyContext(ctx, e.loadTarget(e.model))
+	} else if err = ctx.Err(); err == nil {
+		err = e.adapter.LoadPolicy(e.loadTarget(e.model))
+	}
+	if err != nil {
+		e.GetLogger().Error("loading policy failed", "error", err)
+		return err
+	}
+	return e.checkLoaded()
+}
+
+// LoadFilteredPolicy loads only the rules matching the filter from the storage adapter into the model,
+// e.g. the rules of a tenant of a large table. The adapter must implement storage.FilteredAdapter.
+// The model is not cleared before the loading process, SavePolicy is refused until LoadPolicy loads all rules again.
+//
+//	e.LoadFilteredPolicy(storage.Filter{"p": {"", "tenant1"}, "g": {"", "", "tenant1"}})
+func (e *Enforcer) LoadFilteredPolicy(filter storage.Filter) error {
+	fa, ok := e.adapter.(storage.FilteredAdapter)
+	if !ok {
+		return errors.New(str.ERR_FILTER_UNSUPPORTED)
+	}
+	if err := e.own(); err != nil {
+		return err
+	}
 	if e.sc.Enabled() {
 		e.sc.Disable()
 		defer e.sc.Enable()
 	}
-	return e.adapter.LoadPolicy(e.model)
+	if err := fa.LoadFilteredPolicy(e.loadTarget(e.model), filter); err != nil {
+		e.GetLogger().Error("loading filtered policy failed", "error", err)
+		return err
+	}
+	return e.checkLoaded()
+}
+
+// IsFiltered returns true, if the rules have been loaded by LoadFilteredPolicy or are scoped to an environment, see OptionEnvironment
+func (e *Enforcer) IsFiltered() bool {
+	if e.envScoped() {
+		return true
+	}
+	fa, ok := e.adapter.(storage.FilteredAdapter)
+	return ok && fa.IsFiltered()
+}
+
+// checkLoaded checks the loaded rules
+func (e *Enforcer) checkLoaded() error {
+	if e.admit != nil {
+		if err := e.admit(nil); err != nil {
+			return err
+		}
+	}
+	if err := e.loadTombstones(); err != nil {
+		return err
+	}
+	return e.checkRules()
 }
 
 // SavePolicy stores all rules from the model into the storage adapter.
+// The rules are saved from a snapshot, so concurrent writes do not tear the saved state.
 func (e *Enforcer) SavePolicy() error {
-	return e.adapter.SavePolicy(e.model)
+	_, err := e.SaveSnapshot()
+	return err
+}
+
+// SaveSnapshot stores a consistent snapshot of all rules into the storage adapter
+// and returns the model version of the snapshot
+func (e *Enforcer) SaveSnapshot() (uint64, error) {
+	return e.saveSnapshot(context.Background())
+}
+
+// SavePolicyCtx is like SavePolicy, adapters implementing storage.ContextAdapter abort saving, if ctx is canceled
+func (e *Enforcer) SavePolicyCtx(ctx context.Context) error {
+	_, err := e.saveSnapshot(ctx)
+	return err
+}
+
+func (e *Enforcer) saveSnapshot(ctx context.Context) (uint64, error) {
+	if e.IsFiltered() {
+		return 0, errors.New(str.ERR_FILTERED_SAVE)
+	}
+	snapshot := e.model.Snapshot()
+	var err error
+	if ca, ok := e.adapter.(storage.ContextAdapter); ok {
+		err = ca.SavePolicyContext(ctx, snapshot)
+	} else if err = ctx.Err(); err == nil {
+		err = e.adapter.SavePolicy(snapshot)
+	}
+	if err != nil {
+		e.GetLogger().Error("saving policy failed", "version", snapshot.Version, "error", err)
+	}
+	return snapshot.Version, err
 }
 
 // Flush sends all the modifications of the rule set to the storage adapter.
@@ -170,6 +380,51 @@ func (e *Enforcer) Flush() error {
 	return e.sc.Flush()
 }
 
+// FlushCtx is like Flush, the pending modifications are kept, if ctx is canceled before they are sent
+func (e *Enforcer) FlushCtx(ctx context.Context) error {
+	return e.sc.FlushContext(ctx)
+}
+
+// SetReadOnly enables or disables the read-only mode.
+// In read-only mode, rule changes through the enforcer are rejected, e.g. on followers of a cluster,
+// rules can still be loaded with LoadPolicy.
+func (e *Enforcer) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+	atomic.StoreInt32(&e.readOnly, v)
+}
+
+// IsReadOnly returns true, if rule changes through the enforcer are rejected
+func (e *Enforcer) IsReadOnly() bool {
+	return atomic.LoadInt32(&e.readOnly) == 1
+}
+
+// ApplyReplicated removes and adds rules, which were already accepted by another enforcer, e.g. the primary of a replication.
+// The read-only mode and the admission of rules are bypassed.
+func (e *Enforcer) ApplyReplicated(removed, added [][]string) error {
+	if err := e.own(); err != nil {
+		return err
+	}
+	for _, rule := range removed {
+		if ok, err := e.model.RemoveRule(rule); err != nil {
+			return err
+		} else if ok {
+			e.bury(rule)
+			e.stamps.drop(rule)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	_, err := e.model.AddRules(added)
+	for _, rule := range added {
+		e.unbury(rule)
+	}
+	return err
+}
+
 // AddRule adds a rule to the model
 // Returns false, if the rule was already present
 //
@@ -181,7 +436,51 @@ func (e *Enforcer) Flush() error {
 //
 //	e.AddRule([]string{"g", "alice", "group1"})
 func (e *Enforcer) AddRule(rule []string) (bool, error) {
-	return e.model.AddRule(rule)
+	if e.IsReadOnly() {
+		return false, errors.New(str.ERR_READ_ONLY)
+	}
+	if err := e.own(); err != nil {
+		return false, err
+	}
+	if err := e.checkRule(rule); err != nil {
+		return false, err
+	}
+	if e.admit != nil {
+		if err := e.admit([][]string{rule}); err != nil {
+			return false, err
+		}
+	}
+	done, err := e.logRules(wal.ADD, rule)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	e.sc.ResetErr()
+	added, err := e.model.AddRule(rule)
+	if added {
+		e.unbury(rule)
+	}
+	return added, e.storageErr(err)
+}
+
+// AddRuleCtx is like AddRule, the autosave flush of the rule stops with the error of ctx, if ctx is canceled.
+// The rule stays in the model and queued for the next flush in that case.
+func (e *Enforcer) AddRuleCtx(ctx context.Context, rule []string) (added bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	e.sc.WithContext(ctx, func() {
+		added, err = e.AddRule(rule)
+	})
+	return added, err
+}
+
+// storageErr returns err or the error of the autosave flush of the last change, the changed rules stay in the model
+func (e *Enforcer) storageErr(err error) error {
+	if err != nil {
+		return err
+	}
+	return e.sc.Err()
 }
 
 // RemoveRule removes a rule from the model
@@ -195,43 +494,126 @@ func (e *Enforcer) AddRule(rule []string) (bool, error) {
 //
 //	e.RemoveRule([]string{"g", "alice", "group1"})
 func (e *Enforcer) RemoveRule(rule []string) (bool, error) {
-	return e.model.RemoveRule(rule)
+	if e.IsReadOnly() {
+		return false, errors.New(str.ERR_READ_ONLY)
+	}
+	if err := e.own(); err != nil {
+		return false, err
+	}
+	done, err := e.logRules(wal.REMOVE, rule)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	e.sc.ResetErr()
+	removed, err := e.model.RemoveRule(rule)
+	if removed {
+		e.bury(rule)
+		e.stamps.drop(rule)
+	}
+	return removed, e.storageErr(err)
+}
+
+// RemoveRuleCtx is like RemoveRule, the autosave flush of the removal stops with the error of ctx, if ctx is canceled.
+// The rule stays removed from the model and the removal queued for the next flush in that case.
+func (e *Enforcer) RemoveRuleCtx(ctx context.Context, rule []string) (removed bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	e.sc.WithContext(ctx, func() {
+		removed, err = e.RemoveRule(rule)
+	})
+	return removed, err
+}
+
+// HasRule returns true, if the rule is present in the model
+//
+//	e.HasRule([]string{"p", "alice", "data1", "read"})
+func (e *Enforcer) HasRule(rule []string) bool {
+	return e.model.HasRule(rule)
+}
+
+// GetRuleByHash returns the rule, whose util.Hash is key
+//
+//	e.GetRuleByHash(util.Hash([]string{"g", "alice", "group1"}))
+func (e *Enforcer) GetRuleByHash(key string) ([]string, bool) {
+	return e.model.GetRuleByHash(key)
+}
+
+// HasLinks checks many role memberships of the role definition key at once.
+// The result contains for every pair whether pair[0] inherits pair[1].
+//
+//	e.HasLinks("g", [][2]string{{"alice", "admin"}, {"alice", "editor"}, {"bob", "admin"}})
+func (e *Enforcer) HasLinks(key string, pairs [][2]string, domain ...string) ([]bool, error) {
+	if _, ok := e.model.GetDef(m.G_SEC, key); !ok {
+		return nil, fmt.Errorf(str.ERR_RM_NOT_FOUND, key)
+	}
+	rm, _ := e.model.GetRoleManager(key)
+	return rbac.HasLinks(rm, pairs, domain...)
 }
 
-// AddRules adds multiple rules to the model
+// AddRules adds multiple rules to the model.
+// The rules are added in bulk: duplicates are skipped, a single RULES_ADDED event is emitted
+// and the storage adapter receives all rules in one batch.
 func (e *Enforcer) AddRules(rules [][]string) error {
-	if e.sc.AutosaveEnabled() {
-		e.sc.DisableAutosave()
-		defer func() {
-			e.sc.EnableAutosave()
-			if err := e.sc.Flush(); err != nil {
-				panic(err)
-			}
-		}()
+	if e.IsReadOnly() {
+		return errors.New(str.ERR_READ_ONLY)
+	}
+	if err := e.own(); err != nil {
+		return err
 	}
 	for _, rule := range rules {
-		if _, err := e.model.AddRule(rule); err != nil {
+		if err := e.checkRule(rule); err != nil {
 			return err
 		}
 	}
-	return nil
+	if e.admit != nil {
+		if err := e.admit(rules); err != nil {
+			return err
+		}
+	}
+	done, err := e.logRules(wal.ADD, rules...)
+	if err != nil {
+		return err
+	}
+	defer done()
+	e.sc.ResetErr()
+	added, err := e.model.AddRules(rules)
+	e.unbury(added...)
+	return e.storageErr(err)
 }
 
 // RemoveRules removes multiple rules from the model
-func (e *Enforcer) RemoveRules(rules [][]string) error {
+func (e *Enforcer) RemoveRules(rules [][]string) (err error) {
+	if e.IsReadOnly() {
+		return errors.New(str.ERR_READ_ONLY)
+	}
+	if err := e.own(); err != nil {
+		return err
+	}
+	done, err := e.logRules(wal.REMOVE, rules...)
+	if err != nil {
+		return err
+	}
+	defer done()
 	if e.sc.AutosaveEnabled() {
 		e.sc.DisableAutosave()
 		defer func() {
 			e.sc.EnableAutosave()
-			if err := e.sc.Flush(); err != nil {
-				panic(err)
+			if ferr := e.sc.Flush(); ferr != nil && err == nil {
+				err = ferr
 			}
 		}()
 	}
 	for _, rule := range rules {
-		if _, err := e.model.RemoveRule(rule); err != nil {
+		removed, err := e.model.RemoveRule(rule)
+		if err != nil {
 			return err
 		}
+		if removed {
+			e.bury(rule)
+			e.stamps.drop(rule)
+		}
 	}
 	return nil
 }
@@ -254,8 +636,24 @@ func (e *Enforcer) splitParams(params ...interface{}) (ctx *Context, request []i
 }
 
 // Enforce decides whether to allow or deny a request
-// It is possible to pass ContextOptions, everything else will be treated as a request value
+// It is possible to pass ContextOptions, everything else will be treated as a request value.
+// The request may also be passed as a single struct or map, whose fields are bound to the request arguments by name,
+// struct fields by their tag `fastac:"name"` or their case-insensitive name:
+//
+//	e.Enforce(Request{Sub: "alice", Obj: doc, Act: "read"})
+//	e.Enforce(map[string]interface{}{"sub": "alice", "obj": doc, "act": "read"})
 func (e *Enforcer) Enforce(params ...interface{}) (bool, error) {
+	if e.decisions != nil {
+		if key, ok := e.decisions.key(e.requestEncoder(), params); ok {
+			return e.decisions.enforce(key, func() (bool, error) {
+				return e.enforce(params)
+			})
+		}
+	}
+	return e.enforce(params)
+}
+
+func (e *Enforcer) enforce(params []interface{}) (bool, error) {
 	ctx, rvals, err := e.splitParams(params...)
 	if err != nil {
 		return false, err
@@ -263,13 +661,103 @@ func (e *Enforcer) Enforce(params ...interface{}) (bool, error) {
 	return e.EnforceWithContext(ctx, rvals...)
 }
 
+// EnforceCtx decides like Enforce, the evaluation stops with the error of ctx, if ctx is canceled or its deadline is exceeded
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+//	defer cancel()
+//	e.EnforceCtx(ctx, "alice", "data1", "read")
+func (e *Enforcer) EnforceCtx(ctx context.Context, params ...interface{}) (bool, error) {
+	return e.Enforce(append(params[:len(params):len(params)], WithContext(ctx))...)
+}
+
+// EnforceWithKeys decides like Enforce with the given request, policy, effect and matcher definitions, an empty key selects the default
+//
+//	e.EnforceWithKeys("r2", "p2", "e2", "m2", "alice", "data1", "read")
+//	e.EnforceWithKeys("", "p2", "", "", "alice", "data1", "read")
+func (e *Enforcer) EnforceWithKeys(rKey, pKey, eKey, mKey string, params ...interface{}) (bool, error) {
+	return e.Enforce(append(params[:len(params):len(params)], SetRequestDef(rKey), SetPolicyKey(pKey), SetEffector(eKey), SetMatcher(mKey))...)
+}
+
 func (e *Enforcer) EnforceWithContext(ctx *Context, rvals ...interface{}) (bool, error) {
-	b, err := e.enforce(ctx, rvals)
+	d := e.decide(ctx, rvals)
+	return d.Allowed, d.Err
+}
+
+// evaluate evaluates the request and runs the decision hooks
+func (e *Enforcer) evaluate(ctx *Context, rvals []interface{}) *Decision {
+	d := &Decision{Request: rvals, Effect: eft.Deny, encoder: e.encoder}
+	d.provenance, _ = e.adapter.(storage.ProvenanceAdapter)
+	ctx = e.traced(ctx)
+	start := time.Now()
+
+	prepared, err := e.prepare(ctx, rvals)
+	if err == nil {
+		ctx, err = ctx.routed(prepared)
+	}
+	d.Timings.Prepare = time.Since(start)
 	if err != nil {
-		return b, err
+		d.Err = err
+	} else {
+		d.rDef, d.values = ctx.rDef, prepared
+		d.Effect, d.rule, d.Matches, d.Err = e.enforceEffect(ctx, prepared)
+		d.Effects = e.effectNames(ctx, d.Matches)
+		d.explicit = e.explicit(ctx, d)
+		if d.Err != nil {
+			d.Allowed = e.failOpen
+		} else {
+			d.Allowed = d.Effect == eft.Allow
+		}
+		if !d.Allowed {
+			if def, ok := e.model.GetDef(m.P_SEC, ctx.matcher.GetPolicyKey()); ok {
+				d.pDef, d.catalog = def.(*defs.PolicyDef), e.messages
+				d.Reasons, d.reasonRules = reasons(d.pDef, d.Matches)
+			}
+		}
 	}
+	d.Timings.Total = time.Since(start)
+	d.Timings.Evaluate = d.Timings.Total - d.Timings.Prepare
 
-	return b, err
+	if ctx.tracer != nil {
+		ctx.tracer.decision(d)
+	}
+	e.logDecision(d)
+	e.runDecisionHooks(d)
+	return d
+}
+
+// effectNames returns the values of the eft column of the matches
+func (e *Enforcer) effectNames(ctx *Context, matches [][]string) []string {
+	def, ok := e.model.GetDef(m.P_SEC, ctx.matcher.GetPolicyKey())
+	if !ok || len(matches) == 0 {
+		return nil
+	}
+	pDef := def.(*defs.PolicyDef)
+	names := make([]string, len(matches))
+	for i, rule := range matches {
+		names[i] = pDef.GetEftName(rule)
+	}
+	return names
+}
+
+// prepare checks the limits, runs the preprocessors and validates the result against the vocabulary
+func (e *Enforcer) prepare(ctx *Context, rvals []interface{}) (res []interface{}, err error) {
+	defer recoverPanic(&err)
+
+	if rvals, err = bindRequest(ctx.rDef, rvals); err != nil {
+		return nil, err
+	}
+	if err := e.limits.Check(rvals); err != nil {
+		return nil, err
+	}
+	if rvals, err = e.preprocess(ctx.rDef, rvals); err != nil {
+		return nil, err
+	}
+	if e.vocab != nil && e.strictVocab {
+		if err := e.vocab.CheckRequest(ctx.rDef.GetArgs(), rvals); err != nil {
+			return nil, err
+		}
+	}
+	return rvals, nil
 }
 
 // Filter will fetch all rules which match the given request
@@ -300,6 +788,27 @@ func (e *Enforcer) FilterWithContext(ctx *Context, rvals ...interface{}) ([][]st
 	return rules, err
 }
 
+// FilterSources is like Filter, but returns the source of every rule, if the adapter tracks provenance
+//
+//	rules, _ := e.FilterSources(SetMatcher("p.user == \"alice\""))
+//	rules[0].Source // "overlay"
+func (e *Enforcer) FilterSources(params ...interface{}) ([]SourcedRule, error) {
+	rules, err := e.Filter(params...)
+	if err != nil {
+		return nil, err
+	}
+	sourced := make([]SourcedRule, len(rules))
+	for i, rule := range rules {
+		sourced[i].Rule = rule
+		sourced[i].Source, _ = e.GetProvenance(rule)
+	}
+	return sourced, nil
+}
+
+// RangeMatches calls fn for every rule, which matches the request, until fn returns false.
+// The matches are collected before fn is called, so fn may change the policy.
+// With WithContext, the matches are passed to fn while they are evaluated, so the evaluation stops as soon as fn returns false,
+// but fn must not change the policy.
 func (e *Enforcer) RangeMatches(params []interface{}, fn func(rule []string) bool) error {
 	ctx, rvals, err := e.splitParams(params...)
 	if err != nil {
@@ -309,48 +818,252 @@ func (e *Enforcer) RangeMatches(params []interface{}, fn func(rule []string) boo
 }
 
 func (e *Enforcer) RangeMatchesWithContext(ctx *Context, rvals []interface{}, fn func(rule []string) bool) error {
-	return e.model.RangeMatches(ctx.matcher, ctx.rDef, rvals, fn)
+	rvals, err := e.prepare(ctx, rvals)
+	if err != nil {
+		return err
+	}
+	if ctx, err = ctx.routed(rvals); err != nil {
+		return err
+	}
+	if ctx.filterEffect {
+		def, ok := e.model.GetDef(m.P_SEC, ctx.matcher.GetPolicyKey())
+		if !ok {
+			return fmt.Errorf(str.ERR_POLICY_NOT_FOUND, ctx.matcher.GetPolicyKey())
+		}
+		pDef, next := def.(*defs.PolicyDef), fn
+		fn = func(rule []string) bool {
+			if pDef.GetEft(rule) != ctx.onlyEffect {
+				return true
+			}
+			return next(rule)
+		}
+	}
+	return e.rangeMatches(ctx, rvals, fn)
+}
+
+func (e *Enforcer) rangeMatches(ctx *Context, rvals []interface{}, fn func(rule []string) bool) (err error) {
+	defer recoverPanic(&err)
+
+	goctx := e.functionContext(ctx.goctx, true)
+	switch {
+	case goctx == nil:
+		return e.model.RangeMatches(ctx.matcher, ctx.rDef, rvals, fn)
+	case ctx.goctx == nil:
+		// the matches are collected like by RangeMatches, so fn may change the policy
+		matches := [][]string{}
+		err := e.model.RangeMatchesContext(goctx, ctx.matcher, ctx.rDef, rvals, nil, func(rule []string) bool {
+			matches = append(matches, rule)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		for _, rule := range matches {
+			if !fn(rule) {
+				break
+			}
+		}
+		return nil
+	default:
+		return e.model.RangeMatchesContext(goctx, ctx.matcher, ctx.rDef, rvals, nil, fn)
+	}
 }
 
-func (e *Enforcer) enforce(ctx *Context, rvals []interface{}) (bool, error) {
+// enforceEffect returns the merged effect, the rule the effector made responsible for it and all rules, which have been evaluated by the effector.
+// The rule is nil, if no rule determined the effect, e.g. if the default effect applies.
+func (e *Enforcer) enforceEffect(ctx *Context, rvals []interface{}) (res types.Effect, rule []string, matches [][]string, err error) {
+	defer recoverPanic(&err)
+
 	def, _ := e.model.GetDef(m.P_SEC, ctx.matcher.GetPolicyKey())
 	pDef := def.(*defs.PolicyDef)
-	res := eft.Indeterminate
+	res = eft.Indeterminate
 	effects := []types.Effect{}
-	matches := [][]string{}
+	matches = [][]string{}
 
+	var responsible []string
 	var eftErr error = nil
-	err := e.RangeMatchesWithContext(ctx, rvals, func(rule []string) bool {
+	collect := func(rule []string) bool {
 		effect := pDef.GetEft(rule)
 
 		effects = append(effects, effect)
 		matches = append(matches, rule)
 
-		res, _, eftErr = ctx.effector.MergeEffects(effects, matches, false)
+		res, responsible, eftErr = ctx.effector.MergeEffects(effects, matches, false)
+		if ctx.tracer != nil {
+			ctx.tracer.merged(rule, pDef.GetEftName(rule), res)
+		}
 
 		if eftErr != nil || res != eft.Indeterminate {
 			return false
 		}
 		return true
-	})
+	}
+	sessions := ctx.sessions != nil && ctx.tracer == nil
+	// the functions of the sessions contain the safe regexMatch already
+	goctx := e.functionContext(ctx.goctx, !sessions)
+	switch {
+	case sessions:
+		err = ctx.sessions.rangeMatches(goctx, ctx.matcher, ctx.rDef, rvals, collect)
+	case goctx != nil:
+		var tracer matcher.Tracer
+		if ctx.tracer != nil {
+			tracer = ctx.tracer
+		}
+		err = e.model.RangeMatchesContext(goctx, ctx.matcher, ctx.rDef, rvals, tracer, collect)
+	case ctx.tracer != nil:
+		err = e.model.RangeMatchesTraced(ctx.matcher, ctx.rDef, rvals, ctx.tracer, collect)
+	default:
+		err = e.model.RangeMatchesLocked(ctx.matcher, ctx.rDef, rvals, collect)
+	}
 	if err != nil {
-		return false, err
+		return eft.Deny, nil, matches, err
 	}
 	if eftErr != nil {
-		return false, err
+		return eft.Deny, nil, matches, eftErr
 	}
 
 	if res == eft.Indeterminate {
-		res, _, _ = ctx.effector.MergeEffects(effects, matches, true)
+		res, responsible, _ = ctx.effector.MergeEffects(effects, matches, true)
+		if e.defaultEffect != nil && !containsEffect(effects, res) {
+			res, responsible = *e.defaultEffect, nil
+		}
+	}
+	if len(responsible) == 0 {
+		responsible = nil
 	}
 
-	return res == eft.Allow, nil
+	return res, responsible, matches, nil
 }
 
 func (e *Enforcer) SetModel(model m.IModel) {
+	old := e.model
+	e.forkOf = nil
 	e.model = model
+	if old != model {
+		e.moveModelListeners(old)
+		if e.sc != nil {
+			e.sc.Attach(model)
+		}
+	}
+	if e.decisions != nil {
+		e.decisions.attach(model)
+	}
 }
 
+// GetModel returns the model for changes. A fork copies the model of its parent first and returns nil, if copying fails.
+// Use ViewModel to read the model.
 func (e *Enforcer) GetModel() m.IModel {
+	if err := e.own(); err != nil {
+		e.GetLogger().Error("copying the model of the fork failed", "error", err)
+		return nil
+	}
+	return e.model
+}
+
+// ViewModel returns the model without copying the model of the parent of a fork, it must not be changed
+func (e *Enforcer) ViewModel() m.IModel {
 	return e.model
 }
+
+// GetVocabulary returns the registered vocabulary or nil
+func (e *Enforcer) GetVocabulary() *Vocabulary {
+	return e.vocab
+}
+
+// GetExpandedPolicy returns the rules of a policy, where patterns are replaced by
+// the matching values of the vocabulary. Patterns without matching values are kept.
+//
+//	e.GetExpandedPolicy("p")
+func (e *Enforcer) GetExpandedPolicy(key string) ([][]string, error) {
+	def, ok := e.model.GetDef(m.P_SEC, key)
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
+	}
+	policy, _ := e.model.GetPolicy(key)
+
+	rules := [][]string{}
+	policy.Range(func(rule []string) bool {
+		rules = append(rules, append([]string{key}, rule...))
+		return true
+	})
+	if e.vocab == nil {
+		return rules, nil
+	}
+
+	args := def.(*defs.PolicyDef).GetArgs()
+	return util.ExpandRules(rules, e.vocab.Columns(args, 1), e.vocab.Matchers()...), nil
+}
+
+// checkRule validates a policy rule against the vocabulary, the regex limits and the columns of its definition
+func (e *Enforcer) checkRule(rule []string) error {
+	if len(rule) == 0 || rule[0] == "" {
+		return nil
+	}
+	def, ok := e.model.GetDef(m.P_SEC, rule[0])
+	if !ok {
+		return nil
+	}
+	pDef := def.(*defs.PolicyDef)
+
+	if !e.InEnvironment(rule) {
+		return fmt.Errorf(str.ERR_OTHER_ENV, util.Hash(rule), e.env)
+	}
+	if pDef.Has(rule[0]+"_"+REASON) && len(rule)-1 != len(pDef.GetArgs()) {
+		return fmt.Errorf(str.ERR_RULE_COLUMNS, util.Hash(rule), len(rule)-1, rule[0], len(pDef.GetArgs()))
+	}
+	if len(e.model.GetEffectVocabulary()) > 0 {
+		if name := pDef.GetEftName(rule[1:]); !pDef.HasEft(name) {
+			return fmt.Errorf(str.ERR_UNKNOWN_EFFECT, name, util.Hash(rule))
+		}
+	}
+
+	if e.vocab != nil && e.strictVocab {
+		values := make([]interface{}, len(rule)-1)
+		for i, value := range rule[1:] {
+			values[i] = value
+		}
+		if err := e.vocab.Check(pDef.GetArgs(), values); err != nil {
+			return err
+		}
+	}
+
+	if e.regex != nil {
+		for _, arg := range e.regexArgs[rule[0]] {
+			pattern, err := pDef.GetParameter(rule[1:], arg)
+			if err != nil {
+				continue
+			}
+			if err := e.regex.CheckPattern(pattern); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules validates all rules of the model
+func (e *Enforcer) checkRules() error {
+	if e.regex == nil && (e.vocab == nil || !e.strictVocab) && len(e.model.GetEffectVocabulary()) == 0 && !e.hasReason() {
+		return nil
+	}
+	var err error
+	e.model.RangeRules(func(rule []string) bool {
+		err = e.checkRule(rule)
+		return err == nil
+	})
+	return err
+}
+
+var regexArgReg = regexp.MustCompile(`regexMatch\(\s*[^,()]+,\s*([pg][0-9]*)[._]([A-Za-z0-9_]+)\s*\)`)
+
+// regexArgs returns the policy arguments, which are used as pattern of regexMatch
+func regexArgs(model m.IModel) map[string][]string {
+	args := make(map[string][]string)
+	model.RangeDefs(m.M_SEC, func(_ string, def defs.IDef) bool {
+		for _, match := range regexArgReg.FindAllStringSubmatch(def.(*defs.MatcherDef).Expr(), -1) {
+			args[match[1]] = append(args[match[1]], match[1]+"_"+match[2])
+		}
+		return true
+	})
+	return args
+}
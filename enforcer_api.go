@@ -15,36 +15,181 @@
 package fastac
 
 import (
+	"context"
+	"time"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/logger"
 	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/types"
 	"github.com/oarkflow/fastac/storage"
 )
 
+var (
+	_ api.Enforcer = (*Enforcer)(nil)
+	_ api.Enforcer = (*SyncedEnforcer)(nil)
+	_ IEnforcer    = (*Enforcer)(nil)
+	_ IEnforcer    = (*SyncedEnforcer)(nil)
+)
+
+// IEnforcer is the API of Enforcer and SyncedEnforcer, it consists of smaller interfaces,
+// so integrators can depend on the part they need, e.g. IDecider to decide requests.
 type IEnforcer interface {
+	IConfigurable
+	IRuleManager
+	IDecider
+	IFilterer
+	IQuerier
+	IResourceRoles
+	IDynamicGroups
+	IStamps
+	IImpersonation
+	IPersister
+	IReplicated
+	ICanary
+	IVersioned
+}
+
+// IConfigurable configures the enforcer and gives access to its model and adapter
+type IConfigurable interface {
 	SetOption(option Option) error
 	GetStorageController() *storage.StorageController
 
 	GetModel() model.IModel
+	ViewModel() model.IModel
 	SetModel(m model.IModel)
 
 	GetAdapter() storage.Adapter
 	SetAdapter(storage.Adapter)
 
-	AddRule(rule []string) (bool, error)
-	AddRules(rules [][]string) error
-	RemoveRule(rule []string) (bool, error)
-	RemoveRules(rules [][]string) error
+	GetLogger() logger.Logger
+	Clone() (*Enforcer, error)
+	Fork() *Enforcer
+	RequestKey(rvals ...interface{}) (string, error)
+	GetEnvironment() string
+	InEnvironment(rule []string) bool
+	BeginTx() *Transaction
+}
+
+// IRuleManager adds and removes rules
+type IRuleManager interface {
+	api.RuleManager
 
-	LoadPolicy() error
-	SavePolicy() error
+	AddRuleCtx(ctx context.Context, rule []string) (bool, error)
+	RemoveRuleCtx(ctx context.Context, rule []string) (bool, error)
+	GetRuleByHash(key string) ([]string, bool)
+}
 
-	Enforce(params ...interface{}) (bool, error)
+// IDecider decides requests
+type IDecider interface {
+	api.Decider
+
+	EnforceCtx(ctx context.Context, params ...interface{}) (bool, error)
+	EnforceWithKeys(rKey, pKey, eKey, mKey string, params ...interface{}) (bool, error)
+	EnforceWithEffect(params ...interface{}) (types.Effect, error)
+	BatchEnforce(requests [][]interface{}, options ...ContextOption) ([]bool, error)
 	EnforceWithContext(ctx *Context, rvals ...interface{}) (bool, error)
+	EnforceExWithContext(ctx *Context, rvals ...interface{}) (bool, []string, error)
+	EnforceDecision(params ...interface{}) *Decision
+	EnforceDecisionWithContext(ctx *Context, rvals ...interface{}) *Decision
+	EnforceAnonymous(params ...interface{}) (bool, error)
+	EnforceAs(actor, subject string, params ...interface{}) (bool, Leg, error)
+	EnforceWithMatcher(expr string, rvals ...interface{}) (bool, error)
+}
 
+// IFilterer lists the rules matching a request
+type IFilterer interface {
 	Filter(params ...interface{}) ([][]string, error)
 	FilterWithContext(ctx *Context, rvals ...interface{}) ([][]string, error)
+	FilterSources(params ...interface{}) ([]SourcedRule, error)
 
 	RangeMatches(params []interface{}, fn func(rule []string) bool) error
 	RangeMatchesWithContext(ctx *Context, rvals []interface{}, fn func(rule []string) bool) error
+}
+
+// IQuerier answers questions about the policy beyond single requests
+type IQuerier interface {
+	HasLinks(key string, pairs [][2]string, domain ...string) ([]bool, error)
+	GetAllowedObjects(sub, act, objectPattern string, page ...Page) ([]string, error)
+	PurposesFor(categoryArg, category string) (map[string][][]string, error)
+	ComputeMatrix(subjects, objects, actions []string) (*Matrix, error)
+	Impact(rule []string) (*ImpactReport, error)
+}
+
+// IResourceRoles manages the roles of users on single resources
+type IResourceRoles interface {
+	AssignResourceRole(user, role, resource string) (bool, error)
+	RevokeResourceRole(user, role, resource string) (bool, error)
+	HasResourceRole(user, role, resource string) (bool, error)
+	GetResourceRoles(user, resource string) ([]string, error)
+	GetResourceUsers(role, resource string) ([]string, error)
+}
+
+// IDynamicGroups manages groups, whose members are selected by an expression
+type IDynamicGroups interface {
+	AddDynamicGroup(name, expr string) error
+	RemoveDynamicGroup(name string) bool
+	GetDynamicGroups(sub interface{}) ([]string, error)
+	InvalidateDynamicGroups(subs ...string)
+}
+
+// IStamps records who changed rules and keeps removed rules as tombstones
+type IStamps interface {
+	WithActor(actor string) *StampedMutator
+	GetStamp(rule []string) (Stamp, bool)
+	GetTombstones() []Tombstone
+	RestoreRule(rule []string) (bool, error)
+	PurgeTombstones(olderThan time.Duration) (int, error)
+}
+
+// IImpersonation manages who may act on behalf of whom
+type IImpersonation interface {
+	GrantImpersonation(actor, subject string) (bool, error)
+	RevokeImpersonation(actor, subject string) (bool, error)
+	CanImpersonate(actor, subject string) (bool, error)
+}
+
+// IPersister loads and saves the rules through the adapter
+type IPersister interface {
+	api.PolicyStore
+
+	LoadPolicyCtx(ctx context.Context) error
+	LoadFilteredPolicy(filter storage.Filter) error
+	ReloadPolicy() error
+	ReloadPolicyCtx(ctx context.Context) error
+	IsFiltered() bool
+	SavePolicyCtx(ctx context.Context) error
+	SaveSnapshot() (uint64, error)
+	CompactWAL() error
+	Migrate(ctx context.Context) ([]string, error)
 
 	Flush() error
+	FlushCtx(ctx context.Context) error
+}
+
+// IReplicated is used to keep the enforcer in sync with other nodes
+type IReplicated interface {
+	SetReadOnly(readOnly bool)
+	IsReadOnly() bool
+	ApplyReplicated(removed, added [][]string) error
+	AddModelListener(event emitter.EventType, handler emitter.HandleFunc) *ModelListener
+	RemoveModelListener(l *ModelListener)
+}
+
+// ICanary decides a part of the requests with a candidate enforcer
+type ICanary interface {
+	StartCanary(candidate *Enforcer, percent float64) error
+	SetCanaryPercent(percent float64) error
+	CanaryStats() (CanaryStats, bool)
+	PromoteCanary() error
+	AbortCanary()
+}
+
+// IVersioned tracks the version of the policy for the decision cache
+type IVersioned interface {
+	InvalidateCache()
+	SetPolicyVersion(version string) bool
+	PolicyVersion() string
+	CheckPolicyVersion(ctx context.Context, source VersionSource) (bool, error)
 }
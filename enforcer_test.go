@@ -0,0 +1,271 @@
+package fastac
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/fastac/api"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/util"
+)
+
+// flakyAdapter fails to add rules, while fail is set
+type flakyAdapter struct {
+	fail  bool
+	added [][]string
+}
+
+func (a *flakyAdapter) LoadPolicy(model api.IAddRuleBool) error { return nil }
+func (a *flakyAdapter) SavePolicy(model api.IRangeRules) error  { return nil }
+func (a *flakyAdapter) RemoveRule(rule []string) error          { return nil }
+func (a *flakyAdapter) AddRule(rule []string) error {
+	if a.fail {
+		return errors.New("adapter unavailable")
+	}
+	a.added = append(a.added, rule)
+	return nil
+}
+
+// benchRules returns n policy rules, every tenth rule is a duplicate of its predecessor
+func benchRules(n int) [][]string {
+	rules := make([][]string, n)
+	for i := range rules {
+		j := i
+		if i%10 == 9 {
+			j--
+		}
+		rules[i] = []string{"p", "user" + strconv.Itoa(j), "data" + strconv.Itoa(j%1000), "read"}
+	}
+	return rules
+}
+
+func benchImport(b *testing.B, n int, add func(e *Enforcer, rules [][]string) error) {
+	rules := benchRules(n)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		model := m.NewModel()
+		if err := model.LoadModelFromText(benchModel); err != nil {
+			b.Fatal(err)
+		}
+		e, err := NewEnforcer(model, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if err := add(e, rules); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkImport compares importing rules one by one with AddRule and at once with AddRules
+func BenchmarkImport(b *testing.B) {
+	for _, n := range []int{10000, 100000, 1000000} {
+		b.Run("AddRule/"+strconv.Itoa(n), func(b *testing.B) {
+			benchImport(b, n, func(e *Enforcer, rules [][]string) error {
+				for _, rule := range rules {
+					if _, err := e.AddRule(rule); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+		b.Run("AddRules/"+strconv.Itoa(n), func(b *testing.B) {
+			benchImport(b, n, func(e *Enforcer, rules [][]string) error {
+				return e.AddRules(rules)
+			})
+		})
+	}
+}
+
+func TestAddRuleEffectVocabulary(t *testing.T) {
+	eftModel := strings.Replace(reasonModel, "p = sub, obj, act, eft, reason", "p = sub, obj, act, eft", 1)
+	tests := []struct {
+		model    string
+		accepted [][]string
+		rejected [][]string
+	}{
+		{
+			model: eftModel,
+			accepted: [][]string{
+				{"p", "alice", "data1", "read", "allow"},
+				{"p", "alice", "data2", "read", "audit"},
+			},
+			rejected: [][]string{
+				{"p", "carol", "data1", "read", "grant"},
+			},
+		},
+		{
+			model: reasonModel,
+			accepted: [][]string{
+				{"p", "alice", "data1", "read", "allow", ""},
+				{"p", "alice", "data2", "read", "audit", ""},
+				{"p", "bob", "data1", "write", "deny", "blocked"},
+			},
+			rejected: [][]string{
+				{"p", "carol", "data1", "read", "grant", "blocked"},
+				// the reason column is required
+				{"p", "carol", "data1", "read", "allow"},
+			},
+		},
+	}
+	for _, test := range tests {
+		model := m.NewModel()
+		if err := model.LoadModelFromText(test.model + "\n[effects]\naudit = allow\n"); err != nil {
+			t.Fatal(err)
+		}
+		e, err := NewEnforcer(model, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rule := range test.accepted {
+			if _, err := e.AddRule(rule); err != nil {
+				t.Fatalf("AddRule(%v): %v", rule, err)
+			}
+		}
+		for _, rule := range test.rejected {
+			if _, err := e.AddRule(rule); err == nil {
+				t.Fatalf("AddRule(%v) accepted an invalid rule", rule)
+			}
+		}
+	}
+}
+
+func TestAddRuleStaleFlushError(t *testing.T) {
+	adapter := &flakyAdapter{fail: true}
+	e := testEnforcer(t, adapter, OptionAutosave(true))
+
+	// the failed flush of a change made directly in the model is not returned by any call
+	if _, err := e.GetModel().AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	adapter.fail = false
+	if _, err := e.AddRule([]string{"p", "bob", "data1", "read"}); err != nil {
+		t.Fatalf("AddRule after a stale flush error: %v", err)
+	}
+	if len(adapter.added) != 2 {
+		t.Fatalf("adapter rules: %v, want the queued and the new rule", adapter.added)
+	}
+
+	adapter.fail = true
+	if _, err := e.AddRule([]string{"p", "carol", "data1", "read"}); err == nil {
+		t.Fatal("AddRule with failing flush: nil error")
+	}
+}
+
+func TestSafeRegexEnforce(t *testing.T) {
+	regexModel := strings.Replace(benchModel, "r.obj == p.obj", "regexMatch(r.obj, p.obj)", 1)
+	model := m.NewModel()
+	if err := model.LoadModelFromText(regexModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil, OptionSafeRegex(util.RegexOptions{MaxPatternLength: 32, MaxInputLength: 16}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "alice", "^/data/[0-9]+$", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "alice", "^/" + strings.Repeat("a", 32), "read"}); err == nil {
+		t.Fatal("AddRule with a pattern exceeding the limit: nil error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	always := func(args ...interface{}) (interface{}, error) { return true, nil }
+	long := "/data/" + strings.Repeat("1", 16)
+	tests := []struct {
+		name    string
+		options []interface{}
+		allowed bool
+	}{
+		{"no context", nil, false},
+		{"background context", []interface{}{WithContext(context.Background())}, false},
+		{"cancellable context", []interface{}{WithContext(ctx)}, false},
+		{"other function", []interface{}{SetFunction("now", always)}, false},
+		{"cancellable context and other function", []interface{}{WithContext(ctx), SetFunction("now", always)}, false},
+		// a regexMatch of the request replaces the safe one
+		{"shadowed regexMatch", []interface{}{SetFunction("regexMatch", always)}, true},
+	}
+	for _, test := range tests {
+		if ok, err := e.Enforce(append([]interface{}{"alice", "/data/1", "read"}, test.options...)...); err != nil || !ok {
+			t.Fatalf("Enforce with %s: %v %v, want true", test.name, ok, err)
+		}
+		ok, err := e.Enforce(append([]interface{}{"alice", long, "read"}, test.options...)...)
+		if test.allowed && (err != nil || !ok) {
+			t.Fatalf("Enforce of a long input with %s: %v %v, want true", test.name, ok, err)
+		}
+		if !test.allowed && err == nil {
+			t.Fatalf("Enforce of a long input with %s: %v, want input length error", test.name, ok)
+		}
+	}
+}
+
+func TestPathPrefixIndexEquivalence(t *testing.T) {
+	enforcer := func(matcher string) *Enforcer {
+		model := m.NewModel()
+		if err := model.LoadModelFromText(strings.Replace(benchModel, "g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act", matcher, 1)); err != nil {
+			t.Fatal(err)
+		}
+		e, err := NewEnforcer(model, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	rules := [][]string{
+		{"p", "alice", "/", "read"},
+		{"p", "alice", "/data/", "write"},
+		{"p", "bob", "/data/public", "read"},
+		{"p", "bob", "", "write"},
+		{"p", "carol", "data", "read"},
+		{"p", "carol", "/data/public/", "write"},
+		{"g", "dave", "bob"},
+	}
+	paths := []string{"", "/", "/data", "/data/", "/data/public", "/data/public/x", "/data/publicity", "data", "data/x", "/other"}
+
+	// only a stage, which is a single pathPrefix call, is indexed, as leaf stage and as inner stage
+	for _, order := range [][2]string{
+		{"g(r.sub, p.sub) && r.act == p.act && pathPrefix(r.obj, p.obj)", "g(r.sub, p.sub) && r.act == p.act && pathPrefix(r.obj, p.obj) == true"},
+		{"pathPrefix(r.obj, p.obj) && g(r.sub, p.sub) && r.act == p.act", "pathPrefix(r.obj, p.obj) == true && g(r.sub, p.sub) && r.act == p.act"},
+	} {
+		indexed, scanned := enforcer(order[0]), enforcer(order[1])
+		check := func(state string) {
+			t.Helper()
+			for _, sub := range []string{"alice", "bob", "carol", "dave"} {
+				for _, path := range paths {
+					for _, act := range []string{"read", "write"} {
+						want, err := scanned.Enforce(sub, path, act)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if got, err := indexed.Enforce(sub, path, act); err != nil || got != want {
+							t.Fatalf("Enforce(%s, %q, %s) with %s %s: %v %v, want %v", sub, path, act, order[0], state, got, err, want)
+						}
+					}
+				}
+			}
+		}
+
+		for _, e := range []*Enforcer{indexed, scanned} {
+			if err := e.AddRules(rules); err != nil {
+				t.Fatal(err)
+			}
+		}
+		check("after AddRules")
+		for _, rule := range rules[:4] {
+			for _, e := range []*Enforcer{indexed, scanned} {
+				if _, err := e.RemoveRule(rule); err != nil {
+					t.Fatal(err)
+				}
+			}
+			check("after removing " + strings.Join(rule, ", "))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package fastac
+
+import (
+	"strings"
+
+	"github.com/oarkflow/fastac/api"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+)
+
+// EnvArg is the policy argument, which scopes rules to environments
+const EnvArg = "env"
+
+// Option to set the environment of the enforcer, e.g. dev, staging or prod (default: none)
+// Policies with the argument env scope their rules to environments, so a single policy serves all environments.
+// A rule applies to the environment, if its env is empty, * or lists the environment separated by |.
+// Rules of other environments are skipped by LoadPolicy and rejected by AddRule,
+// rules of other environments, which are loaded already, are removed from the model, but not from the adapter.
+// Like after LoadFilteredPolicy, IsFiltered returns true and SavePolicy fails, since it would remove the rules of other environments.
+//
+//	p = sub, obj, act, env
+//
+//	p, alice, /deploy, write, dev|staging
+//	p, sre, /deploy, write, *
+//
+//	NewEnforcer("model.conf", adapter, OptionEnvironment("prod"))
+func OptionEnvironment(env string) Option {
+	return func(e *Enforcer) error {
+		e.env = env
+		if env == "" {
+			return nil
+		}
+		others := [][]string{}
+		e.model.RangeRules(func(rule []string) bool {
+			if !e.InEnvironment(rule) {
+				others = append(others, rule)
+			}
+			return true
+		})
+		if len(others) == 0 {
+			return nil
+		}
+		if e.sc.Enabled() {
+			e.sc.Disable()
+			defer e.sc.Enable()
+		}
+		for _, rule := range others {
+			if _, err := e.model.RemoveRule(rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// envScoped returns true, if an environment is set and a policy definition has the argument env
+func (e *Enforcer) envScoped() bool {
+	if e.env == "" {
+		return false
+	}
+	scoped := false
+	e.model.RangeDefs(m.P_SEC, func(key string, def defs.IDef) bool {
+		scoped = def.(*defs.PolicyDef).Has(key + "_" + EnvArg)
+		return !scoped
+	})
+	return scoped
+}
+
+// GetEnvironment returns the environment of the enforcer
+func (e *Enforcer) GetEnvironment() string {
+	return e.env
+}
+
+// InEnvironment returns true, if the rule applies to the environment of the enforcer
+func (e *Enforcer) InEnvironment(rule []string) bool {
+	if e.env == "" || len(rule) == 0 {
+		return true
+	}
+	def, ok := e.model.GetDef(m.P_SEC, rule[0])
+	if !ok {
+		return true
+	}
+	env, err := def.(*defs.PolicyDef).GetParameter(rule, rule[0]+"_"+EnvArg)
+	if err != nil || env == "" || env == "*" {
+		return true
+	}
+	for _, name := range strings.Split(env, "|") {
+		if strings.TrimSpace(name) == e.env {
+			return true
+		}
+	}
+	return false
+}
+
+// envLoader loads the rules of the environment of an enforcer into a target and skips the others
+type envLoader struct {
+	e      *Enforcer
+	target api.IAddRuleBool
+}
+
+func (l *envLoader) AddRule(rule []string) (bool, error) {
+	if !l.e.InEnvironment(rule) {
+		return false, nil
+	}
+	return l.target.AddRule(rule)
+}
+
+func (l *envLoader) AddRules(rules [][]string) ([][]string, error) {
+	scoped := make([][]string, 0, len(rules))
+	for _, rule := range rules {
+		if l.e.InEnvironment(rule) {
+			scoped = append(scoped, rule)
+		}
+	}
+	if bulk, ok := l.target.(api.IAddRulesBulk); ok {
+		return bulk.AddRules(scoped)
+	}
+	added := [][]string{}
+	for _, rule := range scoped {
+		ok, err := l.target.AddRule(rule)
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added = append(added, rule)
+		}
+	}
+	return added, nil
+}
+
+// loadTarget returns the target or, if the enforcer has an environment, a loader skipping the rules of other environments
+func (e *Enforcer) loadTarget(target api.IAddRuleBool) api.IAddRuleBool {
+	if e.env == "" {
+		return target
+	}
+	return &envLoader{e, target}
+}
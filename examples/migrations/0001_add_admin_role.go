@@ -0,0 +1,15 @@
+// Package migrations contains the policy migrations of the example, they are registered by importing the package:
+//
+//	import _ "github.com/oarkflow/fastac/examples/migrations"
+//
+//	e.Migrate(ctx)
+package migrations
+
+import "github.com/oarkflow/fastac"
+
+func init() {
+	fastac.RegisterMigration(fastac.RulesMigration("0001_add_admin_role",
+		[]string{"p", "admin", "true", "read"},
+		[]string{"p", "admin", "true", "write"},
+	))
+}
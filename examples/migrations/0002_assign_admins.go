@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/oarkflow/fastac"
+)
+
+func init() {
+	fastac.RegisterMigration(fastac.Migration{
+		ID:          "0002_assign_admins",
+		Description: "assign the admin role to the operators",
+		Up: func(ctx context.Context, e *fastac.Enforcer) error {
+			_, err := e.AddRule([]string{"g", "alice", "admin"})
+			return err
+		},
+	})
+}
@@ -0,0 +1,90 @@
+package fastac
+
+import (
+	"encoding/json"
+)
+
+// DECISION_SCHEMA identifies the JSON format of decisions, which is described by schemas/decision.v1.json.
+// Fields are only added within a version, incompatible changes increase the version.
+const DECISION_SCHEMA = "fastac.decision.v1"
+
+type decisionJSON struct {
+	Schema       string                 `json:"schema"`
+	Request      []interface{}          `json:"request"`
+	RequestArgs  map[string]interface{} `json:"request_args,omitempty"`
+	Allowed      bool                   `json:"allowed"`
+	Effect       string                 `json:"effect"`
+	Matches      []matchJSON            `json:"matches"`
+	Rule         *matchJSON             `json:"rule,omitempty"`
+	Reasons      []string               `json:"reasons,omitempty"`
+	RolePaths    []RolePath             `json:"role_paths,omitempty"`
+	Steps        []TraceStep            `json:"steps,omitempty"`
+	Timings      timingsJSON            `json:"timings"`
+	OverriddenBy string                 `json:"overridden_by,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+type matchJSON struct {
+	PType  string   `json:"ptype"`
+	Rule   []string `json:"rule"`
+	Effect string   `json:"effect,omitempty"`
+	Source string   `json:"source,omitempty"`
+}
+
+type timingsJSON struct {
+	PrepareNs  int64 `json:"prepare_ns"`
+	EvaluateNs int64 `json:"evaluate_ns"`
+	TotalNs    int64 `json:"total_ns"`
+}
+
+// MarshalJSON encodes the decision in the format DECISION_SCHEMA.
+// Steps and role paths are only included, if the request has been explained, see EnableExplain.
+//
+//	d := e.EnforceDecision("alice", "data1", "read", EnableExplain())
+//	data, _ := json.Marshal(d)
+func (d *Decision) MarshalJSON() ([]byte, error) {
+	res := decisionJSON{
+		Schema:       DECISION_SCHEMA,
+		Request:      d.Request,
+		Allowed:      d.Allowed,
+		Effect:       traceEffect(d.Effect),
+		Matches:      make([]matchJSON, 0, len(d.Matches)),
+		Reasons:      d.Reasons,
+		RolePaths:    d.RolePaths,
+		Steps:        d.Trace,
+		OverriddenBy: d.OverriddenBy,
+		Timings: timingsJSON{
+			PrepareNs:  d.Timings.Prepare.Nanoseconds(),
+			EvaluateNs: d.Timings.Evaluate.Nanoseconds(),
+			TotalNs:    d.Timings.Total.Nanoseconds(),
+		},
+	}
+	if res.Request == nil {
+		res.Request = []interface{}{}
+	}
+	if d.rDef != nil {
+		rvals := d.requestValues()
+		res.RequestArgs = make(map[string]interface{}, len(rvals))
+		for i, arg := range d.rDef.GetArgs() {
+			if i < len(rvals) {
+				res.RequestArgs[arg] = rvals[i]
+			}
+		}
+	}
+	for i, rule := range d.Matches {
+		match := matchJSON{PType: rule[0], Rule: rule[1:]}
+		if i < len(d.Effects) {
+			match.Effect = d.Effects[i]
+		}
+		match.Source, _ = d.sourceOf(rule)
+		res.Matches = append(res.Matches, match)
+	}
+	if rule := d.Explain(); rule != nil {
+		res.Rule = &matchJSON{PType: rule[0], Rule: rule[1:]}
+		res.Rule.Source, _ = d.sourceOf(rule)
+	}
+	if d.Err != nil {
+		res.Error = d.Err.Error()
+	}
+	return json.Marshal(res)
+}
@@ -0,0 +1,58 @@
+// Package cases runs test tables and mutation tests against enforcers.
+// Unlike fastactest, it does not depend on the testing package, so tools like the fastac command can use it.
+//
+//	report, err := cases.RunFile(e, "testdata/policy_cases.csv")
+//	if err == nil && !report.Passed() {
+//		fmt.Print(report)
+//	}
+package cases
+
+import (
+	"fmt"
+
+	"github.com/oarkflow/fastac"
+	m "github.com/oarkflow/fastac/model"
+)
+
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Enforcer is the subset of the enforcer API used to run the cases
+type Enforcer interface {
+	Enforce(params ...interface{}) (bool, error)
+}
+
+// NewTestEnforcer creates an in-memory enforcer from the model text and adds the rules
+func NewTestEnforcer(modelText string, rules ...[]string) (*fastac.Enforcer, error) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(modelText); err != nil {
+		return nil, err
+	}
+	e, err := fastac.NewEnforcer(model, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.AddRules(rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Decision enforces the request and returns "allow", "deny" or the error message
+func Decision(e Enforcer, request ...interface{}) string {
+	ok, err := e.Enforce(request...)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if ok {
+		return Allow
+	}
+	return Deny
+}
+
+// FormatRequest formats the values of a request for reports
+func FormatRequest(request []interface{}) string {
+	return fmt.Sprint(request)
+}
@@ -0,0 +1,214 @@
+package cases
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/govaluate"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/types"
+)
+
+const mutantValue = "__mutant__"
+
+// patternFunctions are the built-in matcher functions, which match "*" against any value
+var patternFunctions = []string{"pathMatch", "pathMatch2", "globMatch", "actMatch"}
+
+var policyArgReg = regexp.MustCompile(`^(p[0-9]*)_([A-Za-z0-9_]+)$`)
+
+// Mutant is a modified copy of a rule set
+type Mutant struct {
+	Description string
+	Rules       [][]string
+}
+
+// MutationReport lists the mutants, which are not detected by any test case
+type MutationReport struct {
+	Mutants   int
+	Survivors []Mutant
+}
+
+// Score returns the share of killed mutants between 0 and 1
+func (r *MutationReport) Score() float64 {
+	if r.Mutants == 0 {
+		return 1
+	}
+	return float64(r.Mutants-len(r.Survivors)) / float64(r.Mutants)
+}
+
+func (r *MutationReport) String() string {
+	var sb strings.Builder
+	for _, mutant := range r.Survivors {
+		sb.WriteString("SURVIVED " + mutant.Description + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("%d mutants, %d survived, score %.2f\n", r.Mutants, len(r.Survivors), r.Score()))
+	return sb.String()
+}
+
+// Rules returns all rules of the model sorted by their values
+func Rules(model m.IModel) [][]string {
+	rules := [][]string{}
+	model.RangeRules(func(rule []string) bool {
+		rules = append(rules, rule)
+		return true
+	})
+	sort.Slice(rules, func(i, j int) bool {
+		return strings.Join(rules[i], ",") < strings.Join(rules[j], ",")
+	})
+	return rules
+}
+
+// Mutate creates the mutants of a rule set:
+// every rule is dropped, the effect of policy rules is flipped,
+// every policy value compared by a pattern function is widened to "*" and every pattern is narrowed.
+// Values compared by == are not widened, "*" only matches itself there.
+// Effects are flipped between allow and deny by the names of the effect vocabulary, rules with other effects are not flipped.
+func Mutate(model m.IModel, rules [][]string) []Mutant {
+	flips := effectFlips(model.GetEffectVocabulary())
+	patterns := patternColumns(model)
+	mutants := []Mutant{}
+	for i, rule := range rules {
+		name := strings.Join(rule, ", ")
+		mutants = append(mutants, Mutant{"drop " + name, without(rules, i)})
+
+		def, ok := model.GetDef(m.P_SEC, rule[0])
+		if !ok {
+			continue
+		}
+		pDef := def.(*defs.PolicyDef)
+		args := pDef.GetArgs()
+		for j, arg := range args {
+			if j+1 >= len(rule) {
+				break
+			}
+			value := rule[j+1]
+			switch {
+			case arg == "eft":
+				if flipped, ok := flips[pDef.GetEft(rule)]; ok {
+					mutants = append(mutants, Mutant{fmt.Sprintf("flip %s in %s", arg, name), replace(rules, i, j+1, flipped)})
+				}
+			case value != "*" && patterns[rule[0]+"_"+arg]:
+				mutants = append(mutants, Mutant{fmt.Sprintf("widen %s in %s", arg, name), replace(rules, i, j+1, "*")})
+			}
+			if strings.Contains(value, "*") {
+				narrowed := strings.ReplaceAll(value, "*", mutantValue)
+				mutants = append(mutants, Mutant{fmt.Sprintf("narrow %s in %s", arg, name), replace(rules, i, j+1, narrowed)})
+			}
+		}
+	}
+	return mutants
+}
+
+// patternColumns returns the policy arguments passed to a pattern function by any matcher, e.g. "p_obj"
+func patternColumns(model m.IModel) map[string]bool {
+	columns := map[string]bool{}
+	functions := model.GetFunctions()
+	model.RangeDefs(m.M_SEC, func(key string, def defs.IDef) bool {
+		expr := defs.ArgReg.ReplaceAllString(def.(*defs.MatcherDef).Expr(), "${1}_${3}")
+		parsed, err := govaluate.NewEvaluableExpressionWithFunctions(expr, functions)
+		if err != nil {
+			return true
+		}
+		// depth counts the open parentheses of a pattern function call
+		call, depth := false, 0
+		for _, token := range parsed.Tokens() {
+			switch token.Kind {
+			case govaluate.FUNCTION:
+				name, _ := token.Value2.(string)
+				call = depth == 0 && contains(patternFunctions, name)
+			case govaluate.CLAUSE:
+				if call || depth > 0 {
+					call = false
+					depth++
+				}
+			case govaluate.CLAUSE_CLOSE:
+				if depth > 0 {
+					depth--
+				}
+			case govaluate.VARIABLE:
+				if name, _ := token.Value.(string); depth > 0 && policyArgReg.MatchString(name) {
+					columns[name] = true
+				}
+			}
+		}
+		return true
+	})
+	return columns
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// effectFlips maps allow and deny to the name of the opposite effect,
+// the first name of the vocabulary in alphabetical order or the built-in name, if the vocabulary has none
+func effectFlips(vocabulary defs.EffectVocabulary) map[types.Effect]string {
+	flips := map[types.Effect]string{eft.Allow: "deny", eft.Deny: "allow"}
+	names := make([]string, 0, len(vocabulary))
+	for name := range vocabulary {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		switch vocabulary[name] {
+		case eft.Allow:
+			flips[eft.Deny] = name
+		case eft.Deny:
+			flips[eft.Allow] = name
+		}
+	}
+	return flips
+}
+
+func without(rules [][]string, i int) [][]string {
+	res := make([][]string, 0, len(rules)-1)
+	res = append(res, rules[:i]...)
+	return append(res, rules[i+1:]...)
+}
+
+func replace(rules [][]string, i, column int, value string) [][]string {
+	res := make([][]string, len(rules))
+	copy(res, rules)
+	rule := make([]string, len(rules[i]))
+	copy(rule, rules[i])
+	rule[column] = value
+	res[i] = rule
+	return res
+}
+
+// CheckMutations runs the test cases against every mutant of the rules.
+// A mutant survives, if all cases pass. The cases have to pass for the unmodified rules.
+// Custom functions and role managers are not part of the model text and are not available to the mutants.
+func CheckMutations(modelText string, rules [][]string, cases []Case) (*MutationReport, error) {
+	base, err := NewTestEnforcer(modelText, rules...)
+	if err != nil {
+		return nil, err
+	}
+	if report := RunCases(base, "", cases); !report.Passed() {
+		return nil, errors.New("fastactest: test cases fail without mutations")
+	}
+
+	mutants := Mutate(base.ViewModel(), rules)
+	report := &MutationReport{Mutants: len(mutants)}
+	for _, mutant := range mutants {
+		e, err := NewTestEnforcer(modelText, mutant.Rules...)
+		if err != nil {
+			return nil, err
+		}
+		if RunCases(e, "", cases).Passed() {
+			report.Survivors = append(report.Survivors, mutant)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,131 @@
+package cases
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const mutationModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = %s
+`
+
+var mutationRules = [][]string{
+	{"p", "alice", "/data/*", "read", "allow"},
+	{"p", "bob", "/data/bob", "write", "deny"},
+	{"g", "carol", "alice"},
+}
+
+func mutationModelText(matcher string) string {
+	return strings.Replace(mutationModel, "%s", matcher, 1)
+}
+
+func TestPatternColumns(t *testing.T) {
+	tests := []struct {
+		matcher string
+		want    []string
+	}{
+		{"g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act", []string{}},
+		{"g(r.sub, p.sub) && pathMatch(r.obj, p.obj) && (r.act == p.act)", []string{"p_obj"}},
+		{"globMatch(r.obj, p.obj) || actMatch(r.act, p.act) && r.sub == p.sub", []string{"p_act", "p_obj"}},
+		// parentheses inside the call and after it
+		{"pathMatch((r.obj), p.sub) && (r.act == p.act) && r.obj == p.obj", []string{"p_sub"}},
+		// "*" is not a wildcard of other functions
+		{"regexMatch(r.obj, p.obj) && ipMatch(r.sub, p.sub) && r.act == p.act", []string{}},
+	}
+	for _, test := range tests {
+		e, err := NewTestEnforcer(mutationModelText(test.matcher))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for column := range patternColumns(e.ViewModel()) {
+			got = append(got, column)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("patternColumns of %s: %v, want %v", test.matcher, got, test.want)
+		}
+	}
+}
+
+func TestMutate(t *testing.T) {
+	e, err := NewTestEnforcer(mutationModelText("g(r.sub, p.sub) && pathMatch(r.obj, p.obj) && r.act == p.act"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutants := Mutate(e.ViewModel(), mutationRules)
+	got := []string{}
+	for _, mutant := range mutants {
+		got = append(got, mutant.Description)
+	}
+	want := []string{
+		"drop p, alice, /data/*, read, allow",
+		"widen obj in p, alice, /data/*, read, allow",
+		"narrow obj in p, alice, /data/*, read, allow",
+		"flip eft in p, alice, /data/*, read, allow",
+		"drop p, bob, /data/bob, write, deny",
+		"widen obj in p, bob, /data/bob, write, deny",
+		"flip eft in p, bob, /data/bob, write, deny",
+		"drop g, carol, alice",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Mutate:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	wantRules := [][]string{
+		{"p", "alice", "/data/" + mutantValue, "read", "allow"},
+		{"p", "bob", "/data/bob", "write", "deny"},
+		{"g", "carol", "alice"},
+	}
+	if !reflect.DeepEqual(mutants[2].Rules, wantRules) {
+		t.Fatalf("rules of %s: %v, want %v", mutants[2].Description, mutants[2].Rules, wantRules)
+	}
+	if mutationRules[0][2] != "/data/*" {
+		t.Fatalf("Mutate modified the rules: %v", mutationRules)
+	}
+}
+
+func TestCheckMutations(t *testing.T) {
+	modelText := mutationModelText("g(r.sub, p.sub) && pathMatch(r.obj, p.obj) && r.act == p.act")
+	cases := []Case{
+		{Request: []interface{}{"alice", "/data/x", "read"}, Expected: Allow},
+		{Request: []interface{}{"carol", "/data/x", "read"}, Expected: Allow},
+		{Request: []interface{}{"alice", "/other", "read"}, Expected: Deny},
+	}
+	report, err := CheckMutations(modelText, mutationRules, cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the deny rule of bob is not covered by any case
+	survivors := []string{}
+	for _, mutant := range report.Survivors {
+		survivors = append(survivors, mutant.Description)
+	}
+	want := []string{
+		"drop p, bob, /data/bob, write, deny",
+		"widen obj in p, bob, /data/bob, write, deny",
+		"flip eft in p, bob, /data/bob, write, deny",
+	}
+	if report.Mutants != 8 || !reflect.DeepEqual(survivors, want) {
+		t.Fatalf("CheckMutations: %d mutants, survivors %v, want 8 mutants, survivors %v", report.Mutants, survivors, want)
+	}
+
+	if _, err := CheckMutations(modelText, mutationRules, []Case{{Request: []interface{}{"bob", "/data/bob", "write"}, Expected: Allow}}); err == nil {
+		t.Fatal("CheckMutations with failing cases: nil error")
+	}
+}
@@ -0,0 +1,182 @@
+package cases
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oarkflow/fastac"
+)
+
+// Case is a single request of a test table and the expected decision
+type Case struct {
+	Line     int
+	Name     string
+	Request  []interface{}
+	Expected string
+}
+
+// Failure describes a case, whose decision differs from the expectation.
+// Matches contains the rules matching the request, if the enforcer supports Filter,
+// Rule and Trace contain the rule responsible for the decision and the trace of the evaluation, if it supports EnforceDecision.
+type Failure struct {
+	Case
+	Got     string
+	Matches [][]string
+	Rule    []string
+	Trace   string
+}
+
+// Report is the result of running a test table
+type Report struct {
+	Source   string
+	Cases    int
+	Failures []Failure
+}
+
+// Filterer is implemented by enforcers, which can list the rules matching a request
+type Filterer interface {
+	Filter(params ...interface{}) ([][]string, error)
+}
+
+// Explainer is implemented by enforcers, which can explain their decisions
+type Explainer interface {
+	EnforceDecision(params ...interface{}) *fastac.Decision
+}
+
+// Passed returns true, if no case failed
+func (r *Report) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, f := range r.Failures {
+		name := ""
+		if f.Name != "" {
+			name = " " + f.Name + ":"
+		}
+		sb.WriteString(fmt.Sprintf("FAIL %s:%d:%s request %s: expected %s, got %s\n", r.Source, f.Line, name, FormatRequest(f.Request), f.Expected, f.Got))
+		if len(f.Matches) == 0 {
+			sb.WriteString("\tno matching rules\n")
+		}
+		for _, rule := range f.Matches {
+			sb.WriteString("\tmatched: " + strings.Join(rule, ", ") + "\n")
+		}
+		if f.Rule != nil {
+			sb.WriteString("\tdecided by: " + strings.Join(f.Rule, ", ") + "\n")
+		}
+		for _, line := range strings.Split(strings.TrimRight(f.Trace, "\n"), "\n") {
+			if line != "" {
+				sb.WriteString("\ttrace: " + line + "\n")
+			}
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%s: %d cases, %d failed\n", r.Source, r.Cases, len(r.Failures)))
+	return sb.String()
+}
+
+// LoadCases reads a test table, files with the extension .yaml or .yml are read by LoadYAMLCases.
+// Every row contains the request values followed by the expected decision (allow or deny).
+// Rows are read as CSV, values containing commas are quoted according to RFC 4180.
+// Values starting with '{' are decoded as JSON objects to test attribute based models.
+//
+//	# sub, obj, act, decision
+//	alice, data1, read, allow
+//	alice, {"owner": "bob"}, write, deny
+//	alice, "{""owner"": ""alice"", ""level"": 2}", write, allow
+func LoadCases(path string) ([]Case, error) {
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		return LoadYAMLCases(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.LazyQuotes = true
+
+	cases := []Case{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return cases, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fastactest: %s: %s", path, err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		if len(row) < 2 {
+			return nil, fmt.Errorf("fastactest: %s:%d: expected request values and decision", path, line)
+		}
+		expected := strings.TrimSpace(row[len(row)-1])
+		if expected != Allow && expected != Deny {
+			return nil, fmt.Errorf("fastactest: %s:%d: invalid decision %q", path, line, expected)
+		}
+		request := make([]interface{}, 0, len(row)-1)
+		for _, cell := range row[:len(row)-1] {
+			value, err := parseValue(cell)
+			if err != nil {
+				return nil, fmt.Errorf("fastactest: %s:%d: %s", path, line, err)
+			}
+			request = append(request, value)
+		}
+		cases = append(cases, Case{Line: line, Request: request, Expected: expected})
+	}
+}
+
+// parseValue decodes cells starting with '{' as JSON object, other cells are strings
+func parseValue(cell string) (interface{}, error) {
+	cell = strings.TrimSpace(cell)
+	if !strings.HasPrefix(cell, "{") {
+		return cell, nil
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(cell), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// RunCases enforces every case and collects the failures
+func RunCases(e Enforcer, source string, cases []Case) *Report {
+	report := &Report{Source: source, Cases: len(cases)}
+	for _, c := range cases {
+		got := Decision(e, c.Request...)
+		if got == c.Expected {
+			continue
+		}
+		failure := Failure{Case: c, Got: got}
+		if f, ok := e.(Filterer); ok {
+			failure.Matches, _ = f.Filter(c.Request...)
+		}
+		if x, ok := e.(Explainer); ok {
+			var trace strings.Builder
+			d := x.EnforceDecision(append(c.Request[:len(c.Request):len(c.Request)], fastac.EnableTrace(&trace))...)
+			failure.Rule, failure.Trace = d.Explain(), trace.String()
+		}
+		report.Failures = append(report.Failures, failure)
+	}
+	return report
+}
+
+// RunFile loads the test table at path and runs it
+func RunFile(e Enforcer, path string) (*Report, error) {
+	cases, err := LoadCases(path)
+	if err != nil {
+		return nil, err
+	}
+	return RunCases(e, path, cases), nil
+}
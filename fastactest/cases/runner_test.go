@@ -0,0 +1,71 @@
+package cases
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTable writes a test table to a temporary file with the given name
+func writeTable(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCases(t *testing.T) {
+	table := `# sub, obj, act, decision
+alice, data1, read, allow
+
+
+bob,data2 , write ,deny
+"smith, alice", data1, read, allow
+"say ""hi""", data1, read, deny
+alice, {"owner": "bob"}, write, deny
+alice, "{""owner"": ""alice"", ""level"": 2}", write, allow
+alice, say "hi", read, deny
+# trailing comment
+`
+	want := []Case{
+		{Line: 2, Request: []interface{}{"alice", "data1", "read"}, Expected: Allow},
+		{Line: 5, Request: []interface{}{"bob", "data2", "write"}, Expected: Deny},
+		{Line: 6, Request: []interface{}{"smith, alice", "data1", "read"}, Expected: Allow},
+		{Line: 7, Request: []interface{}{`say "hi"`, "data1", "read"}, Expected: Deny},
+		{Line: 8, Request: []interface{}{"alice", map[string]interface{}{"owner": "bob"}, "write"}, Expected: Deny},
+		{Line: 9, Request: []interface{}{"alice", map[string]interface{}{"owner": "alice", "level": 2.0}, "write"}, Expected: Allow},
+		{Line: 10, Request: []interface{}{"alice", `say "hi"`, "read"}, Expected: Deny},
+	}
+	got, err := LoadCases(writeTable(t, "cases.csv", table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadCases:\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestLoadCasesErrors(t *testing.T) {
+	tests := []struct {
+		table string
+		err   string
+	}{
+		{"alice, data1, read, allow\nalice\n", "cases.csv:2: expected request values and decision"},
+		{"alice, data1, read, allow\n\nalice, data1, read, grant\n", `cases.csv:3: invalid decision "grant"`},
+		{"alice, {\"owner\": bob}, read, allow\n", "cases.csv:1: invalid character"},
+		// a JSON object with several keys has to be quoted, otherwise it is split at the comma
+		{"alice, {\"owner\": \"bob\", \"level\": 2}, read, allow\n", "cases.csv:1: "},
+		// an unterminated quote extends to the end of the file
+		{"alice, \"data1, read, allow\n", `cases.csv:1: invalid decision "data1, read, allow"`},
+	}
+	for _, test := range tests {
+		_, err := LoadCases(writeTable(t, "cases.csv", test.table))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("LoadCases(%q): %v, want %s", test.table, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package cases
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadYAMLCases reads a test table in YAML.
+// The table is a sequence of cases with the request values, the expected decision and an optional name.
+// Request values are strings or mappings, the values of mappings are typed like JSON, e.g. numbers are float64.
+// Only the block and flow styles needed by test tables are supported, anchors, tags and multi-line scalars are not.
+//
+//	# cases.yaml
+//	- name: alice reads her data
+//	  request: [alice, data1, read]
+//	  expect: allow
+//	- request:
+//	    - alice
+//	    - {owner: bob, level: 2}
+//	    - write
+//	  expect: deny
+func LoadYAMLCases(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := yamlLines(string(data))
+	if len(lines) == 0 {
+		return []Case{}, nil
+	}
+	table, next, err := parseBlock(lines, 0, lines[0].indent)
+	if err == nil && next < len(lines) {
+		err = fmt.Errorf("line %d: unexpected indentation", lines[next].no)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fastactest: %s: %s", path, err)
+	}
+	items, ok := table.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("fastactest: %s: expected a sequence of cases", path)
+	}
+
+	cases := make([]Case, 0, len(items))
+	for i, item := range items {
+		c, err := yamlCase(item)
+		if err != nil {
+			return nil, fmt.Errorf("fastactest: %s: case %d: %s", path, i+1, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+func yamlCase(item interface{}) (Case, error) {
+	node, ok := item.(yamlMap)
+	if !ok {
+		return Case{}, fmt.Errorf("expected a mapping")
+	}
+	c := Case{Line: node.line}
+	for key, value := range node.values {
+		switch key {
+		case "name":
+			c.Name = fmt.Sprint(resolve(value, false))
+		case "expect":
+			c.Expected = fmt.Sprint(resolve(value, false))
+		case "request":
+			values, ok := value.([]interface{})
+			if !ok {
+				return Case{}, fmt.Errorf("request has to be a sequence")
+			}
+			for _, v := range values {
+				c.Request = append(c.Request, resolve(v, false))
+			}
+		default:
+			return Case{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if len(c.Request) == 0 {
+		return Case{}, fmt.Errorf("request values missing")
+	}
+	if c.Expected != Allow && c.Expected != Deny {
+		return Case{}, fmt.Errorf("invalid decision %q", c.Expected)
+	}
+	return c, nil
+}
+
+// yamlLine is a line without comment and its indentation
+type yamlLine struct {
+	no     int
+	indent int
+	text   string
+}
+
+// yamlMap is a mapping and the line it starts at
+type yamlMap struct {
+	line   int
+	values map[string]interface{}
+}
+
+// yamlPlain is an unquoted scalar, which is typed by resolve
+type yamlPlain string
+
+func yamlLines(data string) []yamlLine {
+	lines := []yamlLine{}
+	for i, line := range strings.Split(data, "\n") {
+		text := strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{no: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	return lines
+}
+
+// stripComment removes a comment, which starts with '#' at the beginning of the line or after a space outside of quotes
+func stripComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey splits "key: value" into key and value
+func splitKey(text string) (key string, value string, ok bool) {
+	if text == "" || text[0] == '"' || text[0] == '\'' || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if i := strings.Index(text, ": "); i > 0 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true
+	}
+	if strings.HasSuffix(text, ":") {
+		return strings.TrimSpace(text[:len(text)-1]), "", true
+	}
+	return "", "", false
+}
+
+// parseBlock parses the node starting at lines[i] with the given indentation and returns the index of the next line
+func parseBlock(lines []yamlLine, i int, indent int) (interface{}, int, error) {
+	line := lines[i]
+	if isItem(line.text) {
+		return parseSequence(lines, i, indent)
+	}
+	if _, _, ok := splitKey(line.text); ok {
+		return parseMapping(lines, i, indent)
+	}
+	value, err := parseFlow(line.text)
+	if err != nil {
+		return nil, i, fmt.Errorf("line %d: %s", line.no, err)
+	}
+	return value, i + 1, nil
+}
+
+func parseSequence(lines []yamlLine, i int, indent int) (interface{}, int, error) {
+	items := []interface{}{}
+	for i < len(lines) && lines[i].indent == indent && isItem(lines[i].text) {
+		line := lines[i]
+		content := strings.TrimLeft(line.text[1:], " ")
+		if content == "" {
+			// the item is the block on the following, deeper indented lines
+			if i+1 < len(lines) && lines[i+1].indent > indent {
+				item, next, err := parseBlock(lines, i+1, lines[i+1].indent)
+				if err != nil {
+					return nil, i, err
+				}
+				items, i = append(items, item), next
+			} else {
+				items, i = append(items, nil), i+1
+			}
+			continue
+		}
+		// the content after "- " is parsed as a block indented by its column, e.g. the first key of a mapping
+		lines[i] = yamlLine{no: line.no, indent: indent + len(line.text) - len(content), text: content}
+		item, next, err := parseBlock(lines, i, lines[i].indent)
+		if err != nil {
+			return nil, i, err
+		}
+		items, i = append(items, item), next
+	}
+	return items, i, nil
+}
+
+func parseMapping(lines []yamlLine, i int, indent int) (interface{}, int, error) {
+	node := yamlMap{line: lines[i].no, values: make(map[string]interface{})}
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		key, rest, ok := splitKey(line.text)
+		if !ok {
+			return nil, i, fmt.Errorf("line %d: expected a key", line.no)
+		}
+		if _, exists := node.values[key]; exists {
+			return nil, i, fmt.Errorf("line %d: duplicate key %q", line.no, key)
+		}
+		i++
+		switch {
+		case rest != "":
+			value, err := parseFlow(rest)
+			if err != nil {
+				return nil, i, fmt.Errorf("line %d: %s", line.no, err)
+			}
+			node.values[key] = value
+		case i < len(lines) && (lines[i].indent > indent || lines[i].indent == indent && isItem(lines[i].text)):
+			value, next, err := parseBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, i, err
+			}
+			node.values[key], i = value, next
+		default:
+			node.values[key] = nil
+		}
+	}
+	return node, i, nil
+}
+
+// parseFlow parses a scalar or a flow sequence or mapping, which fills the rest of a line
+func parseFlow(text string) (interface{}, error) {
+	p := &flowParser{text: text}
+	value, err := p.value(false)
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.pos < len(p.text) {
+		return nil, fmt.Errorf("unexpected %q", p.text[p.pos:])
+	}
+	return value, nil
+}
+
+type flowParser struct {
+	text string
+	pos  int
+}
+
+func (p *flowParser) space() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *flowParser) value(inFlow bool) (interface{}, error) {
+	p.space()
+	if p.pos == len(p.text) {
+		return yamlPlain(""), nil
+	}
+	switch p.text[p.pos] {
+	case '[':
+		return p.sequence()
+	case '{':
+		return p.mapping()
+	case '"', '\'':
+		return p.quoted()
+	}
+	return yamlPlain(p.plain(inFlow, false)), nil
+}
+
+// plain reads an unquoted scalar, which ends at a flow indicator inside of flow collections or at ": " of a key
+func (p *flowParser) plain(inFlow bool, key bool) string {
+	start := p.pos
+	for ; p.pos < len(p.text); p.pos++ {
+		c := p.text[p.pos]
+		if inFlow && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if key && c == ':' && (p.pos+1 == len(p.text) || strings.IndexByte(" ,]}", p.text[p.pos+1]) >= 0) {
+			break
+		}
+	}
+	return strings.TrimSpace(p.text[start:p.pos])
+}
+
+func (p *flowParser) quoted() (interface{}, error) {
+	quote := p.text[p.pos]
+	for end := p.pos + 1; end < len(p.text); end++ {
+		switch c := p.text[end]; {
+		case c == '\\' && quote == '"':
+			end++
+		case c == quote && quote == '\'' && end+1 < len(p.text) && p.text[end+1] == '\'':
+			end++
+		case c == quote:
+			raw := p.text[p.pos : end+1]
+			p.pos = end + 1
+			if quote == '\'' {
+				return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+			}
+			return strconv.Unquote(raw)
+		}
+	}
+	return nil, fmt.Errorf("unterminated string %s", p.text[p.pos:])
+}
+
+func (p *flowParser) sequence() (interface{}, error) {
+	p.pos++
+	items := []interface{}{}
+	for {
+		p.space()
+		if p.pos == len(p.text) {
+			return nil, fmt.Errorf("unterminated sequence")
+		}
+		if p.text[p.pos] == ']' {
+			p.pos++
+			return items, nil
+		}
+		item, err := p.value(true)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if err := p.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *flowParser) mapping() (interface{}, error) {
+	p.pos++
+	node := yamlMap{values: make(map[string]interface{})}
+	for {
+		p.space()
+		if p.pos == len(p.text) {
+			return nil, fmt.Errorf("unterminated mapping")
+		}
+		if p.text[p.pos] == '}' {
+			p.pos++
+			return node, nil
+		}
+		var key string
+		if c := p.text[p.pos]; c == '"' || c == '\'' {
+			quoted, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = quoted.(string)
+		} else {
+			key = p.plain(true, true)
+		}
+		p.space()
+		if p.pos == len(p.text) || p.text[p.pos] != ':' {
+			return nil, fmt.Errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+		value, err := p.value(true)
+		if err != nil {
+			return nil, err
+		}
+		node.values[key] = value
+		if err := p.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator skips the comma between the entries of a flow collection, the closing bracket is left for the caller
+func (p *flowParser) separator(closing byte) error {
+	p.space()
+	if p.pos < len(p.text) && p.text[p.pos] == ',' {
+		p.pos++
+		return nil
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == closing {
+		return nil
+	}
+	if p.pos == len(p.text) && closing == ']' {
+		return fmt.Errorf("unterminated sequence")
+	}
+	if p.pos == len(p.text) {
+		return fmt.Errorf("unterminated mapping")
+	}
+	return fmt.Errorf("expected ',' or '%c'", closing)
+}
+
+// resolve converts the parsed nodes to request values.
+// Plain scalars are typed like JSON within mappings and sequences and are strings otherwise.
+func resolve(node interface{}, typed bool) interface{} {
+	switch n := node.(type) {
+	case yamlMap:
+		values := make(map[string]interface{}, len(n.values))
+		for key, value := range n.values {
+			values[key] = resolve(value, true)
+		}
+		return values
+	case []interface{}:
+		items := make([]interface{}, len(n))
+		for i, item := range n {
+			items[i] = resolve(item, true)
+		}
+		return items
+	case yamlPlain:
+		if !typed {
+			return string(n)
+		}
+		switch n {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null", "~", "":
+			return nil
+		}
+		if f, err := strconv.ParseFloat(string(n), 64); err == nil {
+			return f
+		}
+		return string(n)
+	}
+	return node
+}
@@ -0,0 +1,163 @@
+package cases
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"# comment", ""},
+		{"expect: allow # comment", "expect: allow "},
+		{"expect: allow\t# comment", "expect: allow\t"},
+		{"request: [a#b]", "request: [a#b]"},
+		{`name: "a # b" # comment`, `name: "a # b" `},
+		{`name: 'a # b' # comment`, `name: 'a # b' `},
+		{`name: "a \" # b"`, `name: "a \" # b"`},
+		{`name: 'it''s # b'`, `name: 'it''s # b'`},
+	}
+	for _, test := range tests {
+		if got := stripComment(test.line); got != test.want {
+			t.Fatalf("stripComment(%q): %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseFlow(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+	}{
+		{"alice", "alice"},
+		{"", nil},
+		{"http://example.com:80/a", "http://example.com:80/a"},
+		{"2", 2.0},
+		{`"2"`, "2"},
+		{`"a\tb é"`, "a\tb é"},
+		{`'it''s'`, "it's"},
+		{`'a\tb'`, `a\tb`},
+		{"[alice, data1, read]", []interface{}{"alice", "data1", "read"}},
+		{"[ alice ,data1,  read ]", []interface{}{"alice", "data1", "read"}},
+		{"[a b, c]", []interface{}{"a b", "c"}},
+		{"[]", []interface{}{}},
+		{"[1, 2.5, true, false, null, ~, \"true\"]", []interface{}{1.0, 2.5, true, false, nil, nil, "true"}},
+		{"{owner: bob, level: 2}", map[string]interface{}{"owner": "bob", "level": 2.0}},
+		{`{"a b": 'it''s', 'c': "x,y"}`, map[string]interface{}{"a b": "it's", "c": "x,y"}},
+		{"{url: http://example.com:80}", map[string]interface{}{"url": "http://example.com:80"}},
+		{"{empty:}", map[string]interface{}{"empty": nil}},
+		{"{}", map[string]interface{}{}},
+		{"[[a, b], {c: [d, {e: 1}]}]", []interface{}{
+			[]interface{}{"a", "b"},
+			map[string]interface{}{"c": []interface{}{"d", map[string]interface{}{"e": 1.0}}},
+		}},
+	}
+	for _, test := range tests {
+		value, err := parseFlow(test.text)
+		if err != nil {
+			t.Fatalf("parseFlow(%q): %v", test.text, err)
+		}
+		if got := resolve(value, true); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("parseFlow(%q): %#v, want %#v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseFlowErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"[a, b", "unterminated sequence"},
+		{"{a: b", "unterminated mapping"},
+		{"{a b}", `expected ':' after key "a b"`},
+		{`"abc`, `unterminated string "abc`},
+		{`'it''s`, `unterminated string 'it''s`},
+		{`"\q"`, "invalid syntax"},
+		{"[a] b", `unexpected "b"`},
+		{"[{a: 1}}", "expected ',' or ']'"},
+		{"{a: [1}", "expected ',' or ']'"},
+		{`["a" b]`, "expected ',' or ']'"},
+	}
+	for _, test := range tests {
+		if _, err := parseFlow(test.text); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("parseFlow(%q): %v, want %s", test.text, err, test.err)
+		}
+	}
+}
+
+func TestLoadYAMLCases(t *testing.T) {
+	table := `---
+# cases.yaml
+- name: alice reads her data # inline comment
+  request: [alice, data1, read]
+  expect: allow
+
+- request:
+    - alice
+    - {owner: bob, level: 2}
+    - write
+  expect: deny
+- request:
+  - "bob # not a comment"
+  - 'data1'
+  - read
+  expect: "deny"
+-
+  name: block item
+  request: [carol, {tags: [a, b], owner: ~}, "2"]
+  expect: allow
+- expect: allow
+  request:
+      - dave
+      - data2
+  name: 'it''s dave'
+`
+	want := []Case{
+		{Line: 3, Name: "alice reads her data", Request: []interface{}{"alice", "data1", "read"}, Expected: Allow},
+		{Line: 7, Request: []interface{}{"alice", map[string]interface{}{"owner": "bob", "level": 2.0}, "write"}, Expected: Deny},
+		{Line: 12, Request: []interface{}{"bob # not a comment", "data1", "read"}, Expected: Deny},
+		{Line: 18, Name: "block item", Request: []interface{}{"carol", map[string]interface{}{"tags": []interface{}{"a", "b"}, "owner": nil}, "2"}, Expected: Allow},
+		{Line: 21, Name: "it's dave", Request: []interface{}{"dave", "data2"}, Expected: Allow},
+	}
+	got, err := LoadCases(writeTable(t, "cases.yaml", table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadYAMLCases:\n%#v\nwant\n%#v", got, want)
+	}
+
+	got, err = LoadCases(writeTable(t, "empty.yml", "# no cases\n---\n"))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("LoadYAMLCases of an empty table: %v %v, want no cases", got, err)
+	}
+}
+
+func TestLoadYAMLCasesErrors(t *testing.T) {
+	tests := []struct {
+		table string
+		err   string
+	}{
+		{"- request: [a]\n  expect: allow\n   name: x\n", "line 3: unexpected indentation"},
+		{"- request: [a]\n  expect: allow\n  [b]\n", "line 3: expected a key"},
+		{"- request: [a, b\n  expect: allow\n", "line 1: unterminated sequence"},
+		{"- name: a\n  request: [a]\n  request: [b]\n  expect: allow\n", `line 3: duplicate key "request"`},
+		{"- request:\n    - {a: 1\n  expect: allow\n", "line 2: unterminated mapping"},
+		{"request: [a]\nexpect: allow\n", "expected a sequence of cases"},
+		{"- [a, b]\n", "case 1: expected a mapping"},
+		{"- request: [a]\n  expect: allow\n- request: a\n  expect: allow\n", "case 2: request has to be a sequence"},
+		{"- expect: allow\n", "case 1: request values missing"},
+		{"- request: [a]\n  expect: maybe\n", `case 1: invalid decision "maybe"`},
+		{"- request: [a]\n  expect: allow\n  when: now\n", `case 1: unknown key "when"`},
+	}
+	for _, test := range tests {
+		_, err := LoadCases(writeTable(t, "cases.yaml", test.table))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("LoadYAMLCases(%q): %v, want %s", test.table, err, test.err)
+		}
+	}
+}
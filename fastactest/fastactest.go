@@ -0,0 +1,85 @@
+// Copyright 2022 The FastAC Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fastactest provides utilities for testing models and policies.
+//
+//	e, err := fastactest.NewTestEnforcer(modelText,
+//		[]string{"p", "alice", "data1", "read"},
+//		[]string{"g", "bob", "alice"},
+//	)
+//	fastactest.AssertAllow(t, e, "bob", "data1", "read")
+//	fastactest.AssertDeny(t, e, "bob", "data1", "write")
+package fastactest
+
+import (
+	"testing"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/fastactest/cases"
+)
+
+const (
+	Allow = cases.Allow
+	Deny  = cases.Deny
+)
+
+// Enforcer is the subset of the enforcer API used by the test helpers
+type Enforcer = cases.Enforcer
+
+// NewTestEnforcer creates an in-memory enforcer from the model text and adds the rules
+func NewTestEnforcer(modelText string, rules ...[]string) (*fastac.Enforcer, error) {
+	return cases.NewTestEnforcer(modelText, rules...)
+}
+
+// Decision enforces the request and returns "allow", "deny" or the error message
+func Decision(e Enforcer, request ...interface{}) string {
+	return cases.Decision(e, request...)
+}
+
+// AssertAllow reports an error, if the request is not allowed
+func AssertAllow(t testing.TB, e Enforcer, request ...interface{}) bool {
+	t.Helper()
+	return assertDecision(t, e, Allow, request)
+}
+
+// AssertDeny reports an error, if the request is not denied
+func AssertDeny(t testing.TB, e Enforcer, request ...interface{}) bool {
+	t.Helper()
+	return assertDecision(t, e, Deny, request)
+}
+
+func assertDecision(t testing.TB, e Enforcer, expected string, request []interface{}) bool {
+	t.Helper()
+	if got := Decision(e, request...); got != expected {
+		t.Errorf("fastactest: request %s: expected %s, got %s", cases.FormatRequest(request), expected, got)
+		return false
+	}
+	return true
+}
+
+// AssertCases runs the test table at path and reports every failed case, see cases.LoadCases
+//
+//	fastactest.AssertCases(t, e, "testdata/policy_cases.csv")
+func AssertCases(t testing.TB, e Enforcer, path string) bool {
+	t.Helper()
+	report, err := cases.RunFile(e, path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !report.Passed() {
+		t.Errorf("%s", report)
+		return false
+	}
+	return true
+}
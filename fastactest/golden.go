@@ -0,0 +1,95 @@
+package fastactest
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/oarkflow/fastac/fastactest/cases"
+)
+
+var update = flag.Bool("fastactest.update", false, "update golden decision tables")
+
+// DecisionTable returns one row per request: the request values followed by the decision
+func DecisionTable(e Enforcer, requests [][]interface{}) [][]string {
+	table := make([][]string, 0, len(requests))
+	for _, request := range requests {
+		row := make([]string, 0, len(request)+1)
+		for _, value := range request {
+			row = append(row, fmt.Sprint(value))
+		}
+		table = append(table, append(row, Decision(e, request...)))
+	}
+	return table
+}
+
+// WriteDecisionTable writes the decision table of the requests to a CSV file
+func WriteDecisionTable(path string, e Enforcer, requests [][]interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// WriteAll flushes the writer and returns its error
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(DecisionTable(e, requests)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadDecisionTable reads a CSV decision table
+func ReadDecisionTable(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	return r.ReadAll()
+}
+
+// AssertGolden compares the decisions of the requests with the golden file at path.
+// Run the tests with -fastactest.update to create or update the golden file.
+//
+//	fastactest.AssertGolden(t, e, "testdata/decisions.csv", [][]interface{}{
+//		{"alice", "data1", "read"},
+//		{"alice", "data1", "write"},
+//	})
+func AssertGolden(t testing.TB, e Enforcer, path string, requests [][]interface{}) bool {
+	t.Helper()
+
+	if *update {
+		if err := WriteDecisionTable(path, e, requests); err != nil {
+			t.Fatalf("fastactest: %s", err)
+		}
+		return true
+	}
+
+	golden, err := ReadDecisionTable(path)
+	if err != nil {
+		t.Fatalf("fastactest: %s", err)
+	}
+	if len(golden) != len(requests) {
+		t.Errorf("fastactest: %s: expected %d decisions, got %d", path, len(golden), len(requests))
+		return false
+	}
+
+	ok := true
+	for i, row := range DecisionTable(e, requests) {
+		expected := golden[i][len(golden[i])-1]
+		got := row[len(row)-1]
+		if expected != got {
+			t.Errorf("fastactest: %s:%d: request %s: expected %s, got %s", path, i+1, cases.FormatRequest(requests[i]), expected, got)
+			ok = false
+		}
+	}
+	return ok
+}
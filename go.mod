@@ -4,6 +4,7 @@ go 1.20
 
 require (
 	github.com/go-ini/ini v1.67.0
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/oarkflow/govaluate v0.0.1
 )
 
@@ -0,0 +1,130 @@
+package fastac
+
+import (
+	"github.com/oarkflow/fastac/model/eft"
+)
+
+// EnforceFunc evaluates a request
+type EnforceFunc func(rvals []interface{}) (bool, error)
+
+// EnforceHook is a middleware around the evaluation of a request, next evaluates the request with the remaining hooks.
+// A hook may rewrite the request values before calling next, override the result of next or decide without calling next.
+type EnforceHook func(ctx *Context, rvals []interface{}, next EnforceFunc) (bool, error)
+
+type enforceHook struct {
+	name string
+	fn   EnforceHook
+}
+
+// Option to add an enforce hook, see AddEnforceHook
+func OptionEnforceHook(name string, fn EnforceHook) Option {
+	return func(e *Enforcer) error {
+		e.AddEnforceHook(name, fn)
+		return nil
+	}
+}
+
+// AddEnforceHook appends a hook, which wraps every evaluation of Enforce, the hook added first is the outermost.
+// An existing hook with the same name is replaced. The hooks wrap the decision hooks and the decision log,
+// if a hook changes the result, the decision is overridden by the hook.
+//
+// Allow break-glass accounts and measure the evaluation:
+//
+//	e.AddEnforceHook("break_glass", func(ctx *Context, rvals []interface{}, next EnforceFunc) (bool, error) {
+//		if rvals[0] == "break-glass" {
+//			return true, nil
+//		}
+//		return next(rvals)
+//	})
+//	e.AddEnforceHook("metrics", func(ctx *Context, rvals []interface{}, next EnforceFunc) (bool, error) {
+//		start := time.Now()
+//		allowed, err := next(rvals)
+//		latency.Observe(time.Since(start).Seconds())
+//		return allowed, err
+//	})
+func (e *Enforcer) AddEnforceHook(name string, fn EnforceHook) {
+	for i, h := range e.enforceHooks {
+		if h.name == name {
+			e.enforceHooks[i].fn = fn
+			return
+		}
+	}
+	e.enforceHooks = append(e.enforceHooks, enforceHook{name, fn})
+}
+
+// RemoveEnforceHook removes an enforce hook
+func (e *Enforcer) RemoveEnforceHook(name string) bool {
+	for i, h := range e.enforceHooks {
+		if h.name == name {
+			e.enforceHooks = append(e.enforceHooks[:i:i], e.enforceHooks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// decide evaluates the request through the canary, the enforce hooks and the shadow enforcer
+func (e *Enforcer) decide(ctx *Context, rvals []interface{}) *Decision {
+	if c := e.canary; c != nil && c.routes(rvals) {
+		return c.decide(e, ctx, rvals)
+	}
+	d := e.hooked(ctx, rvals)
+	if e.shadowing != nil {
+		e.shadowing.compare(ctx, d)
+	}
+	return d
+}
+
+// hooked evaluates the request through the enforce hooks
+func (e *Enforcer) hooked(ctx *Context, rvals []interface{}) *Decision {
+	if len(e.enforceHooks) == 0 {
+		return e.evaluate(ctx, rvals)
+	}
+	var d *Decision
+	overriddenBy := ""
+	allowed, err := e.chain(0, ctx, &d, &overriddenBy)(rvals)
+	if err != nil {
+		allowed = e.failOpen
+	}
+	if d == nil {
+		d = &Decision{Request: rvals, Effect: eft.Deny}
+	}
+	if overriddenBy != "" {
+		d.Allowed, d.Err, d.OverriddenBy = allowed, err, overriddenBy
+		if allowed {
+			d.Effect = eft.Allow
+		} else {
+			d.Effect = eft.Deny
+		}
+	}
+	return d
+}
+
+// chain returns the evaluation through the hooks starting at i,
+// overriddenBy is set to the outermost hook, which has changed the result or decided without calling next
+func (e *Enforcer) chain(i int, ctx *Context, d **Decision, overriddenBy *string) EnforceFunc {
+	if i == len(e.enforceHooks) {
+		return func(rvals []interface{}) (bool, error) {
+			*d = e.evaluate(ctx, rvals)
+			return (*d).Allowed, (*d).Err
+		}
+	}
+	h, next := e.enforceHooks[i], e.chain(i+1, ctx, d, overriddenBy)
+	return func(rvals []interface{}) (bool, error) {
+		called, inner, innerErr := false, false, error(nil)
+		allowed, err := runEnforceHook(h.fn, ctx, rvals, func(rvals []interface{}) (bool, error) {
+			called = true
+			inner, innerErr = next(rvals)
+			return inner, innerErr
+		})
+		if !called || allowed != inner || err != innerErr {
+			*overriddenBy = h.name
+		}
+		return allowed, err
+	}
+}
+
+func runEnforceHook(fn EnforceHook, ctx *Context, rvals []interface{}, next EnforceFunc) (allowed bool, err error) {
+	defer recoverPanic(&err)
+	return fn(ctx, rvals, next)
+}
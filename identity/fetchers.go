@@ -0,0 +1,222 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// TokenSource returns the access token for the API of an identity provider, e.g. from golang.org/x/oauth2
+type TokenSource func(ctx context.Context) (string, error)
+
+// getJSON decodes the JSON response of a GET request authorized by the bearer token
+func getJSON(ctx context.Context, client *http.Client, rawURL, token string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error: GET %s: %s", rawURL, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// OIDCUserInfo fetches the groups claim of the users, whose access tokens are returned by Tokens, from the userinfo endpoint.
+// The userinfo endpoint only describes the user of a token, so it suits providers without directory API,
+// e.g. with the tokens of the recently active users.
+type OIDCUserInfo struct {
+	// URL is the userinfo endpoint
+	URL string
+	// Tokens returns the access tokens of the users
+	Tokens func(ctx context.Context) ([]string, error)
+	// UserClaim is the claim of the user name (default: sub)
+	UserClaim string
+	// GroupsClaim is the claim of the groups (default: groups)
+	GroupsClaim string
+	Client      *http.Client
+}
+
+func (f *OIDCUserInfo) Fetch(ctx context.Context) ([]Membership, error) {
+	userClaim, groupsClaim := f.UserClaim, f.GroupsClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	tokens, err := f.Tokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memberships := []Membership{}
+	for _, token := range tokens {
+		claims := map[string]interface{}{}
+		if err := getJSON(ctx, f.Client, f.URL, token, &claims); err != nil {
+			return nil, err
+		}
+		user, _ := claims[userClaim].(string)
+		groups, _ := claims[groupsClaim].([]interface{})
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				memberships = append(memberships, Membership{User: user, Group: name})
+			}
+		}
+	}
+	return memberships, nil
+}
+
+// AzureAD fetches the members of all groups from Microsoft Graph.
+// The token needs the permission GroupMember.Read.All.
+type AzureAD struct {
+	Token TokenSource
+	// BaseURL is the URL of the Graph API (default: https://graph.microsoft.com/v1.0)
+	BaseURL string
+	// UserProperty is the property of the user name (default: userPrincipalName)
+	UserProperty string
+	// GroupProperty is the property of the group name (default: displayName)
+	GroupProperty string
+	Client        *http.Client
+}
+
+func (f *AzureAD) Fetch(ctx context.Context) ([]Membership, error) {
+	baseURL, userProperty, groupProperty := f.BaseURL, f.UserProperty, f.GroupProperty
+	if baseURL == "" {
+		baseURL = "https://graph.microsoft.com/v1.0"
+	}
+	if userProperty == "" {
+		userProperty = "userPrincipalName"
+	}
+	if groupProperty == "" {
+		groupProperty = "displayName"
+	}
+	token, err := f.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// list follows the @odata.nextLink of the pages
+	list := func(next string, fn func(item map[string]interface{})) error {
+		for next != "" {
+			page := struct {
+				Value    []map[string]interface{} `json:"value"`
+				NextLink string                   `json:"@odata.nextLink"`
+			}{}
+			if err := getJSON(ctx, f.Client, next, token, &page); err != nil {
+				return err
+			}
+			for _, item := range page.Value {
+				fn(item)
+			}
+			next = page.NextLink
+		}
+		return nil
+	}
+
+	memberships := []Membership{}
+	groups := [][2]string{}
+	err = list(baseURL+"/groups?$select=id,"+url.QueryEscape(groupProperty), func(item map[string]interface{}) {
+		id, _ := item["id"].(string)
+		name, _ := item[groupProperty].(string)
+		groups = append(groups, [2]string{id, name})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		// members, which are no users, e.g. devices, lack the user property and are skipped
+		err := list(baseURL+"/groups/"+url.PathEscape(group[0])+"/members?$select=id,"+url.QueryEscape(userProperty), func(item map[string]interface{}) {
+			if user, _ := item[userProperty].(string); user != "" {
+				memberships = append(memberships, Membership{User: user, Group: group[1]})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return memberships, nil
+}
+
+// GoogleWorkspace fetches the members of all groups from the Admin SDK Directory API, users and groups are named by their email.
+// The token needs the scope https://www.googleapis.com/auth/admin.directory.group.readonly.
+type GoogleWorkspace struct {
+	Token TokenSource
+	// Customer is the id of the Workspace account (default: my_customer)
+	Customer string
+	// BaseURL is the URL of the Directory API (default: https://admin.googleapis.com/admin/directory/v1)
+	BaseURL string
+	Client  *http.Client
+}
+
+func (f *GoogleWorkspace) Fetch(ctx context.Context) ([]Membership, error) {
+	baseURL, customer := f.BaseURL, f.Customer
+	if baseURL == "" {
+		baseURL = "https://admin.googleapis.com/admin/directory/v1"
+	}
+	if customer == "" {
+		customer = "my_customer"
+	}
+	token, err := f.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// list follows the nextPageToken of the pages
+	list := func(rawURL string, field string, fn func(item map[string]interface{})) error {
+		pageToken := ""
+		for {
+			next := rawURL
+			if pageToken != "" {
+				next += "&pageToken=" + url.QueryEscape(pageToken)
+			}
+			page := map[string]interface{}{}
+			if err := getJSON(ctx, f.Client, next, token, &page); err != nil {
+				return err
+			}
+			items, _ := page[field].([]interface{})
+			for _, item := range items {
+				if item, ok := item.(map[string]interface{}); ok {
+					fn(item)
+				}
+			}
+			if pageToken, _ = page["nextPageToken"].(string); pageToken == "" {
+				return nil
+			}
+		}
+	}
+
+	memberships := []Membership{}
+	groups := []string{}
+	err = list(baseURL+"/groups?customer="+url.QueryEscape(customer), "groups", func(item map[string]interface{}) {
+		if email, _ := item["email"].(string); email != "" {
+			groups = append(groups, email)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		// nested groups are members of type GROUP and are skipped
+		err := list(baseURL+"/groups/"+url.PathEscape(group)+"/members?includeDerivedMembership=true", "members", func(item map[string]interface{}) {
+			if kind, _ := item["type"].(string); kind != "USER" {
+				return
+			}
+			if email, _ := item["email"].(string); email != "" {
+				memberships = append(memberships, Membership{User: email, Group: group})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return memberships, nil
+}
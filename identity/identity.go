@@ -0,0 +1,304 @@
+// Package identity imports the group memberships of an identity provider into the role links of an enforcer.
+//
+// A Syncer fetches the memberships from a Fetcher, e.g. the OIDC userinfo endpoint, Azure AD or Google Workspace,
+// and applies only the differences to the rules it manages, which are the links to the groups with its prefix.
+// Links to other roles, e.g. added by an administrator, are never changed:
+//
+//	syncer, _ := identity.NewSyncer(e, &identity.AzureAD{Token: token}, identity.Config{Prefix: "azure:"})
+//	report, _ := syncer.Plan(ctx) // dry-run
+//	go syncer.Run(ctx, 5*time.Minute)
+//
+// The imported groups are used like any other role:
+//
+//	p, azure:Payments Admins, /payments/*, write
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/emitter"
+)
+
+// Membership is the membership of a user in a group of the identity provider
+type Membership struct {
+	User  string
+	Group string
+}
+
+// Fetcher fetches all memberships from an identity provider
+type Fetcher interface {
+	Fetch(ctx context.Context) ([]Membership, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface
+type FetcherFunc func(ctx context.Context) ([]Membership, error)
+
+func (fn FetcherFunc) Fetch(ctx context.Context) ([]Membership, error) {
+	return fn(ctx)
+}
+
+// Config configures the rules managed by a Syncer
+type Config struct {
+	// PType is the type of the link rules (default: g)
+	PType string
+	// Prefix is prepended to the group names, the Syncer manages the links to all roles with the prefix.
+	// It is required, so links to roles of other origins are never removed.
+	Prefix string
+	// Domain is appended to the link rules, if it is set, otherwise only links without domain are managed
+	Domain string
+	// MaxChurn aborts a sync, which would change more than this fraction of the managed links,
+	// e.g. when the identity provider returns an incomplete result (default: 0, no limit)
+	MaxChurn float64
+}
+
+// Events of a Syncer
+const (
+	// SYNCED is emitted after every sync, the handler receives the *Report
+	SYNCED = "synced"
+	// FAILED is emitted, when a sync failed, the handler receives the error
+	FAILED = "failed"
+)
+
+// Report describes the changes of a sync
+type Report struct {
+	Time     time.Time
+	Duration time.Duration
+	// DryRun is true, if the changes were only planned
+	DryRun bool
+	Users  int
+	Groups int
+	// Added and Removed are the added and removed link rules
+	Added   [][]string
+	Removed [][]string
+	// Unchanged is the number of managed links, which were kept
+	Unchanged int
+	// Churn is the fraction of the previously managed links, which were added or removed
+	Churn float64
+}
+
+// Stats are the cumulated metrics of a Syncer
+type Stats struct {
+	Syncs    uint64
+	Failures uint64
+	Added    uint64
+	Removed  uint64
+	// LastSync is the time of the last successful sync
+	LastSync  time.Time
+	LastChurn float64
+	LastError error
+}
+
+// ErrNoPrefix is returned by NewSyncer, if Config.Prefix is empty
+var ErrNoPrefix = errors.New("error: the prefix of the managed groups is required")
+
+// ChurnError is returned, if a sync would change more links than Config.MaxChurn allows
+type ChurnError struct {
+	Report *Report
+	Max    float64
+}
+
+func (err *ChurnError) Error() string {
+	return fmt.Sprintf("error: membership churn %.2f exceeds the maximum %.2f", err.Report.Churn, err.Max)
+}
+
+// Syncer imports the memberships of an identity provider into the link rules of an enforcer
+type Syncer struct {
+	*emitter.Emitter
+
+	e       fastac.IEnforcer
+	fetcher Fetcher
+	config  Config
+
+	// sync serializes syncs, mutex guards the stats
+	sync  sync.Mutex
+	mutex sync.Mutex
+	stats Stats
+}
+
+// NewSyncer creates a Syncer, which imports the memberships of fetcher into e
+func NewSyncer(e fastac.IEnforcer, fetcher Fetcher, config Config) (*Syncer, error) {
+	if config.Prefix == "" {
+		return nil, ErrNoPrefix
+	}
+	if config.PType == "" {
+		config.PType = "g"
+	}
+	return &Syncer{
+		Emitter: emitter.NewEmitter(false),
+		e:       e,
+		fetcher: fetcher,
+		config:  config,
+	}, nil
+}
+
+// Run syncs immediately and then every interval until ctx is done.
+// Failed syncs are logged and retried in the next interval.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			s.e.GetLogger().Warn("identity sync failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the memberships and applies the differences to the managed links
+func (s *Syncer) Sync(ctx context.Context) (*Report, error) {
+	return s.run(ctx, false)
+}
+
+// Plan fetches the memberships and returns the differences to the managed links without applying them
+func (s *Syncer) Plan(ctx context.Context) (*Report, error) {
+	return s.run(ctx, true)
+}
+
+// Stats returns the cumulated metrics
+func (s *Syncer) Stats() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+func (s *Syncer) run(ctx context.Context, dryRun bool) (*Report, error) {
+	s.sync.Lock()
+	defer s.sync.Unlock()
+	report, err := s.plan(ctx, dryRun)
+	if err == nil && !dryRun {
+		err = s.apply(report)
+	}
+	if dryRun {
+		return report, err
+	}
+
+	s.mutex.Lock()
+	s.stats.Syncs++
+	if err != nil {
+		s.stats.Failures++
+		s.stats.LastError = err
+	} else {
+		s.stats.Added += uint64(len(report.Added))
+		s.stats.Removed += uint64(len(report.Removed))
+		s.stats.LastSync = report.Time
+		s.stats.LastChurn = report.Churn
+		s.stats.LastError = nil
+	}
+	s.mutex.Unlock()
+
+	if err != nil {
+		s.EmitEvent(FAILED, err)
+		return report, err
+	}
+	s.e.GetLogger().Info("identity sync finished", "added", len(report.Added), "removed", len(report.Removed), "churn", report.Churn)
+	s.EmitEvent(SYNCED, report)
+	return report, nil
+}
+
+// plan computes the differences between the fetched memberships and the managed links
+func (s *Syncer) plan(ctx context.Context, dryRun bool) (*Report, error) {
+	report := &Report{Time: time.Now(), DryRun: dryRun}
+	defer func() {
+		report.Duration = time.Since(report.Time)
+	}()
+	memberships, err := s.fetcher.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, groups := map[string]struct{}{}, map[string]struct{}{}
+	wanted := map[string][]string{}
+	for _, ms := range memberships {
+		if ms.User == "" || ms.Group == "" {
+			continue
+		}
+		users[ms.User], groups[ms.Group] = struct{}{}, struct{}{}
+		rule := s.rule(ms)
+		wanted[strings.Join(rule, ",")] = rule
+	}
+	report.Users, report.Groups = len(users), len(groups)
+
+	current := 0
+	s.e.ViewModel().RangeRules(func(rule []string) bool {
+		if !s.manages(rule) {
+			return true
+		}
+		current++
+		key := strings.Join(rule, ",")
+		if _, ok := wanted[key]; ok {
+			delete(wanted, key)
+			report.Unchanged++
+		} else {
+			report.Removed = append(report.Removed, rule)
+		}
+		return true
+	})
+	keys := make([]string, 0, len(wanted))
+	for key := range wanted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report.Added = append(report.Added, wanted[key])
+	}
+
+	changes := len(report.Added) + len(report.Removed)
+	if current > 0 {
+		report.Churn = float64(changes) / float64(current)
+	} else if changes > 0 {
+		report.Churn = 1
+	}
+	// the first import of an empty enforcer is not limited
+	if s.config.MaxChurn > 0 && current > 0 && report.Churn > s.config.MaxChurn {
+		return report, &ChurnError{Report: report, Max: s.config.MaxChurn}
+	}
+	return report, nil
+}
+
+// apply changes the links in a transaction, so requests never see a user without the groups, which are replaced
+func (s *Syncer) apply(report *Report) error {
+	if len(report.Added) == 0 && len(report.Removed) == 0 {
+		return nil
+	}
+	tx := s.e.BeginTx()
+	if err := tx.RemoveRules(report.Removed); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.AddRules(report.Added); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// rule returns the link rule of a membership
+func (s *Syncer) rule(ms Membership) []string {
+	rule := []string{s.config.PType, ms.User, s.config.Prefix + ms.Group}
+	if s.config.Domain != "" {
+		rule = append(rule, s.config.Domain)
+	}
+	return rule
+}
+
+// manages returns true, if the rule is a link managed by the Syncer
+func (s *Syncer) manages(rule []string) bool {
+	if len(rule) < 3 || rule[0] != s.config.PType || !strings.HasPrefix(rule[2], s.config.Prefix) {
+		return false
+	}
+	if s.config.Domain == "" {
+		return len(rule) == 3
+	}
+	return len(rule) == 4 && rule[3] == s.config.Domain
+}
@@ -0,0 +1,283 @@
+package fastac
+
+import (
+	"fmt"
+	"sort"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// DecisionChange is a request, whose decision changes
+type DecisionChange struct {
+	Subject string `json:"subject"`
+	Object  string `json:"object"`
+	Action  string `json:"action"`
+	Before  bool   `json:"before"`
+	After   bool   `json:"after"`
+}
+
+// ImpactReport describes the decisions, which change, if a rule is added or removed
+type ImpactReport struct {
+	Rule []string `json:"rule"`
+	// Removal is true, if the rule is present and the report describes its removal, otherwise its addition
+	Removal bool `json:"removal"`
+	// Subjects and Objects are the sorted subjects and objects of the changes
+	Subjects []string         `json:"subjects"`
+	Objects  []string         `json:"objects"`
+	Changes  []DecisionChange `json:"changes"`
+	// Checked is the number of requests, which have been decided before and after the change
+	Checked int `json:"checked"`
+}
+
+// Impact reports the decisions, which would change, if rule were added, or removed if it is present.
+// The candidate subjects are the subject or user of the rule and all users linked to them by the role definitions,
+// the candidate objects and actions are taken from the affected policy rules, patterns are expanded to the concrete
+// values of all policy rules and the vocabulary. The candidates are decided with ComputeMatrix before and after the change,
+// so the request definition "r" must consist of sub, obj and act. The policy is not modified.
+// Role functions are recreated for the changed policy, so role managers and wrappers set after loading the model are not applied.
+//
+//	report, _ := e.Impact([]string{"g", "alice", "admin"})
+//	for _, change := range report.Changes {
+//		fmt.Println(change.Subject, change.Object, change.Action, change.Before, "->", change.After)
+//	}
+func (e *Enforcer) Impact(rule []string) (*ImpactReport, error) {
+	if len(rule) < 2 {
+		return nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, rule)
+	}
+	report := &ImpactReport{Rule: rule, Removal: e.HasRule(rule), Subjects: []string{}, Objects: []string{}, Changes: []DecisionChange{}}
+	shadow, err := e.shadow(rule, report.Removal)
+	if err != nil {
+		return nil, err
+	}
+	subjects, objects, actions, err := e.impactCandidates(shadow, rule)
+	if err != nil {
+		return nil, err
+	}
+	before, err := e.ComputeMatrix(subjects, objects, actions)
+	if err != nil {
+		return nil, err
+	}
+	after, err := shadow.ComputeMatrix(subjects, objects, actions)
+	if err != nil {
+		return nil, err
+	}
+
+	changedSubjects, changedObjects := make(map[string]struct{}), make(map[string]struct{})
+	for s, sub := range subjects {
+		for o, obj := range objects {
+			for a, act := range actions {
+				if before.At(s, o, a) == after.At(s, o, a) {
+					continue
+				}
+				report.Changes = append(report.Changes, DecisionChange{sub, obj, act, before.At(s, o, a), after.At(s, o, a)})
+				changedSubjects[sub], changedObjects[obj] = struct{}{}, struct{}{}
+			}
+		}
+	}
+	report.Checked = len(subjects) * len(objects) * len(actions)
+	report.Subjects, report.Objects = sortedKeys(changedSubjects), sortedKeys(changedObjects)
+	return report, nil
+}
+
+// shadow returns an enforcer with a copy of the model, to which the change is applied
+func (e *Enforcer) shadow(rule []string, removal bool) (*Enforcer, error) {
+	model, err := e.copyModel()
+	if err != nil {
+		return nil, err
+	}
+	if removal {
+		_, err = model.RemoveRule(rule)
+	} else {
+		_, err = model.AddRule(rule)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Enforcer{model: model, limits: e.limits, preprocessors: e.preprocessors, vocab: e.vocab, strictVocab: e.strictVocab, dynamic: newDynamicGroups()}, nil
+}
+
+// impactCandidates returns the subjects, objects and actions of the requests, whose decisions the change of rule may affect
+func (e *Enforcer) impactCandidates(shadow *Enforcer, rule []string) (subjects, objects, actions []string, err error) {
+	models := []m.IModel{e.model, shadow.model}
+	var roots []string
+	var domain []string
+	affected := [][]string{}
+
+	switch rule[0][0] {
+	case 'p':
+		def, ok := e.model.GetDef(m.P_SEC, rule[0])
+		if !ok {
+			return nil, nil, nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, rule[0])
+		}
+		sub, _, _, err := policyColumns(def.(*defs.PolicyDef))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		roots = []string{rule[sub]}
+		affected = append(affected, rule)
+	case 'g':
+		if len(rule) < 3 {
+			return nil, nil, nil, fmt.Errorf(str.ERR_RM_NOT_FOUND, rule)
+		}
+		roots, domain = []string{rule[1]}, rule[3:]
+		roles := make(map[string]struct{})
+		for _, role := range linkedNames(models, []string{rule[2]}, false, domain) {
+			roles[role] = struct{}{}
+		}
+		e.model.RangeDefs(m.P_SEC, func(key string, def defs.IDef) bool {
+			sub, _, _, colErr := policyColumns(def.(*defs.PolicyDef))
+			if colErr != nil {
+				return true
+			}
+			policy, _ := e.model.GetPolicy(key)
+			policy.Range(func(p []string) bool {
+				if _, ok := roles[p[sub-1]]; ok {
+					affected = append(affected, append([]string{key}, p...))
+				}
+				return true
+			})
+			return true
+		})
+	default:
+		return nil, nil, nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, rule[0])
+	}
+
+	subjects = linkedNames(models, roots, true, domain)
+	objects, actions, err = e.impactValues(affected)
+	return subjects, objects, actions, err
+}
+
+// impactValues returns the objects and actions of the affected rules, patterns are replaced by all concrete values
+func (e *Enforcer) impactValues(affected [][]string) (objects, actions []string, err error) {
+	matchers := []util.IMatcher{util.WildcardMatcher, util.PathMatcher, util.RegexMatcher}
+	if e.vocab != nil {
+		matchers = e.vocab.Matchers()
+	}
+	objectSet, actionSet := make(map[string]struct{}), make(map[string]struct{})
+	objectPattern, actionPattern := false, false
+	for _, rule := range affected {
+		def, _ := e.model.GetDef(m.P_SEC, rule[0])
+		_, obj, act, err := policyColumns(def.(*defs.PolicyDef))
+		if err != nil {
+			return nil, nil, err
+		}
+		if isPattern(rule[obj], matchers) {
+			objectPattern = true
+		} else {
+			objectSet[rule[obj]] = struct{}{}
+		}
+		if isPattern(rule[act], matchers) {
+			actionPattern = true
+		} else {
+			actionSet[rule[act]] = struct{}{}
+		}
+	}
+	if !objectPattern && !actionPattern {
+		return sortedKeys(objectSet), sortedKeys(actionSet), nil
+	}
+
+	// a pattern may match every concrete value of the policies and the vocabulary
+	e.model.RangeDefs(m.P_SEC, func(key string, def defs.IDef) bool {
+		_, obj, act, colErr := policyColumns(def.(*defs.PolicyDef))
+		if colErr != nil {
+			return true
+		}
+		policy, _ := e.model.GetPolicy(key)
+		policy.Range(func(p []string) bool {
+			if objectPattern && !isPattern(p[obj-1], matchers) {
+				objectSet[p[obj-1]] = struct{}{}
+			}
+			if actionPattern && !isPattern(p[act-1], matchers) {
+				actionSet[p[act-1]] = struct{}{}
+			}
+			return true
+		})
+		return true
+	})
+	if e.vocab != nil {
+		for _, value := range e.vocab.Values("obj") {
+			if objectPattern && !isPattern(value, matchers) {
+				objectSet[value] = struct{}{}
+			}
+		}
+		for _, value := range e.vocab.Values("act") {
+			if actionPattern && !isPattern(value, matchers) {
+				actionSet[value] = struct{}{}
+			}
+		}
+	}
+	return sortedKeys(objectSet), sortedKeys(actionSet), nil
+}
+
+// policyColumns returns the indices of sub, obj and act in the rules of a policy including the key
+func policyColumns(pDef *defs.PolicyDef) (sub, obj, act int, err error) {
+	for i, arg := range pDef.GetArgs() {
+		switch arg {
+		case "sub":
+			sub = i + 1
+		case "obj":
+			obj = i + 1
+		case "act":
+			act = i + 1
+		}
+	}
+	if sub == 0 || obj == 0 || act == 0 {
+		return 0, 0, 0, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, pDef.GetKey()+".sub, obj, act")
+	}
+	return sub, obj, act, nil
+}
+
+// linkedNames returns the sorted names and all names transitively linked to them by the role definitions of the models,
+// the users of the names, if users is true, otherwise their roles
+func linkedNames(models []m.IModel, names []string, users bool, domain []string) []string {
+	seen := make(map[string]struct{})
+	queue := []string{}
+	for _, name := range names {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			queue = append(queue, name)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, model := range models {
+			model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+				rm, ok := model.GetRoleManager(key)
+				if !ok {
+					return true
+				}
+				var linked []string
+				var err error
+				if users {
+					linked, err = rm.GetUsers(name, domain...)
+				} else {
+					linked, err = rm.GetRoles(name, domain...)
+				}
+				if err != nil {
+					return true
+				}
+				for _, next := range linked {
+					if _, ok := seen[next]; !ok {
+						seen[next] = struct{}{}
+						queue = append(queue, next)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return sortedKeys(seen)
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package fastac
+
+import (
+	"fmt"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// SERVICE_ACCOUNT_PREFIX is the prefix of the subjects of service accounts
+const SERVICE_ACCOUNT_PREFIX = "system:serviceaccount:"
+
+// Leg is a check of an impersonated request
+type Leg string
+
+const (
+	// LEG_IMPERSONATE checks, that the actor may impersonate the subject
+	LEG_IMPERSONATE Leg = "impersonate"
+	// LEG_ENFORCE checks, that the subject may perform the request
+	LEG_ENFORCE Leg = "enforce"
+)
+
+// ServiceAccount returns the subject of a service account, e.g. "system:serviceaccount:ci:deployer"
+func ServiceAccount(namespace, name string) string {
+	return SERVICE_ACCOUNT_PREFIX + namespace + ":" + name
+}
+
+// Option to declare the impersonation policy definition m.IMPERSONATION, if the model does not declare it
+func OptionImpersonation() Option {
+	return func(e *Enforcer) error {
+		if _, ok := e.model.GetDef(m.P_SEC, m.IMPERSONATION); ok {
+			return nil
+		}
+		return e.model.SetDef(m.P_SEC, m.IMPERSONATION, "actor, subject")
+	}
+}
+
+// GrantImpersonation allows actor to impersonate the subjects matching the glob pattern subject
+//
+//	e.GrantImpersonation("ci-deployer", fastac.ServiceAccount("ci", "*"))
+func (e *Enforcer) GrantImpersonation(actor, subject string) (bool, error) {
+	return e.AddRule([]string{m.IMPERSONATION, actor, subject})
+}
+
+// RevokeImpersonation removes an impersonation grant
+func (e *Enforcer) RevokeImpersonation(actor, subject string) (bool, error) {
+	return e.RemoveRule([]string{m.IMPERSONATION, actor, subject})
+}
+
+// CanImpersonate returns true, if actor or one of its roles of g has an impersonation grant matching subject
+func (e *Enforcer) CanImpersonate(actor, subject string) (bool, error) {
+	p, ok := e.model.GetPolicy(m.IMPERSONATION)
+	if !ok {
+		return false, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, m.IMPERSONATION)
+	}
+	actors, err := e.actorRoles(actor)
+	if err != nil {
+		return false, err
+	}
+
+	granted := false
+	p.Range(func(rule []string) bool {
+		if _, ok := actors[rule[0]]; !ok {
+			return true
+		}
+		if rule[1] == subject {
+			granted = true
+		} else {
+			granted, err = util.GlobMatch(subject, rule[1])
+		}
+		return !granted && err == nil
+	})
+	return granted, err
+}
+
+// actorRoles returns the actor and all roles it inherits by g
+func (e *Enforcer) actorRoles(actor string) (map[string]struct{}, error) {
+	res := map[string]struct{}{actor: {}}
+	rm, ok := e.model.GetRoleManager("g")
+	if !ok {
+		return res, nil
+	}
+	queue := []string{actor}
+	for len(queue) > 0 {
+		roles, err := rm.GetRoles(queue[0])
+		if err != nil {
+			return nil, err
+		}
+		queue = queue[1:]
+		for _, role := range roles {
+			if _, ok := res[role]; !ok {
+				res[role] = struct{}{}
+				queue = append(queue, role)
+			}
+		}
+	}
+	return res, nil
+}
+
+// EnforceAs decides a request of actor impersonating subject, params are the request values following the subject.
+// It is allowed, if actor may impersonate subject and subject may perform the request.
+// If it is denied, failed is the leg, which has denied it.
+//
+//	allowed, failed, err := e.EnforceAs("alice", fastac.ServiceAccount("ci", "deployer"), "deployments", "create")
+func (e *Enforcer) EnforceAs(actor, subject string, params ...interface{}) (allowed bool, failed Leg, err error) {
+	if allowed, err = e.CanImpersonate(actor, subject); err != nil || !allowed {
+		return false, LEG_IMPERSONATE, err
+	}
+	if allowed, err = e.Enforce(append([]interface{}{subject}, params...)...); err != nil || !allowed {
+		return false, LEG_ENFORCE, err
+	}
+	return true, "", nil
+}
@@ -0,0 +1,69 @@
+package fastac
+
+import (
+	"sync"
+
+	"github.com/oarkflow/fastac/util"
+)
+
+// ObjectLabels holds the label sets of objects for policies, which select objects by labels.
+// The matcher function labelMatch(r.obj, p.obj) matches the labels of the request object with a label selector in the Kubernetes syntax,
+// the rules of a labelMatch stage are indexed by their labels, so only the rules requiring a label of the object are evaluated.
+// Selectors with several requirements contain commas and must be quoted in CSV files.
+//
+//	m = r.sub == p.sub && labelMatch(r.obj, p.obj) && r.act == p.act
+//
+//	p, dev-team, "labels(env=staging,team=payments)", deploy
+//	p, sre, "labels(env in (staging, prod),!legacy)", deploy
+//
+//	labels := NewObjectLabels()
+//	labels.Set("checkout", util.Labels{"env": "staging", "team": "payments"})
+//	e.AddPreprocessor("labels", labels.Resolve("obj"))
+//	e.Enforce("dev-team", "checkout", "deploy") // true
+type ObjectLabels struct {
+	mutex   sync.RWMutex
+	objects map[string]util.Labels
+}
+
+func NewObjectLabels() *ObjectLabels {
+	return &ObjectLabels{objects: make(map[string]util.Labels)}
+}
+
+// Set replaces the labels of an object
+func (ol *ObjectLabels) Set(object string, labels util.Labels) {
+	ol.mutex.Lock()
+	defer ol.mutex.Unlock()
+	ol.objects[object] = labels
+}
+
+// Remove removes the labels of an object
+func (ol *ObjectLabels) Remove(object string) bool {
+	ol.mutex.Lock()
+	defer ol.mutex.Unlock()
+	_, ok := ol.objects[object]
+	delete(ol.objects, object)
+	return ok
+}
+
+// Get returns the labels of an object
+func (ol *ObjectLabels) Get(object string) (util.Labels, bool) {
+	ol.mutex.RLock()
+	defer ol.mutex.RUnlock()
+	labels, ok := ol.objects[object]
+	return labels, ok
+}
+
+// Resolve returns a preprocessor, which replaces the name of an object in the request argument arg with its labels.
+// Objects without labels get an empty label set, values, which are not strings, are passed unchanged.
+func (ol *ObjectLabels) Resolve(arg string) PreprocessFunc {
+	return EnrichArg(arg, func(value interface{}) (interface{}, error) {
+		object, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		if labels, ok := ol.Get(object); ok {
+			return labels, nil
+		}
+		return util.Labels{}, nil
+	})
+}
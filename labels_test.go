@@ -0,0 +1,93 @@
+package fastac
+
+import (
+	"strings"
+	"testing"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/util"
+)
+
+// labelEnforcer returns an enforcer, which matches the objects with the stage labelMatch
+func labelEnforcer(t *testing.T, labelMatch string) *Enforcer {
+	t.Helper()
+	model := m.NewModel()
+	if err := model.LoadModelFromText(strings.Replace(benchModel, "r.obj == p.obj", labelMatch, 1)); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestLabelIndexEquivalence(t *testing.T) {
+	// only a stage, which is a single labelMatch call, is indexed
+	indexed := labelEnforcer(t, "labelMatch(r.obj, p.obj)")
+	scanned := labelEnforcer(t, "labelMatch(r.obj, p.obj) == true")
+
+	rules := [][]string{
+		{"p", "alice", "labels(env=staging)", "read"},
+		{"p", "alice", "labels(env!=staging)", "write"},
+		{"p", "alice", "labels(env in (staging, prod),!legacy)", "deploy"},
+		{"p", "bob", "labels(env notin (prod))", "read"},
+		{"p", "bob", "labels(team)", "write"},
+		{"p", "bob", "labels(!team)", "deploy"},
+		{"p", "carol", "labels(team=payments,env==prod)", "read"},
+		{"p", "carol", "labels()", "write"},
+		{"p", "carol", "labels(team in (payments, billing),legacy)", "deploy"},
+		{"g", "dave", "alice"},
+	}
+	objects := []util.Labels{
+		{},
+		{"env": "staging"},
+		{"env": "prod", "team": "payments"},
+		{"env": "staging", "team": "billing", "legacy": "true"},
+		{"env": "dev", "team": "payments"},
+		{"legacy": ""},
+	}
+	check := func(state string) {
+		t.Helper()
+		for _, sub := range []string{"alice", "bob", "carol", "dave"} {
+			for _, obj := range objects {
+				for _, act := range []string{"read", "write", "deploy"} {
+					want, err := scanned.Enforce(sub, obj, act)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got, err := indexed.Enforce(sub, obj, act); err != nil || got != want {
+						t.Fatalf("Enforce(%s, %v, %s) %s: %v %v, want %v", sub, obj, act, state, got, err, want)
+					}
+				}
+			}
+		}
+	}
+
+	for _, e := range []*Enforcer{indexed, scanned} {
+		if err := e.AddRules(rules); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("after AddRules")
+
+	for _, rule := range rules[:6] {
+		for _, e := range []*Enforcer{indexed, scanned} {
+			if _, err := e.RemoveRule(rule); err != nil {
+				t.Fatal(err)
+			}
+		}
+		check("after removing " + strings.Join(rule, ", "))
+	}
+
+	// a rule added again after its removal is indexed again
+	for _, e := range []*Enforcer{indexed, scanned} {
+		if _, err := e.AddRule(rules[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("after adding a removed rule again")
+	if ok, _ := indexed.Enforce("dave", util.Labels{"env": "staging"}, "read"); !ok {
+		t.Fatal("Enforce after adding a removed rule again: false, want true")
+	}
+}
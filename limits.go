@@ -0,0 +1,171 @@
+package fastac
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/rbac"
+	"github.com/oarkflow/fastac/str"
+)
+
+// RequestLimits restricts the size of request values, zero values disable a limit
+type RequestLimits struct {
+	// MaxValues is the maximum number of request values
+	MaxValues int
+	// MaxStringLength is the maximum length of a string, including strings nested in attributes
+	MaxStringLength int
+	// MaxDepth is the maximum nesting depth of maps, slices and structs
+	MaxDepth int
+}
+
+// LimitError is returned, if a request exceeds the RequestLimits
+type LimitError struct {
+	Limit string
+	Size  int
+	Max   int
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("error: request exceeds %s (%d > %d)", err.Limit, err.Size, err.Max)
+}
+
+// Option to set limits for request values (default: unlimited)
+// Oversized requests are rejected with a *LimitError before the matcher is evaluated
+//
+//	NewEnforcer(model, adapter, OptionRequestLimits(RequestLimits{MaxValues: 5, MaxStringLength: 1024, MaxDepth: 4}))
+func OptionRequestLimits(limits RequestLimits) Option {
+	return func(e *Enforcer) error {
+		e.limits = limits
+		return nil
+	}
+}
+
+// Option to bound the role graph traversal of g functions (default: unlimited)
+// A role check exceeding the budget fails the enforcement with a *rbac.TraversalError instead of blocking it
+//
+//	NewEnforcer(model, adapter, OptionTraversalBudget(rbac.TraversalBudget{MaxVisited: 10000, Timeout: 50 * time.Millisecond}))
+func OptionTraversalBudget(budget rbac.TraversalBudget) Option {
+	return func(e *Enforcer) error {
+		e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+			rm, ok := e.model.GetRoleManager(key)
+			if brm, isBudget := rm.(rbac.IBudgetRoleManager); ok && isBudget {
+				brm.SetTraversalBudget(budget)
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+// Check returns a *LimitError, if rvals exceed the limits.
+// Values, which contain themselves, e.g. a map stored in itself, are rejected, if strings or the depth are limited.
+func (limits RequestLimits) Check(rvals []interface{}) error {
+	if limits.MaxValues > 0 && len(rvals) > limits.MaxValues {
+		return &LimitError{"maximum number of values", len(rvals), limits.MaxValues}
+	}
+	if limits.MaxStringLength <= 0 && limits.MaxDepth <= 0 {
+		return nil
+	}
+	c := &limitChecker{limits: limits, path: make(map[visit]struct{})}
+	for _, rval := range rvals {
+		if err := c.check(reflect.ValueOf(rval), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visit identifies a map, slice or pointer on the path to the checked value, slices of an array differ by their length
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// limitChecker walks request values, path contains the references of the values enclosing the current value
+type limitChecker struct {
+	limits RequestLimits
+	path   map[visit]struct{}
+}
+
+// enter adds the reference of v to the path, it returns false, if v encloses itself
+func (c *limitChecker) enter(v reflect.Value) (visit, bool) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if _, ok := c.path[key]; ok {
+		return key, false
+	}
+	c.path[key] = struct{}{}
+	return key, true
+}
+
+func (c *limitChecker) check(v reflect.Value, depth int) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Pointer {
+			key, ok := c.enter(v)
+			if !ok {
+				return errors.New(str.ERR_CYCLIC_REQUEST)
+			}
+			defer delete(c.path, key)
+		}
+		v = v.Elem()
+	}
+
+	limits := c.limits
+	switch v.Kind() {
+	case reflect.String:
+		if limits.MaxStringLength > 0 && v.Len() > limits.MaxStringLength {
+			return &LimitError{"maximum string length", v.Len(), limits.MaxStringLength}
+		}
+		return nil
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		depth++
+		if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+			return &LimitError{"maximum depth", depth, limits.MaxDepth}
+		}
+	default:
+		return nil
+	}
+
+	if (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && !v.IsNil() {
+		key, ok := c.enter(v)
+		if !ok {
+			return errors.New(str.ERR_CYCLIC_REQUEST)
+		}
+		defer delete(c.path, key)
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := c.check(iter.Key(), depth); err != nil {
+				return err
+			}
+			if err := c.check(iter.Value(), depth); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := c.check(v.Index(i), depth); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := c.check(v.Field(i), depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package fastac
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRequestLimits(t *testing.T) {
+	type attrs struct {
+		Name string
+		Tags []string
+	}
+	shared := map[string]interface{}{"a": "b"}
+	tests := []struct {
+		name   string
+		limits RequestLimits
+		rvals  []interface{}
+		limit  string
+	}{
+		{"values", RequestLimits{MaxValues: 2}, []interface{}{"a", "b", "c"}, "maximum number of values"},
+		{"values within", RequestLimits{MaxValues: 3}, []interface{}{"a", "b", "c"}, ""},
+		{"string", RequestLimits{MaxStringLength: 3}, []interface{}{"abcd"}, "maximum string length"},
+		{"nested string", RequestLimits{MaxStringLength: 3}, []interface{}{&attrs{Tags: []string{"abcd"}}}, "maximum string length"},
+		{"map key", RequestLimits{MaxStringLength: 3}, []interface{}{map[string]int{"abcd": 1}}, "maximum string length"},
+		{"depth", RequestLimits{MaxDepth: 2}, []interface{}{[]interface{}{[]interface{}{[]int{1}}}}, "maximum depth"},
+		{"depth within", RequestLimits{MaxDepth: 2}, []interface{}{attrs{Tags: []string{"a"}}}, ""},
+		{"shared value", RequestLimits{MaxStringLength: 3}, []interface{}{[]interface{}{shared, shared}}, ""},
+	}
+	for _, test := range tests {
+		err := test.limits.Check(test.rvals)
+		var limitErr *LimitError
+		switch {
+		case test.limit == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.limit != "" && (!errors.As(err, &limitErr) || limitErr.Limit != test.limit):
+			t.Errorf("%s: error %v, want %s", test.name, err, test.limit)
+		}
+	}
+}
+
+func TestRequestLimitsCycle(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+	s := []interface{}{nil}
+	s[0] = s
+	type node struct {
+		Name string
+		Next *node
+	}
+	n := &node{Name: "a"}
+	n.Next = n
+
+	limits := RequestLimits{MaxStringLength: 10}
+	for _, rval := range []interface{}{m, s, n} {
+		if err := limits.Check([]interface{}{rval}); err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("%T: error %v, want a cycle", rval, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !go1.21
+
+package logger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// std logs with the log package in the text format of slog, debug messages are discarded
+type std struct{}
+
+func standard() Logger {
+	return std{}
+}
+
+func (std) print(level, msg string, args []any) {
+	var b strings.Builder
+	b.WriteString(level + " " + msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	log.Print(b.String())
+}
+
+func (std) Debug(msg string, args ...any)   {}
+func (l std) Info(msg string, args ...any)  { l.print("INFO", msg, args) }
+func (l std) Warn(msg string, args ...any)  { l.print("WARN", msg, args) }
+func (l std) Error(msg string, args ...any) { l.print("ERROR", msg, args) }
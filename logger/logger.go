@@ -0,0 +1,55 @@
+// Package logger defines the structured logger used by the enforcer, the storage controller,
+// the role managers and the cluster helpers.
+//
+// Logger is a subset of *slog.Logger, so a slog logger can be used directly:
+//
+//	NewEnforcer(model, adapter, OptionLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil))))
+package logger
+
+import "sync/atomic"
+
+// Logger logs messages with alternating key value pairs, e.g. Error("flush failed", "error", err)
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+type nop struct{}
+
+func (nop) Debug(msg string, args ...any) {}
+func (nop) Info(msg string, args ...any)  {}
+func (nop) Warn(msg string, args ...any)  {}
+func (nop) Error(msg string, args ...any) {}
+
+// Nop discards all messages
+var Nop Logger = nop{}
+
+type holder struct {
+	Logger
+}
+
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(holder{standard()})
+}
+
+// Default returns the default logger, which logs with slog.Default() since Go 1.21 and with the log package before
+func Default() Logger {
+	return defaultLogger.Load().(holder).Logger
+}
+
+// SetDefault replaces the default logger, e.g. by Nop to disable logging
+func SetDefault(l Logger) {
+	defaultLogger.Store(holder{l})
+}
+
+// Or returns l or the default logger, if l is nil
+func Or(l Logger) Logger {
+	if l == nil {
+		return Default()
+	}
+	return l
+}
@@ -0,0 +1,17 @@
+//go:build go1.21
+
+package logger
+
+import "log/slog"
+
+// slogDefault logs with slog.Default(), so it follows slog.SetDefault
+type slogDefault struct{}
+
+func standard() Logger {
+	return slogDefault{}
+}
+
+func (slogDefault) Debug(msg string, args ...any) { slog.Default().Debug(msg, args...) }
+func (slogDefault) Info(msg string, args ...any)  { slog.Default().Info(msg, args...) }
+func (slogDefault) Warn(msg string, args ...any)  { slog.Default().Warn(msg, args...) }
+func (slogDefault) Error(msg string, args ...any) { slog.Default().Error(msg, args...) }
@@ -0,0 +1,43 @@
+package fastac
+
+import (
+	"errors"
+
+	"github.com/oarkflow/fastac/logger"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/rbac"
+)
+
+// Option to set the logger of the enforcer, its storage controller and its role managers (default: logger.Default())
+// Adapter failures are logged as errors, retried flushes as warnings and cache updates as debug messages
+//
+//	NewEnforcer(model, adapter, OptionLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil))))
+func OptionLogger(l logger.Logger) Option {
+	return func(e *Enforcer) error {
+		e.logger = l
+		e.sc.SetLogger(l)
+		e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+			rm, ok := e.model.GetRoleManager(key)
+			if lrm, isLogging := rm.(rbac.ILoggingRoleManager); ok && isLogging {
+				lrm.SetLogger(l)
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+// GetLogger returns the logger of the enforcer
+func (e *Enforcer) GetLogger() logger.Logger {
+	return logger.Or(e.logger)
+}
+
+// logDecision logs evaluation panics, other evaluation errors are returned to the caller only
+func (e *Enforcer) logDecision(d *Decision) {
+	var panicErr *PanicError
+	if errors.As(d.Err, &panicErr) {
+		key, _ := d.RequestKey()
+		e.GetLogger().Error("panic during evaluation", "request", d.Request, "key", key, "error", panicErr, "stack", string(panicErr.Stack))
+	}
+}
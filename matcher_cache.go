@@ -0,0 +1,83 @@
+package fastac
+
+import (
+	"errors"
+	"sync"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/matcher"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// DefaultMatcherCacheSize is the number of ad-hoc matchers compiled by EnforceWithMatcher, which are cached by default
+const DefaultMatcherCacheSize = 100
+
+// matcherCache caches the compiled ad-hoc matchers of a model.
+// Evicted matchers are closed, so they stop indexing the rules of the model.
+type matcherCache struct {
+	mutex    sync.Mutex
+	model    m.IModel
+	matchers *util.SyncLRUCache
+}
+
+func newMatcherCache(model m.IModel, size int) *matcherCache {
+	matchers := util.NewSyncLRUCache(size)
+	matchers.SetOnEvict(func(key interface{}, value interface{}) {
+		if closable, ok := value.(matcher.IClosableMatcher); ok {
+			closable.Close()
+		}
+	})
+	return &matcherCache{model: model, matchers: matchers}
+}
+
+// Option to set the number of ad-hoc matchers compiled by EnforceWithMatcher, which are cached (default: DefaultMatcherCacheSize)
+// Every cached matcher indexes all rules of its policy.
+func OptionMatcherCache(size int) Option {
+	return func(e *Enforcer) error {
+		if size <= 0 {
+			return errors.New(str.ERR_MATCHER_CACHE_SIZE)
+		}
+		e.matchers.matchers.Clear()
+		e.matchers = newMatcherCache(e.model, size)
+		return nil
+	}
+}
+
+// EnforceWithMatcher decides a request with the matcher expression instead of the matcher of the model.
+// The compiled expression is cached, so repeated calls with the same expression do not compile it again.
+// It is possible to pass ContextOptions like to Enforce.
+//
+//	e.EnforceWithMatcher("r.sub == p.sub && pathMatch(r.obj, p.obj)", "alice", "/data/1", "read")
+func (e *Enforcer) EnforceWithMatcher(expr string, rvals ...interface{}) (bool, error) {
+	mat, err := e.compileMatcher(expr)
+	if err != nil {
+		return false, err
+	}
+	return e.Enforce(append(rvals[:len(rvals):len(rvals)], SetMatcher(mat))...)
+}
+
+// compileMatcher returns the cached matcher of expr or compiles it
+func (e *Enforcer) compileMatcher(expr string) (matcher.IMatcher, error) {
+	if mat, ok := e.model.GetMatcher(expr); ok {
+		return mat, nil
+	}
+	mc := e.matchers
+	// the lock prevents compiling and indexing the same expression concurrently
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if mc.model != e.model {
+		mc.matchers.Clear()
+		mc.model = e.model
+	}
+	if mat, ok := mc.matchers.Get(expr); ok {
+		return mat.(matcher.IMatcher), nil
+	}
+	mat, err := e.model.BuildMatcherFromDef(defs.NewMatcherDef("", expr))
+	if err != nil {
+		return nil, err
+	}
+	mc.matchers.Put(expr, mat)
+	return mat, nil
+}
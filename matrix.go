@@ -0,0 +1,224 @@
+package fastac
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	"github.com/oarkflow/govaluate"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/fm"
+	"github.com/oarkflow/fastac/model/matcher"
+)
+
+// pureFunctions are the built-in matcher functions, whose results only depend on their arguments
+var pureFunctions = []string{"pathMatch", "pathMatch2", "pathPrefix", "regexMatch", "ipMatch", "globMatch", "semverMatch", "purposeMatch", "labelMatch"}
+
+// Matrix contains the decisions of all combinations of subjects, objects and actions as bitmap.
+// The decision of Subjects[s], Objects[o] and Actions[a] is bit (s*len(Objects)+o)*len(Actions)+a.
+type Matrix struct {
+	Subjects []string
+	Objects  []string
+	Actions  []string
+
+	bits    []uint64
+	indices [3]map[string]int
+}
+
+func newMatrix(subjects, objects, actions []string) *Matrix {
+	mx := &Matrix{Subjects: subjects, Objects: objects, Actions: actions}
+	mx.bits = make([]uint64, (len(subjects)*len(objects)*len(actions)+63)/64)
+	for i, values := range [3][]string{subjects, objects, actions} {
+		mx.indices[i] = make(map[string]int, len(values))
+		for j, value := range values {
+			if _, ok := mx.indices[i][value]; !ok {
+				mx.indices[i][value] = j
+			}
+		}
+	}
+	return mx
+}
+
+func (mx *Matrix) bit(s, o, a int) int {
+	return (s*len(mx.Objects)+o)*len(mx.Actions) + a
+}
+
+func (mx *Matrix) set(s, o, a int) {
+	i := mx.bit(s, o, a)
+	mx.bits[i/64] |= 1 << (i % 64)
+}
+
+// At returns the decision of the subject, object and action at the indices s, o and a
+func (mx *Matrix) At(s, o, a int) bool {
+	i := mx.bit(s, o, a)
+	return mx.bits[i/64]&(1<<(i%64)) != 0
+}
+
+// Allowed returns the decision of sub, obj and act, false if one of them is not part of the matrix
+//
+//	mx.Allowed("alice", "data1", "read")
+func (mx *Matrix) Allowed(sub, obj, act string) bool {
+	s, ok1 := mx.indices[0][sub]
+	o, ok2 := mx.indices[1][obj]
+	a, ok3 := mx.indices[2][act]
+	return ok1 && ok2 && ok3 && mx.At(s, o, a)
+}
+
+// Count returns the number of allowed combinations
+func (mx *Matrix) Count() int {
+	n := 0
+	for _, word := range mx.bits {
+		for ; word != 0; word &= word - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// MarshalJSON encodes the matrix with the bitmap as base64 of the little-endian 64 bit words
+//
+//	{"subjects": ["alice"], "objects": ["data1"], "actions": ["read", "write"], "bitmap": "AQAAAAAAAAA="}
+func (mx *Matrix) MarshalJSON() ([]byte, error) {
+	data := make([]byte, 8*len(mx.bits))
+	for i, word := range mx.bits {
+		binary.LittleEndian.PutUint64(data[8*i:], word)
+	}
+	return json.Marshal(struct {
+		Subjects []string `json:"subjects"`
+		Objects  []string `json:"objects"`
+		Actions  []string `json:"actions"`
+		Bitmap   string   `json:"bitmap"`
+	}{mx.Subjects, mx.Objects, mx.Actions, base64.StdEncoding.EncodeToString(data)})
+}
+
+// ComputeMatrix decides the requests of all combinations of subjects, objects and actions.
+// The request definition "r" must consist of sub, obj and act. The requests share the results of the role lookups
+// and the built-in match functions, so the roles of every subject and the matching patterns of every object are computed once.
+// Decision hooks and the decision log are not run for the requests of the matrix.
+//
+//	mx, _ := e.ComputeMatrix([]string{"alice", "bob"}, []string{"data1", "data2"}, []string{"read", "write"})
+//	mx.Allowed("alice", "data1", "read")
+func (e *Enforcer) ComputeMatrix(subjects, objects, actions []string) (*Matrix, error) {
+	ctx, err := NewContext(e.model)
+	if err != nil {
+		return nil, err
+	}
+	ctx.sessions = e.newMatcherSessions()
+
+	mx := newMatrix(subjects, objects, actions)
+	for s, sub := range subjects {
+		for o, obj := range objects {
+			for a, act := range actions {
+				rvals, err := objectRequest(ctx.rDef.GetArgs(), sub, obj, act)
+				if err != nil {
+					return nil, err
+				}
+				if rvals, err = e.prepare(ctx, rvals); err != nil {
+					return nil, err
+				}
+				routed, err := ctx.routed(rvals)
+				if err != nil {
+					return nil, err
+				}
+				effect, _, _, err := e.enforceEffect(routed, rvals)
+				if err != nil {
+					return nil, err
+				}
+				if effect == eft.Allow {
+					mx.set(s, o, a)
+				}
+			}
+		}
+	}
+	return mx, nil
+}
+
+// memoizedFunctions returns a copy of the functions of the model, in which the role functions and pure functions cache their results
+func (e *Enforcer) memoizedFunctions() *fm.FunctionMap {
+	functions := fm.NewFunctionMap()
+	for name, function := range e.model.GetFunctions() {
+		functions.SetFunction(name, function)
+	}
+	if e.regex != nil {
+		functions.SetFunction("regexMatch", e.regex.Func())
+	}
+	memoize := func(name string) {
+		if function, ok := functions.GetFunctions()[name]; ok {
+			functions.SetFunction(name, memoizeFunction(function))
+		}
+	}
+	e.model.RangeDefs(m.G_SEC, func(key string, _ defs.IDef) bool {
+		memoize(key)
+		return true
+	})
+	for _, name := range pureFunctions {
+		memoize(name)
+	}
+	return functions
+}
+
+// matcherSessions holds a session with the shared functions for every matcher used by the requests of a matrix or batch
+type matcherSessions struct {
+	functions *fm.FunctionMap
+	sessions  map[matcher.IMatcher]*matcher.Session
+}
+
+func (e *Enforcer) newMatcherSessions() *matcherSessions {
+	return &matcherSessions{functions: e.memoizedFunctions(), sessions: make(map[matcher.IMatcher]*matcher.Session)}
+}
+
+// rangeMatches ranges the matches of a request with the session of the matcher, goctx may be nil
+func (ms *matcherSessions) rangeMatches(goctx context.Context, mt matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error {
+	policyKey := []string{mt.GetPolicyKey()}
+	keyed := func(rule []string) bool {
+		return fn(append(policyKey, rule...))
+	}
+	sm, ok := mt.(matcher.ISessionMatcher)
+	if !ok {
+		if cm, ok := mt.(matcher.IContextMatcher); ok && goctx != nil {
+			return cm.RangeMatchesContext(goctx, *rDef, rvals, *ms.functions, nil, keyed)
+		}
+		return mt.RangeMatchesLocked(*rDef, rvals, *ms.functions, keyed)
+	}
+	session, ok := ms.sessions[mt]
+	if !ok {
+		session = sm.NewSession(*ms.functions)
+		ms.sessions[mt] = session
+	}
+	if goctx != nil {
+		return session.RangeMatchesContext(goctx, *rDef, rvals, keyed)
+	}
+	return session.RangeMatchesLocked(*rDef, rvals, keyed)
+}
+
+type memoResult struct {
+	value interface{}
+	err   error
+}
+
+// memoizeFunction caches the results of a function by its arguments, if all arguments are strings
+func memoizeFunction(function govaluate.ExpressionFunction) govaluate.ExpressionFunction {
+	cache := make(map[string]memoResult)
+	return func(args ...interface{}) (interface{}, error) {
+		key := make([]string, len(args))
+		for i, arg := range args {
+			value, ok := arg.(string)
+			if !ok {
+				return function(args...)
+			}
+			key[i] = value
+		}
+		k := strings.Join(key, "\x00")
+		if res, ok := cache[k]; ok {
+			return res.value, res.err
+		}
+		value, err := function(args...)
+		cache[k] = memoResult{value, err}
+		return value, err
+	}
+}
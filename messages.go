@@ -0,0 +1,105 @@
+package fastac
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-ini/ini"
+)
+
+var placeholderReg = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// MessageCatalog maps reason codes to message templates per locale.
+// Templates contain placeholders like {obj}, which are replaced by the values of the request and the deny rule,
+// request values are available by their argument name (sub, r.sub), rule values by their policy argument (p.obj).
+type MessageCatalog struct {
+	mutex         sync.RWMutex
+	defaultLocale string
+	templates     map[string]map[string]string
+}
+
+// NewMessageCatalog creates an empty catalog, defaultLocale is used if a locale has no template for a code
+func NewMessageCatalog(defaultLocale string) *MessageCatalog {
+	return &MessageCatalog{
+		defaultLocale: defaultLocale,
+		templates:     make(map[string]map[string]string),
+	}
+}
+
+// Option to set the message catalog, which renders the reasons of decisions (default: none)
+//
+//	c := NewMessageCatalog("en")
+//	c.Register("en", "deny.data_residency", "Access blocked: {obj} must stay in {p.region}")
+//	NewEnforcer(model, adapter, OptionMessageCatalog(c))
+func OptionMessageCatalog(catalog *MessageCatalog) Option {
+	return func(e *Enforcer) error {
+		e.messages = catalog
+		return nil
+	}
+}
+
+// Register sets the template of a reason code for a locale
+func (c *MessageCatalog) Register(locale, code, template string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	templates, ok := c.templates[locale]
+	if !ok {
+		templates = make(map[string]string)
+		c.templates[locale] = templates
+	}
+	templates[code] = template
+}
+
+// Load reads templates in ini format, sections are locales and keys are reason codes.
+// Keys outside of sections belong to the default locale.
+//
+//	deny.data_residency = Access blocked: {obj} must stay in {p.region}
+//
+//	[de]
+//	deny.data_residency = Zugriff verweigert: {obj} muss in {p.region} bleiben
+func (c *MessageCatalog) Load(r io.Reader) error {
+	cfg, err := ini.Load(r)
+	if err != nil {
+		return err
+	}
+	for _, sec := range cfg.Sections() {
+		locale := sec.Name()
+		if locale == ini.DefaultSection {
+			locale = c.defaultLocale
+		}
+		for _, key := range sec.Keys() {
+			c.Register(locale, key.Name(), key.Value())
+		}
+	}
+	return nil
+}
+
+// Template returns the template of a code.
+// The locale falls back to its language ("de-CH" to "de") and to the default locale.
+func (c *MessageCatalog) Template(locale, code string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, l := range []string{locale, strings.SplitN(strings.ReplaceAll(locale, "_", "-"), "-", 2)[0], c.defaultLocale} {
+		if template, ok := c.templates[l][code]; ok {
+			return template, true
+		}
+	}
+	return "", false
+}
+
+// Render returns the message of a code with replaced placeholders, unknown placeholders are kept.
+// If the code has no template, the code itself is returned.
+func (c *MessageCatalog) Render(locale, code string, values map[string]string) string {
+	template, ok := c.Template(locale, code)
+	if !ok {
+		return code
+	}
+	return placeholderReg.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, ok := values[placeholder[1:len(placeholder)-1]]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
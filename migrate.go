@@ -0,0 +1,176 @@
+package fastac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Migration is an ordered policy change, which is applied once per storage.
+// Migrations are applied in the order of their IDs, e.g. "0001_add_admin_role" before "0002_grant_reports".
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context, e *Enforcer) error
+}
+
+// MigrationStore records the applied migrations, e.g. in a version table of the adapter.
+// If the adapter of an enforcer implements MigrationStore, it is used by default.
+type MigrationStore interface {
+	AppliedMigrations(ctx context.Context) ([]string, error)
+	MarkMigration(ctx context.Context, id string) error
+}
+
+var migrations = struct {
+	sync.RWMutex
+	m map[string]Migration
+}{m: make(map[string]Migration)}
+
+// RegisterMigration registers a migration globally, usually in the init function of its file.
+// It panics, if a migration with the same ID is already registered.
+//
+//	// migrations/0001_add_admin_role.go
+//	func init() {
+//		fastac.RegisterMigration(fastac.RulesMigration("0001_add_admin_role",
+//			[]string{"p", "admin", "*", "*"},
+//			[]string{"g", "root", "admin"},
+//		))
+//	}
+func RegisterMigration(migration Migration) {
+	migrations.Lock()
+	defer migrations.Unlock()
+	if _, ok := migrations.m[migration.ID]; ok {
+		panic(fmt.Sprintf("error: migration %s is already registered", migration.ID))
+	}
+	migrations.m[migration.ID] = migration
+}
+
+// Migrations returns all registered migrations sorted by ID
+func Migrations() []Migration {
+	migrations.RLock()
+	defer migrations.RUnlock()
+	res := make([]Migration, 0, len(migrations.m))
+	for _, migration := range migrations.m {
+		res = append(res, migration)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// RulesMigration creates a migration, which adds rules. Existing rules are skipped, so it is idempotent.
+func RulesMigration(id string, rules ...[]string) Migration {
+	return Migration{
+		ID:          id,
+		Description: fmt.Sprintf("add %d rules", len(rules)),
+		Up: func(ctx context.Context, e *Enforcer) error {
+			return e.AddRules(rules)
+		},
+	}
+}
+
+// Option to set the store of applied migrations (default: the adapter, if it implements MigrationStore)
+func OptionMigrationStore(store MigrationStore) Option {
+	return func(e *Enforcer) error {
+		e.migrations = store
+		return nil
+	}
+}
+
+func (e *Enforcer) migrationStore() (MigrationStore, error) {
+	if e.migrations != nil {
+		return e.migrations, nil
+	}
+	if store, ok := e.adapter.(MigrationStore); ok {
+		return store, nil
+	}
+	return nil, fmt.Errorf("error: no migration store, the adapter does not record migrations")
+}
+
+// Migrate applies all registered migrations, which have not been applied to the storage yet, in the order of their IDs.
+// The changes of every migration are flushed to the adapter before it is recorded as applied.
+// It returns the IDs of the applied migrations.
+func (e *Enforcer) Migrate(ctx context.Context) ([]string, error) {
+	store, err := e.migrationStore()
+	if err != nil {
+		return nil, err
+	}
+	done, err := store.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]bool, len(done))
+	for _, id := range done {
+		applied[id] = true
+	}
+
+	res := []string{}
+	for _, migration := range Migrations() {
+		if applied[migration.ID] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if err := migration.Up(ctx, e); err != nil {
+			return res, fmt.Errorf("error: migration %s failed: %w", migration.ID, err)
+		}
+		if e.sc.Enabled() {
+			if err := e.Flush(); err != nil {
+				return res, err
+			}
+		}
+		if err := store.MarkMigration(ctx, migration.ID); err != nil {
+			return res, err
+		}
+		res = append(res, migration.ID)
+	}
+	return res, nil
+}
+
+// FileMigrationStore records the applied migrations in a JSON file, e.g. next to a CSV policy
+type FileMigrationStore struct {
+	mutex sync.Mutex
+	path  string
+}
+
+// NewFileMigrationStore creates a store, which records the applied migrations in the file at path
+//
+//	NewEnforcer("model.conf", "policy.csv", OptionMigrationStore(NewFileMigrationStore("policy.migrations.json")))
+func NewFileMigrationStore(path string) *FileMigrationStore {
+	return &FileMigrationStore{path: path}
+}
+
+func (s *FileMigrationStore) AppliedMigrations(ctx context.Context) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.read()
+}
+
+func (s *FileMigrationStore) read() ([]string, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	return ids, json.Unmarshal(data, &ids)
+}
+
+func (s *FileMigrationStore) MarkMigration(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids, err := s.read()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(append(ids, id), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
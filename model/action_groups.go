@@ -0,0 +1,85 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/fastac/util"
+)
+
+// ACTION_GROUPS is the name of the model section, which declares named groups of actions
+//
+//	[action_groups]
+//	read = GET, HEAD, OPTIONS
+//	write = POST, PUT, PATCH, DELETE
+const ACTION_GROUPS = "action_groups"
+
+// SetActionGroup declares a named group of actions, which can be used with actMatch
+func (m *Model) SetActionGroup(name string, actions ...string) {
+	m.actionGroups[name] = actions
+}
+
+// RemoveActionGroup removes a named group of actions
+func (m *Model) RemoveActionGroup(name string) bool {
+	_, ok := m.actionGroups[name]
+	delete(m.actionGroups, name)
+	return ok
+}
+
+// GetActionGroup returns the actions of a named group
+func (m *Model) GetActionGroup(name string) ([]string, bool) {
+	actions, ok := m.actionGroups[name]
+	return actions, ok
+}
+
+// ActMatch determines whether act matches pattern.
+// A pattern is an action, "*", a named action group or a list of alternatives, e.g. "(GET|HEAD)".
+// Alternatives may be named groups as well.
+func (m *Model) ActMatch(act, pattern string) bool {
+	if act == pattern || pattern == "*" {
+		return true
+	}
+	if strings.HasPrefix(pattern, "(") && strings.HasSuffix(pattern, ")") {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	for _, alt := range strings.Split(pattern, "|") {
+		alt = strings.TrimSpace(alt)
+		if alt == act {
+			return true
+		}
+		for _, a := range m.actionGroups[alt] {
+			if a == act {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *Model) loadActionGroups(values map[string]string) {
+	for name, value := range values {
+		actions := strings.Split(value, util.DefaultSep)
+		for i, action := range actions {
+			actions[i] = strings.TrimSpace(action)
+		}
+		m.SetActionGroup(name, actions...)
+	}
+}
+
+func (m *Model) actionGroupsString() string {
+	if len(m.actionGroups) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(m.actionGroups))
+	for name := range m.actionGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := fmt.Sprintf("[%s]\n", ACTION_GROUPS)
+	for _, name := range names {
+		res += fmt.Sprintf("%s = %s\n", name, strings.Join(m.actionGroups[name], util.DefaultSep+" "))
+	}
+	return res + "\n"
+}
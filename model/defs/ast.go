@@ -0,0 +1,137 @@
+package defs
+
+import (
+	"github.com/oarkflow/govaluate"
+)
+
+type NodeKind int
+
+const (
+	// AndNode is true, if all children are true
+	AndNode NodeKind = iota
+	// OrNode is true, if one of the children is true
+	OrNode
+	// TermNode is an expression without logical operators
+	TermNode
+)
+
+func (kind NodeKind) String() string {
+	switch kind {
+	case AndNode:
+		return "and"
+	case OrNode:
+		return "or"
+	default:
+		return "term"
+	}
+}
+
+// Node is a node of the matcher AST.
+// Arguments are named like in the parsed expression, e.g. r_sub for r.sub
+type Node struct {
+	Kind     NodeKind
+	Children []*Node
+
+	// Expr, Tokens and Functions are only set for TermNodes
+	Expr        string
+	Tokens      []govaluate.ExpressionToken
+	Functions   []string
+	RequestArgs []string
+	PolicyArgs  []string
+}
+
+// Visitor is called for every node of an AST by Walk.
+// If the returned visitor w is not nil, Walk visits the children of node with w.
+type Visitor interface {
+	Visit(node *Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order
+func Walk(v Visitor, node *Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range node.Children {
+		Walk(v, child)
+	}
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(node *Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order and stops descending, if fn returns false
+//
+//	defs.Inspect(matcher.AST(), func(node *defs.Node) bool {
+//		fmt.Println(node.Kind, node.Expr)
+//		return true
+//	})
+func Inspect(node *Node, fn func(*Node) bool) {
+	Walk(inspector(fn), node)
+}
+
+// AST converts the stage tree to an AST.
+// Every stage is a term, which is combined by AND with the disjunction of its children.
+func (stage *MatcherStage) AST() *Node {
+	alternatives := make([]*Node, 0, len(stage.children))
+	for _, child := range stage.children {
+		alternatives = append(alternatives, child.AST())
+	}
+
+	var rest *Node
+	switch len(alternatives) {
+	case 0:
+	case 1:
+		rest = alternatives[0]
+	default:
+		rest = &Node{Kind: OrNode, Children: alternatives}
+	}
+
+	if stage.expr == "" {
+		if rest == nil {
+			return &Node{Kind: OrNode}
+		}
+		return rest
+	}
+
+	term := stage.term()
+	if rest == nil {
+		return term
+	}
+	if rest.Kind == AndNode {
+		rest.Children = append([]*Node{term}, rest.Children...)
+		return rest
+	}
+	return &Node{Kind: AndNode, Children: []*Node{term, rest}}
+}
+
+func (stage *MatcherStage) term() *Node {
+	node := &Node{
+		Kind:        TermNode,
+		Expr:        stage.expr,
+		Tokens:      stage.tokens,
+		RequestArgs: stage.rArgs,
+		PolicyArgs:  stage.pArgs,
+	}
+	for _, token := range stage.tokens {
+		if token.Kind == govaluate.FUNCTION {
+			if name, ok := token.Value2.(string); ok {
+				node.Functions = append(node.Functions, name)
+			}
+		}
+	}
+	return node
+}
+
+// AST returns the AST of a built matcher
+func (def *MatcherDef) AST() *Node {
+	if def.root == nil {
+		return nil
+	}
+	return def.root.AST()
+}
@@ -36,10 +36,14 @@ type IDef interface {
 	GetKey() string
 }
 
+// EffectVocabulary maps custom values of the eft column to effects, e.g. "audit" to eft.Allow
+type EffectVocabulary map[string]types.Effect
+
 type PolicyDef struct {
 	key      string
 	args     []string
 	argIndex map[string]int
+	effects  EffectVocabulary
 }
 
 func NewPolicyDef(key, arguments string) *PolicyDef {
@@ -66,10 +70,38 @@ func (def *PolicyDef) Has(name string) bool {
 	return ok
 }
 
+// SetEffectVocabulary sets the custom values of the eft column
+func (def *PolicyDef) SetEffectVocabulary(effects EffectVocabulary) {
+	def.effects = effects
+}
+
+// GetEftName returns the value of the eft column, which is "allow" for policies without eft column
+func (def *PolicyDef) GetEftName(values []string) string {
+	eftArg := def.key + "_eft"
+	if !def.Has(eftArg) {
+		return "allow"
+	}
+	eftStr, _ := def.GetParameter(values, eftArg)
+	return eftStr
+}
+
+// HasEft returns true, if name is a valid value of the eft column
+func (def *PolicyDef) HasEft(name string) bool {
+	switch name {
+	case "", "allow", "deny":
+		return true
+	}
+	_, ok := def.effects[name]
+	return ok
+}
+
 func (def *PolicyDef) GetEft(values []string) types.Effect {
 	eftArg := def.key + "_eft"
 	if def.Has(eftArg) {
 		eftStr, _ := def.GetParameter(values, eftArg)
+		if effect, ok := def.effects[eftStr]; ok {
+			return effect
+		}
 		switch eftStr {
 		case "", "allow":
 			return eft.Allow
@@ -135,6 +167,10 @@ func NewRequestDef(key, arguments string) *RequestDef {
 	return def
 }
 
+func (def *RequestDef) GetArgs() []string {
+	return def.args
+}
+
 func (def *RequestDef) Has(name string) bool {
 	_, ok := def.argIndex[name]
 	return ok
@@ -3,15 +3,22 @@ package defs
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/oarkflow/govaluate"
 )
 
+var prefixReg = regexp.MustCompile(`^pathPrefix\(\s*(r[0-9]*_[A-Za-z0-9_]+)\s*,\s*([pg][0-9]*_[A-Za-z0-9_]+)\s*\)$`)
+var labelReg = regexp.MustCompile(`^labelMatch\(\s*(r[0-9]*_[A-Za-z0-9_]+)\s*,\s*([pg][0-9]*_[A-Za-z0-9_]+)\s*\)$`)
+
 type MatcherStage struct {
 	expr     string
 	pArgs    []string
 	rArgs    []string
+	prefix   []string
+	labels   []string
+	tokens   []govaluate.ExpressionToken
 	children []*MatcherStage
 }
 
@@ -20,9 +27,36 @@ func NewMatcherStage(expr string) *MatcherStage {
 	stage.expr = expr
 	stage.pArgs = pArgReg.FindAllString(stage.expr, -1)
 	stage.rArgs = rArgReg.FindAllString(stage.expr, -1)
+	if match := prefixReg.FindStringSubmatch(expr); match != nil {
+		stage.prefix = match[1:]
+	}
+	if match := labelReg.FindStringSubmatch(expr); match != nil {
+		stage.labels = match[1:]
+	}
 	return stage
 }
 
+// PrefixArgs returns the request and policy argument, if the stage is a single pathPrefix call
+func (stage *MatcherStage) PrefixArgs() (rArg string, pArg string, ok bool) {
+	if stage.prefix == nil {
+		return "", "", false
+	}
+	return stage.prefix[0], stage.prefix[1], true
+}
+
+// LabelArgs returns the request and policy argument, if the stage is a single labelMatch call
+func (stage *MatcherStage) LabelArgs() (rArg string, pArg string, ok bool) {
+	if stage.labels == nil {
+		return "", "", false
+	}
+	return stage.labels[0], stage.labels[1], true
+}
+
+// Expr returns the expression of the stage
+func (stage *MatcherStage) Expr() string {
+	return stage.expr
+}
+
 func (stage *MatcherStage) GetPolicyArgs() []string {
 	return stage.pArgs
 }
@@ -146,6 +180,7 @@ func buildExprTree(node *MatcherStage, tokens []govaluate.ExpressionToken, and [
 	if index == -1 {
 		expr := tokensToExpr(tokens)
 		nextNode := NewMatcherStage(expr)
+		nextNode.tokens = tokens
 		node.children = append(node.children, nextNode)
 		if len(and) > 0 {
 			bTokens := and[len(and)-1]
@@ -197,6 +232,10 @@ func (def *MatcherDef) Root() *MatcherStage {
 	return def.root
 }
 
+func (def *MatcherDef) Expr() string {
+	return def.expr
+}
+
 func (def *MatcherDef) GetKey() string {
 	return def.key
 }
@@ -14,12 +14,7 @@
 
 package effector
 
-import "github.com/oarkflow/fastac/model/types"
+import "github.com/oarkflow/fastac/api"
 
-// IEffector is the interface for FastAC effectors.
-type IEffector interface {
-	// MergeEffects merges a list of effects into a single one
-	// This function gets called during the accumulation of effects and once more when all effects have been gathered
-	// Returns the effect and the rule, which is responsible for the result
-	MergeEffects(effects []types.Effect, matches [][]string, complete bool) (types.Effect, []string, error)
-}
+// IEffector is the interface for FastAC effectors, see api.Effector
+type IEffector = api.Effector
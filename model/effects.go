@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/str"
+)
+
+// EFFECTS is the name of the model section, which maps custom values of the eft column to effects.
+// Rules with values, which are neither allow, deny nor declared, are rejected, if the section is present.
+//
+//	[effects]
+//	audit = allow
+//	challenge = deny
+//	1 = allow
+//	0 = deny
+const EFFECTS = "effects"
+
+var effectNames = map[string]types.Effect{
+	"allow":         eft.Allow,
+	"deny":          eft.Deny,
+	"indeterminate": eft.Indeterminate,
+}
+
+// SetEffect maps a custom value of the eft column to an effect
+func (m *Model) SetEffect(name string, effect types.Effect) {
+	m.effects[name] = effect
+}
+
+// RemoveEffect removes a custom value of the eft column
+func (m *Model) RemoveEffect(name string) bool {
+	_, ok := m.effects[name]
+	delete(m.effects, name)
+	return ok
+}
+
+// GetEffectVocabulary returns the custom values of the eft column, which are shared by all policy definitions
+func (m *Model) GetEffectVocabulary() defs.EffectVocabulary {
+	return m.effects
+}
+
+func (m *Model) loadEffects(values map[string]string) error {
+	for name, value := range values {
+		effect, ok := effectNames[strings.TrimSpace(value)]
+		if !ok {
+			return fmt.Errorf(str.ERR_INVALID_EFFECT, name)
+		}
+		m.SetEffect(name, effect)
+	}
+	return nil
+}
+
+func (m *Model) effectsString() string {
+	if len(m.effects) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(m.effects))
+	for name := range m.effects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := fmt.Sprintf("[%s]\n", EFFECTS)
+	for _, name := range names {
+		for effectName, effect := range effectNames {
+			if effect == m.effects[name] {
+				res += fmt.Sprintf("%s = %s\n", name, effectName)
+			}
+		}
+	}
+	return res + "\n"
+}
@@ -31,16 +31,30 @@ func NewFunctionMap() *FunctionMap {
 	return fm
 }
 
-// DefaultFunctionMap returns a function map with all global registered functions and the built in functions (pathMatch, regexMatch, ...)
+// DefaultFunctionMap returns a function map with all global registered functions and the built in functions (pathMatch, pathPrefix, regexMatch, semverMatch, inRange, ...)
 func DefaultFunctionMap() *FunctionMap {
 	fm := NewFunctionMap()
 
 	fm.SetFunction("eval", func(arguments ...interface{}) (interface{}, error) { return nil, nil })
 	fm.SetFunction("pathMatch", util.PathMatchFunc)
 	fm.SetFunction("pathMatch2", util.PathMatchFunc2)
+	fm.SetFunction("pathPrefix", util.PathPrefixFunc)
 	fm.SetFunction("regexMatch", util.RegexMatchFunc)
 	fm.SetFunction("ipMatch", util.IPMatchFunc)
 	fm.SetFunction("globMatch", util.GlobMatchFunc)
+	fm.SetFunction("semverMatch", util.SemverMatchFunc)
+	fm.SetFunction("inRange", util.InRangeFunc)
+	fm.SetFunction("any", util.AnyFunc)
+	fm.SetFunction("all", util.AllFunc)
+	fm.SetFunction("len", util.LenFunc)
+	fm.SetFunction("sum", util.SumFunc)
+	fm.SetFunction("min", util.MinFunc)
+	fm.SetFunction("max", util.MaxFunc)
+	fm.SetFunction("number", util.NumberFunc)
+	fm.SetFunction("purposeMatch", util.PurposeMatchFunc)
+	fm.SetFunction("labelMatch", util.LabelMatchFunc)
+	fm.SetFunction("isAnonymous", util.AnonymousFunc(util.ANONYMOUS, true))
+	fm.SetFunction("isAuthenticated", util.AnonymousFunc(util.ANONYMOUS, false))
 
 	global := getGlobalFunctionMap()
 	for name, fn := range global.fns {
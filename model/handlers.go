@@ -9,6 +9,7 @@ import (
 
 func addPolicyDef(m *Model, key string, arguments string) error {
 	def := defs.NewPolicyDef(key, arguments)
+	def.SetEffectVocabulary(m.effects)
 	m.defs[P_SEC][key] = def
 	m.pMap[key] = policy.NewPolicy(def)
 	return nil
@@ -23,6 +24,7 @@ func removePolicyDef(m *Model, key string) error {
 func addMatcherDef(m *Model, key string, matcher string) error {
 	mDef := defs.NewMatcherDef(key, matcher)
 	m.defs[M_SEC][key] = mDef
+	addResourceRoleDef(m, matcher)
 	return nil
 }
 
@@ -0,0 +1,10 @@
+package model
+
+// IMPERSONATION is the key of the policy definition, which grants actors to impersonate subjects.
+// Its rules have the form "pImp, actor, subject", the actor may be a role of g and the subject a glob pattern:
+//
+//	[policy_definition]
+//	pImp = actor, subject
+//
+//	pImp, ci-deployer, system:serviceaccount:ci:*
+const IMPERSONATION = "pImp"
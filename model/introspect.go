@@ -0,0 +1,153 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/oarkflow/govaluate"
+
+	"github.com/oarkflow/fastac/model/defs"
+)
+
+// Description is a read-only, JSON serializable view of a model, which can be used by tooling
+type Description struct {
+	Sections     []SectionDescription `json:"sections"`
+	Functions    []string             `json:"functions"`
+	ActionGroups map[string][]string  `json:"action_groups,omitempty"`
+	Effects      map[string]string    `json:"effects,omitempty"`
+}
+
+// SectionDescription describes a section of the model
+type SectionDescription struct {
+	Name        string                  `json:"name"`
+	Prefix      string                  `json:"prefix"`
+	Definitions []DefinitionDescription `json:"definitions"`
+}
+
+// DefinitionDescription describes a definition, only the fields of its section are set
+type DefinitionDescription struct {
+	Key  string `json:"key"`
+	Text string `json:"text"`
+
+	// Args are the arguments of request and policy definitions
+	Args []string `json:"args,omitempty"`
+	// NArgs is the number of arguments of role definitions
+	NArgs int `json:"nargs,omitempty"`
+	// RoleManager is the type of the role manager bound to a role definition
+	RoleManager string `json:"role_manager,omitempty"`
+
+	// Expr is the expression of matchers and effects
+	Expr string `json:"expr,omitempty"`
+	// Tokens are the parsed tokens of a matcher
+	Tokens []TokenDescription `json:"tokens,omitempty"`
+	// PolicyKey is the policy, which is selected by a matcher
+	PolicyKey   string   `json:"policy_key,omitempty"`
+	RequestArgs []string `json:"request_args,omitempty"`
+	PolicyArgs  []string `json:"policy_args,omitempty"`
+}
+
+// TokenDescription describes a token of a matcher expression
+type TokenDescription struct {
+	Kind  string      `json:"kind"`
+	Value interface{} `json:"value"`
+}
+
+// Describe returns a description of all sections, definitions and registered functions
+//
+//	data, _ := json.Marshal(m.Describe())
+func (m *Model) Describe() *Description {
+	d := &Description{}
+
+	for _, sec := range sections {
+		secDesc := SectionDescription{
+			Name:        sec.name,
+			Prefix:      string(sec.keyPrefix),
+			Definitions: []DefinitionDescription{},
+		}
+		keys := []string{}
+		for key := range m.defs[sec.keyPrefix] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			secDesc.Definitions = append(secDesc.Definitions, m.describeDef(m.defs[sec.keyPrefix][key]))
+		}
+		d.Sections = append(d.Sections, secDesc)
+	}
+
+	for name := range m.fm.GetFunctions() {
+		d.Functions = append(d.Functions, name)
+	}
+	sort.Strings(d.Functions)
+
+	if len(m.actionGroups) > 0 {
+		d.ActionGroups = make(map[string][]string, len(m.actionGroups))
+		for name, actions := range m.actionGroups {
+			d.ActionGroups[name] = append([]string{}, actions...)
+		}
+	}
+	if len(m.effects) > 0 {
+		d.Effects = make(map[string]string, len(m.effects))
+		for name, effect := range m.effects {
+			for effectName, e := range effectNames {
+				if e == effect {
+					d.Effects[name] = effectName
+				}
+			}
+		}
+	}
+	return d
+}
+
+func (m *Model) describeDef(def defs.IDef) DefinitionDescription {
+	desc := DefinitionDescription{Key: def.GetKey(), Text: def.String()}
+
+	switch def := def.(type) {
+	case *defs.RequestDef:
+		desc.Args = def.GetArgs()
+	case *defs.PolicyDef:
+		desc.Args = def.GetArgs()
+	case *defs.RoleDef:
+		desc.NArgs = def.NArgs()
+		if rm, ok := m.GetRoleManager(def.GetKey()); ok {
+			desc.RoleManager = fmt.Sprintf("%T", rm)
+		}
+	case *defs.EffectDef:
+		desc.Expr = def.Expr()
+	case *defs.MatcherDef:
+		desc.Expr = def.Expr()
+		if def.Root() != nil {
+			desc.PolicyKey = def.GetPolicyKey()
+			desc.RequestArgs = unique(def.GetRequestArgs())
+			desc.PolicyArgs = unique(def.GetPolicyArgs())
+		}
+		expr := defs.ArgReg.ReplaceAllString(def.Expr(), "${1}_${3}")
+		if parsed, err := govaluate.NewEvaluableExpressionWithFunctions(expr, m.fm.GetFunctions()); err == nil {
+			for _, token := range parsed.Tokens() {
+				value := token.Value
+				switch token.Kind {
+				case govaluate.FUNCTION:
+					value = token.Value2
+				case govaluate.CLAUSE:
+					value = "("
+				case govaluate.CLAUSE_CLOSE:
+					value = ")"
+				}
+				desc.Tokens = append(desc.Tokens, TokenDescription{token.Kind.String(), value})
+			}
+		}
+	}
+	return desc
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	res := []string{}
+	for _, value := range values {
+		if _, ok := seen[value]; !ok {
+			seen[value] = struct{}{}
+			res = append(res, value)
+		}
+	}
+	return res
+}
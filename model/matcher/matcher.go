@@ -15,20 +15,26 @@
 package matcher
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/oarkflow/govaluate"
 
+	"github.com/oarkflow/fastac/emitter"
 	"github.com/oarkflow/fastac/model/defs"
 	"github.com/oarkflow/fastac/model/fm"
 	p "github.com/oarkflow/fastac/model/policy"
+	pm "github.com/oarkflow/fastac/pathmatch"
 	"github.com/oarkflow/fastac/util"
 )
 
 type MatcherNode struct {
 	rule     []string
 	children []map[string]*MatcherNode
+	tries    map[int]*pm.PrefixTrie
+	labels   map[int]*util.LabelIndex
 }
 
 func NewMatcherNode(rule []string) *MatcherNode {
@@ -40,6 +46,32 @@ func NewMatcherNode(rule []string) *MatcherNode {
 	return node
 }
 
+// trie returns the prefix index of the children at i
+func (n *MatcherNode) trie(i int) *pm.PrefixTrie {
+	if n.tries == nil {
+		n.tries = make(map[int]*pm.PrefixTrie)
+	}
+	t, ok := n.tries[i]
+	if !ok {
+		t = pm.NewPrefixTrie()
+		n.tries[i] = t
+	}
+	return t
+}
+
+// labelIndex returns the label index of the children at i
+func (n *MatcherNode) labelIndex(i int) *util.LabelIndex {
+	if n.labels == nil {
+		n.labels = make(map[int]*util.LabelIndex)
+	}
+	idx, ok := n.labels[i]
+	if !ok {
+		idx = util.NewLabelIndex()
+		n.labels[i] = idx
+	}
+	return idx
+}
+
 func (n *MatcherNode) GetOrCreate(i int, key string, rule []string) *MatcherNode {
 	if node, ok := n.children[i][key]; ok {
 		return node
@@ -50,10 +82,33 @@ func (n *MatcherNode) GetOrCreate(i int, key string, rule []string) *MatcherNode
 }
 
 type MatchParameters struct {
-	pDef  defs.PolicyDef
-	pvals []string
-	rDef  defs.RequestDef
-	rvals []interface{}
+	pDef   defs.PolicyDef
+	pvals  []string
+	rDef   defs.RequestDef
+	rvals  []interface{}
+	tracer Tracer
+	// exprs caches the compiled expressions of the stages, if the functions are fixed by a Session
+	exprs map[*defs.MatcherStage]*govaluate.EvaluableExpression
+	// ctx cancels the evaluation, it is checked every checkInterval evaluated rules
+	ctx   context.Context
+	steps int
+	// vars are the variables of ctx, see WithVariables
+	vars map[string]interface{}
+}
+
+// checkInterval is the number of evaluated rules between the checks of the context
+const checkInterval = 64
+
+// canceled returns the error of the context, if the evaluation has been canceled
+func (params *MatchParameters) canceled() error {
+	if params.ctx == nil {
+		return nil
+	}
+	params.steps++
+	if params.steps%checkInterval != 0 {
+		return nil
+	}
+	return params.ctx.Err()
 }
 
 func NewMatchParameters(pDef defs.PolicyDef, pvals []string, rDef defs.RequestDef, rvals []interface{}) *MatchParameters {
@@ -66,21 +121,90 @@ func NewMatchParameters(pDef defs.PolicyDef, pvals []string, rDef defs.RequestDe
 }
 
 func (params *MatchParameters) Get(name string) (interface{}, error) {
+	var value interface{}
+	var err error
 	switch name[0] {
 	case 'p', 'g':
-		return params.pDef.GetParameter(params.pvals, name)
+		value, err = params.pDef.GetParameter(params.pvals, name)
 	case 'r':
-		return params.rDef.GetParameter(params.rvals, name)
+		value, err = params.rDef.GetParameter(params.rvals, name)
 	default:
-		return nil, errors.New("No parameter '" + name + "' found.")
+		err = errors.New("No parameter '" + name + "' found.")
+	}
+	if err != nil {
+		if variable, ok := params.vars[name]; ok {
+			return variable, nil
+		}
+	}
+	return value, err
+}
+
+// setContext sets the context of the evaluation and its variables
+func (params *MatchParameters) setContext(ctx context.Context) {
+	params.ctx, params.vars = ctx, nil
+	if ctx != nil {
+		params.vars = Variables(ctx)
+	}
+}
+
+type variablesKey struct{}
+
+// WithVariables returns a copy of ctx with variables, which can be used by name in matcher expressions
+// evaluated with the context, e.g. env in `env == "prod"`. Request and policy parameters take precedence over variables.
+func WithVariables(ctx context.Context, vars map[string]interface{}) context.Context {
+	return context.WithValue(ctx, variablesKey{}, vars)
+}
+
+// Variables returns the variables of ctx, see WithVariables
+func Variables(ctx context.Context) map[string]interface{} {
+	vars, _ := ctx.Value(variablesKey{}).(map[string]interface{})
+	return vars
+}
+
+type functionsKey struct{}
+
+// WithFunctions returns a copy of ctx with functions, which shadow the functions of the same name
+// in matcher expressions evaluated with the context
+func WithFunctions(ctx context.Context, functions map[string]govaluate.ExpressionFunction) context.Context {
+	return context.WithValue(ctx, functionsKey{}, functions)
+}
+
+// Functions returns the functions of ctx, see WithFunctions
+func Functions(ctx context.Context) map[string]govaluate.ExpressionFunction {
+	functions, _ := ctx.Value(functionsKey{}).(map[string]govaluate.ExpressionFunction)
+	return functions
+}
+
+// expression compiles the expression of a stage, or returns the cached expression of a Session
+func (params *MatchParameters) expression(exprNode *defs.MatcherStage, functions map[string]govaluate.ExpressionFunction) (*govaluate.EvaluableExpression, error) {
+	if params.exprs == nil {
+		return exprNode.NewExpressionWithFunctions(functions)
+	}
+	if expr, ok := params.exprs[exprNode]; ok {
+		return expr, nil
+	}
+	expr, err := exprNode.NewExpressionWithFunctions(functions)
+	if err == nil {
+		params.exprs[exprNode] = expr
 	}
+	return expr, err
 }
 
+// Matcher indexes the rules of a policy by the stages of a matcher expression.
+// Rules may be added and removed while matches are ranged, RangeMatches sees the index as of its start.
 type Matcher struct {
+	mutex    sync.RWMutex
 	exprRoot *defs.MatcherStage
 	pDef     *defs.PolicyDef
 	policy   p.IPolicy
 	root     *MatcherNode
+	// listeners index the rule changes of the policy until Close is called
+	listeners []listener
+}
+
+type listener struct {
+	event    emitter.EventType
+	listener *emitter.Listener
 }
 
 func NewMatcher(pDef *defs.PolicyDef, policy p.IPolicy, exprRoot *defs.MatcherStage) *Matcher {
@@ -95,28 +219,57 @@ func NewMatcher(pDef *defs.PolicyDef, policy p.IPolicy, exprRoot *defs.MatcherSt
 		return true
 	})
 
-	policy.AddListener(p.EVT_RULE_ADDED, func(arguments ...interface{}) {
+	m.listen(p.EVT_RULE_ADDED, func(arguments ...interface{}) {
 		rule := arguments[0].([]string)
 		m.addRule(rule)
 	})
 
-	policy.AddListener(p.EVT_RULE_REMOVED, func(arguments ...interface{}) {
+	m.listen(p.EVT_RULES_ADDED, func(arguments ...interface{}) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		for _, rule := range arguments[0].([][]string) {
+			m.addRuleHelper(rule, m.exprRoot, m.root)
+		}
+	})
+
+	m.listen(p.EVT_RULE_REMOVED, func(arguments ...interface{}) {
 		rule := arguments[0].([]string)
 		m.removeRule(rule)
 	})
 
-	policy.AddListener(p.EVT_CLEARED, func(arguments ...interface{}) {
+	m.listen(p.EVT_CLEARED, func(arguments ...interface{}) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
 		m.root = NewMatcherNode([]string{""})
 	})
 
 	return m
 }
 
+func (m *Matcher) listen(event emitter.EventType, handler emitter.HandleFunc) {
+	m.listeners = append(m.listeners, listener{event, m.policy.AddListener(event, handler)})
+}
+
+// Close stops indexing the rule changes of the policy, the matcher must not be used afterwards
+func (m *Matcher) Close() {
+	for _, l := range m.listeners {
+		m.policy.RemoveListener(l.event, l.listener)
+	}
+	m.listeners = nil
+}
+
+// AST returns the parsed matcher expression
+func (m *Matcher) AST() *defs.Node {
+	return m.exprRoot.AST()
+}
+
 func (m *Matcher) GetPolicyKey() string {
 	return m.pDef.GetKey()
 }
 
 func (m *Matcher) addRule(rule []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.addRuleHelper(rule, m.exprRoot, m.root)
 }
 
@@ -132,6 +285,17 @@ func (m *Matcher) addRuleHelper(rule []string, exprNode *defs.MatcherStage, node
 			key = util.Hash(r)
 		}
 
+		if _, pArg, ok := nextExpr.PrefixArgs(); ok {
+			if prefix, err := m.pDef.GetParameter(rule, pArg); err == nil {
+				node.trie(i).Insert(prefix, key)
+			}
+		}
+		if _, pArg, ok := nextExpr.LabelArgs(); ok {
+			if selector, err := m.pDef.GetParameter(rule, pArg); err == nil {
+				node.labelIndex(i).Insert(selector, key)
+			}
+		}
+
 		if !nextExpr.IsLeafNode() {
 			nextNode := node.GetOrCreate(i, key, rule)
 			m.addRuleHelper(rule, nextExpr, nextNode)
@@ -143,6 +307,8 @@ func (m *Matcher) addRuleHelper(rule []string, exprNode *defs.MatcherStage, node
 }
 
 func (m *Matcher) removeRule(rule []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.removeRuleHelper(rule, m.exprRoot, m.root)
 }
 
@@ -164,12 +330,70 @@ func (m *Matcher) removeRuleHelper(rule []string, exprNode *defs.MatcherStage, n
 			}
 		} else {
 			delete(node.children[i], key)
+			if _, pArg, ok := nextExpr.PrefixArgs(); ok {
+				if prefix, err := m.pDef.GetParameter(rule, pArg); err == nil {
+					node.trie(i).Remove(prefix, key)
+				}
+			}
+			if _, pArg, ok := nextExpr.LabelArgs(); ok {
+				if selector, err := m.pDef.GetParameter(rule, pArg); err == nil {
+					node.labelIndex(i).Remove(selector, key)
+				}
+			}
 		}
 	}
 }
 
+// candidates returns the children at i, which can match the request.
+// If the stage is a pathPrefix call, only children with a matching prefix are returned,
+// if it is a labelMatch call, only children, whose selectors may select the labels of the request.
+func (m *Matcher) candidates(exprNode *defs.MatcherStage, node *MatcherNode, i int, params *MatchParameters) map[string]*MatcherNode {
+	if rArg, _, ok := exprNode.LabelArgs(); ok && node.labels != nil && node.labels[i] != nil {
+		return m.labelCandidates(rArg, node, i, params)
+	}
+	rArg, _, ok := exprNode.PrefixArgs()
+	if !ok || node.tries == nil || node.tries[i] == nil {
+		return node.children[i]
+	}
+	rval, err := params.rDef.GetParameter(params.rvals, rArg)
+	if err != nil {
+		return node.children[i]
+	}
+	path, ok := rval.(string)
+	if !ok {
+		return node.children[i]
+	}
+	res := make(map[string]*MatcherNode)
+	node.tries[i].Prefixes(path, func(key string) bool {
+		if child, ok := node.children[i][key]; ok {
+			res[key] = child
+		}
+		return true
+	})
+	return res
+}
+
+func (m *Matcher) labelCandidates(rArg string, node *MatcherNode, i int, params *MatchParameters) map[string]*MatcherNode {
+	rval, err := params.rDef.GetParameter(params.rvals, rArg)
+	if err != nil {
+		return node.children[i]
+	}
+	labels, ok := util.ToLabels(rval)
+	if !ok {
+		return node.children[i]
+	}
+	res := make(map[string]*MatcherNode)
+	node.labels[i].Candidates(labels, func(key string) bool {
+		if child, ok := node.children[i][key]; ok {
+			res[key] = child
+		}
+		return true
+	})
+	return res
+}
+
 func (m *Matcher) rangeMatches(exprNode *defs.MatcherStage, rules map[string]*MatcherNode, params *MatchParameters, functions map[string]govaluate.ExpressionFunction, fn func(node *MatcherNode) bool) (bool, error) {
-	expr, err := exprNode.NewExpressionWithFunctions(functions)
+	expr, err := params.expression(exprNode, functions)
 	if err != nil {
 		return false, err
 	}
@@ -181,8 +405,15 @@ func (m *Matcher) rangeMatches(exprNode *defs.MatcherStage, rules map[string]*Ma
 		}
 	}
 	for _, child := range rules {
+		if err := params.canceled(); err != nil {
+			return false, err
+		}
 		params.pvals = child.rule
 		res, err := expr.Eval(params)
+		if params.tracer != nil {
+			values, _ := m.pDef.GetParameters(child.rule, exprNode.GetPolicyArgs())
+			params.tracer.Stage(exprNode.Expr(), exprNode.GetPolicyArgs(), values, res, err)
+		}
 		if err != nil {
 			return false, err
 		}
@@ -198,12 +429,18 @@ func (m *Matcher) rangeMatches(exprNode *defs.MatcherStage, rules map[string]*Ma
 
 func (m *Matcher) rangeMatchesHelper(exprNode *defs.MatcherStage, node *MatcherNode, params *MatchParameters, functions map[string]govaluate.ExpressionFunction, fn func(rule []string) bool) (bool, error) {
 	for i, nextExpr := range exprNode.Children() {
-		cont, err := m.rangeMatches(nextExpr, node.children[i], params, functions, func(nextNode *MatcherNode) bool {
+		rules := m.candidates(nextExpr, node, i, params)
+		if len(rules) == 0 && len(node.children[i]) > 0 {
+			continue
+		}
+		var childErr error
+		cont, err := m.rangeMatches(nextExpr, rules, params, functions, func(nextNode *MatcherNode) bool {
 			if nextExpr.IsLeafNode() && !fn(nextNode.rule) {
 				return false // break
 			} else {
 				cont, err := m.rangeMatchesHelper(nextExpr, nextNode, params, functions, fn)
 				if err != nil || !cont {
+					childErr = err
 					return false
 				}
 			}
@@ -212,6 +449,9 @@ func (m *Matcher) rangeMatchesHelper(exprNode *defs.MatcherStage, node *MatcherN
 		if err != nil {
 			return false, err
 		}
+		if childErr != nil {
+			return false, childErr
+		}
 		if !cont {
 			return false, nil
 		}
@@ -219,17 +459,122 @@ func (m *Matcher) rangeMatchesHelper(exprNode *defs.MatcherStage, node *MatcherN
 	return true, nil
 }
 
+// RangeMatches calls fn for every rule, which matches the request.
+// The matches are collected before fn is called, so fn sees the policy as of the start of the call
+// and may modify it, while other goroutines can add and remove rules concurrently.
 func (m *Matcher) RangeMatches(rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, fn func(rule []string) bool) error {
+	matches := [][]string{}
+	err := m.RangeMatchesLocked(rDef, rvals, fMap, func(rule []string) bool {
+		matches = append(matches, rule)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, rule := range matches {
+		if !fn(rule) {
+			break
+		}
+	}
+	return nil
+}
+
+// RangeMatchesLocked calls fn for every rule, which matches the request, while the index is locked for reading.
+// Other rangings may run concurrently, rules are only added and removed after it returns.
+// The ranging stops as soon as fn returns false, fn must not modify the policy, since that waits for the write lock.
+func (m *Matcher) RangeMatchesLocked(rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, fn func(rule []string) bool) error {
+	return m.rangeMatchesLocked(nil, rDef, rvals, fMap, nil, fn)
+}
+
+// RangeMatchesTraced is like RangeMatchesLocked, but reports every evaluated stage and function call to tracer
+func (m *Matcher) RangeMatchesTraced(rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, tracer Tracer, fn func(rule []string) bool) error {
+	return m.rangeMatchesLocked(nil, rDef, rvals, fMap, tracer, fn)
+}
+
+// RangeMatchesContext is like RangeMatchesTraced, but stops with the error of ctx, if ctx is canceled, tracer may be nil
+func (m *Matcher) RangeMatchesContext(ctx context.Context, rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, tracer Tracer, fn func(rule []string) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.rangeMatchesLocked(ctx, rDef, rvals, fMap, tracer, fn)
+}
+
+func (m *Matcher) rangeMatchesLocked(ctx context.Context, rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, tracer Tracer, fn func(rule []string) bool) error {
 	params := NewMatchParameters(*m.pDef, nil, rDef, rvals)
-	fMap.SetFunction("eval", generateEvalFunction(fMap, params))
-	functions := fMap.GetFunctions()
+	params.tracer = tracer
+	params.setContext(ctx)
+	functions := make(map[string]govaluate.ExpressionFunction, len(fMap.GetFunctions())+1)
+	for name, function := range fMap.GetFunctions() {
+		if tracer != nil {
+			function = traceFunction(tracer, name, function)
+		}
+		functions[name] = function
+	}
+	if ctx != nil {
+		for name, function := range Functions(ctx) {
+			if tracer != nil {
+				function = traceFunction(tracer, name, function)
+			}
+			functions[name] = function
+		}
+	}
+	functions["eval"] = generateEvalFunction(functions, params)
 
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 	_, err := m.rangeMatchesHelper(m.exprRoot, m.root, params, functions, fn)
-	if err != nil {
+	return err
+}
+
+// Session evaluates many requests with the same functions, the expressions of the stages are compiled once.
+// A session must not be used concurrently.
+type Session struct {
+	m         *Matcher
+	params    *MatchParameters
+	functions map[string]govaluate.ExpressionFunction
+}
+
+// NewSession creates a session, which evaluates requests with the functions of fMap
+func (m *Matcher) NewSession(fMap fm.FunctionMap) *Session {
+	params := NewMatchParameters(*m.pDef, nil, defs.RequestDef{}, nil)
+	params.exprs = make(map[*defs.MatcherStage]*govaluate.EvaluableExpression)
+	functions := make(map[string]govaluate.ExpressionFunction, len(fMap.GetFunctions())+1)
+	for name, function := range fMap.GetFunctions() {
+		functions[name] = function
+	}
+	functions["eval"] = generateEvalFunction(functions, params)
+	return &Session{m: m, params: params, functions: functions}
+}
+
+// RangeMatchesLocked is like Matcher.RangeMatchesLocked with the functions of the session
+func (s *Session) RangeMatchesLocked(rDef defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error {
+	return s.rangeMatches(nil, rDef, rvals, fn)
+}
+
+// RangeMatchesContext is like RangeMatchesLocked, but stops with the error of ctx, if ctx is canceled
+func (s *Session) RangeMatchesContext(ctx context.Context, rDef defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	return s.rangeMatches(ctx, rDef, rvals, fn)
+}
 
-	return nil
+func (s *Session) rangeMatches(ctx context.Context, rDef defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error {
+	if ctx != nil && len(Functions(ctx)) > 0 {
+		// the cached expressions are bound to the functions of the session
+		fMap := fm.NewFunctionMap()
+		for name, function := range s.functions {
+			fMap.SetFunction(name, function)
+		}
+		return s.m.rangeMatchesLocked(ctx, rDef, rvals, *fMap, nil, fn)
+	}
+	s.params.rDef, s.params.rvals, s.params.pvals = rDef, rvals, nil
+	s.params.setContext(ctx)
+
+	s.m.mutex.RLock()
+	defer s.m.mutex.RUnlock()
+	_, err := s.m.rangeMatchesHelper(s.m.exprRoot, s.m.root, s.params, s.functions, fn)
+	return err
 }
 
 func eval(expression string, functions map[string]govaluate.ExpressionFunction, parameters *MatchParameters) (interface{}, error) {
@@ -240,9 +585,7 @@ func eval(expression string, functions map[string]govaluate.ExpressionFunction,
 	return expr.Eval(parameters)
 }
 
-func generateEvalFunction(fMap fm.FunctionMap, parameters *MatchParameters) govaluate.ExpressionFunction {
-	functions := fMap.GetFunctions()
-
+func generateEvalFunction(functions map[string]govaluate.ExpressionFunction, parameters *MatchParameters) govaluate.ExpressionFunction {
 	return func(args ...interface{}) (interface{}, error) {
 		if err := util.ValidateVariadicArgs(1, args...); err != nil {
 			return false, fmt.Errorf("%s: %s", "eval", err)
@@ -253,3 +596,12 @@ func generateEvalFunction(fMap fm.FunctionMap, parameters *MatchParameters) gova
 		return eval(expression, functions, parameters)
 	}
 }
+
+// traceFunction reports the calls of a matcher function to tracer
+func traceFunction(tracer Tracer, name string, function govaluate.ExpressionFunction) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		res, err := function(args...)
+		tracer.Call(name, args, res, err)
+		return res, err
+	}
+}
@@ -1,11 +1,43 @@
 package matcher
 
 import (
+	"context"
+
 	"github.com/oarkflow/fastac/model/defs"
 	"github.com/oarkflow/fastac/model/fm"
 )
 
 type IMatcher interface {
 	GetPolicyKey() string
+	AST() *defs.Node
 	RangeMatches(rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, fn func(rule []string) bool) error
+	RangeMatchesLocked(rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, fn func(rule []string) bool) error
+}
+
+// Tracer receives the steps of a traced evaluation
+type Tracer interface {
+	// Stage is called for every evaluated stage of the matcher expression with the policy arguments of the stage and their values
+	Stage(expr string, args []string, values []string, result interface{}, err error)
+	// Call is called for every function call of the matcher expression, e.g. the role lookups of g
+	Call(name string, args []interface{}, result interface{}, err error)
+}
+
+// ITracingMatcher is implemented by matchers, which can report the steps of an evaluation
+type ITracingMatcher interface {
+	RangeMatchesTraced(rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, tracer Tracer, fn func(rule []string) bool) error
+}
+
+// ISessionMatcher is implemented by matchers, which can evaluate many requests with the same functions
+type ISessionMatcher interface {
+	NewSession(fMap fm.FunctionMap) *Session
+}
+
+// IClosableMatcher is implemented by matchers, which index the rules of their policy until they are closed
+type IClosableMatcher interface {
+	Close()
+}
+
+// IContextMatcher is implemented by matchers, whose evaluation can be canceled by a context
+type IContextMatcher interface {
+	RangeMatchesContext(ctx context.Context, rDef defs.RequestDef, rvals []interface{}, fMap fm.FunctionMap, tracer Tracer, fn func(rule []string) bool) error
 }
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/fastac/str"
+)
+
+// MATCHER_ROUTES is the name of the model section, which selects the matcher by the value of a request argument.
+// arg is the request argument, default is the matcher of all other values.
+//
+//	[matcher_routes]
+//	arg = r.kind
+//	api = m_api
+//	ui = m_ui
+//	default = m
+const MATCHER_ROUTES = "matcher_routes"
+
+// MatcherRoute maps the values of a request argument to matchers
+type MatcherRoute struct {
+	// Arg is the request argument, e.g. "r.kind"
+	Arg      string
+	Matchers map[string]string
+	// Default is the matcher of values without route, the request is not routed if it is empty
+	Default string
+}
+
+// RequestKey returns the key of the request definition of Arg, e.g. "r"
+func (route *MatcherRoute) RequestKey() string {
+	return strings.SplitN(route.Arg, ".", 2)[0]
+}
+
+// Parameter returns the parameter name of Arg, e.g. "r_kind"
+func (route *MatcherRoute) Parameter() string {
+	return strings.Replace(route.Arg, ".", "_", 1)
+}
+
+// Select returns the matcher key for the value of the request argument
+func (route *MatcherRoute) Select(value interface{}) (string, bool) {
+	if key, ok := route.Matchers[fmt.Sprint(value)]; ok {
+		return key, true
+	}
+	return route.Default, route.Default != ""
+}
+
+// SetMatcherRoute sets the route, which selects the matcher of requests without explicit matcher.
+// All matchers of the route must be defined. A nil route disables routing.
+func (m *Model) SetMatcherRoute(route *MatcherRoute) error {
+	if route != nil {
+		keys := []string{}
+		for _, key := range route.Matchers {
+			keys = append(keys, key)
+		}
+		if route.Default != "" {
+			keys = append(keys, route.Default)
+		}
+		for _, key := range keys {
+			if _, ok := m.defs[M_SEC][key]; !ok {
+				return fmt.Errorf(str.ERR_MATCHER_NOT_FOUND, key)
+			}
+		}
+		if !strings.Contains(route.Arg, ".") {
+			return fmt.Errorf("error: route argument %s must have the form r.arg", route.Arg)
+		}
+	}
+	m.route = route
+	return nil
+}
+
+// GetMatcherRoute returns the matcher route or nil
+func (m *Model) GetMatcherRoute() *MatcherRoute {
+	return m.route
+}
+
+func (m *Model) loadMatcherRoute(values map[string]string) error {
+	route := &MatcherRoute{Matchers: make(map[string]string)}
+	for name, value := range values {
+		switch name {
+		case "arg":
+			route.Arg = value
+		case "default":
+			route.Default = value
+		default:
+			route.Matchers[name] = value
+		}
+	}
+	return m.SetMatcherRoute(route)
+}
+
+func (m *Model) matcherRouteString() string {
+	if m.route == nil {
+		return ""
+	}
+	values := make([]string, 0, len(m.route.Matchers))
+	for value := range m.route.Matchers {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	res := fmt.Sprintf("[%s]\narg = %s\n", MATCHER_ROUTES, m.route.Arg)
+	for _, value := range values {
+		res += fmt.Sprintf("%s = %s\n", value, m.route.Matchers[value])
+	}
+	if m.route.Default != "" {
+		res += fmt.Sprintf("default = %s\n", m.route.Default)
+	}
+	return res + "\n"
+}
@@ -0,0 +1,224 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-ini/ini"
+
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// MergeStrategy decides, which definition Merge keeps, if both models define a key differently
+type MergeStrategy int
+
+const (
+	// KeepA keeps the definitions of the first model
+	KeepA MergeStrategy = iota
+	// KeepB keeps the definitions of the second model
+	KeepB
+	// FailOnConflict aborts the merge, if a definition conflicts
+	FailOnConflict
+)
+
+// DefinitionConflict is a key, which both models define differently
+type DefinitionConflict struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	A       string `json:"a"`
+	B       string `json:"b"`
+	// Kept is the kept definition
+	Kept string `json:"kept"`
+}
+
+// RuleContradiction is a pair of rules, which only differ in their effect
+type RuleContradiction struct {
+	A []string `json:"a"`
+	B []string `json:"b"`
+}
+
+// MergeReport describes the conflicts of a merge
+type MergeReport struct {
+	Definitions []DefinitionConflict `json:"definitions"`
+	// Duplicates are the rules of both models, which are added once
+	Duplicates [][]string `json:"duplicates"`
+	// Contradictions are the rules with equal values, but different effects, both rules are added
+	Contradictions []RuleContradiction `json:"contradictions"`
+	// Skipped are the rules, whose definition has been replaced by the conflicting definition of the other model
+	Skipped [][]string `json:"skipped"`
+}
+
+// HasConflicts returns true, if a definition conflicts or rules contradict each other
+func (r *MergeReport) HasConflicts() bool {
+	return len(r.Definitions) > 0 || len(r.Contradictions) > 0
+}
+
+// Merge combines the definitions and rules of two models into a new model, e.g. the policy repositories of two companies.
+// Definitions of the same key are compared in all sections including action groups, effects and matcher routes,
+// conflicting definitions are resolved by the strategy and the rules of the dropped definition are skipped.
+// Rules of both models are reported as duplicates, rules, which only differ in their eft value, as contradictions.
+// Functions and role managers set after loading the models are not merged.
+//
+//	merged, report, err := Merge(acme, globex, FailOnConflict)
+//	for _, c := range report.Contradictions {
+//		fmt.Println(c.A, "contradicts", c.B)
+//	}
+func Merge(a, b IModel, strategy MergeStrategy) (*Model, *MergeReport, error) {
+	report := &MergeReport{Definitions: []DefinitionConflict{}, Duplicates: [][]string{}, Contradictions: []RuleContradiction{}, Skipped: [][]string{}}
+	text, dropped, err := mergeDefinitions(a, b, strategy, report)
+	if err != nil {
+		return nil, report, err
+	}
+	merged := NewModel()
+	if err := merged.LoadModelFromText(text); err != nil {
+		return nil, report, err
+	}
+
+	rules := [][]string{}
+	// effects maps the rules of a without their eft value to their rules
+	effects := map[string][]string{}
+	a.RangeRules(func(rule []string) bool {
+		if dropped[rule[0]] == 'a' {
+			report.Skipped = append(report.Skipped, rule)
+			return true
+		}
+		rules = append(rules, rule)
+		if key, ok := merged.effectlessKey(rule); ok {
+			effects[key] = rule
+		}
+		return true
+	})
+	b.RangeRules(func(rule []string) bool {
+		if dropped[rule[0]] == 'b' {
+			report.Skipped = append(report.Skipped, rule)
+			return true
+		}
+		if a.HasRule(rule) && dropped[rule[0]] == 0 {
+			report.Duplicates = append(report.Duplicates, rule)
+			return true
+		}
+		if key, ok := merged.effectlessKey(rule); ok {
+			if other, ok := effects[key]; ok && merged.ruleEffect(other) != merged.ruleEffect(rule) {
+				report.Contradictions = append(report.Contradictions, RuleContradiction{other, rule})
+			}
+		}
+		rules = append(rules, rule)
+		return true
+	})
+	sortRules(report.Duplicates)
+	sortRules(report.Skipped)
+	sort.Slice(report.Contradictions, func(i, j int) bool {
+		return strings.Join(report.Contradictions[i].B, ",") < strings.Join(report.Contradictions[j].B, ",")
+	})
+	if _, err := merged.AddRules(rules); err != nil {
+		return nil, report, err
+	}
+	return merged, report, nil
+}
+
+// mergeDefinitions returns the text of the merged definitions and the model ('a' or 'b'), whose rules are dropped per policy type
+func mergeDefinitions(a, b IModel, strategy MergeStrategy, report *MergeReport) (string, map[string]byte, error) {
+	cfgA, err := ini.Load([]byte(a.String()))
+	if err != nil {
+		return "", nil, err
+	}
+	cfgB, err := ini.Load([]byte(b.String()))
+	if err != nil {
+		return "", nil, err
+	}
+	dropped := map[string]byte{}
+	names := cfgA.SectionStrings()
+	for _, name := range cfgB.SectionStrings() {
+		if !cfgA.HasSection(name) {
+			names = append(names, name)
+		}
+	}
+
+	var text strings.Builder
+	for _, name := range names {
+		if name == ini.DefaultSection {
+			continue
+		}
+		secA, secB := cfgA.Section(name), cfgB.Section(name)
+		keys := secA.KeyStrings()
+		for _, key := range secB.KeyStrings() {
+			if !secA.HasKey(key) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(&text, "[%s]\n", name)
+		for _, key := range keys {
+			// Key creates missing keys, so the presence is checked first
+			hasA, hasB := secA.HasKey(key), secB.HasKey(key)
+			valueA, valueB := secA.Key(key).String(), secB.Key(key).String()
+			value := valueA
+			switch {
+			case !hasA:
+				value = valueB
+			case !hasB || normalizeDef(valueA) == normalizeDef(valueB):
+			default:
+				conflict := DefinitionConflict{Section: name, Key: key, A: valueA, B: valueB}
+				// the rules of a replaced policy or role definition do not fit the kept definition
+				rules := name == "policy_definition" || name == "role_definition"
+				switch strategy {
+				case KeepA:
+					if rules {
+						dropped[key] = 'b'
+					}
+				case KeepB:
+					value = valueB
+					if rules {
+						dropped[key] = 'a'
+					}
+				default:
+					report.Definitions = append(report.Definitions, conflict)
+					return "", nil, fmt.Errorf(str.ERR_MERGE_CONFLICT, name, key)
+				}
+				conflict.Kept = value
+				report.Definitions = append(report.Definitions, conflict)
+			}
+			fmt.Fprintf(&text, "%s = %s\n", key, value)
+		}
+		text.WriteString("\n")
+	}
+	return text.String(), dropped, nil
+}
+
+func normalizeDef(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
+
+// effectlessKey returns the key of a policy rule without its eft value, false if the policy has no eft argument
+func (m *Model) effectlessKey(rule []string) (string, bool) {
+	def, ok := m.GetDef(P_SEC, rule[0])
+	if !ok {
+		return "", false
+	}
+	pDef := def.(*defs.PolicyDef)
+	for i, arg := range pDef.GetArgs() {
+		if arg == "eft" && i+1 < len(rule) {
+			values := append([]string(nil), rule...)
+			values[i+1] = ""
+			return util.Hash(values), true
+		}
+	}
+	return "", false
+}
+
+func (m *Model) ruleEffect(rule []string) types.Effect {
+	def, _ := m.GetDef(P_SEC, rule[0])
+	return def.(*defs.PolicyDef).GetEft(rule[1:])
+}
+
+func sortRules(rules [][]string) {
+	sort.Slice(rules, func(i, j int) bool {
+		return strings.Join(rules[i], ",") < strings.Join(rules[j], ",")
+	})
+}
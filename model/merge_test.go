@@ -0,0 +1,205 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/str"
+)
+
+const mergeModelA = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+p2 = sub, obj
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+m2 = r.sub == p2.sub && r.obj == p2.obj
+`
+
+// mergeModelB defines p2 and m2 differently, m only differs in whitespace
+const mergeModelB = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+p2 = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub)&&r.obj == p.obj&&r.act == p.act
+m2 = r.sub == p2.sub && r.obj == p2.obj && r.act == p2.act
+`
+
+func mergeModel(t *testing.T, text string, rules [][]string) *Model {
+	t.Helper()
+	m := NewModel()
+	if err := m.LoadModelFromText(text); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+var mergeRulesA = [][]string{
+	{"p", "alice", "data1", "read", "allow"},
+	{"p", "bob", "data2", "write", "allow"},
+	{"p", "carol", "data3", "read", "deny"},
+	{"p2", "alice", "data1"},
+	{"g", "alice", "admin"},
+}
+
+var mergeRulesB = [][]string{
+	{"p", "alice", "data1", "read", "allow"},
+	{"p", "bob", "data2", "write", "deny"},
+	{"p", "carol", "data3", "read", "allow"},
+	{"p", "dave", "data4", "read", "allow"},
+	{"p2", "dave", "data4", "read"},
+	{"g", "alice", "admin"},
+	{"g", "bob", "admin"},
+}
+
+func TestMerge(t *testing.T) {
+	duplicates := [][]string{{"g", "alice", "admin"}, {"p", "alice", "data1", "read", "allow"}}
+	contradictions := []RuleContradiction{
+		{[]string{"p", "bob", "data2", "write", "allow"}, []string{"p", "bob", "data2", "write", "deny"}},
+		{[]string{"p", "carol", "data3", "read", "deny"}, []string{"p", "carol", "data3", "read", "allow"}},
+	}
+	m2A, m2B := "r.sub == p2.sub && r.obj == p2.obj", "r.sub == p2.sub && r.obj == p2.obj && r.act == p2.act"
+	shared := [][]string{
+		{"g", "alice", "admin"},
+		{"g", "bob", "admin"},
+		{"p", "alice", "data1", "read", "allow"},
+		{"p", "bob", "data2", "write", "allow"},
+		{"p", "bob", "data2", "write", "deny"},
+		{"p", "carol", "data3", "read", "allow"},
+		{"p", "carol", "data3", "read", "deny"},
+		{"p", "dave", "data4", "read", "allow"},
+	}
+
+	tests := []struct {
+		strategy MergeStrategy
+		report   MergeReport
+		rules    [][]string
+		p2       []string
+	}{
+		{
+			strategy: KeepA,
+			report: MergeReport{
+				Definitions: []DefinitionConflict{
+					{Section: "policy_definition", Key: "p2", A: "sub, obj", B: "sub, obj, act", Kept: "sub, obj"},
+					{Section: "matchers", Key: "m2", A: m2A, B: m2B, Kept: m2A},
+				},
+				Duplicates:     duplicates,
+				Contradictions: contradictions,
+				// the rule of the dropped definition of b does not fit the definition of a
+				Skipped: [][]string{{"p2", "dave", "data4", "read"}},
+			},
+			rules: append(append([][]string{}, shared...), []string{"p2", "alice", "data1"}),
+			p2:    []string{"sub", "obj"},
+		},
+		{
+			strategy: KeepB,
+			report: MergeReport{
+				Definitions: []DefinitionConflict{
+					{Section: "policy_definition", Key: "p2", A: "sub, obj", B: "sub, obj, act", Kept: "sub, obj, act"},
+					{Section: "matchers", Key: "m2", A: m2A, B: m2B, Kept: m2B},
+				},
+				Duplicates:     duplicates,
+				Contradictions: contradictions,
+				Skipped:        [][]string{{"p2", "alice", "data1"}},
+			},
+			rules: append(append([][]string{}, shared...), []string{"p2", "dave", "data4", "read"}),
+			p2:    []string{"sub", "obj", "act"},
+		},
+	}
+	for _, test := range tests {
+		a, b := mergeModel(t, mergeModelA, mergeRulesA), mergeModel(t, mergeModelB, mergeRulesB)
+		merged, report, err := Merge(a, b, test.strategy)
+		if err != nil {
+			t.Fatalf("Merge with strategy %d: %v", test.strategy, err)
+		}
+		if !reflect.DeepEqual(*report, test.report) {
+			t.Fatalf("report of Merge with strategy %d:\n%+v\nwant\n%+v", test.strategy, *report, test.report)
+		}
+		if !report.HasConflicts() {
+			t.Fatalf("HasConflicts with strategy %d: false", test.strategy)
+		}
+
+		rules := [][]string{}
+		merged.RangeRules(func(rule []string) bool {
+			rules = append(rules, rule)
+			return true
+		})
+		sortRules(rules)
+		sortRules(test.rules)
+		if !reflect.DeepEqual(rules, test.rules) {
+			t.Fatalf("rules merged with strategy %d: %v, want %v", test.strategy, rules, test.rules)
+		}
+		def, ok := merged.GetDef(P_SEC, "p2")
+		if !ok || !reflect.DeepEqual(def.(*defs.PolicyDef).GetArgs(), test.p2) {
+			t.Fatalf("definition p2 merged with strategy %d: %v, want %v", test.strategy, def, test.p2)
+		}
+	}
+}
+
+func TestMergeFailOnConflict(t *testing.T) {
+	a, b := mergeModel(t, mergeModelA, mergeRulesA), mergeModel(t, mergeModelB, mergeRulesB)
+	merged, report, err := Merge(a, b, FailOnConflict)
+	if err == nil || err.Error() != fmt.Sprintf(str.ERR_MERGE_CONFLICT, "policy_definition", "p2") || merged != nil {
+		t.Fatalf("Merge with FailOnConflict: %v %v, want conflict of p2", merged, err)
+	}
+	// the merge stops at the first conflict, before the rules are compared
+	want := MergeReport{
+		Definitions:    []DefinitionConflict{{Section: "policy_definition", Key: "p2", A: "sub, obj", B: "sub, obj, act"}},
+		Duplicates:     [][]string{},
+		Contradictions: []RuleContradiction{},
+		Skipped:        [][]string{},
+	}
+	if !reflect.DeepEqual(*report, want) {
+		t.Fatalf("report of Merge with FailOnConflict:\n%+v\nwant\n%+v", *report, want)
+	}
+
+	// without conflicting definitions the rules are merged, contradictions are reported only
+	b = mergeModel(t, mergeModelA, [][]string{{"p", "bob", "data2", "write", "deny"}, {"p", "erin", "data5", "read", "allow"}})
+	merged, report, err = Merge(a, b, FailOnConflict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = MergeReport{
+		Definitions:    []DefinitionConflict{},
+		Duplicates:     [][]string{},
+		Contradictions: []RuleContradiction{{[]string{"p", "bob", "data2", "write", "allow"}, []string{"p", "bob", "data2", "write", "deny"}}},
+		Skipped:        [][]string{},
+	}
+	if !reflect.DeepEqual(*report, want) || !report.HasConflicts() {
+		t.Fatalf("report of Merge without conflicting definitions:\n%+v\nwant\n%+v", *report, want)
+	}
+	if !merged.HasRule([]string{"p", "erin", "data5", "read", "allow"}) || !merged.HasRule([]string{"p2", "alice", "data1"}) {
+		t.Fatal("rules of both models are not merged")
+	}
+
+	_, report, err = Merge(a, mergeModel(t, mergeModelA, mergeRulesA), FailOnConflict)
+	if err != nil || report.HasConflicts() || len(report.Duplicates) != len(mergeRulesA) {
+		t.Fatalf("Merge of equal models: %+v %v, want only duplicates", report, err)
+	}
+}
@@ -15,8 +15,11 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
 
 	"github.com/go-ini/ini"
 	"github.com/oarkflow/govaluate"
@@ -31,11 +34,14 @@ import (
 	"github.com/oarkflow/fastac/model/policy"
 	"github.com/oarkflow/fastac/rbac"
 	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
 )
 
 const (
 	RULE_ADDED   = "rule_added"
 	RULE_REMOVED = "rule_removed"
+	// RULES_ADDED is emitted once by AddRules, the handler receives all added rules
+	RULES_ADDED = "rules_added"
 )
 
 const (
@@ -82,6 +88,15 @@ type Model struct {
 	secDefs    map[byte]*SectionDef
 	secNameMap map[string]byte
 
+	actionGroups map[string][]string
+	effects      defs.EffectVocabulary
+	profile      *Profile
+	route        *MatcherRoute
+
+	// writes hold the read lock of the fence, Snapshot holds the write lock
+	fence   sync.RWMutex
+	version uint64
+
 	fm *fm.FunctionMap
 	*em.Emitter
 }
@@ -97,6 +112,9 @@ func NewModel() *Model {
 	m.secDefs = make(map[byte]*SectionDef)
 	m.secNameMap = make(map[string]byte)
 	m.fm = fm.DefaultFunctionMap()
+	m.actionGroups = make(map[string][]string)
+	m.effects = make(defs.EffectVocabulary)
+	m.fm.SetFunction("actMatch", util.WrapMatchingFunc(m.ActMatch))
 
 	for _, sec := range sections {
 		m.secDefs[sec.keyPrefix] = sec
@@ -148,7 +166,22 @@ func (m *Model) LoadModelFromText(text string) error {
 }
 
 func (m *Model) loadModelFromConfig(cfg *ini.File) error {
+	var routes map[string]string
 	for _, sec := range cfg.Sections() {
+		switch sec.Name() {
+		case MATCHER_ROUTES:
+			// matchers have to be defined before the route is validated
+			routes = sec.KeysHash()
+			continue
+		case ACTION_GROUPS:
+			m.loadActionGroups(sec.KeysHash())
+			continue
+		case EFFECTS:
+			if err := m.loadEffects(sec.KeysHash()); err != nil {
+				return err
+			}
+			continue
+		}
 		secKey, ok := m.getSecKeyByName(sec.Name())
 		if !ok {
 			continue // ignore unknown section
@@ -161,6 +194,11 @@ func (m *Model) loadModelFromConfig(cfg *ini.File) error {
 			}
 		}
 	}
+	if routes != nil {
+		if err := m.loadMatcherRoute(routes); err != nil {
+			return err
+		}
+	}
 
 	return m.BuildMatchers()
 }
@@ -184,6 +222,15 @@ func (m *Model) GetDef(sec byte, key string) (defs.IDef, bool) {
 	return def, ok
 }
 
+// RangeDefs calls fn for every definition of a section
+func (m *Model) RangeDefs(sec byte, fn func(key string, def defs.IDef) bool) {
+	for key, def := range m.defs[sec] {
+		if !fn(key, def) {
+			break
+		}
+	}
+}
+
 func (m *Model) RemoveDef(sec byte, key string) error {
 	secDef, ok := m.getSecDefByKey(sec)
 	if !ok {
@@ -220,6 +267,9 @@ func (m *Model) BuildMatcher(key string) error {
 }
 
 func (m *Model) BuildMatcherFromDef(mDef *defs.MatcherDef) (matcher.IMatcher, error) {
+	if err := m.checkProfile(mDef); err != nil {
+		return nil, err
+	}
 	if err := mDef.Build(m.fm.GetFunctions()); err != nil {
 		return nil, err
 	}
@@ -252,14 +302,17 @@ func (m *Model) AddRule(rule []string) (bool, error) {
 	sec := key[0]
 	var added bool
 	var err error
+	m.fence.RLock()
 	switch sec {
 	case 'p':
 		added, err = m.addPolicyRule(key, rule[1:])
 	case 'g':
 		added, err = m.addRoleRule(key, rule[1:])
 	default:
+		m.fence.RUnlock()
 		return false, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
 	}
+	m.commit(added)
 	if added {
 		m.Emitter.EmitEvent(RULE_ADDED, rule)
 	}
@@ -271,20 +324,78 @@ func (m *Model) RemoveRule(rule []string) (bool, error) {
 	sec := key[0]
 	var removed bool
 	var err error
+	m.fence.RLock()
 	switch sec {
 	case 'p':
 		removed, err = m.removePolicyRule(key, rule[1:])
 	case 'g':
 		removed, err = m.removeRoleRule(key, rule[1:])
 	default:
+		m.fence.RUnlock()
 		return false, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
 	}
+	m.commit(removed)
 	if removed {
 		m.Emitter.EmitEvent(RULE_REMOVED, rule)
 	}
 	return removed, err
 }
 
+// AddRules adds multiple rules and emits a single RULES_ADDED event instead of one RULE_ADDED event per rule.
+// Returns the added rules, rules which are already present or duplicated in rules are skipped.
+func (m *Model) AddRules(rules [][]string) ([][]string, error) {
+	keys := []string{}
+	groups := make(map[string][][]string)
+	for _, rule := range rules {
+		key := rule[0]
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], rule[1:])
+	}
+
+	added := make([][]string, 0, len(rules))
+	var err error
+	m.fence.RLock()
+	for _, key := range keys {
+		var target policy.IPolicy
+		switch key[0] {
+		case 'p':
+			if p, ok := m.pMap[key]; ok {
+				target = p
+			} else {
+				err = fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
+			}
+		case 'g':
+			if rp, ok := m.rpMap[key]; ok {
+				target = rp
+			} else {
+				err = fmt.Errorf(str.ERR_RM_NOT_FOUND, key)
+			}
+		default:
+			err = fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
+		}
+		if err != nil {
+			break
+		}
+
+		var res [][]string
+		res, err = target.AddRules(groups[key])
+		for _, rule := range res {
+			added = append(added, append([]string{key}, rule...))
+		}
+		if err != nil {
+			break
+		}
+	}
+	m.commit(len(added) > 0)
+
+	if len(added) > 0 {
+		m.Emitter.EmitEvent(RULES_ADDED, added)
+	}
+	return added, err
+}
+
 func (m *Model) addPolicyRule(key string, rule []string) (bool, error) {
 	policy, ok := m.pMap[key]
 	if !ok {
@@ -330,7 +441,10 @@ func (m *Model) SetPolicy(key string, policy policy.IPolicy) {
 
 func (m *Model) GetRoleManager(key string) (rbac.IRoleManager, bool) {
 	rp, ok := m.rpMap[key]
-	return rp.GetRoleManager(), ok
+	if !ok {
+		return nil, false
+	}
+	return rp.GetRoleManager(), true
 }
 
 func (m *Model) SetRoleManager(key string, rm rbac.IRoleManager) {
@@ -372,6 +486,43 @@ func (m *Model) RangeMatches(matcher matcher.IMatcher, rDef *defs.RequestDef, rv
 	})
 }
 
+// RangeMatchesLocked is like RangeMatches, but calls fn while the matcher is locked, fn must not modify the policy
+func (m *Model) RangeMatchesLocked(matcher matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error {
+	policyKey := []string{matcher.GetPolicyKey()}
+	return matcher.RangeMatchesLocked(*rDef, rvals, *m.fm, func(rule []string) bool {
+		return fn(append(policyKey, rule...))
+	})
+}
+
+// RangeMatchesTraced is like RangeMatchesLocked, but reports the steps of the evaluation to tracer,
+// if the matcher implements matcher.ITracingMatcher
+func (m *Model) RangeMatchesTraced(mt matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, tracer matcher.Tracer, fn func(rule []string) bool) error {
+	traced, ok := mt.(matcher.ITracingMatcher)
+	if !ok || tracer == nil {
+		return m.RangeMatchesLocked(mt, rDef, rvals, fn)
+	}
+	policyKey := []string{mt.GetPolicyKey()}
+	return traced.RangeMatchesTraced(*rDef, rvals, *m.fm, tracer, func(rule []string) bool {
+		return fn(append(policyKey, rule...))
+	})
+}
+
+// RangeMatchesContext is like RangeMatchesTraced, but stops with the error of ctx, if ctx is canceled, tracer may be nil.
+// Matchers, which do not implement matcher.IContextMatcher, are only checked before the evaluation.
+func (m *Model) RangeMatchesContext(ctx context.Context, mt matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, tracer matcher.Tracer, fn func(rule []string) bool) error {
+	cm, ok := mt.(matcher.IContextMatcher)
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return m.RangeMatchesTraced(mt, rDef, rvals, tracer, fn)
+	}
+	policyKey := []string{mt.GetPolicyKey()}
+	return cm.RangeMatchesContext(ctx, *rDef, rvals, *m.fm, tracer, func(rule []string) bool {
+		return fn(append(policyKey, rule...))
+	})
+}
+
 func (m *Model) SetFunction(name string, function govaluate.ExpressionFunction) {
 	m.fm.SetFunction(name, function)
 }
@@ -380,6 +531,11 @@ func (m *Model) RemoveFunction(name string) bool {
 	return m.fm.RemoveFunction(name)
 }
 
+// GetFunctions returns all functions, which can be used in matchers
+func (m *Model) GetFunctions() map[string]govaluate.ExpressionFunction {
+	return m.fm.GetFunctions()
+}
+
 func (m *Model) String() string {
 	res := ""
 	for _, sec := range sections {
@@ -402,7 +558,7 @@ func (m *Model) String() string {
 
 		res += "\n"
 	}
-	return res
+	return res + m.actionGroupsString() + m.effectsString() + m.matcherRouteString()
 }
 
 func (m *Model) RangeRules(fn func(rule []string) bool) {
@@ -420,10 +576,59 @@ func (m *Model) RangeRules(fn func(rule []string) bool) {
 	}
 }
 
+// HasRule returns true, if the rule is present
+//
+//	m.HasRule([]string{"p", "alice", "data1", "read"})
+func (m *Model) HasRule(rule []string) bool {
+	if len(rule) == 0 {
+		return false
+	}
+	target, ok := m.GetPolicy(rule[0])
+	return ok && target.HasRule(rule[1:])
+}
+
+// GetRuleByHash returns the rule, whose util.Hash is key
+func (m *Model) GetRuleByHash(key string) ([]string, bool) {
+	keys := make([]string, 0, len(m.pMap)+len(m.rpMap))
+	for pKey := range m.pMap {
+		keys = append(keys, pKey)
+	}
+	for gKey := range m.rpMap {
+		keys = append(keys, gKey)
+	}
+
+	// the keys of the default strategies start with the policy type
+	for _, pKey := range keys {
+		prefix := pKey + util.DefaultSep
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		target, _ := m.GetPolicy(pKey)
+		if rule, ok := target.GetRule(key[len(prefix):]); ok {
+			rule = append([]string{pKey}, rule...)
+			if util.Hash(rule) == key {
+				return rule, true
+			}
+		}
+	}
+
+	var res []string
+	m.RangeRules(func(rule []string) bool {
+		if util.Hash(rule) == key {
+			res = rule
+			return false
+		}
+		return true
+	})
+	return res, res != nil
+}
+
 func (m *Model) ClearPolicy(pKey string) error {
 	p, ok := m.GetPolicy(pKey)
 	if !ok {
 		return fmt.Errorf(str.ERR_POLICY_NOT_FOUND, pKey)
 	}
+	m.fence.RLock()
+	defer m.commit(true)
 	return p.Clear()
 }
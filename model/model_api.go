@@ -15,14 +15,18 @@
 package model
 
 import (
+	"context"
+
 	"github.com/oarkflow/govaluate"
 
 	"github.com/oarkflow/fastac/api"
+	em "github.com/oarkflow/fastac/emitter"
 	"github.com/oarkflow/fastac/model/defs"
 	e "github.com/oarkflow/fastac/model/effector"
 	"github.com/oarkflow/fastac/model/matcher"
 	m "github.com/oarkflow/fastac/model/matcher"
 	p "github.com/oarkflow/fastac/model/policy"
+	"github.com/oarkflow/fastac/model/types"
 	"github.com/oarkflow/fastac/rbac"
 )
 
@@ -30,11 +34,18 @@ type IModel interface {
 	api.IAddRuleBool
 	api.IRemoveRuleBool
 	api.IRangeRules
+
+	AddRules(rules [][]string) ([][]string, error)
+	HasRule(rule []string) bool
+	GetRuleByHash(key string) ([]string, bool)
 	api.IAddRemoveListener
+	AddRuleListener(event em.EventType, filter RuleFilter, handler em.HandleFunc) *em.Listener
+	MatchRule(filter RuleFilter, rule []string) bool
 
 	GetDef(sec byte, key string) (defs.IDef, bool)
 	SetDef(sec byte, key string, value string) error
 	RemoveDef(sec byte, key string) error
+	RangeDefs(sec byte, fn func(key string, def defs.IDef) bool)
 
 	GetRoleManager(key string) (rbac.IRoleManager, bool)
 	SetRoleManager(key string, rm rbac.IRoleManager)
@@ -53,12 +64,43 @@ type IModel interface {
 
 	ClearPolicy(key string) error
 
+	SetActionGroup(name string, actions ...string)
+	RemoveActionGroup(name string) bool
+	GetActionGroup(name string) ([]string, bool)
+
+	SetEffect(name string, effect types.Effect)
+	RemoveEffect(name string) bool
+	GetEffectVocabulary() defs.EffectVocabulary
+
 	SetFunction(name string, function govaluate.ExpressionFunction)
 	RemoveFunction(name string) bool
+	GetFunctions() map[string]govaluate.ExpressionFunction
+
+	SetMatcherRoute(route *MatcherRoute) error
+	GetMatcherRoute() *MatcherRoute
 
+	SetProfile(profile *Profile) error
+	GetProfile() *Profile
+
+	BuildMatchers() error
 	BuildMatcherFromDef(mDef *defs.MatcherDef) (matcher.IMatcher, error)
 
 	RangeMatches(matcher matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error
+	RangeMatchesLocked(matcher matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, fn func(rule []string) bool) error
+	RangeMatchesTraced(matcher matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, tracer matcher.Tracer, fn func(rule []string) bool) error
+	RangeMatchesContext(ctx context.Context, matcher matcher.IMatcher, rDef *defs.RequestDef, rvals []interface{}, tracer matcher.Tracer, fn func(rule []string) bool) error
+
+	Version() uint64
+	Snapshot() *Snapshot
 
 	String() string
 }
+
+// IDescriber is implemented by models, which can describe their definitions, see Model.Describe
+//
+//	if d, ok := model.(IDescriber); ok {
+//		data, _ := json.Marshal(d.Describe())
+//	}
+type IDescriber interface {
+	Describe() *Description
+}
@@ -15,6 +15,8 @@
 package policy
 
 import (
+	"sync"
+
 	em "github.com/oarkflow/fastac/emitter"
 
 	"github.com/oarkflow/fastac/model/defs"
@@ -22,6 +24,7 @@ import (
 )
 
 type Policy struct {
+	mutex   sync.RWMutex
 	ruleMap map[string][]string
 
 	*em.Emitter
@@ -38,26 +41,75 @@ func NewPolicy(pDef *defs.PolicyDef) *Policy {
 
 func (p *Policy) AddRule(rule []string) (bool, error) {
 	key := util.Hash(rule)
+	p.mutex.Lock()
 	if _, ok := p.ruleMap[key]; ok {
+		p.mutex.Unlock()
 		return false, nil
 	}
 	p.ruleMap[key] = rule
+	p.mutex.Unlock()
 	p.Emitter.EmitEvent(EVT_RULE_ADDED, rule)
 	return true, nil
 }
 
+// AddRules adds multiple rules and emits a single EVT_RULES_ADDED event.
+// Returns the added rules, duplicates and rules which are already present are skipped.
+func (p *Policy) AddRules(rules [][]string) ([][]string, error) {
+	added := make([][]string, 0, len(rules))
+
+	p.mutex.Lock()
+	if len(p.ruleMap) == 0 {
+		p.ruleMap = make(map[string][]string, len(rules))
+	}
+	for _, rule := range rules {
+		key := util.Hash(rule)
+		if _, ok := p.ruleMap[key]; ok {
+			continue
+		}
+		p.ruleMap[key] = rule
+		added = append(added, rule)
+	}
+	p.mutex.Unlock()
+
+	if len(added) > 0 {
+		p.Emitter.EmitEvent(EVT_RULES_ADDED, added)
+	}
+	return added, nil
+}
+
 func (p *Policy) RemoveRule(rule []string) (bool, error) {
 	key := util.Hash(rule)
+	p.mutex.Lock()
 	_, ok := p.ruleMap[key]
 	if !ok {
+		p.mutex.Unlock()
 		return false, nil
 	}
 	delete(p.ruleMap, key)
+	p.mutex.Unlock()
 	p.Emitter.EmitEvent(EVT_RULE_REMOVED, rule)
 	return true, nil
 }
 
+// HasRule returns true, if the rule is present
+func (p *Policy) HasRule(rule []string) bool {
+	_, ok := p.GetRule(util.Hash(rule))
+	return ok
+}
+
+// GetRule returns the rule with the given key, see util.Hash
+func (p *Policy) GetRule(key string) ([]string, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	rule, ok := p.ruleMap[key]
+	return rule, ok
+}
+
+// Range calls fn for every rule, while the policy is locked for writing.
+// Concurrent writes wait until Range returns, so fn must not modify the policy, collect the rules to change instead.
 func (p *Policy) Range(fn func(rule []string) bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 	for _, r := range p.ruleMap {
 		if !fn(r) {
 			break
@@ -70,7 +122,9 @@ func (p *Policy) GetDistinct(columns []int) ([][]string, error) {
 }
 
 func (p *Policy) Clear() error {
+	p.mutex.Lock()
 	p.ruleMap = make(map[string][]string)
+	p.mutex.Unlock()
 	p.Emitter.EmitEvent(EVT_CLEARED)
 	return nil
 }
@@ -11,6 +11,8 @@ const (
 	EVT_RULE_ADDED   em.EventType = "rule_added"
 	EVT_RULE_REMOVED em.EventType = "rule_removed"
 	EVT_CLEARED      em.EventType = "cleared"
+	// EVT_RULES_ADDED is emitted once by AddRules with all added rules
+	EVT_RULES_ADDED em.EventType = "rules_added"
 )
 
 type IPolicy interface {
@@ -19,6 +21,9 @@ type IPolicy interface {
 	api.IAddRemoveListener
 	api.IClear
 
+	AddRules(rules [][]string) ([][]string, error)
+	HasRule(rule []string) bool
+	GetRule(key string) ([]string, bool)
 	Range(fn func(rule []string) bool)
 }
 
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/oarkflow/govaluate"
+
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/str"
+)
+
+// Profile restricts the functions and operators, which matchers may use.
+// It is checked when matchers are built, so models authored by tenants can be loaded safely:
+//
+//	m := model.NewModel()
+//	m.SetProfile(model.UntrustedProfile)
+//	err := m.LoadModelFromText(tenantModel)
+type Profile struct {
+	// Functions lists the allowed functions, all functions are allowed if it is nil.
+	// The role functions of the model (g, g2, ...) are always allowed.
+	Functions []string
+	// DeniedFunctions lists functions, which must not be used
+	DeniedFunctions []string
+	// DeniedOperators lists operators, which must not be used, e.g. "=~"
+	DeniedOperators []string
+	// MaxTokens is the maximum number of tokens of a matcher, 0 disables the limit
+	MaxTokens int
+}
+
+// UntrustedProfile denies functions, which evaluate expressions or regular expressions supplied by policies
+var UntrustedProfile = &Profile{
+	DeniedFunctions: []string{"eval", "regexMatch"},
+	DeniedOperators: []string{"=~", "!~"},
+	MaxTokens:       256,
+}
+
+// SetProfile sets the expression profile and checks all matchers against it, nil removes the profile
+func (m *Model) SetProfile(profile *Profile) error {
+	m.profile = profile
+	for _, def := range m.defs[M_SEC] {
+		if err := m.checkProfile(def.(*defs.MatcherDef)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetProfile returns the expression profile or nil
+func (m *Model) GetProfile() *Profile {
+	return m.profile
+}
+
+func (m *Model) checkProfile(mDef *defs.MatcherDef) error {
+	if m.profile == nil {
+		return nil
+	}
+	expr := defs.ArgReg.ReplaceAllString(mDef.Expr(), "${1}_${3}")
+	parsed, err := govaluate.NewEvaluableExpressionWithFunctions(expr, m.fm.GetFunctions())
+	if err != nil {
+		return err
+	}
+	return m.profile.check(mDef.GetKey(), parsed.Tokens(), m.defs[G_SEC])
+}
+
+func (p *Profile) check(key string, tokens []govaluate.ExpressionToken, roles map[string]defs.IDef) error {
+	if p.MaxTokens > 0 && len(tokens) > p.MaxTokens {
+		return fmt.Errorf(str.ERR_PROFILE_VIOLATION, key, fmt.Sprintf("%d tokens", len(tokens)))
+	}
+	for _, token := range tokens {
+		switch token.Kind {
+		case govaluate.FUNCTION:
+			name, _ := token.Value2.(string)
+			if contains(p.DeniedFunctions, name) {
+				return fmt.Errorf(str.ERR_PROFILE_VIOLATION, key, "function "+name)
+			}
+			if _, isRole := roles[name]; p.Functions != nil && !isRole && !contains(p.Functions, name) {
+				return fmt.Errorf(str.ERR_PROFILE_VIOLATION, key, "function "+name)
+			}
+		case govaluate.COMPARATOR, govaluate.LOGICALOP, govaluate.MODIFIER, govaluate.PREFIX, govaluate.TERNARY:
+			op := fmt.Sprintf("%v", token.Value)
+			if contains(p.DeniedOperators, op) {
+				return fmt.Errorf(str.ERR_PROFILE_VIOLATION, key, "operator "+op)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
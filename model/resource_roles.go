@@ -0,0 +1,20 @@
+package model
+
+import "strings"
+
+// RESOURCE_ROLE is the key of the role definition, which binds roles to resource instances.
+// Its rules have the form "gObj, user, role, resource", e.g. "gObj, alice, editor, doc:123".
+// Matchers can use it without declaring it in the role_definition section:
+//
+//	m = gObj(r.sub, p.sub, r.obj) && r.act == p.act
+const RESOURCE_ROLE = "gObj"
+
+// addResourceRoleDef declares the resource role definition, if matcher uses it and it was not declared
+func addResourceRoleDef(m *Model, matcher string) {
+	if !strings.Contains(matcher, RESOURCE_ROLE+"(") {
+		return
+	}
+	if _, ok := m.defs[G_SEC][RESOURCE_ROLE]; !ok {
+		_ = addRoleDef(m, RESOURCE_ROLE, "_, _, _")
+	}
+}
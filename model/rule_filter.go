@@ -0,0 +1,111 @@
+package model
+
+import (
+	em "github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/model/defs"
+)
+
+// RuleFilter selects the rules of rule events, empty fields match every rule
+//
+//	// role assignments of tenant1
+//	RuleFilter{PType: "g", Domain: "tenant1"}
+//
+//	// policy rules of alice
+//	RuleFilter{PType: "p", Prefix: []string{"alice"}}
+type RuleFilter struct {
+	// PType is the policy type of the rules, e.g. "g"
+	PType string
+	// Prefix contains the first values of the rules after the policy type, an empty value matches every value
+	Prefix []string
+	// Domain is the third value of role rules and the value of the argument dom or domain of policy rules.
+	// Rules without domain do not match a filter with domain.
+	Domain string
+}
+
+// MatchRule returns true, if the rule is selected by the filter
+func (m *Model) MatchRule(filter RuleFilter, rule []string) bool {
+	return m.ruleMatcher(filter)(rule)
+}
+
+// ruleMatcher returns a function, which selects the rules matching the filter.
+// The domain index of the policy type of the filter is resolved once, so listeners do not look up the definition per event.
+func (m *Model) ruleMatcher(filter RuleFilter) func(rule []string) bool {
+	index, indexed := 0, false
+	if filter.Domain != "" && filter.PType != "" {
+		index, indexed = m.domainIndex(filter.PType)
+	}
+	return func(rule []string) bool {
+		if len(rule) == 0 || (filter.PType != "" && rule[0] != filter.PType) {
+			return false
+		}
+		for i, value := range filter.Prefix {
+			if value == "" {
+				continue
+			}
+			if i+1 >= len(rule) || rule[i+1] != value {
+				return false
+			}
+		}
+		if filter.Domain == "" {
+			return true
+		}
+		index, ok := index, indexed
+		if filter.PType == "" {
+			index, ok = m.domainIndex(rule[0])
+		}
+		return ok && index < len(rule) && rule[index] == filter.Domain
+	}
+}
+
+// domainIndex returns the index of the domain in the rules of a policy type
+func (m *Model) domainIndex(key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+	def, ok := m.GetDef(key[0], key)
+	if !ok {
+		return 0, false
+	}
+	switch def := def.(type) {
+	case *defs.RoleDef:
+		return 3, def.NArgs() >= 3
+	case *defs.PolicyDef:
+		for i, arg := range def.GetArgs() {
+			if arg == "dom" || arg == "domain" {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// AddRuleListener adds a listener of RULE_ADDED, RULES_ADDED or RULE_REMOVED, which is only called for the rules matching the filter.
+// A listener of RULES_ADDED receives only the matching rules, so the listener of a tenant is not called for the changes of other tenants.
+//
+//	m.AddRuleListener(RULES_ADDED, RuleFilter{PType: "g", Domain: "tenant1"}, func(arguments ...interface{}) {
+//		rules := arguments[0].([][]string)
+//	})
+func (m *Model) AddRuleListener(event em.EventType, filter RuleFilter, handler em.HandleFunc) *em.Listener {
+	match := m.ruleMatcher(filter)
+	if event != RULES_ADDED {
+		return m.AddFilteredListener(event, func(arguments ...interface{}) bool {
+			rule, ok := arguments[0].([]string)
+			return ok && match(rule)
+		}, handler)
+	}
+	return m.AddListener(event, func(arguments ...interface{}) {
+		rules, ok := arguments[0].([][]string)
+		if !ok {
+			return
+		}
+		matched := [][]string{}
+		for _, rule := range rules {
+			if match(rule) {
+				matched = append(matched, rule)
+			}
+		}
+		if len(matched) > 0 {
+			handler(matched)
+		}
+	})
+}
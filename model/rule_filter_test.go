@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+)
+
+const benchModel = `
+[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act
+`
+
+// benchRuleListeners adds a listener of RULE_ADDED per tenant, filter selects the rules of a tenant or nil for unfiltered listeners
+func benchRuleListeners(b *testing.B, tenants int, filter func(tenant string) *RuleFilter) *Model {
+	m := NewModel()
+	if err := m.LoadModelFromText(benchModel); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < tenants; i++ {
+		tenant := fmt.Sprintf("tenant%d", i)
+		if f := filter(tenant); f != nil {
+			m.AddRuleListener(RULE_ADDED, *f, func(arguments ...interface{}) {})
+		} else {
+			m.AddListener(RULE_ADDED, func(arguments ...interface{}) {
+				if rule := arguments[0].([]string); len(rule) < 4 || rule[3] != tenant {
+					return
+				}
+			})
+		}
+	}
+	return m
+}
+
+// BenchmarkRuleListeners dispatches a role rule to 100 listeners, which check the tenant themselves
+func BenchmarkRuleListeners(b *testing.B) {
+	m := benchRuleListeners(b, 100, func(tenant string) *RuleFilter { return nil })
+	rule := []string{"g", "alice", "admin", "tenant1"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.EmitEvent(RULE_ADDED, rule)
+	}
+}
+
+// BenchmarkRuleListenersFiltered dispatches a role rule to 100 listeners with a RuleFilter of their tenant
+func BenchmarkRuleListenersFiltered(b *testing.B) {
+	m := benchRuleListeners(b, 100, func(tenant string) *RuleFilter {
+		return &RuleFilter{PType: "g", Domain: tenant}
+	})
+	rule := []string{"g", "alice", "admin", "tenant1"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.EmitEvent(RULE_ADDED, rule)
+	}
+}
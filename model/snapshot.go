@@ -0,0 +1,46 @@
+package model
+
+import "sync/atomic"
+
+// Snapshot is a copy of all rules of a model at a single point in time
+type Snapshot struct {
+	// Version is the version of the model, when the snapshot was taken
+	Version uint64
+	Rules   [][]string
+}
+
+// RangeRules calls fn for every rule of the snapshot, so it can be passed to Adapter.SavePolicy
+func (s *Snapshot) RangeRules(fn func(rule []string) bool) {
+	for _, rule := range s.Rules {
+		if !fn(rule) {
+			return
+		}
+	}
+}
+
+// Version returns the number of changes applied to the rules of the model
+func (m *Model) Version() uint64 {
+	return atomic.LoadUint64(&m.version)
+}
+
+// Snapshot copies all rules consistently.
+// Writes, which are in progress, are completed before and new writes wait until the rules are copied.
+func (m *Model) Snapshot() *Snapshot {
+	m.fence.Lock()
+	defer m.fence.Unlock()
+
+	s := &Snapshot{Version: m.Version()}
+	m.RangeRules(func(rule []string) bool {
+		s.Rules = append(s.Rules, append([]string(nil), rule...))
+		return true
+	})
+	return s
+}
+
+// commit increments the version, if the rules were changed, and releases the fence
+func (m *Model) commit(changed bool) {
+	if changed {
+		atomic.AddUint64(&m.version, 1)
+	}
+	m.fence.RUnlock()
+}
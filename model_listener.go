@@ -0,0 +1,53 @@
+package fastac
+
+import (
+	"github.com/oarkflow/fastac/emitter"
+	m "github.com/oarkflow/fastac/model"
+)
+
+// ModelListener is a listener of the events of the model of an enforcer, see AddModelListener
+type ModelListener struct {
+	event    emitter.EventType
+	handler  emitter.HandleFunc
+	listener *emitter.Listener
+}
+
+// AddModelListener adds a listener to the model of the enforcer. Unlike a listener added to the model itself,
+// it is moved to the new model by SetModel, e.g. after PromoteCanary, the first change of a fork or a reload of OptionWatchFiles.
+//
+//	l := e.AddModelListener(model.RULE_ADDED, func(arguments ...interface{}) {
+//		log.Println("added", arguments[0])
+//	})
+//	defer e.RemoveModelListener(l)
+func (e *Enforcer) AddModelListener(event emitter.EventType, handler emitter.HandleFunc) *ModelListener {
+	e.listenerMutex.Lock()
+	defer e.listenerMutex.Unlock()
+	l := &ModelListener{event: event, handler: handler, listener: e.model.AddListener(event, handler)}
+	e.modelListeners = append(e.modelListeners, l)
+	return l
+}
+
+// RemoveModelListener removes a listener added by AddModelListener
+func (e *Enforcer) RemoveModelListener(l *ModelListener) {
+	e.listenerMutex.Lock()
+	defer e.listenerMutex.Unlock()
+	for i, listener := range e.modelListeners {
+		if listener == l {
+			e.model.RemoveListener(l.event, l.listener)
+			e.modelListeners = append(e.modelListeners[:i:i], e.modelListeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// moveModelListeners moves the listeners added by AddModelListener from the model old to the model of the enforcer
+func (e *Enforcer) moveModelListeners(old m.IModel) {
+	e.listenerMutex.Lock()
+	defer e.listenerMutex.Unlock()
+	for _, l := range e.modelListeners {
+		if old != nil {
+			old.RemoveListener(l.event, l.listener)
+		}
+		l.listener = e.model.AddListener(l.event, l.handler)
+	}
+}
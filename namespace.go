@@ -0,0 +1,160 @@
+package fastac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/types"
+)
+
+// Precedence defines how the decisions of nested namespaces are merged
+type Precedence int
+
+const (
+	// MostSpecific uses the decision of the deepest namespace, which has matching rules
+	MostSpecific Precedence = iota
+	// LeastSpecific uses the decision of the outermost namespace, which has matching rules
+	LeastSpecific
+	// DenyOverrides denies, if any namespace denies
+	DenyOverrides
+)
+
+// NamespacedEnforcer mounts enforcers under namespaces, e.g. /org/teamA.
+// A namespace applies to a request, if it is a path prefix of the request object.
+// Requests are passed unchanged to the enforcers of all applicable namespaces,
+// namespaces without matching rules do not take part in the decision.
+// If no namespace decides, the request is denied.
+type NamespacedEnforcer struct {
+	mounts     map[string]*Enforcer
+	objIndex   int
+	sep        string
+	precedence Precedence
+}
+
+// NewNamespacedEnforcer creates an enforcer without mounts.
+// objIndex is the index of the request value, which contains the object path.
+//
+//	ne := NewNamespacedEnforcer(1, MostSpecific)
+//	ne.Mount("/org", orgEnforcer)
+//	ne.Mount("/org/teamA", teamEnforcer)
+//	ne.Enforce("alice", "/org/teamA/repo1", "read")
+func NewNamespacedEnforcer(objIndex int, precedence Precedence) *NamespacedEnforcer {
+	return &NamespacedEnforcer{
+		mounts:     make(map[string]*Enforcer),
+		objIndex:   objIndex,
+		sep:        "/",
+		precedence: precedence,
+	}
+}
+
+// SetSeperator sets the path seperator, default: "/"
+func (ne *NamespacedEnforcer) SetSeperator(sep string) {
+	ne.sep = sep
+}
+
+func (ne *NamespacedEnforcer) clean(namespace string) string {
+	return strings.TrimSuffix(namespace, ne.sep)
+}
+
+// Mount mounts the enforcer under the namespace and replaces an existing mount
+func (ne *NamespacedEnforcer) Mount(namespace string, e *Enforcer) {
+	ne.mounts[ne.clean(namespace)] = e
+}
+
+// Unmount removes the enforcer of a namespace
+func (ne *NamespacedEnforcer) Unmount(namespace string) bool {
+	namespace = ne.clean(namespace)
+	_, ok := ne.mounts[namespace]
+	delete(ne.mounts, namespace)
+	return ok
+}
+
+// GetEnforcer returns the enforcer mounted under the namespace
+func (ne *NamespacedEnforcer) GetEnforcer(namespace string) (*Enforcer, bool) {
+	e, ok := ne.mounts[ne.clean(namespace)]
+	return e, ok
+}
+
+// Namespaces returns the namespaces, which apply to obj, ordered from the most specific namespace
+func (ne *NamespacedEnforcer) Namespaces(obj string) []string {
+	res := []string{}
+	for namespace := range ne.mounts {
+		if namespace == "" || obj == namespace || strings.HasPrefix(obj, namespace+ne.sep) {
+			res = append(res, namespace)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return len(res[i]) > len(res[j])
+	})
+	return res
+}
+
+// Enforce decides whether to allow or deny a request
+// It is possible to pass ContextOptions, they are applied by every mounted enforcer
+func (ne *NamespacedEnforcer) Enforce(params ...interface{}) (bool, error) {
+	rvals := []interface{}{}
+	for _, param := range params {
+		if _, ok := param.(ContextOption); !ok {
+			rvals = append(rvals, param)
+		}
+	}
+	if ne.objIndex >= len(rvals) {
+		return false, errors.New("error: request has no object")
+	}
+	obj, ok := rvals[ne.objIndex].(string)
+	if !ok {
+		return false, fmt.Errorf("error: object %v is not a path", rvals[ne.objIndex])
+	}
+
+	namespaces := ne.Namespaces(obj)
+	if ne.precedence == LeastSpecific {
+		for i, j := 0, len(namespaces)-1; i < j; i, j = i+1, j-1 {
+			namespaces[i], namespaces[j] = namespaces[j], namespaces[i]
+		}
+	}
+
+	res := eft.Indeterminate
+	for _, namespace := range namespaces {
+		effect, err := ne.enforceNamespace(namespace, params)
+		if err != nil {
+			return false, fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		if effect == eft.Indeterminate {
+			continue
+		}
+		if ne.precedence != DenyOverrides {
+			return effect == eft.Allow, nil
+		}
+		if effect == eft.Deny {
+			return false, nil
+		}
+		res = effect
+	}
+	return res == eft.Allow, nil
+}
+
+// enforceNamespace returns Indeterminate, if no rule of the namespace matches
+func (ne *NamespacedEnforcer) enforceNamespace(namespace string, params []interface{}) (types.Effect, error) {
+	e := ne.mounts[namespace]
+	ctx, rvals, err := e.splitParams(params...)
+	if err != nil {
+		return eft.Deny, err
+	}
+	if rvals, err = e.prepare(ctx, rvals); err != nil {
+		return eft.Deny, err
+	}
+	if ctx, err = ctx.routed(rvals); err != nil {
+		return eft.Deny, err
+	}
+	effect, _, matches, err := e.enforceEffect(ctx, rvals)
+	if err != nil {
+		return eft.Deny, err
+	}
+	if len(matches) == 0 {
+		return eft.Indeterminate, nil
+	}
+	return effect, nil
+}
@@ -0,0 +1,134 @@
+package fastac
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Page selects a range of a sorted result, a Limit of 0 returns all remaining entries
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// GetAllowedObjects returns the sorted concrete objects of the policy "p", on which sub may perform act.
+// The objects are checked with Enforce, so roles and all other parts of the matcher apply.
+// objectPattern is a glob pattern like "doc:*", which restricts the candidates, "" selects all objects.
+// Objects, which are patterns, are replaced by their values in the vocabulary or skipped without vocabulary.
+//
+//	e.GetAllowedObjects("alice", "read", "doc:*", Page{Offset: 0, Limit: 50})
+func (e *Enforcer) GetAllowedObjects(sub, act, objectPattern string, page ...Page) ([]string, error) {
+	def, ok := e.model.GetDef(m.P_SEC, "p")
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, "p")
+	}
+	rDef, ok := e.model.GetRequestDef("r")
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_REQUESTDEF_NOT_FOUND, "r")
+	}
+	objIndex := -1
+	for i, arg := range def.(*defs.PolicyDef).GetArgs() {
+		if arg == "obj" {
+			objIndex = i + 1
+		}
+	}
+	if objIndex < 0 {
+		return nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, "p.obj")
+	}
+
+	rules, err := e.GetExpandedPolicy("p")
+	if err != nil {
+		return nil, err
+	}
+	matchers := []util.IMatcher{util.WildcardMatcher}
+	if e.vocab != nil {
+		matchers = e.vocab.Matchers()
+	}
+
+	seen := make(map[string]struct{})
+	candidates := []string{}
+	for _, rule := range rules {
+		if objIndex >= len(rule) {
+			continue
+		}
+		obj := rule[objIndex]
+		if _, ok := seen[obj]; ok {
+			continue
+		}
+		seen[obj] = struct{}{}
+		if isPattern(obj, matchers) {
+			continue
+		}
+		if objectPattern != "" {
+			if ok, err := path.Match(objectPattern, obj); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		candidates = append(candidates, obj)
+	}
+	sort.Strings(candidates)
+
+	p := Page{}
+	if len(page) > 0 {
+		p = page[0]
+	}
+	res := []string{}
+	skipped := 0
+	for _, obj := range candidates {
+		if p.Limit > 0 && len(res) >= p.Limit {
+			break
+		}
+		rvals, err := objectRequest(rDef.GetArgs(), sub, obj, act)
+		if err != nil {
+			return nil, err
+		}
+		allowed, err := e.Enforce(rvals...)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+		if skipped < p.Offset {
+			skipped++
+			continue
+		}
+		res = append(res, obj)
+	}
+	return res, nil
+}
+
+func isPattern(value string, matchers []util.IMatcher) bool {
+	for _, matcher := range matchers {
+		if matcher.IsPattern(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectRequest orders sub, obj and act by the arguments of the request definition
+func objectRequest(args []string, sub, obj, act string) ([]interface{}, error) {
+	rvals := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch arg {
+		case "sub":
+			rvals[i] = sub
+		case "obj":
+			rvals[i] = obj
+		case "act":
+			rvals[i] = act
+		default:
+			return nil, fmt.Errorf(str.ERR_REQUESTDEF_NOT_FOUND, "r."+arg)
+		}
+	}
+	return rvals, nil
+}
@@ -0,0 +1,284 @@
+package pathmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SegmentMatcher matches a single segment of a path against a custom segment kind
+type SegmentMatcher interface {
+	Match(s string) bool
+}
+
+// SegmentMatcherFunc adapts a function to the SegmentMatcher interface
+type SegmentMatcherFunc func(s string) bool
+
+func (fn SegmentMatcherFunc) Match(s string) bool {
+	return fn(s)
+}
+
+// SegmentParser is a SegmentMatcher, which converts the matched segment to a typed value for FindTypedSubmatch
+type SegmentParser interface {
+	SegmentMatcher
+	Parse(s string) (interface{}, error)
+}
+
+// SegmentParserFunc adapts a conversion function to the SegmentParser interface, a segment matches, if it is converted without error
+type SegmentParserFunc func(s string) (interface{}, error)
+
+func (fn SegmentParserFunc) Match(s string) bool {
+	_, err := fn(s)
+	return err == nil
+}
+
+func (fn SegmentParserFunc) Parse(s string) (interface{}, error) {
+	return fn(s)
+}
+
+// SegmentFactory compiles the argument of a custom segment, arg is empty, if the segment has no argument
+type SegmentFactory func(arg string) (SegmentMatcher, error)
+
+var kindReg = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+var segmentKinds = struct {
+	sync.RWMutex
+	factories map[string]SegmentFactory
+}{factories: map[string]SegmentFactory{
+	"uuid": uuidSegment,
+	"date": dateSegment,
+	"int":  intSegment,
+	"bool": boolSegment,
+}}
+
+// RegisterSegment registers a custom segment kind, which is used by Compile for segments written as <kind> or <kind:arg>.
+// A custom segment can be named by a preceding parameter, e.g. :day<date:2024-01-01..2024-12-31>, otherwise
+// its value is captured as $0, $1, ... like wildcards. Segments of unknown kinds are static segments.
+// The built-in kinds are uuid, date, int and bool.
+//
+//	pathmatch.RegisterSegment("int", func(arg string) (pathmatch.SegmentMatcher, error) {
+//		return pathmatch.SegmentMatcherFunc(func(s string) bool {
+//			_, err := strconv.Atoi(s)
+//			return err == nil
+//		}), nil
+//	})
+//	p, _ := pathmatch.Compile("/orders/:id<int>")
+func RegisterSegment(kind string, factory SegmentFactory) error {
+	if !kindReg.MatchString(kind) {
+		return fmt.Errorf("pathmatch: invalid segment kind %q", kind)
+	}
+	segmentKinds.Lock()
+	defer segmentKinds.Unlock()
+	segmentKinds.factories[kind] = factory
+	return nil
+}
+
+// UnregisterSegment removes a custom segment kind, paths compiled before keep using it
+func UnregisterSegment(kind string) bool {
+	segmentKinds.Lock()
+	defer segmentKinds.Unlock()
+	_, ok := segmentKinds.factories[kind]
+	delete(segmentKinds.factories, kind)
+	return ok
+}
+
+func segmentFactory(kind string) (SegmentFactory, bool) {
+	segmentKinds.RLock()
+	defer segmentKinds.RUnlock()
+	factory, ok := segmentKinds.factories[kind]
+	return factory, ok
+}
+
+// parseCustom returns the name, kind and argument of a custom segment, name is empty for unnamed segments
+func (p *Path) parseCustom(strSeg string) (name, kind, arg string, ok bool) {
+	start := strings.Index(strSeg, "<")
+	if start == -1 || !strings.HasSuffix(strSeg, ">") {
+		return "", "", "", false
+	}
+	kind, arg, _ = strings.Cut(strSeg[start+1:len(strSeg)-1], ":")
+	if _, ok := segmentFactory(kind); !ok {
+		return "", "", "", false
+	}
+	if head := strSeg[:start]; head != "" {
+		if !strings.HasPrefix(head, p.Prefix) || !strings.HasSuffix(head, p.Suffix) {
+			return "", "", "", false
+		}
+		name = head[len(p.Prefix) : len(head)-len(p.Suffix)]
+		if name == "" || except.FindString(name) != name {
+			return "", "", "", false
+		}
+	}
+	return name, kind, arg, true
+}
+
+type customSegment struct {
+	key        string
+	matcher    SegmentMatcher
+	equalCheck bool
+}
+
+func newCustomSegment(key, kind, arg string, equalCheck bool) (*customSegment, error) {
+	factory, _ := segmentFactory(kind)
+	matcher, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("pathmatch: <%s:%s>, %w", kind, arg, err)
+	}
+	return &customSegment{key, matcher, equalCheck}, nil
+}
+
+func (seg *customSegment) Type() SegType {
+	return Custom
+}
+
+func (seg *customSegment) Match(m *matchDraft, s string) *matchDraft {
+	if !seg.matcher.Match(s) {
+		return nil
+	}
+	if value, ok := m.match[seg.key]; seg.equalCheck && ok && s != value {
+		return nil
+	}
+	m.set(seg.key, s)
+	return m
+}
+
+func (seg *customSegment) Multiple() bool {
+	return false
+}
+
+var uuidReg = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// uuidSegment matches UUIDs in the canonical form
+func uuidSegment(arg string) (SegmentMatcher, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("uuid has no argument")
+	}
+	return SegmentMatcherFunc(uuidReg.MatchString), nil
+}
+
+// DateLayout is the layout of the values and bounds of date segments
+const DateLayout = "2006-01-02"
+
+// intSegment matches decimal integers, which are captured as int64 by FindTypedSubmatch,
+// the argument is an inclusive range "min..max", either bound may be omitted
+func intSegment(arg string) (SegmentMatcher, error) {
+	var min, max int64
+	hasMin, hasMax := false, false
+	if arg != "" {
+		lower, upper, ok := strings.Cut(arg, "..")
+		if !ok {
+			return nil, fmt.Errorf("int range must be min..max")
+		}
+		var err error
+		if lower != "" {
+			if min, err = strconv.ParseInt(lower, 10, 64); err != nil {
+				return nil, err
+			}
+			hasMin = true
+		}
+		if upper != "" {
+			if max, err = strconv.ParseInt(upper, 10, 64); err != nil {
+				return nil, err
+			}
+			hasMax = true
+		}
+	}
+	return SegmentParserFunc(func(s string) (interface{}, error) {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if (hasMin && i < min) || (hasMax && i > max) {
+			return nil, fmt.Errorf("%d out of range %s", i, arg)
+		}
+		return i, nil
+	}), nil
+}
+
+// boolSegment matches true and false, which are captured as bool by FindTypedSubmatch
+func boolSegment(arg string) (SegmentMatcher, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("bool has no argument")
+	}
+	return SegmentParserFunc(func(s string) (interface{}, error) {
+		switch s {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a bool", s)
+	}), nil
+}
+
+// dateSegment matches dates, which are captured as time.Time by FindTypedSubmatch,
+// the argument is an inclusive range "from..to", either bound may be omitted
+func dateSegment(arg string) (SegmentMatcher, error) {
+	var from, to time.Time
+	if arg != "" {
+		lower, upper, ok := strings.Cut(arg, "..")
+		if !ok {
+			return nil, fmt.Errorf("date range must be from..to")
+		}
+		var err error
+		if lower != "" {
+			if from, err = time.Parse(DateLayout, lower); err != nil {
+				return nil, err
+			}
+		}
+		if upper != "" {
+			if to, err = time.Parse(DateLayout, upper); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return SegmentParserFunc(func(s string) (interface{}, error) {
+		date, err := time.Parse(DateLayout, s)
+		if err != nil {
+			return nil, err
+		}
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			return nil, fmt.Errorf("%s out of range %s", s, arg)
+		}
+		return date, nil
+	}), nil
+}
+
+// TypedMatch contains the values of the parameterized segments,
+// the values of custom segments implementing SegmentParser are converted, all others are strings
+type TypedMatch map[string]interface{}
+
+// FindTypedSubmatch is like FindSubmatch, but converts the values of typed custom segments,
+// e.g. int to int64, bool to bool and date to time.Time. A segment, whose value does not match its type, fails the match.
+//
+//	p, _ := Compile("/orders/:id<int>/paid/:paid<bool>")
+//	p.FindTypedSubmatch("/orders/42/paid/true") // {"id": int64(42), "paid": true}
+//	p.FindTypedSubmatch("/orders/x/paid/true")  // nil
+func (p *Path) FindTypedSubmatch(s string) TypedMatch {
+	match := p.FindSubmatch(s)
+	if match == nil {
+		return nil
+	}
+	res := make(TypedMatch, len(match))
+	for key, value := range match {
+		res[key] = value
+	}
+	for _, seg := range p.Segments {
+		custom, ok := seg.(*customSegment)
+		if !ok {
+			continue
+		}
+		parser, ok := custom.matcher.(SegmentParser)
+		if !ok {
+			continue
+		}
+		value, err := parser.Parse(match[custom.key])
+		if err != nil {
+			return nil
+		}
+		res[custom.key] = value
+	}
+	return res
+}
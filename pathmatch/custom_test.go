@@ -0,0 +1,199 @@
+package pathmatch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hexSegment matches lower case hex strings, the argument is their length
+func hexSegment(arg string) (SegmentMatcher, error) {
+	if arg != "" && arg != "8" {
+		return nil, errors.New("unsupported length")
+	}
+	return SegmentMatcherFunc(func(s string) bool {
+		if s == "" || arg == "8" && len(s) != 8 {
+			return false
+		}
+		return strings.Trim(s, "0123456789abcdef") == ""
+	}), nil
+}
+
+func TestRegisterSegment(t *testing.T) {
+	for _, kind := range []string{"", "1hex", "he x", "hex:8", "<hex>"} {
+		if err := RegisterSegment(kind, hexSegment); err == nil {
+			t.Fatalf("RegisterSegment(%q): nil error", kind)
+		}
+	}
+
+	// a segment of an unknown kind is static
+	p, err := Compile("/blobs/<hex>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Match("/blobs/<hex>") || p.Match("/blobs/ff") {
+		t.Fatal("segment of an unknown kind is not static")
+	}
+
+	if err := RegisterSegment("hex", hexSegment); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterSegment("hex")
+
+	tests := []struct {
+		path, s string
+		want    Match
+	}{
+		{"/blobs/<hex>", "/blobs/ff", Match{"$0": "ff"}},
+		{"/blobs/<hex>", "/blobs/fg", nil},
+		{"/blobs/<hex>", "/blobs/", nil},
+		{"/blobs/<hex:8>", "/blobs/0123abcd", Match{"$0": "0123abcd"}},
+		{"/blobs/<hex:8>", "/blobs/0123abc", nil},
+		{"/blobs/:id<hex>", "/blobs/ff", Match{"id": "ff"}},
+		{"/blobs/:id<hex>/*", "/blobs/ff/a/b", Match{"id": "ff", "$0": "a/b"}},
+		{"/*/<hex>/<hex>", "/a/b/ff/0a", Match{"$0": "a/b", "$1": "ff", "$2": "0a"}},
+		// a custom segment matches a whole segment only
+		{"/blobs/x<hex>", "/blobs/xff", nil},
+		{"/blobs/x<hex>", "/blobs/x<hex>", Match{}},
+		{"/blobs/<hex", "/blobs/<hex", Match{}},
+	}
+	for _, test := range tests {
+		p, err := Compile(test.path)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", test.path, err)
+		}
+		if got := p.FindSubmatch(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("FindSubmatch(%q) of %s: %v, want %v", test.s, test.path, got, test.want)
+		}
+		if got := p.Match(test.s); got != (test.want != nil) {
+			t.Fatalf("Match(%q) of %s: %v, want %v", test.s, test.path, got, test.want != nil)
+		}
+	}
+
+	// the error of the factory is returned by Compile
+	if _, err := Compile("/blobs/<hex:4>"); err == nil || !strings.Contains(err.Error(), "<hex:4>, unsupported length") {
+		t.Fatalf("Compile with invalid argument: %v, want unsupported length", err)
+	}
+
+	// a compiled path keeps its custom segments after the kind is unregistered
+	p, err = Compile("/blobs/<hex>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !UnregisterSegment("hex") || UnregisterSegment("hex") {
+		t.Fatal("UnregisterSegment does not report the registered kind")
+	}
+	if !p.Match("/blobs/ff") {
+		t.Fatal("compiled path does not match after UnregisterSegment")
+	}
+	if p, _ := Compile("/blobs/<hex>"); p.Match("/blobs/ff") {
+		t.Fatal("unregistered kind is still compiled")
+	}
+}
+
+func TestCustomSegmentEqualityCheck(t *testing.T) {
+	p, err := Compile("/:id<int>/:id", EnableEqualityCheck(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Match("/1/1") || p.Match("/1/2") {
+		t.Fatal("equality check of a named custom segment failed")
+	}
+	p, err = Compile("/:id/:id<int>", EnableEqualityCheck(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Match("/1/1") || p.Match("/1/2") || p.Match("/x/x") {
+		t.Fatal("equality check of a named custom segment failed")
+	}
+}
+
+func TestBuiltinSegments(t *testing.T) {
+	tests := []struct {
+		path, s string
+		want    bool
+	}{
+		{"/users/<uuid>", "/users/123e4567-e89b-12d3-a456-426614174000", true},
+		{"/users/<uuid>", "/users/123E4567-E89B-12D3-A456-426614174000", true},
+		{"/users/<uuid>", "/users/123e4567e89b12d3a456426614174000", false},
+		{"/users/<uuid>", "/users/not-a-uuid", false},
+		{"/logs/<date>", "/logs/2024-02-29", true},
+		{"/logs/<date>", "/logs/2023-02-29", false},
+		{"/logs/<date>", "/logs/2024-3-1", false},
+		{"/logs/<date:2024-01-01..2024-12-31>", "/logs/2024-01-01", true},
+		{"/logs/<date:2024-01-01..2024-12-31>", "/logs/2024-12-31", true},
+		{"/logs/<date:2024-01-01..2024-12-31>", "/logs/2023-12-31", false},
+		{"/logs/<date:2024-01-01..2024-12-31>", "/logs/2025-01-01", false},
+		{"/logs/<date:2024-01-01..>", "/logs/2999-01-01", true},
+		{"/logs/<date:..2024-01-01>", "/logs/2024-01-02", false},
+	}
+	for _, test := range tests {
+		p, err := Compile(test.path)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", test.path, err)
+		}
+		if got := p.Match(test.s); got != test.want {
+			t.Fatalf("Match(%q) of %s: %v, want %v", test.s, test.path, got, test.want)
+		}
+	}
+
+	for _, path := range []string{"/<uuid:v4>", "/<date:2024-01-01>", "/<date:2024-13-01..>", "/<date:..tomorrow>"} {
+		if _, err := Compile(path); err == nil {
+			t.Fatalf("Compile(%q): nil error", path)
+		}
+	}
+}
+
+func TestFindTypedSubmatch(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse(DateLayout, s)
+		return d
+	}
+	tests := []struct {
+		path, s string
+		want    TypedMatch
+	}{
+		{"/orders/:id<int>", "/orders/42", TypedMatch{"id": int64(42)}},
+		{"/orders/:id<int>", "/orders/-7", TypedMatch{"id": int64(-7)}},
+		{"/orders/:id<int>", "/orders/+7", TypedMatch{"id": int64(7)}},
+		{"/orders/:id<int>", "/orders/4.2", nil},
+		{"/orders/:id<int>", "/orders/9223372036854775808", nil},
+		{"/orders/:id<int:1..99>", "/orders/1", TypedMatch{"id": int64(1)}},
+		{"/orders/:id<int:1..99>", "/orders/99", TypedMatch{"id": int64(99)}},
+		{"/orders/:id<int:1..99>", "/orders/0", nil},
+		{"/orders/:id<int:1..99>", "/orders/100", nil},
+		{"/orders/:id<int:10..>", "/orders/9223372036854775807", TypedMatch{"id": int64(9223372036854775807)}},
+		{"/orders/:id<int:..0>", "/orders/1", nil},
+		{"/flags/:on<bool>", "/flags/true", TypedMatch{"on": true}},
+		{"/flags/:on<bool>", "/flags/false", TypedMatch{"on": false}},
+		{"/flags/:on<bool>", "/flags/yes", nil},
+		{"/flags/:on<bool>", "/flags/TRUE", nil},
+		{"/logs/:day<date>", "/logs/2024-03-01", TypedMatch{"day": date("2024-03-01")}},
+		// unnamed typed segments are converted as well, other values stay strings
+		{"/orders/<int>/<bool>", "/orders/42/true", TypedMatch{"$0": int64(42), "$1": true}},
+		{"/:user/orders/:id<int>/*", "/bob/orders/42/items/1", TypedMatch{"user": "bob", "id": int64(42), "$0": "items/1"}},
+		{"/users/:id<uuid>", "/users/123e4567-e89b-12d3-a456-426614174000", TypedMatch{"id": "123e4567-e89b-12d3-a456-426614174000"}},
+		{"/orders/:id<int>/paid/:paid<bool>", "/orders/42/paid/true", TypedMatch{"id": int64(42), "paid": true}},
+		{"/orders/:id<int>/paid/:paid<bool>", "/orders/x/paid/true", nil},
+	}
+	for _, test := range tests {
+		p, err := Compile(test.path)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", test.path, err)
+		}
+		if got := p.FindTypedSubmatch(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("FindTypedSubmatch(%q) of %s: %#v, want %#v", test.s, test.path, got, test.want)
+		}
+		if got := p.Match(test.s); got != (test.want != nil) {
+			t.Fatalf("Match(%q) of %s: %v, want %v", test.s, test.path, got, test.want != nil)
+		}
+	}
+
+	for _, path := range []string{"/<int:1-9>", "/<int:a..>", "/<int:..9.5>", "/<bool:strict>"} {
+		if _, err := Compile(path); err == nil {
+			t.Fatalf("Compile(%q): nil error", path)
+		}
+	}
+}
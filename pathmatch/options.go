@@ -42,6 +42,42 @@ func SetSuffix(suffix string) Option {
 	}
 }
 
+// SetMaxLength limits the length of a path expression, e.g. for untrusted patterns
+// default: 0, no limit
+func SetMaxLength(n int) Option {
+	return func(p *Path) error {
+		if n < 0 {
+			return errors.New("pathmatch: max length can't be negative")
+		}
+		p.limits.length = n
+		return nil
+	}
+}
+
+// SetMaxSegments limits the number of segments of a path expression, which bounds the matching time
+// default: 0, no limit
+func SetMaxSegments(n int) Option {
+	return func(p *Path) error {
+		if n < 0 {
+			return errors.New("pathmatch: max segments can't be negative")
+		}
+		p.limits.segments = n
+		return nil
+	}
+}
+
+// SetMaxParams limits the number of parameters and wildcards of a path expression
+// default: 0, no limit
+func SetMaxParams(n int) Option {
+	return func(p *Path) error {
+		if n < 0 {
+			return errors.New("pathmatch: max params can't be negative")
+		}
+		p.limits.params = n
+		return nil
+	}
+}
+
 // EnableEqualityCheck enables the equality check between parameterized segments with the same name
 // e.g. /foo/:id/bar/:id will not match /foo/1/bar/2, if the equality check is enabled
 // default: false
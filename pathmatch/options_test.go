@@ -0,0 +1,108 @@
+package pathmatch
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompileLimits(t *testing.T) {
+	tests := []struct {
+		path    string
+		options []Option
+		err     string
+	}{
+		{"/users/:id", []Option{SetMaxLength(10)}, ""},
+		{"/users/:id/", []Option{SetMaxLength(10)}, "length 11 of the path exceeds the limit of 10"},
+		// the empty string before the leading seperator is a segment
+		{"/a/b/c", []Option{SetMaxSegments(4)}, ""},
+		{"/a/b/c/", []Option{SetMaxSegments(4)}, "5 segments exceed the limit of 4"},
+		{"/:a/*/<int>", []Option{SetMaxParams(3)}, ""},
+		{"/:a/*/<int>/:b", []Option{SetMaxParams(3)}, "4 parameters exceed the limit of 3"},
+		// every parameter of a mixed segment counts
+		{"/:name.:ext/:v", []Option{SetMaxParams(2)}, "3 parameters exceed the limit of 2"},
+		{"/static/path", []Option{SetMaxParams(1)}, ""},
+		{strings.Repeat("/:p", 1000), []Option{SetMaxLength(0), SetMaxSegments(0), SetMaxParams(0)}, ""},
+		{"/a", []Option{SetMaxLength(-1)}, "max length can't be negative"},
+		{"/a", []Option{SetMaxSegments(-1)}, "max segments can't be negative"},
+		{"/a", []Option{SetMaxParams(-1)}, "max params can't be negative"},
+	}
+	for _, test := range tests {
+		_, err := Compile(test.path, test.options...)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Fatalf("Compile(%q): %v, want %q", test.path, err, test.err)
+		}
+	}
+}
+
+func TestConcurrentMatch(t *testing.T) {
+	p, err := Compile("/*/:id/:id", EnableEqualityCheck(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strings.Repeat("x", i+1)
+			for j := 0; j < 100; j++ {
+				m := p.FindSubmatch("/a/b/" + id + "/" + id)
+				if m == nil || m["$0"] != "a/b" || m["id"] != id {
+					t.Errorf("FindSubmatch of %s: %v", id, m)
+					return
+				}
+				if p.Match("/a/b/" + id + "/y") {
+					t.Errorf("Match of %s: true", id)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// countParams returns the number of parameters and wildcards of p like checkParams
+func countParams(p *Path) int {
+	n := 0
+	for _, seg := range p.Segments {
+		switch s := seg.(type) {
+		case *staticSegment:
+		case *mixedSegment:
+			n += len(s.keys)
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+func FuzzCompile(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"/users/:id", "/users/42"},
+		{"/*/:x/*", "/a/b/c/d"},
+		{"/index.:ext?:p1=:v1", "/index.html?x=1"},
+		{"/logs/:day<date:2024-01-01..2024-12-31>", "/logs/2024-03-01"},
+		{"/orders/:id<int:1..99>/<bool>", "/orders/7/true"},
+		{"/:a:b", "/ab"},
+		{"//*//", "///"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, path, s string) {
+		p, err := Compile(path, SetMaxLength(64), SetMaxSegments(8), SetMaxParams(4))
+		if err != nil {
+			return
+		}
+		if len(path) > 64 || len(p.Segments) > 8 || countParams(p) > 4 {
+			t.Fatalf("Compile(%q) exceeds the limits: length %d, %d segments, %d parameters", path, len(path), len(p.Segments), countParams(p))
+		}
+		m := p.FindSubmatch(s)
+		if p.Match(s) != (m != nil) {
+			t.Fatalf("Match(%q) of %s: %v, but FindSubmatch: %v", s, path, p.Match(s), m)
+		}
+		if typed := p.FindTypedSubmatch(s); (typed != nil) != (m != nil) {
+			t.Fatalf("FindTypedSubmatch(%q) of %s: %v, but FindSubmatch: %v", s, path, typed, m)
+		}
+	})
+}
@@ -21,6 +21,21 @@
 //	path	string		result
 //	/* 		/foo 		{"$1": "foo"}
 //	/* 		/foo/bar  	{"$1": "foo/bar"}
+//
+// Custom segments match a whole segment by a kind registered with RegisterSegment, e.g. the built-in uuid and date kinds.
+// They are written as <kind> or <kind:arg> and may be named by a preceding parameter.
+//
+//	path								string					result
+//	/logs/:day<date:2024-01-01..2024-12-31>	/logs/2024-03-01		{"day": "2024-03-01"}
+//	/logs/:day<date:2024-01-01..2024-12-31>	/logs/2025-01-01		nil
+//	/users/<uuid>						/users/not-a-uuid		nil
+//
+// FindTypedSubmatch converts the values of typed segments, e.g. int, bool and date.
+//
+//	path					string				result
+//	/orders/:id<int>		/orders/42			{"id": int64(42)}
+//	/orders/:id<int:1..99>	/orders/100			nil
+//	/flags/:on<bool>		/flags/yes			nil
 package pathmatch
 
 import (
@@ -37,6 +52,7 @@ type savePoint struct {
 	sIndex      int
 	searchStart int
 	valid       bool
+	match       Match // captures before the wildcard, restored on backtracking
 }
 
 type Path struct {
@@ -47,15 +63,24 @@ type Path struct {
 	Wildcard   string
 	Segments   []ISegment
 	match      Match
-	save       *savePoint
 	equalCheck bool
+	limits     limits
+}
+
+// limits of a path expression, 0 disables a limit
+type limits struct {
+	length   int
+	segments int
+	params   int
 }
 
 var except = regexp.MustCompile(`[^.?=&#:]+`)
 
-// Compile parses a path expression and returns a Path if successful
+// Compile parses a path expression and returns a Path if successful.
+// Untrusted path expressions should be limited by SetMaxLength, SetMaxSegments and SetMaxParams,
+// the matching time of a Path is linear in the length of the string times its number of segments.
 func Compile(path string, options ...Option) (*Path, error) {
-	p := &Path{path, "/", ":", "", "*", []ISegment{}, make(Match, 0), &savePoint{}, false}
+	p := &Path{path: path, Seperator: "/", Prefix: ":", Wildcard: "*", Segments: []ISegment{}, match: make(Match, 0)}
 
 	for _, option := range options {
 		if err := option(p); err != nil {
@@ -63,13 +88,30 @@ func Compile(path string, options ...Option) (*Path, error) {
 		}
 	}
 
+	if p.limits.length > 0 && len(path) > p.limits.length {
+		return nil, fmt.Errorf("pathmatch: length %d of the path exceeds the limit of %d", len(path), p.limits.length)
+	}
 	unnamed := 0
 	strSegments := strings.Split(path, p.Seperator)
+	if p.limits.segments > 0 && len(strSegments) > p.limits.segments {
+		return nil, fmt.Errorf("pathmatch: %d segments exceed the limit of %d", len(strSegments), p.limits.segments)
+	}
 	for _, strSeg := range strSegments {
 		if strSeg == p.Wildcard {
 			key := "$" + strconv.Itoa(unnamed)
 			unnamed++
 			p.Segments = append(p.Segments, newWildcardSegment(key))
+		} else if name, kind, arg, ok := p.parseCustom(strSeg); ok {
+			key := name
+			if key == "" {
+				key = "$" + strconv.Itoa(unnamed)
+				unnamed++
+			}
+			custom, err := newCustomSegment(key, kind, arg, p.equalCheck)
+			if err != nil {
+				return nil, err
+			}
+			p.Segments = append(p.Segments, custom)
 		} else if iPrefix := strings.Index(strSeg, p.Prefix); iPrefix != -1 {
 
 			var key string
@@ -116,9 +158,33 @@ func Compile(path string, options ...Option) (*Path, error) {
 		}
 	}
 
+	if err := p.checkParams(); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
+// checkParams returns an error, if the parameters and wildcards of p exceed the limit
+func (p *Path) checkParams() error {
+	if p.limits.params == 0 {
+		return nil
+	}
+	n := 0
+	for _, seg := range p.Segments {
+		switch s := seg.(type) {
+		case *staticSegment:
+		case *mixedSegment:
+			n += len(s.keys)
+		default:
+			n++
+		}
+	}
+	if n > p.limits.params {
+		return fmt.Errorf("pathmatch: %d parameters exceed the limit of %d", n, p.limits.params)
+	}
+	return nil
+}
+
 // Match returns true if s and p match
 func (p *Path) Match(s string) bool {
 	m := p.getMatch(s, false || p.equalCheck)
@@ -148,8 +214,11 @@ func segmentLen(s string, sep string, done bool) int {
 	return len(s) + len(sep)
 }
 
+// getMatch matches s segment by segment. Only the last wildcard is backtracked, so matching takes
+// O(len(s) * len(p.Segments)) and p is not modified, which makes it safe for concurrent use.
 func (p *Path) getMatch(s string, capture bool) Match {
 	draft := newMatchDraft(capture, p.match)
+	save := savePoint{}
 
 	sIndex := 0
 	searchStart := 0
@@ -170,31 +239,33 @@ func (p *Path) getMatch(s string, capture bool) Match {
 				break
 			}
 
-			if p.save.valid && p.save.i == i {
-				p.save.searchStart = segmentLen(str, p.Seperator, done)
+			if save.valid && save.i == i {
+				save.searchStart = segmentLen(str, p.Seperator, done)
 			} else {
-				p.save.i = i
-				p.save.sIndex = sIndex
-				p.save.searchStart = segmentLen(str, p.Seperator, done)
-				p.save.valid = true
+				save.i = i
+				save.sIndex = sIndex
+				save.searchStart = segmentLen(str, p.Seperator, done)
+				save.valid = true
+				save.match = draft.snapshot()
 			}
 		}
 
 		m := seg.Match(draft, str)
-		if m == nil && p.save.valid {
-			i = p.save.i - 1
-			sIndex = p.save.sIndex
-			searchStart = p.save.searchStart
+		if m != nil && len(p.Segments)-1 == i && !done {
+			// the last segment has to match the rest of s
+			m = nil
+		}
+		if m == nil && save.valid {
+			i = save.i - 1
+			sIndex = save.sIndex
+			searchStart = save.searchStart
+			draft.restore(save.match)
 			continue
 		}
 
 		draft = m
 		sIndex += segmentLen(str, p.Seperator, done)
 		searchStart = 0
-
-		if len(p.Segments)-1 == i && !done {
-			return nil
-		}
 	}
 	if draft == nil || len(s) != sIndex {
 		return nil
@@ -0,0 +1,37 @@
+package pathmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWildcardBacktracking(t *testing.T) {
+	tests := []struct {
+		path, s string
+		equal   bool
+		want    Match
+	}{
+		{"/*/c", "/a/b/c", false, Match{"$0": "a/b"}},
+		// the last segment matches the rest of the string only after the wildcard took more segments
+		{"/*/:x", "/a/b/c", false, Match{"$0": "a/b", "x": "c"}},
+		{"/*/:x/:y", "/a/b/c/d", false, Match{"$0": "a/b", "x": "c", "y": "d"}},
+		{"/*/:x.:ext", "/a/b/c.txt", false, Match{"$0": "a/b", "x": "c", "ext": "txt"}},
+		{"/*/:x", "/a", false, nil},
+		// captures of a failed attempt do not fail the equality check of the next attempt
+		{"/*/:id/:id", "/a/b/c/c", true, Match{"$0": "a/b", "id": "c"}},
+		{"/*/:id/:id", "/a/b/c/d", true, nil},
+		{"/:id/*/:id", "/a/b/c/a", true, Match{"id": "a", "$0": "b/c"}},
+	}
+	for _, test := range tests {
+		p, err := Compile(test.path, EnableEqualityCheck(test.equal))
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", test.path, err)
+		}
+		if got := p.FindSubmatch(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("FindSubmatch(%q) of %s: %v, want %v", test.s, test.path, got, test.want)
+		}
+		if got := p.Match(test.s); got != (test.want != nil) {
+			t.Fatalf("Match(%q) of %s: %v, want %v", test.s, test.path, got, test.want != nil)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pathmatch
+
+import (
+	"sort"
+)
+
+// ranks of the segment types, a higher rank is more specific
+var segmentRanks = map[SegType]int{
+	Wildcard:      1,
+	Parameterized: 2,
+	Custom:        3,
+	Mixed:         4,
+	Static:        5,
+}
+
+// String returns the path expression
+func (p *Path) String() string {
+	return p.path
+}
+
+// Score returns the ranks of the segments of p, static > mixed > custom > parameterized > wildcard
+func (p *Path) Score() []int {
+	score := make([]int, len(p.Segments))
+	for i, seg := range p.Segments {
+		score[i] = segmentRanks[seg.Type()]
+	}
+	return score
+}
+
+// staticLen returns the number of static characters of p
+func (p *Path) staticLen() int {
+	n := 0
+	for _, seg := range p.Segments {
+		switch s := seg.(type) {
+		case *staticSegment:
+			n += len(s.value)
+		case *mixedSegment:
+			for _, static := range s.static {
+				n += len(static)
+			}
+		}
+	}
+	return n
+}
+
+// Compare returns a positive number, if p is more specific than q, a negative number, if q is more specific, otherwise 0.
+// The scores are compared segment by segment, so the path with the longer specific prefix wins,
+// e.g. /a/b/* is more specific than /a/:x/c. If one score is a prefix of the other, the longer path is more specific.
+// Paths with equal scores are ordered by the number of their static characters.
+func Compare(p, q *Path) int {
+	ps, qs := p.Score(), q.Score()
+	for i := 0; i < len(ps) && i < len(qs); i++ {
+		if ps[i] != qs[i] {
+			return ps[i] - qs[i]
+		}
+	}
+	if len(ps) != len(qs) {
+		return len(ps) - len(qs)
+	}
+	return p.staticLen() - q.staticLen()
+}
+
+// Set is a set of paths, which resolves multiple matches by specificity like a router.
+// Add must not be called concurrently with other methods of the set.
+type Set struct {
+	paths   []*Path
+	options []Option
+}
+
+// NewSet compiles the patterns with the options
+//
+//	s, _ := NewSet([]string{"/users/*", "/users/:id", "/users/me"})
+//	p, m, _ := s.BestMatch("/users/me") // /users/me
+func NewSet(patterns []string, options ...Option) (*Set, error) {
+	s := &Set{options: options}
+	for _, pattern := range patterns {
+		if err := s.Add(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add compiles a pattern with the options of the set and adds it, the paths stay ordered by specificity
+func (s *Set) Add(pattern string) error {
+	p, err := Compile(pattern, s.options...)
+	if err != nil {
+		return err
+	}
+	i := sort.Search(len(s.paths), func(i int) bool {
+		return Compare(s.paths[i], p) < 0
+	})
+	s.paths = append(s.paths, nil)
+	copy(s.paths[i+1:], s.paths[i:])
+	s.paths[i] = p
+	return nil
+}
+
+// Paths returns the paths of the set, the most specific first
+func (s *Set) Paths() []*Path {
+	return s.paths
+}
+
+// Matches returns all paths matching str, the most specific first
+func (s *Set) Matches(str string) []*Path {
+	res := []*Path{}
+	for _, p := range s.paths {
+		if p.Match(str) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// BestMatch returns the most specific path matching str and its submatch
+func (s *Set) BestMatch(str string) (*Path, Match, bool) {
+	for _, p := range s.paths {
+		if m := p.FindSubmatch(str); m != nil {
+			return p, m, true
+		}
+	}
+	return nil, nil, false
+}
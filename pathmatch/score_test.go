@@ -0,0 +1,119 @@
+package pathmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		path string
+		want []int
+	}{
+		{"/users/me", []int{5, 5, 5}},
+		{"/users/:id", []int{5, 5, 2}},
+		{"/users/:id<int>", []int{5, 5, 3}},
+		{"/users/<uuid>", []int{5, 5, 3}},
+		{"/files/:name.:ext", []int{5, 5, 4}},
+		{"/*", []int{5, 1}},
+	}
+	for _, test := range tests {
+		p, err := Compile(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Score(); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("Score() of %s: %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		p, q string
+		want int // sign of Compare(p, q)
+	}{
+		{"/users/me", "/users/:id", 1},
+		{"/users/:id<int>", "/users/:id", 1},
+		{"/files/:name.json", "/files/:id<int>", 1},
+		{"/users/:id", "/*", 1},
+		// the longer specific prefix wins
+		{"/a/b/*", "/a/:x/c", 1},
+		{"/a/:x/c", "/a/b/*", -1},
+		// if one score is a prefix of the other, the longer path wins
+		{"/a/:x/c", "/a/:x", 1},
+		// equal scores are ordered by the number of static characters
+		{"/users/list", "/users/me", 1},
+		{"/files/:name.json", "/files/:name.md", 1},
+		{"/users/:id", "/users/:name", 0},
+		{"/*", "/*", 0},
+	}
+	sign := func(n int) int {
+		switch {
+		case n > 0:
+			return 1
+		case n < 0:
+			return -1
+		}
+		return 0
+	}
+	for _, test := range tests {
+		p, _ := Compile(test.p)
+		q, _ := Compile(test.q)
+		if got := sign(Compare(p, q)); got != test.want {
+			t.Fatalf("Compare(%s, %s): %d, want %d", test.p, test.q, got, test.want)
+		}
+		if got := sign(Compare(q, p)); got != -test.want {
+			t.Fatalf("Compare(%s, %s): %d, want %d", test.q, test.p, got, -test.want)
+		}
+	}
+}
+
+func TestSetBestMatch(t *testing.T) {
+	s, err := NewSet([]string{"/*", "/users/:id", "/users/*", "/users/me", "/users/:id<int>", "/users/:id/posts", "/users/:name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the scores are compared segment by segment, paths with equal specificity keep the order they were added in
+	want := []string{"/users/me", "/users/:id<int>", "/users/:id/posts", "/users/:id", "/users/:name", "/users/*", "/*"}
+	got := []string{}
+	for _, p := range s.Paths() {
+		got = append(got, p.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Paths(): %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		s     string
+		path  string
+		match Match
+	}{
+		{"/users/me", "/users/me", Match{}},
+		{"/users/42", "/users/:id<int>", Match{"id": "42"}},
+		{"/users/bob", "/users/:id", Match{"id": "bob"}},
+		{"/users/bob/posts", "/users/:id/posts", Match{"id": "bob"}},
+		{"/users/bob/likes", "/users/*", Match{"$0": "bob/likes"}},
+		{"/orders", "/*", Match{"$0": "orders"}},
+		{"orders", "", nil},
+	}
+	for _, test := range tests {
+		p, m, ok := s.BestMatch(test.s)
+		if ok != (test.path != "") || ok && (p.String() != test.path || !reflect.DeepEqual(m, test.match)) {
+			t.Fatalf("BestMatch(%q): %v %v %v, want %s %v", test.s, p, m, ok, test.path, test.match)
+		}
+	}
+
+	matches := []string{}
+	for _, p := range s.Matches("/users/42") {
+		matches = append(matches, p.String())
+	}
+	if want := []string{"/users/:id<int>", "/users/:id", "/users/:name", "/users/*", "/*"}; !reflect.DeepEqual(matches, want) {
+		t.Fatalf("Matches(%q): %v, want %v", "/users/42", matches, want)
+	}
+
+	if _, err := NewSet([]string{"/users/:id", "/files/:"}); err == nil {
+		t.Fatal("NewSet with an invalid pattern: nil error")
+	}
+}
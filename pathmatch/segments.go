@@ -26,11 +26,35 @@ func (m *matchDraft) set(key, value string) {
 	m.match[key] = value
 }
 
+// snapshot returns a copy of the captured values
+func (m *matchDraft) snapshot() Match {
+	if !m.capture {
+		return nil
+	}
+	res := make(Match, len(m.match))
+	for key, value := range m.match {
+		res[key] = value
+	}
+	return res
+}
+
+// restore replaces the captured values with a copy of a snapshot
+func (m *matchDraft) restore(snapshot Match) {
+	if !m.capture {
+		return
+	}
+	m.match = make(Match, len(snapshot))
+	for key, value := range snapshot {
+		m.match[key] = value
+	}
+}
+
 const (
 	Static SegType = iota
 	Parameterized
 	Wildcard
 	Mixed
+	Custom
 )
 
 type ISegment interface {
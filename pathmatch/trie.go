@@ -0,0 +1,84 @@
+package pathmatch
+
+import "strings"
+
+type trieNode struct {
+	children map[string]*trieNode
+	values   map[string]struct{}
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children: make(map[string]*trieNode),
+		values:   make(map[string]struct{}),
+	}
+}
+
+// PrefixTrie stores values under paths and finds all values,
+// whose path is a prefix of a given path, in O(path length).
+// Paths are compared segment by segment, "/a/b" is a prefix of "/a/b" and "/a/b/c", but not of "/a/bc".
+type PrefixTrie struct {
+	Seperator string
+	root      *trieNode
+}
+
+func NewPrefixTrie() *PrefixTrie {
+	return &PrefixTrie{"/", newTrieNode()}
+}
+
+func (t *PrefixTrie) segments(path string) []string {
+	return strings.Split(strings.TrimSuffix(path, t.Seperator), t.Seperator)
+}
+
+// Insert stores value under path
+func (t *PrefixTrie) Insert(path, value string) {
+	node := t.root
+	for _, seg := range t.segments(path) {
+		next, ok := node.children[seg]
+		if !ok {
+			next = newTrieNode()
+			node.children[seg] = next
+		}
+		node = next
+	}
+	node.values[value] = struct{}{}
+}
+
+// Remove removes value from path and returns true, if it was present
+func (t *PrefixTrie) Remove(path, value string) bool {
+	return t.remove(t.root, t.segments(path), value)
+}
+
+func (t *PrefixTrie) remove(node *trieNode, segments []string, value string) bool {
+	if len(segments) == 0 {
+		_, ok := node.values[value]
+		delete(node.values, value)
+		return ok
+	}
+	next, ok := node.children[segments[0]]
+	if !ok {
+		return false
+	}
+	removed := t.remove(next, segments[1:], value)
+	if len(next.values) == 0 && len(next.children) == 0 {
+		delete(node.children, segments[0])
+	}
+	return removed
+}
+
+// Prefixes calls fn for every value, whose path is a prefix of path
+func (t *PrefixTrie) Prefixes(path string, fn func(value string) bool) {
+	node := t.root
+	for _, seg := range strings.Split(path, t.Seperator) {
+		next, ok := node.children[seg]
+		if !ok {
+			return
+		}
+		node = next
+		for value := range node.values {
+			if !fn(value) {
+				return
+			}
+		}
+	}
+}
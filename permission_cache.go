@@ -0,0 +1,448 @@
+package fastac
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/oarkflow/govaluate"
+
+	"github.com/oarkflow/fastac/emitter"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// PermissionCache materializes the permissions of hot users, so their requests are answered by a set lookup.
+//
+// The cache supports models, whose matcher has the form
+//
+//	m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+//
+// i.e. the first policy argument is the subject, which is resolved through the role definition,
+// and all other arguments are compared by equality with the request arguments in the same order.
+// NewPermissionCache rejects all other matchers, e.g. with keyMatch or additional conditions.
+// Requests without matching rule get the effect of OptionDefaultEffect like in Enforce.
+// The cache is updated incrementally from the rule events of the model.
+// ClearPolicy does not emit rule events, call Refresh afterwards.
+// Users resolved before the model of the enforcer is replaced, e.g. by SetModel, are answered by the enforcer until they are warmed again.
+type PermissionCache struct {
+	e       *Enforcer
+	pDef    *defs.PolicyDef
+	gKey    string
+	expr    string
+	columns []int
+
+	mutex sync.RWMutex
+	users map[string]*permissions
+	// generation is incremented by every rule event, so Warm detects events during the resolution
+	generation uint64
+	listeners  []*ModelListener
+}
+
+// permissions are the subjects of a user and the rules granting or denying the permissions of the subjects.
+// The rules are stored by their hash, so a rule event, which was already seen by the resolution, is applied once.
+type permissions struct {
+	model    m.IModel
+	subjects map[string]struct{}
+	allow    map[string]map[string]struct{}
+	deny     map[string]map[string]struct{}
+}
+
+type modelListener struct {
+	event    emitter.EventType
+	listener *emitter.Listener
+}
+
+// NewPermissionCache creates a cache for the policy definition pKey and the role definition gKey.
+// gKey may be empty, if subjects are not resolved through roles.
+//
+//	pc, _ := NewPermissionCache(e, "p", "g")
+//	pc.Warm("alice", "bob")
+//	pc.Enforce("alice", "data1", "read")
+func NewPermissionCache(e *Enforcer, pKey, gKey string) (*PermissionCache, error) {
+	def, ok := e.model.GetDef(m.P_SEC, pKey)
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, pKey)
+	}
+	if gKey != "" {
+		gDef, ok := e.model.GetDef(m.G_SEC, gKey)
+		if !ok {
+			return nil, fmt.Errorf(str.ERR_RM_NOT_FOUND, gKey)
+		}
+		if gDef.(*defs.RoleDef).NArgs() != 2 {
+			return nil, fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "role definition with domains")
+		}
+	}
+	effector, ok := e.model.GetEffector("e")
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_EFFECTOR_NOT_FOUND, "e")
+	}
+	expr := ""
+	if ex, ok := effector.(interface{ Expr() string }); ok {
+		expr = ex.Expr()
+	}
+	switch expr {
+	case eft.SOME_ALLOW, eft.NO_DENY, eft.SOME_ALLOW_NO_DENY:
+	default:
+		return nil, fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "effect "+expr)
+	}
+
+	pc := &PermissionCache{
+		e:     e,
+		pDef:  def.(*defs.PolicyDef),
+		gKey:  gKey,
+		expr:  expr,
+		users: make(map[string]*permissions),
+	}
+	for i, arg := range pc.pDef.GetArgs() {
+		if i > 0 && arg != "eft" {
+			pc.columns = append(pc.columns, i)
+		}
+	}
+	if err := pc.checkMatcher(); err != nil {
+		return nil, err
+	}
+	pc.listen(m.RULE_ADDED, func(arguments ...interface{}) {
+		pc.update(arguments[0].([]string), true)
+	})
+	pc.listen(m.RULES_ADDED, func(arguments ...interface{}) {
+		for _, rule := range arguments[0].([][]string) {
+			pc.update(rule, true)
+		}
+	})
+	pc.listen(m.RULE_REMOVED, func(arguments ...interface{}) {
+		pc.update(arguments[0].([]string), false)
+	})
+	return pc, nil
+}
+
+// checkMatcher returns an error, unless the matcher m is a conjunction of g(r.sub, p.sub) and equalities,
+// which compare the request arguments after the subject to the columns of the cache in order
+func (pc *PermissionCache) checkMatcher() error {
+	matcher, ok := pc.e.model.GetMatcher("m")
+	if !ok {
+		return fmt.Errorf(str.ERR_MATCHER_NOT_FOUND, "m")
+	}
+	if matcher.GetPolicyKey() != pc.pDef.GetKey() {
+		return fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "matcher of policy "+matcher.GetPolicyKey())
+	}
+	rDef, ok := pc.e.model.GetRequestDef("r")
+	if !ok {
+		return fmt.Errorf(str.ERR_REQUESTDEF_NOT_FOUND, "r")
+	}
+	rArgs, pArgs := rDef.GetArgs(), pc.pDef.GetArgs()
+	if len(rArgs) != len(pc.columns)+1 {
+		return fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "request definition with other arguments than the policy")
+	}
+
+	r, p := rDef.GetKey()+"_", pc.pDef.GetKey()+"_"
+	expected := map[string]struct{}{}
+	if pc.gKey != "" {
+		expected[pc.gKey+"("+r+rArgs[0]+","+p+pArgs[0]+")"] = struct{}{}
+	} else {
+		expected[r+rArgs[0]+"=="+p+pArgs[0]] = struct{}{}
+	}
+	for i, column := range pc.columns {
+		expected[r+rArgs[i+1]+"=="+p+pArgs[column]] = struct{}{}
+	}
+
+	ast := matcher.AST()
+	terms := []*defs.Node{ast}
+	if ast.Kind == defs.AndNode {
+		terms = ast.Children
+	}
+	for _, term := range terms {
+		if term.Kind != defs.TermNode {
+			return fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "matcher with disjunctions")
+		}
+		signature := termSignature(term)
+		if _, ok := expected[signature]; !ok {
+			return fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "matcher term "+term.Expr)
+		}
+		delete(expected, signature)
+	}
+	if len(expected) > 0 {
+		return fmt.Errorf(str.ERR_CACHE_UNSUPPORTED, "matcher, which does not compare all policy arguments")
+	}
+	return nil
+}
+
+// termSignature returns the tokens of a term without spaces, the operands of == are ordered request argument first
+func termSignature(term *defs.Node) string {
+	tokens := term.Tokens
+	if len(tokens) == 3 && tokens[1].Kind == govaluate.COMPARATOR && tokens[1].Value == "==" &&
+		tokens[0].Kind == govaluate.VARIABLE && tokens[2].Kind == govaluate.VARIABLE {
+		left, right := fmt.Sprint(tokens[0].Value), fmt.Sprint(tokens[2].Value)
+		if len(term.PolicyArgs) == 1 && term.PolicyArgs[0] == left {
+			left, right = right, left
+		}
+		return left + "==" + right
+	}
+	signature := ""
+	for _, token := range tokens {
+		switch token.Kind {
+		case govaluate.FUNCTION:
+			name, _ := token.Value2.(string)
+			signature += name
+		case govaluate.CLAUSE:
+			signature += "("
+		case govaluate.CLAUSE_CLOSE:
+			signature += ")"
+		case govaluate.VARIABLE, govaluate.SEPARATOR:
+			signature += fmt.Sprint(token.Value)
+		default:
+			return term.Expr
+		}
+	}
+	return signature
+}
+
+// listen adds a listener, which follows the model of the enforcer, see AddModelListener
+func (pc *PermissionCache) listen(event emitter.EventType, handler emitter.HandleFunc) {
+	pc.listeners = append(pc.listeners, pc.e.AddModelListener(event, handler))
+}
+
+// Close stops updating the cache and evicts all users
+func (pc *PermissionCache) Close() {
+	for _, l := range pc.listeners {
+		pc.e.RemoveModelListener(l)
+	}
+	pc.listeners = nil
+	pc.EvictAll()
+}
+
+// warmAttempts is the number of resolutions of a user, which are discarded because of concurrent rule events,
+// before the user is resolved while the cache is locked
+const warmAttempts = 3
+
+// Warm resolves and caches the permissions of users, cached users are resolved again
+func (pc *PermissionCache) Warm(users ...string) error {
+	for _, user := range users {
+		if err := pc.warm(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warm resolves user and stores the permissions, unless a rule event arrived meanwhile, which the resolution may have missed
+func (pc *PermissionCache) warm(user string) error {
+	for i := 0; i < warmAttempts; i++ {
+		pc.mutex.RLock()
+		generation := pc.generation
+		pc.mutex.RUnlock()
+		perms, err := pc.resolve(user)
+		if err != nil {
+			return err
+		}
+		pc.mutex.Lock()
+		if pc.generation == generation {
+			pc.users[user] = perms
+			pc.mutex.Unlock()
+			return nil
+		}
+		pc.mutex.Unlock()
+	}
+
+	// the events are applied after the resolution, when the lock is released
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	perms, err := pc.resolve(user)
+	if err != nil {
+		return err
+	}
+	pc.users[user] = perms
+	return nil
+}
+
+// Refresh resolves the permissions of all cached users again
+func (pc *PermissionCache) Refresh() error {
+	return pc.Warm(pc.Users()...)
+}
+
+// Evict removes users from the cache
+func (pc *PermissionCache) Evict(users ...string) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	for _, user := range users {
+		delete(pc.users, user)
+	}
+	pc.e.GetLogger().Debug("permission cache evicted", "users", users)
+}
+
+// EvictAll removes all users from the cache
+func (pc *PermissionCache) EvictAll() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.users = make(map[string]*permissions)
+}
+
+// Users returns the sorted names of all cached users
+func (pc *PermissionCache) Users() []string {
+	pc.mutex.RLock()
+	defer pc.mutex.RUnlock()
+	users := make([]string, 0, len(pc.users))
+	for user := range pc.users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// Has returns true, if the permissions of user are cached
+func (pc *PermissionCache) Has(user string) bool {
+	pc.mutex.RLock()
+	defer pc.mutex.RUnlock()
+	_, ok := pc.users[user]
+	return ok
+}
+
+// Lookup answers a request of a cached user, values are the request values after the subject.
+// ok is false, if the user is not cached.
+func (pc *PermissionCache) Lookup(user string, values ...string) (allowed bool, ok bool) {
+	key := util.Hash(values)
+	pc.mutex.RLock()
+	defer pc.mutex.RUnlock()
+	perms, ok := pc.users[user]
+	if !ok || perms.model != pc.e.model {
+		return false, false
+	}
+	return pc.decide(len(perms.allow[key]) > 0, len(perms.deny[key]) > 0), true
+}
+
+// Enforce answers requests of cached users from the cache and passes all other requests to the enforcer
+func (pc *PermissionCache) Enforce(rvals ...interface{}) (bool, error) {
+	if len(rvals) > 0 {
+		if user, ok := rvals[0].(string); ok {
+			values := make([]string, 0, len(rvals)-1)
+			for _, rval := range rvals[1:] {
+				value, ok := rval.(string)
+				if !ok {
+					return pc.e.Enforce(rvals...)
+				}
+				values = append(values, value)
+			}
+			if allowed, ok := pc.Lookup(user, values...); ok {
+				return allowed, nil
+			}
+		}
+	}
+	return pc.e.Enforce(rvals...)
+}
+
+// decide merges the effects of the matching rules like the effector, requests without matching rule get the effect of OptionDefaultEffect
+func (pc *PermissionCache) decide(allow, deny bool) bool {
+	if !allow && !deny && pc.e.defaultEffect != nil {
+		return *pc.e.defaultEffect == eft.Allow
+	}
+	switch pc.expr {
+	case eft.SOME_ALLOW:
+		return allow
+	case eft.NO_DENY:
+		return !deny
+	default:
+		return allow && !deny
+	}
+}
+
+// resolve collects the subjects of user and the permissions granted to them
+func (pc *PermissionCache) resolve(user string) (*permissions, error) {
+	model := pc.e.model
+	perms := &permissions{
+		model:    model,
+		subjects: map[string]struct{}{user: {}},
+		allow:    make(map[string]map[string]struct{}),
+		deny:     make(map[string]map[string]struct{}),
+	}
+	if pc.gKey != "" {
+		rm, _ := model.GetRoleManager(pc.gKey)
+		queue := []string{user}
+		for len(queue) > 0 {
+			roles, err := rm.GetRoles(queue[0])
+			if err != nil {
+				return nil, err
+			}
+			queue = queue[1:]
+			for _, role := range roles {
+				if _, ok := perms.subjects[role]; !ok {
+					perms.subjects[role] = struct{}{}
+					queue = append(queue, role)
+				}
+			}
+		}
+	}
+
+	p, _ := model.GetPolicy(pc.pDef.GetKey())
+	p.Range(func(rule []string) bool {
+		if _, ok := perms.subjects[rule[0]]; ok {
+			pc.apply(perms, rule, true)
+		}
+		return true
+	})
+	return perms, nil
+}
+
+// apply adds or removes a rule without key to the rules granting or denying its permission
+func (pc *PermissionCache) apply(perms *permissions, rule []string, added bool) {
+	values := make([]string, 0, len(pc.columns))
+	for _, i := range pc.columns {
+		if i < len(rule) {
+			values = append(values, rule[i])
+		}
+	}
+	key := util.Hash(values)
+	target := perms.allow
+	switch pc.pDef.GetEft(rule) {
+	case eft.Allow:
+	case eft.Deny:
+		target = perms.deny
+	default:
+		return
+	}
+	ruleKey := util.Hash(rule)
+	if !added {
+		if delete(target[key], ruleKey); len(target[key]) == 0 {
+			delete(target, key)
+		}
+		return
+	}
+	if target[key] == nil {
+		target[key] = make(map[string]struct{})
+	}
+	target[key][ruleKey] = struct{}{}
+}
+
+// update applies a rule event to the cached users
+func (pc *PermissionCache) update(rule []string, added bool) {
+	switch rule[0] {
+	case pc.pDef.GetKey():
+		pc.mutex.Lock()
+		defer pc.mutex.Unlock()
+		pc.generation++
+		for _, perms := range pc.users {
+			if _, ok := perms.subjects[rule[1]]; ok {
+				pc.apply(perms, rule[1:], added)
+			}
+		}
+	case pc.gKey:
+		pc.mutex.Lock()
+		pc.generation++
+		// the affected users are evicted before they are resolved again, so a failed resolution serves no stale permissions
+		affected := []string{}
+		for user, perms := range pc.users {
+			if _, ok := perms.subjects[rule[1]]; ok {
+				affected = append(affected, user)
+				delete(pc.users, user)
+			}
+		}
+		pc.mutex.Unlock()
+		for _, user := range affected {
+			if err := pc.warm(user); err != nil {
+				pc.e.GetLogger().Warn("permission cache update failed", "user", user, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package fastac
+
+import (
+	"errors"
+	"testing"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/rbac"
+)
+
+func TestPermissionCache(t *testing.T) {
+	e := testEnforcer(t, nil)
+	if err := e.AddRules([][]string{{"p", "reader", "data1", "read"}, {"g", "alice", "reader"}}); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := NewPermissionCache(e, "p", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := pc.Warm("alice"); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(want bool) {
+		t.Helper()
+		if allowed, ok := pc.Lookup("alice", "data1", "read"); !ok || allowed != want {
+			t.Fatalf("Lookup: %v %v, want %v true", allowed, ok, want)
+		}
+	}
+
+	lookup(true)
+	// an event of a rule, which the resolution has already seen, is applied once
+	pc.update([]string{"p", "reader", "data1", "read"}, true)
+	if _, err := e.RemoveRule([]string{"p", "reader", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	lookup(false)
+	if _, err := e.AddRule([]string{"p", "reader", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	lookup(true)
+	if _, err := e.RemoveRule([]string{"g", "alice", "reader"}); err != nil {
+		t.Fatal(err)
+	}
+	lookup(false)
+}
+
+func TestPermissionCacheFork(t *testing.T) {
+	e := testEnforcer(t, nil)
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	fork := e.Fork()
+	pc, err := NewPermissionCache(fork, "p", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := pc.Warm("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first change copies the model of the fork, the listeners of the cache follow the copy
+	if _, err := fork.RemoveRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, ok := pc.Lookup("alice", "data1", "read"); ok && allowed {
+		t.Fatal("removed permission is still cached")
+	}
+	if err := pc.Warm("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fork.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, ok := pc.Lookup("alice", "data1", "read"); !ok || !allowed {
+		t.Fatalf("Lookup: %v %v, want true true", allowed, ok)
+	}
+}
+
+// failingRoleManager fails to look up the roles of a user, once fail is set
+type failingRoleManager struct {
+	rbac.IRoleManager
+	fail string
+}
+
+func (rm *failingRoleManager) GetRoles(name string, domain ...string) ([]string, error) {
+	if name == rm.fail {
+		return nil, errors.New("lookup failed")
+	}
+	return rm.IRoleManager.GetRoles(name, domain...)
+}
+
+func TestPermissionCacheEvictsOnFailedUpdate(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := model.GetRoleManager("g")
+	rm := &failingRoleManager{IRoleManager: orig}
+	model.SetRoleManager("g", rm)
+	e, err := NewEnforcer(model, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRules([][]string{
+		{"p", "staff", "data1", "read"},
+		{"g", "alice", "reader"},
+		{"g", "bob", "reader"},
+		{"g", "reader", "staff"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := NewPermissionCache(e, "p", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := pc.Warm("alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	rm.fail = "alice"
+	if _, err := e.RemoveRule([]string{"g", "reader", "staff"}); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, ok := pc.Lookup("alice", "data1", "read"); ok {
+		t.Fatalf("Lookup(alice): %v %v, want the user evicted", allowed, ok)
+	}
+	if allowed, ok := pc.Lookup("bob", "data1", "read"); !ok || allowed {
+		t.Fatalf("Lookup(bob): %v %v, want false true", allowed, ok)
+	}
+}
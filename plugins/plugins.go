@@ -0,0 +1,203 @@
+// Package plugins loads matcher functions at runtime, so matchers can be extended without recompiling.
+//
+// Functions are loaded by kind: "so" loads Go plugins (go build -buildmode=plugin), which export
+//
+//	var Functions = map[string]func(args ...interface{}) (interface{}, error){
+//		"isWeekend": func(args ...interface{}) (interface{}, error) { ... },
+//	}
+//
+// Interpreters like yaegi are plugged in with Register:
+//
+//	plugins.Register("yaegi", plugins.LoaderFunc(func(source string) (map[string]govaluate.ExpressionFunction, error) {
+//		i := interp.New(interp.Options{})
+//		_ = i.Use(stdlib.Symbols)
+//		if _, err := i.EvalPath(source); err != nil {
+//			return nil, err
+//		}
+//		v, err := i.Eval("plugin.Functions")
+//		if err != nil {
+//			return nil, err
+//		}
+//		return plugins.Functions(v.Interface())
+//	}))
+//
+// The configuration lists the sources of the functions:
+//
+//	{"plugins": [{"kind": "so", "source": "/opt/pdp/calendar.so"}, {"kind": "yaegi", "source": "/opt/pdp/geo.go", "functions": ["inRegion"]}]}
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"plugin"
+	"sort"
+	"sync"
+
+	"github.com/oarkflow/govaluate"
+
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/fm"
+)
+
+// Symbol is the name of the variable or function, which a Go plugin exports
+const Symbol = "Functions"
+
+// Loader loads the matcher functions of a source, e.g. the path of a shared object or a script
+type Loader interface {
+	Load(source string) (map[string]govaluate.ExpressionFunction, error)
+}
+
+// LoaderFunc adapts a function to the Loader interface
+type LoaderFunc func(source string) (map[string]govaluate.ExpressionFunction, error)
+
+func (fn LoaderFunc) Load(source string) (map[string]govaluate.ExpressionFunction, error) {
+	return fn(source)
+}
+
+var (
+	mutex   sync.RWMutex
+	loaders = map[string]Loader{
+		"so": LoaderFunc(LoadSharedObject),
+	}
+)
+
+// Register adds or replaces the loader of a kind
+func Register(kind string, loader Loader) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	loaders[kind] = loader
+}
+
+// Kinds returns the sorted kinds of all registered loaders
+func Kinds() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	kinds := make([]string, 0, len(loaders))
+	for kind := range loaders {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// LoadSharedObject opens a Go plugin and returns the functions exported as Symbol
+func LoadSharedObject(source string) (map[string]govaluate.ExpressionFunction, error) {
+	p, err := plugin.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(Symbol)
+	if err != nil {
+		return nil, err
+	}
+	return Functions(sym)
+}
+
+// Functions converts the value exported by a plugin or script to matcher functions.
+// Supported are maps of functions, pointers to them and functions returning them.
+func Functions(value interface{}) (map[string]govaluate.ExpressionFunction, error) {
+	switch v := value.(type) {
+	case map[string]govaluate.ExpressionFunction:
+		return v, nil
+	case *map[string]govaluate.ExpressionFunction:
+		return *v, nil
+	case func() map[string]govaluate.ExpressionFunction:
+		return v(), nil
+	case map[string]func(args ...interface{}) (interface{}, error):
+		fns := make(map[string]govaluate.ExpressionFunction, len(v))
+		for name, fn := range v {
+			fns[name] = fn
+		}
+		return fns, nil
+	case *map[string]func(args ...interface{}) (interface{}, error):
+		return Functions(*v)
+	case func() map[string]func(args ...interface{}) (interface{}, error):
+		return Functions(v())
+	}
+	return nil, fmt.Errorf("error: unsupported plugin functions of type %T", value)
+}
+
+// Source is a configured source of matcher functions
+type Source struct {
+	Kind   string `json:"kind"`
+	Source string `json:"source"`
+	// Functions restricts the loaded functions, all functions are loaded if it is empty
+	Functions []string `json:"functions,omitempty"`
+}
+
+// Config lists the sources of matcher functions
+type Config struct {
+	Plugins []Source `json:"plugins"`
+}
+
+// LoadConfig reads a JSON configuration
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Load loads the functions of all sources, later sources replace functions of earlier ones
+func (cfg *Config) Load() (map[string]govaluate.ExpressionFunction, error) {
+	res := make(map[string]govaluate.ExpressionFunction)
+	for _, src := range cfg.Plugins {
+		mutex.RLock()
+		loader, ok := loaders[src.Kind]
+		mutex.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("error: no plugin loader registered for kind %s", src.Kind)
+		}
+		fns, err := loader.Load(src.Source)
+		if err != nil {
+			return nil, fmt.Errorf("error: loading plugin %s: %w", src.Source, err)
+		}
+		if len(src.Functions) == 0 {
+			for name, fn := range fns {
+				res[name] = fn
+			}
+			continue
+		}
+		for _, name := range src.Functions {
+			fn, ok := fns[name]
+			if !ok {
+				return nil, fmt.Errorf("error: plugin %s does not export function %s", src.Source, name)
+			}
+			res[name] = fn
+		}
+	}
+	return res, nil
+}
+
+// Install loads the functions and registers them globally,
+// so they are available in all models, which are created afterwards.
+// Call it before loading the model, otherwise matchers using the functions fail to build.
+func Install(cfg *Config) error {
+	fns, err := cfg.Load()
+	if err != nil {
+		return err
+	}
+	for name, fn := range fns {
+		fm.SetFunction(name, fn)
+	}
+	return nil
+}
+
+// Apply loads the functions and sets them in an existing model, e.g. to replace functions of a running PDP.
+// Matchers are rebuilt, so they use the new functions.
+func Apply(m model.IModel, cfg *Config) error {
+	fns, err := cfg.Load()
+	if err != nil {
+		return err
+	}
+	for name, fn := range fns {
+		m.SetFunction(name, fn)
+	}
+	return m.BuildMatchers()
+}
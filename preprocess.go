@@ -0,0 +1,128 @@
+package fastac
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/oarkflow/fastac/model/defs"
+)
+
+// PreprocessFunc transforms the request values before they are matched.
+// rvals must not be modified, a new slice has to be returned instead
+type PreprocessFunc func(rDef *defs.RequestDef, rvals []interface{}) ([]interface{}, error)
+
+type preprocessor struct {
+	name string
+	fn   PreprocessFunc
+}
+
+// PreprocessError is returned, if a preprocessor fails
+type PreprocessError struct {
+	Stage string
+	Err   error
+}
+
+func (err *PreprocessError) Error() string {
+	return fmt.Sprintf("error: preprocessor %s: %s", err.Stage, err.Err)
+}
+
+func (err *PreprocessError) Unwrap() error {
+	return err.Err
+}
+
+// Option to add a preprocessor, see AddPreprocessor
+func OptionPreprocessor(name string, fn PreprocessFunc) Option {
+	return func(e *Enforcer) error {
+		e.AddPreprocessor(name, fn)
+		return nil
+	}
+}
+
+// AddPreprocessor appends a preprocessor to the pipeline, an existing preprocessor with the same name is replaced.
+// Preprocessors run in the order they have been added.
+//
+//	e.AddPreprocessor("lower_act", NormalizeArg("act", strings.ToLower))
+//	e.AddPreprocessor("clean_obj", NormalizeArg("obj", CanonicalPath))
+func (e *Enforcer) AddPreprocessor(name string, fn PreprocessFunc) {
+	for i, p := range e.preprocessors {
+		if p.name == name {
+			e.preprocessors[i].fn = fn
+			return
+		}
+	}
+	e.preprocessors = append(e.preprocessors, preprocessor{name, fn})
+}
+
+// RemovePreprocessor removes a preprocessor from the pipeline
+func (e *Enforcer) RemovePreprocessor(name string) bool {
+	for i, p := range e.preprocessors {
+		if p.name == name {
+			e.preprocessors = append(e.preprocessors[:i:i], e.preprocessors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Enforcer) preprocess(rDef *defs.RequestDef, rvals []interface{}) ([]interface{}, error) {
+	for _, p := range e.preprocessors {
+		res, err := p.fn(rDef, rvals)
+		if err != nil {
+			return nil, &PreprocessError{p.name, err}
+		}
+		rvals = res
+	}
+	return rvals, nil
+}
+
+// argIndex returns the index of a request argument in rvals
+func argIndex(rDef *defs.RequestDef, rvals []interface{}, arg string) (int, bool) {
+	args := rDef.GetArgs()
+	offset := 0
+	if len(rvals) > len(args) {
+		offset = 1
+	}
+	for i, a := range args {
+		if a == arg && i+offset < len(rvals) {
+			return i + offset, true
+		}
+	}
+	return 0, false
+}
+
+// EnrichArg returns a preprocessor, which replaces the value of a request argument with the result of fn,
+// e.g. to resolve an object id to its attributes
+func EnrichArg(arg string, fn func(value interface{}) (interface{}, error)) PreprocessFunc {
+	return func(rDef *defs.RequestDef, rvals []interface{}) ([]interface{}, error) {
+		i, ok := argIndex(rDef, rvals, arg)
+		if !ok {
+			return rvals, nil
+		}
+		value, err := fn(rvals[i])
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, len(rvals))
+		copy(res, rvals)
+		res[i] = value
+		return res, nil
+	}
+}
+
+// NormalizeArg returns a preprocessor, which applies fn to a string value of a request argument
+func NormalizeArg(arg string, fn func(string) string) PreprocessFunc {
+	return EnrichArg(arg, func(value interface{}) (interface{}, error) {
+		if s, ok := value.(string); ok {
+			return fn(s), nil
+		}
+		return value, nil
+	})
+}
+
+// CanonicalPath cleans a slash seperated path, e.g. "/a//b/../c/" becomes "/a/c"
+func CanonicalPath(p string) string {
+	if p == "" {
+		return p
+	}
+	return path.Clean(p)
+}
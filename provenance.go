@@ -0,0 +1,146 @@
+package fastac
+
+import (
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac/util"
+)
+
+// Stamp records who changed a rule, when and through which channel
+type Stamp struct {
+	Actor   string
+	Channel string
+	Time    time.Time
+}
+
+// ruleStamps holds the stamp of the last stamped change of every rule
+type ruleStamps struct {
+	mutex  sync.RWMutex
+	stamps map[string]Stamp
+}
+
+func (s *ruleStamps) set(stamp Stamp, rules ...[]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stamps == nil {
+		s.stamps = make(map[string]Stamp)
+	}
+	for _, rule := range rules {
+		s.stamps[util.Hash(rule)] = stamp
+	}
+}
+
+func (s *ruleStamps) drop(rules ...[]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, rule := range rules {
+		delete(s.stamps, util.Hash(rule))
+	}
+}
+
+func (s *ruleStamps) get(rule []string) (Stamp, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stamp, ok := s.stamps[util.Hash(rule)]
+	return stamp, ok
+}
+
+// GetStamp returns the stamp of a rule, if it has been added by a StampedMutator.
+// While listeners of the model handle the removal of a rule by a StampedMutator, the stamp of the removal is returned.
+//
+//	e.GetModel().AddListener(model.RULE_REMOVED, func(arguments ...interface{}) {
+//		stamp, _ := e.GetStamp(arguments[0].([]string))
+//		log.Println(stamp.Actor, "removed", arguments[0])
+//	})
+func (e *Enforcer) GetStamp(rule []string) (Stamp, bool) {
+	return e.stamps.get(rule)
+}
+
+// StampedMutator changes the rules of an enforcer and stamps them with its actor and channel.
+// Stamps are kept in memory and are part of the events of the publish package.
+type StampedMutator struct {
+	e       *Enforcer
+	actor   string
+	channel string
+	// lock is the write lock of the SyncedEnforcer, which created the mutator
+	lock sync.Locker
+}
+
+// WithActor returns a mutator, which stamps the rules it changes with actor
+//
+//	e.WithActor("alice").Via("admin-api").AddRule([]string{"p", "bob", "data1", "read"})
+func (e *Enforcer) WithActor(actor string) *StampedMutator {
+	return &StampedMutator{e: e, actor: actor}
+}
+
+// Via returns a copy of the mutator, which stamps the rules with channel
+func (s *StampedMutator) Via(channel string) *StampedMutator {
+	return &StampedMutator{e: s.e, actor: s.actor, channel: channel, lock: s.lock}
+}
+
+// guard takes the write lock of a SyncedEnforcer, the returned function releases it
+func (s *StampedMutator) guard() func() {
+	if s.lock == nil {
+		return func() {}
+	}
+	s.lock.Lock()
+	return s.lock.Unlock
+}
+
+func (s *StampedMutator) stamp() Stamp {
+	return Stamp{Actor: s.actor, Channel: s.channel, Time: time.Now()}
+}
+
+// AddRule adds a rule like Enforcer.AddRule and stamps it
+func (s *StampedMutator) AddRule(rule []string) (bool, error) {
+	defer s.guard()()
+	previous, stamped := s.e.stamps.get(rule)
+	s.e.stamps.set(s.stamp(), rule)
+	added, err := s.e.AddRule(rule)
+	if !added {
+		s.restore(rule, previous, stamped)
+	}
+	return added, err
+}
+
+// AddRules adds rules like Enforcer.AddRules and stamps them
+func (s *StampedMutator) AddRules(rules [][]string) error {
+	defer s.guard()()
+	s.e.stamps.set(s.stamp(), rules...)
+	err := s.e.AddRules(rules)
+	if err != nil {
+		s.e.stamps.drop(rules...)
+	}
+	return err
+}
+
+// RemoveRule removes a rule like Enforcer.RemoveRule, listeners of the removal see the stamp of the mutator
+func (s *StampedMutator) RemoveRule(rule []string) (bool, error) {
+	defer s.guard()()
+	previous, stamped := s.e.stamps.get(rule)
+	s.e.stamps.set(s.stamp(), rule)
+	removed, err := s.e.RemoveRule(rule)
+	if !removed {
+		s.restore(rule, previous, stamped)
+	}
+	return removed, err
+}
+
+// RemoveRules removes rules like Enforcer.RemoveRules, listeners of the removals see the stamp of the mutator
+func (s *StampedMutator) RemoveRules(rules [][]string) error {
+	defer s.guard()()
+	s.e.stamps.set(s.stamp(), rules...)
+	err := s.e.RemoveRules(rules)
+	s.e.stamps.drop(rules...)
+	return err
+}
+
+// restore resets the stamp of a rule, which has not been changed
+func (s *StampedMutator) restore(rule []string, previous Stamp, stamped bool) {
+	if stamped {
+		s.e.stamps.set(previous, rule)
+	} else {
+		s.e.stamps.drop(rule)
+	}
+}
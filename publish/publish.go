@@ -0,0 +1,172 @@
+// Package publish sends policy changes of an enforcer to a message broker,
+// so downstream systems like SIEMs or cache invalidators can react to authorization changes.
+//
+// The broker client is plugged in as Producer, e.g. for NATS:
+//
+//	p := publish.NewPublisher(e, publish.ProducerFunc(func(topic string, key, value []byte) error {
+//		return nc.Publish(topic, value)
+//	}), "fastac.policy")
+//	defer p.Close()
+//
+// or for Kafka (segmentio/kafka-go):
+//
+//	publish.ProducerFunc(func(topic string, key, value []byte) error {
+//		return w.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value})
+//	})
+package publish
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/storage"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Types of published events
+const (
+	RuleAdded   = "rule_added"
+	RuleRemoved = "rule_removed"
+	Flushed     = "flushed"
+)
+
+// Producer sends a message to a topic of a message broker.
+// key is the hash of the rule and can be used for partitioning, it is nil for flush events.
+type Producer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// ProducerFunc adapts a function to the Producer interface
+type ProducerFunc func(topic string, key, value []byte) error
+
+func (fn ProducerFunc) Produce(topic string, key, value []byte) error {
+	return fn(topic, key, value)
+}
+
+// Event is the JSON payload of a published message
+type Event struct {
+	Type       string    `json:"type"`
+	Version    uint64    `json:"version"`
+	Time       time.Time `json:"time"`
+	Rule       []string  `json:"rule,omitempty"`
+	Provenance string    `json:"provenance,omitempty"`
+	// Actor and Channel are the stamp of a rule changed by a fastac.StampedMutator
+	Actor      string `json:"actor,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	Operations int    `json:"operations,omitempty"`
+}
+
+type listener struct {
+	observable api.IAddRemoveListener
+	event      emitter.EventType
+	listener   *emitter.Listener
+}
+
+// Publisher publishes the rule changes and flushes of an enforcer.
+// Every event carries a version, which is incremented for each event.
+type Publisher struct {
+	e        *fastac.Enforcer
+	producer Producer
+	topic    string
+	version  uint64
+
+	mutex     sync.Mutex
+	onError   func(event *Event, err error)
+	listeners []listener
+}
+
+// NewPublisher starts publishing the events of e to topic
+func NewPublisher(e *fastac.Enforcer, producer Producer, topic string) *Publisher {
+	p := &Publisher{
+		e:        e,
+		producer: producer,
+		topic:    topic,
+	}
+
+	p.listen(e.GetModel(), model.RULE_ADDED, func(arguments ...interface{}) {
+		p.publishRule(RuleAdded, arguments[0].([]string))
+	})
+	p.listen(e.GetModel(), model.RULES_ADDED, func(arguments ...interface{}) {
+		for _, rule := range arguments[0].([][]string) {
+			p.publishRule(RuleAdded, rule)
+		}
+	})
+	p.listen(e.GetModel(), model.RULE_REMOVED, func(arguments ...interface{}) {
+		p.publishRule(RuleRemoved, arguments[0].([]string))
+	})
+	p.listen(e.GetStorageController(), storage.FLUSHED, func(arguments ...interface{}) {
+		p.Publish(&Event{Type: Flushed, Operations: arguments[0].(int)})
+	})
+	return p
+}
+
+func (p *Publisher) listen(observable api.IAddRemoveListener, event emitter.EventType, handler emitter.HandleFunc) {
+	l := observable.AddListener(event, handler)
+	p.listeners = append(p.listeners, listener{observable, event, l})
+}
+
+// SetErrorHandler sets a handler, which is called if an event could not be published.
+// Errors are dropped by default, since the policy change itself already succeeded.
+func (p *Publisher) SetErrorHandler(fn func(event *Event, err error)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.onError = fn
+}
+
+// SetVersion sets the version of the last event, e.g. to continue the versions of a previous process
+func (p *Publisher) SetVersion(version uint64) {
+	atomic.StoreUint64(&p.version, version)
+}
+
+// Version returns the version of the last published event
+func (p *Publisher) Version() uint64 {
+	return atomic.LoadUint64(&p.version)
+}
+
+func (p *Publisher) publishRule(typ string, rule []string) {
+	event := &Event{Type: typ, Rule: rule}
+	event.Provenance, _ = p.e.GetProvenance(rule)
+	if stamp, ok := p.e.GetStamp(rule); ok {
+		event.Actor, event.Channel = stamp.Actor, stamp.Channel
+	}
+	p.Publish(event)
+}
+
+// Publish assigns the next version to event and sends it to the producer
+func (p *Publisher) Publish(event *Event) error {
+	event.Version = atomic.AddUint64(&p.version, 1)
+	event.Time = time.Now()
+
+	var key []byte
+	if event.Rule != nil {
+		key = []byte(util.Hash(event.Rule))
+	}
+
+	value, err := json.Marshal(event)
+	if err == nil {
+		err = p.producer.Produce(p.topic, key, value)
+	}
+	if err != nil {
+		p.mutex.Lock()
+		onError := p.onError
+		p.mutex.Unlock()
+		if onError != nil {
+			onError(event, err)
+		}
+	}
+	return err
+}
+
+// Close stops publishing events
+func (p *Publisher) Close() {
+	for _, l := range p.listeners {
+		l.observable.RemoveListener(l.event, l.listener)
+	}
+	p.listeners = nil
+}
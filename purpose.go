@@ -0,0 +1,101 @@
+package fastac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// PURPOSE is the name of the request and policy argument, which carries the purpose of use
+//
+//	r = sub, obj, act, purpose
+//	p = sub, obj, act, purpose
+//	m = r.sub == p.sub && r.obj == p.obj && r.act == p.act && purposeMatch(r.purpose, p.purpose)
+//
+//	p, support, customer.contact, read, support|billing
+const PURPOSE = "purpose"
+
+// RequirePurpose returns a preprocessor, which rejects requests without purpose,
+// requests of request definitions without purpose argument are passed unchanged
+//
+//	e.AddPreprocessor("purpose", RequirePurpose())
+func RequirePurpose() PreprocessFunc {
+	return func(rDef *defs.RequestDef, rvals []interface{}) ([]interface{}, error) {
+		i, ok := argIndex(rDef, rvals, PURPOSE)
+		if !ok {
+			hasPurpose := false
+			for _, arg := range rDef.GetArgs() {
+				hasPurpose = hasPurpose || arg == PURPOSE
+			}
+			if hasPurpose {
+				return nil, errors.New(str.ERR_PURPOSE_REQUIRED)
+			}
+			return rvals, nil
+		}
+		if purpose, ok := rvals[i].(string); !ok || purpose == "" {
+			return nil, errors.New(str.ERR_PURPOSE_REQUIRED)
+		}
+		return rvals, nil
+	}
+}
+
+// PurposesFor reports the purposes, which grant access to a data category, with their granting rules.
+// It considers the allow rules of all policy types with the arguments purpose and categoryArg,
+// whose category equals category or matches it as glob pattern.
+// Rules listing several purposes are reported for each of them.
+//
+//	purposes, _ := e.PurposesFor("obj", "customer.contact")
+//	// map[billing:[[p support customer.contact read support|billing]] support:[...]]
+func (e *Enforcer) PurposesFor(categoryArg, category string) (map[string][][]string, error) {
+	res := make(map[string][][]string)
+	var err error
+	e.model.RangeDefs(m.P_SEC, func(key string, def defs.IDef) bool {
+		pDef := def.(*defs.PolicyDef)
+		purposeArg, catArg := key+"_"+PURPOSE, key+"_"+categoryArg
+		if !pDef.Has(purposeArg) || !pDef.Has(catArg) {
+			return true
+		}
+		p, ok := e.model.GetPolicy(key)
+		if !ok {
+			err = fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
+			return false
+		}
+		p.Range(func(rule []string) bool {
+			if pDef.GetEft(rule) != eft.Allow {
+				return true
+			}
+			value, _ := pDef.GetParameter(rule, catArg)
+			if value != category {
+				if matched, _ := util.GlobMatch(category, value); !matched {
+					return true
+				}
+			}
+			purposes, _ := pDef.GetParameter(rule, purposeArg)
+			for _, purpose := range strings.Split(purposes, "|") {
+				if purpose = strings.TrimSpace(purpose); purpose != "" {
+					res[purpose] = append(res[purpose], append([]string{key}, rule...))
+				}
+			}
+			return true
+		})
+		return true
+	})
+	return res, err
+}
+
+// Purposes returns the sorted purposes of a report of PurposesFor
+func Purposes(report map[string][][]string) []string {
+	purposes := make([]string, 0, len(report))
+	for purpose := range report {
+		purposes = append(purposes, purpose)
+	}
+	sort.Strings(purposes)
+	return purposes
+}
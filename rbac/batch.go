@@ -0,0 +1,119 @@
+package rbac
+
+import "context"
+
+// IBatchRoleManager is implemented by role managers, which answer many role checks at once
+type IBatchRoleManager interface {
+	// HasLinks determines for every pair whether role: pair[0] inherits role: pair[1]
+	HasLinks(pairs [][2]string, domain ...string) ([]bool, error)
+}
+
+// HasLinks calls rm.HasLinks, if rm implements IBatchRoleManager, otherwise rm.HasLink for every pair
+func HasLinks(rm IRoleManager, pairs [][2]string, domain ...string) ([]bool, error) {
+	if brm, ok := rm.(IBatchRoleManager); ok {
+		return brm.HasLinks(pairs, domain...)
+	}
+	res := make([]bool, len(pairs))
+	for i, pair := range pairs {
+		ok, err := rm.HasLink(pair[0], pair[1], domain...)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = ok
+	}
+	return res, nil
+}
+
+// HasLinks determines for every pair whether role: pair[0] inherits role: pair[1].
+// The roles of a user are traversed once and shared by all pairs of the user.
+// It returns a *TraversalError, if a traversal exceeds the budget of the role manager.
+func (rm *RoleManager) HasLinks(pairs [][2]string, domains ...string) ([]bool, error) {
+	res := make([]bool, len(pairs))
+	byUser := make(map[string][]int)
+	users := []string{}
+	for i, pair := range pairs {
+		if _, ok := byUser[pair[0]]; !ok {
+			users = append(users, pair[0])
+		}
+		byUser[pair[0]] = append(byUser[pair[0]], i)
+	}
+
+	for _, name := range users {
+		indices := byUser[name]
+		user, created := rm.getRole(name)
+		if created {
+			defer rm.removeRole(user.name)
+		}
+		for _, i := range indices {
+			if role, created := rm.getRole(pairs[i][1]); created {
+				defer rm.removeRole(role.name)
+			}
+		}
+
+		reachable, err := rm.reachableRoles(user)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indices {
+			res[i] = rm.reaches(name, pairs[i][1], reachable)
+		}
+	}
+	return res, nil
+}
+
+// reachableRoles returns all roles inherited by user within maxHierarchyLevel, including the user itself
+func (rm *RoleManager) reachableRoles(user *Role) (map[string]*Role, error) {
+	t, cancel := newTraversal(context.Background(), rm.budget, user.name, "*")
+	defer cancel()
+
+	reachable := map[string]*Role{user.name: user}
+	roles := map[string]*Role{user.name: user}
+	for level := rm.maxHierarchyLevel; level > 0 && len(roles) > 0; level-- {
+		if err := t.level(len(roles)); err != nil {
+			return nil, err
+		}
+		nextRoles := map[string]*Role{}
+		for _, role := range roles {
+			if err := t.visit(); err != nil {
+				return nil, err
+			}
+			if level == 1 {
+				continue
+			}
+			role.rangeRoles(func(key, value interface{}) bool {
+				if _, ok := reachable[key.(string)]; !ok {
+					reachable[key.(string)] = value.(*Role)
+					nextRoles[key.(string)] = value.(*Role)
+				}
+				return true
+			})
+		}
+		roles = nextRoles
+	}
+	return reachable, nil
+}
+
+func (rm *RoleManager) reaches(name1, name2 string, reachable map[string]*Role) bool {
+	if _, ok := reachable[name2]; ok {
+		return true
+	}
+	if rm.matcher == nil {
+		return false
+	}
+	for name := range reachable {
+		if rm.match(name, name2) {
+			return true
+		}
+	}
+	return rm.match(name1, name2)
+}
+
+// HasLinks determines for every pair whether role: pair[0] inherits role: pair[1] in the domain
+func (dm *DomainManager) HasLinks(pairs [][2]string, domains ...string) ([]bool, error) {
+	domain, subdomains, err := dm.getDomain(domains...)
+	if err != nil {
+		return nil, err
+	}
+	rm := dm.getRoleManager(domain, false, subdomains...)
+	return HasLinks(rm, pairs, subdomains...)
+}
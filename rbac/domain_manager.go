@@ -15,9 +15,11 @@
 package rbac
 
 import (
+	"context"
 	"strings"
 	"sync"
 
+	"github.com/oarkflow/fastac/logger"
 	"github.com/oarkflow/fastac/util"
 )
 
@@ -29,7 +31,9 @@ type DomainManager struct {
 	maxHierarchyLevel int
 	matcher           util.IMatcher
 	domainMatcher     util.IMatcher
-	matchingFuncCache *util.SyncLRUCache
+	matchingFuncCache util.Cache
+	budget            TraversalBudget
+	logger            logger.Logger
 }
 
 // NewDomainManager is the constructor for creating an instance of the
@@ -49,6 +53,28 @@ func (dm *DomainManager) SetMatcher(matcher util.IMatcher) {
 	})
 }
 
+// SetLogger sets the logger of all domains
+func (dm *DomainManager) SetLogger(l logger.Logger) {
+	dm.logger = l
+	dm.rmMap.Range(func(key, value interface{}) bool {
+		if rm, ok := value.(ILoggingRoleManager); ok {
+			rm.SetLogger(l)
+		}
+		return true
+	})
+}
+
+// SetTraversalBudget sets the budget of every HasLink query of all domains
+func (dm *DomainManager) SetTraversalBudget(budget TraversalBudget) {
+	dm.budget = budget
+	dm.rmMap.Range(func(key, value interface{}) bool {
+		if rm, ok := value.(IBudgetRoleManager); ok {
+			rm.SetTraversalBudget(budget)
+		}
+		return true
+	})
+}
+
 // SetDomainMatcher support use domain pattern in g
 func (dm *DomainManager) SetDomainMatcher(matcher util.IMatcher) {
 	dm.domainMatcher = matcher
@@ -59,6 +85,16 @@ func (dm *DomainManager) SetDomainMatcher(matcher util.IMatcher) {
 	dm.rebuild()
 }
 
+// Renew returns a domain manager with the matchers, the budget and the logger of dm, but without links
+func (dm *DomainManager) Renew() IRoleManager {
+	renewed := NewDomainManager(dm.maxHierarchyLevel)
+	renewed.matcher = dm.matcher
+	renewed.domainMatcher = dm.domainMatcher
+	renewed.budget = dm.budget
+	renewed.logger = dm.logger
+	return renewed
+}
+
 // clears the map of RoleManagers
 func (dm *DomainManager) rebuild() {
 	rmMap := dm.rmMap
@@ -73,7 +109,7 @@ func (dm *DomainManager) rebuild() {
 func (dm *DomainManager) Clear() error {
 	dm.rmMap = &sync.Map{}
 	dm.patternMap = &sync.Map{}
-	dm.matchingFuncCache = util.NewSyncLRUCache(100)
+	dm.matchingFuncCache = util.NewShardedLRUCache(100)
 	return nil
 }
 
@@ -132,11 +168,17 @@ func (dm *DomainManager) getRoleManager(domain string, store bool, subdomains ..
 
 	if rm, ok = dm.load(domain); !ok {
 		if domain != defaultDomain {
-			rm = NewDomainManager(dm.maxHierarchyLevel - 1)
-			rm.SetMatcher(dm.matcher)
-			rm.SetDomainMatcher(dm.domainMatcher)
+			domainManager := NewDomainManager(dm.maxHierarchyLevel - 1)
+			domainManager.SetMatcher(dm.matcher)
+			domainManager.SetDomainMatcher(dm.domainMatcher)
+			domainManager.budget = dm.budget
+			domainManager.logger = dm.logger
+			rm = domainManager
 		} else {
-			rm = newRoleManagerWithMatchingFunc(dm.maxHierarchyLevel-1, dm.matcher)
+			roleManager := newRoleManagerWithMatchingFunc(dm.maxHierarchyLevel-1, dm.matcher)
+			roleManager.budget = dm.budget
+			roleManager.logger = dm.logger
+			rm = roleManager
 		}
 		if store {
 			dm.rmMap.Store(domain, rm)
@@ -203,6 +245,21 @@ func (dm *DomainManager) HasLink(name1 string, name2 string, domains ...string)
 	return rm.HasLink(name1, name2, subdomains...)
 }
 
+// HasLinkWithContext determines whether role: name1 inherits role: name2 and returns statistics of the traversal.
+// It returns a *TraversalError, if ctx is done or the traversal exceeds the budget of the role manager.
+func (dm *DomainManager) HasLinkWithContext(ctx context.Context, name1 string, name2 string, domains ...string) (bool, TraversalStats, error) {
+	domain, subdomains, err := dm.getDomain(domains...)
+	if err != nil {
+		return false, TraversalStats{}, err
+	}
+	rm := dm.getRoleManager(domain, false, subdomains...)
+	if brm, ok := rm.(IBudgetRoleManager); ok {
+		return brm.HasLinkWithContext(ctx, name1, name2, subdomains...)
+	}
+	ok, err := rm.HasLink(name1, name2, subdomains...)
+	return ok, TraversalStats{}, err
+}
+
 // GetRoles gets the roles that a subject inherits.
 func (dm *DomainManager) GetRoles(name string, domains ...string) ([]string, error) {
 	domain, subdomains, err := dm.getDomain(domains...)
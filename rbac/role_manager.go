@@ -15,9 +15,11 @@
 package rbac
 
 import (
+	"context"
 	"strings"
 	"sync"
 
+	"github.com/oarkflow/fastac/logger"
 	"github.com/oarkflow/fastac/util"
 )
 
@@ -30,7 +32,9 @@ type RoleManager struct {
 	maxHierarchyLevel int
 	matcher           util.IMatcher
 	domainMatcher     util.IMatcher
-	matchingFuncCache *util.SyncLRUCache
+	matchingFuncCache util.Cache
+	budget            TraversalBudget
+	logger            logger.Logger
 }
 
 // NewRoleManager is the constructor for creating an instance of the
@@ -49,6 +53,15 @@ func newRoleManagerWithMatchingFunc(maxHierarchyLevel int, matcher util.IMatcher
 	return rm
 }
 
+// Renew returns a role manager with the matchers, the budget and the logger of rm, but without links
+func (rm *RoleManager) Renew() IRoleManager {
+	renewed := newRoleManagerWithMatchingFunc(rm.maxHierarchyLevel, rm.matcher)
+	renewed.domainMatcher = rm.domainMatcher
+	renewed.budget = rm.budget
+	renewed.logger = rm.logger
+	return renewed
+}
+
 // rebuilds role cache
 func (rm *RoleManager) rebuild() {
 	roles := rm.allRoles
@@ -145,6 +158,16 @@ func (rm *RoleManager) SetMatcher(matcher util.IMatcher) {
 	rm.rebuild()
 }
 
+// SetLogger sets the logger of aborted traversals (default: logger.Default())
+func (rm *RoleManager) SetLogger(l logger.Logger) {
+	rm.logger = l
+}
+
+// SetTraversalBudget sets the budget of every HasLink query
+func (rm *RoleManager) SetTraversalBudget(budget TraversalBudget) {
+	rm.budget = budget
+}
+
 // SetDomainMatcher support use domain pattern in g
 func (rm *RoleManager) SetDomainMatcher(matcher util.IMatcher) {
 	rm.domainMatcher = matcher
@@ -152,7 +175,7 @@ func (rm *RoleManager) SetDomainMatcher(matcher util.IMatcher) {
 
 // Clear clears all stored data and resets the role manager to the initial state.
 func (rm *RoleManager) Clear() error {
-	rm.matchingFuncCache = util.NewSyncLRUCache(100)
+	rm.matchingFuncCache = util.NewShardedLRUCache(100)
 	rm.allRoles = &sync.Map{}
 	rm.patternRoles = &sync.Map{}
 	return nil
@@ -185,9 +208,17 @@ func (rm *RoleManager) DeleteLink(name1 string, name2 string, domains ...string)
 }
 
 // HasLink determines whether role: name1 inherits role: name2.
+// It returns a *TraversalError, if the traversal exceeds the budget of the role manager.
 func (rm *RoleManager) HasLink(name1 string, name2 string, domains ...string) (bool, error) {
+	ok, _, err := rm.HasLinkWithContext(context.Background(), name1, name2, domains...)
+	return ok, err
+}
+
+// HasLinkWithContext determines whether role: name1 inherits role: name2 and returns statistics of the traversal.
+// It returns a *TraversalError, if ctx is done or the traversal exceeds the budget of the role manager.
+func (rm *RoleManager) HasLinkWithContext(ctx context.Context, name1 string, name2 string, domains ...string) (bool, TraversalStats, error) {
 	if name1 == name2 || (rm.matcher != nil && rm.match(name1, name2)) {
-		return true, nil
+		return true, TraversalStats{}, nil
 	}
 
 	user, userCreated := rm.getRole(name1)
@@ -200,18 +231,31 @@ func (rm *RoleManager) HasLink(name1 string, name2 string, domains ...string) (b
 		defer rm.removeRole(role.name)
 	}
 
-	return rm.hasLinkHelper(role.name, map[string]*Role{user.name: user}, rm.maxHierarchyLevel), nil
+	t, cancel := newTraversal(ctx, rm.budget, name1, name2)
+	defer cancel()
+	ok, err := rm.hasLinkHelper(t, role.name, map[string]*Role{user.name: user}, rm.maxHierarchyLevel)
+	if err != nil {
+		logger.Or(rm.logger).Warn("role traversal aborted", "name1", name1, "name2", name2,
+			"levels", t.stats.Levels, "visited", t.stats.Visited, "error", err)
+	}
+	return ok, t.stats, err
 }
 
-func (rm *RoleManager) hasLinkHelper(targetName string, roles map[string]*Role, level int) bool {
+func (rm *RoleManager) hasLinkHelper(t *traversal, targetName string, roles map[string]*Role, level int) (bool, error) {
 	if level <= 0 || len(roles) == 0 {
-		return false
+		return false, nil
+	}
+	if err := t.level(len(roles)); err != nil {
+		return false, err
 	}
 
 	nextRoles := map[string]*Role{}
 	for _, role := range roles {
+		if err := t.visit(); err != nil {
+			return false, err
+		}
 		if targetName == role.name || (rm.matcher != nil && rm.match(role.name, targetName)) {
-			return true
+			return true, nil
 		}
 		role.rangeRoles(func(key, value interface{}) bool {
 			nextRoles[key.(string)] = value.(*Role)
@@ -219,7 +263,7 @@ func (rm *RoleManager) hasLinkHelper(targetName string, roles map[string]*Role,
 		})
 	}
 
-	return rm.hasLinkHelper(targetName, nextRoles, level-1)
+	return rm.hasLinkHelper(t, targetName, nextRoles, level-1)
 }
 
 // GetRoles gets the roles that a user inherits.
@@ -15,32 +15,23 @@
 package rbac
 
 import (
+	"context"
+
 	"github.com/oarkflow/govaluate"
 
+	"github.com/oarkflow/fastac/api"
 	"github.com/oarkflow/fastac/util"
 )
 
-// RoleManager provides interface to define the operations for managing roles.
-type IRoleManager interface {
-	// Clear clears all stored data and resets the role manager to the initial state.
-	Clear() error
-	// AddLink adds the inheritance link between two roles. role: name1 and role: name2.
-	// domain is a prefix to the roles (can be used for other purposes).
-	AddLink(name1 string, name2 string, domain ...string) (bool, error)
-	// DeleteLink deletes the inheritance link between two roles. role: name1 and role: name2.
-	// domain is a prefix to the roles (can be used for other purposes).
-	DeleteLink(name1 string, name2 string, domain ...string) (bool, error)
-	// HasLink determines whether a link exists between two roles. role: name1 inherits role: name2.
-	// domain is a prefix to the roles (can be used for other purposes).
-	HasLink(name1 string, name2 string, domain ...string) (bool, error)
-	// GetRoles gets the roles that a user inherits.
-	// domain is a prefix to the roles (can be used for other purposes).
-	GetRoles(name string, domain ...string) ([]string, error)
-	// GetUsers gets the users that inherits a role.
-	// domain is a prefix to the users (can be used for other purposes).
-	GetUsers(name string, domain ...string) ([]string, error)
+// IRoleManager provides interface to define the operations for managing roles, see api.RoleManager
+type IRoleManager = api.RoleManager
 
-	Range(fn func(name1, name2 string, domain ...string) bool)
+// IRenewableRoleManager is implemented by role managers, which can create an empty role manager of the same kind,
+// e.g. for the copies of an enforcer
+type IRenewableRoleManager interface {
+	IRoleManager
+	// Renew returns a role manager with the settings, but without the links of the role manager
+	Renew() IRoleManager
 }
 
 type IDefaultRoleManager interface {
@@ -50,6 +41,25 @@ type IDefaultRoleManager interface {
 	SetDomainMatcher(fn util.IMatcher)
 }
 
+// GenerateGFunctionWithContext is like GenerateGFunction, but the role lookups of role managers,
+// which implement IBudgetRoleManager, stop with a *TraversalError, if ctx is done
+func GenerateGFunctionWithContext(ctx context.Context, rm IRoleManager) govaluate.ExpressionFunction {
+	brm, ok := rm.(IBudgetRoleManager)
+	if !ok {
+		return GenerateGFunction(rm)
+	}
+	return func(args ...interface{}) (interface{}, error) {
+		name1 := args[0].(string)
+		name2 := args[1].(string)
+		domains := make([]string, 0, len(args)-2)
+		for _, domain := range args[2:] {
+			domains = append(domains, domain.(string))
+		}
+		ok, _, err := brm.HasLinkWithContext(ctx, name1, name2, domains...)
+		return ok, err
+	}
+}
+
 // GenerateGFunction is the factory method of the g(_, _) function.
 func GenerateGFunction(rm IRoleManager) govaluate.ExpressionFunction {
 
@@ -0,0 +1,44 @@
+package rbac
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/fastac/util"
+)
+
+// benchRoleMatching checks the roles of users against pattern roles from parallel goroutines, the matches are cached in cache
+func benchRoleMatching(b *testing.B, cache util.Cache) {
+	rm := NewRoleManager(10)
+	rm.SetMatcher(util.NewMatcher(func(str string) bool {
+		return strings.Contains(str, "*")
+	}, util.PathMatch))
+	rm.matchingFuncCache = cache
+	for i := 0; i < 10; i++ {
+		_, _ = rm.AddLink("/team"+strconv.Itoa(i)+"/*", "member"+strconv.Itoa(i))
+	}
+	users := make([]string, 50)
+	for i := range users {
+		users[i] = "/team" + strconv.Itoa(i%10) + "/user" + strconv.Itoa(i)
+		_, _ = rm.AddLink(users[i], "user")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = rm.HasLink(users[i%len(users)], "member"+strconv.Itoa(i%10))
+			i++
+		}
+	})
+}
+
+func BenchmarkRoleMatchingSyncLRUCache(b *testing.B) {
+	benchRoleMatching(b, util.NewSyncLRUCache(100))
+}
+
+func BenchmarkRoleMatchingShardedLRUCache(b *testing.B) {
+	benchRoleMatching(b, util.NewShardedLRUCache(100))
+}
@@ -1,22 +1,39 @@
 package rbac
 
 import (
+	"sync"
+
 	em "github.com/oarkflow/fastac/emitter"
 	"github.com/oarkflow/fastac/model/policy"
+	"github.com/oarkflow/fastac/util"
 )
 
 type RolePolicy struct {
 	rm IRoleManager
 	*em.Emitter
+
+	// ruleMap indexes the links of rm by their hash, see util.Hash
+	mutex   sync.RWMutex
+	ruleMap map[string][]string
 }
 
 func NewRolePolicy(rm IRoleManager) *RolePolicy {
 	emitter := em.NewEmitter(false)
-	return &RolePolicy{rm, emitter}
+	p := &RolePolicy{rm: rm, Emitter: emitter, ruleMap: make(map[string][]string)}
+	p.Range(func(rule []string) bool {
+		p.ruleMap[util.Hash(rule)] = rule
+		return true
+	})
+	return p
 }
 
 func (p *RolePolicy) AddRule(rule []string) (bool, error) {
+	p.mutex.Lock()
 	added, err := p.rm.AddLink(rule[0], rule[1], rule[2:]...)
+	if added {
+		p.ruleMap[util.Hash(rule)] = rule
+	}
+	p.mutex.Unlock()
 	if !added || err != nil {
 		return added, err
 	}
@@ -24,8 +41,34 @@ func (p *RolePolicy) AddRule(rule []string) (bool, error) {
 	return true, nil
 }
 
+func (p *RolePolicy) AddRules(rules [][]string) ([][]string, error) {
+	added := make([][]string, 0, len(rules))
+	var err error
+	p.mutex.Lock()
+	for _, rule := range rules {
+		var ok bool
+		if ok, err = p.rm.AddLink(rule[0], rule[1], rule[2:]...); err != nil {
+			break
+		}
+		if ok {
+			p.ruleMap[util.Hash(rule)] = rule
+			added = append(added, rule)
+		}
+	}
+	p.mutex.Unlock()
+	if len(added) > 0 {
+		p.Emitter.EmitEvent(policy.EVT_RULES_ADDED, added)
+	}
+	return added, err
+}
+
 func (p *RolePolicy) RemoveRule(rule []string) (bool, error) {
+	p.mutex.Lock()
 	removed, err := p.rm.DeleteLink(rule[0], rule[1], rule[2:]...)
+	if removed {
+		delete(p.ruleMap, util.Hash(rule))
+	}
+	p.mutex.Unlock()
 	if !removed || err != nil {
 		return removed, err
 	}
@@ -40,11 +83,29 @@ func (p *RolePolicy) Range(fn func(rule []string) bool) {
 	})
 }
 
+// HasRule returns true, if the link is present.
+// Unlike HasLink of the role manager, inherited links are not considered.
+func (p *RolePolicy) HasRule(rule []string) bool {
+	_, ok := p.GetRule(util.Hash(rule))
+	return ok
+}
+
+// GetRule returns the link with the given key, see util.Hash
+func (p *RolePolicy) GetRule(key string) ([]string, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	rule, ok := p.ruleMap[key]
+	return rule, ok
+}
+
 func (p *RolePolicy) GetDistinct(columns []int) ([][]string, error) {
 	return policy.GetDistinct(p, columns)
 }
 
 func (p *RolePolicy) Clear() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ruleMap = make(map[string][]string)
 	return p.rm.Clear()
 }
 
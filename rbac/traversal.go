@@ -0,0 +1,110 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oarkflow/fastac/logger"
+)
+
+// TraversalBudget bounds the role graph traversal of HasLink, zero values disable a limit
+type TraversalBudget struct {
+	// MaxVisited is the maximum number of roles visited by a single query
+	MaxVisited int
+	// Timeout is the maximum duration of a single query
+	Timeout time.Duration
+}
+
+// TraversalStats describes the breadth of a role graph traversal
+type TraversalStats struct {
+	// Levels is the number of hierarchy levels, which were expanded
+	Levels int
+	// Visited is the number of roles, which were visited
+	Visited int
+	// MaxBreadth is the largest number of roles on a single level
+	MaxBreadth int
+}
+
+// ILoggingRoleManager is implemented by role managers, which log aborted traversals
+type ILoggingRoleManager interface {
+	SetLogger(l logger.Logger)
+}
+
+// TraversalError is returned, if a traversal exceeds its budget or its context is done
+type TraversalError struct {
+	Name1 string
+	Name2 string
+	Stats TraversalStats
+	// Err is the error of the context or nil, if MaxVisited was exceeded
+	Err error
+}
+
+func (err *TraversalError) Error() string {
+	reason := "maximum number of visited roles"
+	if err.Err != nil {
+		reason = err.Err.Error()
+	}
+	return fmt.Sprintf("error: role traversal budget exceeded for %s -> %s (%s, %d levels, %d roles visited)",
+		err.Name1, err.Name2, reason, err.Stats.Levels, err.Stats.Visited)
+}
+
+func (err *TraversalError) Unwrap() error {
+	return err.Err
+}
+
+// IBudgetRoleManager is implemented by role managers, which support bounded traversals
+type IBudgetRoleManager interface {
+	// SetTraversalBudget sets the budget of every HasLink query
+	SetTraversalBudget(budget TraversalBudget)
+	// HasLinkWithContext is HasLink, which stops, if ctx is done or the budget is exceeded.
+	// It returns a *TraversalError in that case.
+	HasLinkWithContext(ctx context.Context, name1 string, name2 string, domain ...string) (bool, TraversalStats, error)
+}
+
+// checks the context every checkInterval visited roles within a level
+const checkInterval = 64
+
+type traversal struct {
+	ctx    context.Context
+	budget TraversalBudget
+	stats  TraversalStats
+	name1  string
+	name2  string
+}
+
+func newTraversal(ctx context.Context, budget TraversalBudget, name1, name2 string) (*traversal, context.CancelFunc) {
+	cancel := func() {}
+	if budget.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, budget.Timeout)
+	}
+	return &traversal{ctx: ctx, budget: budget, name1: name1, name2: name2}, cancel
+}
+
+// level records a new level of n roles
+func (t *traversal) level(n int) error {
+	t.stats.Levels++
+	if n > t.stats.MaxBreadth {
+		t.stats.MaxBreadth = n
+	}
+	return t.check()
+}
+
+// visit records a visited role
+func (t *traversal) visit() error {
+	t.stats.Visited++
+	if t.budget.MaxVisited > 0 && t.stats.Visited > t.budget.MaxVisited {
+		return &TraversalError{t.name1, t.name2, t.stats, nil}
+	}
+	if t.stats.Visited%checkInterval == 0 {
+		return t.check()
+	}
+	return nil
+}
+
+func (t *traversal) check() error {
+	if err := t.ctx.Err(); err != nil {
+		return &TraversalError{t.name1, t.name2, t.stats, err}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package fastac
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/str"
+)
+
+// PanicError is returned, if a panic occurred during the evaluation of a request,
+// e.g. in a custom function or role manager
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("error: panic during evaluation: %v", err.Value)
+}
+
+// Option to allow requests, if their evaluation fails (default: disabled)
+// Enforce returns the error in both cases, requests are denied if fail-open is disabled
+func OptionFailOpen(enable bool) Option {
+	return func(e *Enforcer) error {
+		e.failOpen = enable
+		return nil
+	}
+}
+
+// Option to set the effect of requests, which no matching rule determines (default: the effect of the policy effect expression)
+// Without this option, some(where (p.eft == allow)) denies and !some(where (p.eft == deny)) allows such requests.
+// eft.Allow keeps internal tools available, if their policy is incomplete, errors are still handled by OptionFailOpen.
+//
+//	NewEnforcer(model, adapter, OptionDefaultEffect(eft.Allow))
+func OptionDefaultEffect(effect types.Effect) Option {
+	return func(e *Enforcer) error {
+		if effect != eft.Allow && effect != eft.Deny {
+			return fmt.Errorf(str.ERR_DEFAULT_EFFECT, effect)
+		}
+		e.defaultEffect = &effect
+		return nil
+	}
+}
+
+// containsEffect returns true, if one of the effects equals effect
+func containsEffect(effects []types.Effect, effect types.Effect) bool {
+	for _, e := range effects {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverPanic converts a panic to a *PanicError
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{r, debug.Stack()}
+	}
+}
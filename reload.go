@@ -0,0 +1,67 @@
+package fastac
+
+import (
+	"context"
+
+	"github.com/oarkflow/fastac/storage"
+	a "github.com/oarkflow/fastac/storage/adapter"
+)
+
+// ReloadPolicy replaces the rules of the model by the rules of the storage adapter, see ReloadPolicyCtx
+func (e *Enforcer) ReloadPolicy() error {
+	return e.ReloadPolicyCtx(context.Background())
+}
+
+// ReloadPolicyCtx loads the rules of the storage adapter and applies only the differences to the model:
+// rules missing in the adapter are removed and new rules are added, emitting RULE_REMOVED and RULES_ADDED.
+// Unlike clearing the model and loading it again, the links of unchanged roles and the indexes of the matchers are kept
+// and decision caches are kept warm, if nothing changed.
+// The differences are applied rule by rule, removed rules first. Requests of a SyncedEnforcer wait for the write lock
+// and see either the old or the new rules, concurrent requests of an Enforcer may see a partially applied reload.
+func (e *Enforcer) ReloadPolicyCtx(ctx context.Context) error {
+	if err := e.own(); err != nil {
+		return err
+	}
+	rs := a.NewRuleSet()
+	var err error
+	if ca, ok := e.adapter.(storage.ContextAdapter); ok {
+		err = ca.LoadPolicyContext(ctx, e.loadTarget(rs))
+	} else if err = ctx.Err(); err == nil {
+		err = e.adapter.LoadPolicy(e.loadTarget(rs))
+	}
+	if err != nil {
+		e.GetLogger().Error("reloading policy failed", "error", err)
+		return err
+	}
+
+	if e.sc.Enabled() {
+		e.sc.Disable()
+		defer e.sc.Enable()
+	}
+	removed := [][]string{}
+	e.model.RangeRules(func(rule []string) bool {
+		if !rs.HasRule(rule) {
+			removed = append(removed, rule)
+		}
+		return true
+	})
+	for _, rule := range removed {
+		if _, err := e.model.RemoveRule(rule); err != nil {
+			return err
+		}
+	}
+	added := [][]string{}
+	rs.RangeRules(func(rule []string) bool {
+		if !e.model.HasRule(rule) {
+			added = append(added, rule)
+		}
+		return true
+	})
+	if len(added) > 0 {
+		if _, err := e.model.AddRules(added); err != nil {
+			return err
+		}
+	}
+	e.GetLogger().Debug("policy reloaded", "added", len(added), "removed", len(removed))
+	return e.checkLoaded()
+}
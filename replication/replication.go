@@ -0,0 +1,567 @@
+// Package replication replicates the rules of enforcers between sites, which mutate them independently.
+//
+// The rule set of each site is an observed-remove set (OR-Set CRDT):
+// every add is tagged with a unique dot (site, sequence number) and a remove only deletes the dots it has observed.
+// Concurrent adds and removes of the same rule therefore merge deterministically, the add wins.
+// Operations are idempotent, so sites can exchange them repeatedly and in both directions after reconnecting:
+//
+//	ops, err := siteA.Changes(siteB.Clock())
+//	if errors.Is(err, replication.ErrSnapshotRequired) {
+//		err = siteB.MergeState(siteA.State())
+//	} else if err == nil {
+//		err = siteB.Merge(ops)
+//	}
+//
+// The log of operations is bounded by Compact, which drops the operations seen by all replicas.
+// Tombstones are only kept for removed adds, which have not been received yet.
+//
+// Read replicas are followers of a single primary instead. The primary serves a snapshot and the ordered deltas after it,
+// followers apply them to read-only enforcers:
+//
+//	http.Handle("/replication/", http.StripPrefix("/replication", replication.NewPrimary(e, 10000).Handler()))
+//	go replication.NewFollower(replica, &replication.HTTPSource{URL: "http://primary:8080/replication"}).Run(ctx)
+package replication
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Types of operations
+const (
+	Add    = "add"
+	Remove = "remove"
+)
+
+// Dot identifies an operation by the site, which created it, and its sequence number at that site
+type Dot struct {
+	Site string `json:"site"`
+	Seq  uint64 `json:"seq"`
+}
+
+// Clock is a version vector, it contains the highest sequence number seen of every site
+type Clock map[string]uint64
+
+// Contains returns true, if the operation dot has been seen
+func (c Clock) Contains(dot Dot) bool {
+	return c[dot.Site] >= dot.Seq
+}
+
+// Covers returns true, if all operations seen by other have been seen
+func (c Clock) Covers(other Clock) bool {
+	for site, seq := range other {
+		if c[site] < seq {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Clock) copy() Clock {
+	clock := make(Clock, len(c))
+	for site, seq := range c {
+		clock[site] = seq
+	}
+	return clock
+}
+
+// Op is a replicated operation.
+// Removes contains the dots of the adds of Rule, which were observed by the removing site.
+type Op struct {
+	Type    string   `json:"type"`
+	Dot     Dot      `json:"dot"`
+	Rule    []string `json:"rule"`
+	Removes []Dot    `json:"removes,omitempty"`
+}
+
+// State is the state of a replica. It is merged by replicas, which are missing operations dropped by Compact.
+// Adds contains an add operation for every dot of the rules in the set.
+type State struct {
+	Clock Clock `json:"clock"`
+	Adds  []Op  `json:"adds"`
+}
+
+// Replica tracks the rules of an enforcer as OR-Set and applies merged operations to it.
+// Local changes are recorded from the rule events of the model, ClearPolicy emits no rule events and is not replicated.
+type Replica struct {
+	e    fastac.IEnforcer
+	site string
+
+	// merge serializes Merge, MergeState and Compact, so merged changes are applied to the model in order
+	merge sync.Mutex
+
+	mutex sync.Mutex
+	seq   uint64
+	clock Clock
+	// horizon contains the operations, which are not in the log, because they have been compacted or merged by MergeState
+	horizon    Clock
+	adds       map[string]map[Dot]struct{}
+	rules      map[Dot][]string
+	tombstones map[Dot]struct{}
+	log        []Op
+	listeners  []*fastac.ModelListener
+}
+
+// NewReplica starts tracking the rules of e for site, which must be unique among all replicas.
+// The current rules of e are recorded as adds of site.
+func NewReplica(e fastac.IEnforcer, site string) *Replica {
+	r := &Replica{
+		e:          e,
+		site:       site,
+		clock:      Clock{},
+		horizon:    Clock{},
+		adds:       make(map[string]map[Dot]struct{}),
+		rules:      make(map[Dot][]string),
+		tombstones: make(map[Dot]struct{}),
+	}
+
+	r.mutex.Lock()
+	e.ViewModel().RangeRules(func(rule []string) bool {
+		r.localAdd(rule)
+		return true
+	})
+	r.mutex.Unlock()
+
+	r.listen(model.RULE_ADDED, func(arguments ...interface{}) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.localAdd(arguments[0].([]string))
+	})
+	r.listen(model.RULES_ADDED, func(arguments ...interface{}) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for _, rule := range arguments[0].([][]string) {
+			r.localAdd(rule)
+		}
+	})
+	r.listen(model.RULE_REMOVED, func(arguments ...interface{}) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.localRemove(arguments[0].([]string))
+	})
+	return r
+}
+
+func (r *Replica) listen(event emitter.EventType, handler emitter.HandleFunc) {
+	r.listeners = append(r.listeners, r.e.AddModelListener(event, handler))
+}
+
+// Close stops recording local changes
+func (r *Replica) Close() {
+	for _, l := range r.listeners {
+		r.e.RemoveModelListener(l)
+	}
+	r.listeners = nil
+}
+
+// Site returns the name of the site of the replica
+func (r *Replica) Site() string {
+	return r.site
+}
+
+// Clock returns a copy of the version vector of the replica
+func (r *Replica) Clock() Clock {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.clock.copy()
+}
+
+// Changes returns all operations, which are not contained in since, in the order they were recorded.
+// Pass the Clock of another replica to get the operations it is missing or nil to get all operations.
+// ErrSnapshotRequired is returned, if some of these operations are no longer in the log, the other replica has to merge the State instead.
+func (r *Replica) Changes(since Clock) ([]Op, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !since.Covers(r.horizon) {
+		return nil, ErrSnapshotRequired
+	}
+	ops := []Op{}
+	for _, op := range r.log {
+		if !since.Contains(op.Dot) {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// State returns the clock and the adds of the rules in the set
+func (r *Replica) State() State {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	s := State{Clock: r.clock.copy(), Adds: []Op{}}
+	for _, dots := range r.adds {
+		for dot := range dots {
+			s.Adds = append(s.Adds, Op{Type: Add, Dot: dot, Rule: r.rules[dot]})
+		}
+	}
+	sort.Slice(s.Adds, func(i, j int) bool {
+		return lessDot(s.Adds[i].Dot, s.Adds[j].Dot)
+	})
+	return s
+}
+
+// Compact drops the operations contained in stable from the log.
+// Pass the pointwise minimum of the clocks of all replicas, so only operations, which have been seen by every replica, are dropped.
+// Replicas, which have not seen them, e.g. new replicas, have to merge the State.
+func (r *Replica) Compact(stable Clock) {
+	r.merge.Lock()
+	defer r.merge.Unlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	log := r.log[:0]
+	for _, op := range r.log {
+		if stable.Contains(op.Dot) {
+			r.raise(op.Dot)
+		} else {
+			log = append(log, op)
+		}
+	}
+	for i := len(log); i < len(r.log); i++ {
+		r.log[i] = Op{}
+	}
+	r.log = log
+}
+
+// raise adds the operation dot to the horizon
+func (r *Replica) raise(dot Dot) {
+	if dot.Seq > r.horizon[dot.Site] {
+		r.horizon[dot.Site] = dot.Seq
+	}
+}
+
+// Merge applies the operations of other replicas in the order returned by Changes,
+// operations which have been seen already are skipped.
+// Rules, which are added or removed by the merge, are applied to the enforcer.
+// If the enforcer rejects a rule, the merge is rolled back, the replica and the enforcer are left unchanged.
+func (r *Replica) Merge(ops []Op) error {
+	r.merge.Lock()
+	defer r.merge.Unlock()
+
+	r.mutex.Lock()
+	u := newUndo()
+	c := changes{}
+	for _, op := range ops {
+		if r.clock.Contains(op.Dot) {
+			continue
+		}
+		key := util.Hash(op.Rule)
+		before := len(r.adds[key]) > 0
+		r.apply(key, op, u)
+		c.record(key, op.Rule, before, len(r.adds[key]) > 0)
+	}
+	r.mutex.Unlock()
+
+	return r.commit(c, u)
+}
+
+// MergeState merges the state of another replica, e.g. if Changes of the other replica returned ErrSnapshotRequired.
+// Adds seen by the other replica, which are not in its state, have been removed.
+// Like Merge, the merge is rolled back, if the enforcer rejects a rule.
+func (r *Replica) MergeState(s State) error {
+	r.merge.Lock()
+	defer r.merge.Unlock()
+
+	r.mutex.Lock()
+	u := newUndo()
+	c := changes{}
+	live := make(map[Dot]struct{}, len(s.Adds))
+	for _, op := range s.Adds {
+		live[op.Dot] = struct{}{}
+	}
+	for key, dots := range r.adds {
+		for dot := range dots {
+			if _, ok := live[dot]; !ok && s.Clock.Contains(dot) {
+				rule := r.rules[dot]
+				r.removeDot(key, dot, u)
+				c.record(key, rule, true, len(r.adds[key]) > 0)
+			}
+		}
+	}
+	for _, op := range s.Adds {
+		if r.clock.Contains(op.Dot) {
+			continue
+		}
+		key := util.Hash(op.Rule)
+		before := len(r.adds[key]) > 0
+		r.addDot(key, op, u)
+		c.record(key, op.Rule, before, len(r.adds[key]) > 0)
+	}
+	// the adds of the state have been received, their tombstones are no longer needed
+	for dot := range r.tombstones {
+		if s.Clock.Contains(dot) {
+			u.untombstone(r, dot)
+		}
+	}
+	for site, seq := range s.Clock {
+		if seq > r.clock[site] {
+			u.saveClock(r, site)
+			r.clock[site] = seq
+			// the operations up to seq are not in the log
+			r.horizon[site] = seq
+		}
+	}
+	r.mutex.Unlock()
+
+	return r.commit(c, u)
+}
+
+// commit applies the changed rules to the enforcer, the OR-Set and the enforcer are restored, if it fails
+func (r *Replica) commit(c changes, u *undo) error {
+	keys := c.keys()
+	// the model events of these changes are ignored by the listeners, since the OR-Set already contains them
+	for i, key := range keys {
+		if err := r.applyRule(c[key]); err != nil {
+			r.mutex.Lock()
+			u.restore(r)
+			r.mutex.Unlock()
+			// the restored OR-Set contains the reverted changes, so the listeners ignore them as well
+			for j := i - 1; j >= 0; j-- {
+				ch := c[keys[j]]
+				ch.after = !ch.after
+				if rerr := r.applyRule(ch); rerr != nil {
+					r.e.GetLogger().Error("reverting the merge failed", "site", r.site, "error", rerr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Replica) applyRule(ch *change) error {
+	if ch.after {
+		return r.e.ApplyReplicated(nil, [][]string{ch.rule})
+	}
+	return r.e.ApplyReplicated([][]string{ch.rule}, nil)
+}
+
+// apply adds an operation to the OR-Set and the log
+func (r *Replica) apply(key string, op Op, u *undo) {
+	switch op.Type {
+	case Add:
+		r.addDot(key, op, u)
+	case Remove:
+		for _, dot := range op.Removes {
+			if _, ok := r.adds[key][dot]; ok {
+				r.removeDot(key, dot, u)
+			} else if !r.clock.Contains(dot) {
+				// the add has not been received yet, it is removed on arrival
+				u.tombstone(r, dot)
+			}
+		}
+	}
+	u.saveClock(r, op.Dot.Site)
+	if op.Dot.Seq > r.clock[op.Dot.Site] {
+		r.clock[op.Dot.Site] = op.Dot.Seq
+	}
+	u.logged(op.Dot)
+	r.log = append(r.log, op)
+}
+
+// addDot adds the dot of an add operation to the OR-Set, unless it has been removed before
+func (r *Replica) addDot(key string, op Op, u *undo) {
+	if _, removed := r.tombstones[op.Dot]; removed {
+		u.untombstone(r, op.Dot)
+		return
+	}
+	if r.adds[key] == nil {
+		r.adds[key] = make(map[Dot]struct{})
+	}
+	r.adds[key][op.Dot] = struct{}{}
+	r.rules[op.Dot] = op.Rule
+	if u != nil {
+		u.added = append(u.added, keyDot{key, op.Dot})
+	}
+}
+
+// removeDot removes the dot of an add operation from the OR-Set
+func (r *Replica) removeDot(key string, dot Dot, u *undo) {
+	if u != nil {
+		u.removed = append(u.removed, keyRule{keyDot{key, dot}, r.rules[dot]})
+	}
+	delete(r.adds[key], dot)
+	delete(r.rules, dot)
+	if len(r.adds[key]) == 0 {
+		delete(r.adds, key)
+	}
+}
+
+// localAdd records a rule added to the model, rules which are already in the OR-Set are skipped
+func (r *Replica) localAdd(rule []string) {
+	key := util.Hash(rule)
+	if len(r.adds[key]) > 0 {
+		return
+	}
+	r.seq++
+	r.apply(key, Op{Type: Add, Dot: Dot{r.site, r.seq}, Rule: rule}, nil)
+}
+
+// localRemove records a rule removed from the model, rules which are not in the OR-Set are skipped
+func (r *Replica) localRemove(rule []string) {
+	key := util.Hash(rule)
+	if len(r.adds[key]) == 0 {
+		return
+	}
+	removes := make([]Dot, 0, len(r.adds[key]))
+	for dot := range r.adds[key] {
+		removes = append(removes, dot)
+	}
+	sort.Slice(removes, func(i, j int) bool {
+		return lessDot(removes[i], removes[j])
+	})
+	r.seq++
+	r.apply(key, Op{Type: Remove, Dot: Dot{r.site, r.seq}, Rule: rule, Removes: removes}, nil)
+}
+
+func lessDot(a, b Dot) bool {
+	if a.Site != b.Site {
+		return a.Site < b.Site
+	}
+	return a.Seq < b.Seq
+}
+
+// change is the membership of a rule before and after a merge
+type change struct {
+	rule          []string
+	before, after bool
+}
+
+// changes contains the rules changed by a merge by their hash
+type changes map[string]*change
+
+func (c changes) record(key string, rule []string, before, after bool) {
+	if ch, ok := c[key]; ok {
+		ch.after = after
+		return
+	}
+	c[key] = &change{rule, before, after}
+}
+
+// keys returns the sorted hashes of the rules, whose membership changed
+func (c changes) keys() []string {
+	keys := make([]string, 0, len(c))
+	for key, ch := range c {
+		if ch.before != ch.after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type keyDot struct {
+	key string
+	dot Dot
+}
+
+type keyRule struct {
+	keyDot
+	rule []string
+}
+
+// undo records the changes of a merge to the OR-Set, so they can be reverted without reverting concurrent local changes.
+// A nil undo records nothing.
+type undo struct {
+	added        []keyDot
+	removed      []keyRule
+	tombstoned   []Dot
+	untombstoned []Dot
+	clock        Clock
+	horizon      Clock
+	dots         map[Dot]struct{}
+}
+
+func newUndo() *undo {
+	return &undo{clock: Clock{}, horizon: Clock{}, dots: make(map[Dot]struct{})}
+}
+
+func (u *undo) tombstone(r *Replica, dot Dot) {
+	if _, ok := r.tombstones[dot]; ok {
+		return
+	}
+	r.tombstones[dot] = struct{}{}
+	if u != nil {
+		u.tombstoned = append(u.tombstoned, dot)
+	}
+}
+
+func (u *undo) untombstone(r *Replica, dot Dot) {
+	delete(r.tombstones, dot)
+	if u != nil {
+		u.untombstoned = append(u.untombstoned, dot)
+	}
+}
+
+// saveClock records the sequence numbers of site before the merge
+func (u *undo) saveClock(r *Replica, site string) {
+	if u == nil {
+		return
+	}
+	if _, ok := u.clock[site]; !ok {
+		u.clock[site] = r.clock[site]
+		u.horizon[site] = r.horizon[site]
+	}
+}
+
+func (u *undo) logged(dot Dot) {
+	if u != nil {
+		u.dots[dot] = struct{}{}
+	}
+}
+
+// restore reverts the changes of the merge in reverse order.
+// A dot is only removed after it has been added and only untombstoned after it has been tombstoned by the same merge,
+// so the removes and untombstones are reverted first.
+func (u *undo) restore(r *Replica) {
+	for i := len(u.removed) - 1; i >= 0; i-- {
+		kr := u.removed[i]
+		if r.adds[kr.key] == nil {
+			r.adds[kr.key] = make(map[Dot]struct{})
+		}
+		r.adds[kr.key][kr.dot] = struct{}{}
+		r.rules[kr.dot] = kr.rule
+	}
+	for i := len(u.added) - 1; i >= 0; i-- {
+		kd := u.added[i]
+		if dots, ok := r.adds[kd.key]; ok {
+			delete(dots, kd.dot)
+			delete(r.rules, kd.dot)
+			if len(dots) == 0 {
+				delete(r.adds, kd.key)
+			}
+		}
+	}
+	for i := len(u.untombstoned) - 1; i >= 0; i-- {
+		r.tombstones[u.untombstoned[i]] = struct{}{}
+	}
+	for i := len(u.tombstoned) - 1; i >= 0; i-- {
+		delete(r.tombstones, u.tombstoned[i])
+	}
+	for site, seq := range u.clock {
+		restoreSeq(r.clock, site, seq)
+		restoreSeq(r.horizon, site, u.horizon[site])
+	}
+	if len(u.dots) > 0 {
+		log := r.log[:0]
+		for _, op := range r.log {
+			if _, ok := u.dots[op.Dot]; !ok {
+				log = append(log, op)
+			}
+		}
+		r.log = log
+	}
+}
+
+func restoreSeq(c Clock, site string, seq uint64) {
+	if seq == 0 {
+		delete(c, site)
+	} else {
+		c[site] = seq
+	}
+}
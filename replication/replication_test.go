@@ -0,0 +1,81 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/model"
+)
+
+const testModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = r.sub == p.sub && r.obj == p.obj && r.act == p.act
+`
+
+func newTestEnforcer(t *testing.T) *fastac.Enforcer {
+	t.Helper()
+	m := model.NewModel()
+	if err := m.LoadModelFromText(testModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := fastac.NewEnforcer(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func countRules(e fastac.IEnforcer) int {
+	n := 0
+	e.ViewModel().RangeRules(func(rule []string) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestMergeRollbackRestoresRemovedAdds(t *testing.T) {
+	e := newTestEnforcer(t)
+	r := NewReplica(e, "a")
+	defer r.Close()
+
+	x := []string{"p", "alice", "data1", "read"}
+	ops := []Op{
+		{Type: Add, Dot: Dot{"b", 1}, Rule: x},
+		{Type: Remove, Dot: Dot{"b", 2}, Rule: x, Removes: []Dot{{"b", 1}}},
+		{Type: Add, Dot: Dot{"b", 3}, Rule: []string{"p9", "bob", "data2", "write"}},
+	}
+	if err := r.Merge(ops); err == nil {
+		t.Fatal("merging a rule with an unknown key succeeded")
+	}
+
+	if s := r.State(); len(s.Adds) != 0 {
+		t.Fatalf("rolled back state contains adds: %v", s.Adds)
+	}
+	if c := r.Clock(); len(c) != 0 {
+		t.Fatalf("rolled back clock = %v, want empty", c)
+	}
+	if n := countRules(e); n != 0 {
+		t.Fatalf("model contains %d rules after the rollback", n)
+	}
+
+	if _, err := e.AddRule(x); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := r.Changes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != Add || changes[0].Dot != (Dot{"a", 1}) {
+		t.Fatalf("local add after the rollback is not replicated: %v", changes)
+	}
+}
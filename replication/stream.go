@@ -0,0 +1,506 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Heartbeat is the type of the deltas, which carry no rules, but the current sequence number of the primary
+const Heartbeat = "heartbeat"
+
+// Delta is a change of the rules of a primary, deltas are numbered consecutively starting at 1
+type Delta struct {
+	Seq   uint64     `json:"seq"`
+	Type  string     `json:"type"`
+	Rules [][]string `json:"rules,omitempty"`
+	Time  time.Time  `json:"time"`
+}
+
+// Snapshot contains all rules of a primary, the deltas after Seq have to be applied to it
+type Snapshot struct {
+	Seq   uint64     `json:"seq"`
+	Rules [][]string `json:"rules"`
+	Time  time.Time  `json:"time"`
+}
+
+var (
+	// ErrSnapshotRequired is returned, if the requested deltas are no longer retained by the primary
+	ErrSnapshotRequired = errors.New("error: deltas are no longer retained, a snapshot is required")
+	// ErrTooSlow is returned, if a follower does not receive the deltas as fast as they are produced
+	ErrTooSlow = errors.New("error: follower is too slow")
+)
+
+// Source provides the snapshot and the deltas of a primary to followers, e.g. through HTTP or gRPC
+type Source interface {
+	Snapshot(ctx context.Context) (*Snapshot, error)
+	// Deltas calls fn with the deltas after since and the heartbeats in order, until ctx is done or fn returns an error
+	Deltas(ctx context.Context, since uint64, fn func(delta *Delta) error) error
+}
+
+// Primary records the rule changes of an enforcer as ordered deltas for read replicas.
+// It retains the latest deltas, so reconnecting followers do not need a new snapshot.
+// ClearPolicy emits no rule events and is not replicated.
+//
+//	primary := replication.NewPrimary(e, 10000)
+//	http.Handle("/replication/", http.StripPrefix("/replication", primary.Handler()))
+type Primary struct {
+	e         fastac.IEnforcer
+	retain    int
+	heartbeat time.Duration
+	authorize func(r *http.Request) error
+
+	mutex       sync.Mutex
+	seq         uint64
+	log         []*Delta
+	subscribers map[chan *Delta]struct{}
+	listeners   []*fastac.ModelListener
+}
+
+// NewPrimary starts recording the rule changes of e and retains the latest retain deltas.
+// The changes are recorded across model swaps, e.g. by SetModel or PromoteCanary.
+func NewPrimary(e fastac.IEnforcer, retain int) *Primary {
+	p := &Primary{
+		e:           e,
+		retain:      retain,
+		heartbeat:   5 * time.Second,
+		subscribers: make(map[chan *Delta]struct{}),
+	}
+	p.listen(model.RULE_ADDED, func(arguments ...interface{}) {
+		p.record(Add, [][]string{arguments[0].([]string)})
+	})
+	p.listen(model.RULES_ADDED, func(arguments ...interface{}) {
+		p.record(Add, arguments[0].([][]string))
+	})
+	p.listen(model.RULE_REMOVED, func(arguments ...interface{}) {
+		p.record(Remove, [][]string{arguments[0].([]string)})
+	})
+	return p
+}
+
+func (p *Primary) listen(event emitter.EventType, handler emitter.HandleFunc) {
+	p.listeners = append(p.listeners, p.e.AddModelListener(event, handler))
+}
+
+// Close stops recording changes
+func (p *Primary) Close() {
+	for _, l := range p.listeners {
+		p.e.RemoveModelListener(l)
+	}
+	p.listeners = nil
+}
+
+// SetAuthorizer sets a function, which authorizes the requests of followers to the Handler.
+// A request is answered with status 403 Forbidden, if the function returns an error.
+//
+//	primary.SetAuthorizer(func(r *http.Request) error {
+//		if r.Header.Get("Authorization") != "Bearer "+token {
+//			return errors.New("invalid token")
+//		}
+//		return nil
+//	})
+func (p *Primary) SetAuthorizer(authorize func(r *http.Request) error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.authorize = authorize
+}
+
+// SetHeartbeat sets the interval of the heartbeats sent to idle followers (default: 5s)
+func (p *Primary) SetHeartbeat(interval time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.heartbeat = interval
+}
+
+// Seq returns the sequence number of the latest delta
+func (p *Primary) Seq() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.seq
+}
+
+func (p *Primary) record(typ string, rules [][]string) {
+	copied := make([][]string, len(rules))
+	for i, rule := range rules {
+		copied[i] = append([]string(nil), rule...)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.seq++
+	delta := &Delta{Seq: p.seq, Type: typ, Rules: copied, Time: time.Now()}
+	p.log = append(p.log, delta)
+	if len(p.log) > p.retain {
+		p.log = append([]*Delta(nil), p.log[len(p.log)-p.retain:]...)
+	}
+	for ch := range p.subscribers {
+		select {
+		case ch <- delta:
+		default:
+			// the follower has to reconnect and continues with the retained deltas
+			close(ch)
+			delete(p.subscribers, ch)
+		}
+	}
+}
+
+// Snapshot copies all rules of the enforcer.
+// The sequence number is read before the rules are copied, so the deltas after it include all changes, which are missing in the copy.
+// Applying a delta, which is already contained in the copy, has no effect.
+func (p *Primary) Snapshot(ctx context.Context) (*Snapshot, error) {
+	seq := p.Seq()
+	s := p.e.ViewModel().Snapshot()
+	return &Snapshot{Seq: seq, Rules: s.Rules, Time: time.Now()}, nil
+}
+
+// Deltas calls fn with the retained deltas after since and a heartbeat, then with every new delta and periodic heartbeats
+func (p *Primary) Deltas(ctx context.Context, since uint64, fn func(delta *Delta) error) error {
+	p.mutex.Lock()
+	if since > p.seq || (since < p.seq && (len(p.log) == 0 || p.log[0].Seq > since+1)) {
+		p.mutex.Unlock()
+		return ErrSnapshotRequired
+	}
+	backlog := []*Delta{}
+	for _, delta := range p.log {
+		if delta.Seq > since {
+			backlog = append(backlog, delta)
+		}
+	}
+	ch := make(chan *Delta, p.retain+1)
+	p.subscribers[ch] = struct{}{}
+	heartbeat := p.heartbeat
+	p.mutex.Unlock()
+	defer func() {
+		p.mutex.Lock()
+		delete(p.subscribers, ch)
+		p.mutex.Unlock()
+	}()
+
+	for _, delta := range backlog {
+		if err := fn(delta); err != nil {
+			return err
+		}
+	}
+	// the first heartbeat tells the follower, that it caught up
+	if err := fn(&Delta{Seq: p.Seq(), Type: Heartbeat, Time: time.Now()}); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case delta, ok := <-ch:
+			if !ok {
+				return ErrTooSlow
+			}
+			if err := fn(delta); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := fn(&Delta{Seq: p.Seq(), Type: Heartbeat, Time: time.Now()}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Handler serves the snapshot at /snapshot and the deltas after the query parameter since at /deltas
+// as stream of JSON objects separated by newlines. A missing delta is answered with status 410 Gone.
+// The requests are checked by the function set with SetAuthorizer.
+func (p *Primary) Handler() http.Handler {
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		p.mutex.Lock()
+		authorize := p.authorize
+		p.mutex.Unlock()
+		if authorize != nil {
+			if err := authorize(r); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	}
+	mux.HandleFunc("/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := p.Snapshot(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(snapshot)
+	})
+	mux.HandleFunc("/deltas", func(w http.ResponseWriter, r *http.Request) {
+		since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		started := false
+		err = p.Deltas(r.Context(), since, func(delta *Delta) error {
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				started = true
+			}
+			if err := enc.Encode(delta); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if errors.Is(err, ErrSnapshotRequired) && !started {
+			http.Error(w, err.Error(), http.StatusGone)
+		}
+	})
+	return http.HandlerFunc(handler)
+}
+
+// HTTPSource reads the snapshot and the deltas from the Handler of a primary
+type HTTPSource struct {
+	// URL is the URL, at which the Handler is served
+	URL    string
+	Client *http.Client
+}
+
+func (s *HTTPSource) get(ctx context.Context, path string) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusGone {
+		res.Body.Close()
+		return nil, ErrSnapshotRequired
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("error: GET %s: %s", s.URL+path, res.Status)
+	}
+	return res, nil
+}
+
+func (s *HTTPSource) Snapshot(ctx context.Context) (*Snapshot, error) {
+	res, err := s.get(ctx, "/snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(res.Body).Decode(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (s *HTTPSource) Deltas(ctx context.Context, since uint64, fn func(delta *Delta) error) error {
+	res, err := s.get(ctx, "/deltas?since="+strconv.FormatUint(since, 10))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	dec := json.NewDecoder(res.Body)
+	for {
+		delta := &Delta{}
+		if err := dec.Decode(delta); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := fn(delta); err != nil {
+			return err
+		}
+	}
+}
+
+// FollowerStats describe the replication lag of a follower
+type FollowerStats struct {
+	// Seq is the sequence number of the latest applied delta, PrimarySeq the latest one known of the primary
+	Seq        uint64
+	PrimarySeq uint64
+	// Lag is the time between the latest applied delta or heartbeat of a follower, which caught up, at the primary and at the follower
+	Lag        time.Duration
+	LastUpdate time.Time
+	Connected  bool
+	Snapshots  uint64
+	Deltas     uint64
+	Errors     uint64
+}
+
+// Follower applies the snapshot and the deltas of a primary to a read-only enforcer
+//
+//	follower := replication.NewFollower(e, &replication.HTTPSource{URL: "http://primary:8080/replication"})
+//	go follower.Run(ctx)
+type Follower struct {
+	e      fastac.IEnforcer
+	source Source
+	retry  time.Duration
+
+	mutex  sync.Mutex
+	synced bool
+	stats  FollowerStats
+}
+
+// NewFollower makes e read-only and creates a follower, which replicates the rules of source into it
+func NewFollower(e fastac.IEnforcer, source Source) *Follower {
+	e.SetReadOnly(true)
+	return &Follower{e: e, source: source, retry: time.Second}
+}
+
+// SetRetry sets the interval, after which a lost connection is retried (default: 1s)
+func (f *Follower) SetRetry(interval time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.retry = interval
+}
+
+// Stats returns the replication metrics
+func (f *Follower) Stats() FollowerStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.stats
+}
+
+// Run replicates the rules until ctx is done. It loads a snapshot first and whenever the primary no longer retains the missing deltas.
+func (f *Follower) Run(ctx context.Context) error {
+	for {
+		err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		f.mutex.Lock()
+		f.stats.Connected = false
+		f.stats.Errors++
+		if errors.Is(err, ErrSnapshotRequired) {
+			f.synced = false
+		}
+		retry := f.retry
+		f.mutex.Unlock()
+		f.e.GetLogger().Warn("replication interrupted", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (f *Follower) follow(ctx context.Context) error {
+	f.mutex.Lock()
+	synced := f.synced
+	f.mutex.Unlock()
+	if !synced {
+		snapshot, err := f.source.Snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if err := f.restore(snapshot); err != nil {
+			return err
+		}
+	}
+	f.mutex.Lock()
+	since := f.stats.Seq
+	f.stats.Connected = true
+	f.mutex.Unlock()
+	return f.source.Deltas(ctx, since, f.apply)
+}
+
+// restore replaces the rules of the enforcer by the rules of the snapshot, only the differences are applied
+func (f *Follower) restore(snapshot *Snapshot) error {
+	m := f.e.ViewModel()
+	wanted := make(map[string]struct{}, len(snapshot.Rules))
+	for _, rule := range snapshot.Rules {
+		wanted[util.Hash(rule)] = struct{}{}
+	}
+	removed := [][]string{}
+	m.RangeRules(func(rule []string) bool {
+		if _, ok := wanted[util.Hash(rule)]; !ok {
+			removed = append(removed, rule)
+		}
+		return true
+	})
+	if err := f.e.ApplyReplicated(removed, snapshot.Rules); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.synced = true
+	f.stats.Seq, f.stats.PrimarySeq = snapshot.Seq, snapshot.Seq
+	f.stats.Lag = time.Since(snapshot.Time)
+	f.stats.LastUpdate = time.Now()
+	f.stats.Snapshots++
+	return nil
+}
+
+// apply applies a delta, which must follow the latest applied delta
+func (f *Follower) apply(delta *Delta) error {
+	f.mutex.Lock()
+	seq := f.stats.Seq
+	f.mutex.Unlock()
+	if delta.Type == Heartbeat {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if delta.Seq > f.stats.PrimarySeq {
+			f.stats.PrimarySeq = delta.Seq
+		}
+		if seq >= delta.Seq {
+			f.stats.Lag = time.Since(delta.Time)
+		}
+		return nil
+	}
+	if delta.Seq <= seq {
+		return nil
+	}
+	if delta.Seq != seq+1 {
+		return ErrSnapshotRequired
+	}
+
+	switch delta.Type {
+	case Add:
+		if err := f.e.ApplyReplicated(nil, delta.Rules); err != nil {
+			return err
+		}
+	case Remove:
+		if err := f.e.ApplyReplicated(delta.Rules, nil); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("error: unknown delta type %s", delta.Type)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stats.Seq = delta.Seq
+	if delta.Seq > f.stats.PrimarySeq {
+		f.stats.PrimarySeq = delta.Seq
+	}
+	f.stats.Lag = time.Since(delta.Time)
+	f.stats.LastUpdate = time.Now()
+	f.stats.Deltas++
+	return nil
+}
@@ -0,0 +1,215 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/model"
+)
+
+func newSyncedEnforcer(t *testing.T) *fastac.SyncedEnforcer {
+	t.Helper()
+	m := model.NewModel()
+	if err := m.LoadModelFromText(testModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := fastac.NewSyncedEnforcer(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// waitFor polls cond until it returns true or fails the test after a timeout
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runFollower runs f until the returned function is called
+func runFollower(f *Follower) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = f.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestFollowerSnapshotAndDeltas(t *testing.T) {
+	primary := newSyncedEnforcer(t)
+	if _, err := primary.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	p := NewPrimary(primary, 100)
+	defer p.Close()
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	replica := newSyncedEnforcer(t)
+	if _, err := replica.AddRule([]string{"p", "stale", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFollower(replica, &HTTPSource{URL: srv.URL})
+	f.SetRetry(10 * time.Millisecond)
+	stop := runFollower(f)
+	defer stop()
+
+	waitFor(t, "the snapshot", func() bool {
+		return replica.HasRule([]string{"p", "alice", "data1", "read"})
+	})
+	if replica.HasRule([]string{"p", "stale", "data1", "read"}) {
+		t.Fatal("rule missing in the snapshot has not been removed")
+	}
+	if !replica.IsReadOnly() {
+		t.Fatal("follower enforcer is not read-only")
+	}
+
+	if _, err := primary.AddRule([]string{"p", "bob", "data2", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.RemoveRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the deltas", func() bool {
+		return replica.HasRule([]string{"p", "bob", "data2", "write"}) && !replica.HasRule([]string{"p", "alice", "data1", "read"})
+	})
+	stats := f.Stats()
+	if stats.Snapshots != 1 || stats.Deltas != 2 || stats.Seq != 2 || stats.PrimarySeq != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestFollowerSnapshotRequired(t *testing.T) {
+	primary := newSyncedEnforcer(t)
+	p := NewPrimary(primary, 1)
+	defer p.Close()
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+	src := &HTTPSource{URL: srv.URL}
+
+	replica := newSyncedEnforcer(t)
+	f := NewFollower(replica, src)
+	f.SetRetry(10 * time.Millisecond)
+	stop := runFollower(f)
+	waitFor(t, "the snapshot", func() bool {
+		return f.Stats().Connected
+	})
+	stop()
+
+	// the primary retains only the latest delta, the follower missed two
+	for _, rule := range [][]string{{"p", "alice", "data1", "read"}, {"p", "bob", "data2", "write"}} {
+		if _, err := primary.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := src.Deltas(context.Background(), 0, func(delta *Delta) error {
+		return nil
+	})
+	if !errors.Is(err, ErrSnapshotRequired) {
+		t.Fatalf("Deltas: %v, want ErrSnapshotRequired", err)
+	}
+
+	stop = runFollower(f)
+	defer stop()
+	waitFor(t, "a new snapshot", func() bool {
+		return replica.HasRule([]string{"p", "alice", "data1", "read"}) && replica.HasRule([]string{"p", "bob", "data2", "write"})
+	})
+	if stats := f.Stats(); stats.Snapshots != 2 || stats.Seq != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPrimaryDisconnectsSlowSubscriber(t *testing.T) {
+	primary := newSyncedEnforcer(t)
+	p := NewPrimary(primary, 1)
+	defer p.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		first := true
+		done <- p.Deltas(context.Background(), 0, func(delta *Delta) error {
+			if first {
+				first = false
+				close(started)
+				<-release
+			}
+			return nil
+		})
+	}()
+	<-started
+	// the buffer of the subscriber holds retain+1 deltas
+	for _, rule := range [][]string{{"p", "a", "data1", "read"}, {"p", "b", "data1", "read"}, {"p", "c", "data1", "read"}} {
+		if _, err := primary.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTooSlow) {
+			t.Fatalf("Deltas: %v, want ErrTooSlow", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow subscriber has not been disconnected")
+	}
+}
+
+// bearer adds a token to the requests of a client
+type bearer string
+
+func (b bearer) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+string(b))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestPrimaryAuthorizer(t *testing.T) {
+	primary := newSyncedEnforcer(t)
+	p := NewPrimary(primary, 100)
+	defer p.Close()
+	p.SetAuthorizer(func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("invalid token")
+		}
+		return nil
+	})
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	for _, token := range []string{"", "wrong"} {
+		src := &HTTPSource{URL: srv.URL, Client: &http.Client{Transport: bearer(token)}}
+		if _, err := src.Snapshot(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+			t.Fatalf("Snapshot with token %q: %v, want 403", token, err)
+		}
+		err := src.Deltas(context.Background(), 0, func(delta *Delta) error {
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "403") {
+			t.Fatalf("Deltas with token %q: %v, want 403", token, err)
+		}
+	}
+
+	src := &HTTPSource{URL: srv.URL, Client: &http.Client{Transport: bearer("secret")}}
+	if _, err := src.Snapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,88 @@
+package fastac
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/str"
+)
+
+// requestFields caches the field indexes of request structs by their lower case names
+var requestFields sync.Map
+
+// bindRequest binds a request passed as a single struct or map to the arguments of the request definition by name.
+// Requests with several values or for a single argument are returned unchanged.
+func bindRequest(rDef *defs.RequestDef, rvals []interface{}) ([]interface{}, error) {
+	args := rDef.GetArgs()
+	if len(rvals) != 1 || len(args) < 2 {
+		return rvals, nil
+	}
+
+	switch request := rvals[0].(type) {
+	case map[string]interface{}:
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, ok := request[arg]
+			if !ok {
+				return nil, fmt.Errorf(str.ERR_REQUEST_ARG_MISSING, arg)
+			}
+			values[i] = value
+		}
+		return values, nil
+	case map[string]string:
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, ok := request[arg]
+			if !ok {
+				return nil, fmt.Errorf(str.ERR_REQUEST_ARG_MISSING, arg)
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+
+	v := reflect.ValueOf(rvals[0])
+	if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return rvals, nil
+	}
+	fields := structFields(v.Type())
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		index, ok := fields[strings.ToLower(arg)]
+		if !ok {
+			return nil, fmt.Errorf(str.ERR_REQUEST_ARG_MISSING, arg)
+		}
+		values[i] = v.Field(index).Interface()
+	}
+	return values, nil
+}
+
+// structFields returns the indexes of the exported fields of a struct type by their lower case names
+func structFields(t reflect.Type) map[string]int {
+	if fields, ok := requestFields.Load(t); ok {
+		return fields.(map[string]int)
+	}
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("fastac"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fields[strings.ToLower(name)] = i
+	}
+	requestFields.Store(t, fields)
+	return fields
+}
@@ -0,0 +1,77 @@
+package fastac
+
+import (
+	"errors"
+
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// RequestEncoder converts request values into stable keys, which identify requests in the decision cache and in audit logs.
+// Equal requests must have equal keys, e.g. regardless of the iteration order of maps, and distinct requests distinct keys.
+type RequestEncoder interface {
+	// AppendKey appends the key of a request value to buf, an error marks the value as not encodable
+	AppendKey(buf []byte, value interface{}) ([]byte, error)
+}
+
+// RequestEncoderFunc is a function implementing RequestEncoder
+type RequestEncoderFunc func(buf []byte, value interface{}) ([]byte, error)
+
+func (fn RequestEncoderFunc) AppendKey(buf []byte, value interface{}) ([]byte, error) {
+	return fn(buf, value)
+}
+
+// CanonicalEncoder is the default RequestEncoder, it encodes maps, structs, slices and scalars deterministically,
+// see util.AppendCanonical
+type CanonicalEncoder struct{}
+
+func (CanonicalEncoder) AppendKey(buf []byte, value interface{}) ([]byte, error) {
+	return util.AppendCanonical(buf, value)
+}
+
+// Option to set the encoder of the request keys (default: CanonicalEncoder)
+// A custom encoder may e.g. key objects by their id instead of all their fields.
+//
+//	NewEnforcer(model, adapter, OptionRequestEncoder(RequestEncoderFunc(func(buf []byte, value interface{}) ([]byte, error) {
+//		if doc, ok := value.(*Document); ok {
+//			return append(buf, "doc:"+doc.ID+";"...), nil
+//		}
+//		return CanonicalEncoder{}.AppendKey(buf, value)
+//	})))
+func OptionRequestEncoder(encoder RequestEncoder) Option {
+	return func(e *Enforcer) error {
+		if encoder == nil {
+			return errors.New(str.ERR_NO_ENCODER)
+		}
+		e.encoder = encoder
+		if e.decisions != nil {
+			e.decisions.invalidate()
+		}
+		return nil
+	}
+}
+
+// RequestKey returns the key of the request values
+//
+//	e.RequestKey("alice", map[string]interface{}{"owner": "alice"}, "read")
+func (e *Enforcer) RequestKey(rvals ...interface{}) (string, error) {
+	return requestKey(e.requestEncoder(), nil, rvals)
+}
+
+func (e *Enforcer) requestEncoder() RequestEncoder {
+	if e.encoder == nil {
+		return CanonicalEncoder{}
+	}
+	return e.encoder
+}
+
+// requestKey appends the keys of the values to buf
+func requestKey(encoder RequestEncoder, buf []byte, rvals []interface{}) (string, error) {
+	var err error
+	for _, value := range rvals {
+		if buf, err = encoder.AppendKey(buf, value); err != nil {
+			return "", err
+		}
+	}
+	return string(buf), nil
+}
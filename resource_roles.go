@@ -0,0 +1,62 @@
+package fastac
+
+import (
+	"fmt"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/rbac"
+	"github.com/oarkflow/fastac/str"
+)
+
+// AssignResourceRole grants user the role on a single resource instance,
+// e.g. AssignResourceRole("alice", "editor", "doc:123").
+// The model must use gObj(r.sub, p.sub, r.obj) in its matcher or declare gObj in the role_definition section.
+func (e *Enforcer) AssignResourceRole(user, role, resource string) (bool, error) {
+	if _, err := e.resourceRoleManager(); err != nil {
+		return false, err
+	}
+	return e.AddRule([]string{m.RESOURCE_ROLE, user, role, resource})
+}
+
+// RevokeResourceRole removes the role of user on a resource instance
+func (e *Enforcer) RevokeResourceRole(user, role, resource string) (bool, error) {
+	if _, err := e.resourceRoleManager(); err != nil {
+		return false, err
+	}
+	return e.RemoveRule([]string{m.RESOURCE_ROLE, user, role, resource})
+}
+
+// HasResourceRole returns true, if user has the role on a resource instance, directly or through inheritance
+func (e *Enforcer) HasResourceRole(user, role, resource string) (bool, error) {
+	rm, err := e.resourceRoleManager()
+	if err != nil {
+		return false, err
+	}
+	return rm.HasLink(user, role, resource)
+}
+
+// GetResourceRoles returns the roles, which are directly assigned to user on a resource instance
+func (e *Enforcer) GetResourceRoles(user, resource string) ([]string, error) {
+	rm, err := e.resourceRoleManager()
+	if err != nil {
+		return nil, err
+	}
+	return rm.GetRoles(user, resource)
+}
+
+// GetResourceUsers returns the users, which are directly assigned to the role on a resource instance
+func (e *Enforcer) GetResourceUsers(role, resource string) ([]string, error) {
+	rm, err := e.resourceRoleManager()
+	if err != nil {
+		return nil, err
+	}
+	return rm.GetUsers(role, resource)
+}
+
+func (e *Enforcer) resourceRoleManager() (rbac.IRoleManager, error) {
+	if _, ok := e.model.GetDef(m.G_SEC, m.RESOURCE_ROLE); !ok {
+		return nil, fmt.Errorf(str.ERR_RM_NOT_FOUND, m.RESOURCE_ROLE)
+	}
+	rm, _ := e.model.GetRoleManager(m.RESOURCE_ROLE)
+	return rm, nil
+}
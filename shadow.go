@@ -0,0 +1,70 @@
+package fastac
+
+// Discrepancy is a request, which the primary and the candidate enforcer of a shadow evaluation decide differently
+type Discrepancy struct {
+	Request   []interface{}
+	Primary   *Decision
+	Candidate *Decision
+}
+
+// DiscrepancyFunc is called for every discrepancy of a shadow evaluation
+type DiscrepancyFunc func(d Discrepancy)
+
+type shadowEnforcer struct {
+	candidate *Enforcer
+	fn        DiscrepancyFunc
+}
+
+// Option to evaluate a candidate enforcer alongside, see SetShadow
+func OptionShadow(candidate *Enforcer, fn DiscrepancyFunc) Option {
+	return func(e *Enforcer) error {
+		e.SetShadow(candidate, fn)
+		return nil
+	}
+}
+
+// SetShadow evaluates every request of Enforce with the candidate enforcer as well and calls fn,
+// if the candidate allows a request denied by e, or vice versa, or only one of them fails.
+// The decision of e is returned unchanged, so a new model or policy can be tested with production traffic before it is put in place.
+// The candidate is evaluated synchronously with the variables, functions and context of the request, but without other context options.
+// A nil candidate stops the shadow evaluation.
+//
+//	candidate, _ := NewEnforcer("model_v2.conf", adapterV2)
+//	e.SetShadow(candidate, func(d Discrepancy) {
+//		log.Println("policy v2 differs", d.Request, d.Primary.Allowed, d.Candidate.Allowed)
+//	})
+func (e *Enforcer) SetShadow(candidate *Enforcer, fn DiscrepancyFunc) {
+	if candidate == nil || fn == nil {
+		e.shadowing = nil
+		return
+	}
+	e.shadowing = &shadowEnforcer{candidate, fn}
+}
+
+// compare evaluates the request of a decision with the candidate and reports a discrepancy
+func (s *shadowEnforcer) compare(ctx *Context, primary *Decision) {
+	candidate := candidateDecision(s.candidate, ctx, primary.Request)
+	if primary.Allowed == candidate.Allowed && (primary.Err == nil) == (candidate.Err == nil) {
+		return
+	}
+	if err := runDiscrepancyFunc(s.fn, Discrepancy{primary.Request, primary, candidate}); err != nil {
+		s.candidate.GetLogger().Error("discrepancy callback failed", "request", primary.Request, "error", err)
+	}
+}
+
+// candidateDecision decides a request with the candidate enforcer of a shadow evaluation or canary,
+// the context of the request and the variables and functions of its matcher are passed to the candidate
+func candidateDecision(candidate *Enforcer, ctx *Context, rvals []interface{}) *Decision {
+	candidateCtx, err := NewContext(candidate.model)
+	if err != nil {
+		return &Decision{Request: rvals, Err: err}
+	}
+	candidateCtx.goctx = ctx.goctx
+	return candidate.decide(candidateCtx, rvals)
+}
+
+func runDiscrepancyFunc(fn DiscrepancyFunc, d Discrepancy) (err error) {
+	defer recoverPanic(&err)
+	fn(d)
+	return nil
+}
@@ -0,0 +1,425 @@
+// Package sqlrls converts the policy of an enforcer into SQL predicates,
+// so row-level security of a database can mirror the fastac model.
+//
+// The request arguments, which are known when the predicate is generated (usually the subject),
+// are evaluated by fastac, including role checks like g(r.sub, p.sub).
+// All other request arguments must be mapped to columns and are translated to SQL:
+//
+//	m = g(r.sub, p.sub) && r.obj.Owner == r.sub && regexMatch(r.obj.Path, p.obj)
+//
+//	gen, _ := sqlrls.NewGenerator(e, map[string]string{"r.obj.Owner": "owner", "r.obj.Path": "path"})
+//	pred, _ := gen.Predicate(map[string]interface{}{"r.sub": "alice"})
+//	// (owner = 'alice' AND path ~ '^/docs/')
+//
+// Only comparisons, arithmetic and functions with a registered Translator are supported
+// in terms, which reference columns.
+package sqlrls
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/govaluate"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/str"
+)
+
+const (
+	True  = "TRUE"
+	False = "FALSE"
+)
+
+// Translator converts a function call with translated arguments to SQL
+type Translator func(args ...string) (string, error)
+
+var operators = map[string]string{
+	"==": "=",
+	"!=": "<>",
+	"=~": "~",
+	"!~": "!~",
+	"&&": "AND",
+	"||": "OR",
+	"!":  "NOT",
+}
+
+// Generator generates SQL predicates for a matcher and effect of an enforcer
+type Generator struct {
+	model       model.IModel
+	ctx         *fastac.Context
+	pDef        *defs.PolicyDef
+	columns     map[string]string
+	translators map[string]Translator
+}
+
+// NewGenerator creates a generator for the matcher and effect selected by options.
+// columns maps request arguments or their attributes to SQL columns, e.g. "r.obj.Owner" to "owner".
+func NewGenerator(e *fastac.Enforcer, columns map[string]string, options ...fastac.ContextOption) (*Generator, error) {
+	ctx, err := fastac.NewContext(e.ViewModel(), options...)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Matcher() == nil {
+		return nil, fmt.Errorf(str.ERR_MATCHER_NOT_FOUND, "m")
+	}
+	key := ctx.Matcher().GetPolicyKey()
+	def, ok := e.ViewModel().GetDef(model.P_SEC, key)
+	if !ok {
+		return nil, fmt.Errorf(str.ERR_POLICY_NOT_FOUND, key)
+	}
+
+	g := &Generator{
+		model:       e.ViewModel(),
+		ctx:         ctx,
+		pDef:        def.(*defs.PolicyDef),
+		columns:     columns,
+		translators: make(map[string]Translator),
+	}
+	g.SetTranslator("regexMatch", func(args ...string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf(str.ERR_SQL_UNSUPPORTED, "regexMatch with "+strconv.Itoa(len(args))+" arguments")
+		}
+		return args[0] + " ~ " + args[1], nil
+	})
+	return g, nil
+}
+
+// SetTranslator registers the SQL translation of a matcher function
+//
+//	gen.SetTranslator("lower", func(args ...string) (string, error) {
+//		return "lower(" + strings.Join(args, ", ") + ")", nil
+//	})
+func (g *Generator) SetTranslator(name string, fn Translator) {
+	g.translators[name] = fn
+}
+
+// Predicate returns a SQL boolean expression, which is true for all rows, that would be allowed.
+// known maps request arguments to their values, e.g. "r.sub" to "alice".
+func (g *Generator) Predicate(known map[string]interface{}) (string, error) {
+	var allow, deny []string
+	var err error
+
+	policy, ok := g.model.GetPolicy(g.pDef.GetKey())
+	if !ok {
+		return "", fmt.Errorf(str.ERR_POLICY_NOT_FOUND, g.pDef.GetKey())
+	}
+	ast := g.ctx.Matcher().AST()
+	policy.Range(func(rule []string) bool {
+		var pred string
+		if pred, err = g.node(ast, rule, known); err != nil {
+			return false
+		}
+		if pred == False {
+			return true
+		}
+		switch g.pDef.GetEft(rule) {
+		case eft.Allow:
+			allow = append(allow, pred)
+		case eft.Deny:
+			deny = append(deny, pred)
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	// the rules are ranged in random order, sorting keeps the predicate stable
+	sort.Strings(allow)
+	sort.Strings(deny)
+
+	effector, ok := g.ctx.Effector().(interface{ Expr() string })
+	if !ok {
+		return "", fmt.Errorf(str.ERR_SQL_UNSUPPORTED, "custom effector")
+	}
+	switch effector.Expr() {
+	case eft.SOME_ALLOW:
+		return or(allow), nil
+	case eft.NO_DENY:
+		return not(or(deny)), nil
+	case eft.SOME_ALLOW_NO_DENY:
+		return and([]string{or(allow), not(or(deny))}), nil
+	}
+	return "", fmt.Errorf(str.ERR_SQL_UNSUPPORTED, "effect "+effector.Expr())
+}
+
+var identifierReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CreatePolicy returns a Postgres statement, which creates a row-level security policy for table.
+// name and table must be plain identifiers, the table may be qualified by its schema, e.g. public.docs.
+//
+//	CREATE POLICY alice_docs ON docs USING (owner = 'alice');
+func (g *Generator) CreatePolicy(name, table string, known map[string]interface{}) (string, error) {
+	if !identifierReg.MatchString(name) {
+		return "", fmt.Errorf(str.ERR_SQL_IDENTIFIER, name)
+	}
+	for _, part := range strings.SplitN(table, ".", 2) {
+		if !identifierReg.MatchString(part) {
+			return "", fmt.Errorf(str.ERR_SQL_IDENTIFIER, table)
+		}
+	}
+	pred, err := g.Predicate(known)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CREATE POLICY %s ON %s USING (%s);", name, table, pred), nil
+}
+
+func (g *Generator) node(node *defs.Node, rule []string, known map[string]interface{}) (string, error) {
+	if node.Kind == defs.TermNode {
+		return g.term(node, rule, known)
+	}
+
+	preds := make([]string, 0, len(node.Children))
+	for _, child := range node.Children {
+		pred, err := g.node(child, rule, known)
+		if err != nil {
+			return "", err
+		}
+		preds = append(preds, pred)
+	}
+	if node.Kind == defs.AndNode {
+		return and(preds), nil
+	}
+	return or(preds), nil
+}
+
+// term evaluates a term without column references and translates all other terms
+func (g *Generator) term(node *defs.Node, rule []string, known map[string]interface{}) (string, error) {
+	for _, arg := range node.RequestArgs {
+		if _, ok := known[argName(arg)]; !ok {
+			return g.translate(node.Tokens, rule, known)
+		}
+	}
+
+	expr, err := govaluate.NewEvaluableExpressionWithFunctions(node.Expr, g.model.GetFunctions())
+	if err != nil {
+		return "", err
+	}
+	res, err := expr.Eval(&parameters{g.pDef, rule, known})
+	if err != nil {
+		return "", err
+	}
+	if b, ok := res.(bool); ok && b {
+		return True, nil
+	}
+	return False, nil
+}
+
+func (g *Generator) translate(tokens []govaluate.ExpressionToken, rule []string, known map[string]interface{}) (string, error) {
+	res := ""
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		var part string
+		var err error
+
+		switch token.Kind {
+		case govaluate.FUNCTION:
+			end := closing(tokens, i+1)
+			part, err = g.function(token.Value2.(string), tokens[i+2:end], rule, known)
+			i = end
+		case govaluate.VARIABLE:
+			part, err = g.value(token.Value.(string), rule, known)
+		case govaluate.ACCESSOR:
+			part, err = g.value(strings.Join(token.Value.([]string), "."), rule, known)
+		case govaluate.STRING, govaluate.NUMERIC, govaluate.BOOLEAN:
+			part, err = literal(token.Value)
+		case govaluate.COMPARATOR, govaluate.LOGICALOP, govaluate.PREFIX, govaluate.MODIFIER:
+			op := fmt.Sprintf("%v", token.Value)
+			if sqlOp, ok := operators[op]; ok {
+				op = sqlOp
+			}
+			switch op {
+			case "<", "<=", ">", ">=", "=", "<>", "~", "!~", "AND", "OR", "NOT", "+", "-", "*", "/", "%":
+				part = op
+			default:
+				err = fmt.Errorf(str.ERR_SQL_UNSUPPORTED, "operator "+op)
+			}
+		case govaluate.CLAUSE:
+			part = "("
+		case govaluate.CLAUSE_CLOSE:
+			part = ")"
+		case govaluate.SEPARATOR:
+			part = ","
+		default:
+			err = fmt.Errorf(str.ERR_SQL_UNSUPPORTED, fmt.Sprintf("%v", token.Value))
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if res != "" && !strings.HasSuffix(res, "(") && part != ")" && part != "," {
+			res += " "
+		}
+		res += part
+	}
+	return res, nil
+}
+
+func (g *Generator) function(name string, tokens []govaluate.ExpressionToken, rule []string, known map[string]interface{}) (string, error) {
+	fn, ok := g.translators[name]
+	if !ok {
+		return "", fmt.Errorf(str.ERR_SQL_UNSUPPORTED, "function "+name)
+	}
+
+	args := []string{}
+	depth, start := 0, 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) {
+			switch tokens[i].Kind {
+			case govaluate.CLAUSE:
+				depth++
+				continue
+			case govaluate.CLAUSE_CLOSE:
+				depth--
+				continue
+			case govaluate.SEPARATOR:
+				if depth != 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if i > start {
+			arg, err := g.translate(tokens[start:i], rule, known)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+		}
+		start = i + 1
+	}
+	return fn(args...)
+}
+
+// value returns the column or literal of a parameter, e.g. r_obj.Owner or p_sub
+func (g *Generator) value(name string, rule []string, known map[string]interface{}) (string, error) {
+	arg := argName(name)
+	if column, ok := g.columns[arg]; ok {
+		return column, nil
+	}
+	switch name[0] {
+	case 'p':
+		value, err := g.pDef.GetParameter(rule, name)
+		if err != nil {
+			return "", err
+		}
+		return literal(value)
+	case 'r':
+		if value, ok := known[arg]; ok {
+			return literal(value)
+		}
+	}
+	return "", fmt.Errorf(str.ERR_SQL_NO_COLUMN, arg)
+}
+
+// argName converts a parameter name to the notation of the model, e.g. r_obj.Owner to r.obj.Owner
+func argName(name string) string {
+	return strings.Replace(name, "_", ".", 1)
+}
+
+func literal(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case bool:
+		if v {
+			return True, nil
+		}
+		return False, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	}
+	return "", fmt.Errorf(str.ERR_SQL_UNSUPPORTED, fmt.Sprintf("value %v", value))
+}
+
+// closing returns the index of the bracket, which closes the bracket at start
+func closing(tokens []govaluate.ExpressionToken, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].Kind {
+		case govaluate.CLAUSE:
+			depth++
+		case govaluate.CLAUSE_CLOSE:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+func and(preds []string) string {
+	res := []string{}
+	for _, pred := range preds {
+		switch pred {
+		case False:
+			return False
+		case True:
+		default:
+			res = append(res, pred)
+		}
+	}
+	return join(res, " AND ", True)
+}
+
+func or(preds []string) string {
+	res := []string{}
+	for _, pred := range preds {
+		switch pred {
+		case True:
+			return True
+		case False:
+		default:
+			res = append(res, pred)
+		}
+	}
+	return join(res, " OR ", False)
+}
+
+func not(pred string) string {
+	switch pred {
+	case True:
+		return False
+	case False:
+		return True
+	}
+	return "NOT " + pred
+}
+
+func join(preds []string, sep string, empty string) string {
+	switch len(preds) {
+	case 0:
+		return empty
+	case 1:
+		return preds[0]
+	}
+	return "(" + strings.Join(preds, sep) + ")"
+}
+
+type parameters struct {
+	pDef  *defs.PolicyDef
+	rule  []string
+	known map[string]interface{}
+}
+
+func (params *parameters) Get(name string) (interface{}, error) {
+	if name[0] == 'r' {
+		if value, ok := params.known[argName(name)]; ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf(str.ERR_SQL_NO_COLUMN, argName(name))
+	}
+	return params.pDef.GetParameter(params.rule, name)
+}
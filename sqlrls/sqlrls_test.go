@@ -0,0 +1,183 @@
+package sqlrls
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/fastac"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/str"
+)
+
+const rlsModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj.Owner == r.sub && regexMatch(r.obj.Path, p.obj) && r.act == p.act
+`
+
+var rlsRules = [][]string{
+	{"p", "alice", "^/docs/", "read", "allow"},
+	{"p", "editors", "^/drafts/", "read", "allow"},
+	{"p", "alice", "^/docs/secret/", "read", "deny"},
+	{"p", "bob", "^/bob's/", "read", "allow"},
+	{"g", "alice", "editors"},
+}
+
+var rlsColumns = map[string]string{"r.obj.Owner": "owner", "r.obj.Path": "path", "r.obj.Level": "level", "r.obj.Size": "size"}
+
+func testGenerator(t *testing.T, options ...fastac.ContextOption) *Generator {
+	t.Helper()
+	m := model.NewModel()
+	if err := m.LoadModelFromText(rlsModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := fastac.NewEnforcer(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRules(rlsRules); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGenerator(e, rlsColumns, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestPredicateOperators(t *testing.T) {
+	tests := []struct {
+		matcher string
+		want    string
+	}{
+		{"r.sub == p.sub && r.obj.Owner == r.sub", "owner = 'alice'"},
+		{"r.sub == p.sub && r.obj.Owner != r.sub", "owner <> 'alice'"},
+		{"r.sub == p.sub && r.obj.Path =~ p.obj", "path ~ '^/docs/'"},
+		{"r.sub == p.sub && r.obj.Path !~ p.obj", "path !~ '^/docs/'"},
+		{"r.sub == p.sub && regexMatch(r.obj.Path, p.obj)", "path ~ '^/docs/'"},
+		{"r.sub == p.sub && (r.obj.Owner == r.sub || r.obj.Level < 2)", "(owner = 'alice' OR level < 2)"},
+		{"r.sub == p.sub && !(r.obj.Owner == r.sub)", "NOT (owner = 'alice')"},
+		{"r.sub == p.sub && r.obj.Level <= 2", "level <= 2"},
+		{"r.sub == p.sub && r.obj.Level > 2", "level > 2"},
+		{"r.sub == p.sub && r.obj.Level >= 2", "level >= 2"},
+		{"r.sub == p.sub && r.obj.Size + 1 > 10 && r.obj.Size - 1 < 100", "(size + 1 > 10 AND size - 1 < 100)"},
+		{"r.sub == p.sub && r.obj.Size * 2 >= r.obj.Level / 4 && r.obj.Size % 2 == 0", "(size * 2 >= level / 4 AND size % 2 = 0)"},
+		// every allowing rule contributes a predicate, the predicates are sorted
+		{"r.obj.Path =~ p.obj", "(path ~ '^/bob''s/' OR path ~ '^/docs/' OR path ~ '^/drafts/')"},
+		// terms without columns are evaluated by fastac
+		{"g(r.sub, p.sub) && r.obj.Path =~ p.obj", "(path ~ '^/docs/' OR path ~ '^/drafts/')"},
+		{"r.sub == 'bob' && r.obj.Owner == r.sub", False},
+		{"r.sub == 'alice' || r.obj.Owner == r.sub", True},
+		// string values are quoted and escaped
+		{"r.obj.Path =~ p.obj && p.sub == 'bob'", "path ~ '^/bob''s/'"},
+	}
+	for _, test := range tests {
+		g := testGenerator(t, fastac.SetMatcher(test.matcher))
+		got, err := g.Predicate(map[string]interface{}{"r.sub": "alice"})
+		if err != nil || got != test.want {
+			t.Fatalf("Predicate of %s: %q %v, want %q", test.matcher, got, err, test.want)
+		}
+	}
+}
+
+func TestPredicateErrors(t *testing.T) {
+	tests := []struct {
+		matcher string
+		err     string
+	}{
+		{"r.obj.Name == r.sub", "no column mapped to r.obj.Name"},
+		{"r.obj.Owner in ('a', 'b')", "operator in is not supported"},
+		{"pathMatch(r.obj.Path, p.obj)", "function pathMatch is not supported"},
+		{"r.obj.Level << 2 == 4", "operator << is not supported"},
+		{"r.obj.Level == 1 ? true : false", "is not supported"},
+	}
+	for _, test := range tests {
+		g := testGenerator(t, fastac.SetMatcher(test.matcher))
+		if _, err := g.Predicate(map[string]interface{}{"r.sub": "alice"}); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Predicate of %s: %v, want %s", test.matcher, err, test.err)
+		}
+	}
+}
+
+type firstEffector struct{}
+
+func (firstEffector) MergeEffects(effects []types.Effect, matches [][]string, complete bool) (types.Effect, []string, error) {
+	return effects[0], matches[0], nil
+}
+
+func TestPredicateEffectors(t *testing.T) {
+	allowed := "((owner = 'alice' AND path ~ '^/docs/') OR (owner = 'alice' AND path ~ '^/drafts/'))"
+	denied := "(owner = 'alice' AND path ~ '^/docs/secret/')"
+	tests := []struct {
+		effector string
+		want     string
+	}{
+		{"", allowed},
+		{"allow-override", allowed},
+		{"deny-override", "NOT " + denied},
+		{"allow-and-deny", "(" + allowed + " AND NOT " + denied + ")"},
+		{"some(where (p.eft == allow)) && !some(where (p.eft == deny))", "(" + allowed + " AND NOT " + denied + ")"},
+	}
+	for _, test := range tests {
+		g := testGenerator(t, fastac.SetEffector(test.effector))
+		got, err := g.Predicate(map[string]interface{}{"r.sub": "alice", "r.act": "read"})
+		if err != nil || got != test.want {
+			t.Fatalf("Predicate with effector %q: %q %v, want %q", test.effector, got, err, test.want)
+		}
+	}
+
+	// without matching rules, allow-override allows nothing and deny-override everything
+	for effector, want := range map[string]string{"allow-override": False, "deny-override": True, "allow-and-deny": False} {
+		g := testGenerator(t, fastac.SetEffector(effector))
+		if got, err := g.Predicate(map[string]interface{}{"r.sub": "carol", "r.act": "read"}); err != nil || got != want {
+			t.Fatalf("Predicate for carol with effector %s: %q %v, want %q", effector, got, err, want)
+		}
+	}
+
+	g := testGenerator(t, fastac.SetEffector(firstEffector{}))
+	if _, err := g.Predicate(map[string]interface{}{"r.sub": "alice", "r.act": "read"}); err == nil || err.Error() != fmt.Sprintf(str.ERR_SQL_UNSUPPORTED, "custom effector") {
+		t.Fatalf("Predicate with custom effector: %v, want custom effector is not supported", err)
+	}
+}
+
+func TestCreatePolicy(t *testing.T) {
+	g := testGenerator(t, fastac.SetMatcher("r.sub == p.sub && r.obj.Owner == r.sub"))
+	known := map[string]interface{}{"r.sub": "alice"}
+	tests := []struct {
+		name, table string
+		want        string // the statement or the rejected identifier
+	}{
+		{"alice_docs", "docs", "CREATE POLICY alice_docs ON docs USING (owner = 'alice');"},
+		{"_p1", "public.docs", "CREATE POLICY _p1 ON public.docs USING (owner = 'alice');"},
+		{"alice docs", "docs", "alice docs"},
+		{"1alice", "docs", "1alice"},
+		{"p", "docs; DROP TABLE docs", "docs; DROP TABLE docs"},
+		{"p", `"docs"`, `"docs"`},
+		{"p", "a.b.c", "a.b.c"},
+		{"p", "public.", "public."},
+		{"p", "", ""},
+		{"", "docs", ""},
+	}
+	for _, test := range tests {
+		got, err := g.CreatePolicy(test.name, test.table, known)
+		if strings.HasPrefix(test.want, "CREATE") && (err != nil || got != test.want) {
+			t.Fatalf("CreatePolicy(%q, %q): %q %v, want %q", test.name, test.table, got, err, test.want)
+		}
+		if !strings.HasPrefix(test.want, "CREATE") && (err == nil || err.Error() != fmt.Sprintf(str.ERR_SQL_IDENTIFIER, test.want)) {
+			t.Fatalf("CreatePolicy(%q, %q): %v, want invalid identifier %q", test.name, test.table, err, test.want)
+		}
+	}
+}
@@ -15,16 +15,14 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/oarkflow/fastac/api"
 )
 
-// Adapter is the interface for Casbin adapters.
-type Adapter interface {
-	// LoadPolicy loads all policy rules from the storage.
-	LoadPolicy(model api.IAddRuleBool) error
-	// SavePolicy saves all policy rules to the storage.
-	SavePolicy(model api.IRangeRules) error
-}
+// Adapter is the interface for Casbin adapters, see api.Adapter
+type Adapter = api.Adapter
 
 type SimpleAdapter interface {
 	Adapter
@@ -33,14 +31,66 @@ type SimpleAdapter interface {
 	api.IRemoveRule
 }
 
-// type FilteredAdapter interface {
-// 	Adapter
+// ProvenanceAdapter is the interface for adapters, which know the source of their rules.
+type ProvenanceAdapter interface {
+	Adapter
+
+	// Provenance returns the name of the source a rule has been loaded from.
+	Provenance(rule []string) (string, bool)
+}
+
+// ContextAdapter is the interface for adapters, whose I/O can be canceled by a context.
+type ContextAdapter interface {
+	Adapter
+
+	LoadPolicyContext(ctx context.Context, model api.IAddRuleBool) error
+	SavePolicyContext(ctx context.Context, model api.IRangeRules) error
+	AddRulesContext(ctx context.Context, rules [][]string) error
+	RemoveRulesContext(ctx context.Context, rules [][]string) error
+}
+
+// TransactionalAdapter is the interface for adapters, which can apply several changes atomically.
+type TransactionalAdapter interface {
+	Adapter
+
+	// ApplyRules removes and adds rules in a single transaction, either all changes are stored or none.
+	ApplyRules(ctx context.Context, added, removed [][]string) error
+}
+
+// FencedAdapter is the interface for adapters, which reject writes with a stale fencing token.
+// The token increases every time the leadership of a cluster changes hands, the adapter stores the highest token
+// it has seen and rejects writes with a lower one, so a deposed leader cannot overwrite the rules of its successor.
+type FencedAdapter interface {
+	Adapter
+
+	// SetFencingToken sets the token of all following writes.
+	SetFencingToken(token uint64)
+}
+
+// SoftDeleteAdapter is the interface for adapters, which keep removed rules as tombstones.
+// Removed rules are soft-deleted, if soft deletes are enabled by the storage controller.
+// LoadPolicy skips soft-deleted rules, adding a soft-deleted rule restores it.
+type SoftDeleteAdapter interface {
+	Adapter
+
+	// SoftDeleteRules marks rules as deleted.
+	SoftDeleteRules(rules [][]string) error
+	// PurgeRules deletes soft-deleted rules permanently.
+	PurgeRules(rules [][]string) error
+	// LoadTombstones calls fn for every soft-deleted rule with the time of its deletion.
+	LoadTombstones(fn func(rule []string, deletedAt time.Time)) error
+}
 
-// 	// LoadFilteredPolicy loads only policy rules that match the filter.
-// 	LoadFilteredPolicy(model *model.Model, filter interface{}) error
-// 	// IsFiltered returns true if the loaded policy has been filtered.
-// 	IsFiltered() bool
-// }
+// FilteredAdapter is the interface for adapters, which can load a subset of the rules, e.g. the rules of a tenant.
+type FilteredAdapter interface {
+	Adapter
+
+	// LoadFilteredPolicy loads only the rules matching the filter.
+	LoadFilteredPolicy(model api.IAddRuleBool, filter Filter) error
+	// IsFiltered returns true, if the rules have been loaded by LoadFilteredPolicy.
+	// SavePolicy of a filtered adapter would delete the rules, which have not been loaded.
+	IsFiltered() bool
+}
 
 // BatchAdapter is the interface for Casbin adapters with multiple add and remove policy functions.
 type BatchAdapter interface {
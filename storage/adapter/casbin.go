@@ -0,0 +1,284 @@
+package adapter
+
+import (
+	"fmt"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/storage"
+)
+
+// CasbinPersister has the rule methods of casbin's persist.Adapter, every casbin adapter implements it
+type CasbinPersister interface {
+	AddPolicy(sec string, ptype string, rule []string) error
+	RemovePolicy(sec string, ptype string, rule []string) error
+}
+
+// CasbinBatchPersister has the rule methods of casbin's persist.BatchAdapter
+type CasbinBatchPersister interface {
+	CasbinPersister
+	AddPolicies(sec string, ptype string, rules [][]string) error
+	RemovePolicies(sec string, ptype string, rules [][]string) error
+}
+
+// CasbinLoader loads and saves all rules of a casbin adapter.
+// Rules start with the policy type, like the lines of a casbin policy file.
+// Casbin's LoadPolicy and SavePolicy take a casbin model, so they are bridged by a few lines of glue code:
+//
+//	loader := adapter.CasbinLoaderFuncs{
+//		LoadFunc: func() ([][]string, error) {
+//			m, _ := model.NewModelFromFile("model.conf")
+//			if err := xormAdapter.LoadPolicy(m); err != nil {
+//				return nil, err
+//			}
+//			return append(adapter.CasbinRules("p", m.GetPolicy("p", "p")), adapter.CasbinRules("g", m.GetPolicy("g", "g"))...), nil
+//		},
+//		SaveFunc: func(rules [][]string) error {
+//			m, _ := model.NewModelFromFile("model.conf")
+//			for _, rule := range rules {
+//				persist.LoadPolicyArray(rule, m)
+//			}
+//			return xormAdapter.SavePolicy(m)
+//		},
+//	}
+//	NewEnforcer("model.conf", adapter.NewCasbinAdapter(xormAdapter, loader))
+type CasbinLoader interface {
+	LoadRules() ([][]string, error)
+	SaveRules(rules [][]string) error
+}
+
+// CasbinLoaderFuncs adapts functions to the CasbinLoader interface
+type CasbinLoaderFuncs struct {
+	LoadFunc func() ([][]string, error)
+	SaveFunc func(rules [][]string) error
+}
+
+func (l CasbinLoaderFuncs) LoadRules() ([][]string, error) {
+	return l.LoadFunc()
+}
+
+func (l CasbinLoaderFuncs) SaveRules(rules [][]string) error {
+	return l.SaveFunc(rules)
+}
+
+// CasbinRules prefixes the rules of a casbin policy type with ptype, e.g. the result of model.GetPolicy("p", ptype)
+func CasbinRules(ptype string, rules [][]string) [][]string {
+	res := make([][]string, len(rules))
+	for i, rule := range rules {
+		res[i] = append([]string{ptype}, rule...)
+	}
+	return res
+}
+
+// casbinSec returns the section of a policy type, e.g. "g" for "g2"
+func casbinSec(rule []string) (sec string, ptype string, err error) {
+	if len(rule) < 2 || rule[0] == "" {
+		return "", "", fmt.Errorf("error: invalid rule %v", rule)
+	}
+	return rule[0][:1], rule[0], nil
+}
+
+// CasbinAdapter wraps a casbin adapter as storage.Adapter
+type CasbinAdapter struct {
+	persister CasbinPersister
+	loader    CasbinLoader
+}
+
+// NewCasbinAdapter wraps a casbin adapter, loader loads and saves its rules
+func NewCasbinAdapter(persister CasbinPersister, loader CasbinLoader) *CasbinAdapter {
+	return &CasbinAdapter{persister: persister, loader: loader}
+}
+
+func (a *CasbinAdapter) LoadPolicy(model api.IAddRuleBool) error {
+	rules, err := a.loader.LoadRules()
+	if err != nil {
+		return err
+	}
+	return addRules(model, rules)
+}
+
+func (a *CasbinAdapter) SavePolicy(model api.IRangeRules) error {
+	rules := [][]string{}
+	model.RangeRules(func(rule []string) bool {
+		rules = append(rules, rule)
+		return true
+	})
+	return a.loader.SaveRules(rules)
+}
+
+func (a *CasbinAdapter) AddRule(rule []string) error {
+	sec, ptype, err := casbinSec(rule)
+	if err != nil {
+		return err
+	}
+	return a.persister.AddPolicy(sec, ptype, rule[1:])
+}
+
+func (a *CasbinAdapter) RemoveRule(rule []string) error {
+	sec, ptype, err := casbinSec(rule)
+	if err != nil {
+		return err
+	}
+	return a.persister.RemovePolicy(sec, ptype, rule[1:])
+}
+
+// AddRules adds the rules in batches per policy type, if the casbin adapter is a batch adapter
+func (a *CasbinAdapter) AddRules(rules [][]string) error {
+	batch, ok := a.persister.(CasbinBatchPersister)
+	if !ok {
+		for _, rule := range rules {
+			if err := a.AddRule(rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return casbinBatches(rules, batch.AddPolicies)
+}
+
+// RemoveRules removes the rules in batches per policy type, if the casbin adapter is a batch adapter
+func (a *CasbinAdapter) RemoveRules(rules [][]string) error {
+	batch, ok := a.persister.(CasbinBatchPersister)
+	if !ok {
+		for _, rule := range rules {
+			if err := a.RemoveRule(rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return casbinBatches(rules, batch.RemovePolicies)
+}
+
+// casbinBatches calls fn for every run of rules with the same policy type
+func casbinBatches(rules [][]string, fn func(sec string, ptype string, rules [][]string) error) error {
+	for start := 0; start < len(rules); {
+		sec, ptype, err := casbinSec(rules[start])
+		if err != nil {
+			return err
+		}
+		end := start
+		batch := [][]string{}
+		for ; end < len(rules) && len(rules[end]) > 0 && rules[end][0] == ptype; end++ {
+			batch = append(batch, rules[end][1:])
+		}
+		if err := fn(sec, ptype, batch); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// CasbinBridge exposes a storage.Adapter with the methods of casbin's persist.Adapter and persist.BatchAdapter.
+// LoadPolicy and SavePolicy of casbin take a casbin model, they are bridged by LoadRules and SaveRules:
+//
+//	type casbinAdapter struct{ *adapter.CasbinBridge }
+//
+//	func (a casbinAdapter) LoadPolicy(m model.Model) error {
+//		rules, err := a.LoadRules()
+//		for _, rule := range rules {
+//			persist.LoadPolicyArray(rule, m)
+//		}
+//		return err
+//	}
+//
+//	func (a casbinAdapter) SavePolicy(m model.Model) error {
+//		return a.SaveRules(append(adapter.CasbinRules("p", m.GetPolicy("p", "p")), adapter.CasbinRules("g", m.GetPolicy("g", "g"))...))
+//	}
+type CasbinBridge struct {
+	adapter storage.Adapter
+}
+
+// NewCasbinBridge wraps a fastac adapter for casbin
+func NewCasbinBridge(adapter storage.Adapter) *CasbinBridge {
+	return &CasbinBridge{adapter: adapter}
+}
+
+// LoadRules returns all rules of the adapter
+func (b *CasbinBridge) LoadRules() ([][]string, error) {
+	rs := NewRuleSet()
+	if err := b.adapter.LoadPolicy(rs); err != nil {
+		return nil, err
+	}
+	return rs.Rules(), nil
+}
+
+// SaveRules replaces all rules of the adapter
+func (b *CasbinBridge) SaveRules(rules [][]string) error {
+	rs := NewRuleSet()
+	for _, rule := range rules {
+		if _, err := rs.AddRule(rule); err != nil {
+			return err
+		}
+	}
+	return b.adapter.SavePolicy(rs)
+}
+
+func (b *CasbinBridge) AddPolicy(sec string, ptype string, rule []string) error {
+	return b.AddPolicies(sec, ptype, [][]string{rule})
+}
+
+func (b *CasbinBridge) RemovePolicy(sec string, ptype string, rule []string) error {
+	return b.RemovePolicies(sec, ptype, [][]string{rule})
+}
+
+func (b *CasbinBridge) AddPolicies(sec string, ptype string, rules [][]string) error {
+	rules = CasbinRules(ptype, rules)
+	switch a := b.adapter.(type) {
+	case storage.BatchAdapter:
+		return a.AddRules(rules)
+	case storage.SimpleAdapter:
+		for _, rule := range rules {
+			if err := a.AddRule(rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("error: adapter does not support adding rules")
+}
+
+func (b *CasbinBridge) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	rules = CasbinRules(ptype, rules)
+	switch a := b.adapter.(type) {
+	case storage.BatchAdapter:
+		return a.RemoveRules(rules)
+	case storage.SimpleAdapter:
+		for _, rule := range rules {
+			if err := a.RemoveRule(rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("error: adapter does not support removing rules")
+}
+
+// RemoveFilteredPolicy removes the rules of ptype, whose values starting at fieldIndex equal fieldValues,
+// empty field values match every value
+func (b *CasbinBridge) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	rules, err := b.LoadRules()
+	if err != nil {
+		return err
+	}
+	removed := [][]string{}
+	for _, rule := range rules {
+		if rule[0] != ptype {
+			continue
+		}
+		matched := true
+		for i, value := range fieldValues {
+			if value != "" && (fieldIndex+i+1 >= len(rule) || rule[fieldIndex+i+1] != value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			removed = append(removed, rule[1:])
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	return b.RemovePolicies(sec, ptype, removed)
+}
@@ -0,0 +1,208 @@
+package adapter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/storage"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Source is a named adapter of a CompositeAdapter
+type Source struct {
+	Name    string
+	Adapter storage.Adapter
+}
+
+type sourcedRule struct {
+	rule   []string
+	source int
+}
+
+// CompositeAdapter merges the rules of several sources.
+// Sources are ordered by ascending precedence, e.g. a base policy followed by an environment overlay.
+// A rule, which is defined by multiple sources, belongs to the source with the highest precedence.
+// Provenance may be called concurrently with the other methods, e.g. while decisions are encoded.
+type CompositeAdapter struct {
+	sources   []Source
+	overrides map[string][]int
+
+	mutex    sync.RWMutex
+	rules    map[string]sourcedRule
+	shadowed []sourcedRule
+}
+
+// NewCompositeAdapter creates a composite adapter, the last source has the highest precedence
+//
+//	adapter.NewCompositeAdapter(
+//		adapter.Source{Name: "base", Adapter: adapter.NewFileAdapter("base.csv")},
+//		adapter.Source{Name: "prod", Adapter: adapter.NewFileAdapter("prod.csv")},
+//	)
+func NewCompositeAdapter(sources ...Source) *CompositeAdapter {
+	return &CompositeAdapter{
+		sources:   sources,
+		overrides: make(map[string][]int),
+		rules:     make(map[string]sourcedRule),
+	}
+}
+
+// SetOverrideKey defines the columns, which identify a rule of the policy type key.
+// Rules of a source with higher precedence replace all rules of sources with lower precedence,
+// which have the same values in these columns. Columns are indices of the rule without the policy type.
+//
+// Let the overlay change the effect of (sub, obj, act):
+//
+//	a.SetOverrideKey("p", 0, 1, 2)
+func (a *CompositeAdapter) SetOverrideKey(key string, columns ...int) {
+	a.overrides[key] = columns
+}
+
+func (a *CompositeAdapter) overrideKey(rule []string) (string, bool) {
+	columns, ok := a.overrides[rule[0]]
+	if !ok {
+		return "", false
+	}
+	values := []string{rule[0]}
+	for _, column := range columns {
+		if column+1 < len(rule) {
+			values = append(values, rule[column+1])
+		}
+	}
+	return util.Hash(values), true
+}
+
+// LoadPolicy loads the merged rules of all sources into the model
+func (a *CompositeAdapter) LoadPolicy(model api.IAddRuleBool) error {
+	rules := make(map[string]sourcedRule)
+	order := []string{}
+	overridden := make(map[string][]string)
+	shadowed := []sourcedRule{}
+
+	for i, source := range a.sources {
+		rs := NewRuleSet()
+		if err := source.Adapter.LoadPolicy(rs); err != nil {
+			return err
+		}
+		for _, rule := range rs.Rules() {
+			hash := util.Hash(rule)
+			if key, ok := a.overrideKey(rule); ok {
+				kept := []string{hash}
+				for _, prev := range overridden[key] {
+					if r, ok := rules[prev]; ok && r.source < i {
+						shadowed = append(shadowed, r)
+						delete(rules, prev)
+					} else if prev != hash {
+						kept = append(kept, prev)
+					}
+				}
+				overridden[key] = kept
+			}
+			if _, ok := rules[hash]; !ok {
+				order = append(order, hash)
+			}
+			rules[hash] = sourcedRule{rule, i}
+		}
+	}
+
+	for _, hash := range order {
+		r, ok := rules[hash]
+		if !ok {
+			continue
+		}
+		if _, err := model.AddRule(r.rule); err != nil {
+			return err
+		}
+	}
+	a.mutex.Lock()
+	a.rules = rules
+	a.shadowed = shadowed
+	a.mutex.Unlock()
+	return nil
+}
+
+// SavePolicy saves every rule to the source it belongs to.
+// New rules are saved to the source with the highest precedence,
+// overridden rules are kept in their source.
+func (a *CompositeAdapter) SavePolicy(model api.IRangeRules) error {
+	sets := make([]*RuleSet, len(a.sources))
+	for i := range sets {
+		sets[i] = NewRuleSet()
+	}
+	a.mutex.RLock()
+	shadowed := a.shadowed
+	a.mutex.RUnlock()
+	for _, r := range shadowed {
+		if _, err := sets[r.source].AddRule(r.rule); err != nil {
+			return err
+		}
+	}
+	var err error
+	model.RangeRules(func(rule []string) bool {
+		_, err = sets[a.sourceIndex(rule)].AddRule(rule)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, source := range a.sources {
+		if err := source.Adapter.SavePolicy(sets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *CompositeAdapter) sourceIndex(rule []string) int {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if r, ok := a.rules[util.Hash(rule)]; ok {
+		return r.source
+	}
+	return len(a.sources) - 1
+}
+
+// AddRule adds the rule to the source with the highest precedence,
+// an error is returned, if the source does not implement storage.SimpleAdapter
+func (a *CompositeAdapter) AddRule(rule []string) error {
+	i := len(a.sources) - 1
+	simple, ok := a.sources[i].Adapter.(storage.SimpleAdapter)
+	if !ok {
+		return fmt.Errorf("error: source %s does not support adding rules", a.sources[i].Name)
+	}
+	if err := simple.AddRule(rule); err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	a.rules[util.Hash(rule)] = sourcedRule{rule, i}
+	a.mutex.Unlock()
+	return nil
+}
+
+// RemoveRule removes the rule from the source it belongs to,
+// an error is returned, if the source does not implement storage.SimpleAdapter
+func (a *CompositeAdapter) RemoveRule(rule []string) error {
+	i := a.sourceIndex(rule)
+	simple, ok := a.sources[i].Adapter.(storage.SimpleAdapter)
+	if !ok {
+		return fmt.Errorf("error: source %s does not support removing rules", a.sources[i].Name)
+	}
+	if err := simple.RemoveRule(rule); err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	delete(a.rules, util.Hash(rule))
+	a.mutex.Unlock()
+	return nil
+}
+
+// Provenance returns the name of the source a rule belongs to
+func (a *CompositeAdapter) Provenance(rule []string) (string, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	r, ok := a.rules[util.Hash(rule)]
+	if !ok {
+		return "", false
+	}
+	return a.sources[r.source].Name, true
+}
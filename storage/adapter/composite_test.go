@@ -0,0 +1,230 @@
+package adapter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/util"
+)
+
+// readOnlySource keeps its rules in memory and supports neither AddRule nor RemoveRule
+type readOnlySource struct {
+	rules [][]string
+}
+
+func (s *readOnlySource) LoadPolicy(model api.IAddRuleBool) error {
+	for _, rule := range s.rules {
+		if _, err := model.AddRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *readOnlySource) SavePolicy(model api.IRangeRules) error {
+	s.rules = [][]string{}
+	model.RangeRules(func(rule []string) bool {
+		s.rules = append(s.rules, rule)
+		return true
+	})
+	return nil
+}
+
+// memorySource is a readOnlySource, which supports AddRule and RemoveRule
+type memorySource struct {
+	readOnlySource
+}
+
+func (s *memorySource) AddRule(rule []string) error {
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+func (s *memorySource) RemoveRule(rule []string) error {
+	for i, r := range s.rules {
+		if reflect.DeepEqual(r, rule) {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func sorted(rules [][]string) [][]string {
+	res := append([][]string{}, rules...)
+	util.SortRules(res)
+	return res
+}
+
+func loadComposite(t *testing.T, a *CompositeAdapter) [][]string {
+	t.Helper()
+	rs := NewRuleSet()
+	if err := a.LoadPolicy(rs); err != nil {
+		t.Fatal(err)
+	}
+	return sorted(rs.Rules())
+}
+
+func TestCompositeAdapterPrecedence(t *testing.T) {
+	base := &memorySource{readOnlySource{[][]string{
+		{"p", "alice", "data1", "read", "allow"},
+		{"p", "bob", "data2", "write", "allow"},
+		{"g", "alice", "admin"},
+	}}}
+	prod := &memorySource{readOnlySource{[][]string{
+		{"p", "alice", "data1", "read", "deny"},
+		{"g", "alice", "admin"},
+	}}}
+
+	tests := []struct {
+		overrides  map[string][]int
+		want       [][]string
+		provenance map[string]string
+	}{
+		{
+			overrides: nil,
+			want: [][]string{
+				{"p", "alice", "data1", "read", "allow"},
+				{"p", "alice", "data1", "read", "deny"},
+				{"p", "bob", "data2", "write", "allow"},
+				{"g", "alice", "admin"},
+			},
+			provenance: map[string]string{
+				"g,alice,admin":                "prod",
+				"p,alice,data1,read,allow":     "base",
+				"p,alice,data1,read,deny":      "prod",
+				"p,bob,data2,write,allow":      "base",
+				"p,carol,data3,read,allow":     "",
+				"p,alice,data1,read,allow,foo": "",
+			},
+		},
+		{
+			overrides: map[string][]int{"p": {0, 1, 2}},
+			want: [][]string{
+				{"p", "alice", "data1", "read", "deny"},
+				{"p", "bob", "data2", "write", "allow"},
+				{"g", "alice", "admin"},
+			},
+			provenance: map[string]string{
+				"g,alice,admin":            "prod",
+				"p,alice,data1,read,allow": "",
+				"p,alice,data1,read,deny":  "prod",
+				"p,bob,data2,write,allow":  "base",
+			},
+		},
+		{
+			// the subject alone identifies a rule, the rule of bob is not overridden
+			overrides: map[string][]int{"p": {0}},
+			want: [][]string{
+				{"p", "alice", "data1", "read", "deny"},
+				{"p", "bob", "data2", "write", "allow"},
+				{"g", "alice", "admin"},
+			},
+			provenance: map[string]string{
+				"p,alice,data1,read,allow": "",
+				"p,bob,data2,write,allow":  "base",
+			},
+		},
+	}
+	for _, test := range tests {
+		a := NewCompositeAdapter(Source{"base", base}, Source{"prod", prod})
+		for key, columns := range test.overrides {
+			a.SetOverrideKey(key, columns...)
+		}
+		if got := loadComposite(t, a); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("LoadPolicy with overrides %v: %v, want %v", test.overrides, got, test.want)
+		}
+		for rule, want := range test.provenance {
+			got, ok := a.Provenance(strings.Split(rule, ","))
+			if got != want || ok != (want != "") {
+				t.Fatalf("Provenance(%s) with overrides %v: %s %v, want %s", rule, test.overrides, got, ok, want)
+			}
+		}
+	}
+}
+
+func TestCompositeAdapterSavePolicy(t *testing.T) {
+	base := &memorySource{readOnlySource{[][]string{
+		{"p", "alice", "data1", "read", "allow"},
+		{"p", "bob", "data2", "write", "allow"},
+	}}}
+	prod := &memorySource{readOnlySource{[][]string{
+		{"p", "alice", "data1", "read", "deny"},
+	}}}
+	a := NewCompositeAdapter(Source{"base", base}, Source{"prod", prod})
+	a.SetOverrideKey("p", 0, 1, 2)
+	loadComposite(t, a)
+
+	model := NewRuleSet()
+	for _, rule := range [][]string{
+		{"p", "alice", "data1", "read", "deny"},
+		{"p", "carol", "data3", "read", "allow"},
+	} {
+		if _, err := model.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.SavePolicy(model); err != nil {
+		t.Fatal(err)
+	}
+	// the overridden rule is kept in base, the removed rule of bob is dropped, the new rule is saved to prod
+	if want := [][]string{{"p", "alice", "data1", "read", "allow"}}; !reflect.DeepEqual(sorted(base.rules), want) {
+		t.Fatalf("base after SavePolicy: %v, want %v", base.rules, want)
+	}
+	if want := [][]string{{"p", "alice", "data1", "read", "deny"}, {"p", "carol", "data3", "read", "allow"}}; !reflect.DeepEqual(sorted(prod.rules), want) {
+		t.Fatalf("prod after SavePolicy: %v, want %v", prod.rules, want)
+	}
+}
+
+func TestCompositeAdapterAddRemoveRule(t *testing.T) {
+	base := &memorySource{readOnlySource{[][]string{{"p", "alice", "data1", "read", "allow"}}}}
+	prod := &memorySource{readOnlySource{[][]string{}}}
+	a := NewCompositeAdapter(Source{"base", base}, Source{"prod", prod})
+	loadComposite(t, a)
+
+	rule := []string{"p", "carol", "data3", "read", "allow"}
+	if err := a.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(prod.rules, [][]string{rule}) {
+		t.Fatalf("prod after AddRule: %v, want %v", prod.rules, [][]string{rule})
+	}
+	if source, _ := a.Provenance(rule); source != "prod" {
+		t.Fatalf("Provenance after AddRule: %s, want prod", source)
+	}
+
+	if err := a.RemoveRule(base.rules[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(base.rules) != 0 {
+		t.Fatalf("base after RemoveRule: %v, want none", base.rules)
+	}
+	if _, ok := a.Provenance([]string{"p", "alice", "data1", "read", "allow"}); ok {
+		t.Fatal("Provenance after RemoveRule: true")
+	}
+}
+
+func TestCompositeAdapterUnsupportedWrite(t *testing.T) {
+	base := &readOnlySource{[][]string{{"p", "alice", "data1", "read", "allow"}}}
+	prod := &readOnlySource{[][]string{}}
+	a := NewCompositeAdapter(Source{"base", base}, Source{"prod", prod})
+	loadComposite(t, a)
+
+	rule := []string{"p", "carol", "data3", "read", "allow"}
+	if err := a.AddRule(rule); err == nil {
+		t.Fatal("AddRule to a read-only source: nil error")
+	}
+	if _, ok := a.Provenance(rule); ok {
+		t.Fatal("Provenance after failed AddRule: true")
+	}
+
+	rule = []string{"p", "alice", "data1", "read", "allow"}
+	if err := a.RemoveRule(rule); err == nil {
+		t.Fatal("RemoveRule from a read-only source: nil error")
+	}
+	if source, _ := a.Provenance(rule); source != "base" {
+		t.Fatalf("Provenance after failed RemoveRule: %s, want base", source)
+	}
+}
@@ -0,0 +1,313 @@
+// Package dynamodb implements an adapter, which stores rules in an AWS DynamoDB table.
+//
+// Every rule is an item with the partition key "ptype" and the sort key "hash", a digest of the rule.
+// The DynamoDB API is plugged in as Client, so the adapter does not depend on a specific AWS SDK version,
+// e.g. with aws-sdk-go-v2 PutItem is implemented by
+//
+//	input := &dynamodb.PutItemInput{TableName: aws.String(table), Item: marshal(item)}
+//	if ifNotExists {
+//		input.ConditionExpression = aws.String("attribute_not_exists(#h)")
+//		input.ExpressionAttributeNames = map[string]string{"#h": "hash"}
+//	}
+//	_, err := client.PutItem(ctx, input)
+//	var ccf *types.ConditionalCheckFailedException
+//	if errors.As(err, &ccf) {
+//		return dynamodb.ErrConditionFailed
+//	}
+package dynamodb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/storage"
+)
+
+// MaxBatchSize is the maximum number of writes of a DynamoDB BatchWriteItem request
+const MaxBatchSize = 25
+
+// ErrConditionFailed is returned by a Client, if the condition of a write was not met
+var ErrConditionFailed = errors.New("error: condition of the write was not met")
+
+// Key identifies an item
+type Key struct {
+	PType string
+	Hash  string
+}
+
+// Item is a stored rule. Rule contains the values of the rule without the policy type.
+type Item struct {
+	Key
+	Rule []string
+	// Version identifies the incarnation of the item, it is set when the item is created,
+	// so a conditional delete never removes an item, which was deleted and created again by another writer
+	Version int64
+}
+
+// Client performs the DynamoDB requests of the adapter on a table
+type Client interface {
+	// PutItem writes an item. If ifNotExists is set, it fails with ErrConditionFailed, if the item exists.
+	PutItem(ctx context.Context, item Item, ifNotExists bool) error
+	// DeleteItem deletes an item. If ifVersion is not 0, it fails with ErrConditionFailed,
+	// if the item does not exist or has another version.
+	DeleteItem(ctx context.Context, key Key, ifVersion int64) error
+	// BatchWriteItems writes at most MaxBatchSize puts and deletes unconditionally
+	BatchWriteItems(ctx context.Context, puts []Item, deletes []Key) error
+	// QueryItems returns all items of a policy type
+	QueryItems(ctx context.Context, ptype string) ([]Item, error)
+	// ScanItems returns all items of the table
+	ScanItems(ctx context.Context) ([]Item, error)
+}
+
+// KeyOf returns the key of a rule, whose first value is the policy type
+func KeyOf(rule []string) Key {
+	data, _ := json.Marshal(rule)
+	sum := sha256.Sum256(data)
+	return Key{PType: rule[0], Hash: hex.EncodeToString(sum[:])}
+}
+
+func newVersion() int64 {
+	return time.Now().UnixNano()
+}
+
+// Adapter stores rules in a DynamoDB table.
+//
+// AddRule uses a conditional put, so it never overwrites the item of another writer.
+// SavePolicy only deletes items, which were loaded by the adapter and have not been changed since,
+// items added concurrently by other writers are kept.
+// Flush writes with batch requests, which are not conditional.
+type Adapter struct {
+	client Client
+	ctx    context.Context
+
+	mutex    sync.Mutex
+	loaded   map[Key]int64
+	filtered bool
+}
+
+// NewAdapter creates an adapter, which sends its requests with client
+//
+//	NewEnforcer("model.conf", dynamodb.NewAdapter(client))
+func NewAdapter(client Client) *Adapter {
+	return &Adapter{
+		client: client,
+		ctx:    context.Background(),
+		loaded: make(map[Key]int64),
+	}
+}
+
+// WithContext returns a copy of the adapter, which sends its requests with ctx
+func (a *Adapter) WithContext(ctx context.Context) *Adapter {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	loaded := make(map[Key]int64, len(a.loaded))
+	for key, version := range a.loaded {
+		loaded[key] = version
+	}
+	return &Adapter{client: a.client, ctx: ctx, loaded: loaded}
+}
+
+func (a *Adapter) load(model api.IAddRuleBool, items []Item) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, item := range items {
+		a.loaded[item.Key] = item.Version
+		if _, err := model.AddRule(append([]string{item.PType}, item.Rule...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) LoadPolicy(model api.IAddRuleBool) error {
+	items, err := a.client.ScanItems(a.ctx)
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	a.loaded = make(map[Key]int64)
+	a.filtered = false
+	a.mutex.Unlock()
+	return a.load(model, items)
+}
+
+// LoadFilteredPolicy loads only the rules matching the filter.
+// Every policy type of the filter is a single query, the values are compared by the adapter, an empty filter scans all rules.
+//
+//	a.LoadFilteredPolicy(e.GetModel(), storage.Filter{"p": {"", "tenant1"}, "g": {"", "", "tenant1"}})
+func (a *Adapter) LoadFilteredPolicy(model api.IAddRuleBool, filter storage.Filter) error {
+	a.mutex.Lock()
+	a.loaded = make(map[Key]int64)
+	a.mutex.Unlock()
+	if len(filter) == 0 {
+		items, err := a.client.ScanItems(a.ctx)
+		if err != nil {
+			return err
+		}
+		if err := a.load(model, items); err != nil {
+			return err
+		}
+	}
+	for _, ptype := range filter.PTypes() {
+		items, err := a.client.QueryItems(a.ctx, ptype)
+		if err != nil {
+			return err
+		}
+		matched := items[:0]
+		for _, item := range items {
+			if filter.Match(append([]string{item.PType}, item.Rule...)) {
+				matched = append(matched, item)
+			}
+		}
+		if err := a.load(model, matched); err != nil {
+			return err
+		}
+	}
+	a.mutex.Lock()
+	a.filtered = true
+	a.mutex.Unlock()
+	return nil
+}
+
+// IsFiltered returns true, if the rules have been loaded by LoadFilteredPolicy
+func (a *Adapter) IsFiltered() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.filtered
+}
+
+// SavePolicy writes all rules of model and deletes the loaded rules, which are not part of model
+func (a *Adapter) SavePolicy(model api.IRangeRules) error {
+	keep := make(map[Key]struct{})
+	var err error
+	model.RangeRules(func(rule []string) bool {
+		key := KeyOf(rule)
+		keep[key] = struct{}{}
+		if err = a.put(key, rule); err == ErrConditionFailed {
+			err = nil
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	deletes := map[Key]int64{}
+	for key, version := range a.loaded {
+		if _, ok := keep[key]; !ok {
+			deletes[key] = version
+		}
+	}
+	a.mutex.Unlock()
+	for key, version := range deletes {
+		if err := a.delete(key, version); err != nil && err != ErrConditionFailed {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) put(key Key, rule []string) error {
+	item := Item{Key: key, Rule: rule[1:], Version: newVersion()}
+	if err := a.client.PutItem(a.ctx, item, true); err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	a.loaded[key] = item.Version
+	a.mutex.Unlock()
+	return nil
+}
+
+func (a *Adapter) delete(key Key, version int64) error {
+	if err := a.client.DeleteItem(a.ctx, key, version); err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	delete(a.loaded, key)
+	a.mutex.Unlock()
+	return nil
+}
+
+// AddRule stores a rule, rules which already exist are not overwritten
+func (a *Adapter) AddRule(rule []string) error {
+	if err := a.put(KeyOf(rule), rule); err != nil && err != ErrConditionFailed {
+		return err
+	}
+	return nil
+}
+
+// RemoveRule deletes a rule
+func (a *Adapter) RemoveRule(rule []string) error {
+	return a.delete(KeyOf(rule), 0)
+}
+
+// AddRules stores the rules with batch requests of MaxBatchSize rules
+func (a *Adapter) AddRules(rules [][]string) error {
+	return a.batch(rules, func(batch [][]string) error {
+		puts := make([]Item, len(batch))
+		for i, rule := range batch {
+			puts[i] = Item{Key: KeyOf(rule), Rule: rule[1:], Version: newVersion()}
+		}
+		if err := a.client.BatchWriteItems(a.ctx, puts, nil); err != nil {
+			return err
+		}
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		for _, item := range puts {
+			a.loaded[item.Key] = item.Version
+		}
+		return nil
+	})
+}
+
+// RemoveRules deletes the rules with batch requests of MaxBatchSize rules
+func (a *Adapter) RemoveRules(rules [][]string) error {
+	return a.batch(rules, func(batch [][]string) error {
+		deletes := make([]Key, len(batch))
+		for i, rule := range batch {
+			deletes[i] = KeyOf(rule)
+		}
+		if err := a.client.BatchWriteItems(a.ctx, nil, deletes); err != nil {
+			return err
+		}
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		for _, key := range deletes {
+			delete(a.loaded, key)
+		}
+		return nil
+	})
+}
+
+// batch calls fn with batches of at most MaxBatchSize rules. BatchWriteItem rejects requests,
+// which write a key twice, so duplicate rules are written once.
+func (a *Adapter) batch(rules [][]string, fn func(batch [][]string) error) error {
+	seen := make(map[Key]struct{}, len(rules))
+	unique := make([][]string, 0, len(rules))
+	for _, rule := range rules {
+		key := KeyOf(rule)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, rule)
+	}
+	rules = unique
+	for start := 0; start < len(rules); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(rules) {
+			end = len(rules)
+		}
+		if err := fn(rules[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/oarkflow/fastac/storage"
+	"github.com/oarkflow/fastac/storage/adapter"
+	"github.com/oarkflow/fastac/util"
+)
+
+// fakeClient keeps the items in memory and validates batches like DynamoDB
+type fakeClient struct {
+	mutex   sync.Mutex
+	items   map[Key]Item
+	batches int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{items: make(map[Key]Item)}
+}
+
+func (c *fakeClient) PutItem(ctx context.Context, item Item, ifNotExists bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.items[item.Key]; ok && ifNotExists {
+		return ErrConditionFailed
+	}
+	c.items[item.Key] = item
+	return nil
+}
+
+func (c *fakeClient) DeleteItem(ctx context.Context, key Key, ifVersion int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if item, ok := c.items[key]; ifVersion != 0 && (!ok || item.Version != ifVersion) {
+		return ErrConditionFailed
+	}
+	delete(c.items, key)
+	return nil
+}
+
+func (c *fakeClient) BatchWriteItems(ctx context.Context, puts []Item, deletes []Key) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(puts)+len(deletes) > MaxBatchSize {
+		return errors.New("too many items requested for the BatchWriteItem call")
+	}
+	keys := make(map[Key]struct{})
+	for _, key := range append(keysOf(puts), deletes...) {
+		if _, ok := keys[key]; ok {
+			return errors.New("provided list of item keys contains duplicates")
+		}
+		keys[key] = struct{}{}
+	}
+	c.batches++
+	for _, item := range puts {
+		c.items[item.Key] = item
+	}
+	for _, key := range deletes {
+		delete(c.items, key)
+	}
+	return nil
+}
+
+func keysOf(items []Item) []Key {
+	keys := make([]Key, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return keys
+}
+
+func (c *fakeClient) QueryItems(ctx context.Context, ptype string) ([]Item, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	items := []Item{}
+	for _, item := range c.items {
+		if item.PType == ptype {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (c *fakeClient) ScanItems(ctx context.Context) ([]Item, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	items := []Item{}
+	for _, item := range c.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (c *fakeClient) rules() [][]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	rules := [][]string{}
+	for _, item := range c.items {
+		rules = append(rules, append([]string{item.PType}, item.Rule...))
+	}
+	util.SortRules(rules)
+	return rules
+}
+
+func testRules(n int) [][]string {
+	rules := [][]string{}
+	for i := 0; i < n; i++ {
+		rules = append(rules, []string{"p", "user" + string(rune('a'+i%26)) + string(rune('a'+i/26)), "data", "read"})
+	}
+	util.SortRules(rules)
+	return rules
+}
+
+func TestAddRemoveRulesDuplicates(t *testing.T) {
+	client := newFakeClient()
+	a := NewAdapter(client)
+	rules := testRules(30)
+
+	// every rule is written twice in a row, so the 60 writes would fill three batches with duplicate keys
+	twice := [][]string{}
+	for _, rule := range rules {
+		twice = append(twice, rule, rule)
+	}
+	if err := a.AddRules(twice); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.rules(); !reflect.DeepEqual(got, rules) {
+		t.Fatalf("items after AddRules: %v, want %v", got, rules)
+	}
+	if client.batches != 2 {
+		t.Fatalf("AddRules sent %d batches, want 2", client.batches)
+	}
+
+	// duplicates are dropped across batches as well, the 25 unique keys fit into a single batch
+	removed := append(append([][]string{rules[0]}, rules[:20]...), rules[5:25]...)
+	if err := a.RemoveRules(removed); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.rules(); !reflect.DeepEqual(got, rules[25:]) {
+		t.Fatalf("items after RemoveRules: %v, want %v", got, rules[25:])
+	}
+	if client.batches != 3 {
+		t.Fatalf("RemoveRules sent %d batches, want 1", client.batches-2)
+	}
+	if err := a.AddRules(nil); err != nil || client.batches != 3 {
+		t.Fatalf("AddRules without rules: %v, %d batches, want none", err, client.batches-3)
+	}
+}
+
+func TestAddRuleConditional(t *testing.T) {
+	client := newFakeClient()
+	a := NewAdapter(client)
+	rule := []string{"p", "alice", "data1", "read"}
+	if err := a.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	version := client.items[KeyOf(rule)].Version
+
+	// the existing item of another writer is not overwritten
+	if err := NewAdapter(client).AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.items[KeyOf(rule)].Version; got != version {
+		t.Fatalf("version after second AddRule: %d, want %d", got, version)
+	}
+	if err := a.RemoveRule(rule); err != nil || len(client.items) != 0 {
+		t.Fatalf("RemoveRule: %v, items %v", err, client.items)
+	}
+}
+
+func TestSavePolicy(t *testing.T) {
+	client := newFakeClient()
+	rules := testRules(3)
+	if err := NewAdapter(client).AddRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAdapter(client)
+	if err := a.LoadPolicy(adapter.NewRuleSet()); err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer adds a rule and recreates the first rule after loading
+	other := NewAdapter(client)
+	added := []string{"p", "bob", "data2", "write"}
+	if err := other.AddRule(added); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.RemoveRule(rules[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.AddRule(rules[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	model := adapter.NewRuleSet()
+	carol := []string{"p", "carol", "data3", "read"}
+	for _, rule := range [][]string{rules[2], carol} {
+		if _, err := model.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.SavePolicy(model); err != nil {
+		t.Fatal(err)
+	}
+	// the unchanged loaded rule is deleted, the rules of the other writer are kept
+	want := [][]string{added, carol, rules[0], rules[2]}
+	util.SortRules(want)
+	if got := client.rules(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("items after SavePolicy: %v, want %v", got, want)
+	}
+}
+
+func TestLoadFilteredPolicy(t *testing.T) {
+	client := newFakeClient()
+	rules := [][]string{
+		{"p", "alice", "tenant1", "read"},
+		{"p", "bob", "tenant2", "read"},
+		{"g", "alice", "admin", "tenant1"},
+		{"g", "bob", "admin", "tenant2"},
+	}
+	if err := NewAdapter(client).AddRules(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter storage.Filter
+		want   [][]string
+	}{
+		{storage.Filter{"p": {"", "tenant1"}, "g": {"", "", "tenant1"}}, [][]string{rules[0], rules[2]}},
+		{storage.Filter{"g": {"bob"}}, [][]string{rules[3]}},
+		{storage.Filter{}, rules},
+		{nil, rules},
+	}
+	for _, test := range tests {
+		a := NewAdapter(client)
+		model := adapter.NewRuleSet()
+		if err := a.LoadFilteredPolicy(model, test.filter); err != nil {
+			t.Fatal(err)
+		}
+		got := model.Rules()
+		util.SortRules(got)
+		want := append([][]string{}, test.want...)
+		util.SortRules(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("LoadFilteredPolicy(%v): %v, want %v", test.filter, got, want)
+		}
+		if !a.IsFiltered() {
+			t.Fatalf("IsFiltered after LoadFilteredPolicy(%v): false", test.filter)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Types of stream records
+const (
+	INSERT = "INSERT"
+	MODIFY = "MODIFY"
+	REMOVE = "REMOVE"
+)
+
+// Record is a change of the table read from DynamoDB Streams
+type Record struct {
+	EventName string
+	Item      Item
+}
+
+// Stream reads the records of DynamoDB Streams, e.g. by GetRecords of all shards of the table stream
+type Stream interface {
+	// Next returns the next records, it returns no records, if there are no new changes
+	Next(ctx context.Context) ([]Record, error)
+}
+
+// StreamWatcher notifies about changes of the table, which are read from DynamoDB Streams.
+// It implements the Watcher of the cluster package, Update is a no-op, since every write appears in the stream.
+//
+//	w := dynamodb.NewStreamWatcher(stream, time.Second)
+//	w.SetUpdateCallback(func() { e.GetModel().ClearPolicy(); e.LoadPolicy() })
+//	go w.Run(ctx)
+type StreamWatcher struct {
+	stream   Stream
+	interval time.Duration
+
+	mutex    sync.Mutex
+	onUpdate func()
+	onRecord func(record Record)
+}
+
+// NewStreamWatcher creates a watcher, which polls stream every interval
+func NewStreamWatcher(stream Stream, interval time.Duration) *StreamWatcher {
+	return &StreamWatcher{stream: stream, interval: interval}
+}
+
+// Update does nothing, the other nodes read the changes from the stream
+func (w *StreamWatcher) Update() error {
+	return nil
+}
+
+// SetUpdateCallback sets the function, which is called once for every batch of records
+func (w *StreamWatcher) SetUpdateCallback(fn func()) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onUpdate = fn
+	return nil
+}
+
+// SetRecordCallback sets the function, which is called for every record, e.g. to apply changes incrementally
+func (w *StreamWatcher) SetRecordCallback(fn func(record Record)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onRecord = fn
+}
+
+// Run polls the stream until ctx is done or the stream fails
+func (w *StreamWatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		records, err := w.stream.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if len(records) > 0 {
+			w.mutex.Lock()
+			onUpdate, onRecord := w.onUpdate, w.onRecord
+			w.mutex.Unlock()
+			if onRecord != nil {
+				for _, record := range records {
+					onRecord(record)
+				}
+			}
+			if onUpdate != nil {
+				onUpdate()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -17,13 +17,14 @@ package adapter
 import (
 	"bufio"
 	"encoding/csv"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/oarkflow/fastac/api"
 	"github.com/oarkflow/fastac/model/defs"
 	"github.com/oarkflow/fastac/model/policy"
-	"github.com/oarkflow/fastac/util"
+	"github.com/oarkflow/fastac/storage"
 )
 
 // LoadPolicyLine loads a text line as a policy rule to model.
@@ -32,10 +33,7 @@ func LoadPolicyLine(line string, m api.IAddRuleBool) error {
 		return nil
 	}
 
-	r := csv.NewReader(strings.NewReader(line))
-	r.Comma = ','
-	r.Comment = '#'
-	r.TrimLeadingSpace = true
+	r := newPolicyReader(strings.NewReader(line))
 
 	tokens, err := r.Read()
 	if err != nil {
@@ -46,8 +44,53 @@ func LoadPolicyLine(line string, m api.IAddRuleBool) error {
 	return err
 }
 
+// LoadPolicyReader loads all rules from r to model.
+// Values may be quoted according to RFC 4180 and contain separators, quotes and line breaks.
+func LoadPolicyReader(r io.Reader, m api.IAddRuleBool) error {
+	reader := newPolicyReader(r)
+	rules := [][]string{}
+	for {
+		tokens, err := reader.Read()
+		if err == io.EOF {
+			return addRules(m, rules)
+		}
+		if err != nil {
+			return err
+		}
+		rules = append(rules, tokens)
+	}
+}
+
+// addRules adds the rules in bulk, if the model supports it
+func addRules(m api.IAddRuleBool, rules [][]string) error {
+	if bulk, ok := m.(api.IAddRulesBulk); ok {
+		_, err := bulk.AddRules(rules)
+		return err
+	}
+	for _, rule := range rules {
+		if _, err := m.AddRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPolicyReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comma = ','
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// FileAdapter stores rules in a CSV file.
+// Comments, blank lines and include directives are restored by SavePolicy. Rules are saved to the file they were loaded from,
+// so removed rules of included files are removed from these files, new rules are appended to the file of the adapter.
 type FileAdapter struct {
-	path string
+	path     string
+	layout   *layout
+	filtered bool
 }
 
 type RuleSet struct {
@@ -75,65 +118,76 @@ func (set *RuleSet) Rules() [][]string {
 }
 
 func NewFileAdapter(path string) *FileAdapter {
-	return &FileAdapter{path: path}
+	return &FileAdapter{path: path, layout: newLayout()}
+}
+
+// Path returns the path of the CSV file
+func (a *FileAdapter) Path() string {
+	return a.path
 }
 
 func (a *FileAdapter) LoadPolicy(model api.IAddRuleBool) error {
-	file, err := os.Open(a.path)
-	if err != nil {
+	if err := a.load(model); err != nil {
 		return err
 	}
-	defer file.Close()
+	a.filtered = false
+	return nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		if err := LoadPolicyLine(scanner.Text(), model); err != nil {
-			return err
-		}
+// LoadFilteredPolicy loads only the rules matching the filter, the file is read completely
+func (a *FileAdapter) LoadFilteredPolicy(model api.IAddRuleBool, filter storage.Filter) error {
+	if err := a.load(filter.Target(model)); err != nil {
+		return err
 	}
+	a.filtered = true
+	return nil
+}
 
-	return scanner.Err()
+// IsFiltered returns true, if the rules have been loaded by LoadFilteredPolicy
+func (a *FileAdapter) IsFiltered() bool {
+	return a.filtered
 }
 
-func getWriter(path string) (*bufio.Writer, error) {
-	if exists, err := util.FileExists(path); err != nil {
-		return nil, err
-	} else if exists {
-		if err := os.Remove(path); err != nil {
-			return nil, err
-		}
-	}
-	if _, err := os.Create(path); err != nil {
-		return nil, err
-	}
-	f, err := os.OpenFile(path, os.O_WRONLY, 0600)
-	if err != nil {
-		return nil, err
+func (a *FileAdapter) load(model api.IAddRuleBool) error {
+	l := newLayout()
+	if err := l.load(a.path, model, map[string]bool{}); err != nil {
+		return err
 	}
-	return bufio.NewWriter(f), nil
+	a.layout = l
+	return nil
 }
 
 func (a *FileAdapter) SavePolicy(model api.IRangeRules) error {
-	writer, err := getWriter(a.path)
+	for _, file := range a.layout.lines(a.path, model) {
+		if err := writeLines(file.path, file.lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLines(path string, lines []string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
-	model.RangeRules(func(rule []string) bool {
-		if _, err = writer.WriteString(strings.Join(rule, ", ") + "\n"); err != nil {
-			return false
+	writer := bufio.NewWriter(f)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			f.Close()
+			return err
 		}
-		return true
-	})
-	if err != nil {
+	}
+	if err := writer.Flush(); err != nil {
+		f.Close()
 		return err
 	}
-
-	return writer.Flush()
+	return f.Close()
 }
 
 func (a *FileAdapter) AddRule(rule []string) error {
 	rs := NewRuleSet()
-	if err := a.LoadPolicy(rs); err != nil {
+	if err := a.load(rs); err != nil {
 		return err
 	}
 	if _, err := rs.AddRule(rule); err != nil {
@@ -147,7 +201,7 @@ func (a *FileAdapter) AddRule(rule []string) error {
 
 func (a *FileAdapter) RemoveRule(rule []string) error {
 	rs := NewRuleSet()
-	if err := a.LoadPolicy(rs); err != nil {
+	if err := a.load(rs); err != nil {
 		return err
 	}
 	if _, err := rs.RemoveRule(rule); err != nil {
@@ -161,7 +215,7 @@ func (a *FileAdapter) RemoveRule(rule []string) error {
 
 func (a *FileAdapter) AddRules(rules [][]string) error {
 	rs := NewRuleSet()
-	if err := a.LoadPolicy(rs); err != nil {
+	if err := a.load(rs); err != nil {
 		return err
 	}
 	for _, rule := range rules {
@@ -177,7 +231,7 @@ func (a *FileAdapter) AddRules(rules [][]string) error {
 
 func (a *FileAdapter) RemoveRules(rules [][]string) error {
 	rs := NewRuleSet()
-	if err := a.LoadPolicy(rs); err != nil {
+	if err := a.load(rs); err != nil {
 		return err
 	}
 	for _, rule := range rules {
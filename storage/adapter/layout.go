@@ -0,0 +1,247 @@
+package adapter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/util"
+)
+
+// IncludeDirective includes the rules of another policy file.
+// Relative paths are resolved against the directory of the including file.
+//
+//	!include team_a.csv
+const IncludeDirective = "!include"
+
+type entryKind int
+
+const (
+	ruleEntry entryKind = iota
+	textEntry
+	includeEntry
+)
+
+// layoutEntry is a line of a policy file, which is restored by SavePolicy
+type layoutEntry struct {
+	kind entryKind
+	text string
+	// rule is the parsed rule of a ruleEntry, text is its hash
+	rule []string
+}
+
+// fileLayout keeps the comments, blank lines, include directives and rules of a policy file
+type fileLayout struct {
+	path    string
+	abs     string
+	entries []layoutEntry
+}
+
+// layout keeps the layouts of a policy file and the files it includes, the including file is the first
+type layout struct {
+	files []*fileLayout
+}
+
+func newLayout() *layout {
+	return &layout{}
+}
+
+// records splits text into lines and multi-line records with quoted line breaks.
+// Comments are single lines, their quotes do not start a multi-line record.
+func records(text string) []string {
+	res := []string{}
+	buf := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if buf == "" && strings.HasPrefix(strings.TrimSpace(line), "#") {
+			res = append(res, line)
+			continue
+		}
+		if buf != "" {
+			buf += "\n" + line
+		} else {
+			buf = line
+		}
+		if strings.Count(buf, "\"")%2 == 0 {
+			res = append(res, buf)
+			buf = ""
+		}
+	}
+	if buf != "" {
+		res = append(res, buf)
+	}
+	return res
+}
+
+func (l *layout) load(path string, model api.IAddRuleBool, visited map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if visited[abs] {
+		return fmt.Errorf("include cycle: %s", path)
+	}
+	for _, file := range l.files {
+		// a file included twice is loaded once
+		if file.abs == abs {
+			return nil
+		}
+	}
+	visited[abs] = true
+	defer delete(visited, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	recs := records(string(data))
+	// a trailing line break does not start a new line
+	if len(recs) > 0 && recs[len(recs)-1] == "" {
+		recs = recs[:len(recs)-1]
+	}
+
+	file := &fileLayout{path: path, abs: abs}
+	l.files = append(l.files, file)
+	rules := [][]string{}
+	for _, rec := range recs {
+		trimmed := strings.TrimSpace(rec)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			file.entries = append(file.entries, layoutEntry{kind: textEntry, text: rec})
+		case strings.HasPrefix(trimmed, IncludeDirective+" "):
+			file.entries = append(file.entries, layoutEntry{kind: includeEntry, text: rec})
+			incPath := strings.TrimSpace(trimmed[len(IncludeDirective):])
+			if !filepath.IsAbs(incPath) {
+				incPath = filepath.Join(filepath.Dir(path), incPath)
+			}
+			if err := l.load(incPath, model, visited); err != nil {
+				return err
+			}
+		default:
+			tokens, err := newPolicyReader(strings.NewReader(rec)).Read()
+			if err != nil {
+				return err
+			}
+			rules = append(rules, tokens)
+			file.entries = append(file.entries, layoutEntry{kind: ruleEntry, text: util.Hash(tokens), rule: tokens})
+		}
+	}
+	return addRules(model, rules)
+}
+
+// lines restores the layouts of the files for the given rules and returns the lines of every file.
+// Rules missing in model are removed from the file they were loaded from,
+// new rules are appended to the including file in canonical order.
+func (l *layout) lines(path string, model api.IRangeRules) []fileLines {
+	current := make(map[string][]string)
+	model.RangeRules(func(rule []string) bool {
+		current[util.Hash(rule)] = rule
+		return true
+	})
+
+	files := l.files
+	if len(files) == 0 {
+		files = []*fileLayout{{path: path}}
+	}
+	res := make([]fileLines, 0, len(files))
+	written := make(map[string]struct{})
+	for _, file := range files {
+		lines := []string{}
+		for _, entry := range file.entries {
+			switch entry.kind {
+			case textEntry, includeEntry:
+				lines = append(lines, entry.text)
+			case ruleEntry:
+				rule, ok := current[entry.text]
+				if _, done := written[entry.text]; !ok || done {
+					continue
+				}
+				lines = append(lines, util.FormatRule(rule))
+				written[entry.text] = struct{}{}
+			}
+		}
+		res = append(res, fileLines{file.path, lines})
+	}
+
+	added := [][]string{}
+	for key, rule := range current {
+		if _, done := written[key]; !done {
+			added = append(added, rule)
+		}
+	}
+	util.SortRules(added)
+	for _, rule := range added {
+		res[0].lines = append(res[0].lines, util.FormatRule(rule))
+	}
+	return res
+}
+
+// fileLines are the lines of a policy file
+type fileLines struct {
+	path  string
+	lines []string
+}
+
+// PolicyFile is the path and the content of a policy file
+type PolicyFile struct {
+	Path    string
+	Content string
+}
+
+// FormatPolicyFile formats a policy file and the files it includes, see util.FormatRule.
+// Comments, blank lines and include directives are kept, runs of rules between them are sorted by columns and deduplicated.
+//
+//	files, _ := FormatPolicyFile("policy.csv", 1, 0)
+func FormatPolicyFile(path string, columns ...int) ([]PolicyFile, error) {
+	l := newLayout()
+	if err := l.load(path, NewRuleSet(), map[string]bool{}); err != nil {
+		return nil, err
+	}
+	files := []PolicyFile{}
+	for _, file := range l.format(columns...) {
+		files = append(files, PolicyFile{file.path, joinLines(file.lines)})
+	}
+	return files, nil
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// format returns the lines of every file with formatted rules. Runs of rules between comments, blank lines
+// and include directives are sorted by columns and deduplicated, so comments stay in front of the rules they describe.
+func (l *layout) format(columns ...int) []fileLines {
+	res := make([]fileLines, 0, len(l.files))
+	for _, file := range l.files {
+		lines := []string{}
+		run := [][]string{}
+		flush := func() {
+			util.SortRules(run, columns...)
+			last := ""
+			for _, rule := range run {
+				if line := util.FormatRule(rule); line != last {
+					lines = append(lines, line)
+					last = line
+				}
+			}
+			run = run[:0]
+		}
+		for _, entry := range file.entries {
+			if entry.kind == ruleEntry {
+				run = append(run, entry.rule)
+				continue
+			}
+			flush()
+			lines = append(lines, entry.text)
+		}
+		flush()
+		res = append(res, fileLines{file.path, lines})
+	}
+	return res
+}
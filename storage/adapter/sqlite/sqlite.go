@@ -0,0 +1,391 @@
+// Package sqlite implements an adapter, which stores rules in a single SQLite database file.
+//
+// The adapter uses database/sql, the CGO-free driver modernc.org/sqlite is registered by importing it:
+//
+//	import _ "modernc.org/sqlite"
+//
+//	a, _ := sqlite.Open("/var/lib/pdp/rules.db")
+//	defer a.Close()
+//	NewEnforcer("model.conf", a, OptionAutosave(true))
+//
+// The database is switched to WAL mode, so enforcers can load rules while another process writes.
+// PRAGMA settings other than journal_mode apply only to the connection executing them,
+// Open passes them in the DSN syntax of modernc.org/sqlite, so every connection of the pool uses them.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/storage"
+)
+
+// DriverName is the name of the database/sql driver used by Open
+var DriverName = "sqlite"
+
+// DefaultTable is the name of the rule table used by Open
+const DefaultTable = "fastac_rules"
+
+var tableReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ErrFenced is returned by writes, whose fencing token is lower than the token of a previous write
+var ErrFenced = errors.New("error: write has been fenced by a newer leader")
+
+// Adapter stores rules in a table with the columns ptype and rule, rule contains the values as JSON array.
+// The applied migrations of fastac.Enforcer.Migrate are recorded in the table with the suffix _migrations,
+// soft-deleted rules are moved to the table with the suffix _tombstones.
+// The highest fencing token, see SetFencingToken, is stored in the table with the suffix _fence.
+type Adapter struct {
+	db       *sql.DB
+	table    string
+	owned    bool
+	filtered bool
+	token    uint64
+}
+
+// Pragmas are appended to the path by Open, every connection opened by the driver applies them
+const Pragmas = "_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)"
+
+// Open opens the database file at path with DriverName and creates the rule table, if it does not exist
+func Open(path string) (*Adapter, error) {
+	dsn := path + "?" + Pragmas
+	if strings.Contains(path, "?") {
+		dsn = path + "&" + Pragmas
+	}
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewAdapter(db, DefaultTable)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	a.owned = true
+	return a, nil
+}
+
+// NewAdapter creates an adapter for an open database, enables WAL mode and creates the table, if it does not exist.
+// The synchronous and busy_timeout pragmas are executed on a single connection of the pool only,
+// callers must either pass them in the DSN, see Pragmas, or limit the pool with db.SetMaxOpenConns(1).
+func NewAdapter(db *sql.DB, table string) (*Adapter, error) {
+	if !tableReg.MatchString(table) {
+		return nil, fmt.Errorf("error: invalid table name %s", table)
+	}
+	statements := []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA synchronous=NORMAL",
+		"PRAGMA busy_timeout=5000",
+		"CREATE TABLE IF NOT EXISTS " + table + " (ptype TEXT NOT NULL, rule TEXT NOT NULL, PRIMARY KEY (ptype, rule))",
+		"CREATE TABLE IF NOT EXISTS " + table + "_migrations (id TEXT PRIMARY KEY, applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)",
+		"CREATE TABLE IF NOT EXISTS " + table + "_tombstones (ptype TEXT NOT NULL, rule TEXT NOT NULL, deleted_at INTEGER NOT NULL, PRIMARY KEY (ptype, rule))",
+		"CREATE TABLE IF NOT EXISTS " + table + "_fence (id INTEGER PRIMARY KEY CHECK (id = 0), token INTEGER NOT NULL)",
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, err
+		}
+	}
+	return &Adapter{db: db, table: table}, nil
+}
+
+// Close closes the database, if it has been opened by Open
+func (a *Adapter) Close() error {
+	if !a.owned {
+		return nil
+	}
+	return a.db.Close()
+}
+
+func encodeRule(rule []string) (ptype string, values string, err error) {
+	if len(rule) < 2 {
+		return "", "", fmt.Errorf("error: invalid rule %v", rule)
+	}
+	data, err := json.Marshal(rule[1:])
+	return rule[0], string(data), err
+}
+
+func (a *Adapter) load(ctx context.Context, model api.IAddRuleBool, query string, args ...interface{}) error {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ptype, values string
+		if err := rows.Scan(&ptype, &values); err != nil {
+			return err
+		}
+		var rule []string
+		if err := json.Unmarshal([]byte(values), &rule); err != nil {
+			return err
+		}
+		if _, err := model.AddRule(append([]string{ptype}, rule...)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (a *Adapter) LoadPolicy(model api.IAddRuleBool) error {
+	return a.LoadPolicyContext(context.Background(), model)
+}
+
+// LoadPolicyContext loads all rules, the query is canceled with ctx
+func (a *Adapter) LoadPolicyContext(ctx context.Context, model api.IAddRuleBool) error {
+	if err := a.load(ctx, model, "SELECT ptype, rule FROM "+a.table+" ORDER BY rowid"); err != nil {
+		return err
+	}
+	a.filtered = false
+	return nil
+}
+
+// LoadFilteredPolicy loads only the rules matching the filter, the values are compared by the database with json_extract.
+// An empty filter loads all rules.
+//
+//	a.LoadFilteredPolicy(e.GetModel(), storage.Filter{"p": {"", "tenant1"}, "g": {"", "", "tenant1"}})
+func (a *Adapter) LoadFilteredPolicy(model api.IAddRuleBool, filter storage.Filter) error {
+	query := "SELECT ptype, rule FROM " + a.table
+	clauses := []string{}
+	args := []interface{}{}
+	for _, ptype := range filter.PTypes() {
+		clause := "(ptype = ?"
+		args = append(args, ptype)
+		for i, value := range filter[ptype] {
+			if value == "" {
+				continue
+			}
+			clause += fmt.Sprintf(" AND json_extract(rule, '$[%d]') = ?", i)
+			args = append(args, value)
+		}
+		clauses = append(clauses, clause+")")
+	}
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " OR ")
+	}
+	if err := a.load(context.Background(), model, query+" ORDER BY rowid", args...); err != nil {
+		return err
+	}
+	a.filtered = true
+	return nil
+}
+
+// IsFiltered returns true, if the rules have been loaded by LoadFilteredPolicy
+func (a *Adapter) IsFiltered() bool {
+	return a.filtered
+}
+
+// SavePolicy replaces all rules in a single transaction
+func (a *Adapter) SavePolicy(model api.IRangeRules) error {
+	return a.SavePolicyContext(context.Background(), model)
+}
+
+// SavePolicyContext replaces all rules in a single transaction, which is rolled back, if ctx is canceled
+func (a *Adapter) SavePolicyContext(ctx context.Context, model api.IRangeRules) error {
+	rules := [][]string{}
+	model.RangeRules(func(rule []string) bool {
+		rules = append(rules, rule)
+		return true
+	})
+	return a.transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM " + a.table); err != nil {
+			return err
+		}
+		return a.exec(tx, "INSERT OR IGNORE INTO "+a.table+" (ptype, rule) VALUES (?, ?)", rules)
+	})
+}
+
+func (a *Adapter) AddRule(rule []string) error {
+	return a.AddRules([][]string{rule})
+}
+
+func (a *Adapter) RemoveRule(rule []string) error {
+	return a.RemoveRules([][]string{rule})
+}
+
+// AddRules inserts the rules in a single transaction, existing rules are skipped and soft-deleted rules are restored
+func (a *Adapter) AddRules(rules [][]string) error {
+	return a.AddRulesContext(context.Background(), rules)
+}
+
+// AddRulesContext is like AddRules, the transaction is rolled back, if ctx is canceled
+func (a *Adapter) AddRulesContext(ctx context.Context, rules [][]string) error {
+	return a.transaction(ctx, func(tx *sql.Tx) error {
+		if err := a.exec(tx, "DELETE FROM "+a.table+"_tombstones WHERE ptype = ? AND rule = ?", rules); err != nil {
+			return err
+		}
+		return a.exec(tx, "INSERT OR IGNORE INTO "+a.table+" (ptype, rule) VALUES (?, ?)", rules)
+	})
+}
+
+// RemoveRules deletes the rules in a single transaction
+func (a *Adapter) RemoveRules(rules [][]string) error {
+	return a.RemoveRulesContext(context.Background(), rules)
+}
+
+// RemoveRulesContext is like RemoveRules, the transaction is rolled back, if ctx is canceled
+func (a *Adapter) RemoveRulesContext(ctx context.Context, rules [][]string) error {
+	return a.transaction(ctx, func(tx *sql.Tx) error {
+		return a.exec(tx, "DELETE FROM "+a.table+" WHERE ptype = ? AND rule = ?", rules)
+	})
+}
+
+// ApplyRules removes and adds the rules in a single transaction, soft-deleted rules, which are added, are restored
+func (a *Adapter) ApplyRules(ctx context.Context, added, removed [][]string) error {
+	return a.transaction(ctx, func(tx *sql.Tx) error {
+		if err := a.exec(tx, "DELETE FROM "+a.table+" WHERE ptype = ? AND rule = ?", removed); err != nil {
+			return err
+		}
+		if err := a.exec(tx, "DELETE FROM "+a.table+"_tombstones WHERE ptype = ? AND rule = ?", added); err != nil {
+			return err
+		}
+		return a.exec(tx, "INSERT OR IGNORE INTO "+a.table+" (ptype, rule) VALUES (?, ?)", added)
+	})
+}
+
+// SoftDeleteRules moves the rules to the tombstone table in a single transaction
+func (a *Adapter) SoftDeleteRules(rules [][]string) error {
+	deletedAt := time.Now().UnixNano()
+	return a.transaction(context.Background(), func(tx *sql.Tx) error {
+		if err := a.exec(tx, "DELETE FROM "+a.table+" WHERE ptype = ? AND rule = ?", rules); err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare("INSERT OR REPLACE INTO " + a.table + "_tombstones (ptype, rule, deleted_at) VALUES (?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, rule := range rules {
+			ptype, values, err := encodeRule(rule)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.Exec(ptype, values, deletedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeRules deletes soft-deleted rules from the tombstone table in a single transaction
+func (a *Adapter) PurgeRules(rules [][]string) error {
+	return a.transaction(context.Background(), func(tx *sql.Tx) error {
+		return a.exec(tx, "DELETE FROM "+a.table+"_tombstones WHERE ptype = ? AND rule = ?", rules)
+	})
+}
+
+// LoadTombstones calls fn for every soft-deleted rule
+func (a *Adapter) LoadTombstones(fn func(rule []string, deletedAt time.Time)) error {
+	rows, err := a.db.Query("SELECT ptype, rule, deleted_at FROM " + a.table + "_tombstones ORDER BY deleted_at")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ptype, values string
+		var deletedAt int64
+		if err := rows.Scan(&ptype, &values, &deletedAt); err != nil {
+			return err
+		}
+		var rule []string
+		if err := json.Unmarshal([]byte(values), &rule); err != nil {
+			return err
+		}
+		fn(append([]string{ptype}, rule...), time.Unix(0, deletedAt))
+	}
+	return rows.Err()
+}
+
+// exec executes a prepared statement with the policy type and the encoded values of every rule
+func (a *Adapter) exec(tx *sql.Tx, query string, rules [][]string) error {
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, rule := range rules {
+		ptype, values, err := encodeRule(rule)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(ptype, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetFencingToken sets the token of all following writes, 0 disables fencing.
+// A write fails with ErrFenced, if another adapter has written with a higher token.
+func (a *Adapter) SetFencingToken(token uint64) {
+	atomic.StoreUint64(&a.token, token)
+}
+
+// fence records the fencing token in the transaction, it fails, if a higher token has been recorded
+func (a *Adapter) fence(tx *sql.Tx) error {
+	token := atomic.LoadUint64(&a.token)
+	if token == 0 {
+		return nil
+	}
+	res, err := tx.Exec("INSERT INTO "+a.table+"_fence (id, token) VALUES (0, ?) ON CONFLICT (id) DO UPDATE SET token = excluded.token WHERE excluded.token >= token", int64(token))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrFenced
+	}
+	return nil
+}
+
+func (a *Adapter) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := a.fence(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// AppliedMigrations returns the IDs of the applied migrations
+func (a *Adapter) AppliedMigrations(ctx context.Context) ([]string, error) {
+	rows, err := a.db.QueryContext(ctx, "SELECT id FROM "+a.table+"_migrations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkMigration records a migration as applied
+func (a *Adapter) MarkMigration(ctx context.Context, id string) error {
+	_, err := a.db.ExecContext(ctx, "INSERT OR IGNORE INTO "+a.table+"_migrations (id) VALUES (?)", id)
+	return err
+}
@@ -0,0 +1,312 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/oarkflow/fastac/storage"
+)
+
+// rules is a model, which keeps the rules in a set
+type rules map[string][]string
+
+func (r rules) AddRule(rule []string) (bool, error) {
+	key := strings.Join(rule, ",")
+	if _, ok := r[key]; ok {
+		return false, nil
+	}
+	r[key] = rule
+	return true, nil
+}
+
+func (r rules) RangeRules(fn func(rule []string) bool) {
+	for _, rule := range r {
+		if !fn(rule) {
+			return
+		}
+	}
+}
+
+func (r rules) keys() []string {
+	keys := make([]string, 0, len(r))
+	for key := range r {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// openDB opens an in-memory database, the pool is limited to one connection, since every connection has its own database
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		t.Skip("sqlite driver not available:", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newAdapter(t *testing.T, db *sql.DB) *Adapter {
+	t.Helper()
+	a, err := NewAdapter(db, DefaultTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func load(t *testing.T, a *Adapter) []string {
+	t.Helper()
+	r := rules{}
+	if err := a.LoadPolicy(r); err != nil {
+		t.Fatal(err)
+	}
+	return r.keys()
+}
+
+var testRules = [][]string{
+	{"p", "alice", "tenant1", "data1", "read"},
+	{"p", "bob", "tenant2", "data2", "write"},
+	{"g", "alice", "admin", "tenant1"},
+	{"g", "bob", "admin", "tenant2"},
+}
+
+func TestNewAdapterTable(t *testing.T) {
+	db := openDB(t)
+	for _, table := range []string{"", "rules; DROP TABLE x", "1rules", "rules-v2"} {
+		if _, err := NewAdapter(db, table); err == nil {
+			t.Fatalf("NewAdapter(%q): nil error, want invalid table name", table)
+		}
+	}
+	if _, err := NewAdapter(db, "tenant_rules"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAdapter(db, "tenant_rules"); err != nil {
+		t.Fatalf("NewAdapter of existing table: %v", err)
+	}
+}
+
+func TestAdapterAddRemoveSave(t *testing.T) {
+	a := newAdapter(t, openDB(t))
+	if err := a.AddRules(testRules); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddRule(testRules[0]); err != nil {
+		t.Fatalf("AddRule of existing rule: %v", err)
+	}
+	want := []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2", "p,alice,tenant1,data1,read", "p,bob,tenant2,data2,write"}
+	if got := load(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy: %v, want %v", got, want)
+	}
+
+	if err := a.RemoveRule(testRules[1]); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2", "p,alice,tenant1,data1,read"}
+	if got := load(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy after RemoveRule: %v, want %v", got, want)
+	}
+
+	saved := rules{}
+	saved.AddRule([]string{"p", "carol", "tenant3", "data3", "read"})
+	if err := a.SavePolicy(saved); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"p,carol,tenant3,data3,read"}
+	if got := load(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy after SavePolicy: %v, want %v", got, want)
+	}
+
+	if err := a.AddRule([]string{"p"}); err == nil {
+		t.Fatal("AddRule without values: nil error")
+	}
+}
+
+func TestAdapterApplyRules(t *testing.T) {
+	a := newAdapter(t, openDB(t))
+	if err := a.AddRules(testRules[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ApplyRules(context.Background(), testRules[2:], testRules[:1]); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2", "p,bob,tenant2,data2,write"}
+	if got := load(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy after ApplyRules: %v, want %v", got, want)
+	}
+}
+
+func TestAdapterLoadFilteredPolicy(t *testing.T) {
+	a := newAdapter(t, openDB(t))
+	if err := a.AddRules(testRules); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filter storage.Filter
+		want   []string
+	}{
+		{storage.Filter{"p": {"", "tenant1"}, "g": {"", "", "tenant1"}}, []string{"g,alice,admin,tenant1", "p,alice,tenant1,data1,read"}},
+		{storage.Filter{"p": nil}, []string{"p,alice,tenant1,data1,read", "p,bob,tenant2,data2,write"}},
+		{storage.Filter{"p": {"bob", "tenant1"}}, []string{}},
+		{storage.Filter{}, []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2", "p,alice,tenant1,data1,read", "p,bob,tenant2,data2,write"}},
+		{nil, []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2", "p,alice,tenant1,data1,read", "p,bob,tenant2,data2,write"}},
+	}
+	for _, test := range tests {
+		r := rules{}
+		if err := a.LoadFilteredPolicy(r, test.filter); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.keys(); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("LoadFilteredPolicy(%v): %v, want %v", test.filter, got, test.want)
+		}
+		if !a.IsFiltered() {
+			t.Fatalf("IsFiltered after LoadFilteredPolicy(%v): false", test.filter)
+		}
+		load(t, a)
+		if a.IsFiltered() {
+			t.Fatal("IsFiltered after LoadPolicy: true")
+		}
+	}
+}
+
+func TestAdapterLoadFilteredPolicyError(t *testing.T) {
+	db := openDB(t)
+	a := newAdapter(t, db)
+	if _, err := db.Exec("DROP TABLE " + DefaultTable); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadFilteredPolicy(rules{}, storage.Filter{"p": {"alice"}}); err == nil {
+		t.Fatal("LoadFilteredPolicy of dropped table: nil error")
+	}
+	if a.IsFiltered() {
+		t.Fatal("IsFiltered after failed LoadFilteredPolicy: true")
+	}
+}
+
+func TestAdapterTombstones(t *testing.T) {
+	a := newAdapter(t, openDB(t))
+	if err := a.AddRules(testRules); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if err := a.SoftDeleteRules(testRules[:2]); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2"}
+	if got := load(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy after SoftDeleteRules: %v, want %v", got, want)
+	}
+
+	tombstones := func() []string {
+		deleted := []string{}
+		err := a.LoadTombstones(func(rule []string, deletedAt time.Time) {
+			if deletedAt.Before(before.Add(-time.Second)) {
+				t.Fatalf("LoadTombstones: %v deleted at %v, want after %v", rule, deletedAt, before)
+			}
+			deleted = append(deleted, strings.Join(rule, ","))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(deleted)
+		return deleted
+	}
+	want = []string{"p,alice,tenant1,data1,read", "p,bob,tenant2,data2,write"}
+	if got := tombstones(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadTombstones: %v, want %v", got, want)
+	}
+
+	if err := a.AddRule(testRules[0]); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"p,bob,tenant2,data2,write"}
+	if got := tombstones(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadTombstones after restoring a rule: %v, want %v", got, want)
+	}
+	want = []string{"g,alice,admin,tenant1", "g,bob,admin,tenant2", "p,alice,tenant1,data1,read"}
+	if got := load(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy after restoring a rule: %v, want %v", got, want)
+	}
+
+	if err := a.PurgeRules(testRules[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if got := tombstones(); len(got) != 0 {
+		t.Fatalf("LoadTombstones after PurgeRules: %v, want none", got)
+	}
+}
+
+func TestAdapterFencing(t *testing.T) {
+	db := openDB(t)
+	leader, stale := newAdapter(t, db), newAdapter(t, db)
+	stale.SetFencingToken(1)
+	if err := stale.AddRule(testRules[0]); err != nil {
+		t.Fatal(err)
+	}
+	leader.SetFencingToken(2)
+	if err := leader.AddRule(testRules[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]func() error{
+		"AddRules":        func() error { return stale.AddRules(testRules[2:]) },
+		"RemoveRules":     func() error { return stale.RemoveRules(testRules[:1]) },
+		"SavePolicy":      func() error { return stale.SavePolicy(rules{}) },
+		"SoftDeleteRules": func() error { return stale.SoftDeleteRules(testRules[:1]) },
+		"PurgeRules":      func() error { return stale.PurgeRules(testRules[:1]) },
+		"ApplyRules":      func() error { return stale.ApplyRules(context.Background(), testRules[2:], testRules[:1]) },
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrFenced) {
+			t.Fatalf("%s with lower token: %v, want %v", name, err, ErrFenced)
+		}
+	}
+	want := []string{"p,alice,tenant1,data1,read", "p,bob,tenant2,data2,write"}
+	if got := load(t, leader); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadPolicy after fenced writes: %v, want %v", got, want)
+	}
+
+	if err := leader.AddRule(testRules[2]); err != nil {
+		t.Fatalf("AddRule with equal token: %v", err)
+	}
+	stale.SetFencingToken(3)
+	if err := stale.AddRule(testRules[3]); err != nil {
+		t.Fatalf("AddRule with higher token: %v", err)
+	}
+	if err := leader.AddRule(testRules[3]); !errors.Is(err, ErrFenced) {
+		t.Fatalf("AddRule of the previous leader: %v, want %v", err, ErrFenced)
+	}
+	leader.SetFencingToken(0)
+	if err := leader.RemoveRule(testRules[3]); err != nil {
+		t.Fatalf("RemoveRule without fencing: %v", err)
+	}
+}
+
+func TestAdapterMigrations(t *testing.T) {
+	a := newAdapter(t, openDB(t))
+	for _, id := range []string{"002_rename", "001_init", "002_rename"} {
+		if err := a.MarkMigration(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := a.AppliedMigrations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"001_init", "002_rename"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("AppliedMigrations: %v, want %v", ids, want)
+	}
+}
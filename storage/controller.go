@@ -15,13 +15,27 @@
 package storage
 
 import (
+	"context"
 	"errors"
 
 	"github.com/oarkflow/fastac/api"
 	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/logger"
 	"github.com/oarkflow/fastac/model"
 )
 
+// FLUSHED is emitted by the storage controller after the queued operations have been sent to the adapter.
+// The handler receives the number of flushed operations.
+const FLUSHED = "flushed"
+
+// FLUSH_FAILED is emitted by the storage controller, if sending the queued operations to the adapter failed.
+// The handler receives the error and the rules of the failed operations, which have been dropped from the queue
+// after DefaultMaxFlushAttempts, or nil, if they stay queued.
+const FLUSH_FAILED = "flush_failed"
+
+// DefaultMaxFlushAttempts is the number of times an operation is sent to the adapter, before it is dropped from the queue
+const DefaultMaxFlushAttempts = 3
+
 type opcode int
 
 const (
@@ -40,20 +54,35 @@ type listener struct {
 }
 
 type StorageController struct {
-	autosave  bool
-	em        api.IAddRemoveListener
-	adapter   Adapter
-	q         []operation
-	wait      int
-	listeners []listener
+	*emitter.Emitter
+
+	autosave   bool
+	softDelete bool
+	em         api.IAddRemoveListener
+	adapter    Adapter
+	q          []operation
+	wait       int
+	listeners  []listener
+	logger     logger.Logger
+	// attempts counts the failed flushes of the first queued operation, which is dropped after maxAttempts
+	attempts    int
+	maxAttempts int
+	// err is the error of the last automatic flush, see Err
+	err error
+	// ctx is the context of the automatic flushes, see WithContext
+	ctx context.Context
 }
 
-func NewStorageController(emitter api.IAddRemoveListener, adapter Adapter, autosave bool) *StorageController {
+func NewStorageController(em api.IAddRemoveListener, adapter Adapter, autosave bool) *StorageController {
 	sc := &StorageController{
-		em:        emitter,
+		Emitter:   emitter.NewEmitter(false),
+		em:        em,
 		adapter:   adapter,
 		autosave:  autosave,
 		listeners: []listener{},
+		logger:    logger.Default(),
+
+		maxAttempts: DefaultMaxFlushAttempts,
 	}
 
 	sc.Enable()
@@ -91,6 +120,11 @@ func (sc *StorageController) Enable() {
 	for _, params := range listenerParams {
 		sc.addListener(params.evt, params.opc)
 	}
+
+	l := sc.em.AddListener(model.RULES_ADDED, func(arguments ...interface{}) {
+		sc.addOps(add, arguments[0].([][]string))
+	})
+	sc.listeners = append(sc.listeners, listener{model.RULES_ADDED, l})
 }
 
 func (sc *StorageController) Disable() {
@@ -100,19 +134,97 @@ func (sc *StorageController) Disable() {
 	sc.listeners = []listener{}
 }
 
+// Attach moves the listeners of an enabled controller to em, the queued operations are kept
+func (sc *StorageController) Attach(em api.IAddRemoveListener) {
+	if sc.em == em {
+		return
+	}
+	enabled := sc.Enabled()
+	sc.Disable()
+	sc.em = em
+	if enabled {
+		sc.Enable()
+	}
+}
+
 func (sc *StorageController) addOp(opc opcode, rule []string) {
-	sc.q = append(sc.q, operation{opc, rule})
+	sc.addOps(opc, [][]string{rule})
+}
+
+// SetLogger sets the logger of failed flushes
+func (sc *StorageController) SetLogger(l logger.Logger) {
+	sc.logger = logger.Or(l)
+}
+
+// SetMaxFlushAttempts sets the number of times an operation is sent to the adapter, before it is dropped from the queue,
+// 0 retries it forever (default: DefaultMaxFlushAttempts)
+func (sc *StorageController) SetMaxFlushAttempts(n int) {
+	sc.maxAttempts = n
+}
+
+// Err returns and clears the error of the last automatic flush of autosave
+func (sc *StorageController) Err() error {
+	err := sc.err
+	sc.err = nil
+	return err
+}
+
+// ResetErr clears the error of the last automatic flush, so Err only returns the error of the following changes
+func (sc *StorageController) ResetErr() {
+	sc.err = nil
+}
+
+// WithContext calls fn, the automatic flushes of autosave during the call stop with the error of ctx, if ctx is canceled
+func (sc *StorageController) WithContext(ctx context.Context, fn func()) {
+	previous := sc.ctx
+	sc.ctx = ctx
+	defer func() {
+		sc.ctx = previous
+	}()
+	fn()
+}
+
+// addOps queues multiple operations, which are flushed at once, if autosave is enabled.
+// If the flush fails, the operations stay queued and are retried with the next change or Flush, see SetMaxFlushAttempts.
+// The error is returned by Err.
+func (sc *StorageController) addOps(opc opcode, rules [][]string) {
+	for _, rule := range rules {
+		sc.q = append(sc.q, operation{opc, rule})
+	}
 	if sc.autosave {
 		sc.wait--
 		if sc.wait <= 0 {
-			err := sc.Flush()
-			if err != nil {
-				panic(err)
+			ctx := sc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if err := sc.FlushContext(ctx); err != nil {
+				sc.logger.Warn("autosave flush failed", "queued", len(sc.q), "error", err)
+				sc.err = err
 			}
 		}
 	}
 }
 
+// fail counts a failed attempt to send the first n queued operations and drops them after maxAttempts,
+// it returns true, if they have been dropped
+func (sc *StorageController) fail(n int, err error) bool {
+	sc.attempts++
+	if sc.maxAttempts <= 0 || sc.attempts < sc.maxAttempts {
+		sc.EmitEvent(FLUSH_FAILED, err, [][]string(nil))
+		return false
+	}
+	dropped := make([][]string, 0, n)
+	for _, operation := range sc.q[:n] {
+		dropped = append(dropped, operation.rule)
+	}
+	sc.q = sc.q[n:]
+	sc.attempts = 0
+	sc.logger.Error("dropping operations after failed flushes", "rules", dropped, "attempts", sc.maxAttempts, "error", err)
+	sc.EmitEvent(FLUSH_FAILED, err, dropped)
+	return true
+}
+
 func (sc *StorageController) EnableAutosave() {
 	sc.autosave = true
 }
@@ -125,63 +237,92 @@ func (sc *StorageController) AutosaveEnabled() bool {
 	return sc.autosave
 }
 
-func (sc *StorageController) flush() error {
+// SetSoftDelete enables or disables soft deletes, removed rules are soft-deleted, if the adapter is a SoftDeleteAdapter
+func (sc *StorageController) SetSoftDelete(enable bool) {
+	sc.softDelete = enable
+}
+
+// softDeleter returns the adapter, if removed rules are soft-deleted
+func (sc *StorageController) softDeleter() (SoftDeleteAdapter, bool) {
+	if !sc.softDelete {
+		return nil, false
+	}
+	sa, ok := sc.adapter.(SoftDeleteAdapter)
+	return sa, ok
+}
+
+// flush sends the queued operations one by one, the operations following dropped operations are still sent,
+// but the error of the dropped operations is returned
+func (sc *StorageController) flush(ctx context.Context) (dropErr error) {
 	for len(sc.q) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		operation := sc.q[0]
-		sc.q = sc.q[1:]
 		if err := sc.run(operation.opc, operation.rule); err != nil {
+			if sc.fail(1, err) {
+				dropErr = err
+				continue
+			}
 			return err
 		}
+		sc.q = sc.q[1:]
+		sc.attempts = 0
 	}
-	return nil
+	return dropErr
 }
 
-func (sc *StorageController) batchFlush() error {
-
-	rules := [][]string{}
-
-	var currentOpc opcode
-	if len(sc.q) > 0 {
-		currentOpc = sc.q[0].opc
-	}
-
+// batchFlush sends every run of operations with the same opcode as one batch,
+// a batch is removed from the queue after it has been sent or dropped
+func (sc *StorageController) batchFlush(ctx context.Context) (dropErr error) {
 	for len(sc.q) > 0 {
-
-		operation := sc.q[0]
-		sc.q = sc.q[1:]
-
-		if currentOpc == operation.opc {
-			rules = append(rules, operation.rule)
-		} else {
-			if err := sc.runBatch(currentOpc, rules); err != nil {
-				return err
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opc := sc.q[0].opc
+		rules := [][]string{}
+		for _, operation := range sc.q {
+			if operation.opc != opc {
+				break
 			}
-			currentOpc = operation.opc
-			rules = [][]string{operation.rule}
+			rules = append(rules, operation.rule)
 		}
-	}
-
-	if len(rules) > 0 {
-		if err := sc.runBatch(currentOpc, rules); err != nil {
+		if err := sc.runBatch(ctx, opc, rules); err != nil {
+			if ctx.Err() == nil && sc.fail(len(rules), err) {
+				dropErr = err
+				continue
+			}
 			return err
 		}
+		sc.q = sc.q[len(rules):]
+		sc.attempts = 0
 	}
-	return nil
+	return dropErr
 }
 
 func (sc *StorageController) Flush() error {
+	return sc.FlushContext(context.Background())
+}
+
+// FlushContext sends the queued operations to the adapter and stops with the error of ctx, if ctx is canceled.
+// Operations, which have not been sent, stay queued.
+func (sc *StorageController) FlushContext(ctx context.Context) error {
 	var err error
+	n := len(sc.q)
 
 	switch sc.adapter.(type) {
-	case BatchAdapter:
-		err = sc.batchFlush()
+	case ContextAdapter, BatchAdapter:
+		err = sc.batchFlush(ctx)
 	case SimpleAdapter:
-		err = sc.flush()
+		err = sc.flush(ctx)
 	default:
 		err = errors.New("invalid adapter")
 	}
 
 	sc.wait = 0
+	if err == nil {
+		sc.EmitEvent(FLUSHED, n)
+	}
 	return err
 }
 
@@ -193,12 +334,26 @@ func (sc *StorageController) run(opc opcode, rule []string) error {
 	case add:
 		err = adapter.AddRule(rule)
 	case remove:
-		err = adapter.RemoveRule(rule)
+		if sa, ok := sc.softDeleter(); ok {
+			err = sa.SoftDeleteRules([][]string{rule})
+		} else {
+			err = adapter.RemoveRule(rule)
+		}
 	}
 	return err
 }
 
-func (sc *StorageController) runBatch(opc opcode, rules [][]string) error {
+func (sc *StorageController) runBatch(ctx context.Context, opc opcode, rules [][]string) error {
+	if sa, ok := sc.softDeleter(); ok && opc == remove {
+		return sa.SoftDeleteRules(rules)
+	}
+	if adapter, ok := sc.adapter.(ContextAdapter); ok {
+		if opc == add {
+			return adapter.AddRulesContext(ctx, rules)
+		}
+		return adapter.RemoveRulesContext(ctx, rules)
+	}
+
 	adapter := sc.adapter.(BatchAdapter)
 	var err error
 
@@ -211,6 +366,53 @@ func (sc *StorageController) runBatch(opc opcode, rules [][]string) error {
 	return err
 }
 
+// Apply sends added and removed rules to the adapter immediately, bypassing the queue.
+// TransactionalAdapters apply them atomically, unless removed rules are soft-deleted.
+// Other adapters receive the removed rules first, if the added rules fail,
+// the rules added before the failure are removed and the removed rules are added again.
+func (sc *StorageController) Apply(ctx context.Context, added, removed [][]string) error {
+	if _, soft := sc.softDeleter(); !soft {
+		if adapter, ok := sc.adapter.(TransactionalAdapter); ok {
+			return adapter.ApplyRules(ctx, added, removed)
+		}
+	}
+	if len(removed) > 0 {
+		if err := sc.write(ctx, remove, removed); err != nil {
+			return err
+		}
+	}
+	if len(added) > 0 {
+		if err := sc.write(ctx, add, added); err != nil {
+			if undoErr := sc.write(context.Background(), remove, added); undoErr != nil {
+				sc.logger.Error("removing added rules failed", "rules", added, "error", undoErr)
+			}
+			if len(removed) > 0 {
+				if undoErr := sc.write(context.Background(), add, removed); undoErr != nil {
+					sc.logger.Error("restoring removed rules failed", "rules", removed, "error", undoErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// write sends the rules of an operation to the adapter in one batch, if possible
+func (sc *StorageController) write(ctx context.Context, opc opcode, rules [][]string) error {
+	switch sc.adapter.(type) {
+	case ContextAdapter, BatchAdapter:
+		return sc.runBatch(ctx, opc, rules)
+	case SimpleAdapter:
+		for _, rule := range rules {
+			if err := sc.run(opc, rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New("invalid adapter")
+}
+
 func (sc *StorageController) AddWait(i int) {
 	sc.wait += i
 }
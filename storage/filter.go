@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/oarkflow/fastac/api"
+)
+
+// Filter selects the rules loaded by LoadFilteredPolicy.
+// It maps policy types to the values of their rules, an empty value matches every value.
+// Rules of policy types, which are missing in the filter, are not loaded, an empty filter selects all rules.
+//
+//	// rules of tenant1, the tenant is the second value of p and the third value of g
+//	storage.Filter{"p": {"", "tenant1"}, "g": {"", "", "tenant1"}}
+//
+//	// all rules of p and g
+//	storage.Filter{"p": nil, "g": nil}
+type Filter map[string][]string
+
+// PTypes returns the sorted policy types of the filter
+func (f Filter) PTypes() []string {
+	ptypes := make([]string, 0, len(f))
+	for ptype := range f {
+		ptypes = append(ptypes, ptype)
+	}
+	sort.Strings(ptypes)
+	return ptypes
+}
+
+// Match returns true, if the rule is selected by the filter
+func (f Filter) Match(rule []string) bool {
+	if len(rule) == 0 {
+		return false
+	}
+	if len(f) == 0 {
+		return true
+	}
+	values, ok := f[rule[0]]
+	if !ok {
+		return false
+	}
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		if i+1 >= len(rule) || rule[i+1] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Target returns a model, which adds only the rules matching the filter to model
+func (f Filter) Target(model api.IAddRuleBool) api.IAddRuleBool {
+	return &filteredModel{model: model, filter: f}
+}
+
+type filteredModel struct {
+	model  api.IAddRuleBool
+	filter Filter
+}
+
+func (m *filteredModel) AddRule(rule []string) (bool, error) {
+	if !m.filter.Match(rule) {
+		return false, nil
+	}
+	return m.model.AddRule(rule)
+}
+
+func (m *filteredModel) AddRules(rules [][]string) ([][]string, error) {
+	matched := make([][]string, 0, len(rules))
+	for _, rule := range rules {
+		if m.filter.Match(rule) {
+			matched = append(matched, rule)
+		}
+	}
+	if bulk, ok := m.model.(api.IAddRulesBulk); ok {
+		return bulk.AddRules(matched)
+	}
+	added := [][]string{}
+	for _, rule := range matched {
+		ok, err := m.model.AddRule(rule)
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added = append(added, rule)
+		}
+	}
+	return added, nil
+}
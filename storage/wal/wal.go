@@ -0,0 +1,185 @@
+// Package wal implements a write-ahead log for rule mutations.
+//
+// Every mutation is appended to the log before it is applied to the model,
+// so deployments without a storage adapter can restore their rules after a crash by replaying the log.
+package wal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/oarkflow/fastac/api"
+)
+
+// Operations of log records
+const (
+	ADD    = "+"
+	REMOVE = "-"
+)
+
+// Target receives the replayed mutations, e.g. a model
+type Target interface {
+	api.IAddRuleBool
+	api.IRemoveRuleBool
+}
+
+// WAL is an append-only log of rule mutations.
+// Records are stored as JSON arrays, one per line: the operation followed by the rule.
+//
+//	["+","p","alice","data1","read"]
+//	["-","g","alice","admin"]
+type WAL struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	sync  bool
+}
+
+// Open opens or creates the log at path.
+// If sync is enabled, every append is flushed to disk before it returns.
+func Open(path string, sync bool) (*WAL, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL{path: path, file: file, sync: sync}, nil
+}
+
+// Path returns the path of the log file
+func (w *WAL) Path() string {
+	return w.path
+}
+
+// Append writes one record per rule
+func (w *WAL) Append(op string, rules ...[]string) error {
+	if op != ADD && op != REMOVE {
+		return fmt.Errorf("error: invalid log operation %s", op)
+	}
+	buf := []byte{}
+	for _, rule := range rules {
+		line, err := json.Marshal(append([]string{op}, rule...))
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return fmt.Errorf("error: log %s is closed", w.path)
+	}
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	if w.sync {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+// Replay applies all records of the log to target and returns the number of records.
+// An incomplete last record, left by a crash during an append, is discarded and truncated.
+func (w *WAL) Replay(target Target) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return 0, fmt.Errorf("error: log %s is closed", w.path)
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(w.file)
+	n, offset := 0, int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return n, w.file.Truncate(offset)
+			}
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		record := []string{}
+		if err := json.Unmarshal(line, &record); err != nil || len(record) < 2 {
+			return n, fmt.Errorf("error: invalid log record at offset %d of %s", offset, w.path)
+		}
+		switch record[0] {
+		case ADD:
+			_, err = target.AddRule(record[1:])
+		case REMOVE:
+			_, err = target.RemoveRule(record[1:])
+		default:
+			err = fmt.Errorf("error: invalid log operation %s", record[0])
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+		offset += int64(len(line))
+	}
+}
+
+// Compact replaces the log by the rules of snapshot, e.g. a model.Snapshot,
+// so replaying it does not repeat the whole history of mutations
+func (w *WAL) Compact(snapshot api.IRangeRules) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return fmt.Errorf("error: log %s is closed", w.path)
+	}
+
+	tmp := w.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	snapshot.RangeRules(func(rule []string) bool {
+		var line []byte
+		if line, err = json.Marshal(append([]string{ADD}, rule...)); err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		return err == nil
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, w.path); err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file, err = os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0600)
+	return err
+}
+
+// Close closes the log file
+func (w *WAL) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,160 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// rules is a Target, which keeps the rules in a set
+type rules map[string][]string
+
+func (r rules) AddRule(rule []string) (bool, error) {
+	key := strings.Join(rule, ",")
+	if _, ok := r[key]; ok {
+		return false, nil
+	}
+	r[key] = rule
+	return true, nil
+}
+
+func (r rules) RemoveRule(rule []string) (bool, error) {
+	key := strings.Join(rule, ",")
+	if _, ok := r[key]; !ok {
+		return false, nil
+	}
+	delete(r, key)
+	return true, nil
+}
+
+func (r rules) RangeRules(fn func(rule []string) bool) {
+	for _, rule := range r {
+		if !fn(rule) {
+			return
+		}
+	}
+}
+
+func (r rules) keys() []string {
+	keys := make([]string, 0, len(r))
+	for key := range r {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func openLog(t *testing.T, path string) *WAL {
+	t.Helper()
+	w, err := Open(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func replay(t *testing.T, w *WAL, want int) rules {
+	t.Helper()
+	target := rules{}
+	n, err := w.Replay(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Fatalf("replayed %d records, want %d", n, want)
+	}
+	return target
+}
+
+func TestAppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.wal")
+	w := openLog(t, path)
+	if err := w.Append(ADD, []string{"p", "alice", "data1", "read"}, []string{"g", "alice", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Append(REMOVE, []string{"g", "alice", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Append("*", []string{"p", "bob", "data1", "read"}); err == nil {
+		t.Fatal("Append accepted an invalid operation")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	target := replay(t, openLog(t, path), 3)
+	if keys := target.keys(); !reflect.DeepEqual(keys, []string{"p,alice,data1,read"}) {
+		t.Fatalf("replayed rules %v", keys)
+	}
+}
+
+func TestReplayTruncatesTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.wal")
+	w := openLog(t, path)
+	if err := w.Append(ADD, []string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	complete, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a crash during the append of the second record
+	if err := os.WriteFile(path, append(complete, `["+","p","bob"`...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w = openLog(t, path)
+	target := replay(t, w, 1)
+	if keys := target.keys(); !reflect.DeepEqual(keys, []string{"p,alice,data1,read"}) {
+		t.Fatalf("replayed rules %v", keys)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(complete) {
+		t.Fatalf("torn record has not been truncated: %q", data)
+	}
+
+	// records appended after the truncation are replayed
+	if err := w.Append(ADD, []string{"p", "bob", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	replay(t, w, 2)
+}
+
+func TestCompactReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.wal")
+	w := openLog(t, path)
+	for i := 0; i < 3; i++ {
+		if err := w.Append(ADD, []string{"p", "alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Append(REMOVE, []string{"p", "alice", "data1", "read"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Append(ADD, []string{"p", "bob", "data2", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := replay(t, w, 7)
+
+	if err := w.Compact(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file of the compaction remains: %v", err)
+	}
+	if err := w.Append(ADD, []string{"g", "bob", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	target := replay(t, w, 2)
+	if keys := target.keys(); !reflect.DeepEqual(keys, []string{"g,bob,admin", "p,bob,data2,write"}) {
+		t.Fatalf("replayed rules %v", keys)
+	}
+}
@@ -20,7 +20,40 @@ const (
 	ERR_MATCHER_NOT_FOUND    = "error: matcher %s not found"
 	ERR_POLICY_NOT_FOUND     = "error: policy %s not found"
 	ERR_RM_NOT_FOUND         = "error: role manager %s not found"
+	ERR_RM_NOT_RENEWABLE     = "error: role manager %s cannot be copied, it does not implement rbac.IRenewableRoleManager"
 	ERR_REQUESTDEF_NOT_FOUND = "error: request definition %s not found"
 	ERR_EFFECTOR_NOT_FOUND   = "error: effect definition %s not found"
 	ERR_INVALID_MODEL        = "invalid model"
+	ERR_UNKNOWN_TERM         = "error: value %s of %s is not part of the vocabulary"
+	ERR_UNKNOWN_EFFECT       = "error: effect %s of rule %s is not part of the effect vocabulary"
+	ERR_INVALID_EFFECT       = "error: effect %s must map to allow, deny or indeterminate"
+	ERR_BUNDLE_FORMAT        = "error: unsupported bundle format %s version %d"
+	ERR_BUNDLE_DIGEST        = "error: bundle digest %s does not match its content"
+	ERR_PROFILE_VIOLATION    = "error: matcher %s uses %s, which is not allowed by the expression profile"
+	ERR_CACHE_UNSUPPORTED    = "error: %s is not supported by the permission cache"
+	ERR_SQL_UNSUPPORTED      = "error: %s is not supported in SQL predicates"
+	ERR_SQL_NO_COLUMN        = "error: no column mapped to %s"
+	ERR_SQL_IDENTIFIER       = "error: %s is not a valid SQL identifier"
+	ERR_READ_ONLY            = "error: enforcer is read-only"
+	ERR_PURPOSE_REQUIRED     = "error: request has no purpose"
+	ERR_INVALID_CACHE        = "error: cache size and ttl must not be negative"
+	ERR_REQUEST_ARG_MISSING  = "error: request has no value for %s"
+	ERR_MATCHER_CACHE_SIZE   = "error: matcher cache size must be positive"
+	ERR_NO_CANARY            = "error: no canary is running"
+	ERR_NO_CANDIDATE         = "error: canary has no candidate enforcer"
+	ERR_TX_DONE              = "error: transaction has already been committed or rolled back"
+	ERR_OTHER_ENV            = "error: rule %s does not apply to environment %s"
+	ERR_FILTER_UNSUPPORTED   = "error: adapter does not support filtered loading"
+	ERR_FILTERED_SAVE        = "error: policy has been loaded filtered and cannot be saved"
+	ERR_NO_ENCODER           = "error: request encoder must not be nil"
+	ERR_NO_WATCH_FILES       = "error: enforcer has neither a model file nor a policy file to watch"
+	ERR_DEFAULT_EFFECT       = "error: default effect %d must be allow or deny"
+	ERR_MERGE_CONFLICT       = "error: definition %s.%s conflicts"
+	ERR_WATCH_UNSYNCED       = "error: watching files requires a SyncedEnforcer"
+	ERR_NO_POLICY_MATCHER    = "error: no matcher uses policy definition %s"
+	ERR_POLICY_MISMATCH      = "error: matcher uses policy definition %s instead of %s"
+	ERR_CANARY_PERCENT       = "error: canary percentage %v must be between 0 and 100"
+	ERR_CYCLIC_REQUEST       = "error: request value contains a cycle"
+	ERR_TYPED_ARITY          = "error: request definition %s has %d arguments, typed requests have 3"
+	ERR_RULE_COLUMNS         = "error: rule %s has %d values, policy definition %s requires %d"
 )
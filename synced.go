@@ -0,0 +1,562 @@
+package fastac
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac/api"
+	"github.com/oarkflow/fastac/emitter"
+	"github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/types"
+	"github.com/oarkflow/fastac/storage"
+)
+
+// SyncedEnforcer is a thread-safe Enforcer, which guards rule changes with a write lock and enforcement with a read lock,
+// so concurrent requests are decided in parallel, while AddRule, LoadPolicy and the like wait for them.
+// The methods, which are not overridden, are called on the embedded Enforcer without locking.
+// Hooks, matcher functions and the like must not call the SyncedEnforcer, otherwise they may deadlock.
+type SyncedEnforcer struct {
+	*Enforcer
+	mutex sync.RWMutex
+}
+
+// NewSyncedEnforcer creates a new thread-safe Enforcer with the arguments of NewEnforcer
+//
+//	e, _ := NewSyncedEnforcer("model.conf", adapter, OptionAutosave(true))
+//	go e.AddRule([]string{"p", "alice", "data1", "read"})
+//	e.Enforce("alice", "data1", "read")
+func NewSyncedEnforcer(model interface{}, adapter interface{}, options ...Option) (*SyncedEnforcer, error) {
+	s := &SyncedEnforcer{}
+	e, err := NewEnforcer(model, adapter, append([]Option{s.guard}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	s.Enforcer = e
+	return s, nil
+}
+
+// guard sets the write lock of the SyncedEnforcer as lock of the enforcer, before the other options are applied
+func (e *SyncedEnforcer) guard(enforcer *Enforcer) error {
+	enforcer.lock = &e.mutex
+	return nil
+}
+
+func (e *SyncedEnforcer) SetOption(option Option) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.SetOption(option)
+}
+
+func (e *SyncedEnforcer) SetModel(m model.IModel) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.SetModel(m)
+}
+
+func (e *SyncedEnforcer) SetAdapter(adapter storage.Adapter) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.SetAdapter(adapter)
+}
+
+func (e *SyncedEnforcer) AddRule(rule []string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.AddRule(rule)
+}
+
+func (e *SyncedEnforcer) AddRuleCtx(ctx context.Context, rule []string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.AddRuleCtx(ctx, rule)
+}
+
+func (e *SyncedEnforcer) AddRules(rules [][]string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.AddRules(rules)
+}
+
+func (e *SyncedEnforcer) RemoveRule(rule []string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemoveRule(rule)
+}
+
+func (e *SyncedEnforcer) RemoveRuleCtx(ctx context.Context, rule []string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemoveRuleCtx(ctx, rule)
+}
+
+func (e *SyncedEnforcer) RemoveRules(rules [][]string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemoveRules(rules)
+}
+
+func (e *SyncedEnforcer) ApplyReplicated(removed, added [][]string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.ApplyReplicated(removed, added)
+}
+
+func (e *SyncedEnforcer) AddModelListener(event emitter.EventType, handler emitter.HandleFunc) *ModelListener {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.AddModelListener(event, handler)
+}
+
+func (e *SyncedEnforcer) RemoveModelListener(l *ModelListener) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	e.Enforcer.RemoveModelListener(l)
+}
+
+func (e *SyncedEnforcer) AssignResourceRole(user, role, resource string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.AssignResourceRole(user, role, resource)
+}
+
+func (e *SyncedEnforcer) RevokeResourceRole(user, role, resource string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RevokeResourceRole(user, role, resource)
+}
+
+func (e *SyncedEnforcer) HasResourceRole(user, role, resource string) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.HasResourceRole(user, role, resource)
+}
+
+func (e *SyncedEnforcer) GetResourceRoles(user, resource string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.GetResourceRoles(user, resource)
+}
+
+func (e *SyncedEnforcer) GetResourceUsers(role, resource string) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.GetResourceUsers(role, resource)
+}
+
+func (e *SyncedEnforcer) GrantImpersonation(actor, subject string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.GrantImpersonation(actor, subject)
+}
+
+func (e *SyncedEnforcer) RevokeImpersonation(actor, subject string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RevokeImpersonation(actor, subject)
+}
+
+func (e *SyncedEnforcer) CanImpersonate(actor, subject string) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.CanImpersonate(actor, subject)
+}
+
+func (e *SyncedEnforcer) AddDynamicGroup(name, expr string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.AddDynamicGroup(name, expr)
+}
+
+func (e *SyncedEnforcer) RemoveDynamicGroup(name string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemoveDynamicGroup(name)
+}
+
+func (e *SyncedEnforcer) GetDynamicGroups(sub interface{}) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.GetDynamicGroups(sub)
+}
+
+func (e *SyncedEnforcer) InvalidateDynamicGroups(subs ...string) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	e.Enforcer.InvalidateDynamicGroups(subs...)
+}
+
+func (e *SyncedEnforcer) Migrate(ctx context.Context) ([]string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.Migrate(ctx)
+}
+
+func (e *SyncedEnforcer) CompactWAL() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.CompactWAL()
+}
+
+func (e *SyncedEnforcer) SetReadOnly(readOnly bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.SetReadOnly(readOnly)
+}
+
+func (e *SyncedEnforcer) AddDecisionHook(name string, fn DecisionHook) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.AddDecisionHook(name, fn)
+}
+
+func (e *SyncedEnforcer) RemoveDecisionHook(name string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemoveDecisionHook(name)
+}
+
+func (e *SyncedEnforcer) AddEnforceHook(name string, fn EnforceHook) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.AddEnforceHook(name, fn)
+}
+
+func (e *SyncedEnforcer) RemoveEnforceHook(name string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemoveEnforceHook(name)
+}
+
+func (e *SyncedEnforcer) AddPreprocessor(name string, fn PreprocessFunc) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.AddPreprocessor(name, fn)
+}
+
+func (e *SyncedEnforcer) RemovePreprocessor(name string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RemovePreprocessor(name)
+}
+
+func (e *SyncedEnforcer) SetShadow(candidate *Enforcer, fn DiscrepancyFunc) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.SetShadow(candidate, fn)
+}
+
+// WithActor returns a mutator, which stamps the rules it changes with actor and takes the write lock for every change
+func (e *SyncedEnforcer) WithActor(actor string) *StampedMutator {
+	mutator := e.Enforcer.WithActor(actor)
+	mutator.lock = &e.mutex
+	return mutator
+}
+
+func (e *SyncedEnforcer) RestoreRule(rule []string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.RestoreRule(rule)
+}
+
+func (e *SyncedEnforcer) PurgeTombstones(olderThan time.Duration) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.PurgeTombstones(olderThan)
+}
+
+func (e *SyncedEnforcer) StartCanary(candidate *Enforcer, percent float64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.StartCanary(candidate, percent)
+}
+
+func (e *SyncedEnforcer) SetCanaryPercent(percent float64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.SetCanaryPercent(percent)
+}
+
+func (e *SyncedEnforcer) PromoteCanary() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.PromoteCanary()
+}
+
+func (e *SyncedEnforcer) AbortCanary() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.Enforcer.AbortCanary()
+}
+
+// BeginTx starts a transaction, whose commit waits for the write lock
+func (e *SyncedEnforcer) BeginTx() *Transaction {
+	tx := e.Enforcer.BeginTx()
+	tx.lock = &e.mutex
+	return tx
+}
+
+func (e *SyncedEnforcer) HasRule(rule []string) bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.HasRule(rule)
+}
+
+func (e *SyncedEnforcer) GetRuleByHash(key string) ([]string, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.GetRuleByHash(key)
+}
+
+func (e *SyncedEnforcer) HasLinks(key string, pairs [][2]string, domain ...string) ([]bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.HasLinks(key, pairs, domain...)
+}
+
+func (e *SyncedEnforcer) GetExpandedPolicy(key string) ([][]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.GetExpandedPolicy(key)
+}
+
+func (e *SyncedEnforcer) PurposesFor(categoryArg, category string) (map[string][][]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.PurposesFor(categoryArg, category)
+}
+
+// SaveBundle copies the rules under the read lock and writes the bundle after releasing it
+func (e *SyncedEnforcer) SaveBundle(w io.Writer, metadata map[string]string) error {
+	e.mutex.RLock()
+	b := NewBundle(e.Enforcer, metadata)
+	e.mutex.RUnlock()
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func (e *SyncedEnforcer) GetAllowedObjects(sub, act, objectPattern string, page ...Page) ([]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.GetAllowedObjects(sub, act, objectPattern, page...)
+}
+
+func (e *SyncedEnforcer) ComputeMatrix(subjects, objects, actions []string) (*Matrix, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.ComputeMatrix(subjects, objects, actions)
+}
+
+func (e *SyncedEnforcer) Impact(rule []string) (*ImpactReport, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.Impact(rule)
+}
+
+func (e *SyncedEnforcer) LoadPolicy() error {
+	return e.LoadPolicyCtx(context.Background())
+}
+
+func (e *SyncedEnforcer) LoadPolicyCtx(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.LoadPolicyCtx(ctx)
+}
+
+// ReloadPolicy applies the differences between the adapter and the model, requests wait until all of them are applied
+func (e *SyncedEnforcer) ReloadPolicy() error {
+	return e.ReloadPolicyCtx(context.Background())
+}
+
+func (e *SyncedEnforcer) ReloadPolicyCtx(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.ReloadPolicyCtx(ctx)
+}
+
+// Clone copies the enforcer, rule changes wait until it is copied
+func (e *SyncedEnforcer) Clone() (*Enforcer, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.Clone()
+}
+
+// Fork creates a copy-on-write fork, the fork itself is not synchronized
+func (e *SyncedEnforcer) Fork() *Enforcer {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.Fork()
+}
+
+func (e *SyncedEnforcer) LoadFilteredPolicy(filter storage.Filter) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.LoadFilteredPolicy(filter)
+}
+
+// SavePolicy stores a snapshot of all rules, rule changes wait until it is stored
+func (e *SyncedEnforcer) SavePolicy() error {
+	return e.SavePolicyCtx(context.Background())
+}
+
+func (e *SyncedEnforcer) SavePolicyCtx(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.SavePolicyCtx(ctx)
+}
+
+func (e *SyncedEnforcer) SaveSnapshot() (uint64, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.SaveSnapshot()
+}
+
+func (e *SyncedEnforcer) Flush() error {
+	return e.FlushCtx(context.Background())
+}
+
+func (e *SyncedEnforcer) FlushCtx(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.Enforcer.FlushCtx(ctx)
+}
+
+func (e *SyncedEnforcer) Enforce(params ...interface{}) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.Enforce(params...)
+}
+
+func (e *SyncedEnforcer) EnforceCtx(ctx context.Context, params ...interface{}) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceCtx(ctx, params...)
+}
+
+func (e *SyncedEnforcer) EnforceWithKeys(rKey, pKey, eKey, mKey string, params ...interface{}) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceWithKeys(rKey, pKey, eKey, mKey, params...)
+}
+
+func (e *SyncedEnforcer) EnforceEx(params ...interface{}) (bool, []string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceEx(params...)
+}
+
+func (e *SyncedEnforcer) EnforceWithEffect(params ...interface{}) (types.Effect, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceWithEffect(params...)
+}
+
+func (e *SyncedEnforcer) EnforceWithMatcher(expr string, rvals ...interface{}) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceWithMatcher(expr, rvals...)
+}
+
+func (e *SyncedEnforcer) BatchEnforce(requests [][]interface{}, options ...ContextOption) ([]bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.BatchEnforce(requests, options...)
+}
+
+func (e *SyncedEnforcer) BatchEnforceWithContext(ctx *Context, requests [][]interface{}) ([]bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.BatchEnforceWithContext(ctx, requests)
+}
+
+func (e *SyncedEnforcer) EnforceWithContext(ctx *Context, rvals ...interface{}) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceWithContext(ctx, rvals...)
+}
+
+func (e *SyncedEnforcer) EnforceExWithContext(ctx *Context, rvals ...interface{}) (bool, []string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceExWithContext(ctx, rvals...)
+}
+
+func (e *SyncedEnforcer) EnforceDecision(params ...interface{}) *Decision {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceDecision(params...)
+}
+
+func (e *SyncedEnforcer) Decide(params ...interface{}) api.Decision {
+	return e.EnforceDecision(params...)
+}
+
+func (e *SyncedEnforcer) EnforceDecisionWithContext(ctx *Context, rvals ...interface{}) *Decision {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceDecisionWithContext(ctx, rvals...)
+}
+
+func (e *SyncedEnforcer) EnforceAnonymous(params ...interface{}) (bool, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceAnonymous(params...)
+}
+
+func (e *SyncedEnforcer) EnforceAs(actor, subject string, params ...interface{}) (bool, Leg, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.EnforceAs(actor, subject, params...)
+}
+
+func (e *SyncedEnforcer) Filter(params ...interface{}) ([][]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.Filter(params...)
+}
+
+func (e *SyncedEnforcer) FilterWithContext(ctx *Context, rvals ...interface{}) ([][]string, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.FilterWithContext(ctx, rvals...)
+}
+
+func (e *SyncedEnforcer) FilterSources(params ...interface{}) ([]SourcedRule, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.Enforcer.FilterSources(params...)
+}
+
+// RangeMatches calls fn for every rule, which matches the request, until fn returns false.
+// The matches are collected under the read lock and passed to fn after it is released, so fn may change the policy.
+// This holds for every context, e.g. with WithContext, SetVariable or SetFunction.
+func (e *SyncedEnforcer) RangeMatches(params []interface{}, fn func(rule []string) bool) error {
+	e.mutex.RLock()
+	ctx, rvals, err := e.splitParams(params...)
+	e.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return e.RangeMatchesWithContext(ctx, rvals, fn)
+}
+
+// RangeMatchesWithContext is like RangeMatches, fn is called after the read lock is released
+func (e *SyncedEnforcer) RangeMatchesWithContext(ctx *Context, rvals []interface{}, fn func(rule []string) bool) error {
+	matches := [][]string{}
+	e.mutex.RLock()
+	err := e.Enforcer.RangeMatchesWithContext(ctx, rvals, func(rule []string) bool {
+		matches = append(matches, rule)
+		return true
+	})
+	e.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	for _, rule := range matches {
+		if !fn(rule) {
+			break
+		}
+	}
+	return nil
+}
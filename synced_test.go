@@ -0,0 +1,161 @@
+package fastac
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	m "github.com/oarkflow/fastac/model"
+)
+
+func TestSyncedRangeMatchesChangesPolicy(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewSyncedEnforcer(model, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- e.RangeMatches([]interface{}{"alice", "data1", "read"}, func(rule []string) bool {
+			_, err := e.RemoveRule(rule)
+			return err == nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("changing the policy from fn deadlocked")
+	}
+	if e.HasRule([]string{"p", "alice", "data1", "read"}) {
+		t.Fatal("matching rule has not been removed")
+	}
+}
+
+func TestSyncedRangeMatchesWithContextChangesPolicy(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewSyncedEnforcer(model, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		params := []interface{}{WithContext(context.Background()), "alice", "data1", "read"}
+		done <- e.RangeMatches(params, func(rule []string) bool {
+			_, err := e.RemoveRule(rule)
+			return err == nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("changing the policy from fn deadlocked")
+	}
+	if e.HasRule([]string{"p", "alice", "data1", "read"}) {
+		t.Fatal("matching rule has not been removed")
+	}
+}
+
+// TestSyncedRace changes the enforcer while it is read, run it with -race
+func TestSyncedRace(t *testing.T) {
+	e := testSyncedEnforcer(t)
+	if err := e.AddRules([][]string{{"p", "reader", "data1", "read"}, {"g", "alice", "reader"}}); err != nil {
+		t.Fatal(err)
+	}
+	candidate := testEnforcer(t, nil)
+
+	const n = 100
+	var wg sync.WaitGroup
+	run := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				fn()
+			}
+		}()
+	}
+	run(func() {
+		e.AddPreprocessor("lower", NormalizeArg("act", strings.ToLower))
+		e.RemovePreprocessor("lower")
+	})
+	run(func() {
+		e.SetShadow(candidate, func(d Discrepancy) {})
+		e.SetShadow(nil, nil)
+	})
+	run(func() {
+		if _, err := e.AddRule([]string{"p", "bob", "data2", "write"}); err != nil {
+			t.Error(err)
+		}
+		if _, err := e.RemoveRule([]string{"p", "bob", "data2", "write"}); err != nil {
+			t.Error(err)
+		}
+		if _, err := e.AddRule([]string{"g", "bob", "reader"}); err != nil {
+			t.Error(err)
+		}
+		if _, err := e.RemoveRule([]string{"g", "bob", "reader"}); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func() {
+		if _, err := e.Enforce("alice", "data1", "read"); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func() {
+		if _, err := e.HasLinks("g", [][2]string{{"alice", "reader"}, {"bob", "reader"}}); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func() {
+		if _, err := e.GetExpandedPolicy("p"); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func() {
+		if _, err := e.PurposesFor("obj", "data1"); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func() {
+		if err := e.SaveBundle(io.Discard, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	wg.Wait()
+}
+
+func testSyncedEnforcer(t *testing.T) *SyncedEnforcer {
+	t.Helper()
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewSyncedEnforcer(model, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
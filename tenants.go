@@ -0,0 +1,263 @@
+package fastac
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac/emitter"
+	m "github.com/oarkflow/fastac/model"
+)
+
+// TenantQuota limits the resources of a tenant, zero values disable a limit
+type TenantQuota struct {
+	// MaxRules is the maximum number of policy and role rules
+	MaxRules int
+	// MaxMemory is the maximum estimated size of all rules in bytes
+	MaxMemory int64
+	// MaxEvalTime is the maximum duration of a single enforcement,
+	// slower enforcements are denied with a *QuotaError
+	MaxEvalTime time.Duration
+}
+
+// TenantUsage contains the accounting of a tenant
+type TenantUsage struct {
+	Tenant string
+	Rules  int
+	// Memory is the estimated size of all rules in bytes
+	Memory      int64
+	Evaluations uint64
+	// EvalTime is the total duration of all enforcements
+	EvalTime time.Duration
+	// Violations is the number of rejected rule changes and enforcements
+	Violations uint64
+}
+
+// QuotaError is returned, if a tenant exceeds its quota
+type QuotaError struct {
+	Tenant string
+	Quota  string
+	Usage  int64
+	Max    int64
+}
+
+func (err *QuotaError) Error() string {
+	return fmt.Sprintf("error: tenant %s exceeds %s (%d > %d)", err.Tenant, err.Quota, err.Usage, err.Max)
+}
+
+// Tenants accounts the resources of the enforcers of multiple tenants and enforces their quotas.
+// Rule changes through AddRule and AddRules are rejected with a *QuotaError, if they would exceed the quota,
+// LoadPolicy returns a *QuotaError after loading, if the loaded rules exceed it.
+type Tenants struct {
+	mutex   sync.RWMutex
+	quota   TenantQuota
+	tenants map[string]*tenant
+}
+
+type tenant struct {
+	e         *Enforcer
+	mutex     sync.Mutex
+	quota     TenantQuota
+	usage     TenantUsage
+	listeners []modelListener
+}
+
+// NewTenants creates an empty registry, quota is the default quota of tenants
+//
+//	tenants := NewTenants(TenantQuota{MaxRules: 10000, MaxEvalTime: 10 * time.Millisecond})
+//	tenants.Add("acme", e)
+//	tenants.Enforce("acme", "alice", "data1", "read")
+func NewTenants(quota TenantQuota) *Tenants {
+	return &Tenants{
+		quota:   quota,
+		tenants: make(map[string]*tenant),
+	}
+}
+
+// Add starts accounting the enforcer of a tenant and replaces an existing tenant.
+// The default quota is used, if quota is omitted.
+// A *QuotaError is returned, if the current rules of e already exceed the quota.
+func (ts *Tenants) Add(name string, e *Enforcer, quota ...TenantQuota) error {
+	ts.Remove(name)
+
+	t := &tenant{e: e, quota: ts.quota, usage: TenantUsage{Tenant: name}}
+	if len(quota) > 0 {
+		t.quota = quota[0]
+	}
+	t.recount()
+
+	t.listen(m.RULE_ADDED, func(arguments ...interface{}) {
+		t.count(arguments[0].([]string), 1)
+	})
+	t.listen(m.RULES_ADDED, func(arguments ...interface{}) {
+		for _, rule := range arguments[0].([][]string) {
+			t.count(rule, 1)
+		}
+	})
+	t.listen(m.RULE_REMOVED, func(arguments ...interface{}) {
+		t.count(arguments[0].([]string), -1)
+	})
+	e.admit = t.admit
+
+	ts.mutex.Lock()
+	ts.tenants[name] = t
+	ts.mutex.Unlock()
+	return t.admit(nil)
+}
+
+// Remove stops accounting a tenant
+func (ts *Tenants) Remove(name string) bool {
+	ts.mutex.Lock()
+	t, ok := ts.tenants[name]
+	delete(ts.tenants, name)
+	ts.mutex.Unlock()
+	if ok {
+		for _, l := range t.listeners {
+			t.e.model.RemoveListener(l.event, l.listener)
+		}
+		t.e.admit = nil
+	}
+	return ok
+}
+
+func (ts *Tenants) get(name string) (*tenant, bool) {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	t, ok := ts.tenants[name]
+	return t, ok
+}
+
+// GetEnforcer returns the enforcer of a tenant
+func (ts *Tenants) GetEnforcer(name string) (*Enforcer, bool) {
+	if t, ok := ts.get(name); ok {
+		return t.e, true
+	}
+	return nil, false
+}
+
+// SetQuota replaces the quota of a tenant
+func (ts *Tenants) SetQuota(name string, quota TenantQuota) bool {
+	t, ok := ts.get(name)
+	if ok {
+		t.mutex.Lock()
+		t.quota = quota
+		t.mutex.Unlock()
+	}
+	return ok
+}
+
+// Recount recomputes the rule accounting of a tenant, e.g. after ClearPolicy, which emits no rule events
+func (ts *Tenants) Recount(name string) bool {
+	t, ok := ts.get(name)
+	if ok {
+		t.recount()
+	}
+	return ok
+}
+
+// Usage returns the accounting of a tenant
+func (ts *Tenants) Usage(name string) (TenantUsage, bool) {
+	t, ok := ts.get(name)
+	if !ok {
+		return TenantUsage{}, false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.usage, true
+}
+
+// Usages returns the accounting of all tenants sorted by name, e.g. to export metrics
+func (ts *Tenants) Usages() []TenantUsage {
+	ts.mutex.RLock()
+	names := make([]string, 0, len(ts.tenants))
+	for name := range ts.tenants {
+		names = append(names, name)
+	}
+	ts.mutex.RUnlock()
+	sort.Strings(names)
+
+	res := make([]TenantUsage, 0, len(names))
+	for _, name := range names {
+		if usage, ok := ts.Usage(name); ok {
+			res = append(res, usage)
+		}
+	}
+	return res
+}
+
+// Enforce decides a request with the enforcer of a tenant and accounts its evaluation time
+func (ts *Tenants) Enforce(name string, params ...interface{}) (bool, error) {
+	t, ok := ts.get(name)
+	if !ok {
+		return false, fmt.Errorf("error: tenant %s not found", name)
+	}
+
+	start := time.Now()
+	allowed, err := t.e.Enforce(params...)
+	elapsed := time.Since(start)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.usage.Evaluations++
+	t.usage.EvalTime += elapsed
+	if t.quota.MaxEvalTime > 0 && elapsed > t.quota.MaxEvalTime {
+		t.usage.Violations++
+		return false, &QuotaError{t.usage.Tenant, "maximum evaluation time", int64(elapsed), int64(t.quota.MaxEvalTime)}
+	}
+	return allowed, err
+}
+
+func (t *tenant) listen(event emitter.EventType, handler emitter.HandleFunc) {
+	l := t.e.model.AddListener(event, handler)
+	t.listeners = append(t.listeners, modelListener{event, l})
+}
+
+// ruleSize estimates the memory of a rule including its index entry
+func ruleSize(rule []string) int64 {
+	size := int64(64)
+	for _, value := range rule {
+		size += int64(2*len(value) + 16)
+	}
+	return size
+}
+
+func (t *tenant) count(rule []string, delta int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.usage.Rules += delta
+	t.usage.Memory += int64(delta) * ruleSize(rule)
+}
+
+func (t *tenant) recount() {
+	rules, memory := 0, int64(0)
+	t.e.model.RangeRules(func(rule []string) bool {
+		rules++
+		memory += ruleSize(rule)
+		return true
+	})
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.usage.Rules, t.usage.Memory = rules, memory
+}
+
+// admit returns a *QuotaError, if adding rules would exceed the quota
+func (t *tenant) admit(rules [][]string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	count, memory := t.usage.Rules+len(rules), t.usage.Memory
+	for _, rule := range rules {
+		memory += ruleSize(rule)
+	}
+
+	var err error
+	if t.quota.MaxRules > 0 && count > t.quota.MaxRules {
+		err = &QuotaError{t.usage.Tenant, "maximum number of rules", int64(count), int64(t.quota.MaxRules)}
+	} else if t.quota.MaxMemory > 0 && memory > t.quota.MaxMemory {
+		err = &QuotaError{t.usage.Tenant, "maximum memory", memory, t.quota.MaxMemory}
+	}
+	if err != nil {
+		t.usage.Violations++
+	}
+	return err
+}
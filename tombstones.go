@@ -0,0 +1,155 @@
+package fastac
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/fastac/storage"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Tombstone is a removed rule, which can be restored until it is purged
+type Tombstone struct {
+	Rule      []string
+	DeletedAt time.Time
+	// Stamp is the stamp of the removal, if the rule has been removed by a StampedMutator
+	Stamp Stamp
+}
+
+// tombstones holds the removed rules by their hash
+type tombstones struct {
+	mutex sync.RWMutex
+	rules map[string]Tombstone
+}
+
+// Option to disable/enable soft deletes (default: disabled)
+// If soft deletes are enabled, removed rules are kept as tombstones, which can be restored by RestoreRule until they are purged.
+// Adapters implementing storage.SoftDeleteAdapter soft-delete removed rules and keep the tombstones across restarts.
+//
+//	NewEnforcer(model, adapter, OptionSoftDelete(true))
+func OptionSoftDelete(enable bool) Option {
+	return func(e *Enforcer) error {
+		e.sc.SetSoftDelete(enable)
+		if !enable {
+			e.tombstones = nil
+			return nil
+		}
+		e.tombstones = &tombstones{rules: make(map[string]Tombstone)}
+		return e.loadTombstones()
+	}
+}
+
+// loadTombstones adds the tombstones of a soft-delete adapter
+func (e *Enforcer) loadTombstones() error {
+	sa, ok := e.adapter.(storage.SoftDeleteAdapter)
+	if e.tombstones == nil || !ok {
+		return nil
+	}
+	e.tombstones.mutex.Lock()
+	defer e.tombstones.mutex.Unlock()
+	return sa.LoadTombstones(func(rule []string, deletedAt time.Time) {
+		if !e.model.HasRule(rule) {
+			e.tombstones.rules[util.Hash(rule)] = Tombstone{Rule: rule, DeletedAt: deletedAt}
+		}
+	})
+}
+
+// bury keeps removed rules as tombstones, if soft deletes are enabled
+func (e *Enforcer) bury(rules ...[]string) {
+	if e.tombstones == nil {
+		return
+	}
+	now := time.Now()
+	e.tombstones.mutex.Lock()
+	defer e.tombstones.mutex.Unlock()
+	for _, rule := range rules {
+		stamp, _ := e.stamps.get(rule)
+		e.tombstones.rules[util.Hash(rule)] = Tombstone{Rule: rule, DeletedAt: now, Stamp: stamp}
+	}
+}
+
+// unbury drops the tombstones of added rules
+func (e *Enforcer) unbury(rules ...[]string) {
+	if e.tombstones == nil {
+		return
+	}
+	e.tombstones.mutex.Lock()
+	defer e.tombstones.mutex.Unlock()
+	for _, rule := range rules {
+		delete(e.tombstones.rules, util.Hash(rule))
+	}
+}
+
+// GetTombstones returns the tombstones of the removed rules ordered by their deletion, if soft deletes are enabled
+func (e *Enforcer) GetTombstones() []Tombstone {
+	if e.tombstones == nil {
+		return nil
+	}
+	e.tombstones.mutex.RLock()
+	res := make([]Tombstone, 0, len(e.tombstones.rules))
+	for _, tombstone := range e.tombstones.rules {
+		res = append(res, tombstone)
+	}
+	e.tombstones.mutex.RUnlock()
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].DeletedAt.Before(res[j].DeletedAt)
+	})
+	return res
+}
+
+// RestoreRule adds a removed rule again, returns false, if the rule has no tombstone
+//
+//	e.RemoveRule([]string{"g", "alice", "admin"})
+//	e.RestoreRule([]string{"g", "alice", "admin"})
+func (e *Enforcer) RestoreRule(rule []string) (bool, error) {
+	if e.tombstones == nil {
+		return false, nil
+	}
+	e.tombstones.mutex.RLock()
+	_, ok := e.tombstones.rules[util.Hash(rule)]
+	e.tombstones.mutex.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return e.AddRule(rule)
+}
+
+// PurgeTombstones permanently deletes the tombstones of rules removed more than olderThan ago and returns their number.
+// Pending changes are flushed first, so a soft-delete adapter purges the rules after they have been soft-deleted.
+//
+//	e.PurgeTombstones(30 * 24 * time.Hour)
+func (e *Enforcer) PurgeTombstones(olderThan time.Duration) (int, error) {
+	if e.IsReadOnly() {
+		return 0, errors.New(str.ERR_READ_ONLY)
+	}
+	if e.tombstones == nil {
+		return 0, nil
+	}
+	deadline := time.Now().Add(-olderThan)
+	rules := [][]string{}
+	e.tombstones.mutex.RLock()
+	for _, tombstone := range e.tombstones.rules {
+		if tombstone.DeletedAt.Before(deadline) {
+			rules = append(rules, tombstone.Rule)
+		}
+	}
+	e.tombstones.mutex.RUnlock()
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	if sa, ok := e.adapter.(storage.SoftDeleteAdapter); ok && e.sc.Enabled() {
+		if err := e.sc.Flush(); err != nil {
+			return 0, err
+		}
+		if err := sa.PurgeRules(rules); err != nil {
+			e.GetLogger().Error("purging tombstones failed", "rules", len(rules), "error", err)
+			return 0, err
+		}
+	}
+	e.unbury(rules...)
+	return len(rules), nil
+}
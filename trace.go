@@ -0,0 +1,249 @@
+package fastac
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/model/defs"
+	"github.com/oarkflow/fastac/model/eft"
+	"github.com/oarkflow/fastac/model/types"
+)
+
+// kinds of trace steps
+const (
+	// STEP_EVAL is the evaluation of a stage of the matcher expression
+	STEP_EVAL = "eval"
+	// STEP_CALL is a function call of the matcher expression, e.g. a role lookup of g
+	STEP_CALL = "call"
+	// STEP_EFFECT is the merge of the effect of a matching rule by the effector
+	STEP_EFFECT = "effect"
+)
+
+// traceMutex serializes the traces of concurrent requests, so they do not interleave in the writer
+var traceMutex sync.Mutex
+
+// TraceStep is a step of a traced evaluation
+type TraceStep struct {
+	Kind string `json:"kind"`
+	// Expr is the evaluated expression of an eval step, or the called function of a call step
+	Expr string `json:"expr,omitempty"`
+	// Values contains the policy values of an eval step by argument name, e.g. "p.sub"
+	Values map[string]string `json:"values,omitempty"`
+	// Args contains the arguments of a call step
+	Args []string `json:"args,omitempty"`
+	// Rule is the matching rule of an effect step, Effect its value of the eft column
+	Rule   []string `json:"rule,omitempty"`
+	Effect string   `json:"effect,omitempty"`
+	// Result is the result of an eval or call step, or the merged effect of an effect step
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// RolePath is a role lookup of a traced evaluation, which has been granted
+type RolePath struct {
+	// Key is the key of the role definition, e.g. "g"
+	Key    string   `json:"key"`
+	User   string   `json:"user"`
+	Role   string   `json:"role"`
+	Domain []string `json:"domain,omitempty"`
+	// Path contains the roles from User to Role, it is empty, if the link is not stored, e.g. matched by a pattern
+	Path []string `json:"path,omitempty"`
+}
+
+// Option to write a trace of every Enforce call to w, nil disables tracing (default: disabled).
+// The trace lists the evaluated matcher stages with their policy values and results,
+// the function calls like role lookups and the matched rules with their effects.
+//
+//	NewEnforcer(model, adapter, OptionTrace(os.Stderr))
+func OptionTrace(w io.Writer) Option {
+	return func(e *Enforcer) error {
+		e.trace = w
+		return nil
+	}
+}
+
+// EnableTrace writes the trace of a single request to w
+//
+//	e.Enforce("alice", "data1", "read", EnableTrace(os.Stderr))
+func EnableTrace(w io.Writer) ContextOption {
+	return func(ctx *Context) error {
+		ctx.trace = w
+		ctx.explain = true
+		return nil
+	}
+}
+
+// EnableExplain records the trace steps and role paths of a single request in its decision
+//
+//	d := e.EnforceDecision("alice", "data1", "read", EnableExplain())
+//	data, _ := json.Marshal(d)
+func EnableExplain() ContextOption {
+	return func(ctx *Context) error {
+		ctx.explain = true
+		return nil
+	}
+}
+
+// tracer collects the steps of a request
+type tracer struct {
+	e         *Enforcer
+	w         io.Writer
+	steps     []TraceStep
+	rolePaths []RolePath
+}
+
+// traced returns a copy of the context, which collects the trace of the request, if tracing is enabled
+func (e *Enforcer) traced(ctx *Context) *Context {
+	w := ctx.trace
+	if w == nil {
+		w = e.trace
+	}
+	if w == nil && !ctx.explain {
+		return ctx
+	}
+	traced := *ctx
+	traced.tracer = &tracer{e: e, w: w}
+	return &traced
+}
+
+func (t *tracer) Stage(expr string, args []string, values []string, result interface{}, err error) {
+	step := TraceStep{Kind: STEP_EVAL, Expr: traceName(expr), Values: make(map[string]string, len(values)), Result: result}
+	for i, value := range values {
+		step.Values[traceName(args[i])] = value
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	t.steps = append(t.steps, step)
+}
+
+func (t *tracer) Call(name string, args []interface{}, result interface{}, err error) {
+	step := TraceStep{Kind: STEP_CALL, Expr: name, Args: make([]string, len(args)), Result: result}
+	for i, arg := range args {
+		step.Args[i] = fmt.Sprint(arg)
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	t.steps = append(t.steps, step)
+
+	if granted, _ := result.(bool); granted && len(step.Args) >= 2 {
+		if _, ok := t.e.model.GetDef(m.G_SEC, name); ok {
+			t.rolePaths = append(t.rolePaths, RolePath{Key: name, User: step.Args[0], Role: step.Args[1], Domain: step.Args[2:]})
+		}
+	}
+}
+
+// merged records the effect of a matching rule and the merged effect of the effector
+func (t *tracer) merged(rule []string, effect string, result types.Effect) {
+	t.steps = append(t.steps, TraceStep{Kind: STEP_EFFECT, Rule: rule, Effect: effect, Result: traceEffect(result)})
+}
+
+// decision completes the decision with the collected steps and writes the trace, if a writer is set
+func (t *tracer) decision(d *Decision) {
+	for i := range t.rolePaths {
+		t.rolePaths[i].Path = t.rolePath(t.rolePaths[i])
+	}
+	d.Trace, d.RolePaths = t.steps, t.rolePaths
+	if t.w == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "request %v\n", d.Request)
+	for _, step := range t.steps {
+		fmt.Fprintln(buf, step.String())
+	}
+	for _, path := range t.rolePaths {
+		if len(path.Path) > 0 {
+			fmt.Fprintf(buf, "  role  %s: %s\n", path.Key, strings.Join(path.Path, " -> "))
+		}
+	}
+	if d.Err != nil {
+		fmt.Fprintf(buf, "  error %v\n", d.Err)
+	}
+	fmt.Fprintf(buf, "  decision allowed=%v effect=%s\n", d.Allowed, traceEffect(d.Effect))
+
+	traceMutex.Lock()
+	defer traceMutex.Unlock()
+	t.w.Write(buf.Bytes())
+}
+
+// rolePath returns the shortest chain of stored links from the user to the role of a granted lookup
+func (t *tracer) rolePath(lookup RolePath) []string {
+	rm, _ := t.e.model.GetRoleManager(lookup.Key)
+	parents := map[string]string{lookup.User: ""}
+	queue := []string{lookup.User}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if name == lookup.Role {
+			path := []string{}
+			for ; name != ""; name = parents[name] {
+				path = append([]string{name}, path...)
+			}
+			return path
+		}
+		roles, err := rm.GetRoles(name, lookup.Domain...)
+		if err != nil {
+			return nil
+		}
+		for _, role := range roles {
+			if _, ok := parents[role]; !ok {
+				parents[role] = name
+				queue = append(queue, role)
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the step as line of a trace
+func (step TraceStep) String() string {
+	var line string
+	switch step.Kind {
+	case STEP_EVAL:
+		names := make([]string, 0, len(step.Values))
+		for name := range step.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		bindings := make([]string, len(names))
+		for i, name := range names {
+			bindings[i] = fmt.Sprintf("%s=%q", name, step.Values[name])
+		}
+		line = fmt.Sprintf("  eval  %s [%s]", step.Expr, strings.Join(bindings, " "))
+	case STEP_CALL:
+		args := make([]string, len(step.Args))
+		for i, arg := range step.Args {
+			args[i] = fmt.Sprintf("%q", arg)
+		}
+		line = fmt.Sprintf("    call %s(%s)", step.Expr, strings.Join(args, ", "))
+	case STEP_EFFECT:
+		line = fmt.Sprintf("  match %v effect %s, merged", step.Rule, step.Effect)
+	}
+	if step.Error != "" {
+		return line + " => error: " + step.Error
+	}
+	return fmt.Sprintf("%s => %v", line, step.Result)
+}
+
+// traceName returns the name of an argument or expression as written in the model, e.g. r.sub instead of r_sub
+func traceName(expr string) string {
+	return defs.ArgReg.ReplaceAllString(expr, "${1}.${3}")
+}
+
+func traceEffect(effect types.Effect) string {
+	switch effect {
+	case eft.Allow:
+		return "allow"
+	case eft.Deny:
+		return "deny"
+	}
+	return "indeterminate"
+}
@@ -0,0 +1,210 @@
+package fastac
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+type txOp struct {
+	remove bool
+	rule   []string
+}
+
+// Transaction stages rule changes, which are applied at once by Commit or discarded by Rollback.
+// The staged changes are not visible to requests until they are committed.
+type Transaction struct {
+	e *Enforcer
+	// lock guards the commit, e.g. the write lock of a SyncedEnforcer
+	lock sync.Locker
+
+	mutex sync.Mutex
+	ops   []txOp
+	done  bool
+}
+
+// BeginTx starts a transaction
+//
+//	tx := e.BeginTx()
+//	tx.RemoveRule([]string{"p", "alice", "data1", "write"})
+//	tx.AddRule([]string{"p", "alice", "data1", "read"})
+//	if err := tx.Commit(); err != nil {
+//		// neither the model nor the adapter have been changed
+//	}
+func (e *Enforcer) BeginTx() *Transaction {
+	return &Transaction{e: e}
+}
+
+// AddRule stages adding a rule, the rule is checked immediately
+func (tx *Transaction) AddRule(rule []string) error {
+	return tx.AddRules([][]string{rule})
+}
+
+// AddRules stages adding rules, the rules are checked immediately
+func (tx *Transaction) AddRules(rules [][]string) error {
+	for _, rule := range rules {
+		if err := tx.e.checkRule(rule); err != nil {
+			return err
+		}
+	}
+	return tx.stage(false, rules)
+}
+
+// RemoveRule stages removing a rule
+func (tx *Transaction) RemoveRule(rule []string) error {
+	return tx.stage(true, [][]string{rule})
+}
+
+// RemoveRules stages removing rules
+func (tx *Transaction) RemoveRules(rules [][]string) error {
+	return tx.stage(true, rules)
+}
+
+func (tx *Transaction) stage(remove bool, rules [][]string) error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.done {
+		return errors.New(str.ERR_TX_DONE)
+	}
+	for _, rule := range rules {
+		tx.ops = append(tx.ops, txOp{remove, append([]string(nil), rule...)})
+	}
+	return nil
+}
+
+// Rollback discards the staged changes
+func (tx *Transaction) Rollback() {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	tx.done = true
+	tx.ops = nil
+}
+
+// Commit applies the staged changes, see CommitCtx
+func (tx *Transaction) Commit() error {
+	return tx.CommitCtx(context.Background())
+}
+
+// CommitCtx applies the net effect of the staged changes to the model and the adapter.
+// The queued changes of autosave are flushed first, so they cannot overwrite the transaction later.
+// The model is changed first, then the changes are sent to the adapter, bypassing the queue of autosave,
+// TransactionalAdapters store them atomically. If the adapter fails, the changes of the model are reverted,
+// so a failed commit leaves both unchanged. Requests of a SyncedEnforcer see either none or all changes,
+// since they wait for the write lock held by the commit.
+// The transaction ends with the commit, even if it fails.
+func (tx *Transaction) CommitCtx(ctx context.Context) error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.done {
+		return errors.New(str.ERR_TX_DONE)
+	}
+	tx.done = true
+	if tx.lock != nil {
+		tx.lock.Lock()
+		defer tx.lock.Unlock()
+	}
+
+	e := tx.e
+	if e.IsReadOnly() {
+		return errors.New(str.ERR_READ_ONLY)
+	}
+	added, removed := tx.changes()
+	if e.admit != nil && len(added) > 0 {
+		if err := e.admit(added); err != nil {
+			return err
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	sc := e.sc
+	store := sc != nil && sc.Enabled()
+	if store {
+		if err := sc.FlushContext(ctx); err != nil {
+			return err
+		}
+		// the changes are sent to the adapter by Apply and must not be queued
+		sc.Disable()
+		defer sc.Enable()
+	}
+	if err := tx.apply(added, removed); err != nil {
+		return err
+	}
+	if store {
+		if err := sc.Apply(ctx, added, removed); err != nil {
+			if rerr := tx.apply(removed, added); rerr != nil {
+				e.GetLogger().Error("reverting the transaction failed", "error", rerr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// apply removes and adds the rules in the model, which are present respectively missing.
+// If adding fails, the rules added before the failure are removed and the removed rules are added again.
+func (tx *Transaction) apply(added, removed [][]string) error {
+	e := tx.e
+	if len(removed) > 0 {
+		if err := e.RemoveRules(removed); err != nil {
+			return err
+		}
+	}
+	if len(added) > 0 {
+		if err := e.AddRules(added); err != nil {
+			partial := [][]string{}
+			for _, rule := range added {
+				if e.model.HasRule(rule) {
+					partial = append(partial, rule)
+				}
+			}
+			if len(partial) > 0 {
+				if rerr := e.RemoveRules(partial); rerr != nil {
+					e.GetLogger().Error("reverting the transaction failed", "error", rerr)
+				}
+			}
+			if len(removed) > 0 {
+				if rerr := e.AddRules(removed); rerr != nil {
+					e.GetLogger().Error("reverting the transaction failed", "error", rerr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// changes returns the rules, which are missing in the model and added by the transaction,
+// and the rules, which are present and removed, in the order they were staged first
+func (tx *Transaction) changes() (added, removed [][]string) {
+	type state struct {
+		rule             []string
+		initial, present bool
+	}
+	states := map[string]*state{}
+	order := []string{}
+	for _, op := range tx.ops {
+		key := util.Hash(op.rule)
+		s, ok := states[key]
+		if !ok {
+			present := tx.e.model.HasRule(op.rule)
+			s = &state{rule: op.rule, initial: present, present: present}
+			states[key] = s
+			order = append(order, key)
+		}
+		s.present = !op.remove
+	}
+	for _, key := range order {
+		s := states[key]
+		if s.present && !s.initial {
+			added = append(added, s.rule)
+		} else if !s.present && s.initial {
+			removed = append(removed, s.rule)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,320 @@
+package fastac
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/oarkflow/fastac/api"
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// memoryAdapter keeps the rules in memory, adding rules of the subject mallory fails
+type memoryAdapter struct {
+	rules map[string][]string
+}
+
+func newMemoryAdapter(rules ...[]string) *memoryAdapter {
+	a := &memoryAdapter{rules: make(map[string][]string)}
+	for _, rule := range rules {
+		a.rules[util.Hash(rule)] = rule
+	}
+	return a
+}
+
+func (a *memoryAdapter) LoadPolicy(model api.IAddRuleBool) error {
+	for _, rule := range a.rules {
+		if _, err := model.AddRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *memoryAdapter) SavePolicy(model api.IRangeRules) error { return nil }
+
+func (a *memoryAdapter) AddRule(rule []string) error {
+	if len(rule) > 1 && rule[1] == "mallory" {
+		return errors.New("adapter rejects mallory")
+	}
+	a.rules[util.Hash(rule)] = rule
+	return nil
+}
+
+func (a *memoryAdapter) RemoveRule(rule []string) error {
+	delete(a.rules, util.Hash(rule))
+	return nil
+}
+
+func (a *memoryAdapter) sorted() [][]string {
+	rules := [][]string{}
+	for _, rule := range a.rules {
+		rules = append(rules, rule)
+	}
+	util.SortRules(rules)
+	return rules
+}
+
+// txAdapter is a memoryAdapter, which applies the changes of a transaction atomically
+type txAdapter struct {
+	*memoryAdapter
+	applied int
+}
+
+func (a *txAdapter) ApplyRules(ctx context.Context, added, removed [][]string) error {
+	for _, rule := range added {
+		if rule[1] == "mallory" {
+			return errors.New("adapter rejects mallory")
+		}
+	}
+	a.applied++
+	for _, rule := range removed {
+		a.RemoveRule(rule)
+	}
+	for _, rule := range added {
+		a.AddRule(rule)
+	}
+	return nil
+}
+
+func sortedRules(e *Enforcer) [][]string {
+	rules := [][]string{}
+	e.GetModel().RangeRules(func(rule []string) bool {
+		rules = append(rules, rule)
+		return true
+	})
+	util.SortRules(rules)
+	return rules
+}
+
+var txRules = [][]string{
+	{"p", "alice", "data1", "read"},
+	{"p", "bob", "data2", "write"},
+}
+
+func TestTransactionChanges(t *testing.T) {
+	alice, bob, carol := txRules[0], txRules[1], []string{"p", "carol", "data3", "read"}
+	tests := []struct {
+		ops     []txOp
+		added   [][]string
+		removed [][]string
+	}{
+		{[]txOp{{false, carol}, {true, alice}}, [][]string{carol}, [][]string{alice}},
+		// adding an existing rule and removing a missing one do not change the model
+		{[]txOp{{false, alice}, {true, carol}}, nil, nil},
+		{[]txOp{{false, carol}, {true, carol}}, nil, nil},
+		{[]txOp{{true, alice}, {false, alice}}, nil, nil},
+		{[]txOp{{false, carol}, {false, carol}}, [][]string{carol}, nil},
+		{[]txOp{{true, alice}, {false, alice}, {true, alice}}, nil, [][]string{alice}},
+		{[]txOp{{true, bob}, {false, carol}, {true, alice}}, [][]string{carol}, [][]string{bob, alice}},
+	}
+	for _, test := range tests {
+		e := testEnforcer(t, nil)
+		if err := e.AddRules(txRules); err != nil {
+			t.Fatal(err)
+		}
+		tx := e.BeginTx()
+		tx.ops = test.ops
+		added, removed := tx.changes()
+		if !reflect.DeepEqual(added, test.added) || !reflect.DeepEqual(removed, test.removed) {
+			t.Fatalf("changes of %v: %v %v, want %v %v", test.ops, added, removed, test.added, test.removed)
+		}
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		memory := newMemoryAdapter(txRules...)
+		var adapter api.Adapter = memory
+		if transactional {
+			adapter = &txAdapter{memoryAdapter: memory}
+		}
+		e := testEnforcer(t, adapter, OptionAutosave(true))
+		if err := e.LoadPolicy(); err != nil {
+			t.Fatal(err)
+		}
+
+		tx := e.BeginTx()
+		tx.RemoveRule(txRules[0])
+		tx.AddRule([]string{"p", "carol", "data3", "read"})
+		tx.AddRule([]string{"p", "carol", "data3", "read"})
+		tx.AddRule(txRules[1])
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		want := [][]string{{"p", "bob", "data2", "write"}, {"p", "carol", "data3", "read"}}
+		if got := sortedRules(e); !reflect.DeepEqual(got, want) {
+			t.Fatalf("model after commit (transactional %v): %v, want %v", transactional, got, want)
+		}
+		if got := memory.sorted(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("adapter after commit (transactional %v): %v, want %v", transactional, got, want)
+		}
+		if a, ok := adapter.(*txAdapter); ok && a.applied != 1 {
+			t.Fatalf("ApplyRules called %d times, want once", a.applied)
+		}
+	}
+}
+
+func TestTransactionCommitAdapterFailure(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		memory := newMemoryAdapter(txRules...)
+		var adapter api.Adapter = memory
+		if transactional {
+			adapter = &txAdapter{memoryAdapter: memory}
+		}
+		e := testEnforcer(t, adapter, OptionAutosave(true))
+		if err := e.LoadPolicy(); err != nil {
+			t.Fatal(err)
+		}
+
+		tx := e.BeginTx()
+		tx.RemoveRule(txRules[0])
+		tx.AddRule([]string{"p", "carol", "data3", "read"})
+		tx.AddRule([]string{"p", "mallory", "data3", "read"})
+		if err := tx.Commit(); err == nil {
+			t.Fatalf("commit with failing adapter (transactional %v): nil error", transactional)
+		}
+		if got := sortedRules(e); !reflect.DeepEqual(got, txRules) {
+			t.Fatalf("model after failed commit (transactional %v): %v, want %v", transactional, got, txRules)
+		}
+		// the plain adapter stores carol, before it rejects mallory, carol is removed again
+		if got := memory.sorted(); !reflect.DeepEqual(got, txRules) {
+			t.Fatalf("adapter after failed commit (transactional %v): %v, want %v", transactional, got, txRules)
+		}
+		if ok, _ := e.Enforce("alice", "data1", "read"); !ok {
+			t.Fatalf("Enforce after failed commit (transactional %v): removed rule is not restored", transactional)
+		}
+
+		// the queue of autosave is empty, the next change is stored without the failed transaction
+		if _, err := e.AddRule([]string{"p", "dave", "data4", "read"}); err != nil {
+			t.Fatalf("AddRule after failed commit (transactional %v): %v", transactional, err)
+		}
+	}
+}
+
+func TestTransactionCommitPartlyFailedAdd(t *testing.T) {
+	e := testEnforcer(t, nil)
+	if err := e.AddRules(txRules); err != nil {
+		t.Fatal(err)
+	}
+
+	// p9 is not defined by the model, the rule of carol is added before adding it fails
+	tx := e.BeginTx()
+	tx.RemoveRule(txRules[0])
+	tx.AddRule([]string{"p", "carol", "data3", "read"})
+	tx.AddRule([]string{"p9", "carol", "data3", "read"})
+	if err := tx.Commit(); err == nil {
+		t.Fatal("commit with undefined policy type: nil error")
+	}
+	if got := sortedRules(e); !reflect.DeepEqual(got, txRules) {
+		t.Fatalf("model after failed commit: %v, want %v", got, txRules)
+	}
+}
+
+func TestTransactionDone(t *testing.T) {
+	e := testEnforcer(t, nil)
+	rule := []string{"p", "alice", "data1", "read"}
+
+	tx := e.BeginTx()
+	tx.AddRule(rule)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil || err.Error() != str.ERR_TX_DONE {
+		t.Fatalf("second commit: %v, want %s", err, str.ERR_TX_DONE)
+	}
+	if err := tx.AddRule([]string{"p", "bob", "data2", "write"}); err == nil || err.Error() != str.ERR_TX_DONE {
+		t.Fatalf("AddRule after commit: %v, want %s", err, str.ERR_TX_DONE)
+	}
+
+	tx = e.BeginTx()
+	tx.RemoveRule(rule)
+	tx.Rollback()
+	if err := tx.Commit(); err == nil || err.Error() != str.ERR_TX_DONE {
+		t.Fatalf("commit after rollback: %v, want %s", err, str.ERR_TX_DONE)
+	}
+	if err := tx.RemoveRules([][]string{rule}); err == nil || err.Error() != str.ERR_TX_DONE {
+		t.Fatalf("RemoveRules after rollback: %v, want %s", err, str.ERR_TX_DONE)
+	}
+	if !e.HasRule(rule) {
+		t.Fatal("rolled back removal has been applied")
+	}
+
+	// a failed commit ends the transaction as well
+	tx = e.BeginTx()
+	tx.AddRule([]string{"p9", "carol", "data3", "read"})
+	if err := tx.Commit(); err == nil {
+		t.Fatal("commit with undefined policy type: nil error")
+	}
+	if err := tx.Commit(); err == nil || err.Error() != str.ERR_TX_DONE {
+		t.Fatalf("commit after failed commit: %v, want %s", err, str.ERR_TX_DONE)
+	}
+}
+
+func TestSyncedTransactionRace(t *testing.T) {
+	model := m.NewModel()
+	if err := model.LoadModelFromText(benchModel); err != nil {
+		t.Fatal(err)
+	}
+	adapter := &txAdapter{memoryAdapter: newMemoryAdapter()}
+	e, err := NewSyncedEnforcer(model, adapter, OptionAutosave(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			user := "user" + strconv.Itoa(i)
+			for j := 0; j < 20; j++ {
+				tx := e.BeginTx()
+				tx.RemoveRule([]string{"p", user, "data" + strconv.Itoa(j-1), "read"})
+				tx.AddRule([]string{"p", user, "data" + strconv.Itoa(j), "read"})
+				if j%5 == 4 {
+					// the adapter rejects the transaction, the model keeps the previous rule
+					tx.AddRule([]string{"p", "mallory", "data" + strconv.Itoa(j), "read"})
+					if err := tx.Commit(); err == nil {
+						t.Error("commit with failing adapter: nil error")
+					}
+					tx = e.BeginTx()
+					tx.RemoveRule([]string{"p", user, "data" + strconv.Itoa(j-1), "read"})
+					tx.AddRule([]string{"p", user, "data" + strconv.Itoa(j), "read"})
+				}
+				if err := tx.Commit(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			user := "user" + strconv.Itoa(i)
+			for j := 0; j < 20; j++ {
+				if _, err := e.Enforce(user, "data"+strconv.Itoa(j), "read"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	want := [][]string{}
+	for i := 0; i < 8; i++ {
+		want = append(want, []string{"p", "user" + strconv.Itoa(i), "data19", "read"})
+	}
+	util.SortRules(want)
+	if got := sortedRules(e.Enforcer); !reflect.DeepEqual(got, want) {
+		t.Fatalf("model after transactions: %v, want %v", got, want)
+	}
+	if got := adapter.sorted(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("adapter after transactions: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package fastac
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oarkflow/fastac/str"
+)
+
+// Codec converts a typed request value to the value passed to the matcher
+type Codec[T any] func(v T) interface{}
+
+// StringCodec converts a value of a string type, e.g. an action enum, to its string
+func StringCodec[T ~string]() Codec[T] {
+	return func(v T) interface{} {
+		return string(v)
+	}
+}
+
+// StringerCodec converts a value to the result of its String method
+func StringerCodec[T fmt.Stringer]() Codec[T] {
+	return func(v T) interface{} {
+		return v.String()
+	}
+}
+
+// Typed enforces requests of the form sub, obj, act with compile-time types, so the arguments cannot be swapped.
+// The values are converted by the codecs and always passed in the order sub, obj, act,
+// so they are bound to the first, second and third argument of the request definition.
+// A nil codec passes the value unchanged.
+//
+//	type Action string
+//	const ActRead Action = "read"
+//
+//	enf, err := NewTyped[User, *Document, Action](e, func(u User) interface{} { return u.Name }, nil, StringCodec[Action]())
+//	enf.Enforce(user, doc, ActRead)
+type Typed[Sub, Obj, Act any] struct {
+	e       IEnforcer
+	sub     Codec[Sub]
+	obj     Codec[Obj]
+	act     Codec[Act]
+	options []ContextOption
+}
+
+// NewTyped returns a typed facade of e, options are passed to every request, e.g. SetPolicyKey("p2").
+// It fails, if the request definition selected by the options, "r" by default, does not have 3 arguments.
+func NewTyped[Sub, Obj, Act any](e IEnforcer, sub Codec[Sub], obj Codec[Obj], act Codec[Act], options ...ContextOption) (*Typed[Sub, Obj, Act], error) {
+	ctx, err := NewContext(e.ViewModel(), options...)
+	if err != nil {
+		return nil, err
+	}
+	rDef := ctx.RequestDef()
+	if rDef == nil {
+		return nil, fmt.Errorf(str.ERR_REQUESTDEF_NOT_FOUND, "r")
+	}
+	if n := len(rDef.GetArgs()); n != 3 {
+		return nil, fmt.Errorf(str.ERR_TYPED_ARITY, rDef.GetKey(), n)
+	}
+	return &Typed[Sub, Obj, Act]{e: e, sub: sub, obj: obj, act: act, options: options}, nil
+}
+
+// Enforcer returns the wrapped enforcer
+func (t *Typed[Sub, Obj, Act]) Enforcer() IEnforcer {
+	return t.e
+}
+
+// Enforce decides whether sub may perform act on obj
+func (t *Typed[Sub, Obj, Act]) Enforce(sub Sub, obj Obj, act Act, options ...ContextOption) (bool, error) {
+	return t.e.Enforce(t.params(sub, obj, act, options)...)
+}
+
+// EnforceCtx decides like Enforce, the evaluation stops with the error of ctx, if ctx is canceled or its deadline is exceeded
+func (t *Typed[Sub, Obj, Act]) EnforceCtx(ctx context.Context, sub Sub, obj Obj, act Act, options ...ContextOption) (bool, error) {
+	return t.e.EnforceCtx(ctx, t.params(sub, obj, act, options)...)
+}
+
+// EnforceDecision decides like Enforce, but returns the whole decision
+func (t *Typed[Sub, Obj, Act]) EnforceDecision(sub Sub, obj Obj, act Act, options ...ContextOption) *Decision {
+	return t.e.EnforceDecision(t.params(sub, obj, act, options)...)
+}
+
+// params returns the positional request values followed by the options
+func (t *Typed[Sub, Obj, Act]) params(sub Sub, obj Obj, act Act, options []ContextOption) []interface{} {
+	params := make([]interface{}, 0, 3+len(t.options)+len(options))
+	params = append(params, encode(t.sub, sub), encode(t.obj, obj), encode(t.act, act))
+	for _, option := range t.options {
+		params = append(params, option)
+	}
+	for _, option := range options {
+		params = append(params, option)
+	}
+	return params
+}
+
+func encode[T any](codec Codec[T], v T) interface{} {
+	if codec == nil {
+		return v
+	}
+	return codec(v)
+}
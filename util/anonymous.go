@@ -0,0 +1,29 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/oarkflow/govaluate"
+)
+
+// ANONYMOUS is the default principal of unauthenticated requests
+const ANONYMOUS = "anonymous"
+
+// AnonymousFunc returns the matcher function isAnonymous(sub), which is true for principal and the empty subject.
+// If anonymous is false, it returns the negation isAuthenticated(sub).
+func AnonymousFunc(principal string, anonymous bool) govaluate.ExpressionFunction {
+	name := "isAnonymous"
+	if !anonymous {
+		name = "isAuthenticated"
+	}
+	return func(args ...interface{}) (interface{}, error) {
+		if err := ValidateVariadicArgs(1, args...); err != nil {
+			return false, fmt.Errorf("%s: %s", name, err)
+		}
+		sub, ok := args[0].(string)
+		if !ok {
+			return false, fmt.Errorf("%s: argument must be a string", name)
+		}
+		return (sub == "" || sub == principal) == anonymous, nil
+	}
+}
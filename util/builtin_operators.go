@@ -116,6 +116,13 @@ func IPMatch(ip1 string, ip2 string) bool {
 	return cidr.Contains(objIP1)
 }
 
+// PathPrefix determines whether prefix is a path prefix of path, segments are seperated by "/"
+// For example, "/a/b" is a prefix of "/a/b" and "/a/b/c", but not of "/a/bc"
+func PathPrefix(path string, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
 // GlobMatch determines whether key1 matches the pattern of key2 using glob pattern
 func GlobMatch(key1 string, key2 string) (bool, error) {
 	return path.Match(key2, key1)
@@ -149,10 +156,10 @@ func WrapMatchingFunc(fn MatchingFunc) govaluate.ExpressionFunction {
 	}
 }
 
-var pathMatchCache = NewSyncLRUCache(100)
-var pathMatchCache2 = NewSyncLRUCache(100)
+var pathMatchCache = NewShardedLRUCache(100)
+var pathMatchCache2 = NewShardedLRUCache(100)
 
-func getPath(cache *SyncLRUCache, pattern string, options ...pm.Option) *pm.Path {
+func getPath(cache Cache, pattern string, options ...pm.Option) *pm.Path {
 	value, ok := cache.Get(pattern)
 	var p *pm.Path
 	var err error
@@ -189,6 +196,7 @@ func IsPathPattern2(path string) bool {
 }
 
 var PathMatchFunc = WrapMatchingFunc(PathMatch)
+var PathPrefixFunc = WrapMatchingFunc(PathPrefix)
 var PathMatchFunc2 = WrapMatchingFunc(PathMatch2)
 var RegexMatchFunc = WrapMatchingFunc(RegexMatch)
 var IPMatchFunc = WrapMatchingFunc(IPMatch)
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	pm "github.com/oarkflow/fastac/pathmatch"
+)
+
+var pathPrefixes = []string{"", "/", "//", "/a", "/a/", "/a//", "/a/b", "/a/b/", "a", "a/", "a/b", "/ab"}
+
+func TestPathPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		want         bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/b/c", "/a/b", true},
+		{"/a/bc", "/a/b", false},
+		{"/a/b", "/a/b/", true},
+		{"/a/b/", "/a/b", true},
+		{"/a", "/a/b", false},
+		// the empty prefix and "/" are prefixes of all absolute paths
+		{"/a", "", true},
+		{"/a", "/", true},
+		{"", "", true},
+		{"", "/", true},
+		{"a", "", false},
+		{"a", "/", false},
+		// relative paths are only prefixes of relative paths
+		{"a/b", "a", true},
+		{"/a/b", "a", false},
+		{"a/b", "/a", false},
+		{"//x", "//", true},
+		{"/x", "//", false},
+	}
+	for _, test := range tests {
+		if got := PathPrefix(test.path, test.prefix); got != test.want {
+			t.Fatalf("PathPrefix(%q, %q): %v, want %v", test.path, test.prefix, got, test.want)
+		}
+	}
+}
+
+// the prefix trie of the matcher index has to find exactly the prefixes selected by PathPrefix
+func TestPathPrefixTrie(t *testing.T) {
+	trie := pm.NewPrefixTrie()
+	for _, prefix := range pathPrefixes {
+		trie.Insert(prefix, prefix)
+	}
+	paths := append([]string{"/a/b/c", "/a/bc", "/ab/c", "/b", "a/b/c", "//", "///x", "/a//b"}, pathPrefixes...)
+
+	check := func(state string, prefixes []string) {
+		t.Helper()
+		for _, path := range paths {
+			got := []string{}
+			trie.Prefixes(path, func(value string) bool {
+				got = append(got, value)
+				return true
+			})
+			want := []string{}
+			for _, prefix := range prefixes {
+				if PathPrefix(path, prefix) {
+					want = append(want, prefix)
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("prefixes of %q %s: %q, want %q", path, state, got, want)
+			}
+		}
+	}
+	check("after Insert", pathPrefixes)
+
+	remaining := []string{}
+	for i, prefix := range pathPrefixes {
+		if i%2 == 0 {
+			if !trie.Remove(prefix, prefix) {
+				t.Fatalf("Remove(%q): false", prefix)
+			}
+		} else {
+			remaining = append(remaining, prefix)
+		}
+	}
+	if trie.Remove(pathPrefixes[0], pathPrefixes[0]) || trie.Remove("/c", "/c") {
+		t.Fatal("Remove of a missing value: true")
+	}
+	check("after Remove", remaining)
+}
@@ -1,6 +1,17 @@
 package util
 
-import "sync"
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+// Cache is a goroutine-safe key value cache with a bounded capacity
+type Cache interface {
+	Get(key interface{}) (value interface{}, ok bool)
+	Put(key interface{}, value interface{})
+}
 
 type node struct {
 	key   interface{}
@@ -14,6 +25,7 @@ type LRUCache struct {
 	m        map[interface{}]*node
 	head     *node
 	tail     *node
+	onEvict  func(key interface{}, value interface{})
 }
 
 func NewLRUCache(capacity int) *LRUCache {
@@ -70,18 +82,49 @@ func (cache *LRUCache) Get(key interface{}) (value interface{}, ok bool) {
 func (cache *LRUCache) Put(key interface{}, value interface{}) {
 	n, ok := cache.m[key]
 	if ok {
+		n.value = value
 		cache.remove(n, false)
 	} else {
 		n = &node{key, value, nil, nil}
 		if len(cache.m) >= cache.capacity {
-			cache.remove(cache.tail.prev, false)
+			evicted := cache.tail.prev
+			cache.remove(evicted, false)
+			if cache.onEvict != nil {
+				cache.onEvict(evicted.key, evicted.value)
+			}
 		}
 	}
 	cache.add(n, false)
 }
 
+// Remove deletes the entry of key, the evict function is not called
+func (cache *LRUCache) Remove(key interface{}) {
+	if n, ok := cache.m[key]; ok {
+		cache.remove(n, false)
+	}
+}
+
+// SetOnEvict sets the function, which is called with every entry evicted by Put or Clear
+func (cache *LRUCache) SetOnEvict(fn func(key interface{}, value interface{})) {
+	cache.onEvict = fn
+}
+
+// Clear evicts all entries
+func (cache *LRUCache) Clear() {
+	for n := cache.head.next; n != cache.tail; n = n.next {
+		if cache.onEvict != nil {
+			cache.onEvict(n.key, n.value)
+		}
+	}
+	cache.m = map[interface{}]*node{}
+	cache.head.next = cache.tail
+	cache.tail.prev = cache.head
+}
+
+// SyncLRUCache guards a LRUCache with a single mutex.
+// Get reorders the entries, so readers are serialized as well, use ShardedLRUCache under high concurrency.
 type SyncLRUCache struct {
-	rwm sync.RWMutex
+	mutex sync.Mutex
 	*LRUCache
 }
 
@@ -92,13 +135,82 @@ func NewSyncLRUCache(capacity int) *SyncLRUCache {
 }
 
 func (cache *SyncLRUCache) Get(key interface{}) (value interface{}, ok bool) {
-	cache.rwm.RLock()
-	defer cache.rwm.RUnlock()
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
 	return cache.LRUCache.Get(key)
 }
 
 func (cache *SyncLRUCache) Put(key interface{}, value interface{}) {
-	cache.rwm.Lock()
-	defer cache.rwm.Unlock()
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
 	cache.LRUCache.Put(key, value)
 }
+
+func (cache *SyncLRUCache) Remove(key interface{}) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.LRUCache.Remove(key)
+}
+
+func (cache *SyncLRUCache) Clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.LRUCache.Clear()
+}
+
+// DefaultShards is the number of shards used by NewShardedLRUCache
+const DefaultShards = 16
+
+// ShardedLRUCache distributes the keys over multiple SyncLRUCaches,
+// so goroutines accessing different keys rarely contend for the same lock.
+// The least recently used entry is evicted per shard, not globally.
+type ShardedLRUCache struct {
+	seed   maphash.Seed
+	shards []*SyncLRUCache
+}
+
+// NewShardedLRUCache creates a cache with DefaultShards shards, which hold at least capacity entries in total
+func NewShardedLRUCache(capacity int) *ShardedLRUCache {
+	return NewShardedLRUCacheWithShards(capacity, DefaultShards)
+}
+
+// NewShardedLRUCacheWithShards creates a cache with the given number of shards.
+// The keys are not distributed evenly, so every shard holds three standard deviations more than its share,
+// otherwise a working set of capacity keys would be evicted repeatedly by the fuller shards.
+func NewShardedLRUCacheWithShards(capacity, shards int) *ShardedLRUCache {
+	if shards < 1 {
+		shards = 1
+	}
+	perShard := (capacity + shards - 1) / shards
+	if perShard < 1 {
+		perShard = 1
+	}
+	if shards > 1 {
+		perShard += int(math.Ceil(3 * math.Sqrt(float64(perShard))))
+	}
+	cache := &ShardedLRUCache{seed: maphash.MakeSeed(), shards: make([]*SyncLRUCache, shards)}
+	for i := range cache.shards {
+		cache.shards[i] = NewSyncLRUCache(perShard)
+	}
+	return cache
+}
+
+func (cache *ShardedLRUCache) shard(key interface{}) *SyncLRUCache {
+	s, ok := key.(string)
+	if !ok {
+		s = fmt.Sprint(key)
+	}
+	return cache.shards[maphash.String(cache.seed, s)%uint64(len(cache.shards))]
+}
+
+func (cache *ShardedLRUCache) Get(key interface{}) (value interface{}, ok bool) {
+	return cache.shard(key).Get(key)
+}
+
+func (cache *ShardedLRUCache) Put(key interface{}, value interface{}) {
+	cache.shard(key).Put(key, value)
+}
+
+func (cache *ShardedLRUCache) Remove(key interface{}) {
+	cache.shard(key).Remove(key)
+}
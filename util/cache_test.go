@@ -0,0 +1,87 @@
+package util
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	evicted := []interface{}{}
+	cache.SetOnEvict(func(key interface{}, value interface{}) {
+		evicted = append(evicted, key)
+	})
+
+	cache.Put("a", 1)
+	cache.Put("a", 2)
+	if v, ok := cache.Get("a"); !ok || v != 2 {
+		t.Fatalf("after update: %v %v, want 2 true", v, ok)
+	}
+
+	// b is the least recently used entry after a has been read
+	cache.Put("b", 1)
+	cache.Get("a")
+	cache.Put("c", 1)
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b has not been evicted")
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("evicted %v, want [b]", evicted)
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a has been evicted")
+	}
+
+	cache.Remove("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("a has not been removed")
+	}
+	if len(evicted) != 1 {
+		t.Fatalf("Remove called the evict function: %v", evicted)
+	}
+	cache.Put("d", 1)
+	if _, ok := cache.Get("c"); !ok {
+		t.Fatal("c has been evicted, although a has been removed")
+	}
+}
+
+func TestShardedLRUCacheUpdate(t *testing.T) {
+	cache := NewShardedLRUCache(100)
+	cache.Put("a", 1)
+	cache.Put("a", 2)
+	if v, ok := cache.Get("a"); !ok || v != 2 {
+		t.Fatalf("after update: %v %v, want 2 true", v, ok)
+	}
+	cache.Remove("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("a has not been removed")
+	}
+}
+
+// benchCache reads and writes 1000 keys of a cache with capacity 100 from parallel goroutines, 90% of the accesses are reads
+func benchCache(b *testing.B, cache Cache) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if _, ok := cache.Get(key); !ok || i%10 == 0 {
+				cache.Put(key, i)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSyncLRUCache(b *testing.B) {
+	benchCache(b, NewSyncLRUCache(100))
+}
+
+func BenchmarkShardedLRUCache(b *testing.B) {
+	benchCache(b, NewShardedLRUCache(100))
+}
@@ -0,0 +1,129 @@
+package util
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// maxCanonicalDepth limits the nesting of encoded values, so cyclic values fail instead of recursing forever
+const maxCanonicalDepth = 32
+
+// AppendCanonical appends a stable encoding of value to buf, equal values always have equal encodings.
+// Map entries are sorted by their encoded keys, structs are encoded with their type and exported fields in declaration order,
+// pointers and interfaces by the value they point to. Integers are encoded independently of their size, so int32(1) equals int64(1).
+// Values implementing encoding.TextMarshaler, e.g. time.Time, are encoded with their type and text.
+// Functions, channels, cyclic values and structs with unexported fields cannot be encoded,
+// since values differing only in unexported fields would get the same encoding.
+//
+//	AppendCanonical(nil, map[string]interface{}{"owner": "alice", "tags": []string{"a"}})
+func AppendCanonical(buf []byte, value interface{}) ([]byte, error) {
+	return appendCanonical(buf, reflect.ValueOf(value), 0)
+}
+
+func appendCanonical(buf []byte, v reflect.Value, depth int) ([]byte, error) {
+	if depth > maxCanonicalDepth {
+		return nil, fmt.Errorf("canonical: value is nested deeper than %d levels", maxCanonicalDepth)
+	}
+	if !v.IsValid() {
+		return append(buf, 'n'), nil
+	}
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case string:
+			return appendCanonicalString(append(buf, 's'), value), nil
+		case []byte:
+			return appendCanonicalString(append(buf, 'b'), string(value)), nil
+		case encoding.TextMarshaler:
+			if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+				return append(buf, 'n'), nil
+			}
+			text, err := value.MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			buf = appendCanonicalString(append(buf, 'x'), v.Type().String())
+			return appendCanonicalString(buf, string(text)), nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return appendCanonicalString(append(buf, 's'), v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 't'), nil
+		}
+		return append(buf, 'f'), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(strconv.AppendInt(append(buf, 'i'), v.Int(), 10), ';'), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return append(strconv.AppendUint(append(buf, 'u'), v.Uint(), 10), ';'), nil
+	case reflect.Float32, reflect.Float64:
+		return append(strconv.AppendFloat(append(buf, 'd'), v.Float(), 'g', -1, 64), ';'), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, 'n'), nil
+		}
+		return appendCanonical(buf, v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return append(buf, 'n'), nil
+		}
+		buf = append(strconv.AppendInt(append(buf, 'l'), int64(v.Len()), 10), ':')
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if buf, err = appendCanonical(buf, v.Index(i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(buf, 'n'), nil
+		}
+		entries := make([][]byte, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entry, err := appendCanonical(nil, iter.Key(), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			// encodings are self-delimiting, so distinct keys never share a prefix and entries are ordered by their keys
+			if entry, err = appendCanonical(entry, iter.Value(), depth+1); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i], entries[j]) < 0 })
+		buf = append(strconv.AppendInt(append(buf, 'm'), int64(len(entries)), 10), ':')
+		for _, entry := range entries {
+			buf = append(buf, entry...)
+		}
+		return buf, nil
+	case reflect.Struct:
+		t := v.Type()
+		buf = appendCanonicalString(append(buf, 'o'), t.String())
+		var err error
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				return nil, fmt.Errorf("canonical: cannot encode %s with unexported field %s", t, t.Field(i).Name)
+			}
+			buf = appendCanonicalString(buf, t.Field(i).Name)
+			if buf, err = appendCanonical(buf, v.Field(i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, ';'), nil
+	}
+	return nil, fmt.Errorf("canonical: cannot encode %s", v.Type())
+}
+
+// appendCanonicalString appends the length-prefixed string
+func appendCanonicalString(buf []byte, s string) []byte {
+	buf = append(strconv.AppendInt(buf, int64(len(s)), 10), ':')
+	return append(buf, s...)
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Any determines whether an element of collection equals value.
+// Numbers are compared by their value, e.g. int 1 equals float64 1.
+//
+//	any(r.sub.groups, "admins")
+func Any(collection, value interface{}) (bool, error) {
+	found := false
+	err := rangeCollection(collection, func(element interface{}) bool {
+		found = equal(element, value)
+		return !found
+	})
+	return found, err
+}
+
+// All determines whether all elements of collection equal value, it is true for empty collections
+//
+//	all(r.items.inStock, true)
+func All(collection, value interface{}) (bool, error) {
+	res := true
+	err := rangeCollection(collection, func(element interface{}) bool {
+		res = equal(element, value)
+		return res
+	})
+	return res, err
+}
+
+// Len returns the number of elements of a slice, array or map or the length of a string
+func Len(collection interface{}) (float64, error) {
+	v := reflect.ValueOf(collection)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return float64(v.Len()), nil
+	case reflect.Invalid:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%v is not a collection", collection)
+}
+
+// Number converts a number or a numeric string to float64.
+// Policy values are strings, so they have to be converted before numeric comparisons.
+//
+//	sum(r.items.prices) < number(p.limit)
+func Number(value interface{}) (float64, error) {
+	return toFloat(value)
+}
+
+// Sum returns the sum of the numeric elements of collection
+//
+//	sum(r.items.prices) < number(p.limit)
+func Sum(collection interface{}) (float64, error) {
+	var sum float64
+	var err error
+	rangeErr := rangeCollection(collection, func(element interface{}) bool {
+		var f float64
+		f, err = toFloat(element)
+		sum += f
+		return err == nil
+	})
+	if rangeErr != nil {
+		return 0, rangeErr
+	}
+	return sum, err
+}
+
+// Min returns the smallest numeric element of collection, it fails for empty collections
+func Min(collection interface{}) (float64, error) {
+	return extreme(collection, func(a, b float64) bool { return a < b })
+}
+
+// Max returns the largest numeric element of collection, it fails for empty collections
+func Max(collection interface{}) (float64, error) {
+	return extreme(collection, func(a, b float64) bool { return a > b })
+}
+
+func extreme(collection interface{}, better func(a, b float64) bool) (float64, error) {
+	var res float64
+	var err error
+	first := true
+	rangeErr := rangeCollection(collection, func(element interface{}) bool {
+		var f float64
+		if f, err = toFloat(element); err != nil {
+			return false
+		}
+		if first || better(f, res) {
+			res, first = f, false
+		}
+		return true
+	})
+	if rangeErr != nil {
+		return 0, rangeErr
+	}
+	if err == nil && first {
+		err = fmt.Errorf("empty collection")
+	}
+	return res, err
+}
+
+// rangeCollection calls fn for every element of a slice or array or for every value of a map
+func rangeCollection(collection interface{}, fn func(element interface{}) bool) error {
+	v := reflect.ValueOf(collection)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !fn(v.Index(i).Interface()) {
+				return nil
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if !fn(iter.Value().Interface()) {
+				return nil
+			}
+		}
+	case reflect.Invalid:
+	default:
+		return fmt.Errorf("%v is not a collection", collection)
+	}
+	return nil
+}
+
+func equal(a, b interface{}) bool {
+	if fa, err := toFloat(a); err == nil {
+		if _, ok := a.(string); !ok {
+			if fb, err := toFloat(b); err == nil {
+				if _, ok := b.(string); !ok {
+					return fa == fb
+				}
+			}
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func wrapCollectionFunc(name string, nargs int, fn func(args ...interface{}) (interface{}, error)) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != nargs {
+			return nil, fmt.Errorf("%s: Expected %d arguments, but got %d", name, nargs, len(args))
+		}
+		res, err := fn(args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		return res, nil
+	}
+}
+
+// Wrappers of the collection functions, registered as any, all, len, sum, min, max and number
+var (
+	NumberFunc = wrapCollectionFunc("number", 1, func(args ...interface{}) (interface{}, error) { return Number(args[0]) })
+	AnyFunc    = wrapCollectionFunc("any", 2, func(args ...interface{}) (interface{}, error) { return Any(args[0], args[1]) })
+	AllFunc    = wrapCollectionFunc("all", 2, func(args ...interface{}) (interface{}, error) { return All(args[0], args[1]) })
+	LenFunc    = wrapCollectionFunc("len", 1, func(args ...interface{}) (interface{}, error) { return Len(args[0]) })
+	SumFunc    = wrapCollectionFunc("sum", 1, func(args ...interface{}) (interface{}, error) { return Sum(args[0]) })
+	MinFunc    = wrapCollectionFunc("min", 1, func(args ...interface{}) (interface{}, error) { return Min(args[0]) })
+	MaxFunc    = wrapCollectionFunc("max", 1, func(args ...interface{}) (interface{}, error) { return Max(args[0]) })
+)
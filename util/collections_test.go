@@ -0,0 +1,183 @@
+package util
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{1, 1.0, true},
+		{int64(2), uint32(2), true},
+		{float32(0.5), 0.5, true},
+		{1, 2, false},
+		// numeric strings are not numbers
+		{"1", 1, false},
+		{1, "1", false},
+		{"1", "1", true},
+		{"1", "1.0", false},
+		{true, true, true},
+		{true, 1, false},
+		{nil, nil, true},
+		{[]string{"a"}, []string{"a"}, true},
+	}
+	for _, test := range tests {
+		if got := equal(test.a, test.b); got != test.want {
+			t.Errorf("equal(%#v, %#v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestAnyAll(t *testing.T) {
+	tests := []struct {
+		collection, value interface{}
+		any, all          bool
+	}{
+		{[]string{"admins", "users"}, "admins", true, false},
+		{[]string{"users"}, "admins", false, false},
+		{[]interface{}{1, 1.0, int64(1)}, 1, true, true},
+		{[]interface{}{"1", 1}, 1, true, false},
+		{[2]bool{true, true}, true, true, true},
+		{map[string]interface{}{"a": "x", "b": "y"}, "y", true, false},
+		{[]string{}, "admins", false, true},
+		{nil, "admins", false, true},
+	}
+	for _, test := range tests {
+		anyRes, err := Any(test.collection, test.value)
+		if err != nil {
+			t.Fatalf("Any(%v, %v): %v", test.collection, test.value, err)
+		}
+		allRes, err := All(test.collection, test.value)
+		if err != nil {
+			t.Fatalf("All(%v, %v): %v", test.collection, test.value, err)
+		}
+		if anyRes != test.any || allRes != test.all {
+			t.Errorf("Any, All(%v, %v) = %v, %v, want %v, %v", test.collection, test.value, anyRes, allRes, test.any, test.all)
+		}
+	}
+
+	if _, err := Any("admins", "a"); err == nil {
+		t.Error("Any accepted a string as collection")
+	}
+	if _, err := All(42, 42); err == nil {
+		t.Error("All accepted a number as collection")
+	}
+}
+
+func TestLen(t *testing.T) {
+	tests := []struct {
+		collection interface{}
+		want       float64
+	}{
+		{[]int{1, 2, 3}, 3},
+		{[1]string{"a"}, 1},
+		{map[string]int{"a": 1, "b": 2}, 2},
+		{"hello", 5},
+		{"", 0},
+		{nil, 0},
+	}
+	for _, test := range tests {
+		got, err := Len(test.collection)
+		if err != nil {
+			t.Fatalf("Len(%v): %v", test.collection, err)
+		}
+		if got != test.want {
+			t.Errorf("Len(%v) = %v, want %v", test.collection, got, test.want)
+		}
+	}
+	if _, err := Len(42); err == nil {
+		t.Error("Len accepted a number")
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  float64
+	}{
+		{42, 42},
+		{int32(-3), -3},
+		{uint64(7), 7},
+		{float32(1.5), 1.5},
+		{"2.5", 2.5},
+		{" 10 ", 10},
+	}
+	for _, test := range tests {
+		got, err := Number(test.value)
+		if err != nil {
+			t.Fatalf("Number(%#v): %v", test.value, err)
+		}
+		if got != test.want {
+			t.Errorf("Number(%#v) = %v, want %v", test.value, got, test.want)
+		}
+	}
+	for _, value := range []interface{}{"abc", "", true, nil, []int{1}} {
+		if _, err := Number(value); err == nil {
+			t.Errorf("Number(%#v) accepted a value, which is not a number", value)
+		}
+	}
+}
+
+func TestSumMinMax(t *testing.T) {
+	tests := []struct {
+		collection    interface{}
+		sum, min, max float64
+	}{
+		{[]float64{1.5, 2.5, -1}, 3, -1, 2.5},
+		{[]interface{}{1, "2", int64(3)}, 6, 1, 3},
+		{map[string]int{"a": 4, "b": 2}, 6, 2, 4},
+		{[1]int{7}, 7, 7, 7},
+	}
+	for _, test := range tests {
+		sum, err := Sum(test.collection)
+		if err != nil {
+			t.Fatalf("Sum(%v): %v", test.collection, err)
+		}
+		min, err := Min(test.collection)
+		if err != nil {
+			t.Fatalf("Min(%v): %v", test.collection, err)
+		}
+		max, err := Max(test.collection)
+		if err != nil {
+			t.Fatalf("Max(%v): %v", test.collection, err)
+		}
+		if sum != test.sum || min != test.min || max != test.max {
+			t.Errorf("Sum, Min, Max(%v) = %v, %v, %v, want %v, %v, %v", test.collection, sum, min, max, test.sum, test.min, test.max)
+		}
+	}
+
+	if sum, err := Sum([]int{}); err != nil || sum != 0 {
+		t.Errorf("Sum of an empty collection = %v %v, want 0", sum, err)
+	}
+	for _, collection := range []interface{}{[]int{}, nil} {
+		if _, err := Min(collection); err == nil {
+			t.Errorf("Min(%v) succeeded for an empty collection", collection)
+		}
+		if _, err := Max(collection); err == nil {
+			t.Errorf("Max(%v) succeeded for an empty collection", collection)
+		}
+	}
+	for _, collection := range []interface{}{[]interface{}{1, "x"}, "123", 5} {
+		if _, err := Sum(collection); err == nil {
+			t.Errorf("Sum(%v) succeeded", collection)
+		}
+		if _, err := Min(collection); err == nil {
+			t.Errorf("Min(%v) succeeded", collection)
+		}
+		if _, err := Max(collection); err == nil {
+			t.Errorf("Max(%v) succeeded", collection)
+		}
+	}
+}
+
+func TestCollectionFuncs(t *testing.T) {
+	if res, err := SumFunc([]int{1, 2}); err != nil || res != 3.0 {
+		t.Errorf("SumFunc = %v %v, want 3", res, err)
+	}
+	if _, err := AnyFunc([]int{1}); err == nil {
+		t.Error("AnyFunc accepted 1 argument")
+	}
+	if _, err := MinFunc([]int{}); err == nil {
+		t.Error("MinFunc succeeded for an empty collection")
+	}
+}
@@ -0,0 +1,81 @@
+package util
+
+// WildcardMatcher treats "*" as a pattern, which matches every value
+var WildcardMatcher = NewMatcher(
+	func(str string) bool { return str == "*" },
+	func(str, pattern string) bool { return pattern == "*" },
+)
+
+// ExpandRule returns all concrete rules implied by rule.
+// vocab maps a column index of rule to the concrete values known for this column.
+// A value is expanded, if one of the matchers identifies it as pattern.
+// A pattern, which matches none of the known values, is kept, so the rule is not lost.
+// If no matchers are supplied, WildcardMatcher is used.
+//
+// Expand the wildcard action of a policy rule:
+//
+//	ExpandRule([]string{"p", "alice", "data1", "*"}, map[int][]string{3: {"read", "write"}})
+//	// [[p alice data1 read] [p alice data1 write]]
+//
+// Expand path patterns:
+//
+//	ExpandRule(rule, vocab, WildcardMatcher, PathMatcher)
+func ExpandRule(rule []string, vocab map[int][]string, matchers ...IMatcher) [][]string {
+	if len(matchers) == 0 {
+		matchers = []IMatcher{WildcardMatcher}
+	}
+
+	res := [][]string{{}}
+	for i, value := range rule {
+		values := expandValue(value, vocab[i], matchers)
+		next := make([][]string, 0, len(res)*len(values))
+		for _, prefix := range res {
+			for _, v := range values {
+				r := make([]string, len(prefix), len(rule))
+				copy(r, prefix)
+				next = append(next, append(r, v))
+			}
+		}
+		res = next
+	}
+	return res
+}
+
+// ExpandRules calls ExpandRule for every rule and removes duplicates
+func ExpandRules(rules [][]string, vocab map[int][]string, matchers ...IMatcher) [][]string {
+	seen := make(map[string]struct{})
+	res := [][]string{}
+	for _, rule := range rules {
+		for _, r := range ExpandRule(rule, vocab, matchers...) {
+			key := Hash(r)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func expandValue(value string, known []string, matchers []IMatcher) []string {
+	if len(known) == 0 {
+		return []string{value}
+	}
+	for _, matcher := range matchers {
+		if !matcher.IsPattern(value) {
+			continue
+		}
+		values := []string{}
+		for _, v := range known {
+			if matcher.Match(v, value) {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return []string{value}
+		}
+		return values
+	}
+	return []string{value}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandRule(t *testing.T) {
+	vocab := map[int][]string{
+		1: {"alice", "bob"},
+		2: {"/data/1", "/data/2", "/logs/1"},
+		3: {"read", "write"},
+	}
+	tests := []struct {
+		rule []string
+		want [][]string
+	}{
+		{[]string{"p", "alice", "/data/1", "*"}, [][]string{{"p", "alice", "/data/1", "read"}, {"p", "alice", "/data/1", "write"}}},
+		{[]string{"p", "*", "/data/*", "read"}, [][]string{
+			{"p", "alice", "/data/1", "read"}, {"p", "alice", "/data/2", "read"},
+			{"p", "bob", "/data/1", "read"}, {"p", "bob", "/data/2", "read"},
+		}},
+		// patterns matching no known value are kept
+		{[]string{"p", "alice", "/tmp/*", "read"}, [][]string{{"p", "alice", "/tmp/*", "read"}}},
+		{[]string{"p", "*", "/tmp/*", "*"}, [][]string{
+			{"p", "alice", "/tmp/*", "read"}, {"p", "alice", "/tmp/*", "write"},
+			{"p", "bob", "/tmp/*", "read"}, {"p", "bob", "/tmp/*", "write"},
+		}},
+		// values, which are no patterns, and columns without vocabulary are not expanded
+		{[]string{"p", "carol", "/data/3", "delete", "*"}, [][]string{{"p", "carol", "/data/3", "delete", "*"}}},
+	}
+	for _, test := range tests {
+		if got := ExpandRule(test.rule, vocab, WildcardMatcher, PathMatcher); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("ExpandRule(%v): %v, want %v", test.rule, got, test.want)
+		}
+	}
+
+	// WildcardMatcher is the default
+	want := [][]string{{"p", "alice", "/data/*", "read"}, {"p", "alice", "/data/*", "write"}}
+	if got := ExpandRule([]string{"p", "alice", "/data/*", "*"}, vocab); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExpandRule without matchers: %v, want %v", got, want)
+	}
+}
+
+func TestExpandRules(t *testing.T) {
+	vocab := map[int][]string{2: {"read", "write"}}
+	rules := [][]string{
+		{"p", "alice", "*"},
+		{"p", "alice", "read"},
+		{"p", "bob", "none*"},
+	}
+	want := [][]string{
+		{"p", "alice", "read"},
+		{"p", "alice", "write"},
+		{"p", "bob", "none*"},
+	}
+	matcher := NewMatcher(
+		func(str string) bool { return str == "*" || str == "none*" },
+		func(str, pattern string) bool { return pattern == "*" },
+	)
+	if got := ExpandRules(rules, vocab, matcher); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExpandRules: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"sort"
+	"strings"
+)
+
+// QuoteValue quotes a rule value according to RFC 4180, if it contains
+// a separator, quotes, line breaks or surrounding spaces
+func QuoteValue(value string) string {
+	if value == "" || !strings.ContainsAny(value, ",\"\r\n#") && strings.TrimSpace(value) == value {
+		return value
+	}
+	return "\"" + strings.ReplaceAll(value, "\"", "\"\"") + "\""
+}
+
+// FormatRule returns the canonical text form of a rule
+func FormatRule(rule []string) string {
+	values := make([]string, len(rule))
+	for i, value := range rule {
+		values[i] = QuoteValue(value)
+	}
+	return strings.Join(values, DefaultSep+" ")
+}
+
+// SortRules sorts rules by their policy type and then by the given columns.
+// Columns are indices of the rule values without the policy type.
+// Ties are resolved by comparing all values.
+// Policy types are ordered p, p2, ..., g, g2, ..., followed by all other types.
+func SortRules(rules [][]string, columns ...int) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a[0] != b[0] {
+			return lessPolicyType(a[0], b[0])
+		}
+		for _, column := range columns {
+			va, vb := value(a, column+1), value(b, column+1)
+			if va != vb {
+				return va < vb
+			}
+		}
+		for k := 1; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+func value(rule []string, i int) string {
+	if i < len(rule) {
+		return rule[i]
+	}
+	return ""
+}
+
+func policyTypeRank(key string) int {
+	switch key[0] {
+	case 'p':
+		return 0
+	case 'g':
+		return 1
+	default:
+		return 2
+	}
+}
+
+func lessPolicyType(a, b string) bool {
+	ra, rb := policyTypeRank(a), policyTypeRank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// FormatPolicy returns the canonical text form of a policy.
+// Rules are sorted with SortRules, duplicates are removed and
+// every policy type is separated by a blank line.
+//
+//	util.FormatPolicy(rules)     // sort by all values
+//	util.FormatPolicy(rules, 1)  // sort by the object column first
+func FormatPolicy(rules [][]string, columns ...int) string {
+	sorted := make([][]string, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule) > 0 && rule[0] != "" {
+			sorted = append(sorted, rule)
+		}
+	}
+	SortRules(sorted, columns...)
+
+	var sb strings.Builder
+	last := ""
+	for i, rule := range sorted {
+		line := FormatRule(rule)
+		if i > 0 {
+			if line == last {
+				continue
+			}
+			if rule[0] != sorted[i-1][0] {
+				sb.WriteString("\n")
+			}
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+		last = line
+	}
+	return sb.String()
+}
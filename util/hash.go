@@ -0,0 +1,60 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync/atomic"
+)
+
+// HashFunc computes the key, which identifies a rule in the rule maps and matcher indices.
+// Distinct rules must never have the same key.
+type HashFunc func(rule []string) string
+
+var hashFunc atomic.Value
+
+func init() {
+	hashFunc.Store(HashFunc(CanonicalHash))
+}
+
+// SetHashFunc replaces the hashing strategy used by Hash.
+// It must be called before any model is created, since existing keys are not rehashed.
+//
+//	util.SetHashFunc(util.SHA256Hash)
+func SetHashFunc(fn HashFunc) {
+	hashFunc.Store(fn)
+}
+
+// Hash returns the key of a rule, CanonicalHash is used by default
+func Hash(rule []string) string {
+	return hashFunc.Load().(HashFunc)(rule)
+}
+
+// JoinHash joins the values with DefaultSep.
+// It is the fastest strategy, but rules collide if their values contain the separator,
+// e.g. ["a,b", "c"] and ["a", "b,c"].
+func JoinHash(rule []string) string {
+	return strings.Join(rule, DefaultSep)
+}
+
+// CanonicalHash joins the values with DefaultSep and quotes values containing the separator or quotes,
+// so distinct rules never collide. The key is equal to JoinHash for rules without such values.
+func CanonicalHash(rule []string) string {
+	for _, value := range rule {
+		if QuoteValue(value) != value {
+			values := make([]string, len(rule))
+			for i, v := range rule {
+				values[i] = QuoteValue(v)
+			}
+			return strings.Join(values, DefaultSep)
+		}
+	}
+	return strings.Join(rule, DefaultSep)
+}
+
+// SHA256Hash returns the hex encoded SHA-256 hash of the canonical key.
+// The keys have a fixed length, which saves memory for long rules.
+func SHA256Hash(rule []string) string {
+	sum := sha256.Sum256([]byte(CanonicalHash(rule)))
+	return hex.EncodeToString(sum[:])
+}
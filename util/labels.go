@@ -0,0 +1,335 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Labels is the label set of an object
+type Labels map[string]string
+
+// ParseLabels parses a label set written as "key=value,key=value"
+//
+//	ParseLabels("env=staging,team=payments")
+func ParseLabels(s string) (Labels, error) {
+	labels := Labels{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("labels: invalid label %q", pair)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// ToLabels converts a request value to a label set, which may be Labels, a map of strings or a string parsed by ParseLabels
+func ToLabels(value interface{}) (Labels, bool) {
+	switch v := value.(type) {
+	case Labels:
+		return v, true
+	case map[string]string:
+		return Labels(v), true
+	case map[string]interface{}:
+		labels := make(Labels, len(v))
+		for key, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			labels[key] = s
+		}
+		return labels, true
+	case string:
+		labels, err := ParseLabels(v)
+		return labels, err == nil
+	}
+	return nil, false
+}
+
+// LabelOperator is the operator of a requirement of a label selector
+type LabelOperator int
+
+const (
+	LabelEquals LabelOperator = iota
+	LabelNotEquals
+	LabelIn
+	LabelNotIn
+	LabelExists
+	LabelNotExists
+)
+
+// LabelRequirement is a single requirement of a label selector
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Values   []string
+}
+
+// Matches returns true, if labels satisfy the requirement
+func (req LabelRequirement) Matches(labels Labels) bool {
+	value, ok := labels[req.Key]
+	switch req.Operator {
+	case LabelEquals, LabelIn:
+		return ok && req.has(value)
+	case LabelNotEquals, LabelNotIn:
+		return !ok || !req.has(value)
+	case LabelExists:
+		return ok
+	case LabelNotExists:
+		return !ok
+	}
+	return false
+}
+
+func (req LabelRequirement) has(value string) bool {
+	for _, v := range req.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelSelector selects objects by their labels, all requirements must be satisfied
+type LabelSelector []LabelRequirement
+
+// Matches returns true, if labels satisfy all requirements of the selector
+func (sel LabelSelector) Matches(labels Labels) bool {
+	for _, req := range sel {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLabelSelector returns true, if s is written as labels(...)
+func IsLabelSelector(s string) bool {
+	return strings.HasPrefix(s, "labels(") && strings.HasSuffix(s, ")")
+}
+
+// ParseSelector parses a label selector in the Kubernetes syntax, optionally written as labels(...).
+// The requirements are separated by commas and written as key=value, key==value, key!=value,
+// key in (a, b), key notin (a, b), key or !key.
+//
+//	ParseSelector("labels(env=staging,team in (payments, billing),!legacy)")
+func ParseSelector(s string) (LabelSelector, error) {
+	s = strings.TrimSpace(s)
+	if IsLabelSelector(s) {
+		s = s[len("labels(") : len(s)-1]
+	}
+	sel := LabelSelector{}
+	for _, part := range splitRequirements(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// splitRequirements splits s at the commas outside of parentheses
+func splitRequirements(s string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseRequirement(s string) (LabelRequirement, error) {
+	if strings.HasPrefix(s, "!") {
+		key := strings.TrimSpace(s[1:])
+		if key == "" {
+			return LabelRequirement{}, errors.New("labels: missing key after !")
+		}
+		return LabelRequirement{Key: key, Operator: LabelNotExists}, nil
+	}
+	if fields := strings.Fields(s); len(fields) > 2 && (fields[1] == "in" || fields[1] == "notin") {
+		return parseSetRequirement(s, fields)
+	}
+	for _, op := range []struct {
+		token    string
+		operator LabelOperator
+	}{{"!=", LabelNotEquals}, {"==", LabelEquals}, {"=", LabelEquals}} {
+		if key, value, ok := strings.Cut(s, op.token); ok {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return LabelRequirement{}, fmt.Errorf("labels: missing key in %q", s)
+			}
+			return LabelRequirement{Key: key, Operator: op.operator, Values: []string{strings.TrimSpace(value)}}, nil
+		}
+	}
+	if fields := strings.Fields(s); len(fields) == 1 {
+		return LabelRequirement{Key: fields[0], Operator: LabelExists}, nil
+	}
+	return LabelRequirement{}, fmt.Errorf("labels: invalid requirement %q", s)
+}
+
+// parseSetRequirement parses "key in (a, b)" and "key notin (a, b)"
+func parseSetRequirement(s string, fields []string) (LabelRequirement, error) {
+	operator := LabelIn
+	if fields[1] == "notin" {
+		operator = LabelNotIn
+	}
+	rest := strings.TrimSpace(s[strings.Index(s, fields[1])+len(fields[1]):])
+	if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+		return LabelRequirement{}, fmt.Errorf("labels: values of %q must be in parentheses", s)
+	}
+	values := []string{}
+	for _, value := range strings.Split(rest[1:len(rest)-1], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return LabelRequirement{Key: fields[0], Operator: operator, Values: values}, nil
+}
+
+var selectorCache = NewShardedLRUCache(100)
+
+// getSelector returns the parsed selector from the cache
+func getSelector(s string) (LabelSelector, error) {
+	if value, ok := selectorCache.Get(s); ok {
+		return value.(LabelSelector), nil
+	}
+	sel, err := ParseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	selectorCache.Put(s, sel)
+	return sel, nil
+}
+
+// LabelMatch returns true, if the labels of an object are selected by selector
+//
+//	LabelMatch(Labels{"env": "staging", "team": "payments"}, "labels(env=staging,team=payments)") // true
+func LabelMatch(labels Labels, selector string) (bool, error) {
+	sel, err := getSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(labels), nil
+}
+
+// LabelMatchFunc is the wrapper for LabelMatch, the first argument is converted by ToLabels
+func LabelMatchFunc(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("%s: expected 2 arguments, but got %d", "labelMatch", len(args))
+	}
+	labels, ok := ToLabels(args[0])
+	if !ok {
+		return false, fmt.Errorf("%s: %v is not a label set", "labelMatch", args[0])
+	}
+	selector, ok := args[1].(string)
+	if !ok {
+		return false, fmt.Errorf("%s: %v is not a label selector", "labelMatch", args[1])
+	}
+	matched, err := LabelMatch(labels, selector)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", "labelMatch", err)
+	}
+	return matched, nil
+}
+
+// LabelIndex is an inverted index from labels to the keys of selectors.
+// A selector is indexed by the values of its first equality or set requirement, so only selectors,
+// which require a label of an object, and selectors without such a requirement are candidates for the object.
+type LabelIndex struct {
+	labels map[string]map[string]struct{}
+	scan   map[string]struct{}
+}
+
+func NewLabelIndex() *LabelIndex {
+	return &LabelIndex{labels: make(map[string]map[string]struct{}), scan: make(map[string]struct{})}
+}
+
+// indexed returns the label pairs, under which the selector is indexed, or nil, if it has to be scanned
+func indexed(selector string) []string {
+	sel, err := getSelector(selector)
+	if err != nil {
+		return nil
+	}
+	for _, req := range sel {
+		if req.Operator != LabelEquals && req.Operator != LabelIn {
+			continue
+		}
+		pairs := make([]string, len(req.Values))
+		for i, value := range req.Values {
+			pairs[i] = req.Key + "=" + value
+		}
+		return pairs
+	}
+	return nil
+}
+
+// Insert adds the key of a selector
+func (idx *LabelIndex) Insert(selector, key string) {
+	pairs := indexed(selector)
+	if pairs == nil {
+		idx.scan[key] = struct{}{}
+		return
+	}
+	for _, pair := range pairs {
+		keys, ok := idx.labels[pair]
+		if !ok {
+			keys = make(map[string]struct{})
+			idx.labels[pair] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// Remove removes the key of a selector
+func (idx *LabelIndex) Remove(selector, key string) {
+	pairs := indexed(selector)
+	if pairs == nil {
+		delete(idx.scan, key)
+		return
+	}
+	for _, pair := range pairs {
+		if keys, ok := idx.labels[pair]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(idx.labels, pair)
+			}
+		}
+	}
+}
+
+// Candidates calls fn with the keys of all selectors, which may select labels, until fn returns false
+func (idx *LabelIndex) Candidates(labels Labels, fn func(key string) bool) {
+	for key := range idx.scan {
+		if !fn(key) {
+			return
+		}
+	}
+	for name, value := range labels {
+		for key := range idx.labels[name+"="+value] {
+			if !fn(key) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     LabelSelector
+	}{
+		{"env=staging", LabelSelector{{"env", LabelEquals, []string{"staging"}}}},
+		{"labels(env==staging)", LabelSelector{{"env", LabelEquals, []string{"staging"}}}},
+		{"env != staging", LabelSelector{{"env", LabelNotEquals, []string{"staging"}}}},
+		{"env in (staging, prod)", LabelSelector{{"env", LabelIn, []string{"staging", "prod"}}}},
+		{"env notin (staging,prod)", LabelSelector{{"env", LabelNotIn, []string{"staging", "prod"}}}},
+		{"legacy", LabelSelector{{"legacy", LabelExists, nil}}},
+		{"!legacy", LabelSelector{{"legacy", LabelNotExists, nil}}},
+		{"labels(env in (staging, prod), team=payments, !legacy)", LabelSelector{
+			{"env", LabelIn, []string{"staging", "prod"}},
+			{"team", LabelEquals, []string{"payments"}},
+			{"legacy", LabelNotExists, nil},
+		}},
+		{"labels()", LabelSelector{}},
+	}
+	for _, test := range tests {
+		got, err := ParseSelector(test.selector)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("ParseSelector(%q): %v %v, want %v", test.selector, got, err, test.want)
+		}
+	}
+
+	for _, selector := range []string{"!", "=staging", "env staging", "env in staging"} {
+		if _, err := ParseSelector(selector); err == nil {
+			t.Fatalf("ParseSelector(%q): nil error", selector)
+		}
+	}
+}
+
+// labelSelectors covers every operator, as first requirement indexed by the label index and as later requirement
+var labelSelectors = []string{
+	"labels(env=staging)",
+	"labels(env==prod)",
+	"labels(env!=staging)",
+	"labels(env in (staging, prod))",
+	"labels(env notin (staging, prod))",
+	"labels(env)",
+	"labels(!env)",
+	"labels(env in ())",
+	"labels(env=staging,team=payments)",
+	"labels(team=payments,env in (staging, prod))",
+	"labels(env!=prod,team in (payments, billing))",
+	"labels(!legacy,env=staging)",
+	"labels(legacy,team notin (billing))",
+	"labels(env=)",
+	"labels()",
+	"labels(env=staging,!)",
+}
+
+var labelSets = []Labels{
+	{},
+	{"env": "staging"},
+	{"env": "prod"},
+	{"env": ""},
+	{"env": "dev"},
+	{"team": "payments"},
+	{"env": "staging", "team": "payments"},
+	{"env": "prod", "team": "billing"},
+	{"env": "staging", "team": "billing", "legacy": "true"},
+	{"legacy": ""},
+}
+
+// matching returns the selectors, which select labels, evaluated without the label index
+func matching(selectors []string, labels Labels) []string {
+	res := []string{}
+	for _, selector := range selectors {
+		if ok, err := LabelMatch(labels, selector); ok || err != nil {
+			res = append(res, selector)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestLabelIndex(t *testing.T) {
+	// the selectors are their own keys, invalid selectors are kept, their evaluation fails
+	idx := NewLabelIndex()
+	for _, selector := range labelSelectors {
+		idx.Insert(selector, selector)
+	}
+	check := func(state string, selectors []string) {
+		t.Helper()
+		for _, labels := range labelSets {
+			keys := []string{}
+			idx.Candidates(labels, func(key string) bool {
+				keys = append(keys, key)
+				return true
+			})
+			if got, want := matching(keys, labels), matching(selectors, labels); !reflect.DeepEqual(got, want) {
+				t.Fatalf("selected candidates of %v %s: %v, want %v", labels, state, got, want)
+			}
+		}
+	}
+	check("after Insert", labelSelectors)
+
+	remaining := []string{}
+	for i, selector := range labelSelectors {
+		if i%2 == 0 {
+			idx.Remove(selector, selector)
+		} else {
+			remaining = append(remaining, selector)
+		}
+	}
+	check("after removing every second selector", remaining)
+
+	// removing a selector twice or a selector, which has not been inserted, does not change the index
+	idx.Remove(labelSelectors[0], labelSelectors[0])
+	idx.Remove("labels(env=dev)", "labels(env=dev)")
+	check("after removing missing selectors", remaining)
+
+	for _, selector := range remaining {
+		idx.Remove(selector, selector)
+	}
+	if len(idx.labels) != 0 || len(idx.scan) != 0 {
+		t.Fatalf("label index after removing all selectors: %v %v, want empty", idx.labels, idx.scan)
+	}
+	check("after removing all selectors", nil)
+}
+
+func TestLabelIndexCandidates(t *testing.T) {
+	idx := NewLabelIndex()
+	idx.Insert("labels(env=staging,team=payments)", "a")
+	idx.Insert("labels(env in (staging, prod))", "b")
+	idx.Insert("labels(!legacy)", "c")
+	idx.Insert("labels(env!=staging)", "d")
+
+	tests := []struct {
+		labels Labels
+		want   []string
+	}{
+		// selectors without equality or set requirement are always candidates
+		{Labels{}, []string{"c", "d"}},
+		{Labels{"env": "staging"}, []string{"a", "b", "c", "d"}},
+		{Labels{"env": "prod", "team": "payments"}, []string{"b", "c", "d"}},
+		{Labels{"team": "payments"}, []string{"c", "d"}},
+	}
+	for _, test := range tests {
+		got := []string{}
+		idx.Candidates(test.labels, func(key string) bool {
+			got = append(got, key)
+			return true
+		})
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("Candidates(%v): %v, want %v", test.labels, got, test.want)
+		}
+	}
+
+	// returning false stops the iteration
+	n := 0
+	idx.Candidates(Labels{"env": "staging"}, func(key string) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("Candidates stopped after %d keys, want 1", n)
+	}
+}
+
+func TestLabelMatchFunc(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want bool
+		err  string
+	}{
+		{[]interface{}{Labels{"env": "staging"}, "labels(env=staging)"}, true, ""},
+		{[]interface{}{map[string]string{"env": "prod"}, "labels(env=staging)"}, false, ""},
+		{[]interface{}{map[string]interface{}{"env": "staging"}, "env in (staging)"}, true, ""},
+		{[]interface{}{"env=staging, team=payments", "labels(team=payments)"}, true, ""},
+		{[]interface{}{map[string]interface{}{"env": 1}, "labels(env)"}, false, "is not a label set"},
+		{[]interface{}{Labels{}, 1}, false, "is not a label selector"},
+		{[]interface{}{Labels{}, "labels(!)"}, false, "missing key"},
+		{[]interface{}{Labels{}}, false, "expected 2 arguments"},
+	}
+	for _, test := range tests {
+		got, err := LabelMatchFunc(test.args...)
+		if test.err == "" && (err != nil || got != test.want) || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Fatalf("LabelMatchFunc(%v): %v %v, want %v %s", test.args, got, err, test.want, test.err)
+		}
+	}
+}
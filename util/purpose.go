@@ -0,0 +1,36 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PurposeMatch determines whether the purpose of a request is covered by the purposes of a rule.
+// Purposes are hierarchical and separated by dots, "marketing" covers "marketing.email".
+// The rule may list several purposes separated by "|", "*" covers all purposes.
+//
+//	PurposeMatch("marketing.email", "analytics|marketing") // true
+func PurposeMatch(purpose string, purposes string) bool {
+	if purpose == "" {
+		return false
+	}
+	for _, p := range strings.Split(purposes, "|") {
+		p = strings.TrimSpace(p)
+		if p == "*" || p == purpose || strings.HasPrefix(purpose, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// PurposeMatchFunc is the wrapper for PurposeMatch.
+func PurposeMatchFunc(args ...interface{}) (interface{}, error) {
+	if err := ValidateVariadicArgs(2, args...); err != nil {
+		return false, fmt.Errorf("%s: %s", "purposeMatch", err)
+	}
+
+	purpose, _ := args[0].(string)
+	purposes, _ := args[1].(string)
+
+	return PurposeMatch(purpose, purposes), nil
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oarkflow/govaluate"
+)
+
+// RegexOptions restricts the patterns and inputs of SafeRegex, zero values disable a limit
+type RegexOptions struct {
+	// MaxPatternLength is the maximum length of a pattern
+	MaxPatternLength int
+	// MaxProgramSize is the maximum number of instructions of a compiled pattern
+	MaxProgramSize int
+	// MaxInputLength is the maximum length of a matched string
+	MaxInputLength int
+	// Timeout is the maximum duration of a single evaluation
+	Timeout time.Duration
+}
+
+// DefaultRegexOptions are the recommended limits for untrusted patterns and inputs
+var DefaultRegexOptions = RegexOptions{
+	MaxPatternLength: 1024,
+	MaxProgramSize:   2048,
+	MaxInputLength:   4096,
+	Timeout:          10 * time.Millisecond,
+}
+
+// SafeRegex matches regular expressions with RE2 semantics and enforces RegexOptions.
+// Compiled patterns are cached.
+type SafeRegex struct {
+	opts  RegexOptions
+	cache Cache
+}
+
+func NewSafeRegex(opts RegexOptions) *SafeRegex {
+	return &SafeRegex{opts, NewShardedLRUCache(100)}
+}
+
+// CheckPattern returns an error, if the pattern is invalid or too complex
+func (r *SafeRegex) CheckPattern(pattern string) error {
+	_, err := r.compile(pattern)
+	return err
+}
+
+func (r *SafeRegex) compile(pattern string) (*regexp.Regexp, error) {
+	if v, ok := r.cache.Get(pattern); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	if r.opts.MaxPatternLength > 0 && len(pattern) > r.opts.MaxPatternLength {
+		return nil, fmt.Errorf("regex: pattern length %d exceeds %d", len(pattern), r.opts.MaxPatternLength)
+	}
+	if r.opts.MaxProgramSize > 0 {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			return nil, err
+		}
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			return nil, err
+		}
+		if len(prog.Inst) > r.opts.MaxProgramSize {
+			return nil, fmt.Errorf("regex: pattern %q is too complex (%d > %d instructions)", pattern, len(prog.Inst), r.opts.MaxProgramSize)
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.Put(pattern, re)
+	return re, nil
+}
+
+// Match determines whether str matches pattern
+func (r *SafeRegex) Match(str, pattern string) (bool, error) {
+	return r.MatchContext(context.Background(), str, pattern)
+}
+
+// MatchContext determines whether str matches pattern, the evaluation stops, when ctx is done or the timeout has passed
+func (r *SafeRegex) MatchContext(ctx context.Context, str, pattern string) (bool, error) {
+	if r.opts.MaxInputLength > 0 && len(str) > r.opts.MaxInputLength {
+		return false, fmt.Errorf("regex: input length %d exceeds %d", len(str), r.opts.MaxInputLength)
+	}
+	re, err := r.compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	if r.opts.Timeout <= 0 && ctx.Done() == nil {
+		return re.MatchString(str), nil
+	}
+
+	// the input is read by the evaluation, which ends early, when the reader stops
+	reader := &deadlineReader{str: str, ctx: ctx}
+	if r.opts.Timeout > 0 {
+		reader.deadline = time.Now().Add(r.opts.Timeout)
+	}
+	matched := re.MatchReader(reader)
+	if reader.err != nil {
+		return false, fmt.Errorf("regex: evaluation of %q stopped: %w", pattern, reader.err)
+	}
+	return matched, nil
+}
+
+// ErrRegexTimeout is returned, if the evaluation of a pattern exceeds the timeout of the RegexOptions
+var ErrRegexTimeout = errors.New("regex: timeout exceeded")
+
+// deadlineReader reads the runes of str, until ctx is done or the deadline has passed
+type deadlineReader struct {
+	str      string
+	pos      int
+	reads    int
+	ctx      context.Context
+	deadline time.Time
+	err      error
+}
+
+func (r *deadlineReader) ReadRune() (rune, int, error) {
+	// the clock is read every 256 runes, matching a rune takes at most MaxProgramSize steps
+	if r.reads&255 == 0 {
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+		} else if !r.deadline.IsZero() && time.Now().After(r.deadline) {
+			r.err = ErrRegexTimeout
+		}
+	}
+	r.reads++
+	if r.err != nil || r.pos >= len(r.str) {
+		return 0, 0, io.EOF
+	}
+	c, size := utf8.DecodeRuneInString(r.str[r.pos:])
+	r.pos += size
+	return c, size, nil
+}
+
+// Func returns Match as a function, which can replace regexMatch in a matcher
+func (r *SafeRegex) Func() govaluate.ExpressionFunction {
+	return r.FuncContext(context.Background())
+}
+
+// FuncContext returns MatchContext with ctx as a function, which can replace regexMatch in a matcher
+func (r *SafeRegex) FuncContext(ctx context.Context) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if err := ValidateVariadicArgs(2, args...); err != nil {
+			return false, fmt.Errorf("%s: %s", "regexMatch", err)
+		}
+		return r.MatchContext(ctx, args[0].(string), args[1].(string))
+	}
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeRegexMatch(t *testing.T) {
+	tests := []struct {
+		str, pattern string
+		want         bool
+	}{
+		{"/data/1", "^/data/[0-9]+$", true},
+		{"/data/x", "^/data/[0-9]+$", false},
+		{"héllo", "^h.llo$", true},
+		{"héllo wörld", "wö", true},
+		{"", "^$", true},
+	}
+	// the reader of the timeout is used for a non-zero timeout only
+	for _, timeout := range []time.Duration{0, time.Second} {
+		r := NewSafeRegex(RegexOptions{Timeout: timeout})
+		for _, test := range tests {
+			got, err := r.Match(test.str, test.pattern)
+			if err != nil || got != test.want {
+				t.Fatalf("Match(%q, %q) with timeout %v: %v %v, want %v", test.str, test.pattern, timeout, got, err, test.want)
+			}
+		}
+	}
+}
+
+func TestSafeRegexLimits(t *testing.T) {
+	r := NewSafeRegex(RegexOptions{MaxPatternLength: 16, MaxProgramSize: 64, MaxInputLength: 8})
+	tests := []struct {
+		str, pattern string
+		err          string
+	}{
+		{"abc", "^abc$", ""},
+		{"abc", strings.Repeat("a", 17), "pattern length 17 exceeds 16"},
+		{"abc", "(abc|def){5,9}", "is too complex (87 > 64 instructions)"},
+		{"abcdefghi", "^abc", "input length 9 exceeds 8"},
+		{"abc", "(abc", "missing closing )"},
+	}
+	for _, test := range tests {
+		_, err := r.Match(test.str, test.pattern)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Fatalf("Match(%q, %q): %v, want %q", test.str, test.pattern, err, test.err)
+		}
+		if strings.HasPrefix(test.err, "input") {
+			continue
+		}
+		if err := r.CheckPattern(test.pattern); test.err == "" && err != nil || test.err != "" && err == nil {
+			t.Fatalf("CheckPattern(%q): %v, want %q", test.pattern, err, test.err)
+		}
+	}
+
+	// zero values disable the limits
+	r = NewSafeRegex(RegexOptions{})
+	if _, err := r.Match(strings.Repeat("a", 10000), "(a|b|c){1,9}"+strings.Repeat("x?", 100)); err != nil {
+		t.Fatalf("Match without limits: %v", err)
+	}
+}
+
+func TestSafeRegexTimeout(t *testing.T) {
+	r := NewSafeRegex(RegexOptions{Timeout: time.Nanosecond})
+	_, err := r.Match(strings.Repeat("a", 100000), "b")
+	if !errors.Is(err, ErrRegexTimeout) {
+		t.Fatalf("Match with exceeded timeout: %v, want %v", err, ErrRegexTimeout)
+	}
+}
+
+func TestSafeRegexContext(t *testing.T) {
+	r := NewSafeRegex(RegexOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	if ok, err := r.MatchContext(ctx, "abc", "b"); err != nil || !ok {
+		t.Fatalf("MatchContext before cancel: %v %v, want true", ok, err)
+	}
+	cancel()
+	if _, err := r.MatchContext(ctx, "abc", "b"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("MatchContext after cancel: %v, want %v", err, context.Canceled)
+	}
+	if _, err := r.FuncContext(ctx)("abc", "b"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("FuncContext after cancel: %v, want %v", err, context.Canceled)
+	}
+
+	if ok, err := r.Func()("abc", "b"); err != nil || ok != true {
+		t.Fatalf("Func: %v %v, want true", ok, err)
+	}
+	if _, err := r.Func()("abc"); err == nil {
+		t.Fatal("Func with one argument: nil error")
+	}
+}
@@ -0,0 +1,263 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          []string
+}
+
+// ParseVersion parses a semantic version like "1.2.3", "v1.2" or "1.2.3-beta.1+build".
+// Missing minor and patch versions are 0, build metadata is ignored.
+func ParseVersion(str string) (Version, error) {
+	v := Version{}
+	s := strings.TrimPrefix(strings.TrimSpace(str), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.Prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || parts[0] == "" {
+		return v, fmt.Errorf("invalid version %q", str)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", str)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1, if v is lower, equal or greater than other.
+// Prereleases are lower than their release, e.g. 1.0.0-rc.1 < 1.0.0.
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		a, b := v.Prerelease[i], other.Prerelease[i]
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return sign(na - nb)
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(v.Prerelease) - len(other.Prerelease))
+}
+
+func sign(d int) int {
+	switch {
+	case d < 0:
+		return -1
+	case d > 0:
+		return 1
+	}
+	return 0
+}
+
+// SemverMatch determines whether version satisfies the constraint.
+// Comparators separated by spaces must all match, alternatives are separated by "||".
+// Supported comparators are =, !=, >, >=, <, <=, ~, ^ and *. Like npm, ~ allows patch changes, if a minor version is given,
+// else minor changes, e.g. ~1.2.3 is >=1.2.3 <1.3.0 and ~1 is >=1.0.0 <2.0.0,
+// and ^ allows changes, which keep the left-most non-zero part, e.g. ^1.2.3 is <2.0.0, ^0.2.3 <0.3.0 and ^0.0.3 <0.0.4.
+// Unlike npm, prereleases are not excluded from ranges, they are ordered by Version.Compare.
+//
+//	SemverMatch("1.4.2", ">=1.2.0 <2.0.0") // true
+//	SemverMatch("2.1.0", "^1.2 || ~2.1")   // true
+func SemverMatch(version string, constraint string) (bool, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	for _, alternative := range strings.Split(constraint, "||") {
+		matched := true
+		for _, comparator := range strings.Fields(alternative) {
+			ok, err := matchComparator(v, comparator)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchComparator(v Version, comparator string) (bool, error) {
+	if comparator == "*" || comparator == "x" {
+		return true, nil
+	}
+	op := strings.TrimRight(comparator, "v0123456789.-+abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	other, err := ParseVersion(comparator[len(op):])
+	if err != nil {
+		return false, err
+	}
+	c := v.Compare(other)
+	switch op {
+	case "", "=", "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case "~", "^":
+		upper := other.next(fixedPart(op, other, versionParts(comparator[len(op):])))
+		release := Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+		return c >= 0 && release.Compare(upper) < 0, nil
+	}
+	return false, fmt.Errorf("invalid version comparator %q", comparator)
+}
+
+// versionParts returns the number of the given parts of a version, e.g. 2 for "1.2"
+func versionParts(str string) int {
+	s := strings.TrimPrefix(strings.TrimSpace(str), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Count(s, ".") + 1
+}
+
+// fixedPart returns the index of the last part of the version, which a ~ or ^ range keeps
+func fixedPart(op string, v Version, parts int) int {
+	if op == "~" {
+		if parts == 1 {
+			return 0
+		}
+		return 1
+	}
+	switch {
+	case v.Major != 0 || parts == 1:
+		return 0
+	case v.Minor != 0 || parts == 2:
+		return 1
+	}
+	return 2
+}
+
+// next returns the lowest version, which changes the part at index, e.g. 1.3.0 for 1.2.3 and 1
+func (v Version) next(index int) Version {
+	switch index {
+	case 0:
+		return Version{Major: v.Major + 1}
+	case 1:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// SemverMatchFunc is the wrapper for SemverMatch
+func SemverMatchFunc(args ...interface{}) (interface{}, error) {
+	if err := ValidateVariadicArgs(2, args...); err != nil {
+		return false, fmt.Errorf("%s: %s", "semverMatch", err)
+	}
+	return SemverMatch(args[0].(string), args[1].(string))
+}
+
+// InRange determines whether min <= val <= max. Values may be numbers or numeric strings,
+// an empty string as min or max disables the bound.
+//
+//	InRange(20, "10", "50") // true
+func InRange(val, min, max interface{}) (bool, error) {
+	v, err := toFloat(val)
+	if err != nil {
+		return false, err
+	}
+	if s, ok := min.(string); !ok || s != "" {
+		lower, err := toFloat(min)
+		if err != nil {
+			return false, err
+		}
+		if v < lower {
+			return false, nil
+		}
+	}
+	if s, ok := max.(string); !ok || s != "" {
+		upper, err := toFloat(max)
+		if err != nil {
+			return false, err
+		}
+		if v > upper {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// InRangeFunc is the wrapper for InRange
+func InRangeFunc(args ...interface{}) (interface{}, error) {
+	if len(args) != 3 {
+		return false, fmt.Errorf("%s: Expected %d arguments, but got %d", "inRange", 3, len(args))
+	}
+	ok, err := InRange(args[0], args[1], args[2])
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", "inRange", err)
+	}
+	return ok, nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("%v is not a number", value)
+}
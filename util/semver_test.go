@@ -0,0 +1,117 @@
+package util
+
+import "testing"
+
+func TestSemverMatch(t *testing.T) {
+	tests := []struct {
+		version, constraint string
+		want                bool
+	}{
+		{"1.4.2", ">=1.2.0 <2.0.0", true},
+		{"2.0.0", ">=1.2.0 <2.0.0", false},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "=1.2.4", false},
+		{"1.2.3", "!=1.2.4", true},
+		{"1.2.3", ">1.2.3", false},
+		{"1.2.3", "<=1.2.3", true},
+		{"v1.2.3", "*", true},
+		{"2.1.0", "^1.2 || ~2.1", true},
+		{"3.0.0", "^1.2 || ~2.1", false},
+
+		// ~ allows patch changes, if a minor version is given, else minor changes
+		{"1.2.3", "~1.2.3", true},
+		{"1.2.9", "~1.2.3", true},
+		{"1.2.2", "~1.2.3", false},
+		{"1.3.0", "~1.2.3", false},
+		{"1.2.0", "~1.2", true},
+		{"1.3.0", "~1.2", false},
+		{"1.0.0", "~1", true},
+		{"1.9.9", "~1", true},
+		{"2.0.0", "~1", false},
+		{"0.2.5", "~0.2.3", true},
+		{"0.3.0", "~0.2.3", false},
+
+		// ^ allows changes, which keep the left-most non-zero part
+		{"1.9.0", "^1.2.3", true},
+		{"1.2.2", "^1.2.3", false},
+		{"2.0.0", "^1.2.3", false},
+		{"0.2.9", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+		{"0.0.9", "^0.0", true},
+		{"0.1.0", "^0.0", false},
+		{"0.9.0", "^0", true},
+		{"1.0.0", "^0", false},
+		{"1.9.0", "^1", true},
+
+		// prereleases are lower than their release
+		{"1.0.0-rc.1", "<1.0.0", true},
+		{"1.0.0-rc.2", ">1.0.0-rc.1", true},
+		{"1.0.0-rc.10", ">1.0.0-rc.2", true},
+		{"1.0.0-beta", "<1.0.0-rc", true},
+		{"2.0.0-rc.1", "^1.2.3", false},
+		{"1.2.3+build.5", "1.2.3", true},
+	}
+	for _, test := range tests {
+		got, err := SemverMatch(test.version, test.constraint)
+		if err != nil {
+			t.Fatalf("SemverMatch(%q, %q): %v", test.version, test.constraint, err)
+		}
+		if got != test.want {
+			t.Errorf("SemverMatch(%q, %q) = %v, want %v", test.version, test.constraint, got, test.want)
+		}
+	}
+
+	for _, test := range []struct{ version, constraint string }{
+		{"1.2.x", ">1.0.0"},
+		{"1.2.3", "=>1.0.0"},
+		{"1.2.3", ">=1.a"},
+		{"1.2.3.4", "*"},
+	} {
+		if _, err := SemverMatch(test.version, test.constraint); err == nil {
+			t.Errorf("SemverMatch(%q, %q) accepted an invalid version", test.version, test.constraint)
+		}
+	}
+}
+
+func TestInRange(t *testing.T) {
+	tests := []struct {
+		val, min, max interface{}
+		want          bool
+	}{
+		{20, "10", "50", true},
+		{10, 10, 50, true},
+		{50, 10.0, int64(50), true},
+		{9, 10, 50, false},
+		{51, "10", "50", false},
+		{"20.5", " 20.5 ", "", true},
+		{1000, "", "50", false},
+		{-1000, "", "50", true},
+		{uint32(7), "", "", true},
+		{float32(1.5), 1, 2, true},
+	}
+	for _, test := range tests {
+		got, err := InRange(test.val, test.min, test.max)
+		if err != nil {
+			t.Fatalf("InRange(%v, %v, %v): %v", test.val, test.min, test.max, err)
+		}
+		if got != test.want {
+			t.Errorf("InRange(%v, %v, %v) = %v, want %v", test.val, test.min, test.max, got, test.want)
+		}
+	}
+
+	for _, test := range [][]interface{}{
+		{"abc", 1, 2},
+		{1, "low", 2},
+		{1, 0, true},
+		{"", 0, 1},
+	} {
+		if _, err := InRange(test[0], test[1], test[2]); err == nil {
+			t.Errorf("InRange(%v, %v, %v) accepted a value, which is not a number", test[0], test[1], test[2])
+		}
+	}
+	if _, err := InRangeFunc(1, 2); err == nil {
+		t.Error("InRangeFunc accepted 2 arguments")
+	}
+}
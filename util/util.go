@@ -25,7 +25,3 @@ func FileExists(path string) (bool, error) {
 	}
 	return false, err
 }
-
-func Hash(rule []string) string {
-	return strings.Join(rule, DefaultSep)
-}
@@ -0,0 +1,167 @@
+package fastac
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/oarkflow/fastac/str"
+	"github.com/oarkflow/fastac/util"
+)
+
+// Vocabulary is a registry of the valid values of request and policy arguments.
+// Arguments are identified by their name in the model, e.g. "act" for "p = sub, obj, act".
+// Arguments without registered values are not restricted.
+// A vocabulary is safe for concurrent use, values can be registered while enforcers and their clones check requests.
+type Vocabulary struct {
+	mutex    sync.RWMutex
+	terms    map[string]map[string]struct{}
+	matchers []util.IMatcher
+}
+
+// NewVocabulary creates an empty vocabulary.
+// Values which are identified as pattern by one of the matchers are always accepted,
+// if no matchers are supplied, util.WildcardMatcher is used.
+func NewVocabulary(matchers ...util.IMatcher) *Vocabulary {
+	if len(matchers) == 0 {
+		matchers = []util.IMatcher{util.WildcardMatcher}
+	}
+	return &Vocabulary{
+		terms:    make(map[string]map[string]struct{}),
+		matchers: matchers,
+	}
+}
+
+// Register adds values to the vocabulary of an argument
+//
+//	v.Register("act", "read", "write")
+func (v *Vocabulary) Register(arg string, values ...string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	terms, ok := v.terms[arg]
+	if !ok {
+		terms = make(map[string]struct{})
+		v.terms[arg] = terms
+	}
+	for _, value := range values {
+		terms[value] = struct{}{}
+	}
+}
+
+// Unregister removes values from the vocabulary of an argument
+func (v *Vocabulary) Unregister(arg string, values ...string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	terms, ok := v.terms[arg]
+	if !ok {
+		return
+	}
+	for _, value := range values {
+		delete(terms, value)
+	}
+	if len(terms) == 0 {
+		delete(v.terms, arg)
+	}
+}
+
+// Args returns the sorted names of all registered arguments
+func (v *Vocabulary) Args() []string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	args := make([]string, 0, len(v.terms))
+	for arg := range v.terms {
+		args = append(args, arg)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// Values returns the sorted values of an argument
+func (v *Vocabulary) Values(arg string) []string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.values(arg)
+}
+
+func (v *Vocabulary) values(arg string) []string {
+	values := make([]string, 0, len(v.terms[arg]))
+	for value := range v.terms[arg] {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// Has returns true, if value is a valid value of arg, patterns are valid values of every argument
+func (v *Vocabulary) Has(arg, value string) bool {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.has(arg, value, true)
+}
+
+func (v *Vocabulary) has(arg, value string, patterns bool) bool {
+	terms, ok := v.terms[arg]
+	if !ok {
+		return true
+	}
+	if _, ok := terms[value]; ok {
+		return true
+	}
+	if !patterns {
+		return false
+	}
+	for _, matcher := range v.matchers {
+		if matcher.IsPattern(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error, if one of the values of a rule is not part of the vocabulary, patterns are accepted.
+// values[i] belongs to args[i], strip the key of a rule before checking it. Missing trailing values are not checked.
+func (v *Vocabulary) Check(args []string, values []interface{}) error {
+	return v.check(args, values, true)
+}
+
+// CheckRequest is like Check, but the values of a request are concrete values, patterns are rejected like other unknown values
+func (v *Vocabulary) CheckRequest(args []string, values []interface{}) error {
+	return v.check(args, values, false)
+}
+
+func (v *Vocabulary) check(args []string, values []interface{}, patterns bool) error {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	for i, arg := range args {
+		if i >= len(values) {
+			break
+		}
+		value, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		if !v.has(arg, value, patterns) {
+			return fmt.Errorf(str.ERR_UNKNOWN_TERM, value, arg)
+		}
+	}
+	return nil
+}
+
+// Columns maps the column indices of args to their registered values.
+// The result can be passed to util.ExpandRule, offset is added to every index.
+func (v *Vocabulary) Columns(args []string, offset int) map[int][]string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	columns := make(map[int][]string)
+	for i, arg := range args {
+		if _, ok := v.terms[arg]; ok {
+			columns[i+offset] = v.values(arg)
+		}
+	}
+	return columns
+}
+
+// Matchers returns the matchers, which are used to detect patterns
+func (v *Vocabulary) Matchers() []util.IMatcher {
+	return v.matchers
+}
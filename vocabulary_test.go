@@ -0,0 +1,108 @@
+package fastac
+
+import (
+	"sync"
+	"testing"
+
+	m "github.com/oarkflow/fastac/model"
+)
+
+func TestVocabularyCheckRule(t *testing.T) {
+	v := NewVocabulary()
+	v.Register("act", "read", "write")
+	v.Register("eft", "allow", "deny")
+
+	model := m.NewModel()
+	if err := model.LoadModelFromText(reasonModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil, OptionVocabulary(v, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rule := range [][]string{
+		{"p", "alice", "data1", "read", "allow", ""},
+		{"p", "alice", "data1", "write", "deny", "blocked"},
+	} {
+		if _, err := e.AddRule(rule); err != nil {
+			t.Fatalf("AddRule(%v): %v", rule, err)
+		}
+	}
+	for _, rule := range [][]string{
+		{"p", "alice", "data1", "delete", "allow", ""},
+		{"p", "alice", "data1", "read", "grant", ""},
+	} {
+		if _, err := e.AddRule(rule); err == nil {
+			t.Fatalf("AddRule(%v) accepted an unknown term", rule)
+		}
+	}
+}
+
+func TestVocabularyCheckRequest(t *testing.T) {
+	v := NewVocabulary()
+	v.Register("act", "read", "write")
+
+	model := m.NewModel()
+	if err := model.LoadModelFromText(reasonModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil, OptionVocabulary(v, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// patterns are accepted in rules
+	if _, err := e.AddRule([]string{"p", "alice", "/data/1", "*", "allow", ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.Enforce("alice", "/data/1", "read"); err != nil {
+		t.Fatalf("Enforce with a known term: %v", err)
+	}
+	for _, act := range []string{"*", "re*", "delete"} {
+		if _, err := e.Enforce("alice", "/data/1", act); err == nil {
+			t.Fatalf("Enforce(alice, /data/1, %s) accepted an unknown term", act)
+		}
+	}
+}
+
+func TestVocabularyConcurrentRegister(t *testing.T) {
+	v := NewVocabulary()
+	v.Register("act", "read")
+
+	model := m.NewModel()
+	if err := model.LoadModelFromText(reasonModel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEnforcer(model, nil, OptionVocabulary(v, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone, err := e.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			v.Register("act", "write")
+			v.Unregister("act", "write")
+		}
+	}()
+	for _, enforcer := range []*Enforcer{e, clone} {
+		go func(enforcer *Enforcer) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, err := enforcer.Enforce("alice", "data1", "read"); err != nil {
+					t.Error(err)
+					return
+				}
+				enforcer.GetExpandedPolicy("p")
+			}
+		}(enforcer)
+	}
+	wg.Wait()
+}
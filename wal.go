@@ -0,0 +1,52 @@
+package fastac
+
+import (
+	"sync"
+
+	"github.com/oarkflow/fastac/storage/wal"
+)
+
+type writeAheadLog struct {
+	// mutations hold the read lock while they are logged and applied, CompactWAL holds the write lock
+	mutex sync.RWMutex
+	log   *wal.WAL
+}
+
+// Option to log every rule mutation to a write-ahead log, before it is applied to the model (default: none)
+// The rules of the log are replayed into the model, when the option is applied
+//
+//	log, _ := wal.Open("rules.wal", true)
+//	NewEnforcer(model, nil, OptionWAL(log))
+func OptionWAL(log *wal.WAL) Option {
+	return func(e *Enforcer) error {
+		if _, err := log.Replay(e.model); err != nil {
+			return err
+		}
+		e.wal = &writeAheadLog{log: log}
+		return nil
+	}
+}
+
+// CompactWAL replaces the write-ahead log by a snapshot of the current rules
+func (e *Enforcer) CompactWAL() error {
+	if e.wal == nil {
+		return nil
+	}
+	e.wal.mutex.Lock()
+	defer e.wal.mutex.Unlock()
+	return e.wal.log.Compact(e.model.Snapshot())
+}
+
+// logRules appends a mutation to the write-ahead log, the returned function must be called after the mutation is applied
+func (e *Enforcer) logRules(op string, rules ...[]string) (func(), error) {
+	if e.wal == nil {
+		return func() {}, nil
+	}
+	e.wal.mutex.RLock()
+	if err := e.wal.log.Append(op, rules...); err != nil {
+		e.GetLogger().Error("appending to the write-ahead log failed", "path", e.wal.log.Path(), "error", err)
+		e.wal.mutex.RUnlock()
+		return nil, err
+	}
+	return e.wal.mutex.RUnlock, nil
+}
@@ -0,0 +1,52 @@
+package fastac
+
+import (
+	"path/filepath"
+	"testing"
+
+	m "github.com/oarkflow/fastac/model"
+	"github.com/oarkflow/fastac/storage/wal"
+)
+
+func TestOptionWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.wal")
+	open := func() (*Enforcer, *wal.WAL) {
+		t.Helper()
+		log, err := wal.Open(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model := m.NewModel()
+		if err := model.LoadModelFromText(benchModel); err != nil {
+			t.Fatal(err)
+		}
+		e, err := NewEnforcer(model, nil, OptionWAL(log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e, log
+	}
+
+	e, log := open()
+	if err := e.AddRules([][]string{{"p", "reader", "data1", "read"}, {"g", "alice", "reader"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "alice", "data2", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RemoveRule([]string{"p", "alice", "data2", "write"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e, log = open()
+	defer log.Close()
+	if allowed, err := e.Enforce("alice", "data1", "read"); err != nil || !allowed {
+		t.Fatalf("Enforce: %v %v, want true", allowed, err)
+	}
+	if allowed, err := e.Enforce("alice", "data2", "write"); err != nil || allowed {
+		t.Fatalf("Enforce: %v %v, want false", allowed, err)
+	}
+}
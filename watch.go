@@ -0,0 +1,194 @@
+package fastac
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	m "github.com/oarkflow/fastac/model"
+	a "github.com/oarkflow/fastac/storage/adapter"
+	"github.com/oarkflow/fastac/str"
+)
+
+// DefaultWatchInterval is the interval, in which the PollingWatcher of OptionWatchFiles checks the files
+const DefaultWatchInterval = time.Second
+
+// FileWatcher notifies about changes of files, e.g. an adapter of fsnotify
+type FileWatcher interface {
+	// Watch calls onChange with the path of a changed file, until Close is called
+	Watch(paths []string, onChange func(path string)) error
+	// Close stops watching
+	Close() error
+}
+
+// PollingWatcher is a FileWatcher, which compares the modification times and sizes of the files periodically.
+// Files, which are replaced by renaming, e.g. by editors, are detected as well.
+// A change is reported, after the file has not changed for another interval, so files being written are not read half-written.
+type PollingWatcher struct {
+	interval time.Duration
+	once     sync.Once
+	stop     chan struct{}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewPollingWatcher creates a watcher, which checks the files every interval
+func NewPollingWatcher(interval time.Duration) *PollingWatcher {
+	return &PollingWatcher{interval: interval, stop: make(chan struct{})}
+}
+
+func (w *PollingWatcher) Watch(paths []string, onChange func(path string)) error {
+	states := make([]fileState, len(paths))
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		states[i] = fileState{info.ModTime(), info.Size()}
+	}
+	// pending are the changed states, which are reported, if they are still the same on the next tick
+	pending := make([]*fileState, len(paths))
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+			for i, path := range paths {
+				info, err := os.Stat(path)
+				if err != nil {
+					// the file may be replaced right now
+					continue
+				}
+				state := fileState{info.ModTime(), info.Size()}
+				switch {
+				case state == states[i]:
+					pending[i] = nil
+				case pending[i] == nil || *pending[i] != state:
+					pending[i] = &state
+				default:
+					states[i], pending[i] = state, nil
+					onChange(path)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (w *PollingWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	return nil
+}
+
+type fileWatch struct {
+	watcher FileWatcher
+}
+
+// Option to watch the model file and the CSV policy file and reload them on change (default: disabled)
+// The files are the model path passed to NewEnforcer and the path of a storage/adapter.FileAdapter.
+// After a change, the model file, or the model of the enforcer, if it was not loaded from a file, is parsed into a new model,
+// which is loaded with the rules of the adapter and swapped in at once under the write lock, so requests never see a partial reload.
+// The functions, the profile and the model listeners registered through the enforcer are kept.
+// If the new files are invalid, the error is logged and the current model is kept.
+// Reloads need the write lock, so the option is only supported by a SyncedEnforcer.
+// The files are polled every DefaultWatchInterval, see OptionFileWatcher to use e.g. fsnotify.
+//
+//	NewSyncedEnforcer("model.conf", "policy.csv", OptionWatchFiles(true))
+func OptionWatchFiles(enable bool) Option {
+	return func(e *Enforcer) error {
+		if e.watch != nil {
+			if err := e.watch.watcher.Close(); err != nil {
+				return err
+			}
+			e.watch = nil
+		}
+		if !enable {
+			return nil
+		}
+		if e.lock == nil {
+			return errors.New(str.ERR_WATCH_UNSYNCED)
+		}
+		paths := []string{}
+		if e.modelPath != "" {
+			paths = append(paths, e.modelPath)
+		}
+		if fa, ok := e.adapter.(*a.FileAdapter); ok {
+			paths = append(paths, fa.Path())
+		}
+		if len(paths) == 0 {
+			return errors.New(str.ERR_NO_WATCH_FILES)
+		}
+		watcher := e.fileWatcher
+		if watcher == nil {
+			watcher = NewPollingWatcher(DefaultWatchInterval)
+		}
+		fw := &fileWatch{watcher: watcher}
+		if err := watcher.Watch(paths, func(path string) {
+			e.onFileChange(fw, path)
+		}); err != nil {
+			return err
+		}
+		e.watch = fw
+		return nil
+	}
+}
+
+// Option to set the FileWatcher of OptionWatchFiles (default: a PollingWatcher)
+// A watcher, which is already watching, is replaced.
+func OptionFileWatcher(watcher FileWatcher) Option {
+	return func(e *Enforcer) error {
+		e.fileWatcher = watcher
+		if e.watch == nil {
+			return nil
+		}
+		return OptionWatchFiles(true)(e)
+	}
+}
+
+// onFileChange reloads the model and the rules, after a watched file changed
+func (e *Enforcer) onFileChange(fw *fileWatch, path string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.watch != fw {
+		return
+	}
+	if err := e.reloadFiles(); err != nil {
+		e.GetLogger().Error("reloading changed file failed", "path", path, "error", err)
+		return
+	}
+	e.GetLogger().Info("reloaded changed file", "path", path)
+}
+
+// reloadFiles parses the model file, loads the rules of the adapter into it and replaces the model
+func (e *Enforcer) reloadFiles() error {
+	model := m.NewModel()
+	var err error
+	if e.modelPath != "" {
+		err = model.LoadModel(e.modelPath)
+	} else {
+		err = model.LoadModelFromText(e.model.String())
+	}
+	if err != nil {
+		return err
+	}
+	if err := e.copySettings(model); err != nil {
+		return err
+	}
+	if err := e.adapter.LoadPolicy(e.loadTarget(model)); err != nil {
+		return err
+	}
+	// SetModel moves the storage controller to the model, its queued operations are flushed later
+	e.SetModel(model)
+	e.bindRoleFunctions()
+	return nil
+}
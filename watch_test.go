@@ -0,0 +1,23 @@
+package fastac
+
+import "testing"
+
+func TestReloadFilesKeepsQueuedOperations(t *testing.T) {
+	adapter := &recordingAdapter{}
+	e := testEnforcer(t, adapter, OptionAutosave(false))
+	if _, err := e.AddRule([]string{"p", "alice", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.reloadFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRule([]string{"p", "bob", "data1", "read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(adapter.added) != 2 {
+		t.Fatalf("flushed %v, want the rules of alice and bob", adapter.added)
+	}
+}